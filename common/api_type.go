@@ -31,6 +31,12 @@ func ChannelType2APIType(channelType int) (int, bool) {
 		apiType = constant.APITypeMiniMax
 	case constant.ChannelTypeXiaomi:
 		apiType = constant.APITypeXiaomi
+	case constant.ChannelTypeCohere:
+		apiType = constant.APITypeCohere
+	case constant.ChannelTypeDashScope:
+		apiType = constant.APITypeDashScope
+	case constant.ChannelTypeSelfHosted:
+		apiType = constant.APITypeSelfHosted
 	}
 	if apiType == -1 {
 		return constant.APITypeOpenAI, false
@@ -199,8 +199,9 @@ const (
 )
 
 const (
-	TopUpStatusPending = "pending"
-	TopUpStatusSuccess = "success"
-	TopUpStatusExpired = "expired"
-	TopUpStatusFailed  = "failed"
+	TopUpStatusPending  = "pending"
+	TopUpStatusSuccess  = "success"
+	TopUpStatusExpired  = "expired"
+	TopUpStatusFailed   = "failed"
+	TopUpStatusRefunded = "refunded"
 )
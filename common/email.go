@@ -20,6 +20,11 @@ func generateMessageID() (string, error) {
 }
 
 func SendEmail(subject string, receiver string, content string) error {
+	return SendEmailWithReplyTo(subject, receiver, content, "")
+}
+
+// SendEmailWithReplyTo 发送邮件并设置 Reply-To，用于将用户的邮件回复路由回系统
+func SendEmailWithReplyTo(subject string, receiver string, content string, replyTo string) error {
 	if SMTPFrom == "" { // for compatibility
 		SMTPFrom = SMTPAccount
 	}
@@ -31,13 +36,18 @@ func SendEmail(subject string, receiver string, content string) error {
 		return fmt.Errorf("SMTP 服务器未配置")
 	}
 	encodedSubject := fmt.Sprintf("=?UTF-8?B?%s?=", base64.StdEncoding.EncodeToString([]byte(subject)))
+	replyToHeader := ""
+	if replyTo = strings.TrimSpace(replyTo); replyTo != "" && !strings.ContainsAny(replyTo, "\r\n") {
+		replyToHeader = fmt.Sprintf("Reply-To: %s\r\n", replyTo)
+	}
 	mail := []byte(fmt.Sprintf("To: %s\r\n"+
 		"From: %s <%s>\r\n"+
+		"%s"+
 		"Subject: %s\r\n"+
 		"Date: %s\r\n"+
 		"Message-ID: %s\r\n"+ // 添加 Message-ID 头
 		"Content-Type: text/html; charset=UTF-8\r\n\r\n%s\r\n",
-		receiver, SystemName, SMTPFrom, encodedSubject, time.Now().Format(time.RFC1123Z), id, content))
+		receiver, SystemName, SMTPFrom, replyToHeader, encodedSubject, time.Now().Format(time.RFC1123Z), id, content))
 	auth := smtp.PlainAuth("", SMTPAccount, SMTPToken, SMTPServer)
 	addr := fmt.Sprintf("%s:%d", SMTPServer, SMTPPort)
 	to := strings.Split(receiver, ";")
@@ -134,6 +134,16 @@ func initConstantEnv() {
 	constant.MaxVideoUploadMB = GetEnvOrDefault("MAX_VIDEO_UPLOAD_MB", 128)
 	constant.StoredImagePoolMB = GetEnvOrDefault("STORED_IMAGE_POOL_MB", 512)
 	constant.StoredVideoPoolMB = GetEnvOrDefault("STORED_VIDEO_POOL_MB", 1024) // 1GB
+	constant.BlobStoreType = strings.ToLower(GetEnvOrDefaultString("BLOB_STORE_TYPE", "db"))
+	constant.BlobStoreLocalDir = GetEnvOrDefaultString("BLOB_STORE_LOCAL_DIR", "./data/blobs")
+	constant.BlobStoreS3Endpoint = GetEnvOrDefaultString("BLOB_STORE_S3_ENDPOINT", "")
+	constant.BlobStoreS3PublicEndpoint = GetEnvOrDefaultString("BLOB_STORE_S3_PUBLIC_ENDPOINT", "")
+	constant.BlobStoreS3Region = GetEnvOrDefaultString("BLOB_STORE_S3_REGION", "us-east-1")
+	constant.BlobStoreS3Bucket = GetEnvOrDefaultString("BLOB_STORE_S3_BUCKET", "")
+	constant.BlobStoreS3AccessKey = GetEnvOrDefaultString("BLOB_STORE_S3_ACCESS_KEY", "")
+	constant.BlobStoreS3SecretKey = GetEnvOrDefaultString("BLOB_STORE_S3_SECRET_KEY", "")
+	constant.BlobStoreS3PathStyle = GetEnvOrDefaultBool("BLOB_STORE_S3_PATH_STYLE", true)
+	constant.BlobStorePresignTTLSeconds = GetEnvOrDefault("BLOB_STORE_PRESIGN_TTL_SECONDS", 900)
 	constant.StreamScannerMaxBufferMB = GetEnvOrDefault("STREAM_SCANNER_MAX_BUFFER_MB", 64)
 	// MaxRequestBodyMB 请求体最大大小（解压后），用于防止超大请求/zip bomb导致内存暴涨
 	constant.MaxRequestBodyMB = GetEnvOrDefault("MAX_REQUEST_BODY_MB", 128)
@@ -0,0 +1,94 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InternalResponseWriter 在内存中缓存内部请求的响应，供服务端在进程内复用 relay 流程时读取结果
+type InternalResponseWriter struct {
+	header http.Header
+	body   bytes.Buffer
+	status int
+}
+
+var _ gin.ResponseWriter = (*InternalResponseWriter)(nil)
+
+func NewInternalResponseWriter() *InternalResponseWriter {
+	return &InternalResponseWriter{header: http.Header{}}
+}
+
+// NewInternalContext 基于当前请求派生内部请求的上下文：沿用用户、令牌等上下文键，响应写入内存
+func NewInternalContext(c *gin.Context, request *http.Request) (*gin.Context, *InternalResponseWriter) {
+	writer := NewInternalResponseWriter()
+	sub := c.Copy()
+	sub.Writer = writer
+	sub.Request = request
+	return sub, writer
+}
+
+func (w *InternalResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *InternalResponseWriter) WriteHeader(code int) {
+	if w.status == 0 && code > 0 {
+		w.status = code
+	}
+}
+
+func (w *InternalResponseWriter) WriteHeaderNow() {
+	w.WriteHeader(http.StatusOK)
+}
+
+func (w *InternalResponseWriter) Write(data []byte) (int, error) {
+	w.WriteHeaderNow()
+	return w.body.Write(data)
+}
+
+func (w *InternalResponseWriter) WriteString(s string) (int, error) {
+	w.WriteHeaderNow()
+	return w.body.WriteString(s)
+}
+
+// Status 未写入响应时返回 200，与 gin 的默认行为一致
+func (w *InternalResponseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *InternalResponseWriter) Size() int {
+	if w.status == 0 {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *InternalResponseWriter) Written() bool {
+	return w.status != 0
+}
+
+func (w *InternalResponseWriter) Body() []byte {
+	return w.body.Bytes()
+}
+
+func (w *InternalResponseWriter) Flush() {}
+
+func (w *InternalResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("internal response writer does not support hijacking")
+}
+
+func (w *InternalResponseWriter) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
+
+func (w *InternalResponseWriter) Pusher() http.Pusher {
+	return nil
+}
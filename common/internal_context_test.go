@@ -0,0 +1,29 @@
+package common
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNewInternalContextCapturesResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	parent := &gin.Context{}
+	parent.Set("id", 7)
+
+	request, _ := http.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	sub, writer := NewInternalContext(parent, request)
+	sub.Set("channel_id", 3)
+	sub.JSON(http.StatusTeapot, gin.H{"ok": true})
+
+	if writer.Status() != http.StatusTeapot || string(writer.Body()) != `{"ok":true}` {
+		t.Fatalf("captured response = %d %s", writer.Status(), writer.Body())
+	}
+	if sub.GetInt("id") != 7 {
+		t.Fatal("internal context should inherit the parent's keys")
+	}
+	if _, ok := parent.Get("channel_id"); ok {
+		t.Fatal("keys set on the internal context must not leak into the parent")
+	}
+}
@@ -6,7 +6,7 @@ const (
 	_                  // 2 removed: PaLM
 	_                  // 3
 	_                  // 4
-	_                  // 5 removed: Ali
+	APITypeDashScope   = 5
 	_                  // 6 removed: Xunfei
 	_                  // 7
 	_                  // 8 removed: Tencent
@@ -15,7 +15,7 @@ const (
 	APITypeOllama      = 11
 	_                  // 12
 	APITypeAws         = 13
-	_                  // 14 removed: Cohere
+	APITypeCohere      = 14
 	_                  // 15 removed: Dify
 	_                  // 16 removed: Jina
 	_                  // 17 removed: Cloudflare
@@ -23,7 +23,7 @@ const (
 	APITypeVertexAi    = 19
 	_                  // 20 removed: Mistral
 	APITypeDeepSeek    = 21
-	_                  // 22
+	APITypeSelfHosted  = 22
 	_                  // 23 removed: VolcEngine
 	_                  // 24
 	APITypeOpenRouter  = 25
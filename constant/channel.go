@@ -9,16 +9,19 @@ const (
 	ChannelTypeMidjourneyPlus = 5
 	ChannelTypeCustom         = 8
 	ChannelTypeAnthropic      = 14
+	ChannelTypeDashScope      = 17
 	ChannelTypeOpenRouter     = 20
 	ChannelTypeGemini         = 24
 	ChannelTypeMoonshot       = 25
 	ChannelTypeZhipu_v4       = 26
 	ChannelTypeAws            = 33
+	ChannelTypeCohere         = 34
 	ChannelTypeMiniMax        = 35
 	ChannelTypeSunoAPI        = 36
 	ChannelTypeSiliconFlow    = 40
 	ChannelTypeVertexAi       = 41
 	ChannelTypeDeepSeek       = 43
+	ChannelTypeSelfHosted     = 44
 	ChannelTypeXiaomi         = 6
 	ChannelTypeDummy          = 7 // this one is only for count, do not add any channel after this
 )
@@ -41,7 +44,7 @@ var ChannelBaseURLs = []string{
 	"https://api.anthropic.com", // 14 Anthropic
 	"",                          // 15 (removed)
 	"",                          // 16 (removed)
-	"",                          // 17 (removed)
+	"https://dashscope.aliyuncs.com", // 17 DashScope
 	"",                          // 18 (removed)
 	"",                          // 19 (removed)
 	"https://openrouter.ai/api", // 20 OpenRouter
@@ -58,7 +61,7 @@ var ChannelBaseURLs = []string{
 	"",                                          // 31 (removed)
 	"",                                          // 32
 	"",                                          // 33 AWS
-	"",                                          // 34 (removed)
+	"https://api.cohere.com",                    // 34 Cohere
 	"https://api.minimaxi.com/v1",               // 35 MiniMax
 	"",                                          // 36 SunoAPI
 	"",                                          // 37 (removed)
@@ -68,7 +71,7 @@ var ChannelBaseURLs = []string{
 	"",                                          // 41 VertexAi
 	"",                                          // 42 (removed)
 	"https://api.deepseek.com",                  // 43 DeepSeek
-	"",                                          // 44 (removed)
+	"http://localhost:8000",                     // 44 SelfHosted
 	"",                                          // 45 (removed)
 	"",                                          // 46 (removed)
 	"",                                          // 47 (removed)
@@ -82,15 +85,18 @@ var ChannelTypeNames = map[int]string{
 	ChannelTypeOllama:      "Ollama",
 	ChannelTypeCustom:      "Custom",
 	ChannelTypeAnthropic:   "Anthropic",
+	ChannelTypeDashScope:   "DashScope",
 	ChannelTypeOpenRouter:  "OpenRouter",
 	ChannelTypeGemini:      "Gemini",
 	ChannelTypeMoonshot:    "Moonshot",
 	ChannelTypeZhipu_v4:    "ZhipuV4",
 	ChannelTypeAws:         "AWS",
+	ChannelTypeCohere:      "Cohere",
 	ChannelTypeMiniMax:     "MiniMax",
 	ChannelTypeSiliconFlow: "SiliconFlow",
 	ChannelTypeVertexAi:    "VertexAI",
 	ChannelTypeDeepSeek:    "DeepSeek",
+	ChannelTypeSelfHosted:  "SelfHosted",
 	ChannelTypeXiaomi:      "Xiaomi",
 }
 
@@ -10,6 +10,15 @@ const (
 	ContextKeyOriginalModel    ContextKey = "original_model"
 	ContextKeyRequestStartTime ContextKey = "request_start_time"
 
+	// ContextKeyVirtualModel stores the virtual model name requested by the client; original_model holds the resolved target.
+	ContextKeyVirtualModel ContextKey = "virtual_model"
+	// ContextKeyVirtualModelFallbacks stores the remaining fallback targets of the virtual model.
+	ContextKeyVirtualModelFallbacks ContextKey = "virtual_model_fallbacks"
+	// ContextKeyChannelTrafficTrace stores the canary sampling decision of the request.
+	ContextKeyChannelTrafficTrace ContextKey = "channel_traffic_trace"
+	// ContextKeyShadowChannels stores the shadow channels the request should be mirrored to.
+	ContextKeyShadowChannels ContextKey = "shadow_channels"
+
 	/* token related keys */
 	ContextKeyTokenUnlimited         ContextKey = "token_unlimited_quota"
 	ContextKeyTokenQuota             ContextKey = "token_quota"
@@ -21,6 +30,7 @@ const (
 	ContextKeyTokenModelLimitEnabled ContextKey = "token_model_limit_enabled"
 	ContextKeyTokenModelLimit        ContextKey = "token_model_limit"
 	ContextKeyTokenCrossGroupRetry   ContextKey = "token_cross_group_retry"
+	ContextKeyTokenReasoningOutput   ContextKey = "token_reasoning_output"
 
 	/* channel related keys */
 	ContextKeyChannelId                ContextKey = "channel_id"
@@ -20,6 +20,21 @@ var ErrorLogEnabled bool
 var StoredImagePoolMB int
 var StoredVideoPoolMB int
 
+// Blob storage backend for stored images/videos: "db" (default, bytes kept in the database),
+// "local" (filesystem under BlobStoreLocalDir) or "s3" (any S3-compatible bucket, e.g. MinIO).
+var BlobStoreType string
+var BlobStoreLocalDir string
+var BlobStoreS3Endpoint string
+var BlobStoreS3PublicEndpoint string
+var BlobStoreS3Region string
+var BlobStoreS3Bucket string
+var BlobStoreS3AccessKey string
+var BlobStoreS3SecretKey string
+var BlobStoreS3PathStyle bool
+
+// BlobStorePresignTTLSeconds is the lifetime of presigned download URLs; 0 disables redirects.
+var BlobStorePresignTTLSeconds int
+
 // TrustedRedirectDomains is a list of trusted domains for redirect URL validation.
 // Domains support subdomain matching (e.g., "example.com" matches "sub.example.com").
 var TrustedRedirectDomains []string
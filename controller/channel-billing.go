@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/zhongruan0522/new-api/common"
@@ -194,10 +196,11 @@ func updateChannelSiliconFlowBalance(channel *model.Channel) (float64, error) {
 	if response.Code != 20000 {
 		return 0, fmt.Errorf("code: %d, message: %s", response.Code, response.Message)
 	}
-	balance, err := strconv.ParseFloat(response.Data.TotalBalance, 64)
+	balanceCny, err := strconv.ParseFloat(response.Data.TotalBalance, 64)
 	if err != nil {
 		return 0, err
 	}
+	balance := cnyToUSD(balanceCny)
 	channel.UpdateBalance(balance)
 	return balance, nil
 }
@@ -223,10 +226,11 @@ func updateChannelDeepSeekBalance(channel *model.Channel) (float64, error) {
 	if index == -1 {
 		return 0, errors.New("currency CNY not found")
 	}
-	balance, err := strconv.ParseFloat(response.BalanceInfos[index].TotalBalance, 64)
+	balanceCny, err := strconv.ParseFloat(response.BalanceInfos[index].TotalBalance, 64)
 	if err != nil {
 		return 0, err
 	}
+	balance := cnyToUSD(balanceCny)
 	channel.UpdateBalance(balance)
 	return balance, nil
 }
@@ -275,12 +279,19 @@ func updateChannelMoonshotBalance(channel *model.Channel) (float64, error) {
 	if !response.Status || response.Code != 0 {
 		return 0, fmt.Errorf("failed to update moonshot balance, status: %v, code: %d, scode: %s", response.Status, response.Code, response.Scode)
 	}
-	availableBalanceCny := response.Data.AvailableBalance
-	availableBalanceUsd := decimal.NewFromFloat(availableBalanceCny).Div(decimal.NewFromFloat(operation_setting.Price)).InexactFloat64()
+	availableBalanceUsd := cnyToUSD(response.Data.AvailableBalance)
 	channel.UpdateBalance(availableBalanceUsd)
 	return availableBalanceUsd, nil
 }
 
+// cnyToUSD 按充值汇率将以人民币计价的上游余额换算为美元，保证渠道余额、余额历史与低余额阈值使用同一币种
+func cnyToUSD(amount float64) float64 {
+	if operation_setting.Price <= 0 {
+		return amount
+	}
+	return decimal.NewFromFloat(amount).Div(decimal.NewFromFloat(operation_setting.Price)).InexactFloat64()
+}
+
 func updateChannelBalance(channel *model.Channel) (float64, error) {
 	baseURL := constant.ChannelBaseURLs[channel.Type]
 	if channel.GetBaseURL() == "" {
@@ -361,6 +372,7 @@ func UpdateChannelBalance(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	service.CheckChannelLowBalance(channel, balance)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -374,7 +386,8 @@ func updateAllChannelsBalance() error {
 		return err
 	}
 	for _, channel := range channels {
-		if channel.Status != common.ChannelStatusEnabled {
+		// 因低余额被自动禁用的渠道也需要继续查询，以便余额恢复后重新启用
+		if channel.Status != common.ChannelStatusEnabled && !service.IsChannelDisabledByLowBalance(channel) {
 			continue
 		}
 		if channel.ChannelInfo.IsMultiKey {
@@ -388,6 +401,7 @@ func updateAllChannelsBalance() error {
 		if err != nil {
 			continue
 		} else {
+			service.CheckChannelLowBalance(channel, balance)
 			// err is nil & balance <= 0 means quota is used up
 			if balance <= 0 {
 				service.DisableChannel(*types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, "", channel.GetAutoBan()), "余额不足")
@@ -420,3 +434,55 @@ func AutomaticallyUpdateChannels(frequency int) {
 		common.SysLog("channels update done")
 	}
 }
+
+var autoUpdateBalanceOnce sync.Once
+
+// AutomaticallyUpdateChannelBalances 按监控设置定时查询渠道余额，并清理过期的余额历史
+func AutomaticallyUpdateChannelBalances() {
+	// 只在Master节点定时查询余额
+	if !common.IsMasterNode {
+		return
+	}
+	autoUpdateBalanceOnce.Do(func() {
+		for {
+			setting := operation_setting.GetMonitorSetting()
+			if !setting.AutoUpdateBalanceEnabled {
+				time.Sleep(1 * time.Minute)
+				continue
+			}
+			frequency := setting.AutoUpdateBalanceMinutes
+			if frequency < 1 {
+				frequency = 1
+			}
+			time.Sleep(time.Duration(int(math.Round(frequency))) * time.Minute)
+			common.SysLog("automatically updating all channels balance")
+			_ = updateAllChannelsBalance()
+			common.SysLog("automatically channel balance update finished")
+
+			if days := operation_setting.GetMonitorSetting().BalanceHistoryRetentionDays; days > 0 {
+				cutoff := time.Now().AddDate(0, 0, -days).Unix()
+				if _, err := model.DeleteChannelBalanceHistoryBefore(cutoff); err != nil {
+					common.SysError("failed to clean channel balance history: " + err.Error())
+				}
+			}
+		}
+	})
+}
+
+func GetChannelBalanceHistory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if days <= 0 || days > 365 {
+		days = 7
+	}
+	histories, err := model.GetChannelBalanceHistory(id, time.Now().AddDate(0, 0, -days).Unix())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, service.BuildChannelBalanceStats(histories))
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/model"
+	"github.com/zhongruan0522/new-api/relay/channel/selfhosted"
+
+	"github.com/gin-gonic/gin"
+)
+
+const selfHostedMetricsInterval = 15 * time.Second
+
+var selfHostedMetricsOnce sync.Once
+
+// AutomaticallyRefreshSelfHostedMetrics 定时抓取自部署渠道的负载指标并更新饱和状态。
+// 饱和状态只保存在本节点内存中，因此每个节点都会运行，不区分主从。
+func AutomaticallyRefreshSelfHostedMetrics() {
+	selfHostedMetricsOnce.Do(func() {
+		for {
+			refreshSelfHostedMetrics()
+			time.Sleep(selfHostedMetricsInterval)
+		}
+	})
+}
+
+func refreshSelfHostedMetrics() {
+	channels, err := model.GetEnabledSelfHostedChannels()
+	if err != nil {
+		common.SysError("failed to query self-hosted channels: " + err.Error())
+		return
+	}
+	channelIds := make([]int, 0, len(channels))
+	for _, channel := range channels {
+		channelIds = append(channelIds, channel.Id)
+		saturated := false
+		metrics, err := fetchSelfHostedMetrics(channel)
+		if err != nil {
+			// 抓取失败不影响选渠道，交给请求失败重试与自动禁用处理
+			common.SysError(fmt.Sprintf("failed to fetch metrics of self-hosted channel #%d: %s", channel.Id, err.Error()))
+		} else {
+			saturated = metrics.IsSaturated(channel.GetOtherSettings())
+		}
+		if saturated != model.IsChannelSaturated(channel.Id) {
+			common.SysLog(fmt.Sprintf("self-hosted channel #%d saturated: %t", channel.Id, saturated))
+		}
+		model.SetChannelSaturated(channel.Id, saturated)
+	}
+	model.RetainChannelSaturation(channelIds)
+}
+
+func fetchSelfHostedMetrics(channel *model.Channel) (*selfhosted.ServerMetrics, error) {
+	baseURL := constant.ChannelBaseURLs[channel.Type]
+	if channel.GetBaseURL() != "" {
+		baseURL = channel.GetBaseURL()
+	}
+	key := strings.TrimSpace(strings.Split(channel.Key, "\n")[0])
+	backend, _ := channel.GetOtherSettings().SelfHostedBackend.Normalize()
+	return selfhosted.FetchServerMetrics(baseURL, key, channel.GetSetting().Proxy, backend)
+}
+
+// SelfHostedMetrics 实时获取自部署渠道的负载指标
+func SelfHostedMetrics(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid channel id",
+		})
+		return
+	}
+
+	channel, err := model.GetChannelById(id, true)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "Channel not found",
+		})
+		return
+	}
+
+	if channel.Type != constant.ChannelTypeSelfHosted {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "This operation is only supported for self-hosted channels",
+		})
+		return
+	}
+
+	metrics, err := fetchSelfHostedMetrics(channel)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("获取自部署服务指标失败: %s", err.Error()),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"metrics":   metrics,
+			"saturated": metrics.IsSaturated(channel.GetOtherSettings()),
+		},
+	})
+}
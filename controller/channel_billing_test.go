@@ -0,0 +1,25 @@
+package controller
+
+import (
+	"math"
+	"testing"
+
+	"github.com/zhongruan0522/new-api/setting/operation_setting"
+)
+
+func TestCnyToUSD(t *testing.T) {
+	oldPrice := operation_setting.Price
+	t.Cleanup(func() {
+		operation_setting.Price = oldPrice
+	})
+
+	operation_setting.Price = 7.3
+	if got := cnyToUSD(73); math.Abs(got-10) > 1e-9 {
+		t.Fatalf("cnyToUSD(73) = %v, want 10", got)
+	}
+	// 未配置汇率时不换算，避免除零
+	operation_setting.Price = 0
+	if got := cnyToUSD(73); got != 73 {
+		t.Fatalf("cnyToUSD(73) without rate = %v, want 73", got)
+	}
+}
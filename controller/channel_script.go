@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/model"
+	"github.com/zhongruan0522/new-api/pkg/scripthook"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+type channelScriptTestRequest struct {
+	ChannelId int                    `json:"channel_id"` // script 为空时使用该渠道已保存的脚本
+	Script    string                 `json:"script"`
+	Hook      string                 `json:"hook"`  // transform_request / transform_response / transform_event
+	Input     string                 `json:"input"` // 请求体、响应体或单个 SSE data
+	Context   map[string]interface{} `json:"context"`
+}
+
+// TestChannelScript POST /api/channel/script/test
+// 试运行渠道转换脚本，使用与线上相同的执行限制，不发起上游请求
+func TestChannelScript(c *gin.Context) {
+	var req channelScriptTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if !slices.Contains(scripthook.Hooks, req.Hook) {
+		common.ApiErrorMsg(c, fmt.Sprintf("hook 必须是 %s 之一", strings.Join(scripthook.Hooks, "、")))
+		return
+	}
+	script := req.Script
+	if strings.TrimSpace(script) == "" && req.ChannelId > 0 {
+		channel, err := model.GetChannelById(req.ChannelId, true)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		script = channel.GetOtherSettings().TransformScript
+	}
+	if strings.TrimSpace(script) == "" {
+		common.ApiError(c, errors.New("脚本为空"))
+		return
+	}
+
+	prog, err := scripthook.Compile(script)
+	if err != nil {
+		common.ApiErrorMsg(c, "脚本编译失败："+err.Error())
+		return
+	}
+	if !prog.Has(req.Hook) {
+		common.ApiErrorMsg(c, fmt.Sprintf("脚本未定义 %s，线上将原样透传", req.Hook))
+		return
+	}
+
+	res, err := prog.Run(req.Hook, []byte(req.Input), req.Context, relaycommon.ChannelScriptLimits())
+	data := gin.H{
+		"steps":       res.Steps,
+		"duration_ms": res.Duration.Milliseconds(),
+	}
+	if err != nil {
+		// 返回与线上一致的错误形态，便于确认客户端会收到什么
+		newAPIError := types.NewError(fmt.Errorf("channel script %s failed: %w", req.Hook, err), types.ErrorCodeChannelScriptFailed, types.ErrOptionWithSkipRetry())
+		data["error"] = newAPIError.ToOpenAIError()
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": newAPIError.Error(),
+			"data":    data,
+		})
+		return
+	}
+	data["output"] = string(res.Output)
+	data["dropped"] = res.Dropped
+	common.ApiSuccess(c, data)
+}
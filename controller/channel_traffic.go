@@ -0,0 +1,254 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/middleware"
+	"github.com/zhongruan0522/new-api/model"
+	"github.com/zhongruan0522/new-api/relay"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/relay/helper"
+	"github.com/zhongruan0522/new-api/service"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+// shadowRequestSnapshot 影子请求在原请求结束后于后台重放，需提前复制请求数据
+type shadowRequestSnapshot struct {
+	method      string
+	url         string
+	header      http.Header
+	body        []byte
+	relayFormat types.RelayFormat
+	modelName   string
+	requestId   string
+}
+
+func newChannelTrafficSample(mode dto.ChannelTrafficMode, role string, candidateId int, channelId int, info *relaycommon.RelayInfo, latency time.Duration, apiErr *types.NewAPIError) *model.ChannelTrafficSample {
+	sample := &model.ChannelTrafficSample{
+		CandidateChannelId: candidateId,
+		ChannelId:          channelId,
+		Mode:               string(mode),
+		Role:               role,
+		RequestId:          info.RequestId,
+		ModelName:          info.OriginModelName,
+		Success:            apiErr == nil,
+		StatusCode:         http.StatusOK,
+		LatencyMs:          latency.Milliseconds(),
+	}
+	if apiErr != nil {
+		sample.StatusCode = apiErr.StatusCode
+		sample.ErrorMessage = common.LocalLogPreview(apiErr.Error())
+	} else if info.FinalUsage != nil {
+		sample.PromptTokens = info.FinalUsage.PromptTokens
+		sample.CompletionTokens = info.FinalUsage.CompletionTokens
+	}
+	return sample
+}
+
+// recordCanaryAttempt 记录灰度采样请求的首次尝试结果；候选与对照都只取首次尝试，保证对比口径一致
+func recordCanaryAttempt(c *gin.Context, info *relaycommon.RelayInfo, channelId int, attemptStart time.Time, apiErr *types.NewAPIError) {
+	trace := service.GetChannelTrafficTrace(c)
+	if trace == nil || trace.Recorded {
+		return
+	}
+	trace.Recorded = true
+	service.RecordChannelTrafficSample(newChannelTrafficSample(dto.ChannelTrafficModeCanary, trace.Role, trace.CandidateChannelId, channelId, info, time.Since(attemptStart), apiErr))
+}
+
+// startShadowTraffic 原请求结束后记录对照样本，并在后台把请求镜像到采样命中的影子渠道；影子请求不计费、不写消费日志
+func startShadowTraffic(c *gin.Context, info *relaycommon.RelayInfo, relayFormat types.RelayFormat, apiErr *types.NewAPIError) {
+	shadows := service.GetShadowChannels(c)
+	if len(shadows) == 0 {
+		return
+	}
+	if r, ok := info.Request.(*dto.OpenAIResponsesRequest); ok && r.PreviousResponseID != "" {
+		// 续接会话依赖网关保存的上下文，影子渠道无法等价重放
+		return
+	}
+	body, err := common.GetRequestBody(c)
+	if err != nil {
+		return
+	}
+	snapshot := &shadowRequestSnapshot{
+		method:      c.Request.Method,
+		url:         c.Request.URL.String(),
+		header:      c.Request.Header.Clone(),
+		body:        bytes.Clone(body),
+		relayFormat: relayFormat,
+		modelName:   info.OriginModelName,
+		requestId:   info.RequestId,
+	}
+
+	latency := time.Since(common.GetContextKeyTime(c, constant.ContextKeyRequestStartTime))
+	for _, shadow := range shadows {
+		service.RecordChannelTrafficSample(newChannelTrafficSample(dto.ChannelTrafficModeShadow, model.ChannelTrafficRoleBaseline, shadow.Id, c.GetInt("channel_id"), info, latency, apiErr))
+		shadowCtx, err := newShadowContext(c, snapshot)
+		if err != nil {
+			continue
+		}
+		shadow := shadow
+		gopool.Go(func() {
+			runShadowRequest(shadowCtx, snapshot, shadow)
+		})
+	}
+}
+
+// newShadowContext 在原请求结束前为每个影子请求派生独立的内部上下文，上下文键与响应都不与原请求共享
+func newShadowContext(c *gin.Context, snapshot *shadowRequestSnapshot) (*gin.Context, error) {
+	req, err := http.NewRequest(snapshot.method, snapshot.url, bytes.NewReader(snapshot.body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = snapshot.header.Clone()
+	shadowCtx, _ := common.NewInternalContext(c, req)
+	for _, key := range []string{common.KeyBodyStorage, common.KeyRequestBody, string(constant.ContextKeyFileSourcesToCleanup),
+		string(constant.ContextKeyShadowChannels), string(constant.ContextKeyChannelTrafficTrace)} {
+		delete(shadowCtx.Keys, key)
+	}
+	return shadowCtx, nil
+}
+
+func runShadowRequest(c *gin.Context, snapshot *shadowRequestSnapshot, channel *model.Channel) {
+	tik := time.Now()
+	info, usage, apiErr := doShadowRequest(c, snapshot, channel)
+	if info == nil {
+		info = &relaycommon.RelayInfo{RequestId: snapshot.requestId, OriginModelName: snapshot.modelName}
+	}
+	info.FinalUsage = usage
+	sample := newChannelTrafficSample(dto.ChannelTrafficModeShadow, model.ChannelTrafficRoleCandidate, channel.Id, channel.Id, info, time.Since(tik), apiErr)
+	model.RecordChannelTrafficSample(sample)
+}
+
+func doShadowRequest(c *gin.Context, snapshot *shadowRequestSnapshot, channel *model.Channel) (*relaycommon.RelayInfo, *dto.Usage, *types.NewAPIError) {
+	common.SetContextKey(c, constant.ContextKeyRequestStartTime, time.Now())
+
+	if apiErr := middleware.SetupContextForSelectedChannel(c, channel, snapshot.modelName); apiErr != nil {
+		return nil, nil, apiErr
+	}
+	request, err := helper.GetAndValidateRequest(c, snapshot.relayFormat)
+	if err != nil {
+		return nil, nil, types.NewError(err, types.ErrorCodeInvalidRequest)
+	}
+	info, err := relaycommon.GenRelayInfo(c, snapshot.relayFormat, request, nil)
+	if err != nil {
+		return nil, nil, types.NewError(err, types.ErrorCodeGenRelayInfoFailed)
+	}
+	info.InitChannelMeta(c)
+	if err := helper.ModelMappedHelper(c, info, request); err != nil {
+		return info, nil, types.NewError(err, types.ErrorCodeChannelModelMappedError)
+	}
+	request.SetModelName(info.UpstreamModelName)
+
+	adaptor := relay.GetAdaptor(info.ApiType)
+	if adaptor == nil {
+		return info, nil, types.NewError(fmt.Errorf("invalid api type: %d", info.ApiType), types.ErrorCodeInvalidApiType)
+	}
+	adaptor.Init(info)
+
+	var convertedRequest any
+	switch r := request.(type) {
+	case *dto.GeneralOpenAIRequest:
+		convertedRequest, err = adaptor.ConvertOpenAIRequest(c, info, r)
+	case *dto.OpenAIResponsesRequest:
+		convertedRequest, err = adaptor.ConvertOpenAIResponsesRequest(c, info, *r)
+	case *dto.ClaudeRequest:
+		convertedRequest, err = adaptor.ConvertClaudeRequest(c, info, r)
+	case *dto.GeminiChatRequest:
+		convertedRequest, err = adaptor.ConvertGeminiRequest(c, info, r)
+	case *dto.EmbeddingRequest:
+		convertedRequest, err = adaptor.ConvertEmbeddingRequest(c, info, *r)
+	case *dto.RerankRequest:
+		convertedRequest, err = adaptor.ConvertRerankRequest(c, info.RelayMode, *r)
+	default:
+		err = fmt.Errorf("影子流量不支持该请求类型: %T", request)
+	}
+	if err != nil {
+		return info, nil, types.NewError(err, types.ErrorCodeConvertRequestFailed)
+	}
+	jsonData, err := common.Marshal(convertedRequest)
+	if err != nil {
+		return info, nil, types.NewError(err, types.ErrorCodeJsonMarshalFailed)
+	}
+	if len(info.ParamOverride) > 0 {
+		jsonData, err = relaycommon.ApplyParamOverride(jsonData, info.ParamOverride, relaycommon.BuildParamOverrideContext(info))
+		if err != nil {
+			return info, nil, types.NewError(err, types.ErrorCodeChannelParamOverrideInvalid)
+		}
+	}
+	jsonData, err = relaycommon.ApplyChannelScriptToRequest(info, jsonData)
+	if err != nil {
+		return info, nil, types.NewError(err, types.ErrorCodeChannelScriptFailed)
+	}
+
+	c.Request.Body = io.NopCloser(bytes.NewReader(jsonData))
+	resp, err := adaptor.DoRequest(c, info, bytes.NewReader(jsonData))
+	if err != nil {
+		return info, nil, types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+	}
+	var httpResp *http.Response
+	if resp != nil {
+		httpResp = resp.(*http.Response)
+		if httpResp.StatusCode != http.StatusOK {
+			return info, nil, service.RelayErrorHandler(c.Request.Context(), httpResp, false)
+		}
+	}
+	if err := relaycommon.ApplyChannelScriptToResponse(info, httpResp); err != nil {
+		return info, nil, types.NewError(err, types.ErrorCodeChannelScriptFailed)
+	}
+	usageAny, apiErr := adaptor.DoResponse(c, httpResp, info)
+	if apiErr != nil {
+		return info, nil, apiErr
+	}
+	usage, _ := usageAny.(*dto.Usage)
+	return info, usage, nil
+}
+
+// GetChannelTrafficReport 返回灰度/影子渠道与正式渠道的对比报表
+func GetChannelTrafficReport(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	channel, err := model.GetChannelById(id, false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if days <= 0 || days > 90 {
+		days = 7
+	}
+	samples, err := model.GetChannelTrafficSamples(id, time.Now().AddDate(0, 0, -days).Unix())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, service.BuildChannelTrafficReport(channel, samples))
+}
+
+// ClearChannelTrafficSamples 清空渠道的采样记录，便于调整配置后重新对比
+func ClearChannelTrafficSamples(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	deleted, err := model.DeleteChannelTrafficSamples(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, deleted)
+}
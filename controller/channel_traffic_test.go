@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/constant"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNewShadowContextIsIsolatedFromLiveRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"gpt-4o"}`))
+	c.Set("id", 1)
+	c.Set(common.KeyRequestBody, []byte("live"))
+	common.SetContextKey(c, constant.ContextKeyShadowChannels, "live")
+
+	snapshot := &shadowRequestSnapshot{
+		method: http.MethodPost,
+		url:    "/v1/chat/completions",
+		header: http.Header{"Content-Type": []string{"application/json"}},
+		body:   []byte(`{"model":"gpt-4o"}`),
+	}
+	shadowCtx, err := newShadowContext(c, snapshot)
+	if err != nil {
+		t.Fatalf("newShadowContext returned error: %v", err)
+	}
+
+	if shadowCtx.GetInt("id") != 1 {
+		t.Fatal("shadow context should keep user context keys")
+	}
+	if _, ok := shadowCtx.Get(common.KeyRequestBody); ok {
+		t.Fatal("shadow context should not reuse the live request body")
+	}
+	if _, ok := common.GetContextKey(shadowCtx, constant.ContextKeyShadowChannels); ok {
+		t.Fatal("shadow context should not carry shadow channels")
+	}
+
+	// 影子请求写入的键与响应不影响原请求
+	shadowCtx.Set("channel_id", 9)
+	shadowCtx.JSON(http.StatusOK, gin.H{"ok": true})
+	if _, ok := c.Get("channel_id"); ok {
+		t.Fatal("shadow context keys leaked into the live request")
+	}
+	if recorder.Body.Len() != 0 {
+		t.Fatalf("shadow response leaked into the live response: %s", recorder.Body.String())
+	}
+
+	body, _ := io.ReadAll(shadowCtx.Request.Body)
+	if string(body) != `{"model":"gpt-4o"}` || shadowCtx.Request.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("shadow request = %s %v", body, shadowCtx.Request.Header)
+	}
+}
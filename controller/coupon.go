@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetAllCoupons(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	coupons, total, err := model.GetAllCoupons(c.Query("keyword"), pageInfo)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(coupons)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetCoupon(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	coupon, err := model.GetCouponById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, coupon)
+}
+
+func AddCoupon(c *gin.Context) {
+	coupon := model.Coupon{}
+	if err := c.ShouldBindJSON(&coupon); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := coupon.Validate(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	cleanCoupon := model.Coupon{
+		Code:          coupon.Code,
+		Name:          coupon.Name,
+		Status:        model.CouponStatusEnabled,
+		DiscountType:  coupon.DiscountType,
+		DiscountValue: coupon.DiscountValue,
+		BonusQuota:    coupon.BonusQuota,
+		MinAmount:     coupon.MinAmount,
+		StartTime:     coupon.StartTime,
+		EndTime:       coupon.EndTime,
+		PerUserLimit:  coupon.PerUserLimit,
+		TotalLimit:    coupon.TotalLimit,
+		AllowedGroups: coupon.AllowedGroups,
+	}
+	if _, err := model.GetCouponByCode(cleanCoupon.Code); err == nil {
+		common.ApiErrorMsg(c, "优惠码已存在")
+		return
+	}
+	if err := cleanCoupon.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, cleanCoupon)
+}
+
+func UpdateCoupon(c *gin.Context) {
+	statusOnly := c.Query("status_only")
+	coupon := model.Coupon{}
+	if err := c.ShouldBindJSON(&coupon); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	cleanCoupon, err := model.GetCouponById(coupon.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if statusOnly != "" {
+		cleanCoupon.Status = coupon.Status
+	} else {
+		// If you add more fields, please also update coupon.Update()
+		cleanCoupon.Name = coupon.Name
+		cleanCoupon.DiscountType = coupon.DiscountType
+		cleanCoupon.DiscountValue = coupon.DiscountValue
+		cleanCoupon.BonusQuota = coupon.BonusQuota
+		cleanCoupon.MinAmount = coupon.MinAmount
+		cleanCoupon.StartTime = coupon.StartTime
+		cleanCoupon.EndTime = coupon.EndTime
+		cleanCoupon.PerUserLimit = coupon.PerUserLimit
+		cleanCoupon.TotalLimit = coupon.TotalLimit
+		cleanCoupon.AllowedGroups = coupon.AllowedGroups
+		if err := cleanCoupon.Validate(); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
+	if err := cleanCoupon.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, cleanCoupon)
+}
+
+func DeleteCoupon(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := model.DeleteCouponById(id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetAllGatewayTools(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	tools, total, err := model.GetAllGatewayTools(c.Query("keyword"), pageInfo)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(tools)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetGatewayTool(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	tool, err := model.GetGatewayToolById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, tool)
+}
+
+func AddGatewayTool(c *gin.Context) {
+	tool := model.GatewayTool{}
+	if err := c.ShouldBindJSON(&tool); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := tool.Validate(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	cleanTool := model.GatewayTool{
+		Name:           tool.Name,
+		Description:    tool.Description,
+		Type:           tool.Type,
+		Endpoint:       tool.Endpoint,
+		Headers:        tool.Headers,
+		Parameters:     tool.Parameters,
+		RemoteName:     tool.RemoteName,
+		Models:         tool.Models,
+		AutoInject:     tool.AutoInject,
+		TimeoutSeconds: tool.TimeoutSeconds,
+		Status:         model.GatewayToolStatusEnabled,
+	}
+	if _, err := model.GetGatewayToolByName(cleanTool.Name); err == nil {
+		common.ApiErrorMsg(c, "工具名称已存在")
+		return
+	}
+	if err := cleanTool.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, cleanTool)
+}
+
+func UpdateGatewayTool(c *gin.Context) {
+	statusOnly := c.Query("status_only")
+	tool := model.GatewayTool{}
+	if err := c.ShouldBindJSON(&tool); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	cleanTool, err := model.GetGatewayToolById(tool.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if statusOnly != "" {
+		cleanTool.Status = tool.Status
+	} else {
+		// If you add more fields, please also update tool.Update()
+		if tool.Name != cleanTool.Name {
+			if existing, err := model.GetGatewayToolByName(tool.Name); err == nil && existing.Id != cleanTool.Id {
+				common.ApiErrorMsg(c, "工具名称已存在")
+				return
+			}
+		}
+		cleanTool.Name = tool.Name
+		cleanTool.Description = tool.Description
+		cleanTool.Type = tool.Type
+		cleanTool.Endpoint = tool.Endpoint
+		cleanTool.Headers = tool.Headers
+		cleanTool.Parameters = tool.Parameters
+		cleanTool.RemoteName = tool.RemoteName
+		cleanTool.Models = tool.Models
+		cleanTool.AutoInject = tool.AutoInject
+		cleanTool.TimeoutSeconds = tool.TimeoutSeconds
+		if err := cleanTool.Validate(); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
+	if err := cleanTool.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, cleanTool)
+}
+
+func DeleteGatewayTool(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := model.DeleteGatewayToolById(id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
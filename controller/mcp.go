@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/service"
+	"github.com/zhongruan0522/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+const mcpServerName = "new-api"
+
+// 支持的 MCP 协议版本，第一个为默认版本
+var mcpProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// McpServer 内置 MCP 服务（Streamable HTTP 传输，仅使用 JSON 响应，不提供 SSE 流）
+func McpServer(c *gin.Context) {
+	if !operation_setting.GetMcpSetting().Enabled {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"message": "MCP 服务未启用",
+				"type":    "new_api_error",
+			},
+		})
+		return
+	}
+	if c.Request.Method != http.MethodPost {
+		c.Header("Allow", http.MethodPost)
+		c.Status(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, mcpErrorResponse(nil, dto.JsonRpcCodeParseError, "读取请求体失败"))
+		return
+	}
+	body = bytes.TrimSpace(body)
+
+	// 兼容 2025-03-26 协议的批量请求
+	if len(body) > 0 && body[0] == '[' {
+		var requests []dto.JsonRpcRequest
+		if err := common.Unmarshal(body, &requests); err != nil || len(requests) == 0 {
+			c.JSON(http.StatusBadRequest, mcpErrorResponse(nil, dto.JsonRpcCodeParseError, "无效的 JSON-RPC 请求"))
+			return
+		}
+		responses := make([]*dto.JsonRpcResponse, 0, len(requests))
+		for i := range requests {
+			if resp := handleMcpRequest(c, &requests[i]); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			c.Status(http.StatusAccepted)
+			return
+		}
+		c.JSON(http.StatusOK, responses)
+		return
+	}
+
+	var request dto.JsonRpcRequest
+	if err := common.Unmarshal(body, &request); err != nil {
+		c.JSON(http.StatusBadRequest, mcpErrorResponse(nil, dto.JsonRpcCodeParseError, "无效的 JSON-RPC 请求"))
+		return
+	}
+	resp := handleMcpRequest(c, &request)
+	if resp == nil {
+		c.Status(http.StatusAccepted)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// handleMcpRequest 处理单个 JSON-RPC 消息，通知类消息返回 nil
+func handleMcpRequest(c *gin.Context, request *dto.JsonRpcRequest) *dto.JsonRpcResponse {
+	if request.JsonRpc != dto.JsonRpcVersion || request.Method == "" {
+		if request.IsNotification() {
+			return nil
+		}
+		return mcpErrorResponse(request.Id, dto.JsonRpcCodeInvalidRequest, "无效的 JSON-RPC 请求")
+	}
+	if request.IsNotification() {
+		// notifications/initialized、notifications/cancelled 等无需响应
+		return nil
+	}
+
+	switch request.Method {
+	case "initialize":
+		var params dto.McpInitializeParams
+		if len(request.Params) > 0 {
+			_ = common.Unmarshal(request.Params, &params)
+		}
+		return mcpResultResponse(request.Id, dto.McpInitializeResult{
+			ProtocolVersion: negotiateMcpProtocolVersion(params.ProtocolVersion),
+			Capabilities: map[string]any{
+				"tools": map[string]any{"listChanged": false},
+			},
+			ServerInfo: dto.McpServerInfo{
+				Name:    mcpServerName,
+				Version: common.Version,
+			},
+			Instructions: "工具调用按当前令牌正常计费并记录日志。",
+		})
+	case "ping":
+		return mcpResultResponse(request.Id, map[string]any{})
+	case "tools/list":
+		return mcpResultResponse(request.Id, dto.McpListToolsResult{Tools: service.ListMcpTools()})
+	case "tools/call":
+		var params dto.McpCallToolParams
+		if err := common.Unmarshal(request.Params, &params); err != nil || params.Name == "" {
+			return mcpErrorResponse(request.Id, dto.JsonRpcCodeInvalidParams, "无效的工具调用参数")
+		}
+		result, ok := service.CallMcpTool(c.Request, params.Name, params.Arguments)
+		if !ok {
+			return mcpErrorResponse(request.Id, dto.JsonRpcCodeInvalidParams, "未知的工具："+params.Name)
+		}
+		return mcpResultResponse(request.Id, result)
+	default:
+		return mcpErrorResponse(request.Id, dto.JsonRpcCodeMethodNotFound, "不支持的方法："+request.Method)
+	}
+}
+
+func negotiateMcpProtocolVersion(requested string) string {
+	for _, version := range mcpProtocolVersions {
+		if version == requested {
+			return version
+		}
+	}
+	return mcpProtocolVersions[0]
+}
+
+func mcpResultResponse(id json.RawMessage, result any) *dto.JsonRpcResponse {
+	return &dto.JsonRpcResponse{JsonRpc: dto.JsonRpcVersion, Id: id, Result: result}
+}
+
+func mcpErrorResponse(id json.RawMessage, code int, message string) *dto.JsonRpcResponse {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &dto.JsonRpcResponse{
+		JsonRpc: dto.JsonRpcVersion,
+		Id:      id,
+		Error:   &dto.JsonRpcError{Code: code, Message: message},
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/zhongruan0522/new-api/relay/channel/moonshot"
 	relaycommon "github.com/zhongruan0522/new-api/relay/common"
 	"github.com/zhongruan0522/new-api/service"
+	"github.com/zhongruan0522/new-api/setting/operation_setting"
 	"github.com/zhongruan0522/new-api/setting/ratio_setting"
 	"github.com/zhongruan0522/new-api/types"
 )
@@ -42,6 +43,9 @@ func init() {
 		constant.APITypeMoonshot,
 		constant.APITypeMiniMax,
 		constant.APITypeXiaomi,
+		constant.APITypeCohere,
+		constant.APITypeDashScope,
+		constant.APITypeSelfHosted,
 	}
 	for _, apiType := range allAPITypes {
 		adaptor := relay.GetAdaptor(apiType)
@@ -124,6 +128,9 @@ func ListModels(c *gin.Context, modelType int) {
 				})
 			}
 		}
+		userOpenAiModels = appendVirtualModels(userOpenAiModels, func(vm operation_setting.VirtualModel) bool {
+			return tokenModelLimit[vm.Name]
+		})
 	} else {
 		userId := c.GetInt("id")
 		userGroup, err := model.GetUserGroup(userId, false)
@@ -170,6 +177,14 @@ func ListModels(c *gin.Context, modelType int) {
 				})
 			}
 		}
+		userOpenAiModels = appendVirtualModels(userOpenAiModels, func(vm operation_setting.VirtualModel) bool {
+			for _, target := range vm.TargetNames() {
+				if common.StringsContains(models, target) {
+					return true
+				}
+			}
+			return false
+		})
 	}
 
 	switch modelType {
@@ -210,6 +225,27 @@ func ListModels(c *gin.Context, modelType int) {
 	}
 }
 
+// appendVirtualModels 追加当前用户可见的虚拟模型，端点类型沿用首个目标模型
+func appendVirtualModels(models []dto.OpenAIModels, visible func(vm operation_setting.VirtualModel) bool) []dto.OpenAIModels {
+	setting := operation_setting.GetVirtualModelSetting()
+	if !setting.Enabled {
+		return models
+	}
+	for _, vm := range setting.Models {
+		if !visible(vm) || len(vm.Targets) == 0 {
+			continue
+		}
+		models = append(models, dto.OpenAIModels{
+			Id:                     vm.Name,
+			Object:                 "model",
+			Created:                1626777600,
+			OwnedBy:                "virtual",
+			SupportedEndpointTypes: model.GetModelSupportEndpointTypes(vm.Targets[0].Model),
+		})
+	}
+	return models
+}
+
 func ChannelListModels(c *gin.Context) {
 	c.JSON(200, gin.H{
 		"success": true,
@@ -233,7 +269,18 @@ func EnabledListModels(c *gin.Context) {
 
 func RetrieveModel(c *gin.Context, modelType int) {
 	modelId := c.Param("model")
-	if aiModel, ok := openAIModelsMap[modelId]; ok {
+	aiModel, ok := openAIModelsMap[modelId]
+	if !ok {
+		if _, isVirtual := operation_setting.GetVirtualModelSetting().GetVirtualModel(modelId); isVirtual {
+			aiModel, ok = dto.OpenAIModels{
+				Id:      modelId,
+				Object:  "model",
+				Created: 1626777600,
+				OwnedBy: "virtual",
+			}, true
+		}
+	}
+	if ok {
 		switch modelType {
 		case constant.ChannelTypeAnthropic:
 			c.JSON(200, dto.AnthropicModel{
@@ -228,6 +228,51 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "payment_setting.bonus_tiers":
+		err = operation_setting.ValidateTopUpBonusTiers(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "充值阶梯赠送设置失败: " + err.Error(),
+			})
+			return
+		}
+	case "referral_setting.rates":
+		err = operation_setting.ValidateReferralRates(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "返佣比例设置失败: " + err.Error(),
+			})
+			return
+		}
+	case "ticket_setting.first_response_minutes", "ticket_setting.resolution_minutes":
+		err = operation_setting.ValidateTicketSlaMinutes(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "工单 SLA 时限设置失败: " + err.Error(),
+			})
+			return
+		}
+	case "responses_store_setting.retention_days", "responses_store_setting.max_chain_depth":
+		err = operation_setting.ValidateResponsesStorePositiveInt(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "Responses 存储设置失败: " + err.Error(),
+			})
+			return
+		}
+	case "channel_script_setting.max_execution_steps", "channel_script_setting.timeout_ms":
+		err = operation_setting.ValidateChannelScriptPositiveInt(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "渠道脚本设置失败: " + err.Error(),
+			})
+			return
+		}
 	case "tool_billing_setting.rules":
 		err = operation_setting.ValidateToolBillingRules(option.Value.(string))
 		if err != nil {
@@ -237,6 +282,15 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "virtual_model_setting.models":
+		err = operation_setting.ValidateVirtualModels(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "虚拟模型设置失败: " + err.Error(),
+			})
+			return
+		}
 	}
 	err = model.UpdateOption(option.Key, option.Value.(string))
 	if err != nil {
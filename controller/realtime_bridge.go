@@ -0,0 +1,240 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/middleware"
+	"github.com/zhongruan0522/new-api/model"
+	"github.com/zhongruan0522/new-api/relay"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	relayconstant "github.com/zhongruan0522/new-api/relay/constant"
+	"github.com/zhongruan0522/new-api/relay/helper"
+	"github.com/zhongruan0522/new-api/service"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MiniMax TTS 默认返回 mp3 链接，桥接需要 hex 编码的 24kHz 单声道 PCM
+var miniMaxRealtimeSpeechMetadata = json.RawMessage(`{"output_format":"hex","audio_setting":{"format":"pcm","sample_rate":24000,"channel":1}}`)
+
+// realtimeStageRequest 阶段请求的路径与请求体，每次尝试据此重新构造内部请求
+type realtimeStageRequest struct {
+	path        string
+	relayFormat types.RelayFormat
+	request     dto.Request
+	body        []byte
+	contentType string
+}
+
+func buildRealtimeStageRequest(stage *relay.RealtimeStage) (*realtimeStageRequest, error) {
+	req := &realtimeStageRequest{contentType: "application/json"}
+	var err error
+	switch stage.RelayMode {
+	case relayconstant.RelayModeAudioTranscription:
+		req.path, req.relayFormat = "/v1/audio/transcriptions", types.RelayFormatOpenAIAudio
+		req.request = &dto.AudioRequest{Model: stage.Model, ResponseFormat: "json"}
+		req.body, req.contentType, err = buildRealtimeTranscriptionForm(stage.Model, stage.Audio)
+	case relayconstant.RelayModeChatCompletions:
+		req.path, req.relayFormat = "/v1/chat/completions", types.RelayFormatOpenAI
+		req.request = stage.Chat
+		req.body, err = common.Marshal(stage.Chat)
+	case relayconstant.RelayModeAudioSpeech:
+		req.path, req.relayFormat = "/v1/audio/speech", types.RelayFormatOpenAIAudio
+		req.request = stage.Speech
+		req.body, err = common.Marshal(stage.Speech)
+	default:
+		return nil, fmt.Errorf("unsupported realtime stage relay mode: %d", stage.RelayMode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// InvokeRealtimeStage 为实时桥接执行单个阶段：校验令牌的模型限制后，在用户分组下选择提供该模型的渠道，
+// 失败时与普通请求一样按重试次数切换渠道。阶段按自身模型的价格计算额度并计入渠道已用额度，
+// 用户侧的扣费与日志由桥接会话统一处理
+func InvokeRealtimeStage(c *gin.Context, stage *relay.RealtimeStage) (*relay.RealtimeStageResult, error) {
+	if err := middleware.CheckTokenModelLimit(c, stage.Model); err != nil {
+		return nil, err
+	}
+	req, err := buildRealtimeStageRequest(stage)
+	if err != nil {
+		return nil, err
+	}
+
+	retryParam := &service.RetryParam{
+		TokenGroup:  stage.Group,
+		ModelName:   stage.Model,
+		Retry:       common.GetPointer(0),
+		RelayFormat: req.relayFormat,
+	}
+	lastFailedChannelId := 0
+	var apiErr *types.NewAPIError
+	for ; retryParam.GetRetry() <= common.RetryTimes; retryParam.IncreaseRetry() {
+		if retryParam.GetRetry()%2 == 1 {
+			retryParam.ExcludeChannelId = lastFailedChannelId
+		} else {
+			retryParam.ExcludeChannelId = 0
+		}
+		sub, writer, err := newRealtimeStageContext(c, stage, req)
+		if err != nil {
+			return nil, err
+		}
+		retryParam.Ctx = sub
+		channel, _, err := service.CacheGetRandomSatisfiedChannel(retryParam)
+		if err != nil {
+			return nil, fmt.Errorf("获取模型 %s 的可用渠道失败：%w", stage.Model, err)
+		}
+		if channel == nil {
+			if apiErr != nil {
+				break
+			}
+			return nil, fmt.Errorf("分组 %s 下模型 %s 的可用渠道不存在", stage.Group, stage.Model)
+		}
+		lastFailedChannelId = channel.Id
+
+		var result *relay.RealtimeStageResult
+		result, apiErr = doRealtimeStage(sub, writer, stage, req, channel)
+		if apiErr == nil {
+			return result, nil
+		}
+		processChannelError(sub, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey,
+			common.GetContextKeyString(sub, constant.ContextKeyChannelKey), channel.GetAutoBan()), apiErr)
+		if stage.Ctx.Err() != nil || !shouldRetry(sub, apiErr, common.RetryTimes-retryParam.GetRetry()) {
+			break
+		}
+	}
+	return nil, apiErr
+}
+
+// newRealtimeStageContext 沿用会话的用户、令牌上下文，请求体与渠道信息按阶段重新设置
+func newRealtimeStageContext(c *gin.Context, stage *relay.RealtimeStage, req *realtimeStageRequest) (*gin.Context, *common.InternalResponseWriter, error) {
+	request, err := http.NewRequestWithContext(stage.Ctx, http.MethodPost, req.path, bytes.NewReader(req.body))
+	if err != nil {
+		return nil, nil, err
+	}
+	request.Header.Set("Content-Type", req.contentType)
+	sub, writer := common.NewInternalContext(c, request)
+	// 会话指定的渠道只约束桥接本身，阶段按自身模型选渠道
+	delete(sub.Keys, common.KeyBodyStorage)
+	delete(sub.Keys, "specific_channel_id")
+	sub.Set(common.KeyRequestBody, req.body)
+	return sub, writer, nil
+}
+
+func doRealtimeStage(sub *gin.Context, writer *common.InternalResponseWriter, stage *relay.RealtimeStage,
+	req *realtimeStageRequest, channel *model.Channel) (*relay.RealtimeStageResult, *types.NewAPIError) {
+	if apiErr := middleware.SetupContextForSelectedChannel(sub, channel, stage.Model); apiErr != nil {
+		return nil, apiErr
+	}
+	if stage.Speech != nil {
+		stage.Speech.Metadata = nil
+		if channel.Type == constant.ChannelTypeMiniMax {
+			stage.Speech.Metadata = miniMaxRealtimeSpeechMetadata
+		}
+	}
+
+	info, err := relaycommon.GenRelayInfo(sub, req.relayFormat, req.request, nil)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeGenRelayInfoFailed, types.ErrOptionWithSkipRetry())
+	}
+	info.InitChannelMeta(sub)
+	if stage.Speech != nil {
+		info.SetEstimatePromptTokens(service.CountTextToken(stage.Speech.Input, stage.Model))
+	}
+	// 阶段按自身模型的价格计费，而不是实时模型的倍率
+	if _, err := helper.ModelPriceHelper(sub, info, info.GetEstimatePromptTokens(), &types.TokenCountMeta{}); err != nil {
+		return nil, types.NewError(err, types.ErrorCodeModelPriceError, types.ErrOptionWithSkipRetry())
+	}
+	if err := helper.ModelMappedHelper(sub, info, req.request); err != nil {
+		return nil, types.NewError(err, types.ErrorCodeChannelModelMappedError, types.ErrOptionWithSkipRetry())
+	}
+	adaptor := relay.GetAdaptor(info.ApiType)
+	if adaptor == nil {
+		return nil, types.NewError(fmt.Errorf("invalid api type: %d", info.ApiType), types.ErrorCodeInvalidApiType, types.ErrOptionWithSkipRetry())
+	}
+	adaptor.Init(info)
+
+	var requestBody io.Reader
+	switch stage.RelayMode {
+	case relayconstant.RelayModeChatCompletions:
+		converted, err := adaptor.ConvertOpenAIRequest(sub, info, stage.Chat)
+		if err != nil {
+			return nil, types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+		}
+		jsonData, err := common.Marshal(converted)
+		if err != nil {
+			return nil, types.NewError(err, types.ErrorCodeJsonMarshalFailed, types.ErrOptionWithSkipRetry())
+		}
+		if len(info.ParamOverride) > 0 {
+			jsonData, err = relaycommon.ApplyParamOverride(jsonData, info.ParamOverride, relaycommon.BuildParamOverrideContext(info))
+			if err != nil {
+				return nil, types.NewError(err, types.ErrorCodeChannelParamOverrideInvalid, types.ErrOptionWithSkipRetry())
+			}
+		}
+		requestBody = bytes.NewReader(jsonData)
+	default:
+		requestBody, err = adaptor.ConvertAudioRequest(sub, info, *req.request.(*dto.AudioRequest))
+		if err != nil {
+			return nil, types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+		}
+	}
+
+	resp, err := adaptor.DoRequest(sub, info, requestBody)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+	}
+	var httpResp *http.Response
+	if resp != nil {
+		httpResp = resp.(*http.Response)
+		if httpResp.StatusCode != http.StatusOK {
+			return nil, service.RelayErrorHandler(sub.Request.Context(), httpResp, false)
+		}
+	}
+	usageAny, apiErr := adaptor.DoResponse(sub, httpResp, info)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if writer.Status() >= http.StatusBadRequest {
+		return nil, types.NewOpenAIError(errors.New(string(writer.Body())), types.ErrorCodeBadResponse, writer.Status())
+	}
+
+	result := &relay.RealtimeStageResult{Body: writer.Body(), ChannelId: channel.Id}
+	if usage, ok := usageAny.(*dto.Usage); ok {
+		result.Usage = usage
+	}
+	result.Quota = service.CalculateUsageQuota(info.PriceData, result.Usage)
+	if result.Quota > 0 {
+		model.UpdateChannelUsedQuota(channel.Id, result.Quota)
+	}
+	return result, nil
+}
+
+func buildRealtimeTranscriptionForm(model string, wav []byte) ([]byte, string, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	_ = writer.WriteField("model", model)
+	_ = writer.WriteField("response_format", "json")
+	part, err := writer.CreateFormFile("file", "audio.wav")
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := part.Write(wav); err != nil {
+		return nil, "", err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), writer.FormDataContentType(), nil
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/model"
+	"github.com/zhongruan0522/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSelfReferral 获取当前用户的返佣配置与汇总
+func GetSelfReferral(c *gin.Context) {
+	userId := c.GetInt("id")
+	summary, err := model.GetUserReferralSummary(userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	setting := operation_setting.GetReferralSetting()
+	common.ApiSuccess(c, gin.H{
+		"enabled":      setting.Enabled,
+		"rates":        operation_setting.GetReferralRates(),
+		"holding_days": setting.HoldingDays,
+		"summary":      summary,
+	})
+}
+
+// GetSelfReferralCommissions 获取当前用户作为邀请人的返佣流水
+func GetSelfReferralCommissions(c *gin.Context) {
+	userId := c.GetInt("id")
+	pageInfo := common.GetPageQuery(c)
+	commissions, total, err := model.GetUserReferralCommissions(userId, pageInfo)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(commissions)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetAllReferralCommissions 管理员查询全平台返佣流水
+func GetAllReferralCommissions(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	inviterId, _ := strconv.Atoi(c.Query("inviter_id"))
+	inviteeId, _ := strconv.Atoi(c.Query("invitee_id"))
+	commissions, total, err := model.GetAllReferralCommissions(inviterId, inviteeId, c.Query("trade_no"), c.Query("status"), pageInfo)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(commissions)
+	common.ApiSuccess(c, pageInfo)
+}
+
+type AdminRefundTopUpRequest struct {
+	TradeNo string `json:"trade_no"`
+	Reason  string `json:"reason"`
+}
+
+// AdminRefundTopUp 管理员将已完成的充值订单标记为退款，并追回相关返佣
+func AdminRefundTopUp(c *gin.Context) {
+	var req AdminRefundTopUpRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.TradeNo == "" {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+
+	LockOrder(req.TradeNo)
+	defer UnlockOrder(req.TradeNo)
+
+	if err := model.RefundTopUp(req.TradeNo, req.Reason); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
@@ -33,7 +33,7 @@ import (
 func relayHandler(c *gin.Context, info *relaycommon.RelayInfo) *types.NewAPIError {
 	var err *types.NewAPIError
 	switch info.RelayMode {
-	case relayconstant.RelayModeImagesGenerations, relayconstant.RelayModeImagesEdits:
+	case relayconstant.RelayModeImagesGenerations, relayconstant.RelayModeImagesEdits, relayconstant.RelayModeImagesVariations:
 		err = relay.ImageHelper(c, info)
 	case relayconstant.RelayModeAudioSpeech:
 		fallthrough
@@ -185,58 +185,82 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 	}
 	lastFailedChannelId := 0
 
-	for ; retryParam.GetRetry() <= common.RetryTimes; retryParam.IncreaseRetry() {
-		// retry%2==1 means same-priority retry: exclude the previously failed channel
-		// retry%2==1 表示同优先级重试：排除上次失败的渠道
-		if retryParam.GetRetry()%2 == 1 {
-			retryParam.ExcludeChannelId = lastFailedChannelId
-		} else {
-			retryParam.ExcludeChannelId = 0
-		}
-
-		channel, channelErr := getChannel(c, relayInfo, retryParam)
-		if channelErr != nil {
-			logger.LogError(c, channelErr.Error())
-			newAPIError = channelErr
-			break
-		}
+	defer func() {
+		startShadowTraffic(c, relayInfo, relayFormat, newAPIError)
+	}()
 
-		addUsedChannel(c, channel.Id)
-		lastFailedChannelId = channel.Id
-		requestBody, bodyErr := common.GetRequestBody(c)
-		if bodyErr != nil {
-			// Ensure consistent 413 for oversized bodies even when error occurs later (e.g., retry path)
-			if common.IsRequestBodyTooLargeError(bodyErr) || errors.Is(bodyErr, common.ErrRequestBodyTooLarge) {
-				newAPIError = types.NewErrorWithStatusCode(bodyErr, types.ErrorCodeReadRequestBodyFailed, http.StatusRequestEntityTooLarge, types.ErrOptionWithSkipRetry())
+	for {
+		for ; retryParam.GetRetry() <= common.RetryTimes; retryParam.IncreaseRetry() {
+			// retry%2==1 means same-priority retry: exclude the previously failed channel
+			// retry%2==1 表示同优先级重试：排除上次失败的渠道
+			if retryParam.GetRetry()%2 == 1 {
+				retryParam.ExcludeChannelId = lastFailedChannelId
 			} else {
-				newAPIError = types.NewErrorWithStatusCode(bodyErr, types.ErrorCodeReadRequestBodyFailed, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+				retryParam.ExcludeChannelId = 0
 			}
-			break
-		}
-		c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
-
-		switch relayFormat {
-		case types.RelayFormatOpenAIRealtime:
-			newAPIError = relay.WssHelper(c, relayInfo)
-		case types.RelayFormatClaude:
-			newAPIError = relay.ClaudeHelper(c, relayInfo)
-		case types.RelayFormatGemini:
-			newAPIError = geminiRelayHandler(c, relayInfo)
-		default:
-			newAPIError = relayHandler(c, relayInfo)
-		}
 
-		if newAPIError == nil {
-			return
-		}
+			channel, channelErr := getChannel(c, relayInfo, retryParam)
+			if channelErr != nil {
+				logger.LogError(c, channelErr.Error())
+				newAPIError = channelErr
+				break
+			}
 
-		newAPIError = service.NormalizeViolationFeeError(newAPIError)
+			addUsedChannel(c, channel.Id)
+			lastFailedChannelId = channel.Id
+			requestBody, bodyErr := common.GetRequestBody(c)
+			if bodyErr != nil {
+				// Ensure consistent 413 for oversized bodies even when error occurs later (e.g., retry path)
+				if common.IsRequestBodyTooLargeError(bodyErr) || errors.Is(bodyErr, common.ErrRequestBodyTooLarge) {
+					newAPIError = types.NewErrorWithStatusCode(bodyErr, types.ErrorCodeReadRequestBodyFailed, http.StatusRequestEntityTooLarge, types.ErrOptionWithSkipRetry())
+				} else {
+					newAPIError = types.NewErrorWithStatusCode(bodyErr, types.ErrorCodeReadRequestBodyFailed, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+				}
+				break
+			}
+			c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
 
-		processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), newAPIError)
+			attemptStart := time.Now()
+			switch relayFormat {
+			case types.RelayFormatOpenAIRealtime:
+				newAPIError = relay.WssHelper(c, relayInfo)
+			case types.RelayFormatClaude:
+				newAPIError = relay.ClaudeHelper(c, relayInfo)
+			case types.RelayFormatGemini:
+				newAPIError = geminiRelayHandler(c, relayInfo)
+			case types.RelayFormatOpenAIVideo:
+				newAPIError = relay.VideoSubmitHelper(c, relayInfo)
+			default:
+				newAPIError = relayHandler(c, relayInfo)
+			}
+			recordCanaryAttempt(c, relayInfo, channel.Id, attemptStart, newAPIError)
 
-		if !shouldRetry(c, newAPIError, common.RetryTimes-retryParam.GetRetry()) {
+			if newAPIError == nil {
+				return
+			}
+
+			newAPIError = service.NormalizeViolationFeeError(newAPIError)
+
+			processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), newAPIError)
+
+			if !shouldRetry(c, newAPIError, common.RetryTimes-retryParam.GetRetry()) {
+				break
+			}
+		}
+
+		// 虚拟模型：当前目标的渠道全部失败后回退到下一个目标模型
+		if !shouldFallbackVirtualModel(c, newAPIError) {
+			break
+		}
+		nextModel, ok := service.PopVirtualModelFallback(c)
+		if !ok {
 			break
 		}
+		if apiErr := switchVirtualModelTarget(c, relayInfo, retryParam, nextModel, tokens, meta); apiErr != nil {
+			newAPIError = apiErr
+			break
+		}
+		lastFailedChannelId = 0
 	}
 
 	useChannel := c.GetStringSlice("use_channel")
@@ -280,6 +304,9 @@ func fastTokenCountMetaForPricing(request dto.Request) *types.TokenCountMeta {
 	case *dto.ImageRequest:
 		// Pricing for image requests depends on ImagePriceRatio; safe to compute even when CountToken is disabled.
 		return r.GetTokenCountMeta()
+	case *dto.VideoRequest:
+		// Video is priced per second; ImagePriceRatio carries the clip length.
+		return r.GetTokenCountMeta()
 	default:
 		// Best-effort: leave CombineText empty to avoid large allocations.
 	}
@@ -347,6 +374,53 @@ func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) b
 	return operation_setting.ShouldRetryByStatusCode(code)
 }
 
+// shouldFallbackVirtualModel 仅在尚未向客户端写出响应、且失败来自渠道侧时回退到虚拟模型的下一个目标
+func shouldFallbackVirtualModel(c *gin.Context, err *types.NewAPIError) bool {
+	if err == nil || c.Writer.Written() {
+		return false
+	}
+	if common.GetContextKeyString(c, constant.ContextKeyVirtualModel) == "" {
+		return false
+	}
+	if err.GetErrorCode() == types.ErrorCodeGetChannelFailed {
+		return true
+	}
+	return shouldRetry(c, err, 1)
+}
+
+// switchVirtualModelTarget 切换到虚拟模型的下一个目标：重置选渠道状态，并按新目标重新计价与预扣费
+func switchVirtualModelTarget(c *gin.Context, info *relaycommon.RelayInfo, retryParam *service.RetryParam, modelName string, tokens int, meta *types.TokenCountMeta) *types.NewAPIError {
+	logger.LogWarn(c, fmt.Sprintf("虚拟模型 %s 的目标 %s 请求失败，回退到 %s", info.VirtualModelName, info.OriginModelName, modelName))
+	// 先校验令牌能否访问新目标，失败时当前预扣费由 Relay 统一退还
+	if err := middleware.CheckTokenModelLimit(c, modelName); err != nil {
+		return types.NewErrorWithStatusCode(err, types.ErrorCodeAccessDenied, http.StatusForbidden, types.ErrOptionWithSkipRetry())
+	}
+	if info.Billing != nil {
+		info.Billing.Refund(c)
+		info.Billing = nil
+	}
+	info.OriginModelName = modelName
+	if info.ChannelMeta == nil {
+		// 首个目标未进入渠道初始化就失败时，确保 getChannel 按新目标重新选渠道
+		info.ChannelMeta = &relaycommon.ChannelMeta{}
+	}
+	common.SetContextKey(c, constant.ContextKeyOriginalModel, modelName)
+	common.SetContextKey(c, constant.ContextKeyAutoGroupIndex, 0)
+	common.SetContextKey(c, constant.ContextKeyAutoGroupRetryIndex, 0)
+	retryParam.ModelName = modelName
+	retryParam.ExcludeChannelId = 0
+	retryParam.SetRetry(0)
+
+	priceData, err := helper.ModelPriceHelper(c, info, tokens, meta)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeModelPriceError, types.ErrOptionWithSkipRetry())
+	}
+	if priceData.FreeModel {
+		return nil
+	}
+	return service.PreConsumeBilling(c, priceData.QuotaToPreConsume, info)
+}
+
 func processChannelError(c *gin.Context, channelError types.ChannelError, err *types.NewAPIError) {
 	logger.LogError(c, fmt.Sprintf("channel error (channel #%d, status code: %d): %s", channelError.ChannelId, err.StatusCode, common.LocalLogPreview(err.Error())))
 	// 不要使用context获取渠道信息，异步处理时可能会出现渠道信息不一致的情况
@@ -134,22 +134,16 @@ func GetStoredMediaDetail(c *gin.Context) {
 			return
 		}
 
-		img, err := model.GetStoredImageByID(c.Request.Context(), id)
-		if err != nil {
-			common.ApiError(c, err)
-			return
-		}
-
 		c.JSON(http.StatusOK, gin.H{
 			"success": true,
 			"message": "",
 			"data": storedMediaDetailResponse{
-				Id:        img.Id,
+				Id:        meta.Id,
 				MediaType: "image",
-				CreatedAt: img.CreatedAt,
-				MimeType:  img.MimeType,
-				SizeBytes: img.SizeBytes,
-				Url:       buildStoredMediaURL(c, "image", img.Id),
+				CreatedAt: meta.CreatedAt,
+				MimeType:  meta.MimeType,
+				SizeBytes: meta.SizeBytes,
+				Url:       buildStoredMediaURL(c, "image", meta.Id),
 			},
 		})
 		return
@@ -169,21 +163,16 @@ func GetStoredMediaDetail(c *gin.Context) {
 		return
 	}
 
-	v, err := model.GetStoredVideoByID(c.Request.Context(), id)
-	if err != nil {
-		common.ApiError(c, err)
-		return
-	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
 		"data": storedMediaDetailResponse{
-			Id:        v.Id,
+			Id:        meta.Id,
 			MediaType: "video",
-			CreatedAt: v.CreatedAt,
-			MimeType:  v.MimeType,
-			SizeBytes: v.SizeBytes,
-			Url:       buildStoredMediaURL(c, "video", v.Id),
+			CreatedAt: meta.CreatedAt,
+			MimeType:  meta.MimeType,
+			SizeBytes: meta.SizeBytes,
+			Url:       buildStoredMediaURL(c, "video", meta.Id),
 		},
 	})
 }
@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/model"
+	"github.com/zhongruan0522/new-api/setting/operation_setting"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// loadStoredResponse 读取当前令牌用户保存的响应，失败时已写入 OpenAI 格式错误
+func loadStoredResponse(c *gin.Context) (*model.StoredResponse, bool) {
+	id := c.Param("id")
+	setting := operation_setting.GetResponsesStoreSetting()
+	notBefore := common.GetTimestamp() - setting.RetentionSeconds()
+	stored, err := model.GetStoredResponse(c.Request.Context(), c.GetInt("id"), id, notBefore)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			writeStoredResponseNotFound(c, id)
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": types.OpenAIError{
+					Message: "query stored response failed",
+					Type:    "server_error",
+				},
+			})
+		}
+		return nil, false
+	}
+	return stored, true
+}
+
+func writeStoredResponseNotFound(c *gin.Context, id string) {
+	c.JSON(http.StatusNotFound, gin.H{
+		"error": types.OpenAIError{
+			Message: fmt.Sprintf("Response with id '%s' not found.", id),
+			Type:    "invalid_request_error",
+			Param:   "response_id",
+		},
+	})
+}
+
+// GetStoredResponse GET /v1/responses/:id
+func GetStoredResponse(c *gin.Context) {
+	stored, ok := loadStoredResponse(c)
+	if !ok {
+		return
+	}
+	c.Data(http.StatusOK, "application/json", stored.Response)
+}
+
+// DeleteStoredResponse DELETE /v1/responses/:id
+func DeleteStoredResponse(c *gin.Context) {
+	id := c.Param("id")
+	deleted, err := model.DeleteStoredResponse(c.Request.Context(), c.GetInt("id"), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": types.OpenAIError{
+				Message: "delete stored response failed",
+				Type:    "server_error",
+			},
+		})
+		return
+	}
+	if !deleted {
+		writeStoredResponseNotFound(c, id)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":      id,
+		"object":  "response",
+		"deleted": true,
+	})
+}
+
+// ListStoredResponseInputItems GET /v1/responses/:id/input_items
+// 返回生成该响应时使用的完整输入（含 previous_response_id 链上的历史），支持 limit/order/after 分页
+func ListStoredResponseInputItems(c *gin.Context) {
+	stored, ok := loadStoredResponse(c)
+	if !ok {
+		return
+	}
+	items := stored.GetInputItems()
+	if stored.PreviousResponseId != "" {
+		setting := operation_setting.GetResponsesStoreSetting()
+		notBefore := common.GetTimestamp() - setting.RetentionSeconds()
+		history, err := model.GetStoredResponseHistory(c.Request.Context(), stored.UserId, stored.PreviousResponseId, notBefore, setting.GetMaxChainDepth())
+		// 上一轮响应已过期或被删除时只返回本轮输入，其它错误不能当作没有历史
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": types.OpenAIError{
+					Message: "query stored response history failed",
+					Type:    "server_error",
+				},
+			})
+			return
+		}
+		items = append(history, items...)
+	}
+
+	type inputItem struct {
+		id  string
+		raw json.RawMessage
+	}
+	list := make([]inputItem, 0, len(items))
+	for i, raw := range items {
+		list = append(list, inputItem{id: storedResponseItemId(stored.Id, i, raw), raw: raw})
+	}
+	if c.DefaultQuery("order", "desc") != "asc" {
+		for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+			list[i], list[j] = list[j], list[i]
+		}
+	}
+	if after := c.Query("after"); after != "" {
+		index := slices.IndexFunc(list, func(item inputItem) bool {
+			return item.id == after
+		})
+		if index < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": types.OpenAIError{
+					Message: fmt.Sprintf("Input item with id '%s' not found.", after),
+					Type:    "invalid_request_error",
+					Param:   "after",
+				},
+			})
+			return
+		}
+		list = list[index+1:]
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	hasMore := len(list) > limit
+	if hasMore {
+		list = list[:limit]
+	}
+
+	data := make([]json.RawMessage, 0, len(list))
+	for _, item := range list {
+		data = append(data, withStoredResponseItemId(item.raw, item.id))
+	}
+	resp := gin.H{
+		"object":   "list",
+		"data":     data,
+		"first_id": nil,
+		"last_id":  nil,
+		"has_more": hasMore,
+	}
+	if len(list) > 0 {
+		resp["first_id"] = list[0].id
+		resp["last_id"] = list[len(list)-1].id
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// storedResponseItemId 返回输入项自带的 id，没有时按位置生成稳定的 id
+func storedResponseItemId(responseId string, index int, raw json.RawMessage) string {
+	var probe struct {
+		Id string `json:"id"`
+	}
+	if err := common.Unmarshal(raw, &probe); err == nil && probe.Id != "" {
+		return probe.Id
+	}
+	return fmt.Sprintf("item_%s_%d", responseId, index)
+}
+
+func withStoredResponseItemId(raw json.RawMessage, id string) json.RawMessage {
+	var fields map[string]json.RawMessage
+	if err := common.Unmarshal(raw, &fields); err != nil {
+		return raw
+	}
+	if _, ok := fields["id"]; ok {
+		return raw
+	}
+	fields["id"], _ = common.Marshal(id)
+	out, err := common.Marshal(fields)
+	if err != nil {
+		return raw
+	}
+	return out
+}
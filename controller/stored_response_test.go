@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/zhongruan0522/new-api/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+	"gorm.io/gorm"
+)
+
+func setupStoredResponseTestDB(t *testing.T) {
+	t.Helper()
+	oldDB := model.DB
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite test db: %v", err)
+	}
+	if err := db.AutoMigrate(&model.StoredResponse{}); err != nil {
+		t.Fatalf("migrate sqlite test db: %v", err)
+	}
+	model.DB = db
+	t.Cleanup(func() {
+		model.DB = oldDB
+	})
+}
+
+func listStoredResponseInputItems(id string, query string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/responses/"+id+"/input_items?"+query, nil)
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	c.Set("id", 1)
+	ListStoredResponseInputItems(c)
+	return recorder
+}
+
+func TestListStoredResponseInputItemsPaginatesWithCursor(t *testing.T) {
+	setupStoredResponseTestDB(t)
+	// 上一轮响应已被删除时仍返回本轮输入
+	stored := &model.StoredResponse{
+		Id:                 "resp_2",
+		UserId:             1,
+		PreviousResponseId: "resp_deleted",
+		Input:              model.LargeBlob(`[{"id":"msg_a","type":"message"},{"id":"msg_b","type":"message"},{"id":"msg_c","type":"message"}]`),
+	}
+	if err := stored.Save(context.Background()); err != nil {
+		t.Fatalf("save stored response: %v", err)
+	}
+
+	recorder := listStoredResponseInputItems("resp_2", "order=asc&limit=1&after=msg_a")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", recorder.Code, recorder.Body.String())
+	}
+	body := recorder.Body.String()
+	if gjson.Get(body, "data.#").Int() != 1 || gjson.Get(body, "first_id").String() != "msg_b" || !gjson.Get(body, "has_more").Bool() {
+		t.Fatalf("page = %s", body)
+	}
+
+	recorder = listStoredResponseInputItems("resp_2", "after=msg_unknown")
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("unknown cursor status = %d, want 400", recorder.Code)
+	}
+	if got := gjson.Get(recorder.Body.String(), "error.param").String(); got != "after" {
+		t.Fatalf("error param = %q, want after", got)
+	}
+}
@@ -1,27 +1,53 @@
 package controller
 
 import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/constant"
 	"github.com/zhongruan0522/new-api/service"
 )
 
 type createTicketRequest struct {
-	Title   string `json:"title"`
-	Type    string `json:"type"`
-	Content string `json:"content"`
+	Title      string   `json:"title"`
+	Type       string   `json:"type"`
+	Priority   string   `json:"priority"`
+	Content    string   `json:"content"`
+	RequestIds []string `json:"request_ids"`
 }
 
 type replyTicketRequest struct {
-	Content string `json:"content"`
+	Content       string `json:"content"`
+	CannedReplyId int    `json:"canned_reply_id"`
 }
 
 type updateTicketStatusRequest struct {
 	Status string `json:"status"`
 }
 
+type updateTicketPriorityRequest struct {
+	Priority string `json:"priority"`
+}
+
+type assignTicketRequest struct {
+	AssigneeId int `json:"assignee_id"`
+}
+
+type updateTicketRequestIdsRequest struct {
+	RequestIds []string `json:"request_ids"`
+}
+
+type ticketCannedReplyRequest struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
 func GetUserTickets(c *gin.Context) {
 	pageInfo := common.GetPageQuery(c)
 	items, total, err := service.ListUserTickets(c.GetInt("id"), pageInfo.GetPage(), pageInfo.GetPageSize(), c.DefaultQuery("status", "all"), c.Query("keyword"))
@@ -36,7 +62,8 @@ func GetUserTickets(c *gin.Context) {
 
 func GetAdminTickets(c *gin.Context) {
 	pageInfo := common.GetPageQuery(c)
-	items, total, err := service.ListAdminTickets(c.GetInt("role"), pageInfo.GetPage(), pageInfo.GetPageSize(), c.DefaultQuery("status", "all"), c.Query("keyword"))
+	assigneeId, _ := strconv.Atoi(c.Query("assignee_id"))
+	items, total, err := service.ListAdminTickets(c.GetInt("role"), pageInfo.GetPage(), pageInfo.GetPageSize(), c.DefaultQuery("status", "all"), c.Query("keyword"), c.DefaultQuery("priority", "all"), assigneeId)
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -54,12 +81,14 @@ func CreateTicket(c *gin.Context) {
 	}
 
 	data, err := service.CreateTicket(service.CreateTicketInput{
-		UserId:   c.GetInt("id"),
-		Username: c.GetString("username"),
-		Role:     c.GetInt("role"),
-		Title:    req.Title,
-		Type:     req.Type,
-		Content:  req.Content,
+		UserId:     c.GetInt("id"),
+		Username:   c.GetString("username"),
+		Role:       c.GetInt("role"),
+		Title:      req.Title,
+		Type:       req.Type,
+		Priority:   req.Priority,
+		Content:    req.Content,
+		RequestIds: req.RequestIds,
 	})
 	if err != nil {
 		common.ApiError(c, err)
@@ -97,11 +126,12 @@ func ReplyTicket(c *gin.Context) {
 	}
 
 	err = service.ReplyTicket(service.ReplyTicketInput{
-		TicketId: ticketId,
-		UserId:   c.GetInt("id"),
-		Username: c.GetString("username"),
-		Role:     c.GetInt("role"),
-		Content:  req.Content,
+		TicketId:      ticketId,
+		UserId:        c.GetInt("id"),
+		Username:      c.GetString("username"),
+		Role:          c.GetInt("role"),
+		Content:       req.Content,
+		CannedReplyId: req.CannedReplyId,
 	})
 	if err != nil {
 		common.ApiError(c, err)
@@ -145,3 +175,232 @@ func UpdateTicketStatus(c *gin.Context) {
 	}
 	common.ApiSuccess(c, nil)
 }
+
+func UpdateTicketPriority(c *gin.Context) {
+	ticketId, err := strconv.Atoi(c.Param("id"))
+	if err != nil || ticketId <= 0 {
+		common.ApiErrorMsg(c, "无效的工单编号")
+		return
+	}
+
+	var req updateTicketPriorityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+
+	err = service.UpdateTicketPriority(ticketId, c.GetInt("id"), c.GetInt("role"), c.GetString("username"), req.Priority)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func AssignTicket(c *gin.Context) {
+	ticketId, err := strconv.Atoi(c.Param("id"))
+	if err != nil || ticketId <= 0 {
+		common.ApiErrorMsg(c, "无效的工单编号")
+		return
+	}
+
+	var req assignTicketRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+
+	err = service.AssignTicket(ticketId, c.GetInt("id"), c.GetInt("role"), c.GetString("username"), req.AssigneeId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func UpdateTicketRequestIds(c *gin.Context) {
+	ticketId, err := strconv.Atoi(c.Param("id"))
+	if err != nil || ticketId <= 0 {
+		common.ApiErrorMsg(c, "无效的工单编号")
+		return
+	}
+
+	var req updateTicketRequestIdsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+
+	err = service.UpdateTicketRequestIds(ticketId, c.GetInt("id"), c.GetInt("role"), req.RequestIds)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func GetTicketLogs(c *gin.Context) {
+	ticketId, err := strconv.Atoi(c.Param("id"))
+	if err != nil || ticketId <= 0 {
+		common.ApiErrorMsg(c, "无效的工单编号")
+		return
+	}
+
+	logs, err := service.GetTicketLinkedLogs(ticketId, c.GetInt("id"), c.GetInt("role"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, logs)
+}
+
+func UploadTicketAttachment(c *gin.Context) {
+	ticketId, err := strconv.Atoi(c.Param("id"))
+	if err != nil || ticketId <= 0 {
+		common.ApiErrorMsg(c, "无效的工单编号")
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		common.ApiErrorMsg(c, "请选择要上传的附件")
+		return
+	}
+	maxBytes := service.TicketAttachmentMaxBytes()
+	if maxBytes > 0 && fileHeader.Size > maxBytes {
+		common.ApiErrorMsg(c, fmt.Sprintf("附件大小超过限制 %d MB", constant.MaxImageUploadMB))
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+
+	info, err := service.UploadTicketAttachment(service.UploadTicketAttachmentInput{
+		TicketId: ticketId,
+		UserId:   c.GetInt("id"),
+		Username: c.GetString("username"),
+		Role:     c.GetInt("role"),
+		FileName: fileHeader.Filename,
+		Content:  c.PostForm("content"),
+		Data:     data,
+	})
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, info)
+}
+
+func GetTicketAttachment(c *gin.Context) {
+	ticketId, err := strconv.Atoi(c.Param("id"))
+	if err != nil || ticketId <= 0 {
+		common.ApiErrorMsg(c, "无效的工单编号")
+		return
+	}
+
+	attachment, err := service.GetTicketAttachmentFile(ticketId, c.Param("attachment_id"), c.GetInt("id"), c.GetInt("role"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	disposition := "attachment"
+	if strings.HasPrefix(attachment.MimeType, "image/") {
+		disposition = "inline"
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, attachment.MimeType, attachment.Data)
+}
+
+func GetTicketCannedReplies(c *gin.Context) {
+	replies, err := service.ListTicketCannedReplies(c.GetInt("role"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, replies)
+}
+
+func CreateTicketCannedReply(c *gin.Context) {
+	var req ticketCannedReplyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+
+	reply, err := service.SaveTicketCannedReply(c.GetInt("role"), c.GetInt("id"), 0, req.Title, req.Content)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, reply)
+}
+
+func UpdateTicketCannedReply(c *gin.Context) {
+	replyId, err := strconv.Atoi(c.Param("reply_id"))
+	if err != nil || replyId <= 0 {
+		common.ApiErrorMsg(c, "无效的快捷回复编号")
+		return
+	}
+
+	var req ticketCannedReplyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+
+	reply, err := service.SaveTicketCannedReply(c.GetInt("role"), c.GetInt("id"), replyId, req.Title, req.Content)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, reply)
+}
+
+func DeleteTicketCannedReply(c *gin.Context) {
+	replyId, err := strconv.Atoi(c.Param("reply_id"))
+	if err != nil || replyId <= 0 {
+		common.ApiErrorMsg(c, "无效的快捷回复编号")
+		return
+	}
+
+	if err := service.DeleteTicketCannedReply(c.GetInt("role"), replyId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// InboundTicketEmail 收信 Webhook：将用户对通知邮件的回复写入工单，回信地址中的签名用于校验发件身份
+func InboundTicketEmail(c *gin.Context) {
+	token := c.GetHeader("X-Ticket-Inbound-Token")
+	if token == "" {
+		token = c.Query("token")
+	}
+	if !service.VerifyInboundTicketWebhook(token) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "无权进行此操作",
+		})
+		return
+	}
+
+	var email service.InboundTicketEmail
+	if err := c.ShouldBind(&email); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := service.HandleInboundTicketEmail(email); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
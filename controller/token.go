@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
 	"github.com/zhongruan0522/new-api/i18n"
 	"github.com/zhongruan0522/new-api/model"
 
@@ -172,6 +173,14 @@ func AddToken(c *gin.Context) {
 		common.ApiErrorI18n(c, i18n.MsgTokenNameTooLong)
 		return
 	}
+	if token.ReasoningOutput != "" {
+		mode, ok := dto.ReasoningOutputMode(token.ReasoningOutput).Normalize()
+		if !ok {
+			common.ApiErrorI18n(c, i18n.MsgTokenReasoningOutputInvalid)
+			return
+		}
+		token.ReasoningOutput = string(mode)
+	}
 
 	// 根据 quota_type 验证额度参数
 	quotaType := token.QuotaType
@@ -297,6 +306,7 @@ func AddToken(c *gin.Context) {
 		AllowIps:           token.AllowIps,
 		Group:              token.Group,
 		CrossGroupRetry:    token.CrossGroupRetry,
+		ReasoningOutput:    token.ReasoningOutput,
 		QuotaType:          quotaType,
 		WindowHours:        token.WindowHours,
 		WindowQuota:        token.WindowQuota,
@@ -351,6 +361,14 @@ func UpdateToken(c *gin.Context) {
 		common.ApiErrorI18n(c, i18n.MsgTokenNameTooLong)
 		return
 	}
+	if token.ReasoningOutput != "" {
+		mode, ok := dto.ReasoningOutputMode(token.ReasoningOutput).Normalize()
+		if !ok {
+			common.ApiErrorI18n(c, i18n.MsgTokenReasoningOutputInvalid)
+			return
+		}
+		token.ReasoningOutput = string(mode)
+	}
 
 	// 根据 quota_type 验证额度参数
 	quotaType := token.QuotaType
@@ -469,6 +487,7 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
 		cleanToken.CrossGroupRetry = token.CrossGroupRetry
+		cleanToken.ReasoningOutput = token.ReasoningOutput
 		cleanToken.QuotaType = quotaType
 		cleanToken.WindowHours = token.WindowHours
 		cleanToken.WindowQuota = token.WindowQuota
@@ -55,6 +55,7 @@ func GetTopUpInfo(c *gin.Context) {
 		"stripe_min_topup":    setting.StripeMinTopUp,
 		"amount_options":      operation_setting.GetPaymentSetting().AmountOptions,
 		"discount":            operation_setting.GetPaymentSetting().AmountDiscount,
+		"bonus_tiers":         operation_setting.GetPaymentSetting().BonusTiers,
 		"topup_link":          common.TopUpLink,
 	}
 	common.ApiSuccess(c, data)
@@ -63,10 +64,12 @@ func GetTopUpInfo(c *gin.Context) {
 type EpayRequest struct {
 	Amount        int64  `json:"amount"`
 	PaymentMethod string `json:"payment_method"`
+	CouponCode    string `json:"coupon_code,omitempty"`
 }
 
 type AmountRequest struct {
-	Amount int64 `json:"amount"`
+	Amount     int64  `json:"amount"`
+	CouponCode string `json:"coupon_code,omitempty"`
 }
 
 func GetEpayClient() *epay.Client {
@@ -129,7 +132,11 @@ func RequestEpay(c *gin.Context) {
 		c.JSON(200, gin.H{"message": "error", "data": "获取用户分组失败"})
 		return
 	}
-	payMoney := getPayMoney(req.Amount, group)
+	payMoney, couponQuote, err := model.QuoteCoupon(req.CouponCode, id, group, req.Amount, getPayMoney(req.Amount, group))
+	if err != nil {
+		c.JSON(200, gin.H{"message": "error", "data": err.Error()})
+		return
+	}
 	if payMoney < 0.01 {
 		c.JSON(200, gin.H{"message": "error", "data": "充值金额过低"})
 		return
@@ -174,9 +181,15 @@ func RequestEpay(c *gin.Context) {
 		CreateTime:      time.Now().Unix(),
 		Status:          common.TopUpStatusPending,
 	}
+	couponQuote.ApplyToTopUp(topUp)
+	topUp.ApplyBonusTier()
 	err = topUp.Insert()
 	if err != nil {
-		c.JSON(200, gin.H{"message": "error", "data": "创建订单失败"})
+		message := "创建订单失败"
+		if model.IsCouponLimitError(err) {
+			message = err.Error()
+		}
+		c.JSON(200, gin.H{"message": "error", "data": message})
 		return
 	}
 	c.JSON(200, gin.H{"message": "success", "data": params, "url": uri})
@@ -292,12 +305,24 @@ func RequestAmount(c *gin.Context) {
 		c.JSON(200, gin.H{"message": "error", "data": "获取用户分组失败"})
 		return
 	}
-	payMoney := getPayMoney(req.Amount, group)
+	payMoney, couponQuote, err := model.QuoteCoupon(req.CouponCode, id, group, req.Amount, getPayMoney(req.Amount, group))
+	if err != nil {
+		c.JSON(200, gin.H{"message": "error", "data": err.Error()})
+		return
+	}
 	if payMoney <= 0.01 {
 		c.JSON(200, gin.H{"message": "error", "data": "充值金额过低"})
 		return
 	}
-	c.JSON(200, gin.H{"message": "success", "data": strconv.FormatFloat(payMoney, 'f', 2, 64)})
+	estimate := &model.TopUp{Amount: req.Amount, PaymentProvider: model.PaymentProviderEpay}
+	couponQuote.ApplyToTopUp(estimate)
+	estimate.ApplyBonusTier()
+	c.JSON(200, gin.H{
+		"message":        "success",
+		"data":           strconv.FormatFloat(payMoney, 'f', 2, 64),
+		"discount_money": estimate.DiscountMoney,
+		"bonus_quota":    estimate.BonusQuota,
+	})
 }
 
 func GetUserTopUps(c *gin.Context) {
@@ -1,9 +1,11 @@
 package controller
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
@@ -18,6 +20,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/stripe/stripe-go/v81"
 	"github.com/stripe/stripe-go/v81/checkout/session"
+	"github.com/stripe/stripe-go/v81/coupon"
+	"github.com/stripe/stripe-go/v81/price"
 	"github.com/stripe/stripe-go/v81/webhook"
 	"github.com/thanhpk/randstr"
 )
@@ -40,6 +44,8 @@ type StripePayRequest struct {
 	// CancelURL is the optional custom URL to redirect when payment is canceled.
 	// If empty, defaults to the server's console topup page.
 	CancelURL string `json:"cancel_url,omitempty"`
+	// CouponCode is the optional top-up coupon to apply to this order.
+	CouponCode string `json:"coupon_code,omitempty"`
 }
 
 type StripeAdaptor struct {
@@ -56,12 +62,28 @@ func (*StripeAdaptor) RequestAmount(c *gin.Context, req *StripePayRequest) {
 		c.JSON(200, gin.H{"message": "error", "data": "获取用户分组失败"})
 		return
 	}
-	payMoney := getStripePayMoney(float64(req.Amount), group)
+	payMoney, couponQuote, err := model.QuoteCoupon(req.CouponCode, id, group, req.Amount, getStripePayMoney(float64(req.Amount), group))
+	if err != nil {
+		c.JSON(200, gin.H{"message": "error", "data": err.Error()})
+		return
+	}
 	if payMoney <= 0.01 {
 		c.JSON(200, gin.H{"message": "error", "data": "充值金额过低"})
 		return
 	}
-	c.JSON(200, gin.H{"message": "success", "data": strconv.FormatFloat(payMoney, 'f', 2, 64)})
+	estimate := &model.TopUp{
+		Amount:        req.Amount,
+		Money:         getStripeChargedMoney(float64(req.Amount), group),
+		PaymentMethod: model.PaymentMethodStripe,
+	}
+	couponQuote.ApplyToTopUp(estimate)
+	estimate.ApplyBonusTier()
+	c.JSON(200, gin.H{
+		"message":        "success",
+		"data":           strconv.FormatFloat(payMoney, 'f', 2, 64),
+		"discount_money": estimate.DiscountMoney,
+		"bonus_quota":    estimate.BonusQuota,
+	})
 }
 
 func (*StripeAdaptor) RequestPay(c *gin.Context, req *StripePayRequest) {
@@ -92,10 +114,20 @@ func (*StripeAdaptor) RequestPay(c *gin.Context, req *StripePayRequest) {
 	user, _ := model.GetUserById(id, false)
 	chargedMoney := GetChargedAmount(float64(req.Amount), *user)
 
+	_, couponQuote, err := model.QuoteCoupon(req.CouponCode, id, user.Group, req.Amount, getStripePayMoney(float64(req.Amount), user.Group))
+	if err != nil {
+		c.JSON(200, gin.H{"message": "error", "data": err.Error()})
+		return
+	}
+	discountMoney := 0.0
+	if couponQuote != nil {
+		discountMoney = couponQuote.DiscountMoney
+	}
+
 	reference := fmt.Sprintf("new-api-ref-%d-%d-%s", user.Id, time.Now().UnixMilli(), randstr.String(4))
 	referenceId := "ref_" + common.Sha1([]byte(reference))
 
-	payLink, err := genStripeLink(referenceId, user.StripeCustomer, user.Email, req.Amount, req.SuccessURL, req.CancelURL)
+	payLink, err := genStripeLink(referenceId, user.StripeCustomer, user.Email, req.Amount, req.SuccessURL, req.CancelURL, discountMoney)
 	if err != nil {
 		log.Println("获取Stripe Checkout支付链接失败", err)
 		c.JSON(200, gin.H{"message": "error", "data": "拉起支付失败"})
@@ -112,9 +144,15 @@ func (*StripeAdaptor) RequestPay(c *gin.Context, req *StripePayRequest) {
 		CreateTime:      time.Now().Unix(),
 		Status:          common.TopUpStatusPending,
 	}
+	couponQuote.ApplyToTopUp(topUp)
+	topUp.ApplyBonusTier()
 	err = topUp.Insert()
 	if err != nil {
-		c.JSON(200, gin.H{"message": "error", "data": "创建订单失败"})
+		message := "创建订单失败"
+		if model.IsCouponLimitError(err) {
+			message = err.Error()
+		}
+		c.JSON(200, gin.H{"message": "error", "data": message})
 		return
 	}
 	c.JSON(200, gin.H{
@@ -195,6 +233,9 @@ func sessionCompleted(event stripe.Event) {
 		return
 	}
 
+	// the discount is applied once the session completes, so the one-off coupon is no longer needed
+	deleteStripeSessionCoupon(event, referenceId)
+
 	paymentStatus := event.GetObjectValue("payment_status")
 	if paymentStatus != "paid" {
 		log.Printf("Stripe Checkout 支付尚未完成，payment_status: %s, ref: %s（等待异步支付结果）", paymentStatus, referenceId)
@@ -269,6 +310,8 @@ func sessionExpired(event stripe.Event) {
 		return
 	}
 
+	deleteStripeSessionCoupon(event, referenceId)
+
 	LockOrder(referenceId)
 	defer UnlockOrder(referenceId)
 
@@ -290,9 +333,10 @@ func sessionExpired(event stripe.Event) {
 //   - amount: quantity of units to purchase
 //   - successURL: custom URL to redirect after successful payment (empty for default)
 //   - cancelURL: custom URL to redirect when payment is canceled (empty for default)
+//   - discountMoney: coupon discount in the price's currency, applied as a one-off Stripe coupon (0 for none)
 //
 // Returns the checkout session URL or an error if the session creation fails.
-func genStripeLink(referenceId string, customerId string, email string, amount int64, successURL string, cancelURL string) (string, error) {
+func genStripeLink(referenceId string, customerId string, email string, amount int64, successURL string, cancelURL string, discountMoney float64) (string, error) {
 	if !strings.HasPrefix(setting.StripeApiSecret, "sk_") && !strings.HasPrefix(setting.StripeApiSecret, "rk_") {
 		return "", fmt.Errorf("无效的Stripe API密钥")
 	}
@@ -321,6 +365,18 @@ func genStripeLink(referenceId string, customerId string, email string, amount i
 		AllowPromotionCodes: stripe.Bool(setting.StripePromotionCodesEnabled),
 	}
 
+	if discountMoney > 0 {
+		couponId, err := createStripeDiscountCoupon(referenceId, discountMoney)
+		if err != nil {
+			return "", err
+		}
+		// Stripe does not allow promotion codes together with pre-applied discounts
+		params.AllowPromotionCodes = nil
+		params.Discounts = []*stripe.CheckoutSessionDiscountParams{
+			{Coupon: stripe.String(couponId)},
+		}
+	}
+
 	if "" == customerId {
 		if "" != email {
 			params.CustomerEmail = stripe.String(email)
@@ -333,14 +389,88 @@ func genStripeLink(referenceId string, customerId string, email string, amount i
 
 	result, err := session.New(params)
 	if err != nil {
+		if discountMoney > 0 {
+			deleteStripeDiscountCoupon(referenceId)
+		}
 		return "", err
 	}
 
 	return result.URL, nil
 }
 
+// createStripeDiscountCoupon creates a single-use Stripe coupon worth the given
+// amount in the configured price's currency and returns its ID.
+func createStripeDiscountCoupon(referenceId string, discountMoney float64) (string, error) {
+	stripePrice, err := price.Get(setting.StripePriceId, nil)
+	if err != nil {
+		return "", err
+	}
+	currency := string(stripePrice.Currency)
+	amountOff := int64(math.Round(discountMoney * 100))
+	if stripeZeroDecimalCurrencies[strings.ToLower(currency)] {
+		amountOff = int64(math.Round(discountMoney))
+	}
+	if amountOff <= 0 {
+		return "", fmt.Errorf("无效的优惠金额")
+	}
+	result, err := coupon.New(&stripe.CouponParams{
+		ID:             stripe.String(stripeDiscountCouponId(referenceId)),
+		AmountOff:      stripe.Int64(amountOff),
+		Currency:       stripe.String(currency),
+		Duration:       stripe.String(string(stripe.CouponDurationOnce)),
+		MaxRedemptions: stripe.Int64(1),
+		Name:           stripe.String("Top-up coupon " + referenceId[len(referenceId)-8:]),
+	})
+	if err != nil {
+		return "", err
+	}
+	return result.ID, nil
+}
+
+// stripeDiscountCouponId derives the one-off coupon ID from the order reference so
+// webhooks can delete it without storing the ID on the order.
+func stripeDiscountCouponId(referenceId string) string {
+	return "topup_" + referenceId
+}
+
+// deleteStripeSessionCoupon removes the one-off coupon of a finished checkout session,
+// if the session carried a discount.
+func deleteStripeSessionCoupon(event stripe.Event, referenceId string) {
+	if referenceId == "" {
+		return
+	}
+	discount, _ := strconv.ParseInt(event.GetObjectValue("total_details", "amount_discount"), 10, 64)
+	if discount <= 0 {
+		return
+	}
+	deleteStripeDiscountCoupon(referenceId)
+}
+
+func deleteStripeDiscountCoupon(referenceId string) {
+	stripe.Key = setting.StripeApiSecret
+	if _, err := coupon.Del(stripeDiscountCouponId(referenceId), nil); err != nil {
+		var stripeErr *stripe.Error
+		if errors.As(err, &stripeErr) && stripeErr.HTTPStatusCode == http.StatusNotFound {
+			return
+		}
+		log.Printf("删除Stripe优惠券失败: %v, ref: %s", err, referenceId)
+	}
+}
+
+// stripeZeroDecimalCurrencies lists currencies whose amounts are not expressed in cents.
+var stripeZeroDecimalCurrencies = map[string]bool{
+	"bif": true, "clp": true, "djf": true, "gnf": true, "jpy": true, "kmf": true, "krw": true, "mga": true,
+	"pyg": true, "rwf": true, "ugx": true, "vnd": true, "vuv": true, "xaf": true, "xof": true, "xpf": true,
+}
+
 func GetChargedAmount(count float64, user model.User) float64 {
-	topUpGroupRatio := common.GetTopupGroupRatio(user.Group)
+	return getStripeChargedMoney(count, user.Group)
+}
+
+// getStripeChargedMoney returns the USD value credited for a Stripe order, which
+// is stored as TopUp.Money and converted to quota on fulfillment.
+func getStripeChargedMoney(count float64, group string) float64 {
+	topUpGroupRatio := common.GetTopupGroupRatio(group)
 	if topUpGroupRatio == 0 {
 		topUpGroupRatio = 1
 	}
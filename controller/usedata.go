@@ -107,6 +107,31 @@ func GetAllMediaConvertStats(c *gin.Context) {
 	})
 }
 
+// GetCacheEconomicsReport 按渠道、模型或亲和规则汇总提示词缓存的读写费用、节省额与亲和断开造成的损失
+func GetCacheEconomicsReport(c *gin.Context) {
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	if isUserQuotaRangeTooLong(startTimestamp, endTimestamp) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "时间跨度不能超过 1 个月",
+		})
+		return
+	}
+	groupBy := c.DefaultQuery("group_by", service.CacheEconomicsGroupByChannel)
+	if !service.IsValidCacheEconomicsGroupBy(groupBy) {
+		common.ApiErrorMsg(c, "无效的 group_by，可选值：channel、model、rule")
+		return
+	}
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	data, err := model.GetCacheUsageData(startTimestamp, endTimestamp, channelId, c.Query("model_name"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, service.BuildCacheEconomicsReport(data, groupBy))
+}
+
 // RecalculateQuotaData 管理员触发重新计算指定时间范围的数据看板
 func RecalculateQuotaData(c *gin.Context) {
 	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/model"
+	"github.com/zhongruan0522/new-api/relay"
+	"github.com/zhongruan0522/new-api/service"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	taskPollInterval  = 5 * time.Second
+	taskPollBatchSize = 20
+)
+
+var taskPollerOnce sync.Once
+
+// loadUserVideoTask 读取当前令牌用户的视频任务，失败时已写入 OpenAI 格式错误
+func loadUserVideoTask(c *gin.Context) (*model.Task, bool) {
+	id := c.Param("id")
+	task, err := model.GetUserTask(c.Request.Context(), c.GetInt("id"), id)
+	if err == nil && task.Platform != model.TaskPlatformVideo {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": types.OpenAIError{
+					Message: fmt.Sprintf("Video with id '%s' not found.", id),
+					Type:    "invalid_request_error",
+					Param:   "video_id",
+				},
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": types.OpenAIError{
+					Message: "query video task failed",
+					Type:    "server_error",
+				},
+			})
+		}
+		return nil, false
+	}
+	return task, true
+}
+
+// GetVideo GET /v1/videos/:id
+func GetVideo(c *gin.Context) {
+	task, ok := loadUserVideoTask(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, relay.BuildVideoObject(task))
+}
+
+// GetVideoContent GET /v1/videos/:id/content
+func GetVideoContent(c *gin.Context) {
+	task, ok := loadUserVideoTask(c)
+	if !ok {
+		return
+	}
+	if task.Status != model.TaskStatusCompleted || task.ResultVideoId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": types.OpenAIError{
+				Message: fmt.Sprintf("Video '%s' is not completed, current status: %s.", task.TaskId, task.Status),
+				Type:    "invalid_request_error",
+				Param:   "video_id",
+			},
+		})
+		return
+	}
+	video, err := model.GetStoredVideoByID(c.Request.Context(), task.ResultVideoId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": types.OpenAIError{
+				Message: "read video content failed",
+				Type:    "server_error",
+			},
+		})
+		return
+	}
+	contentType := strings.TrimSpace(video.MimeType)
+	if contentType == "" {
+		contentType = "video/mp4"
+	}
+	c.Data(http.StatusOK, contentType, []byte(video.Data))
+}
+
+// RunTaskPoller 后台轮询未完成的异步任务，仅在主节点运行
+func RunTaskPoller() {
+	if !common.IsMasterNode {
+		return
+	}
+	taskPollerOnce.Do(func() {
+		for {
+			time.Sleep(taskPollInterval)
+			tasks, err := model.GetDuePendingTasks(context.Background(), common.GetTimestamp(), taskPollBatchSize)
+			if err != nil {
+				common.SysError("failed to query pending tasks: " + err.Error())
+				continue
+			}
+			for _, task := range tasks {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+				if err := relay.PollVideoTask(ctx, task); err != nil {
+					common.SysError(fmt.Sprintf("failed to update task %s: %s", task.TaskId, err.Error()))
+				}
+				cancel()
+			}
+			retryTaskRefunds()
+		}
+	})
+}
+
+// retryTaskRefunds 重试此前退款失败的任务
+func retryTaskRefunds() {
+	tasks, err := model.GetUnrefundedFailedTasks(context.Background(), taskPollBatchSize)
+	if err != nil {
+		common.SysError("failed to query unrefunded tasks: " + err.Error())
+		return
+	}
+	for _, task := range tasks {
+		if err := service.RefundTask(task); err != nil {
+			common.SysError(err.Error())
+		}
+	}
+}
@@ -81,6 +81,197 @@ type ChannelOtherSettings struct {
 	// ImageAutoConvertToURL is a removed legacy field that is kept read-only for
 	// compatibility with existing rows before migration cleanup runs.
 	ImageAutoConvertToURL bool `json:"image_auto_convert_to_url,omitempty"`
+
+	// LowBalanceThreshold triggers LowBalanceAction when the polled upstream
+	// balance drops below it. It is in USD; providers that report CNY are
+	// converted with the top-up exchange rate first. Zero disables the check.
+	LowBalanceThreshold float64          `json:"low_balance_threshold,omitempty"`
+	LowBalanceAction    LowBalanceAction `json:"low_balance_action,omitempty"`
+	// LowBalancePriority is the priority applied when LowBalanceAction is
+	// "lower_priority". The original priority is restored once the balance recovers.
+	LowBalancePriority int64 `json:"low_balance_priority,omitempty"`
+
+	// ResponseParamOverride rewrites upstream response bodies and each stream
+	// chunk using the same format (and condition engine) as the channel
+	// param_override, e.g. {"operations":[{"mode":"delete","path":"vendor"}]}.
+	ResponseParamOverride map[string]interface{} `json:"response_param_override,omitempty"`
+	// ResponseHeaderOverride sets response headers sent to the client; a null
+	// or empty value removes the header.
+	ResponseHeaderOverride map[string]interface{} `json:"response_header_override,omitempty"`
+
+	// TransformScript is an optional Starlark script defining transform_request,
+	// transform_response and/or transform_event hooks (see pkg/scripthook).
+	TransformScript string `json:"transform_script,omitempty"`
+
+	// TrafficMode holds the channel out of normal selection while it is being evaluated:
+	//   - "canary" : serves TrafficPercent% of matching real requests
+	//   - "shadow" : receives a copy of TrafficPercent% of matching requests; the
+	//     shadow response is discarded and never billed to users
+	TrafficMode    ChannelTrafficMode `json:"traffic_mode,omitempty"`
+	TrafficPercent float64            `json:"traffic_percent,omitempty"`
+
+	// SelfHostedBackend names the inference server behind a self-hosted channel
+	// ("vllm", "llamacpp" or "tgi"). It selects the metrics endpoint that is
+	// scraped and the structured-output dialect. Empty is treated as "vllm".
+	SelfHostedBackend SelfHostedBackend `json:"self_hosted_backend,omitempty"`
+	// SelfHostedMaxQueue and SelfHostedMaxKVCacheUsage (0-1) mark the backend as
+	// saturated so channel selection skips it. Zero uses the built-in defaults
+	// and a negative value disables the check.
+	SelfHostedMaxQueue        int     `json:"self_hosted_max_queue,omitempty"`
+	SelfHostedMaxKVCacheUsage float64 `json:"self_hosted_max_kv_cache_usage,omitempty"`
+
+	// StructuredOutputEmulation emulates response_format json_schema for
+	// upstreams that ignore it. The relay validates the returned JSON against
+	// the schema and retries once with the validation error on mismatch.
+	// Supported values:
+	//   - "off"    : forward response_format unchanged (default)
+	//   - "tool"   : convert the schema into a forced function call
+	//   - "prompt" : describe the schema in a system instruction
+	StructuredOutputEmulation StructuredOutputEmulation `json:"structured_output_emulation,omitempty"`
+
+	// ToolCallEmulation serves tools to models without native function calling:
+	// tool definitions are rendered into the system prompt, textual tool
+	// invocations are parsed back into tool_calls and tool role messages are
+	// sent back as text. The value selects the invocation syntax taught to the
+	// model; both syntaxes are recognised when parsing.
+	//   - "off"  : forward tools unchanged (default)
+	//   - "xml"  : <tool_call><name>..</name><arguments>{..}</arguments></tool_call>
+	//   - "json" : fenced ```tool_call blocks holding {"name":..,"arguments":{..}}
+	ToolCallEmulation ToolCallEmulation `json:"tool_call_emulation,omitempty"`
+
+	// RealtimeBridge serves /v1/realtime on channels that do not speak the
+	// OpenAI Realtime protocol. Clients keep using OpenAI Realtime events while
+	// the relay runs server-side VAD and drives the configured backend. Gemini
+	// channels without this setting default to "gemini_live".
+	RealtimeBridge *RealtimeBridgeSettings `json:"realtime_bridge,omitempty"`
+}
+
+type RealtimeBridgeMode string
+
+const (
+	// RealtimeBridgeModePipeline chains STT -> chat -> TTS, each stage routed
+	// to any channel of the user's group that serves the configured model.
+	RealtimeBridgeModePipeline RealtimeBridgeMode = "pipeline"
+	// RealtimeBridgeModeGeminiLive talks to the Gemini Live API of the channel.
+	RealtimeBridgeModeGeminiLive RealtimeBridgeMode = "gemini_live"
+)
+
+func (mode RealtimeBridgeMode) Normalize() (RealtimeBridgeMode, bool) {
+	raw := strings.TrimSpace(strings.ToLower(string(mode)))
+	switch RealtimeBridgeMode(raw) {
+	case "", RealtimeBridgeModePipeline, RealtimeBridgeModeGeminiLive:
+		return RealtimeBridgeMode(raw), true
+	default:
+		return "", false
+	}
+}
+
+type RealtimeBridgeSettings struct {
+	Mode      RealtimeBridgeMode `json:"mode,omitempty"`
+	STTModel  string             `json:"stt_model,omitempty"`
+	ChatModel string             `json:"chat_model,omitempty"`
+	TTSModel  string             `json:"tts_model,omitempty"`
+	// Voice overrides the voice requested by the client, since OpenAI voice
+	// names are usually unknown to other TTS / Gemini backends.
+	Voice string `json:"voice,omitempty"`
+}
+
+type ToolCallEmulation string
+
+const (
+	ToolCallEmulationOff  ToolCallEmulation = "off"
+	ToolCallEmulationXML  ToolCallEmulation = "xml"
+	ToolCallEmulationJSON ToolCallEmulation = "json"
+)
+
+func (mode ToolCallEmulation) Normalize() (ToolCallEmulation, bool) {
+	raw := strings.TrimSpace(strings.ToLower(string(mode)))
+	switch ToolCallEmulation(raw) {
+	case "":
+		return ToolCallEmulationOff, true
+	case ToolCallEmulationOff, ToolCallEmulationXML, ToolCallEmulationJSON:
+		return ToolCallEmulation(raw), true
+	default:
+		return ToolCallEmulationOff, false
+	}
+}
+
+type StructuredOutputEmulation string
+
+const (
+	StructuredOutputEmulationOff    StructuredOutputEmulation = "off"
+	StructuredOutputEmulationTool   StructuredOutputEmulation = "tool"
+	StructuredOutputEmulationPrompt StructuredOutputEmulation = "prompt"
+)
+
+func (mode StructuredOutputEmulation) Normalize() (StructuredOutputEmulation, bool) {
+	raw := strings.TrimSpace(strings.ToLower(string(mode)))
+	switch StructuredOutputEmulation(raw) {
+	case "":
+		return StructuredOutputEmulationOff, true
+	case StructuredOutputEmulationOff, StructuredOutputEmulationTool, StructuredOutputEmulationPrompt:
+		return StructuredOutputEmulation(raw), true
+	default:
+		return StructuredOutputEmulationOff, false
+	}
+}
+
+type SelfHostedBackend string
+
+const (
+	SelfHostedBackendVLLM     SelfHostedBackend = "vllm"
+	SelfHostedBackendLlamaCpp SelfHostedBackend = "llamacpp"
+	SelfHostedBackendTGI      SelfHostedBackend = "tgi"
+)
+
+func (backend SelfHostedBackend) Normalize() (SelfHostedBackend, bool) {
+	raw := strings.TrimSpace(strings.ToLower(string(backend)))
+	switch SelfHostedBackend(raw) {
+	case "":
+		return SelfHostedBackendVLLM, true
+	case SelfHostedBackendVLLM, SelfHostedBackendLlamaCpp, SelfHostedBackendTGI:
+		return SelfHostedBackend(raw), true
+	case "llama.cpp", "llama_cpp":
+		return SelfHostedBackendLlamaCpp, true
+	default:
+		return SelfHostedBackendVLLM, false
+	}
+}
+
+type ChannelTrafficMode string
+
+const (
+	ChannelTrafficModeCanary ChannelTrafficMode = "canary"
+	ChannelTrafficModeShadow ChannelTrafficMode = "shadow"
+)
+
+func (mode ChannelTrafficMode) Normalize() (ChannelTrafficMode, bool) {
+	raw := strings.TrimSpace(strings.ToLower(string(mode)))
+	switch ChannelTrafficMode(raw) {
+	case "", ChannelTrafficModeCanary, ChannelTrafficModeShadow:
+		return ChannelTrafficMode(raw), true
+	default:
+		return "", false
+	}
+}
+
+type LowBalanceAction string
+
+const (
+	LowBalanceActionNotify        LowBalanceAction = "notify"
+	LowBalanceActionLowerPriority LowBalanceAction = "lower_priority"
+	LowBalanceActionDisable       LowBalanceAction = "disable"
+)
+
+func (action LowBalanceAction) Normalize() LowBalanceAction {
+	switch LowBalanceAction(strings.TrimSpace(strings.ToLower(string(action)))) {
+	case LowBalanceActionLowerPriority:
+		return LowBalanceActionLowerPriority
+	case LowBalanceActionDisable:
+		return LowBalanceActionDisable
+	default:
+		return LowBalanceActionNotify
+	}
 }
 
 type ImageAutoConvertToURLMode string
@@ -490,10 +490,43 @@ func (c *GeminiChatGenerationConfig) UnmarshalJSON(data []byte) error {
 type MediaResolution string
 
 type GeminiChatCandidate struct {
-	Content       GeminiChatContent        `json:"content"`
-	FinishReason  *string                  `json:"finishReason"`
-	Index         int64                    `json:"index"`
-	SafetyRatings []GeminiChatSafetyRating `json:"safetyRatings"`
+	Content           GeminiChatContent        `json:"content"`
+	FinishReason      *string                  `json:"finishReason"`
+	Index             int64                    `json:"index"`
+	SafetyRatings     []GeminiChatSafetyRating `json:"safetyRatings"`
+	GroundingMetadata *GeminiGroundingMetadata `json:"groundingMetadata,omitempty"`
+}
+
+// GeminiGroundingMetadata lists the web sources a grounded candidate cites.
+type GeminiGroundingMetadata struct {
+	WebSearchQueries  []string                 `json:"webSearchQueries,omitempty"`
+	GroundingChunks   []GeminiGroundingChunk   `json:"groundingChunks,omitempty"`
+	GroundingSupports []GeminiGroundingSupport `json:"groundingSupports,omitempty"`
+	SearchEntryPoint  json.RawMessage          `json:"searchEntryPoint,omitempty"`
+}
+
+type GeminiGroundingChunk struct {
+	Web *GeminiGroundingWeb `json:"web,omitempty"`
+}
+
+type GeminiGroundingWeb struct {
+	URI   string `json:"uri"`
+	Title string `json:"title,omitempty"`
+}
+
+// GeminiGroundingSupport ties a segment of the candidate text to the chunks it cites.
+type GeminiGroundingSupport struct {
+	Segment               GeminiGroundingSegment `json:"segment"`
+	GroundingChunkIndices []int                  `json:"groundingChunkIndices"`
+	ConfidenceScores      []float64              `json:"confidenceScores,omitempty"`
+}
+
+// GeminiGroundingSegment indices are byte offsets into the text of the part at PartIndex.
+type GeminiGroundingSegment struct {
+	PartIndex  int    `json:"partIndex,omitempty"`
+	StartIndex int    `json:"startIndex,omitempty"`
+	EndIndex   int    `json:"endIndex"`
+	Text       string `json:"text,omitempty"`
 }
 
 // UnmarshalJSON allows GeminiChatCandidate to accept both snake_case and camelCase fields.
@@ -501,8 +534,9 @@ func (c *GeminiChatCandidate) UnmarshalJSON(data []byte) error {
 	type Alias GeminiChatCandidate
 	var aux struct {
 		Alias
-		FinishReasonSnake  *string                  `json:"finish_reason,omitempty"`
-		SafetyRatingsSnake []GeminiChatSafetyRating `json:"safety_ratings,omitempty"`
+		FinishReasonSnake      *string                  `json:"finish_reason,omitempty"`
+		SafetyRatingsSnake     []GeminiChatSafetyRating `json:"safety_ratings,omitempty"`
+		GroundingMetadataSnake *GeminiGroundingMetadata `json:"grounding_metadata,omitempty"`
 	}
 
 	if err := common.Unmarshal(data, &aux); err != nil {
@@ -516,6 +550,9 @@ func (c *GeminiChatCandidate) UnmarshalJSON(data []byte) error {
 	if len(aux.SafetyRatingsSnake) > 0 {
 		c.SafetyRatings = aux.SafetyRatingsSnake
 	}
+	if aux.GroundingMetadataSnake != nil {
+		c.GroundingMetadata = aux.GroundingMetadataSnake
+	}
 	return nil
 }
 
@@ -666,6 +703,8 @@ type GeminiImageRequest struct {
 
 type GeminiImageInstance struct {
 	Prompt string `json:"prompt"`
+	// ReferenceImages carries the source image and mask for Imagen edits (Vertex AI only).
+	ReferenceImages []GeminiImageReference `json:"referenceImages,omitempty"`
 }
 
 type GeminiImageParameters struct {
@@ -673,6 +712,23 @@ type GeminiImageParameters struct {
 	AspectRatio      string `json:"aspectRatio,omitempty"`
 	PersonGeneration string `json:"personGeneration,omitempty"`
 	ImageSize        string `json:"imageSize,omitempty"`
+	EditMode         string `json:"editMode,omitempty"`
+}
+
+type GeminiImageReference struct {
+	ReferenceType   string                 `json:"referenceType"`
+	ReferenceId     int                    `json:"referenceId"`
+	ReferenceImage  GeminiImageBytes       `json:"referenceImage"`
+	MaskImageConfig *GeminiMaskImageConfig `json:"maskImageConfig,omitempty"`
+}
+
+type GeminiImageBytes struct {
+	BytesBase64Encoded string `json:"bytesBase64Encoded"`
+}
+
+type GeminiMaskImageConfig struct {
+	MaskMode string  `json:"maskMode"`
+	Dilation float64 `json:"dilation,omitempty"`
 }
 
 type GeminiImageResponse struct {
@@ -0,0 +1,86 @@
+package dto
+
+import "encoding/json"
+
+// MCP (Model Context Protocol) messages are JSON-RPC 2.0 envelopes.
+// https://modelcontextprotocol.io/specification/2025-06-18/basic
+
+const (
+	JsonRpcVersion = "2.0"
+
+	JsonRpcCodeParseError     = -32700
+	JsonRpcCodeInvalidRequest = -32600
+	JsonRpcCodeMethodNotFound = -32601
+	JsonRpcCodeInvalidParams  = -32602
+	JsonRpcCodeInternalError  = -32603
+)
+
+type JsonRpcRequest struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// IsNotification reports whether the request expects no response.
+func (r *JsonRpcRequest) IsNotification() bool {
+	return len(r.Id) == 0 || string(r.Id) == "null"
+}
+
+type JsonRpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type JsonRpcResponse struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *JsonRpcError   `json:"error,omitempty"`
+}
+
+type McpInitializeParams struct {
+	ProtocolVersion string         `json:"protocolVersion"`
+	Capabilities    map[string]any `json:"capabilities,omitempty"`
+	ClientInfo      *McpServerInfo `json:"clientInfo,omitempty"`
+}
+
+type McpServerInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type McpInitializeResult struct {
+	ProtocolVersion string         `json:"protocolVersion"`
+	Capabilities    map[string]any `json:"capabilities"`
+	ServerInfo      McpServerInfo  `json:"serverInfo"`
+	Instructions    string         `json:"instructions,omitempty"`
+}
+
+type McpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+type McpListToolsResult struct {
+	Tools []McpTool `json:"tools"`
+}
+
+type McpCallToolParams struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+}
+
+// McpContent is a tool result content block ("text" or "image").
+type McpContent struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	Data     string `json:"data,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+}
+
+type McpCallToolResult struct {
+	Content []McpContent `json:"content"`
+	IsError bool         `json:"isError,omitempty"`
+}
@@ -5,6 +5,8 @@ type Notify struct {
 	Title   string        `json:"title"`
 	Content string        `json:"content"`
 	Values  []interface{} `json:"values"`
+	// ReplyTo 仅对邮件通知生效，用于接收用户的邮件回复
+	ReplyTo string `json:"-"`
 }
 
 const ContentValueParam = "{{value}}"
@@ -13,6 +15,8 @@ const (
 	NotifyTypeQuotaExceed   = "quota_exceed"
 	NotifyTypeChannelUpdate = "channel_update"
 	NotifyTypeChannelTest   = "channel_test"
+	NotifyTypeTicketSla     = "ticket_sla"
+	NotifyTypeTicket        = "ticket"
 )
 
 func NewNotify(t string, title string, content string, values []interface{}) Notify {
@@ -32,6 +32,9 @@ type ImageRequest struct {
 	WatermarkEnabled json.RawMessage `json:"watermark_enabled,omitempty"`
 	UserId           json.RawMessage `json:"user_id,omitempty"`
 	Image            json.RawMessage `json:"image,omitempty"`
+	// JSON 形式的图片编辑：images 为 [{"image_url": ...}]，mask 为 {"image_url": ...}
+	Images json.RawMessage `json:"images,omitempty"`
+	Mask   json.RawMessage `json:"mask,omitempty"`
 	// 用匿名参数接收额外参数
 	Extra map[string]json.RawMessage `json:"-"`
 }
@@ -87,6 +87,7 @@ type GeneralOpenAIRequest struct {
 	EnableThinking         json.RawMessage `json:"enable_thinking,omitempty"`
 	ChatTemplateKwargs     json.RawMessage `json:"chat_template_kwargs,omitempty"`
 	EnableSearch           json.RawMessage `json:"enable_search,omitempty"`
+	ResultFormat           string          `json:"result_format,omitempty"`
 	// ollama Params
 	Think json.RawMessage `json:"think,omitempty"`
 	// baidu v2
@@ -99,6 +100,11 @@ type GeneralOpenAIRequest struct {
 	ReturnImages           bool            `json:"return_images,omitempty"`
 	ReturnRelatedQuestions bool            `json:"return_related_questions,omitempty"`
 	SearchMode             string          `json:"search_mode,omitempty"`
+	// vLLM Params: guided decoding, mapped per backend by the self-hosted adaptor
+	GuidedJson    json.RawMessage `json:"guided_json,omitempty"`
+	GuidedRegex   string          `json:"guided_regex,omitempty"`
+	GuidedChoice  json.RawMessage `json:"guided_choice,omitempty"`
+	GuidedGrammar string          `json:"guided_grammar,omitempty"`
 }
 
 // createFileSource 根据数据内容创建正确类型的 FileSource
@@ -301,10 +307,29 @@ type Message struct {
 	ToolCalls                json.RawMessage `json:"tool_calls,omitempty"`
 	ToolCallId               string          `json:"tool_call_id,omitempty"`
 	ToolCallIsError          *bool           `json:"tool_call_is_error,omitempty"`
-	parsedContent            []MediaContent
+	// Annotations carries url_citation annotations of assistant responses
+	Annotations   []ChatAnnotation `json:"annotations,omitempty"`
+	parsedContent []MediaContent
 	//parsedStringContent *string
 }
 
+// AnnotationTypeURLCitation is the only annotation type defined for chat and Responses text.
+const AnnotationTypeURLCitation = "url_citation"
+
+// ChatAnnotation is an annotation on an assistant message.
+type ChatAnnotation struct {
+	Type        string           `json:"type"`
+	URLCitation *ChatURLCitation `json:"url_citation,omitempty"`
+}
+
+// ChatURLCitation indices are character offsets into the message content.
+type ChatURLCitation struct {
+	StartIndex int    `json:"start_index"`
+	EndIndex   int    `json:"end_index"`
+	URL        string `json:"url"`
+	Title      string `json:"title"`
+}
+
 type MediaContent struct {
 	Type       string `json:"type"`
 	Text       string `json:"text,omitempty"`
@@ -460,6 +485,14 @@ func (m *Message) StringContent() string {
 			}
 		}
 		return contentStr
+	case []MediaContent:
+		var contentStr string
+		for _, contentItem := range m.Content.([]MediaContent) {
+			if contentItem.Type == ContentTypeText {
+				contentStr += contentItem.Text
+			}
+		}
+		return contentStr
 	}
 
 	return ""
@@ -508,6 +541,12 @@ func (m *Message) ParseContent() []MediaContent {
 		return contentList
 	}
 
+	// 进程内转换产生的内容数组，逐字段复制消息时 parsedContent 不会跟随
+	if mediaContents, ok := m.Content.([]MediaContent); ok {
+		m.parsedContent = mediaContents
+		return mediaContents
+	}
+
 	// 尝试解析为数组
 	//var arrayContent []map[string]interface{}
 
@@ -8,6 +8,9 @@ const (
 	RealtimeEventTypeConversationCreate = "conversation.item.create"
 	RealtimeEventTypeResponseCreate     = "response.create"
 	RealtimeEventInputAudioBufferAppend = "input_audio_buffer.append"
+	RealtimeEventInputAudioBufferCommit = "input_audio_buffer.commit"
+	RealtimeEventInputAudioBufferClear  = "input_audio_buffer.clear"
+	RealtimeEventTypeResponseCancel     = "response.cancel"
 )
 
 const (
@@ -19,6 +22,19 @@ const (
 	RealtimeEventResponseFunctionCallArgumentsDelta = "response.function_call_arguments.delta"
 	RealtimeEventResponseFunctionCallArgumentsDone  = "response.function_call_arguments.done"
 	RealtimeEventConversationItemCreated            = "conversation.item.created"
+
+	RealtimeEventInputAudioBufferSpeechStarted   = "input_audio_buffer.speech_started"
+	RealtimeEventInputAudioBufferSpeechStopped   = "input_audio_buffer.speech_stopped"
+	RealtimeEventInputAudioBufferCommitted       = "input_audio_buffer.committed"
+	RealtimeEventInputAudioBufferCleared         = "input_audio_buffer.cleared"
+	RealtimeEventInputAudioTranscriptionComplete = "conversation.item.input_audio_transcription.completed"
+	RealtimeEventResponseCreated                 = "response.created"
+	RealtimeEventResponseOutputItemAdded         = "response.output_item.added"
+	RealtimeEventResponseOutputItemDone          = "response.output_item.done"
+	RealtimeEventResponseTextDelta               = "response.text.delta"
+	RealtimeEventResponseTextDone                = "response.text.done"
+	RealtimeEventResponseAudioDone               = "response.audio.done"
+	RealtimeEventResponseAudioTranscriptionDone  = "response.audio_transcript.done"
 )
 
 type RealtimeEvent struct {
@@ -31,10 +47,21 @@ type RealtimeEvent struct {
 	Response *RealtimeResponse  `json:"response,omitempty"`
 	Delta    string             `json:"delta,omitempty"`
 	Audio    string             `json:"audio,omitempty"`
+
+	ItemId       string `json:"item_id,omitempty"`
+	ResponseId   string `json:"response_id,omitempty"`
+	Transcript   string `json:"transcript,omitempty"`
+	Text         string `json:"text,omitempty"`
+	AudioStartMs *int   `json:"audio_start_ms,omitempty"`
+	AudioEndMs   *int   `json:"audio_end_ms,omitempty"`
 }
 
 type RealtimeResponse struct {
-	Usage *RealtimeUsage `json:"usage"`
+	Id     string         `json:"id,omitempty"`
+	Object string         `json:"object,omitempty"`
+	Status string         `json:"status,omitempty"`
+	Output []RealtimeItem `json:"output,omitempty"`
+	Usage  *RealtimeUsage `json:"usage"`
 }
 
 type RealtimeUsage struct {
@@ -0,0 +1,33 @@
+package dto
+
+import "strings"
+
+// ReasoningOutputMode selects how reasoning output is returned to Chat Completions clients.
+// It can be set per token or per request (X-Reasoning-Output header).
+//
+// Supported values:
+//   - "native" : keep whatever shape the upstream conversion produced (default)
+//   - "hidden" : drop reasoning text and signatures from the response
+//   - "field"  : always return reasoning in reasoning_content, including inline <think> blocks
+//   - "inline" : return reasoning inside content wrapped in <think></think>
+type ReasoningOutputMode string
+
+const (
+	ReasoningOutputNative ReasoningOutputMode = "native"
+	ReasoningOutputHidden ReasoningOutputMode = "hidden"
+	ReasoningOutputField  ReasoningOutputMode = "field"
+	ReasoningOutputInline ReasoningOutputMode = "inline"
+)
+
+// Normalize returns the canonical mode. Empty input maps to native.
+func (mode ReasoningOutputMode) Normalize() (ReasoningOutputMode, bool) {
+	raw := strings.TrimSpace(strings.ToLower(string(mode)))
+	switch ReasoningOutputMode(raw) {
+	case "":
+		return ReasoningOutputNative, true
+	case ReasoningOutputNative, ReasoningOutputHidden, ReasoningOutputField, ReasoningOutputInline:
+		return ReasoningOutputMode(raw), true
+	default:
+		return ReasoningOutputNative, false
+	}
+}
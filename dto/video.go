@@ -0,0 +1,71 @@
+package dto
+
+import (
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	VideoStatusQueued     = "queued"
+	VideoStatusInProgress = "in_progress"
+	VideoStatusCompleted  = "completed"
+	VideoStatusFailed     = "failed"
+)
+
+// DefaultVideoSeconds is the clip length used when the request omits seconds.
+const DefaultVideoSeconds = 4
+
+// VideoRequest is the OpenAI-style POST /v1/videos body; input_reference is only
+// available as a multipart file and is forwarded from the original form.
+type VideoRequest struct {
+	Model   string   `json:"model"`
+	Prompt  string   `json:"prompt"`
+	Seconds IntValue `json:"seconds,omitempty"`
+	Size    string   `json:"size,omitempty"`
+}
+
+func (r *VideoRequest) GetSeconds() int {
+	if r.Seconds <= 0 {
+		return DefaultVideoSeconds
+	}
+	return int(r.Seconds)
+}
+
+// GetTokenCountMeta prices video by clip length: a fixed model price is treated as per-second.
+func (r *VideoRequest) GetTokenCountMeta() *types.TokenCountMeta {
+	return &types.TokenCountMeta{
+		CombineText:     r.Prompt,
+		TokenType:       types.TokenTypeTextNumber,
+		ImagePriceRatio: float64(r.GetSeconds()),
+	}
+}
+
+func (r *VideoRequest) IsStream(c *gin.Context) bool {
+	return false
+}
+
+func (r *VideoRequest) SetModelName(modelName string) {
+	if modelName != "" {
+		r.Model = modelName
+	}
+}
+
+type VideoError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// VideoObject mirrors the OpenAI video resource returned by submit and get.
+type VideoObject struct {
+	Id          string      `json:"id"`
+	Object      string      `json:"object"`
+	Model       string      `json:"model"`
+	Status      string      `json:"status"`
+	Progress    int         `json:"progress"`
+	CreatedAt   int64       `json:"created_at"`
+	CompletedAt int64       `json:"completed_at,omitempty"`
+	Seconds     string      `json:"seconds,omitempty"`
+	Size        string      `json:"size,omitempty"`
+	Error       *VideoError `json:"error,omitempty"`
+}
@@ -13,13 +13,14 @@ const (
 
 // Token related messages
 const (
-	MsgTokenNameTooLong          = "token.name_too_long"
-	MsgTokenQuotaNegative        = "token.quota_negative"
-	MsgTokenQuotaExceedMax       = "token.quota_exceed_max"
-	MsgTokenGenerateFailed       = "token.generate_failed"
-	MsgTokenGetInfoFailed        = "token.get_info_failed"
-	MsgTokenExpiredCannotEnable  = "token.expired_cannot_enable"
-	MsgTokenExhaustedCannotEable = "token.exhausted_cannot_enable"
+	MsgTokenNameTooLong            = "token.name_too_long"
+	MsgTokenQuotaNegative          = "token.quota_negative"
+	MsgTokenQuotaExceedMax         = "token.quota_exceed_max"
+	MsgTokenGenerateFailed         = "token.generate_failed"
+	MsgTokenGetInfoFailed          = "token.get_info_failed"
+	MsgTokenExpiredCannotEnable    = "token.expired_cannot_enable"
+	MsgTokenExhaustedCannotEable   = "token.exhausted_cannot_enable"
+	MsgTokenReasoningOutputInvalid = "token.reasoning_output_invalid"
 )
 
 // Redemption related messages
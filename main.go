@@ -22,6 +22,7 @@ import (
 	"github.com/zhongruan0522/new-api/logger"
 	"github.com/zhongruan0522/new-api/middleware"
 	"github.com/zhongruan0522/new-api/model"
+	"github.com/zhongruan0522/new-api/relay"
 	"github.com/zhongruan0522/new-api/router"
 	"github.com/zhongruan0522/new-api/service"
 	_ "github.com/zhongruan0522/new-api/setting/performance_setting"
@@ -93,6 +94,8 @@ func main() {
 
 	// 设置获取 relay 并发数的函数指针
 	common.GetActiveConnectionsFunc = middleware.GetActiveConnectionCount
+	// 实时桥接的 STT / chat / TTS 阶段需要按模型选择渠道
+	relay.RealtimeStageInvoker = controller.InvokeRealtimeStage
 
 	// 热更新配置
 	go model.SyncOptions(common.SyncFrequency)
@@ -100,6 +103,26 @@ func main() {
 	// 数据看板
 	go model.UpdateQuotaData()
 
+	// 邀请返佣解冻
+	if common.IsMasterNode {
+		go model.RunReferralCommissionSettler()
+	}
+
+	// 将数据库中已有的图片/视频迁移到外部存储
+	if common.IsMasterNode {
+		go model.MigrateStoredBlobs()
+	}
+
+	// 清理过期的 Responses 存储
+	if common.IsMasterNode {
+		go service.RunStoredResponseCleaner()
+	}
+
+	// 工单 SLA 超时提醒
+	if common.IsMasterNode {
+		go service.RunTicketSlaChecker()
+	}
+
 	if os.Getenv("CHANNEL_UPDATE_FREQUENCY") != "" {
 		frequency, err := strconv.Atoi(os.Getenv("CHANNEL_UPDATE_FREQUENCY"))
 		if err != nil {
@@ -110,6 +133,14 @@ func main() {
 
 	go controller.AutomaticallyTestChannels()
 
+	// 异步任务（视频生成）轮询
+	go controller.RunTaskPoller()
+
+	go controller.AutomaticallyUpdateChannelBalances()
+
+	// 自部署渠道负载指标抓取，用于跳过已饱和的后端
+	go controller.AutomaticallyRefreshSelfHostedMetrics()
+
 	if os.Getenv("BATCH_UPDATE_ENABLED") == "true" {
 		common.BatchUpdateEnabled = true
 		common.SysLog("batch update enabled with interval " + strconv.Itoa(common.BatchUpdateInterval) + "s")
@@ -251,6 +282,12 @@ func InitResources() error {
 
 	model.CheckSetup()
 
+	// 初始化媒体外部存储（BLOB_STORE_TYPE）
+	err = model.InitStoredBlobStore()
+	if err != nil {
+		return err
+	}
+
 	// Initialize options, should after model.InitDB()
 	model.InitOptionMap()
 
@@ -53,24 +53,9 @@ func Distribute() func(c *gin.Context) {
 		} else {
 			// Select a channel for the user
 			// check token model mapping
-			modelLimitEnable := common.GetContextKeyBool(c, constant.ContextKeyTokenModelLimitEnabled)
-			if modelLimitEnable {
-				s, ok := common.GetContextKey(c, constant.ContextKeyTokenModelLimit)
-				if !ok {
-					// token model limit is empty, all models are not allowed
-					abortWithOpenAiMessage(c, http.StatusForbidden, "该令牌无权访问任何模型")
-					return
-				}
-				var tokenModelLimit map[string]bool
-				tokenModelLimit, ok = s.(map[string]bool)
-				if !ok {
-					tokenModelLimit = map[string]bool{}
-				}
-				matchName := ratio_setting.FormatMatchingModelName(modelRequest.Model) // match gpts & thinking-*
-				if _, ok := tokenModelLimit[matchName]; !ok {
-					abortWithOpenAiMessage(c, http.StatusForbidden, "该令牌无权访问模型 "+modelRequest.Model)
-					return
-				}
+			if err := CheckTokenModelLimit(c, modelRequest.Model); err != nil {
+				abortWithOpenAiMessage(c, http.StatusForbidden, err.Error())
+				return
 			}
 
 			if shouldSelectChannel {
@@ -100,9 +85,24 @@ func Distribute() func(c *gin.Context) {
 					}
 				}
 
+				// 虚拟模型：按目标顺序选出首个有可用渠道的目标，剩余目标留给 relay 在失败时回退
+				if targets, ok := service.ResolveVirtualModelTargets(modelRequest.Model); ok {
+					// 令牌限制了可用模型时，每个目标都需单独校验，不能因虚拟模型被允许而放行
+					targets = slices.DeleteFunc(targets, func(target string) bool {
+						return CheckTokenModelLimit(c, target) != nil
+					})
+					if len(targets) == 0 {
+						abortWithOpenAiMessage(c, http.StatusForbidden, "该令牌无权访问虚拟模型 "+modelRequest.Model+" 的任何目标模型")
+						return
+					}
+					target, fallbacks := service.SelectVirtualModelTarget(c, targets, usingGroup)
+					service.SetVirtualModelContext(c, modelRequest.Model, fallbacks)
+					modelRequest.Model = target
+				}
+
 				if preferredChannelID, found := service.GetPreferredChannelByAffinity(c, modelRequest.Model, usingGroup); found {
 					preferred, err := model.CacheGetChannel(preferredChannelID)
-					if err == nil && preferred != nil && preferred.Status == common.ChannelStatusEnabled {
+					if err == nil && preferred != nil && preferred.Status == common.ChannelStatusEnabled && !preferred.IsTrafficHeld() {
 						if usingGroup == "auto" {
 							userGroup := common.GetContextKeyString(c, constant.ContextKeyUserGroup)
 							autoGroups := service.GetUserAutoGroup(userGroup)
@@ -150,6 +150,9 @@ func Distribute() func(c *gin.Context) {
 						return
 					}
 				}
+
+				// 影子渠道：按比例把请求镜像到影子渠道，只记录对比数据，不影响本次响应
+				service.PickShadowChannels(c, relayFormat, selectGroup, modelRequest.Model)
 			}
 		}
 		common.SetContextKey(c, constant.ContextKeyRequestStartTime, time.Now())
@@ -168,6 +171,27 @@ func Distribute() func(c *gin.Context) {
 	}
 }
 
+// CheckTokenModelLimit 令牌启用模型限制时，校验是否允许访问该模型
+func CheckTokenModelLimit(c *gin.Context, modelName string) error {
+	if !common.GetContextKeyBool(c, constant.ContextKeyTokenModelLimitEnabled) {
+		return nil
+	}
+	s, ok := common.GetContextKey(c, constant.ContextKeyTokenModelLimit)
+	if !ok {
+		// token model limit is empty, all models are not allowed
+		return errors.New("该令牌无权访问任何模型")
+	}
+	tokenModelLimit, ok := s.(map[string]bool)
+	if !ok {
+		tokenModelLimit = map[string]bool{}
+	}
+	matchName := ratio_setting.FormatMatchingModelName(modelName) // match gpts & thinking-*
+	if _, ok := tokenModelLimit[matchName]; !ok {
+		return errors.New("该令牌无权访问模型 " + modelName)
+	}
+	return nil
+}
+
 // getModelFromRequest 从请求中读取模型信息
 // 根据 Content-Type 自动处理：
 // - application/json
@@ -216,7 +240,7 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 	}
 	if strings.HasPrefix(c.Request.URL.Path, "/v1/images/generations") {
 		modelRequest.Model = common.GetStringIfEmpty(modelRequest.Model, "dall-e")
-	} else if strings.HasPrefix(c.Request.URL.Path, "/v1/images/edits") {
+	} else if strings.HasPrefix(c.Request.URL.Path, "/v1/images/edits") || strings.HasPrefix(c.Request.URL.Path, "/v1/images/variations") {
 		//modelRequest.Model = common.GetStringIfEmpty(c.PostForm("model"), "gpt-image-1")
 		contentType := c.ContentType()
 		if slices.Contains([]string{gin.MIMEPOSTForm, gin.MIMEMultipartPOSTForm}, contentType) {
@@ -225,6 +249,16 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 				modelRequest.Model = req.Model
 			}
 		}
+		// OpenAI 仅 dall-e-2 支持变体接口，未指定模型时与官方保持一致
+		if strings.HasPrefix(c.Request.URL.Path, "/v1/images/variations") {
+			modelRequest.Model = common.GetStringIfEmpty(modelRequest.Model, "dall-e-2")
+		}
+	}
+	if strings.HasPrefix(c.Request.URL.Path, "/v1/videos") && modelRequest.Model == "" {
+		// 视频生成支持 multipart（携带 input_reference 参考图）
+		if req, err := getModelFromRequest(c); err == nil {
+			modelRequest.Model = req.Model
+		}
 	}
 	if strings.HasPrefix(c.Request.URL.Path, "/v1/audio") {
 		relayMode := relayconstant.RelayModeAudioSpeech
@@ -361,6 +395,8 @@ func guessRelayFormatFromPath(path string) types.RelayFormat {
 		return types.RelayFormatEmbedding
 	case strings.HasPrefix(path, "/v1/audio/"):
 		return types.RelayFormatOpenAIAudio
+	case strings.HasPrefix(path, "/v1/videos"):
+		return types.RelayFormatOpenAIVideo
 	case strings.HasPrefix(path, "/v1/rerank"):
 		return types.RelayFormatRerank
 	case strings.HasPrefix(path, "/v1/chat/completions"),
@@ -62,9 +62,9 @@ func GetAllEnableAbilities() []Ability {
 func getPriority(group string, model string, retry int) (int, error) {
 
 	var priorities []int
-	err := DB.Model(&Ability{}).
+	err := excludeTrafficHeldAbilities(DB.Model(&Ability{}).
 		Select("DISTINCT(priority)").
-		Where(commonGroupCol+" = ? and model = ? and enabled = ?", group, model, true).
+		Where(commonGroupCol+" = ? and model = ? and enabled = ?", group, model, true)).
 		Order("priority DESC").              // 按优先级降序排序
 		Pluck("priority", &priorities).Error // Pluck用于将查询的结果直接扫描到一个切片中
 
@@ -92,15 +92,16 @@ func getPriority(group string, model string, retry int) (int, error) {
 // getPriorityCountDB returns the number of distinct priority levels for a group/model pair from DB.
 func getPriorityCountDB(group string, model string) int {
 	var count int64
-	DB.Model(&Ability{}).
-		Where(commonGroupCol+" = ? and model = ? and enabled = ?", group, model, true).
+	excludeTrafficHeldAbilities(DB.Model(&Ability{}).
+		Where(commonGroupCol+" = ? and model = ? and enabled = ?", group, model, true)).
 		Distinct("priority").
 		Count(&count)
 	return int(count)
 }
 
 func getChannelQuery(group string, model string, priorityIndex int, excludeChannelId int) (*gorm.DB, error) {
-	maxPrioritySubQuery := DB.Model(&Ability{}).Select("MAX(priority)").Where(commonGroupCol+" = ? and model = ? and enabled = ?", group, model, true)
+	// 灰度/影子渠道不参与常规选择
+	maxPrioritySubQuery := excludeTrafficHeldAbilities(DB.Model(&Ability{}).Select("MAX(priority)").Where(commonGroupCol+" = ? and model = ? and enabled = ?", group, model, true))
 	channelQuery := DB.Where(commonGroupCol+" = ? and model = ? and enabled = ? and priority = (?)", group, model, true, maxPrioritySubQuery)
 	if priorityIndex != 0 {
 		priority, err := getPriority(group, model, priorityIndex)
@@ -109,6 +110,7 @@ func getChannelQuery(group string, model string, priorityIndex int, excludeChann
 		}
 		channelQuery = DB.Where(commonGroupCol+" = ? and model = ? and enabled = ? and priority = ?", group, model, true, priority)
 	}
+	channelQuery = excludeTrafficHeldAbilities(channelQuery)
 	if excludeChannelId > 0 {
 		channelQuery = channelQuery.Where("channel_id != ?", excludeChannelId)
 	}
@@ -135,6 +137,7 @@ func GetChannelWithRelayFormat(group string, model string, priorityIndex int, pr
 	if err != nil {
 		return nil, err
 	}
+	abilities = excludeSaturatedAbilities(abilities)
 	channel := Channel{}
 	if len(abilities) > 0 {
 		// Prefer explicit OpenAI wire settings before the broader API-type preference.
@@ -0,0 +1,128 @@
+package model
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/zhongruan0522/new-api/common"
+	"gorm.io/gorm"
+)
+
+// CacheUsageData 按小时、渠道、模型与亲和规则聚合的提示词缓存用量；费用为额度单位，仅含输入部分
+type CacheUsageData struct {
+	Id               int    `json:"id"`
+	CreatedAt        int64  `json:"created_at" gorm:"bigint;index:idx_cud_created_channel,priority:1"`
+	ChannelId        int    `json:"channel_id" gorm:"index:idx_cud_created_channel,priority:2"`
+	ModelName        string `json:"model_name" gorm:"size:64;default:''"`
+	RuleName         string `json:"rule_name" gorm:"size:64;default:''"` // 命中的渠道亲和规则，未命中为空
+	Requests         int    `json:"requests" gorm:"default:0"`
+	CacheHitRequests int    `json:"cache_hit_requests" gorm:"default:0"`
+	UncachedTokens   int64  `json:"uncached_tokens" gorm:"default:0"`
+	CacheReadTokens  int64  `json:"cache_read_tokens" gorm:"default:0"`
+	CacheWriteTokens int64  `json:"cache_write_tokens" gorm:"default:0"`
+	// NoCacheCost 同样的输入全部按原价计费时的费用，用于计算节省额
+	NoCacheCost    float64 `json:"no_cache_cost" gorm:"default:0"`
+	UncachedCost   float64 `json:"uncached_cost" gorm:"default:0"`
+	CacheReadCost  float64 `json:"cache_read_cost" gorm:"default:0"`
+	CacheWriteCost float64 `json:"cache_write_cost" gorm:"default:0"`
+	// 亲和渠道存在时的落点统计：保持在亲和渠道 / 重试切走 / 亲和渠道不可用而改选
+	AffinityKeptRequests   int     `json:"affinity_kept_requests" gorm:"default:0"`
+	RetryBrokenRequests    int     `json:"retry_broken_requests" gorm:"default:0"`
+	FailoverBrokenRequests int     `json:"failover_broken_requests" gorm:"default:0"`
+	RetryLostTokens        int64   `json:"retry_lost_tokens" gorm:"default:0"`
+	FailoverLostTokens     int64   `json:"failover_lost_tokens" gorm:"default:0"`
+	RetryLostCost          float64 `json:"retry_lost_cost" gorm:"default:0"`
+	FailoverLostCost       float64 `json:"failover_lost_cost" gorm:"default:0"`
+}
+
+var cacheUsageDataCache = make(map[string]*CacheUsageData)
+var cacheUsageDataLock = sync.Mutex{}
+
+func (d *CacheUsageData) add(other *CacheUsageData) {
+	d.Requests += other.Requests
+	d.CacheHitRequests += other.CacheHitRequests
+	d.UncachedTokens += other.UncachedTokens
+	d.CacheReadTokens += other.CacheReadTokens
+	d.CacheWriteTokens += other.CacheWriteTokens
+	d.NoCacheCost += other.NoCacheCost
+	d.UncachedCost += other.UncachedCost
+	d.CacheReadCost += other.CacheReadCost
+	d.CacheWriteCost += other.CacheWriteCost
+	d.AffinityKeptRequests += other.AffinityKeptRequests
+	d.RetryBrokenRequests += other.RetryBrokenRequests
+	d.FailoverBrokenRequests += other.FailoverBrokenRequests
+	d.RetryLostTokens += other.RetryLostTokens
+	d.FailoverLostTokens += other.FailoverLostTokens
+	d.RetryLostCost += other.RetryLostCost
+	d.FailoverLostCost += other.FailoverLostCost
+}
+
+// LogCacheUsageData 记录一次请求的缓存用量到内存缓存，随数据看板一起定期落库
+func LogCacheUsageData(data *CacheUsageData, createdAt int64) {
+	data.CreatedAt = createdAt - (createdAt % 3600)
+	key := fmt.Sprintf("%d-%d-%s-%s", data.CreatedAt, data.ChannelId, data.ModelName, data.RuleName)
+
+	cacheUsageDataLock.Lock()
+	defer cacheUsageDataLock.Unlock()
+	if cached, ok := cacheUsageDataCache[key]; ok {
+		cached.add(data)
+		return
+	}
+	cacheUsageDataCache[key] = data
+}
+
+func SaveCacheUsageDataCache() {
+	cacheUsageDataLock.Lock()
+	pending := cacheUsageDataCache
+	cacheUsageDataCache = make(map[string]*CacheUsageData)
+	cacheUsageDataLock.Unlock()
+
+	for _, data := range pending {
+		query := DB.Model(&CacheUsageData{}).Where("created_at = ? and channel_id = ? and model_name = ? and rule_name = ?",
+			data.CreatedAt, data.ChannelId, data.ModelName, data.RuleName)
+		result := query.Updates(map[string]interface{}{
+			"requests":                 gorm.Expr("requests + ?", data.Requests),
+			"cache_hit_requests":       gorm.Expr("cache_hit_requests + ?", data.CacheHitRequests),
+			"uncached_tokens":          gorm.Expr("uncached_tokens + ?", data.UncachedTokens),
+			"cache_read_tokens":        gorm.Expr("cache_read_tokens + ?", data.CacheReadTokens),
+			"cache_write_tokens":       gorm.Expr("cache_write_tokens + ?", data.CacheWriteTokens),
+			"no_cache_cost":            gorm.Expr("no_cache_cost + ?", data.NoCacheCost),
+			"uncached_cost":            gorm.Expr("uncached_cost + ?", data.UncachedCost),
+			"cache_read_cost":          gorm.Expr("cache_read_cost + ?", data.CacheReadCost),
+			"cache_write_cost":         gorm.Expr("cache_write_cost + ?", data.CacheWriteCost),
+			"affinity_kept_requests":   gorm.Expr("affinity_kept_requests + ?", data.AffinityKeptRequests),
+			"retry_broken_requests":    gorm.Expr("retry_broken_requests + ?", data.RetryBrokenRequests),
+			"failover_broken_requests": gorm.Expr("failover_broken_requests + ?", data.FailoverBrokenRequests),
+			"retry_lost_tokens":        gorm.Expr("retry_lost_tokens + ?", data.RetryLostTokens),
+			"failover_lost_tokens":     gorm.Expr("failover_lost_tokens + ?", data.FailoverLostTokens),
+			"retry_lost_cost":          gorm.Expr("retry_lost_cost + ?", data.RetryLostCost),
+			"failover_lost_cost":       gorm.Expr("failover_lost_cost + ?", data.FailoverLostCost),
+		})
+		if result.Error != nil {
+			common.SysLog(fmt.Sprintf("failed to update cache usage data: %s", result.Error))
+			continue
+		}
+		if result.RowsAffected == 0 {
+			if err := DB.Create(data).Error; err != nil {
+				common.SysLog(fmt.Sprintf("failed to create cache usage data: %s", err))
+			}
+		}
+	}
+	if len(pending) > 0 {
+		common.SysLog(fmt.Sprintf("保存缓存用量数据成功，共保存%d条数据", len(pending)))
+	}
+}
+
+// GetCacheUsageData 查询时间范围内的缓存用量，channelId 为 0 或 modelName 为空时不过滤
+func GetCacheUsageData(startTime int64, endTime int64, channelId int, modelName string) ([]*CacheUsageData, error) {
+	var data []*CacheUsageData
+	tx := DB.Where("created_at >= ? and created_at <= ?", startTime, endTime)
+	if channelId > 0 {
+		tx = tx.Where("channel_id = ?", channelId)
+	}
+	if modelName != "" {
+		tx = tx.Where("model_name = ?", modelName)
+	}
+	err := tx.Find(&data).Error
+	return data, err
+}
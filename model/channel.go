@@ -12,6 +12,7 @@ import (
 	"github.com/zhongruan0522/new-api/common"
 	"github.com/zhongruan0522/new-api/constant"
 	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/pkg/scripthook"
 	"github.com/zhongruan0522/new-api/types"
 
 	"github.com/samber/lo"
@@ -414,7 +415,11 @@ func BatchInsertChannels(channels []Channel) error {
 			}
 		}
 	}
-	return tx.Commit().Error
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	InvalidateTrafficHeldChannelIds()
+	return nil
 }
 
 func BatchDeleteChannels(ids []int) error {
@@ -485,6 +490,7 @@ func (channel *Channel) Insert() error {
 		return err
 	}
 	err = channel.AddAbilities(nil)
+	InvalidateTrafficHeldChannelIds()
 	return err
 }
 
@@ -534,6 +540,7 @@ func (channel *Channel) Update() error {
 	}
 	DB.Model(channel).First(channel, "id = ?", channel.Id)
 	err = channel.UpdateAbilities(nil)
+	InvalidateTrafficHeldChannelIds()
 	return err
 }
 
@@ -554,7 +561,38 @@ func (channel *Channel) UpdateBalance(balance float64) {
 	}).Error
 	if err != nil {
 		common.SysLog(fmt.Sprintf("failed to update balance: channel_id=%d, error=%v", channel.Id, err))
+		return
+	}
+	RecordChannelBalanceHistory(channel.Id, balance)
+}
+
+// UpdateChannelPriority 更新渠道及其 abilities 的优先级，并刷新内存缓存
+func UpdateChannelPriority(channelId int, priority int64) error {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Channel{}).Where("id = ?", channelId).Update("priority", priority).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Ability{}).Where("channel_id = ?", channelId).Update("priority", priority).Error
+	})
+	if err != nil {
+		return err
+	}
+	if common.MemoryCacheEnabled {
+		InitChannelCache()
+	}
+	return nil
+}
+
+// UpdateChannelOtherInfo 读取最新的 other_info 并通过 mutate 修改后写回，只更新 other_info 字段
+func UpdateChannelOtherInfo(channelId int, mutate func(info map[string]interface{})) error {
+	channel, err := GetChannelById(channelId, false)
+	if err != nil {
+		return err
 	}
+	info := channel.GetOtherInfo()
+	mutate(info)
+	channel.SetOtherInfo(info)
+	return DB.Model(&Channel{}).Where("id = ?", channelId).Update("other_info", channel.OtherInfo).Error
 }
 
 func (channel *Channel) Delete() error {
@@ -918,6 +956,38 @@ func (channel *Channel) ValidateSettings() error {
 		if _, ok := otherSettings.ParseImageAutoConvertToURLMode(); !ok {
 			return fmt.Errorf("invalid image_auto_convert_to_url_mode")
 		}
+		if strings.TrimSpace(otherSettings.TransformScript) != "" {
+			if _, err := scripthook.Compile(otherSettings.TransformScript); err != nil {
+				return fmt.Errorf("transform_script 编译失败：%w", err)
+			}
+		}
+		if _, ok := otherSettings.TrafficMode.Normalize(); !ok {
+			return fmt.Errorf("invalid traffic_mode")
+		}
+		if _, ok := otherSettings.StructuredOutputEmulation.Normalize(); !ok {
+			return fmt.Errorf("invalid structured_output_emulation")
+		}
+		if _, ok := otherSettings.ToolCallEmulation.Normalize(); !ok {
+			return fmt.Errorf("invalid tool_call_emulation")
+		}
+		if bridge := otherSettings.RealtimeBridge; bridge != nil {
+			mode, ok := bridge.Mode.Normalize()
+			if !ok {
+				return fmt.Errorf("invalid realtime_bridge.mode")
+			}
+			if mode == dto.RealtimeBridgeModePipeline && (strings.TrimSpace(bridge.STTModel) == "" ||
+				strings.TrimSpace(bridge.ChatModel) == "" || strings.TrimSpace(bridge.TTSModel) == "") {
+				return fmt.Errorf("realtime_bridge 的 pipeline 模式需要同时配置 stt_model、chat_model 和 tts_model")
+			}
+		}
+		if otherSettings.TrafficPercent < 0 || otherSettings.TrafficPercent > 100 {
+			return fmt.Errorf("traffic_percent 必须在 0 到 100 之间")
+		}
+		for k, v := range otherSettings.ResponseHeaderOverride {
+			if _, ok := v.(string); !ok && v != nil {
+				return fmt.Errorf("response_header_override 的值必须是字符串或 null：%s", k)
+			}
+		}
 
 		var rawMap map[string]json.RawMessage
 		if err := common.Unmarshal([]byte(channel.OtherSettings), &rawMap); err == nil {
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/zhongruan0522/new-api/common"
+)
+
+// maxChannelBalanceHistoryPoints 单次查询返回的最大余额记录数，避免长时间范围查询拖慢接口
+const maxChannelBalanceHistoryPoints = 5000
+
+// ChannelBalanceHistory 渠道余额快照，每次查询上游余额后记录一条，用于计算消耗速度
+type ChannelBalanceHistory struct {
+	Id        int     `json:"id"`
+	ChannelId int     `json:"channel_id" gorm:"index:idx_channel_balance_history_channel_time,priority:1"`
+	Balance   float64 `json:"balance"` // in USD
+	CreatedAt int64   `json:"created_at" gorm:"bigint;index;index:idx_channel_balance_history_channel_time,priority:2"`
+}
+
+func RecordChannelBalanceHistory(channelId int, balance float64) {
+	history := &ChannelBalanceHistory{
+		ChannelId: channelId,
+		Balance:   balance,
+		CreatedAt: common.GetTimestamp(),
+	}
+	if err := DB.Create(history).Error; err != nil {
+		common.SysLog(fmt.Sprintf("failed to record balance history: channel_id=%d, error=%v", channelId, err))
+	}
+}
+
+// GetChannelBalanceHistory 按时间升序返回渠道在 since 之后的余额记录
+func GetChannelBalanceHistory(channelId int, since int64) ([]*ChannelBalanceHistory, error) {
+	var histories []*ChannelBalanceHistory
+	err := DB.Where("channel_id = ? AND created_at >= ?", channelId, since).
+		Order("created_at DESC").Limit(maxChannelBalanceHistoryPoints).Find(&histories).Error
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(histories)-1; i < j; i, j = i+1, j-1 {
+		histories[i], histories[j] = histories[j], histories[i]
+	}
+	return histories, nil
+}
+
+func DeleteChannelBalanceHistoryBefore(timestamp int64) (int64, error) {
+	result := DB.Where("created_at < ?", timestamp).Delete(&ChannelBalanceHistory{})
+	return result.RowsAffected, result.Error
+}
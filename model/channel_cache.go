@@ -116,6 +116,11 @@ func GetRandomSatisfiedChannelWithRelayFormat(group string, model string, priori
 		channels = group2model2channels[group][normalizedModel]
 	}
 
+	// 灰度/影子渠道不参与常规选择
+	channels = excludeTrafficHeldChannelIds(channels)
+	// 负载已满的自部署渠道暂时跳过
+	channels = excludeSaturatedChannelIds(channels)
+
 	if len(channels) == 0 {
 		return nil, nil
 	}
@@ -256,6 +261,7 @@ func GetPriorityCount(group string, model string) int {
 		normalizedModel := ratio_setting.FormatMatchingModelName(model)
 		channels = group2model2channels[group][normalizedModel]
 	}
+	channels = excludeTrafficHeldChannelIds(channels)
 
 	uniquePriorities := make(map[int]bool)
 	for _, channelId := range channels {
@@ -349,3 +349,42 @@ func TestGetRandomSatisfiedChannelWithRelayFormatPrefersExplicitOpenAIWireSettin
 		t.Fatalf("selected channel %d (%s), want explicit chat-only channel %d; openai channel was %d", selected.Id, selected.Name, zhipuChatOnly.Id, openAIChannel.Id)
 	}
 }
+
+func TestGetRandomSatisfiedChannelSkipsSaturatedSelfHostedChannel(t *testing.T) {
+	setupChannelCacheTestDB(t)
+
+	const modelName = "qwen2.5-7b"
+	saturated := createChannelCacheTestChannel(t, Channel{
+		Name:   "vllm-saturated",
+		Type:   constant.ChannelTypeSelfHosted,
+		Models: modelName,
+	})
+	idle := createChannelCacheTestChannel(t, Channel{
+		Name:   "vllm-idle",
+		Type:   constant.ChannelTypeSelfHosted,
+		Models: modelName,
+	})
+	t.Cleanup(func() {
+		RetainChannelSaturation(nil)
+	})
+
+	InitChannelCache()
+	SetChannelSaturated(saturated.Id, true)
+
+	for i := 0; i < 20; i++ {
+		selected, err := GetRandomSatisfiedChannel("Coding", modelName, 0, -1, 0)
+		if err != nil || selected == nil {
+			t.Fatalf("channel selection failed: %v", err)
+		}
+		if selected.Id != idle.Id {
+			t.Fatalf("selected saturated channel %d, want %d", selected.Id, idle.Id)
+		}
+	}
+
+	// 全部饱和时仍然返回渠道，不让请求直接失败
+	SetChannelSaturated(idle.Id, true)
+	selected, err := GetRandomSatisfiedChannel("Coding", modelName, 0, -1, 0)
+	if err != nil || selected == nil {
+		t.Fatalf("selection with all channels saturated returned %v, %v", selected, err)
+	}
+}
@@ -8,6 +8,11 @@ import (
 	"github.com/zhongruan0522/new-api/types"
 )
 
+// PreferChannelsByRequestFormat 返回与请求格式匹配的渠道，没有匹配的渠道时原样返回，与常规选渠道的格式偏好一致
+func PreferChannelsByRequestFormat(channels []*Channel, preferredAPIType int, relayFormat types.RelayFormat) []*Channel {
+	return preferChannelsByRequestFormat(channels, preferredAPIType, relayFormat)
+}
+
 func preferChannelsByRequestFormat(channels []*Channel, preferredAPIType int, relayFormat types.RelayFormat) []*Channel {
 	if preferredAPIType < 0 && !isOpenAIWireRelayFormat(relayFormat) {
 		return channels
@@ -0,0 +1,89 @@
+package model
+
+import (
+	"sync"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/constant"
+)
+
+// 自部署渠道的饱和状态由各节点定时抓取后端指标得到，仅保存在内存中
+var (
+	channelSaturationLock sync.RWMutex
+	saturatedChannels     = make(map[int]bool)
+)
+
+func SetChannelSaturated(channelId int, saturated bool) {
+	channelSaturationLock.Lock()
+	defer channelSaturationLock.Unlock()
+	if saturated {
+		saturatedChannels[channelId] = true
+	} else {
+		delete(saturatedChannels, channelId)
+	}
+}
+
+func IsChannelSaturated(channelId int) bool {
+	channelSaturationLock.RLock()
+	defer channelSaturationLock.RUnlock()
+	return saturatedChannels[channelId]
+}
+
+// RetainChannelSaturation 只保留仍在监控中的渠道，清理已删除或已禁用渠道的残留状态
+func RetainChannelSaturation(channelIds []int) {
+	keep := make(map[int]bool, len(channelIds))
+	for _, id := range channelIds {
+		keep[id] = true
+	}
+	channelSaturationLock.Lock()
+	defer channelSaturationLock.Unlock()
+	for id := range saturatedChannels {
+		if !keep[id] {
+			delete(saturatedChannels, id)
+		}
+	}
+}
+
+// excludeSaturatedChannelIds 过滤掉已饱和的自部署渠道；全部饱和时保留原列表，避免请求直接失败
+func excludeSaturatedChannelIds(channelIds []int) []int {
+	channelSaturationLock.RLock()
+	defer channelSaturationLock.RUnlock()
+	if len(saturatedChannels) == 0 {
+		return channelIds
+	}
+	filtered := make([]int, 0, len(channelIds))
+	for _, id := range channelIds {
+		if !saturatedChannels[id] {
+			filtered = append(filtered, id)
+		}
+	}
+	if len(filtered) == 0 {
+		return channelIds
+	}
+	return filtered
+}
+
+func excludeSaturatedAbilities(abilities []Ability) []Ability {
+	channelSaturationLock.RLock()
+	defer channelSaturationLock.RUnlock()
+	if len(saturatedChannels) == 0 {
+		return abilities
+	}
+	filtered := make([]Ability, 0, len(abilities))
+	for _, ability := range abilities {
+		if !saturatedChannels[ability.ChannelId] {
+			filtered = append(filtered, ability)
+		}
+	}
+	if len(filtered) == 0 {
+		return abilities
+	}
+	return filtered
+}
+
+// GetEnabledSelfHostedChannels 返回需要抓取负载指标的自部署渠道（包含密钥）
+func GetEnabledSelfHostedChannels() ([]*Channel, error) {
+	var channels []*Channel
+	err := DB.Where("type = ? AND status = ?", constant.ChannelTypeSelfHosted, common.ChannelStatusEnabled).Find(&channels).Error
+	return channels, err
+}
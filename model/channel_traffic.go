@@ -0,0 +1,200 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/setting/ratio_setting"
+
+	"gorm.io/gorm"
+)
+
+// maxChannelTrafficSamples 单次报表查询读取的最大样本数
+const maxChannelTrafficSamples = 20000
+
+// trafficHeldChannelIdsTTL 未启用内存缓存时灰度/影子渠道集合的刷新间隔，渠道新增或编辑时立即失效
+const trafficHeldChannelIdsTTL = time.Minute
+
+var (
+	trafficHeldChannelIds     []int
+	trafficHeldChannelIdsAt   time.Time
+	trafficHeldChannelIdsLock sync.RWMutex
+)
+
+const (
+	ChannelTrafficRoleCandidate = "candidate" // 由灰度/影子渠道处理的请求
+	ChannelTrafficRoleBaseline  = "baseline"  // 同一批采样中由正式渠道处理的请求，用于对比
+)
+
+// ChannelTrafficSample 灰度/影子流量的一次采样记录，用于对比候选渠道与正式渠道的表现
+type ChannelTrafficSample struct {
+	Id                 int    `json:"id"`
+	CandidateChannelId int    `json:"candidate_channel_id" gorm:"index:idx_channel_traffic_candidate_time,priority:1"`
+	ChannelId          int    `json:"channel_id"` // 实际处理请求的渠道
+	Mode               string `json:"mode" gorm:"type:varchar(16)"`
+	Role               string `json:"role" gorm:"type:varchar(16)"`
+	RequestId          string `json:"request_id" gorm:"type:varchar(64)"`
+	ModelName          string `json:"model_name" gorm:"type:varchar(255)"`
+	Success            bool   `json:"success"`
+	StatusCode         int    `json:"status_code"`
+	ErrorMessage       string `json:"error_message" gorm:"type:text"`
+	LatencyMs          int64  `json:"latency_ms"`
+	PromptTokens       int    `json:"prompt_tokens"`
+	CompletionTokens   int    `json:"completion_tokens"`
+	CreatedAt          int64  `json:"created_at" gorm:"bigint;index;index:idx_channel_traffic_candidate_time,priority:2"`
+}
+
+func RecordChannelTrafficSample(sample *ChannelTrafficSample) {
+	if sample.CreatedAt == 0 {
+		sample.CreatedAt = common.GetTimestamp()
+	}
+	if err := DB.Create(sample).Error; err != nil {
+		common.SysLog(fmt.Sprintf("failed to record channel traffic sample: candidate_channel_id=%d, error=%v", sample.CandidateChannelId, err))
+	}
+}
+
+// GetChannelTrafficSamples 返回候选渠道在 since 之后的采样记录
+func GetChannelTrafficSamples(candidateChannelId int, since int64) ([]*ChannelTrafficSample, error) {
+	var samples []*ChannelTrafficSample
+	err := DB.Where("candidate_channel_id = ? AND created_at >= ?", candidateChannelId, since).
+		Order("created_at DESC").Limit(maxChannelTrafficSamples).Find(&samples).Error
+	return samples, err
+}
+
+func DeleteChannelTrafficSamples(candidateChannelId int) (int64, error) {
+	result := DB.Where("candidate_channel_id = ?", candidateChannelId).Delete(&ChannelTrafficSample{})
+	return result.RowsAffected, result.Error
+}
+
+// GetTrafficMode 返回渠道的灰度模式与流量比例，未配置时 mode 为空
+func (channel *Channel) GetTrafficMode() (dto.ChannelTrafficMode, float64) {
+	// 绝大多数渠道未配置灰度，避免在选渠道热路径上反复解析 JSON
+	if !strings.Contains(channel.OtherSettings, "traffic_mode") {
+		return "", 0
+	}
+	settings := channel.GetOtherSettings()
+	mode, _ := settings.TrafficMode.Normalize()
+	return mode, settings.TrafficPercent
+}
+
+// IsTrafficHeld 灰度或影子渠道不参与常规选渠道，只通过采样获得流量
+func (channel *Channel) IsTrafficHeld() bool {
+	mode, _ := channel.GetTrafficMode()
+	return mode != ""
+}
+
+// excludeTrafficHeldChannelIds 过滤掉灰度/影子渠道，调用方需持有 channelSyncLock
+func excludeTrafficHeldChannelIds(channelIds []int) []int {
+	held := 0
+	for _, id := range channelIds {
+		if channel, ok := channelsIDM[id]; ok && channel.IsTrafficHeld() {
+			held++
+		}
+	}
+	if held == 0 {
+		return channelIds
+	}
+	filtered := make([]int, 0, len(channelIds)-held)
+	for _, id := range channelIds {
+		if channel, ok := channelsIDM[id]; ok && channel.IsTrafficHeld() {
+			continue
+		}
+		filtered = append(filtered, id)
+	}
+	return filtered
+}
+
+// getTrafficHeldChannelIdsDB 未启用内存缓存时读取灰度/影子渠道 ID，结果缓存 trafficHeldChannelIdsTTL，避免每次选渠道都扫描渠道表
+func getTrafficHeldChannelIdsDB() []int {
+	trafficHeldChannelIdsLock.RLock()
+	if time.Since(trafficHeldChannelIdsAt) < trafficHeldChannelIdsTTL {
+		ids := trafficHeldChannelIds
+		trafficHeldChannelIdsLock.RUnlock()
+		return ids
+	}
+	trafficHeldChannelIdsLock.RUnlock()
+
+	trafficHeldChannelIdsLock.Lock()
+	defer trafficHeldChannelIdsLock.Unlock()
+	// 获取写锁后再次检查，避免并发请求重复查询
+	if time.Since(trafficHeldChannelIdsAt) < trafficHeldChannelIdsTTL {
+		return trafficHeldChannelIds
+	}
+	var channels []*Channel
+	if err := DB.Select("id, settings").Where("settings LIKE ?", "%traffic_mode%").Find(&channels).Error; err != nil {
+		common.SysLog(fmt.Sprintf("failed to load traffic held channels: %v", err))
+		return trafficHeldChannelIds
+	}
+	ids := make([]int, 0, len(channels))
+	for _, channel := range channels {
+		if channel.IsTrafficHeld() {
+			ids = append(ids, channel.Id)
+		}
+	}
+	trafficHeldChannelIds = ids
+	trafficHeldChannelIdsAt = time.Now()
+	return ids
+}
+
+// InvalidateTrafficHeldChannelIds 渠道设置变更后使灰度/影子渠道集合缓存失效
+func InvalidateTrafficHeldChannelIds() {
+	trafficHeldChannelIdsLock.Lock()
+	trafficHeldChannelIdsAt = time.Time{}
+	trafficHeldChannelIdsLock.Unlock()
+}
+
+func excludeTrafficHeldAbilities(query *gorm.DB) *gorm.DB {
+	if ids := getTrafficHeldChannelIdsDB(); len(ids) > 0 {
+		return query.Where("channel_id NOT IN ?", ids)
+	}
+	return query
+}
+
+// GetTrafficChannels 返回分组下该模型处于指定灰度模式的启用渠道
+func GetTrafficChannels(group string, modelName string, mode dto.ChannelTrafficMode) []*Channel {
+	result := make([]*Channel, 0)
+	if !common.MemoryCacheEnabled {
+		var channelIds []int
+		DB.Model(&Ability{}).Where(commonGroupCol+" = ? and model = ? and enabled = ?", group, modelName, true).Pluck("channel_id", &channelIds)
+		if len(channelIds) == 0 {
+			return result
+		}
+		held := getTrafficHeldChannelIdsDB()
+		channelIds = slices.DeleteFunc(channelIds, func(id int) bool {
+			return !slices.Contains(held, id)
+		})
+		if len(channelIds) == 0 {
+			return result
+		}
+		var channels []*Channel
+		DB.Where("id IN ?", channelIds).Find(&channels)
+		for _, channel := range channels {
+			if m, _ := channel.GetTrafficMode(); m == mode {
+				result = append(result, channel)
+			}
+		}
+		return result
+	}
+
+	channelSyncLock.RLock()
+	defer channelSyncLock.RUnlock()
+	channelIds := group2model2channels[group][modelName]
+	if len(channelIds) == 0 {
+		channelIds = group2model2channels[group][ratio_setting.FormatMatchingModelName(modelName)]
+	}
+	for _, id := range channelIds {
+		channel, ok := channelsIDM[id]
+		if !ok {
+			continue
+		}
+		if m, _ := channel.GetTrafficMode(); m == mode {
+			result = append(result, channel)
+		}
+	}
+	return result
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+)
+
+func TestTrafficHeldChannelIdsAreCachedUntilInvalidated(t *testing.T) {
+	setupChannelCacheTestDB(t)
+	common.MemoryCacheEnabled = false
+	InvalidateTrafficHeldChannelIds()
+	t.Cleanup(InvalidateTrafficHeldChannelIds)
+
+	regular := createChannelCacheTestChannel(t, Channel{Name: "regular"})
+	canary := createChannelCacheTestChannel(t, Channel{Name: "canary", OtherSettings: `{"traffic_mode":"canary","traffic_percent":10}`})
+	if held := getTrafficHeldChannelIdsDB(); !slices.Equal(held, []int{canary.Id}) {
+		t.Fatalf("held channels = %v, want [%d]", held, canary.Id)
+	}
+
+	// 直接写库不会失效缓存，缓存期内不重复扫描渠道表
+	if err := DB.Model(&Channel{}).Where("id = ?", regular.Id).Update("settings", `{"traffic_mode":"shadow","traffic_percent":10}`).Error; err != nil {
+		t.Fatalf("update settings: %v", err)
+	}
+	if held := getTrafficHeldChannelIdsDB(); !slices.Equal(held, []int{canary.Id}) {
+		t.Fatalf("cached held channels = %v, want [%d]", held, canary.Id)
+	}
+
+	InvalidateTrafficHeldChannelIds()
+	held := getTrafficHeldChannelIdsDB()
+	slices.Sort(held)
+	if !slices.Equal(held, []int{regular.Id, canary.Id}) {
+		t.Fatalf("held channels after invalidation = %v", held)
+	}
+}
+
+func TestGetTrafficChannelsFromDB(t *testing.T) {
+	setupChannelCacheTestDB(t)
+	common.MemoryCacheEnabled = false
+	InvalidateTrafficHeldChannelIds()
+	t.Cleanup(InvalidateTrafficHeldChannelIds)
+
+	createChannelCacheTestChannel(t, Channel{Name: "regular"})
+	canary := createChannelCacheTestChannel(t, Channel{Name: "canary", OtherSettings: `{"traffic_mode":"canary","traffic_percent":10}`})
+	shadow := createChannelCacheTestChannel(t, Channel{Name: "shadow", OtherSettings: `{"traffic_mode":"shadow","traffic_percent":10}`})
+	createChannelCacheTestChannel(t, Channel{Name: "other-group", Group: "Other", OtherSettings: `{"traffic_mode":"canary","traffic_percent":10}`})
+
+	canaries := GetTrafficChannels("Coding", "claude-haiku-4-5-20251001", dto.ChannelTrafficModeCanary)
+	if len(canaries) != 1 || canaries[0].Id != canary.Id {
+		t.Fatalf("canary channels = %+v, want channel %d", canaries, canary.Id)
+	}
+	shadows := GetTrafficChannels("Coding", "claude-haiku-4-5-20251001", dto.ChannelTrafficModeShadow)
+	if len(shadows) != 1 || shadows[0].Id != shadow.Id {
+		t.Fatalf("shadow channels = %+v, want channel %d", shadows, shadow.Id)
+	}
+
+	// 灰度/影子渠道不参与常规选渠道
+	for i := 0; i < 10; i++ {
+		channel, err := GetRandomSatisfiedChannel("Coding", "claude-haiku-4-5-20251001", 0, -1, 0)
+		if err != nil || channel == nil {
+			t.Fatalf("GetRandomSatisfiedChannel = %v, %v", channel, err)
+		}
+		if channel.IsTrafficHeld() {
+			t.Fatalf("held channel %d selected for regular traffic", channel.Id)
+		}
+	}
+}
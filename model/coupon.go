@@ -0,0 +1,337 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/zhongruan0522/new-api/common"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+const (
+	CouponDiscountTypeNone    = ""        // 仅赠送额度，不减免支付金额
+	CouponDiscountTypePercent = "percent" // 按百分比减免，DiscountValue 取值 0-100
+	CouponDiscountTypeFixed   = "fixed"   // 固定金额减免，单位与支付金额一致
+)
+
+const (
+	CouponStatusEnabled  = 1
+	CouponStatusDisabled = 2
+)
+
+// couponReservationSeconds 待支付订单占用优惠券的最长时间，超时未支付的订单在该优惠券下次被占用时标记为过期并归还次数
+const couponReservationSeconds = 24 * 60 * 60
+
+var (
+	ErrCouponNotFound      = errors.New("优惠券不存在")
+	ErrCouponUnavailable   = errors.New("优惠券不可用")
+	ErrCouponNotStarted    = errors.New("优惠券尚未生效")
+	ErrCouponExpired       = errors.New("优惠券已过期")
+	ErrCouponExhausted     = errors.New("优惠券已被领完")
+	ErrCouponUserLimit     = errors.New("已达到该优惠券的使用次数上限")
+	ErrCouponGroupMismatch = errors.New("当前分组不可使用该优惠券")
+	ErrCouponMinAmount     = errors.New("充值数量未达到优惠券使用门槛")
+)
+
+// Coupon 充值优惠券：可减免支付金额并/或在到账时额外赠送额度
+type Coupon struct {
+	Id            int            `json:"id"`
+	Code          string         `json:"code" gorm:"type:varchar(64);uniqueIndex"`
+	Name          string         `json:"name" gorm:"type:varchar(64)"`
+	Status        int            `json:"status" gorm:"default:1"`
+	DiscountType  string         `json:"discount_type" gorm:"type:varchar(20);default:''"`
+	DiscountValue float64        `json:"discount_value"`
+	BonusQuota    int            `json:"bonus_quota" gorm:"default:0"`                       // 到账时额外赠送的额度
+	MinAmount     int64          `json:"min_amount" gorm:"default:0"`                        // 最低充值数量，0 表示不限
+	StartTime     int64          `json:"start_time" gorm:"bigint;default:0"`                 // 生效时间，0 表示立即生效
+	EndTime       int64          `json:"end_time" gorm:"bigint;default:0"`                   // 过期时间，0 表示不过期
+	PerUserLimit  int            `json:"per_user_limit" gorm:"default:1"`                    // 每个用户可使用次数，0 表示不限
+	TotalLimit    int            `json:"total_limit" gorm:"default:0"`                       // 总可使用次数，0 表示不限
+	UsedCount     int            `json:"used_count" gorm:"default:0"`                        // 已占用的使用次数：下单时占用，订单过期/失败或退款时归还
+	AllowedGroups string         `json:"allowed_groups" gorm:"type:varchar(255);default:''"` // 允许使用的分组，逗号分隔，空表示不限
+	CreatedTime   int64          `json:"created_time" gorm:"bigint"`
+	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// CouponQuote 优惠券对某笔订单的减免结果
+type CouponQuote struct {
+	CouponId      int     `json:"coupon_id"`
+	Code          string  `json:"code"`
+	DiscountMoney float64 `json:"discount_money"`
+	BonusQuota    int     `json:"bonus_quota"`
+}
+
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func (coupon *Coupon) allowsGroup(group string) bool {
+	if strings.TrimSpace(coupon.AllowedGroups) == "" {
+		return true
+	}
+	for _, allowed := range strings.Split(coupon.AllowedGroups, ",") {
+		if strings.TrimSpace(allowed) == group {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate 校验优惠券基本属性（不含用户维度的次数限制）
+func (coupon *Coupon) Validate() error {
+	if normalizeCouponCode(coupon.Code) == "" {
+		return errors.New("优惠码不能为空")
+	}
+	switch coupon.DiscountType {
+	case CouponDiscountTypeNone:
+		if coupon.BonusQuota <= 0 {
+			return errors.New("优惠券至少需要减免金额或赠送额度")
+		}
+	case CouponDiscountTypePercent:
+		if coupon.DiscountValue <= 0 || coupon.DiscountValue >= 100 {
+			return errors.New("百分比减免需在 0 到 100 之间")
+		}
+	case CouponDiscountTypeFixed:
+		if coupon.DiscountValue <= 0 {
+			return errors.New("固定减免金额必须大于 0")
+		}
+	default:
+		return errors.New("不支持的减免类型")
+	}
+	if coupon.BonusQuota < 0 || coupon.MinAmount < 0 || coupon.PerUserLimit < 0 || coupon.TotalLimit < 0 {
+		return errors.New("优惠券参数不能为负数")
+	}
+	if coupon.EndTime != 0 && coupon.StartTime != 0 && coupon.EndTime <= coupon.StartTime {
+		return errors.New("过期时间必须晚于生效时间")
+	}
+	return nil
+}
+
+// ApplyDiscount 计算减免后的支付金额，结果保留两位小数且不低于 0
+func (coupon *Coupon) ApplyDiscount(payMoney float64) (discounted float64, discountMoney float64) {
+	dPayMoney := decimal.NewFromFloat(payMoney)
+	dDiscounted := dPayMoney
+	switch coupon.DiscountType {
+	case CouponDiscountTypePercent:
+		dRate := decimal.NewFromInt(100).Sub(decimal.NewFromFloat(coupon.DiscountValue)).Div(decimal.NewFromInt(100))
+		dDiscounted = dPayMoney.Mul(dRate)
+	case CouponDiscountTypeFixed:
+		dDiscounted = dPayMoney.Sub(decimal.NewFromFloat(coupon.DiscountValue))
+	}
+	if dDiscounted.IsNegative() {
+		dDiscounted = decimal.Zero
+	}
+	dDiscounted = dDiscounted.Round(2)
+	return dDiscounted.InexactFloat64(), dPayMoney.Sub(dDiscounted).Round(2).InexactFloat64()
+}
+
+func GetCouponByCode(code string) (*Coupon, error) {
+	code = normalizeCouponCode(code)
+	if code == "" {
+		return nil, ErrCouponNotFound
+	}
+	coupon := &Coupon{}
+	if err := DB.Where("code = ?", code).First(coupon).Error; err != nil {
+		return nil, ErrCouponNotFound
+	}
+	return coupon, nil
+}
+
+// countUserCouponUsage 统计用户已成功使用或仍在占用该优惠券的订单数
+func countUserCouponUsage(tx *gorm.DB, couponId int, userId int) (int64, error) {
+	var count int64
+	err := tx.Model(&TopUp{}).
+		Where("coupon_id = ? AND user_id = ?", couponId, userId).
+		Where("status = ? OR (status = ? AND create_time >= ?)", common.TopUpStatusSuccess, common.TopUpStatusPending, common.GetTimestamp()-couponReservationSeconds).
+		Count(&count).Error
+	return count, err
+}
+
+// CheckCouponForTopUp 校验用户在当前分组、充值数量下能否使用该优惠券
+func CheckCouponForTopUp(code string, userId int, group string, amount int64) (*Coupon, error) {
+	coupon, err := GetCouponByCode(code)
+	if err != nil {
+		return nil, err
+	}
+	if coupon.Status != CouponStatusEnabled {
+		return nil, ErrCouponUnavailable
+	}
+	now := common.GetTimestamp()
+	if coupon.StartTime != 0 && now < coupon.StartTime {
+		return nil, ErrCouponNotStarted
+	}
+	if coupon.EndTime != 0 && now >= coupon.EndTime {
+		return nil, ErrCouponExpired
+	}
+	if coupon.TotalLimit > 0 && coupon.UsedCount >= coupon.TotalLimit {
+		return nil, ErrCouponExhausted
+	}
+	if !coupon.allowsGroup(group) {
+		return nil, ErrCouponGroupMismatch
+	}
+	if coupon.MinAmount > 0 && amount < coupon.MinAmount {
+		return nil, ErrCouponMinAmount
+	}
+	if coupon.PerUserLimit > 0 {
+		used, err := countUserCouponUsage(DB, coupon.Id, userId)
+		if err != nil {
+			return nil, err
+		}
+		if used >= int64(coupon.PerUserLimit) {
+			return nil, ErrCouponUserLimit
+		}
+	}
+	return coupon, nil
+}
+
+// QuoteCoupon 校验优惠券并计算减免后的支付金额；code 为空时原样返回
+func QuoteCoupon(code string, userId int, group string, amount int64, payMoney float64) (float64, *CouponQuote, error) {
+	if strings.TrimSpace(code) == "" {
+		return payMoney, nil, nil
+	}
+	coupon, err := CheckCouponForTopUp(code, userId, group, amount)
+	if err != nil {
+		return payMoney, nil, err
+	}
+	discounted, discountMoney := coupon.ApplyDiscount(payMoney)
+	return discounted, &CouponQuote{
+		CouponId:      coupon.Id,
+		Code:          coupon.Code,
+		DiscountMoney: discountMoney,
+		BonusQuota:    coupon.BonusQuota,
+	}, nil
+}
+
+// ApplyToTopUp 将优惠券使用信息记录到订单上
+func (quote *CouponQuote) ApplyToTopUp(topUp *TopUp) {
+	if quote == nil {
+		return
+	}
+	topUp.CouponId = quote.CouponId
+	topUp.CouponCode = quote.Code
+	topUp.DiscountMoney = quote.DiscountMoney
+	topUp.BonusQuota += quote.BonusQuota
+}
+
+// adjustCouponUsedCountTx 调整优惠券使用次数，用于退款或释放待支付订单的占用
+func adjustCouponUsedCountTx(tx *gorm.DB, couponId int, delta int) error {
+	if couponId == 0 {
+		return nil
+	}
+	return tx.Model(&Coupon{}).Unscoped().Where("id = ?", couponId).Update("used_count", gorm.Expr("used_count + ?", delta)).Error
+}
+
+// reserveCouponTx 下单时在事务内占用一次优惠券使用次数，需在创建订单前调用。
+// 条件更新锁住优惠券行，多笔待支付订单并发下单时总次数与单用户次数都不会超出限制
+func reserveCouponTx(tx *gorm.DB, topUp *TopUp) error {
+	if topUp.CouponId == 0 {
+		return nil
+	}
+	if err := releaseStaleCouponReservationsTx(tx, topUp.CouponId); err != nil {
+		return err
+	}
+	result := tx.Model(&Coupon{}).
+		Where("id = ? AND (total_limit = 0 OR used_count < total_limit)", topUp.CouponId).
+		Update("used_count", gorm.Expr("used_count + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCouponExhausted
+	}
+	coupon := &Coupon{}
+	if err := tx.First(coupon, "id = ?", topUp.CouponId).Error; err != nil {
+		return err
+	}
+	if coupon.PerUserLimit > 0 {
+		used, err := countUserCouponUsage(tx, coupon.Id, topUp.UserId)
+		if err != nil {
+			return err
+		}
+		if used >= int64(coupon.PerUserLimit) {
+			return ErrCouponUserLimit
+		}
+	}
+	return nil
+}
+
+// releaseStaleCouponReservationsTx 将超时未支付的待支付订单标记为过期，并归还其占用的优惠券次数
+func releaseStaleCouponReservationsTx(tx *gorm.DB, couponId int) error {
+	result := tx.Model(&TopUp{}).
+		Where("coupon_id = ? AND status = ? AND create_time < ?", couponId, common.TopUpStatusPending, common.GetTimestamp()-couponReservationSeconds).
+		Update("status", common.TopUpStatusExpired)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+	return adjustCouponUsedCountTx(tx, couponId, -int(result.RowsAffected))
+}
+
+// IsCouponLimitError 判断下单失败是否因为优惠券次数已被占满
+func IsCouponLimitError(err error) bool {
+	return errors.Is(err, ErrCouponExhausted) || errors.Is(err, ErrCouponUserLimit)
+}
+
+func (coupon *Coupon) Insert() error {
+	coupon.Code = normalizeCouponCode(coupon.Code)
+	coupon.CreatedTime = common.GetTimestamp()
+	return DB.Create(coupon).Error
+}
+
+// Update 更新优惠券可编辑字段，不修改 code 与已使用次数
+func (coupon *Coupon) Update() error {
+	return DB.Model(coupon).Select("name", "status", "discount_type", "discount_value", "bonus_quota", "min_amount",
+		"start_time", "end_time", "per_user_limit", "total_limit", "allowed_groups").Updates(coupon).Error
+}
+
+func GetCouponById(id int) (*Coupon, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	coupon := &Coupon{}
+	err := DB.First(coupon, "id = ?", id).Error
+	return coupon, err
+}
+
+func GetAllCoupons(keyword string, pageInfo *common.PageInfo) (coupons []*Coupon, total int64, err error) {
+	query := DB.Model(&Coupon{})
+	if keyword != "" {
+		pattern, perr := sanitizeLikePattern(keyword)
+		if perr != nil {
+			return nil, 0, perr
+		}
+		query = query.Where("(code LIKE ? ESCAPE '!' OR name LIKE ? ESCAPE '!')", pattern, pattern)
+	}
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Find(&coupons).Error
+	return coupons, total, err
+}
+
+func DeleteCouponById(id int) error {
+	if id == 0 {
+		return errors.New("id 为空！")
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		coupon := &Coupon{}
+		if err := tx.First(coupon, "id = ?", id).Error; err != nil {
+			return err
+		}
+		// 软删除的记录仍占用唯一索引，改写 code 以便之后重新创建同名优惠码
+		if err := tx.Model(coupon).Update("code", deletedCouponCode(coupon)).Error; err != nil {
+			return err
+		}
+		return tx.Delete(coupon).Error
+	})
+}
+
+func deletedCouponCode(coupon *Coupon) string {
+	code := fmt.Sprintf("#%d#%s", coupon.Id, coupon.Code)
+	if len(code) > 64 {
+		code = code[:64]
+	}
+	return code
+}
@@ -0,0 +1,267 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/setting/operation_setting"
+)
+
+func TestCouponApplyDiscount(t *testing.T) {
+	cases := []struct {
+		name         string
+		coupon       Coupon
+		payMoney     float64
+		wantMoney    float64
+		wantDiscount float64
+	}{
+		{"percent", Coupon{DiscountType: CouponDiscountTypePercent, DiscountValue: 20}, 50, 40, 10},
+		{"fixed", Coupon{DiscountType: CouponDiscountTypeFixed, DiscountValue: 5}, 12.5, 7.5, 5},
+		{"fixed exceeds price", Coupon{DiscountType: CouponDiscountTypeFixed, DiscountValue: 30}, 12.5, 0, 12.5},
+		{"bonus only", Coupon{DiscountType: CouponDiscountTypeNone, BonusQuota: 100}, 12.5, 12.5, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			money, discount := tc.coupon.ApplyDiscount(tc.payMoney)
+			if money != tc.wantMoney || discount != tc.wantDiscount {
+				t.Fatalf("ApplyDiscount(%v) = (%v, %v), want (%v, %v)", tc.payMoney, money, discount, tc.wantMoney, tc.wantDiscount)
+			}
+		})
+	}
+}
+
+func TestCheckCouponForTopUpRules(t *testing.T) {
+	setupPaymentProviderGuardTestDB(t)
+	if err := DB.AutoMigrate(&Coupon{}); err != nil {
+		t.Fatalf("migrate coupon: %v", err)
+	}
+	createPaymentProviderGuardUser(t, 1)
+
+	now := time.Now().Unix()
+	coupon := &Coupon{
+		Code:          "spring",
+		Status:        CouponStatusEnabled,
+		DiscountType:  CouponDiscountTypePercent,
+		DiscountValue: 10,
+		MinAmount:     5,
+		EndTime:       now + 3600,
+		PerUserLimit:  1,
+		AllowedGroups: "default,vip",
+	}
+	if err := coupon.Insert(); err != nil {
+		t.Fatalf("insert coupon: %v", err)
+	}
+
+	if _, err := CheckCouponForTopUp(" SPRING ", 1, "default", 10); err != nil {
+		t.Fatalf("CheckCouponForTopUp error = %v, want nil", err)
+	}
+	if _, err := CheckCouponForTopUp("spring", 1, "svip", 10); err != ErrCouponGroupMismatch {
+		t.Fatalf("group mismatch error = %v, want %v", err, ErrCouponGroupMismatch)
+	}
+	if _, err := CheckCouponForTopUp("spring", 1, "default", 2); err != ErrCouponMinAmount {
+		t.Fatalf("min amount error = %v, want %v", err, ErrCouponMinAmount)
+	}
+
+	topUp := &TopUp{
+		UserId:          1,
+		Amount:          10,
+		Money:           9,
+		TradeNo:         "coupon-used",
+		PaymentMethod:   "alipay",
+		PaymentProvider: PaymentProviderEpay,
+		CreateTime:      now,
+		Status:          common.TopUpStatusSuccess,
+		CouponId:        coupon.Id,
+		CouponCode:      coupon.Code,
+	}
+	if err := topUp.Insert(); err != nil {
+		t.Fatalf("insert topup: %v", err)
+	}
+	if _, err := CheckCouponForTopUp("spring", 1, "default", 10); err != ErrCouponUserLimit {
+		t.Fatalf("per user limit error = %v, want %v", err, ErrCouponUserLimit)
+	}
+}
+
+func TestCompleteEpayTopUpCreditsCouponAndTierBonus(t *testing.T) {
+	setupPaymentProviderGuardTestDB(t)
+	if err := DB.AutoMigrate(&Coupon{}, &ReferralCommission{}); err != nil {
+		t.Fatalf("migrate coupon: %v", err)
+	}
+	createPaymentProviderGuardUser(t, 1)
+
+	oldTiers := operation_setting.GetPaymentSetting().BonusTiers
+	operation_setting.GetPaymentSetting().BonusTiers = []operation_setting.TopUpBonusTier{
+		{MinAmount: 1, BonusRatio: 0.05},
+		{MinAmount: 2, BonusRatio: 0.1},
+	}
+	t.Cleanup(func() { operation_setting.GetPaymentSetting().BonusTiers = oldTiers })
+
+	coupon := &Coupon{Code: "BONUS", Status: CouponStatusEnabled, BonusQuota: 30, PerUserLimit: 1}
+	if err := coupon.Insert(); err != nil {
+		t.Fatalf("insert coupon: %v", err)
+	}
+	money, quote, err := QuoteCoupon("bonus", 1, "default", 2, 9.99)
+	if err != nil {
+		t.Fatalf("QuoteCoupon error = %v", err)
+	}
+
+	topUp := &TopUp{
+		UserId:          1,
+		Amount:          2,
+		Money:           money,
+		TradeNo:         "coupon-bonus",
+		PaymentMethod:   "alipay",
+		PaymentProvider: PaymentProviderEpay,
+		CreateTime:      time.Now().Unix(),
+		Status:          common.TopUpStatusPending,
+	}
+	quote.ApplyToTopUp(topUp)
+	topUp.ApplyBonusTier()
+	if topUp.BonusQuota != 50 {
+		t.Fatalf("bonus quota = %d, want 50", topUp.BonusQuota)
+	}
+	if err := topUp.Insert(); err != nil {
+		t.Fatalf("insert topup: %v", err)
+	}
+
+	if err := CompleteEpayTopUp("coupon-bonus", "alipay", "9.99"); err != nil {
+		t.Fatalf("CompleteEpayTopUp error = %v", err)
+	}
+	if got := paymentProviderGuardUserQuota(t, 1); got != 250 {
+		t.Fatalf("user quota = %d, want 250", got)
+	}
+	usedCoupon, _ := GetCouponById(coupon.Id)
+	if usedCoupon.UsedCount != 1 {
+		t.Fatalf("coupon used count = %d, want 1", usedCoupon.UsedCount)
+	}
+
+	if err := RefundTopUp("coupon-bonus", ""); err != nil {
+		t.Fatalf("RefundTopUp error = %v", err)
+	}
+	if got := paymentProviderGuardUserQuota(t, 1); got != 0 {
+		t.Fatalf("user quota after refund = %d, want 0", got)
+	}
+}
+
+func TestTopUpInsertReservesCouponUntilExpired(t *testing.T) {
+	setupPaymentProviderGuardTestDB(t)
+	if err := DB.AutoMigrate(&Coupon{}, &ReferralCommission{}); err != nil {
+		t.Fatalf("migrate coupon: %v", err)
+	}
+	createPaymentProviderGuardUser(t, 1)
+
+	coupon := &Coupon{Code: "ONCE", Status: CouponStatusEnabled, DiscountType: CouponDiscountTypeFixed, DiscountValue: 1, BonusQuota: 30, PerUserLimit: 1, TotalLimit: 1}
+	if err := coupon.Insert(); err != nil {
+		t.Fatalf("insert coupon: %v", err)
+	}
+	newCouponTopUp := func(tradeNo string, createTime int64) *TopUp {
+		money, quote, err := QuoteCoupon("once", 1, "default", 2, 2)
+		if err != nil {
+			t.Fatalf("QuoteCoupon error = %v", err)
+		}
+		topUp := &TopUp{
+			UserId:          1,
+			Amount:          2,
+			Money:           money,
+			TradeNo:         tradeNo,
+			PaymentMethod:   "alipay",
+			PaymentProvider: PaymentProviderEpay,
+			CreateTime:      createTime,
+			Status:          common.TopUpStatusPending,
+		}
+		quote.ApplyToTopUp(topUp)
+		return topUp
+	}
+	usedCount := func() int {
+		usedCoupon, _ := GetCouponById(coupon.Id)
+		return usedCoupon.UsedCount
+	}
+
+	// 两笔订单都在优惠券未使用时报价，模拟并发下单；第二笔在下单时即被拒绝
+	first := newCouponTopUp("coupon-once-1", time.Now().Unix())
+	second := newCouponTopUp("coupon-once-2", time.Now().Unix())
+	if err := first.Insert(); err != nil {
+		t.Fatalf("insert first topup: %v", err)
+	}
+	if err := second.Insert(); !errors.Is(err, ErrCouponExhausted) {
+		t.Fatalf("insert second topup error = %v, want ErrCouponExhausted", err)
+	}
+	if GetTopUpByTradeNo("coupon-once-2") != nil || usedCount() != 1 {
+		t.Fatalf("rejected order should not be created, used count = %d", usedCount())
+	}
+
+	// 订单过期后归还占用，其它订单可以继续使用
+	if err := UpdatePendingTopUpStatus("coupon-once-1", PaymentProviderEpay, common.TopUpStatusExpired); err != nil {
+		t.Fatalf("expire first topup: %v", err)
+	}
+	if usedCount() != 0 {
+		t.Fatalf("used count after expiry = %d, want 0", usedCount())
+	}
+	if err := second.Insert(); err != nil {
+		t.Fatalf("insert second topup after expiry: %v", err)
+	}
+	if err := CompleteEpayTopUp("coupon-once-2", "alipay", "1"); err != nil {
+		t.Fatalf("CompleteEpayTopUp error = %v", err)
+	}
+	if got := paymentProviderGuardUserQuota(t, 1); got != 230 {
+		t.Fatalf("user quota = %d, want 230", got)
+	}
+	if usedCount() != 1 {
+		t.Fatalf("used count after completion = %d, want 1", usedCount())
+	}
+}
+
+func TestTopUpInsertReleasesStaleCouponReservations(t *testing.T) {
+	setupPaymentProviderGuardTestDB(t)
+	if err := DB.AutoMigrate(&Coupon{}, &ReferralCommission{}); err != nil {
+		t.Fatalf("migrate coupon: %v", err)
+	}
+
+	coupon := &Coupon{Code: "STALE", Status: CouponStatusEnabled, BonusQuota: 30, PerUserLimit: 1, TotalLimit: 1}
+	if err := coupon.Insert(); err != nil {
+		t.Fatalf("insert coupon: %v", err)
+	}
+	stale := &TopUp{UserId: 1, Amount: 2, Money: 2, TradeNo: "coupon-stale", PaymentProvider: PaymentProviderEpay, Status: common.TopUpStatusPending,
+		CreateTime: time.Now().Unix() - couponReservationSeconds - 1, CouponId: coupon.Id, CouponCode: coupon.Code}
+	if err := stale.Insert(); err != nil {
+		t.Fatalf("insert stale topup: %v", err)
+	}
+
+	fresh := &TopUp{UserId: 2, Amount: 2, Money: 2, TradeNo: "coupon-fresh", PaymentProvider: PaymentProviderEpay, Status: common.TopUpStatusPending,
+		CreateTime: time.Now().Unix(), CouponId: coupon.Id, CouponCode: coupon.Code}
+	if err := fresh.Insert(); err != nil {
+		t.Fatalf("insert fresh topup: %v", err)
+	}
+	if got := GetTopUpByTradeNo("coupon-stale"); got == nil || got.Status != common.TopUpStatusExpired {
+		t.Fatalf("stale topup = %+v, want expired", got)
+	}
+	usedCoupon, _ := GetCouponById(coupon.Id)
+	if usedCoupon.UsedCount != 1 {
+		t.Fatalf("used count = %d, want 1", usedCoupon.UsedCount)
+	}
+}
+
+func TestDeleteCouponFreesCode(t *testing.T) {
+	setupPaymentProviderGuardTestDB(t)
+	if err := DB.AutoMigrate(&Coupon{}); err != nil {
+		t.Fatalf("migrate coupon: %v", err)
+	}
+
+	coupon := &Coupon{Code: "reuse", Status: CouponStatusEnabled, BonusQuota: 10}
+	if err := coupon.Insert(); err != nil {
+		t.Fatalf("insert coupon: %v", err)
+	}
+	if err := DeleteCouponById(coupon.Id); err != nil {
+		t.Fatalf("DeleteCouponById error = %v", err)
+	}
+	if _, err := GetCouponByCode("reuse"); err != ErrCouponNotFound {
+		t.Fatalf("GetCouponByCode after delete error = %v, want %v", err, ErrCouponNotFound)
+	}
+
+	again := &Coupon{Code: "reuse", Status: CouponStatusEnabled, BonusQuota: 10}
+	if err := again.Insert(); err != nil {
+		t.Fatalf("re-insert deleted code: %v", err)
+	}
+}
@@ -0,0 +1,222 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zhongruan0522/new-api/common"
+)
+
+const (
+	GatewayToolTypeHTTP = "http" // POST 调用参数 JSON 到 Endpoint，响应体作为工具输出
+	GatewayToolTypeMCP  = "mcp"  // 调用远程 MCP 服务（Streamable HTTP）中的工具
+)
+
+const (
+	GatewayToolStatusEnabled  = 1
+	GatewayToolStatusDisabled = 2
+)
+
+const gatewayToolCacheTTL = time.Minute
+
+var gatewayToolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// GatewayTool 管理员注册的网关托管工具，relay 会将其注入请求并在服务端执行
+type GatewayTool struct {
+	Id             int    `json:"id"`
+	Name           string `json:"name" gorm:"type:varchar(64);uniqueIndex"` // 暴露给模型的函数名
+	Description    string `json:"description" gorm:"type:text"`
+	Type           string `json:"type" gorm:"type:varchar(16);default:'http'"`
+	Endpoint       string `json:"endpoint" gorm:"type:varchar(1024)"`
+	Headers        string `json:"headers" gorm:"type:text"`             // 调用时附加的请求头，JSON 对象
+	Parameters     string `json:"parameters" gorm:"type:text"`          // 参数 JSON Schema
+	RemoteName     string `json:"remote_name" gorm:"type:varchar(128)"` // 远程 MCP 工具名，为空时与 Name 相同
+	Models         string `json:"models" gorm:"type:text"`              // 适用模型，逗号分隔，支持 * 后缀通配，空表示全部
+	AutoInject     bool   `json:"auto_inject"`                          // 是否自动注入所有适用请求；否则需通过 X-Gateway-Tools 请求头启用
+	TimeoutSeconds int    `json:"timeout_seconds" gorm:"default:30"`    // 单次调用超时
+	Status         int    `json:"status" gorm:"default:1"`
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime    int64  `json:"updated_time" gorm:"bigint"`
+}
+
+var (
+	gatewayToolCacheLock    sync.RWMutex
+	gatewayToolCache        []*GatewayTool
+	gatewayToolCacheExpires time.Time
+)
+
+// Validate 校验工具配置
+func (tool *GatewayTool) Validate() error {
+	tool.Name = strings.TrimSpace(tool.Name)
+	if !gatewayToolNamePattern.MatchString(tool.Name) {
+		return errors.New("工具名称只能包含字母、数字、下划线和短横线，且不超过 64 个字符")
+	}
+	tool.Type = strings.ToLower(strings.TrimSpace(tool.Type))
+	if tool.Type != GatewayToolTypeHTTP && tool.Type != GatewayToolTypeMCP {
+		return fmt.Errorf("不支持的工具类型：%s", tool.Type)
+	}
+	tool.Endpoint = strings.TrimSpace(tool.Endpoint)
+	u, err := url.Parse(tool.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("工具地址必须是 http(s) 链接")
+	}
+	if strings.TrimSpace(tool.Headers) != "" {
+		var headers map[string]string
+		if err := common.UnmarshalJsonStr(tool.Headers, &headers); err != nil {
+			return errors.New("请求头必须是字符串键值对的 JSON 对象")
+		}
+	}
+	if tool.Type == GatewayToolTypeHTTP && strings.TrimSpace(tool.Parameters) == "" {
+		return errors.New("HTTP 工具必须配置参数 JSON Schema")
+	}
+	if strings.TrimSpace(tool.Parameters) != "" {
+		var schema map[string]any
+		if err := common.UnmarshalJsonStr(tool.Parameters, &schema); err != nil {
+			return errors.New("参数必须是 JSON Schema 对象")
+		}
+	}
+	if tool.TimeoutSeconds <= 0 {
+		tool.TimeoutSeconds = 30
+	}
+	return nil
+}
+
+// GetHeaders 解析附加请求头
+func (tool *GatewayTool) GetHeaders() map[string]string {
+	headers := map[string]string{}
+	if strings.TrimSpace(tool.Headers) != "" {
+		_ = common.UnmarshalJsonStr(tool.Headers, &headers)
+	}
+	return headers
+}
+
+// GetParameters 解析参数 JSON Schema，未配置时返回空对象 Schema
+func (tool *GatewayTool) GetParameters() map[string]any {
+	schema := map[string]any{}
+	if strings.TrimSpace(tool.Parameters) != "" {
+		_ = common.UnmarshalJsonStr(tool.Parameters, &schema)
+	}
+	if len(schema) == 0 {
+		schema = map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+	return schema
+}
+
+// GetRemoteName 返回远程 MCP 工具名
+func (tool *GatewayTool) GetRemoteName() string {
+	if strings.TrimSpace(tool.RemoteName) != "" {
+		return strings.TrimSpace(tool.RemoteName)
+	}
+	return tool.Name
+}
+
+// MatchModel 判断工具是否适用于指定模型
+func (tool *GatewayTool) MatchModel(modelName string) bool {
+	if strings.TrimSpace(tool.Models) == "" {
+		return true
+	}
+	for _, pattern := range strings.Split(tool.Models, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(modelName, prefix) {
+				return true
+			}
+		} else if pattern == modelName {
+			return true
+		}
+	}
+	return false
+}
+
+func (tool *GatewayTool) Insert() error {
+	tool.CreatedTime = common.GetTimestamp()
+	tool.UpdatedTime = tool.CreatedTime
+	err := DB.Create(tool).Error
+	InvalidateGatewayToolCache()
+	return err
+}
+
+func (tool *GatewayTool) Update() error {
+	tool.UpdatedTime = common.GetTimestamp()
+	err := DB.Model(tool).Select("name", "description", "type", "endpoint", "headers", "parameters", "remote_name",
+		"models", "auto_inject", "timeout_seconds", "status", "updated_time").Updates(tool).Error
+	InvalidateGatewayToolCache()
+	return err
+}
+
+func GetGatewayToolById(id int) (*GatewayTool, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	tool := &GatewayTool{}
+	err := DB.First(tool, "id = ?", id).Error
+	return tool, err
+}
+
+func GetGatewayToolByName(name string) (*GatewayTool, error) {
+	tool := &GatewayTool{}
+	err := DB.First(tool, "name = ?", strings.TrimSpace(name)).Error
+	return tool, err
+}
+
+func GetAllGatewayTools(keyword string, pageInfo *common.PageInfo) (tools []*GatewayTool, total int64, err error) {
+	query := DB.Model(&GatewayTool{})
+	if keyword != "" {
+		pattern, perr := sanitizeLikePattern(keyword)
+		if perr != nil {
+			return nil, 0, perr
+		}
+		query = query.Where("(name LIKE ? ESCAPE '!' OR description LIKE ? ESCAPE '!')", pattern, pattern)
+	}
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Find(&tools).Error
+	return tools, total, err
+}
+
+func DeleteGatewayToolById(id int) error {
+	if id == 0 {
+		return errors.New("id 为空！")
+	}
+	err := DB.Delete(&GatewayTool{}, "id = ?", id).Error
+	InvalidateGatewayToolCache()
+	return err
+}
+
+// InvalidateGatewayToolCache 清除本节点的工具缓存，其他节点在缓存过期后刷新
+func InvalidateGatewayToolCache() {
+	gatewayToolCacheLock.Lock()
+	gatewayToolCache = nil
+	gatewayToolCacheExpires = time.Time{}
+	gatewayToolCacheLock.Unlock()
+}
+
+// GetEnabledGatewayTools 返回已启用的工具（带一分钟内存缓存，relay 热路径使用）
+func GetEnabledGatewayTools() ([]*GatewayTool, error) {
+	gatewayToolCacheLock.RLock()
+	if time.Now().Before(gatewayToolCacheExpires) {
+		tools := gatewayToolCache
+		gatewayToolCacheLock.RUnlock()
+		return tools, nil
+	}
+	gatewayToolCacheLock.RUnlock()
+
+	var tools []*GatewayTool
+	if err := DB.Where("status = ?", GatewayToolStatusEnabled).Order("id asc").Find(&tools).Error; err != nil {
+		return nil, err
+	}
+	gatewayToolCacheLock.Lock()
+	gatewayToolCache = tools
+	gatewayToolCacheExpires = time.Now().Add(gatewayToolCacheTTL)
+	gatewayToolCacheLock.Unlock()
+	return tools, nil
+}
@@ -0,0 +1,49 @@
+package model
+
+import "testing"
+
+func TestGatewayToolMatchModel(t *testing.T) {
+	tool := &GatewayTool{Models: "gpt-4o*, claude-3-5-sonnet"}
+	cases := map[string]bool{
+		"gpt-4o":            true,
+		"gpt-4o-mini":       true,
+		"claude-3-5-sonnet": true,
+		"claude-3-opus":     false,
+	}
+	for modelName, want := range cases {
+		if got := tool.MatchModel(modelName); got != want {
+			t.Errorf("MatchModel(%q) = %v, want %v", modelName, got, want)
+		}
+	}
+	if !(&GatewayTool{}).MatchModel("anything") {
+		t.Error("empty Models should match all models")
+	}
+}
+
+func TestGatewayToolValidate(t *testing.T) {
+	tool := &GatewayTool{
+		Name:       "weather",
+		Type:       "HTTP",
+		Endpoint:   "https://tools.example.com/weather",
+		Parameters: `{"type":"object","properties":{"city":{"type":"string"}}}`,
+	}
+	if err := tool.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if tool.Type != GatewayToolTypeHTTP || tool.TimeoutSeconds != 30 {
+		t.Errorf("Validate() did not normalize type/timeout: %q %d", tool.Type, tool.TimeoutSeconds)
+	}
+
+	invalid := []*GatewayTool{
+		{Name: "bad name", Type: "http", Endpoint: "https://x", Parameters: "{}"},
+		{Name: "t", Type: "grpc", Endpoint: "https://x"},
+		{Name: "t", Type: "http", Endpoint: "ftp://x", Parameters: "{}"},
+		{Name: "t", Type: "http", Endpoint: "https://x"},
+		{Name: "t", Type: "mcp", Endpoint: "https://x", Headers: "[1]"},
+	}
+	for i, tool := range invalid {
+		if err := tool.Validate(); err == nil {
+			t.Errorf("case %d: Validate() should fail", i)
+		}
+	}
+}
@@ -261,6 +261,8 @@ func migrateDB() error {
 		&Channel{},
 		&Ticket{},
 		&TicketEntry{},
+		&TicketAttachment{},
+		&TicketCannedReply{},
 		&Token{},
 		&User{},
 		&PasskeyCredential{},
@@ -280,6 +282,14 @@ func migrateDB() error {
 		&TwoFABackupCode{},
 		&Checkin{},
 		&DynamicRatioRule{},
+		&ReferralCommission{},
+		&Coupon{},
+		&ChannelBalanceHistory{},
+		&GatewayTool{},
+		&StoredResponse{},
+		&ChannelTrafficSample{},
+		&CacheUsageData{},
+		&Task{},
 	)
 	if err != nil {
 		return err
@@ -313,6 +323,8 @@ func migrateDBFast() error {
 		{&Channel{}, "Channel"},
 		{&Ticket{}, "Ticket"},
 		{&TicketEntry{}, "TicketEntry"},
+		{&TicketAttachment{}, "TicketAttachment"},
+		{&TicketCannedReply{}, "TicketCannedReply"},
 		{&Token{}, "Token"},
 		{&User{}, "User"},
 		{&PasskeyCredential{}, "PasskeyCredential"},
@@ -332,6 +344,14 @@ func migrateDBFast() error {
 		{&TwoFABackupCode{}, "TwoFABackupCode"},
 		{&Checkin{}, "Checkin"},
 		{&DynamicRatioRule{}, "DynamicRatioRule"},
+		{&ReferralCommission{}, "ReferralCommission"},
+		{&Coupon{}, "Coupon"},
+		{&ChannelBalanceHistory{}, "ChannelBalanceHistory"},
+		{&GatewayTool{}, "GatewayTool"},
+		{&StoredResponse{}, "StoredResponse"},
+		{&ChannelTrafficSample{}, "ChannelTrafficSample"},
+		{&CacheUsageData{}, "CacheUsageData"},
+		{&Task{}, "Task"},
 	}
 	// 动态计算migration数量，确保errChan缓冲区足够大
 	errChan := make(chan error, len(migrations))
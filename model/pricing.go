@@ -10,6 +10,7 @@ import (
 
 	"github.com/zhongruan0522/new-api/common"
 	"github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/setting/operation_setting"
 	"github.com/zhongruan0522/new-api/setting/ratio_setting"
 	"github.com/zhongruan0522/new-api/types"
 )
@@ -41,6 +42,7 @@ type Pricing struct {
 	ContextPricing         *types.ContextPricingConfig `json:"context_pricing,omitempty"`
 	EnableGroup            []string                    `json:"enable_groups"`
 	SupportedEndpointTypes []constant.EndpointType     `json:"supported_endpoint_types"`
+	VirtualTargets         []string                    `json:"virtual_targets,omitempty"` // 虚拟模型的目标模型（按回退顺序）
 }
 
 type PricingVendor struct {
@@ -360,6 +362,12 @@ func updatePricing() {
 		pricingMap = append(pricingMap, pricing)
 	}
 
+	// 虚拟模型沿用首个可用目标的定价与端点展示，实际按最终使用的目标模型计费
+	for _, pricing := range buildVirtualModelPricing(pricingMap) {
+		modelSupportEndpointTypes[pricing.ModelName] = pricing.SupportedEndpointTypes
+		pricingMap = append(pricingMap, pricing)
+	}
+
 	// 刷新缓存映射，供高并发快速查询
 	modelEnableGroupsLock.Lock()
 	modelEnableGroups = make(map[string][]string)
@@ -373,6 +381,48 @@ func updatePricing() {
 	lastGetPricingTime = time.Now()
 }
 
+// buildVirtualModelPricing 为虚拟模型生成定价条目，可用分组为各目标分组的并集；没有任何可用目标时不展示
+func buildVirtualModelPricing(pricings []Pricing) []Pricing {
+	setting := operation_setting.GetVirtualModelSetting()
+	if !setting.Enabled {
+		return nil
+	}
+	byName := make(map[string]*Pricing, len(pricings))
+	for i := range pricings {
+		byName[pricings[i].ModelName] = &pricings[i]
+	}
+	result := make([]Pricing, 0, len(setting.Models))
+	for _, vm := range setting.Models {
+		if _, exists := byName[vm.Name]; exists {
+			continue
+		}
+		var primary *Pricing
+		groups := types.NewSet[string]()
+		for _, target := range vm.TargetNames() {
+			p, ok := byName[target]
+			if !ok {
+				continue
+			}
+			if primary == nil {
+				primary = p
+			}
+			for _, g := range p.EnableGroup {
+				groups.Add(g)
+			}
+		}
+		if primary == nil {
+			continue
+		}
+		pricing := *primary
+		pricing.ModelName = vm.Name
+		pricing.Description = vm.Description
+		pricing.EnableGroup = groups.Items()
+		pricing.VirtualTargets = vm.TargetNames()
+		result = append(result, pricing)
+	}
+	return result
+}
+
 // GetSupportedEndpointMap 返回全局端点到路径的映射
 func GetSupportedEndpointMap() map[string]common.EndpointInfo {
 	return supportedEndpointMap
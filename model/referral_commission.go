@@ -0,0 +1,262 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/logger"
+	"github.com/zhongruan0522/new-api/setting/operation_setting"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+const (
+	ReferralCommissionStatusHolding   = "holding"   // 冻结中，冻结期满后转入邀请额度
+	ReferralCommissionStatusAvailable = "available" // 已计入邀请额度，可划转
+	ReferralCommissionStatusReversed  = "reversed"  // 充值退款后已追回
+)
+
+// ReferralCommission 充值返佣流水，每笔充值的每一级邀请人对应一条记录，只追加不删除，用于审计
+type ReferralCommission struct {
+	Id          int     `json:"id"`
+	TopUpId     int     `json:"topup_id" gorm:"index"`
+	TradeNo     string  `json:"trade_no" gorm:"type:varchar(255);uniqueIndex:idx_referral_trade_level"`
+	Level       int     `json:"level" gorm:"uniqueIndex:idx_referral_trade_level"` // 1 表示直接邀请人
+	InviterId   int     `json:"inviter_id" gorm:"index"`
+	InviteeId   int     `json:"invitee_id" gorm:"index"` // 充值用户
+	BaseQuota   int     `json:"base_quota"`              // 充值到账额度
+	Rate        float64 `json:"rate"`
+	Quota       int     `json:"quota"`
+	Status      string  `json:"status" gorm:"type:varchar(20);index"`
+	CreatedAt   int64   `json:"created_at" gorm:"bigint"`
+	AvailableAt int64   `json:"available_at" gorm:"bigint;index"` // 预计解冻时间
+	SettledAt   int64   `json:"settled_at" gorm:"bigint"`         // 实际计入邀请额度的时间
+	ReversedAt  int64   `json:"reversed_at" gorm:"bigint"`
+	Remark      string  `json:"remark" gorm:"type:varchar(255)"`
+}
+
+// ReferralSummary 邀请返佣汇总
+type ReferralSummary struct {
+	HoldingQuota   int64 `json:"holding_quota"`
+	AvailableQuota int64 `json:"available_quota"`
+	ReversedQuota  int64 `json:"reversed_quota"`
+}
+
+// createReferralCommissionsTx 在充值事务内按邀请链生成返佣记录。
+// 冻结期为 0 时直接计入邀请人的邀请额度。
+func createReferralCommissionsTx(tx *gorm.DB, topUp *TopUp, baseQuota int) ([]*ReferralCommission, error) {
+	rates := operation_setting.GetReferralRates()
+	if len(rates) == 0 || baseQuota <= 0 {
+		return nil, nil
+	}
+
+	now := common.GetTimestamp()
+	holdingSeconds := int64(operation_setting.GetReferralSetting().HoldingDays) * 24 * 60 * 60
+
+	var commissions []*ReferralCommission
+	visited := map[int]bool{topUp.UserId: true}
+	currentId := topUp.UserId
+	for i, rate := range rates {
+		var current User
+		if err := tx.Select("id", "inviter_id").Where("id = ?", currentId).First(&current).Error; err != nil {
+			// 邀请链上的用户已被删除时停止返佣，其余数据库错误回滚整个充值事务
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				break
+			}
+			return nil, err
+		}
+		inviterId := current.InviterId
+		if inviterId == 0 || visited[inviterId] {
+			break
+		}
+		visited[inviterId] = true
+		currentId = inviterId
+
+		quota := int(decimal.NewFromInt(int64(baseQuota)).Mul(decimal.NewFromFloat(rate)).IntPart())
+		if quota <= 0 {
+			continue
+		}
+
+		commission := &ReferralCommission{
+			TopUpId:     topUp.Id,
+			TradeNo:     topUp.TradeNo,
+			Level:       i + 1,
+			InviterId:   inviterId,
+			InviteeId:   topUp.UserId,
+			BaseQuota:   baseQuota,
+			Rate:        rate,
+			Quota:       quota,
+			Status:      ReferralCommissionStatusHolding,
+			CreatedAt:   now,
+			AvailableAt: now + holdingSeconds,
+		}
+		if holdingSeconds == 0 {
+			commission.Status = ReferralCommissionStatusAvailable
+			commission.SettledAt = now
+		}
+		if err := tx.Create(commission).Error; err != nil {
+			return nil, err
+		}
+		if commission.Status == ReferralCommissionStatusAvailable {
+			if err := creditAffQuotaTx(tx, inviterId, quota); err != nil {
+				return nil, err
+			}
+		}
+		commissions = append(commissions, commission)
+	}
+	return commissions, nil
+}
+
+func creditAffQuotaTx(tx *gorm.DB, userId int, quota int) error {
+	return tx.Model(&User{}).Where("id = ?", userId).Updates(map[string]interface{}{
+		"aff_quota":   gorm.Expr("aff_quota + ?", quota),
+		"aff_history": gorm.Expr("aff_history + ?", quota),
+	}).Error
+}
+
+// recordReferralCommissionLogs 事务提交后为邀请人记录返佣日志
+func recordReferralCommissionLogs(commissions []*ReferralCommission) {
+	for _, commission := range commissions {
+		if commission.Status == ReferralCommissionStatusAvailable {
+			RecordLog(commission.InviterId, LogTypeSystem, fmt.Sprintf("邀请用户充值返佣 %s（%d 级，订单 %s）", logger.LogQuota(commission.Quota), commission.Level, commission.TradeNo))
+		} else {
+			RecordLog(commission.InviterId, LogTypeSystem, fmt.Sprintf("邀请用户充值返佣 %s（%d 级，订单 %s），冻结至 %s", logger.LogQuota(commission.Quota), commission.Level, commission.TradeNo, time.Unix(commission.AvailableAt, 0).Format("2006-01-02 15:04:05")))
+		}
+	}
+}
+
+// reverseReferralCommissionsTx 在退款事务内追回该订单的全部返佣。
+// 冻结中的返佣直接作废；已计入的返佣从邀请额度中扣回，邀请额度可能因此变为负数，后续返佣会先抵扣欠额。
+func reverseReferralCommissionsTx(tx *gorm.DB, tradeNo string, remark string) ([]*ReferralCommission, error) {
+	var commissions []*ReferralCommission
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("trade_no = ? AND status <> ?", tradeNo, ReferralCommissionStatusReversed).Find(&commissions).Error; err != nil {
+		return nil, err
+	}
+	now := common.GetTimestamp()
+	for _, commission := range commissions {
+		if commission.Status == ReferralCommissionStatusAvailable {
+			if err := tx.Model(&User{}).Where("id = ?", commission.InviterId).Updates(map[string]interface{}{
+				"aff_quota":   gorm.Expr("aff_quota - ?", commission.Quota),
+				"aff_history": gorm.Expr("aff_history - ?", commission.Quota),
+			}).Error; err != nil {
+				return nil, err
+			}
+		}
+		commission.Status = ReferralCommissionStatusReversed
+		commission.ReversedAt = now
+		commission.Remark = remark
+		if err := tx.Save(commission).Error; err != nil {
+			return nil, err
+		}
+	}
+	return commissions, nil
+}
+
+// SettleDueReferralCommissions 将冻结期已满的返佣计入邀请额度，返回本次结算的记录数
+func SettleDueReferralCommissions() (int, error) {
+	var due []ReferralCommission
+	err := DB.Where("status = ? AND available_at <= ?", ReferralCommissionStatusHolding, common.GetTimestamp()).
+		Order("id asc").Limit(500).Find(&due).Error
+	if err != nil {
+		return 0, err
+	}
+
+	settled := 0
+	for _, commission := range due {
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			// 条件更新保证同一条记录只会结算一次，并与退款追回互斥
+			result := tx.Model(&ReferralCommission{}).
+				Where("id = ? AND status = ?", commission.Id, ReferralCommissionStatusHolding).
+				Updates(map[string]interface{}{
+					"status":     ReferralCommissionStatusAvailable,
+					"settled_at": common.GetTimestamp(),
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errors.New("commission already processed")
+			}
+			return creditAffQuotaTx(tx, commission.InviterId, commission.Quota)
+		})
+		if err != nil {
+			continue
+		}
+		settled++
+		RecordLog(commission.InviterId, LogTypeSystem, fmt.Sprintf("邀请返佣解冻 %s（订单 %s）", logger.LogQuota(commission.Quota), commission.TradeNo))
+	}
+	return settled, nil
+}
+
+// RunReferralCommissionSettler 定时结算冻结期已满的返佣。
+// 关闭邀请返佣只停止产生新返佣，已产生的冻结返佣仍按期解冻
+func RunReferralCommissionSettler() {
+	for {
+		if count, err := SettleDueReferralCommissions(); err != nil {
+			common.SysError("failed to settle referral commissions: " + err.Error())
+		} else if count > 0 {
+			common.SysLog(fmt.Sprintf("settled %d referral commissions", count))
+		}
+		time.Sleep(time.Minute)
+	}
+}
+
+// GetUserReferralCommissions 获取邀请人名下的返佣记录
+func GetUserReferralCommissions(inviterId int, pageInfo *common.PageInfo) (commissions []*ReferralCommission, total int64, err error) {
+	query := DB.Model(&ReferralCommission{}).Where("inviter_id = ?", inviterId)
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Find(&commissions).Error
+	return commissions, total, err
+}
+
+// GetAllReferralCommissions 管理员查询返佣流水，可按邀请人、充值用户、订单号和状态过滤
+func GetAllReferralCommissions(inviterId int, inviteeId int, tradeNo string, status string, pageInfo *common.PageInfo) (commissions []*ReferralCommission, total int64, err error) {
+	query := DB.Model(&ReferralCommission{})
+	if inviterId != 0 {
+		query = query.Where("inviter_id = ?", inviterId)
+	}
+	if inviteeId != 0 {
+		query = query.Where("invitee_id = ?", inviteeId)
+	}
+	if tradeNo != "" {
+		query = query.Where("trade_no = ?", tradeNo)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Find(&commissions).Error
+	return commissions, total, err
+}
+
+// GetUserReferralSummary 汇总邀请人各状态的返佣额度
+func GetUserReferralSummary(inviterId int) (*ReferralSummary, error) {
+	var rows []struct {
+		Status string
+		Total  int64
+	}
+	err := DB.Model(&ReferralCommission{}).Select("status, COALESCE(SUM(quota), 0) AS total").
+		Where("inviter_id = ?", inviterId).Group("status").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	summary := &ReferralSummary{}
+	for _, row := range rows {
+		switch row.Status {
+		case ReferralCommissionStatusHolding:
+			summary.HoldingQuota = row.Total
+		case ReferralCommissionStatusAvailable:
+			summary.AvailableQuota = row.Total
+		case ReferralCommissionStatusReversed:
+			summary.ReversedQuota = row.Total
+		}
+	}
+	return summary, nil
+}
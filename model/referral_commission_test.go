@@ -0,0 +1,194 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/setting/operation_setting"
+	"gorm.io/gorm"
+)
+
+func setupReferralCommissionTestDB(t *testing.T, rates []float64, holdingDays int) {
+	t.Helper()
+
+	oldDB := DB
+	oldLogDB := LOG_DB
+	oldQuotaPerUnit := common.QuotaPerUnit
+	oldRedisEnabled := common.RedisEnabled
+	oldSetting := *operation_setting.GetReferralSetting()
+
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite test db: %v", err)
+	}
+	if err := db.AutoMigrate(&User{}, &TopUp{}, &Log{}, &ReferralCommission{}); err != nil {
+		t.Fatalf("migrate sqlite test db: %v", err)
+	}
+
+	DB = db
+	LOG_DB = db
+	common.QuotaPerUnit = 100
+	common.RedisEnabled = false
+	setting := operation_setting.GetReferralSetting()
+	setting.Enabled = true
+	setting.Rates = rates
+	setting.HoldingDays = holdingDays
+
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+		DB = oldDB
+		LOG_DB = oldLogDB
+		common.QuotaPerUnit = oldQuotaPerUnit
+		common.RedisEnabled = oldRedisEnabled
+		*operation_setting.GetReferralSetting() = oldSetting
+	})
+}
+
+func createReferralTestUser(t *testing.T, id int, inviterId int) {
+	t.Helper()
+
+	user := &User{
+		Id:        id,
+		Username:  fmt.Sprintf("referral-user-%d", id),
+		Status:    common.UserStatusEnabled,
+		AffCode:   fmt.Sprintf("aff%d", id),
+		InviterId: inviterId,
+	}
+	if err := DB.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+}
+
+func createReferralTestTopUp(t *testing.T, tradeNo string, userId int) {
+	t.Helper()
+
+	topUp := &TopUp{
+		UserId:          userId,
+		Amount:          10,
+		Money:           10,
+		TradeNo:         tradeNo,
+		PaymentMethod:   "alipay",
+		PaymentProvider: PaymentProviderEpay,
+		CreateTime:      time.Now().Unix(),
+		Status:          common.TopUpStatusPending,
+	}
+	if err := topUp.Insert(); err != nil {
+		t.Fatalf("create topup: %v", err)
+	}
+}
+
+func referralTestAffQuota(t *testing.T, userId int) int {
+	t.Helper()
+
+	var user User
+	if err := DB.Select("aff_quota").Where("id = ?", userId).First(&user).Error; err != nil {
+		t.Fatalf("get user aff quota: %v", err)
+	}
+	return user.AffQuota
+}
+
+func TestCompleteEpayTopUpCreatesMultiLevelCommissions(t *testing.T) {
+	setupReferralCommissionTestDB(t, []float64{0.1, 0.05}, 0)
+	createReferralTestUser(t, 1, 0)
+	createReferralTestUser(t, 2, 1)
+	createReferralTestUser(t, 3, 2)
+	createReferralTestTopUp(t, "referral-multi-level", 3)
+
+	if err := CompleteEpayTopUp("referral-multi-level", "alipay", "10"); err != nil {
+		t.Fatalf("CompleteEpayTopUp error = %v", err)
+	}
+
+	if got := referralTestAffQuota(t, 2); got != 100 {
+		t.Fatalf("level 1 aff quota = %d, want 100", got)
+	}
+	if got := referralTestAffQuota(t, 1); got != 50 {
+		t.Fatalf("level 2 aff quota = %d, want 50", got)
+	}
+
+	var count int64
+	DB.Model(&ReferralCommission{}).Where("trade_no = ?", "referral-multi-level").Count(&count)
+	if count != 2 {
+		t.Fatalf("commission count = %d, want 2", count)
+	}
+}
+
+func TestReferralCommissionHoldingAndSettle(t *testing.T) {
+	setupReferralCommissionTestDB(t, []float64{0.1}, 7)
+	createReferralTestUser(t, 1, 0)
+	createReferralTestUser(t, 2, 1)
+	createReferralTestTopUp(t, "referral-holding", 2)
+
+	if err := CompleteEpayTopUp("referral-holding", "alipay", "10"); err != nil {
+		t.Fatalf("CompleteEpayTopUp error = %v", err)
+	}
+	if got := referralTestAffQuota(t, 1); got != 0 {
+		t.Fatalf("aff quota during holding = %d, want 0", got)
+	}
+
+	if settled, err := SettleDueReferralCommissions(); err != nil || settled != 0 {
+		t.Fatalf("SettleDueReferralCommissions = (%d, %v), want (0, nil)", settled, err)
+	}
+
+	DB.Model(&ReferralCommission{}).Where("trade_no = ?", "referral-holding").Update("available_at", common.GetTimestamp()-1)
+	if settled, err := SettleDueReferralCommissions(); err != nil || settled != 1 {
+		t.Fatalf("SettleDueReferralCommissions = (%d, %v), want (1, nil)", settled, err)
+	}
+	if got := referralTestAffQuota(t, 1); got != 100 {
+		t.Fatalf("aff quota after settle = %d, want 100", got)
+	}
+}
+
+func TestRefundTopUpReversesCommissions(t *testing.T) {
+	setupReferralCommissionTestDB(t, []float64{0.1}, 0)
+	createReferralTestUser(t, 1, 0)
+	createReferralTestUser(t, 2, 1)
+	createReferralTestTopUp(t, "referral-refund", 2)
+
+	if err := CompleteEpayTopUp("referral-refund", "alipay", "10"); err != nil {
+		t.Fatalf("CompleteEpayTopUp error = %v", err)
+	}
+	if err := RefundTopUp("referral-refund", "chargeback"); err != nil {
+		t.Fatalf("RefundTopUp error = %v", err)
+	}
+
+	if got := referralTestAffQuota(t, 1); got != 0 {
+		t.Fatalf("aff quota after refund = %d, want 0", got)
+	}
+	if got := paymentProviderGuardUserQuota(t, 2); got != 0 {
+		t.Fatalf("user quota after refund = %d, want 0", got)
+	}
+	var commission ReferralCommission
+	if err := DB.Where("trade_no = ?", "referral-refund").First(&commission).Error; err != nil {
+		t.Fatalf("get commission: %v", err)
+	}
+	if commission.Status != ReferralCommissionStatusReversed {
+		t.Fatalf("commission status = %s, want %s", commission.Status, ReferralCommissionStatusReversed)
+	}
+	if err := RefundTopUp("referral-refund", ""); err != ErrTopUpStatusInvalid {
+		t.Fatalf("second RefundTopUp error = %v, want %v", err, ErrTopUpStatusInvalid)
+	}
+}
+
+func TestCreateReferralCommissionsReturnsDatabaseErrors(t *testing.T) {
+	setupReferralCommissionTestDB(t, []float64{0.1, 0.05}, 0)
+	createReferralTestUser(t, 3, 2) // 邀请人 2 已被删除
+
+	topUp := &TopUp{Id: 1, UserId: 3, TradeNo: "referral-deleted-inviter"}
+	commissions, err := createReferralCommissionsTx(DB, topUp, 1000)
+	if err != nil || len(commissions) != 1 || commissions[0].InviterId != 2 {
+		t.Fatalf("createReferralCommissionsTx = (%+v, %v), want only the level 1 commission", commissions, err)
+	}
+
+	if err := DB.Migrator().DropTable(&User{}); err != nil {
+		t.Fatalf("drop users table: %v", err)
+	}
+	topUp.TradeNo = "referral-db-error"
+	if _, err := createReferralCommissionsTx(DB, topUp, 1000); err == nil {
+		t.Fatal("expected database error to be returned")
+	}
+}
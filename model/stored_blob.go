@@ -0,0 +1,271 @@
+package model
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/pkg/blobstore"
+
+	"gorm.io/gorm"
+)
+
+const (
+	storedBlobKindImage = "images"
+	storedBlobKindVideo = "videos"
+
+	storedBlobMigrationBatchSize = 20
+
+	storedBlobLockStripes = 64
+)
+
+// storedBlobStore is the external backend for stored image/video bytes.
+// nil means bytes stay in the database (LargeBlob), which is the legacy behavior.
+var storedBlobStore blobstore.Store
+
+// InitStoredBlobStore configures the blob backend from BLOB_STORE_* env vars.
+func InitStoredBlobStore() error {
+	switch constant.BlobStoreType {
+	case "", "db":
+		storedBlobStore = nil
+	case "local":
+		store, err := blobstore.NewLocalStore(constant.BlobStoreLocalDir)
+		if err != nil {
+			return err
+		}
+		storedBlobStore = store
+	case "s3":
+		store, err := blobstore.NewS3Store(blobstore.S3Config{
+			Endpoint:       constant.BlobStoreS3Endpoint,
+			PublicEndpoint: constant.BlobStoreS3PublicEndpoint,
+			Region:         constant.BlobStoreS3Region,
+			Bucket:         constant.BlobStoreS3Bucket,
+			AccessKey:      constant.BlobStoreS3AccessKey,
+			SecretKey:      constant.BlobStoreS3SecretKey,
+			PathStyle:      constant.BlobStoreS3PathStyle,
+		})
+		if err != nil {
+			return err
+		}
+		storedBlobStore = store
+	default:
+		return fmt.Errorf("unsupported BLOB_STORE_TYPE: %s", constant.BlobStoreType)
+	}
+	if storedBlobStore != nil {
+		common.SysLog("stored media blob store: " + storedBlobStore.Name())
+	}
+	return nil
+}
+
+// storedBlobLocks serialize "put blob + write referencing row" against
+// "check references + delete blob" for the same content address, so a delete
+// cannot remove a blob that a concurrent insert is about to reference.
+// Keys are striped to keep memory bounded.
+var storedBlobLocks [storedBlobLockStripes]sync.Mutex
+
+func storedBlobLock(key string) *sync.Mutex {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return &storedBlobLocks[h.Sum32()%storedBlobLockStripes]
+}
+
+// putStoredBlob writes data to the blob store under its content address and
+// calls attach with the key to write the row referencing it. Both steps run
+// under the key's lock; see storedBlobLocks.
+func putStoredBlob(ctx context.Context, kind string, sha256Hex string, data []byte, mimeType string, attach func(key string) error) error {
+	if storedBlobStore == nil {
+		return errors.New("blob store is not configured")
+	}
+	if sha256Hex == "" {
+		sha256Hex = hex.EncodeToString(common.Sha256Raw(data))
+	}
+	key, err := blobstore.ContentKey(kind, sha256Hex)
+	if err != nil {
+		return err
+	}
+	lock := storedBlobLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+	if err := storedBlobStore.Put(ctx, key, data, mimeType); err != nil {
+		return err
+	}
+	return attach(key)
+}
+
+// deleteStoredBlobIfUnreferenced removes the blob only if no row references it
+// anymore. The reference check runs under the key's lock so it observes any
+// row written by a concurrent putStoredBlob.
+func deleteStoredBlobIfUnreferenced(ctx context.Context, table any, key string) error {
+	lock := storedBlobLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+	var count int64
+	if err := DB.WithContext(ctx).Model(table).Where("blob_key = ?", key).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return storedBlobStore.Delete(ctx, key)
+}
+
+func loadStoredBlob(ctx context.Context, key string) (LargeBlob, error) {
+	if storedBlobStore == nil {
+		return nil, errors.New("blob store is not configured")
+	}
+	data, err := storedBlobStore.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return LargeBlob(data), nil
+}
+
+// StoredBlobPresignEnabled reports whether stored media can be served via presigned redirects.
+func StoredBlobPresignEnabled() bool {
+	if constant.BlobStorePresignTTLSeconds <= 0 {
+		return false
+	}
+	_, ok := storedBlobStore.(blobstore.Presigner)
+	return ok
+}
+
+// PresignStoredBlob returns a direct download URL when the backend supports presigning.
+func PresignStoredBlob(ctx context.Context, key string) (string, bool) {
+	if key == "" || !StoredBlobPresignEnabled() {
+		return "", false
+	}
+	presigner := storedBlobStore.(blobstore.Presigner)
+	u, err := presigner.PresignGet(ctx, key, time.Duration(constant.BlobStorePresignTTLSeconds)*time.Second)
+	if err != nil {
+		common.SysError("failed to presign stored blob: " + err.Error())
+		return "", false
+	}
+	return u, true
+}
+
+// deleteStoredBlobRows deletes rows by id (optionally scoped to a user) and removes
+// the blobs they leave without references. Rows sharing the same Sha256 share one blob,
+// so the row delete and the reference check run in one transaction: concurrent deletes
+// of rows sharing a blob cannot both see the other row and leave the blob behind.
+// Each candidate is re-checked under its key lock before the blob is removed.
+func deleteStoredBlobRows(ctx context.Context, table any, ids []string, userId int) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	var deleted int64
+	var orphans []string
+	err := DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		scoped := func() *gorm.DB {
+			db := tx.Model(table).Where("id IN ?", ids)
+			if userId > 0 {
+				db = db.Where("user_id = ?", userId)
+			}
+			return db
+		}
+		var keys []string
+		if storedBlobStore != nil {
+			if err := scoped().Where("blob_key <> ''").Distinct().Pluck("blob_key", &keys).Error; err != nil {
+				return err
+			}
+		}
+		result := scoped().Delete(table)
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+		if len(keys) == 0 || deleted == 0 {
+			return nil
+		}
+		var live []string
+		if err := tx.Model(table).Where("blob_key IN ?", keys).Distinct().Pluck("blob_key", &live).Error; err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if !slices.Contains(live, key) {
+				orphans = append(orphans, key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range orphans {
+		if err := deleteStoredBlobIfUnreferenced(ctx, table, key); err != nil {
+			common.SysError(fmt.Sprintf("failed to delete stored blob %s: %v", key, err))
+		}
+	}
+	return deleted, nil
+}
+
+type storedBlobRow struct {
+	Id       string
+	Sha256   string
+	MimeType string
+	Data     LargeBlob
+}
+
+// migrateStoredBlobTable moves bytes still kept in the database to the blob store.
+func migrateStoredBlobTable(ctx context.Context, table any, kind string) (int, error) {
+	moved := 0
+	for {
+		if ctx.Err() != nil {
+			return moved, ctx.Err()
+		}
+		var rows []storedBlobRow
+		if err := DB.WithContext(ctx).Model(table).
+			Select("id", "sha256", "mime_type", "data").
+			Where("blob_key = ''").
+			Order("created_at asc").
+			Limit(storedBlobMigrationBatchSize).
+			Find(&rows).Error; err != nil {
+			return moved, err
+		}
+		if len(rows) == 0 {
+			return moved, nil
+		}
+		for _, row := range rows {
+			sha := row.Sha256
+			if sha == "" {
+				sha = hex.EncodeToString(common.Sha256Raw(row.Data))
+			}
+			if err := putStoredBlob(ctx, kind, sha, row.Data, row.MimeType, func(key string) error {
+				return DB.WithContext(ctx).Model(table).Where("id = ? AND blob_key = ''", row.Id).Updates(map[string]any{
+					"blob_key": key,
+					"sha256":   sha,
+					"data":     LargeBlob{},
+				}).Error
+			}); err != nil {
+				return moved, err
+			}
+			moved++
+		}
+	}
+}
+
+// MigrateStoredBlobs moves existing stored image/video bytes out of the database
+// into the configured blob store. It is safe to run repeatedly.
+func MigrateStoredBlobs() {
+	if storedBlobStore == nil {
+		return
+	}
+	ctx := context.Background()
+	images, err := migrateStoredBlobTable(ctx, &StoredImage{}, storedBlobKindImage)
+	if err != nil {
+		common.SysError("failed to migrate stored images to blob store: " + err.Error())
+	}
+	videos, err := migrateStoredBlobTable(ctx, &StoredVideo{}, storedBlobKindVideo)
+	if err != nil {
+		common.SysError("failed to migrate stored videos to blob store: " + err.Error())
+	}
+	if images > 0 || videos > 0 {
+		common.SysLog(fmt.Sprintf("migrated %d stored images and %d stored videos to blob store", images, videos))
+	}
+}
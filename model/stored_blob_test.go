@@ -0,0 +1,177 @@
+package model
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhongruan0522/new-api/pkg/blobstore"
+)
+
+func setupStoredBlobTestStore(t *testing.T) {
+	t.Helper()
+	setupPaymentProviderGuardTestDB(t)
+	if err := DB.AutoMigrate(&StoredImage{}); err != nil {
+		t.Fatalf("migrate stored image: %v", err)
+	}
+	store, err := blobstore.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore returned error: %v", err)
+	}
+	old := storedBlobStore
+	storedBlobStore = store
+	t.Cleanup(func() {
+		storedBlobStore = old
+	})
+}
+
+func TestStoredImageBlobStoreDedupe(t *testing.T) {
+	setupStoredBlobTestStore(t)
+	ctx := context.Background()
+
+	first := &StoredImage{UserId: 1, MimeType: "image/png", Data: LargeBlob("same-bytes")}
+	second := &StoredImage{UserId: 2, MimeType: "image/png", Data: LargeBlob("same-bytes")}
+	for _, img := range []*StoredImage{first, second} {
+		if err := img.Insert(ctx); err != nil {
+			t.Fatalf("Insert returned error: %v", err)
+		}
+	}
+	if first.BlobKey == "" || first.BlobKey != second.BlobKey {
+		t.Fatalf("blob keys = %q, %q, want identical content address", first.BlobKey, second.BlobKey)
+	}
+
+	var stored StoredImage
+	if err := DB.Where("id = ?", first.Id).First(&stored).Error; err != nil {
+		t.Fatalf("query stored image: %v", err)
+	}
+	if len(stored.Data) != 0 {
+		t.Fatalf("database still holds %d bytes", len(stored.Data))
+	}
+
+	loaded, err := GetStoredImageByID(ctx, first.Id)
+	if err != nil || string(loaded.Data) != "same-bytes" {
+		t.Fatalf("GetStoredImageByID = (%v, %v)", loaded, err)
+	}
+
+	if _, err := DeleteStoredImagesByIDs(ctx, []string{first.Id}, 0); err != nil {
+		t.Fatalf("DeleteStoredImagesByIDs returned error: %v", err)
+	}
+	if _, err := storedBlobStore.Get(ctx, second.BlobKey); err != nil {
+		t.Fatalf("shared blob removed while still referenced: %v", err)
+	}
+	if _, err := DeleteStoredImagesByIDs(ctx, []string{second.Id}, 0); err != nil {
+		t.Fatalf("DeleteStoredImagesByIDs returned error: %v", err)
+	}
+	if _, err := storedBlobStore.Get(ctx, second.BlobKey); err != blobstore.ErrNotFound {
+		t.Fatalf("blob after last delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDeleteStoredBlobRowsReleasesSharedBlobOnce(t *testing.T) {
+	setupStoredBlobTestStore(t)
+	ctx := context.Background()
+
+	first := &StoredImage{UserId: 1, MimeType: "image/png", Data: LargeBlob("shared")}
+	second := &StoredImage{UserId: 2, MimeType: "image/png", Data: LargeBlob("shared")}
+	for _, img := range []*StoredImage{first, second} {
+		if err := img.Insert(ctx); err != nil {
+			t.Fatalf("Insert returned error: %v", err)
+		}
+	}
+
+	// 限定用户时只删除该用户的行，共享的 blob 仍被另一行引用
+	deleted, err := DeleteStoredImagesByIDs(ctx, []string{first.Id, second.Id}, 1)
+	if err != nil || deleted != 1 {
+		t.Fatalf("DeleteStoredImagesByIDs = (%d, %v), want 1 row", deleted, err)
+	}
+	if _, err := storedBlobStore.Get(ctx, second.BlobKey); err != nil {
+		t.Fatalf("shared blob removed while still referenced: %v", err)
+	}
+
+	deleted, err = deleteStoredBlobRows(ctx, &StoredImage{}, []string{first.Id, second.Id}, 0)
+	if err != nil || deleted != 1 {
+		t.Fatalf("deleteStoredBlobRows = (%d, %v), want 1 row", deleted, err)
+	}
+	if _, err := storedBlobStore.Get(ctx, second.BlobKey); err != blobstore.ErrNotFound {
+		t.Fatalf("blob after last delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMigrateStoredBlobTable(t *testing.T) {
+	setupStoredBlobTestStore(t)
+	ctx := context.Background()
+
+	legacy := &StoredImage{Id: "legacy", UserId: 1, MimeType: "image/png", Data: LargeBlob("legacy-bytes")}
+	if err := DB.Create(legacy).Error; err != nil {
+		t.Fatalf("create legacy image: %v", err)
+	}
+
+	moved, err := migrateStoredBlobTable(ctx, &StoredImage{}, storedBlobKindImage)
+	if err != nil || moved != 1 {
+		t.Fatalf("migrateStoredBlobTable = (%d, %v), want (1, nil)", moved, err)
+	}
+	loaded, err := GetStoredImageByID(ctx, "legacy")
+	if err != nil {
+		t.Fatalf("GetStoredImageByID returned error: %v", err)
+	}
+	if loaded.BlobKey == "" || loaded.Sha256 == "" || string(loaded.Data) != "legacy-bytes" {
+		t.Fatalf("migrated image = %+v", loaded)
+	}
+	if moved, err := migrateStoredBlobTable(ctx, &StoredImage{}, storedBlobKindImage); err != nil || moved != 0 {
+		t.Fatalf("second migration = (%d, %v), want (0, nil)", moved, err)
+	}
+}
+
+// hookedBlobStore runs onPut after each Put, letting tests interleave other operations.
+type hookedBlobStore struct {
+	blobstore.Store
+	onPut func()
+}
+
+func (s *hookedBlobStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	if err := s.Store.Put(ctx, key, data, contentType); err != nil {
+		return err
+	}
+	if s.onPut != nil {
+		s.onPut()
+	}
+	return nil
+}
+
+func TestStoredImageInsertRacingLastDeleteKeepsSharedBlob(t *testing.T) {
+	setupStoredBlobTestStore(t)
+	ctx := context.Background()
+
+	first := &StoredImage{UserId: 1, MimeType: "image/png", Data: LargeBlob("shared")}
+	if err := first.Insert(ctx); err != nil {
+		t.Fatalf("Insert returned error: %v", err)
+	}
+
+	// 第二次写入 blob 之后、建行之前，并发删除引用同一 blob 的最后一行
+	hooked := &hookedBlobStore{Store: storedBlobStore}
+	storedBlobStore = hooked
+	var wg sync.WaitGroup
+	hooked.onPut = func() {
+		hooked.onPut = nil
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := DeleteStoredImagesByIDs(ctx, []string{first.Id}, 0); err != nil {
+				t.Errorf("DeleteStoredImagesByIDs returned error: %v", err)
+			}
+		}()
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	second := &StoredImage{UserId: 2, MimeType: "image/png", Data: LargeBlob("shared")}
+	if err := second.Insert(ctx); err != nil {
+		t.Fatalf("Insert returned error: %v", err)
+	}
+	wg.Wait()
+
+	loaded, err := GetStoredImageByID(ctx, second.Id)
+	if err != nil || string(loaded.Data) != "shared" {
+		t.Fatalf("GetStoredImageByID after concurrent delete = (%v, %v)", loaded, err)
+	}
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"context"
 	"database/sql/driver"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"strings"
@@ -76,9 +77,12 @@ type StoredImage struct {
 	MimeType  string    `json:"mime_type" gorm:"type:varchar(255);default:''"`
 	SizeBytes int       `json:"size_bytes" gorm:"default:0"`
 	Sha256    string    `json:"sha256" gorm:"type:char(64);index"`
+	BlobKey   string    `json:"-" gorm:"type:varchar(255);default:'';index"` // external blob store key; empty means Data holds the bytes
 	Data      LargeBlob `json:"-" gorm:"not null"`
 }
 
+var storedImageMetaColumns = []string{"id", "user_id", "channel_id", "created_at", "mime_type", "size_bytes", "sha256", "blob_key"}
+
 func (img *StoredImage) Insert(ctx context.Context) error {
 	if img == nil {
 		return errors.New("stored image is nil")
@@ -92,7 +96,19 @@ func (img *StoredImage) Insert(ctx context.Context) error {
 	if img.CreatedAt == 0 {
 		img.CreatedAt = common.GetTimestamp()
 	}
-	return DB.WithContext(ctx).Create(img).Error
+	if storedBlobStore == nil {
+		return DB.WithContext(ctx).Create(img).Error
+	}
+	if img.Sha256 == "" {
+		img.Sha256 = hex.EncodeToString(common.Sha256Raw(img.Data))
+	}
+	data := img.Data
+	defer func() { img.Data = data }()
+	return putStoredBlob(ctx, storedBlobKindImage, img.Sha256, data, img.MimeType, func(key string) error {
+		img.BlobKey = key
+		img.Data = LargeBlob{}
+		return DB.WithContext(ctx).Create(img).Error
+	})
 }
 
 func GetStoredImageByID(ctx context.Context, id string) (*StoredImage, error) {
@@ -106,6 +122,13 @@ func GetStoredImageByID(ctx context.Context, id string) (*StoredImage, error) {
 	if err := DB.WithContext(ctx).Where("id = ?", id).First(&img).Error; err != nil {
 		return nil, err
 	}
+	if img.BlobKey != "" {
+		data, err := loadStoredBlob(ctx, img.BlobKey)
+		if err != nil {
+			return nil, err
+		}
+		img.Data = data
+	}
 	return &img, nil
 }
 
@@ -121,7 +144,7 @@ func GetStoredImageByUserAndSha(ctx context.Context, userId int, sha256 string)
 		ctx = context.Background()
 	}
 	var img StoredImage
-	if err := DB.WithContext(ctx).Where("user_id = ? AND sha256 = ?", userId, sha256).Order("created_at asc").First(&img).Error; err != nil {
+	if err := DB.WithContext(ctx).Select(storedImageMetaColumns).Where("user_id = ? AND sha256 = ?", userId, sha256).Order("created_at asc").First(&img).Error; err != nil {
 		return nil, err
 	}
 	return &img, nil
@@ -136,7 +159,7 @@ func GetStoredImageMetaByID(ctx context.Context, id string) (*StoredImage, error
 	}
 	var img StoredImage
 	if err := DB.WithContext(ctx).Model(&StoredImage{}).
-		Select("id", "user_id", "channel_id", "created_at", "mime_type", "size_bytes", "sha256").
+		Select(storedImageMetaColumns).
 		Where("id = ?", id).
 		First(&img).Error; err != nil {
 		return nil, err
@@ -151,12 +174,7 @@ func DeleteStoredImagesByIDs(ctx context.Context, ids []string, userId int) (int
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	db := DB.WithContext(ctx).Where("id IN ?", ids)
-	if userId > 0 {
-		db = db.Where("user_id = ?", userId)
-	}
-	result := db.Delete(&StoredImage{})
-	return result.RowsAffected, result.Error
+	return deleteStoredBlobRows(ctx, &StoredImage{}, ids, userId)
 }
 
 func DeleteOldStoredImages(ctx context.Context, targetTimestamp int64, limit int) (int64, error) {
@@ -170,14 +188,30 @@ func DeleteOldStoredImages(ctx context.Context, targetTimestamp int64, limit int
 			return total, ctx.Err()
 		}
 
-		result := DB.WithContext(ctx).Where("created_at < ?", targetTimestamp).Limit(limit).Delete(&StoredImage{})
-		if result.Error != nil {
-			return total, result.Error
+		var affected int64
+		if storedBlobStore == nil {
+			result := DB.WithContext(ctx).Where("created_at < ?", targetTimestamp).Limit(limit).Delete(&StoredImage{})
+			if result.Error != nil {
+				return total, result.Error
+			}
+			affected = result.RowsAffected
+		} else {
+			var ids []string
+			if err := DB.WithContext(ctx).Model(&StoredImage{}).Where("created_at < ?", targetTimestamp).Limit(limit).Pluck("id", &ids).Error; err != nil {
+				return total, err
+			}
+			if len(ids) == 0 {
+				break
+			}
+			var err error
+			if affected, err = deleteStoredBlobRows(ctx, &StoredImage{}, ids, 0); err != nil {
+				return total, err
+			}
 		}
 
-		total += result.RowsAffected
+		total += affected
 
-		if result.RowsAffected < int64(limit) {
+		if affected < int64(limit) {
 			break
 		}
 	}
@@ -185,7 +219,7 @@ func DeleteOldStoredImages(ctx context.Context, targetTimestamp int64, limit int
 	return total, nil
 }
 
-func EnsureStoredImagesPoolLimit(ctx context.Context, maxBytes int64, batchSize int) (int64, error) {
+func EnsureStoredImagesPoolLimit(ctx context.Context, maxBytes int64, batchSize int, keepIDs ...string) (int64, error) {
 	if maxBytes <= 0 {
 		return 0, nil
 	}
@@ -213,8 +247,12 @@ func EnsureStoredImagesPoolLimit(ctx context.Context, maxBytes int64, batchSize
 
 		// Delete oldest images in batches until within limit.
 		var oldest []StoredImage
-		if err := DB.WithContext(ctx).Model(&StoredImage{}).
-			Select("id", "size_bytes").
+		query := DB.WithContext(ctx).Model(&StoredImage{}).Select("id", "size_bytes")
+		if len(keepIDs) > 0 {
+			// 当前请求正在返回的资源不参与淘汰，避免刚写入的链接立即失效
+			query = query.Where("id NOT IN ?", keepIDs)
+		}
+		if err := query.
 			Order("created_at asc").
 			Order("id asc").
 			Limit(batchSize).
@@ -225,23 +263,29 @@ func EnsureStoredImagesPoolLimit(ctx context.Context, maxBytes int64, batchSize
 			return deleted, nil
 		}
 
+		// 只删除超出部分所需的最旧图片，避免刚写入的图片随整批一起被清理
+		excess := totalBytes - maxBytes
 		ids := make([]string, 0, len(oldest))
 		for i := range oldest {
+			if excess <= 0 {
+				break
+			}
 			if oldest[i].Id != "" {
 				ids = append(ids, oldest[i].Id)
+				excess -= int64(oldest[i].SizeBytes)
 			}
 		}
 		if len(ids) == 0 {
 			return deleted, nil
 		}
 
-		result := DB.WithContext(ctx).Where("id IN ?", ids).Delete(&StoredImage{})
-		if result.Error != nil {
-			return deleted, result.Error
+		affected, err := deleteStoredBlobRows(ctx, &StoredImage{}, ids, 0)
+		if err != nil {
+			return deleted, err
 		}
-		if result.RowsAffected == 0 {
+		if affected == 0 {
 			return deleted, nil
 		}
-		deleted += result.RowsAffected
+		deleted += affected
 	}
 }
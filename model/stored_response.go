@@ -0,0 +1,140 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/zhongruan0522/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+// StoredResponse 网关侧保存的 Responses API 响应，用于展开 previous_response_id 以及检索/删除接口。
+// Input 只保存本轮新增的输入项，完整上下文通过 PreviousResponseId 链回溯得到。
+// id 由上游生成，不同上游可能重复，因此以 (id, user_id) 作为主键，避免用户之间互相覆盖。
+type StoredResponse struct {
+	Id                 string    `json:"id" gorm:"type:varchar(191);primaryKey"`
+	UserId             int       `json:"user_id" gorm:"primaryKey;autoIncrement:false;index"`
+	TokenId            int       `json:"token_id"`
+	ChannelId          int       `json:"channel_id"`
+	Model              string    `json:"model" gorm:"type:varchar(255)"`
+	PreviousResponseId string    `json:"previous_response_id" gorm:"type:varchar(191)"`
+	Input              LargeBlob `json:"-"` // 本轮输入项 JSON 数组
+	Output             LargeBlob `json:"-"` // 本轮输出项 JSON 数组
+	Response           LargeBlob `json:"-"` // 完整响应对象 JSON
+	CreatedAt          int64     `json:"created_at" gorm:"bigint;index"`
+}
+
+// Save 保存响应；同一用户下上游返回重复 id 时覆盖该用户的旧记录
+func (r *StoredResponse) Save(ctx context.Context) error {
+	if r.Id == "" {
+		return errors.New("id is required")
+	}
+	if r.UserId == 0 {
+		return errors.New("user id is required")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if r.CreatedAt == 0 {
+		r.CreatedAt = common.GetTimestamp()
+	}
+	return DB.WithContext(ctx).Save(r).Error
+}
+
+func (r *StoredResponse) GetInputItems() []json.RawMessage {
+	return unmarshalStoredResponseItems(r.Input)
+}
+
+func (r *StoredResponse) GetOutputItems() []json.RawMessage {
+	return unmarshalStoredResponseItems(r.Output)
+}
+
+func unmarshalStoredResponseItems(data []byte) []json.RawMessage {
+	var items []json.RawMessage
+	if len(data) > 0 {
+		_ = common.Unmarshal(data, &items)
+	}
+	return items
+}
+
+// GetStoredResponse 获取用户未过期的响应，不存在时返回 gorm.ErrRecordNotFound
+func GetStoredResponse(ctx context.Context, userId int, id string, notBefore int64) (*StoredResponse, error) {
+	if id == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var r StoredResponse
+	if err := DB.WithContext(ctx).Where("id = ? AND user_id = ? AND created_at >= ?", id, userId, notBefore).First(&r).Error; err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// GetStoredResponseHistory 沿 previous_response_id 链回溯，按时间顺序返回各轮的输入项与输出项。
+// 起点不存在时返回 gorm.ErrRecordNotFound；链中较早的响应已过期或被删除时在该处截断。
+func GetStoredResponseHistory(ctx context.Context, userId int, id string, notBefore int64, maxDepth int) ([]json.RawMessage, error) {
+	var chain []*StoredResponse
+	visited := make(map[string]bool)
+	for current := id; current != "" && len(chain) < maxDepth && !visited[current]; {
+		visited[current] = true
+		r, err := GetStoredResponse(ctx, userId, current, notBefore)
+		if err != nil {
+			if len(chain) > 0 && errors.Is(err, gorm.ErrRecordNotFound) {
+				break
+			}
+			return nil, err
+		}
+		chain = append(chain, r)
+		current = r.PreviousResponseId
+	}
+
+	var items []json.RawMessage
+	for i := len(chain) - 1; i >= 0; i-- {
+		items = append(items, chain[i].GetInputItems()...)
+		items = append(items, chain[i].GetOutputItems()...)
+	}
+	return items, nil
+}
+
+// DeleteStoredResponse 删除用户的响应，返回是否删除了记录
+func DeleteStoredResponse(ctx context.Context, userId int, id string) (bool, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	result := DB.WithContext(ctx).Where("id = ? AND user_id = ?", id, userId).Delete(&StoredResponse{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// DeleteExpiredStoredResponses 分批删除创建时间早于 targetTimestamp 的响应；
+// id 可能被多个用户共用，删除时仍按创建时间过滤，避免误删未过期的同 id 记录
+func DeleteExpiredStoredResponses(ctx context.Context, targetTimestamp int64, limit int) (int64, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var total int64
+	for {
+		if ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+		var ids []string
+		if err := DB.WithContext(ctx).Model(&StoredResponse{}).Where("created_at < ?", targetTimestamp).Limit(limit).Pluck("id", &ids).Error; err != nil {
+			return total, err
+		}
+		if len(ids) == 0 {
+			break
+		}
+		result := DB.WithContext(ctx).Where("id IN ? AND created_at < ?", ids, targetTimestamp).Delete(&StoredResponse{})
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if len(ids) < limit {
+			break
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,96 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/zhongruan0522/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+func TestStoredResponseHistoryFollowsChain(t *testing.T) {
+	setupPaymentProviderGuardTestDB(t)
+	if err := DB.AutoMigrate(&StoredResponse{}); err != nil {
+		t.Fatalf("migrate stored response: %v", err)
+	}
+	ctx := context.Background()
+	now := common.GetTimestamp()
+
+	responses := []*StoredResponse{
+		{Id: "resp_1", UserId: 1, Input: LargeBlob(`[{"role":"user","content":"a"}]`), Output: LargeBlob(`[{"type":"message","id":"m1"}]`), CreatedAt: now},
+		{Id: "resp_2", UserId: 1, PreviousResponseId: "resp_1", Input: LargeBlob(`[{"role":"user","content":"b"}]`), Output: LargeBlob(`[{"type":"message","id":"m2"}]`), CreatedAt: now},
+		{Id: "resp_other", UserId: 2, Input: LargeBlob(`[]`), Output: LargeBlob(`[]`), CreatedAt: now},
+	}
+	for _, r := range responses {
+		if err := r.Save(ctx); err != nil {
+			t.Fatalf("Save returned error: %v", err)
+		}
+	}
+
+	items, err := GetStoredResponseHistory(ctx, 1, "resp_2", 0, 100)
+	if err != nil {
+		t.Fatalf("GetStoredResponseHistory returned error: %v", err)
+	}
+	want := []string{
+		`{"role":"user","content":"a"}`,
+		`{"type":"message","id":"m1"}`,
+		`{"role":"user","content":"b"}`,
+		`{"type":"message","id":"m2"}`,
+	}
+	if len(items) != len(want) {
+		t.Fatalf("history has %d items, want %d", len(items), len(want))
+	}
+	for i := range want {
+		if string(items[i]) != want[i] {
+			t.Fatalf("items[%d] = %s, want %s", i, items[i], want[i])
+		}
+	}
+
+	if _, err := GetStoredResponseHistory(ctx, 1, "resp_other", 0, 100); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("other user's response err = %v, want ErrRecordNotFound", err)
+	}
+	if _, err := GetStoredResponseHistory(ctx, 1, "resp_2", now+1, 100); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expired response err = %v, want ErrRecordNotFound", err)
+	}
+
+	deleted, err := DeleteExpiredStoredResponses(ctx, now+1, 1)
+	if err != nil || deleted != 3 {
+		t.Fatalf("DeleteExpiredStoredResponses = %d, %v; want 3", deleted, err)
+	}
+}
+
+func TestStoredResponseSameIdDoesNotCrossUsers(t *testing.T) {
+	setupPaymentProviderGuardTestDB(t)
+	if err := DB.AutoMigrate(&StoredResponse{}); err != nil {
+		t.Fatalf("migrate stored response: %v", err)
+	}
+	ctx := context.Background()
+	now := common.GetTimestamp()
+
+	first := &StoredResponse{Id: "chatcmpl-1", UserId: 1, Output: LargeBlob(`[{"id":"u1"}]`), CreatedAt: now}
+	second := &StoredResponse{Id: "chatcmpl-1", UserId: 2, Output: LargeBlob(`[{"id":"u2"}]`), CreatedAt: now}
+	for _, r := range []*StoredResponse{first, second} {
+		if err := r.Save(ctx); err != nil {
+			t.Fatalf("Save returned error: %v", err)
+		}
+	}
+
+	for userId, want := range map[int]string{1: `[{"id":"u1"}]`, 2: `[{"id":"u2"}]`} {
+		r, err := GetStoredResponse(ctx, userId, "chatcmpl-1", 0)
+		if err != nil {
+			t.Fatalf("GetStoredResponse(user %d) returned error: %v", userId, err)
+		}
+		if string(r.Output) != want {
+			t.Fatalf("user %d output = %s, want %s", userId, r.Output, want)
+		}
+	}
+
+	if deleted, err := DeleteStoredResponse(ctx, 2, "chatcmpl-1"); err != nil || !deleted {
+		t.Fatalf("DeleteStoredResponse = %v, %v", deleted, err)
+	}
+	if _, err := GetStoredResponse(ctx, 1, "chatcmpl-1", 0); err != nil {
+		t.Fatalf("user 1 response should survive user 2 delete: %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	"context"
+	"encoding/hex"
 	"errors"
 	"strings"
 
@@ -19,9 +20,12 @@ type StoredVideo struct {
 	MimeType  string    `json:"mime_type" gorm:"type:varchar(255);default:''"`
 	SizeBytes int       `json:"size_bytes" gorm:"default:0"`
 	Sha256    string    `json:"sha256" gorm:"type:char(64);index"`
+	BlobKey   string    `json:"-" gorm:"type:varchar(255);default:'';index"` // external blob store key; empty means Data holds the bytes
 	Data      LargeBlob `json:"-" gorm:"not null"`
 }
 
+var storedVideoMetaColumns = []string{"id", "user_id", "channel_id", "created_at", "mime_type", "size_bytes", "sha256", "blob_key"}
+
 func (v *StoredVideo) Insert(ctx context.Context) error {
 	if v == nil {
 		return errors.New("stored video is nil")
@@ -35,7 +39,19 @@ func (v *StoredVideo) Insert(ctx context.Context) error {
 	if v.CreatedAt == 0 {
 		v.CreatedAt = common.GetTimestamp()
 	}
-	return DB.WithContext(ctx).Create(v).Error
+	if storedBlobStore == nil {
+		return DB.WithContext(ctx).Create(v).Error
+	}
+	if v.Sha256 == "" {
+		v.Sha256 = hex.EncodeToString(common.Sha256Raw(v.Data))
+	}
+	data := v.Data
+	defer func() { v.Data = data }()
+	return putStoredBlob(ctx, storedBlobKindVideo, v.Sha256, data, v.MimeType, func(key string) error {
+		v.BlobKey = key
+		v.Data = LargeBlob{}
+		return DB.WithContext(ctx).Create(v).Error
+	})
 }
 
 func GetStoredVideoByID(ctx context.Context, id string) (*StoredVideo, error) {
@@ -49,6 +65,13 @@ func GetStoredVideoByID(ctx context.Context, id string) (*StoredVideo, error) {
 	if err := DB.WithContext(ctx).Where("id = ?", id).First(&v).Error; err != nil {
 		return nil, err
 	}
+	if v.BlobKey != "" {
+		data, err := loadStoredBlob(ctx, v.BlobKey)
+		if err != nil {
+			return nil, err
+		}
+		v.Data = data
+	}
 	return &v, nil
 }
 
@@ -64,7 +87,7 @@ func GetStoredVideoByUserAndSha(ctx context.Context, userId int, sha256 string)
 		ctx = context.Background()
 	}
 	var v StoredVideo
-	if err := DB.WithContext(ctx).Where("user_id = ? AND sha256 = ?", userId, sha256).Order("created_at asc").First(&v).Error; err != nil {
+	if err := DB.WithContext(ctx).Select(storedVideoMetaColumns).Where("user_id = ? AND sha256 = ?", userId, sha256).Order("created_at asc").First(&v).Error; err != nil {
 		return nil, err
 	}
 	return &v, nil
@@ -79,7 +102,7 @@ func GetStoredVideoMetaByID(ctx context.Context, id string) (*StoredVideo, error
 	}
 	var v StoredVideo
 	if err := DB.WithContext(ctx).Model(&StoredVideo{}).
-		Select("id", "user_id", "channel_id", "created_at", "mime_type", "size_bytes", "sha256").
+		Select(storedVideoMetaColumns).
 		Where("id = ?", id).
 		First(&v).Error; err != nil {
 		return nil, err
@@ -94,12 +117,7 @@ func DeleteStoredVideosByIDs(ctx context.Context, ids []string, userId int) (int
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	db := DB.WithContext(ctx).Where("id IN ?", ids)
-	if userId > 0 {
-		db = db.Where("user_id = ?", userId)
-	}
-	result := db.Delete(&StoredVideo{})
-	return result.RowsAffected, result.Error
+	return deleteStoredBlobRows(ctx, &StoredVideo{}, ids, userId)
 }
 
 func DeleteOldStoredVideos(ctx context.Context, targetTimestamp int64, limit int) (int64, error) {
@@ -113,14 +131,30 @@ func DeleteOldStoredVideos(ctx context.Context, targetTimestamp int64, limit int
 			return total, ctx.Err()
 		}
 
-		result := DB.WithContext(ctx).Where("created_at < ?", targetTimestamp).Limit(limit).Delete(&StoredVideo{})
-		if result.Error != nil {
-			return total, result.Error
+		var affected int64
+		if storedBlobStore == nil {
+			result := DB.WithContext(ctx).Where("created_at < ?", targetTimestamp).Limit(limit).Delete(&StoredVideo{})
+			if result.Error != nil {
+				return total, result.Error
+			}
+			affected = result.RowsAffected
+		} else {
+			var ids []string
+			if err := DB.WithContext(ctx).Model(&StoredVideo{}).Where("created_at < ?", targetTimestamp).Limit(limit).Pluck("id", &ids).Error; err != nil {
+				return total, err
+			}
+			if len(ids) == 0 {
+				break
+			}
+			var err error
+			if affected, err = deleteStoredBlobRows(ctx, &StoredVideo{}, ids, 0); err != nil {
+				return total, err
+			}
 		}
 
-		total += result.RowsAffected
+		total += affected
 
-		if result.RowsAffected < int64(limit) {
+		if affected < int64(limit) {
 			break
 		}
 	}
@@ -128,7 +162,7 @@ func DeleteOldStoredVideos(ctx context.Context, targetTimestamp int64, limit int
 	return total, nil
 }
 
-func EnsureStoredVideosPoolLimit(ctx context.Context, maxBytes int64, batchSize int) (int64, error) {
+func EnsureStoredVideosPoolLimit(ctx context.Context, maxBytes int64, batchSize int, keepIDs ...string) (int64, error) {
 	if maxBytes <= 0 {
 		return 0, nil
 	}
@@ -155,8 +189,12 @@ func EnsureStoredVideosPoolLimit(ctx context.Context, maxBytes int64, batchSize
 		}
 
 		var oldest []StoredVideo
-		if err := DB.WithContext(ctx).Model(&StoredVideo{}).
-			Select("id", "size_bytes").
+		query := DB.WithContext(ctx).Model(&StoredVideo{}).Select("id", "size_bytes")
+		if len(keepIDs) > 0 {
+			// 当前请求正在返回的资源不参与淘汰，避免刚写入的链接立即失效
+			query = query.Where("id NOT IN ?", keepIDs)
+		}
+		if err := query.
 			Order("created_at asc").
 			Order("id asc").
 			Limit(batchSize).
@@ -177,13 +215,13 @@ func EnsureStoredVideosPoolLimit(ctx context.Context, maxBytes int64, batchSize
 			return deleted, nil
 		}
 
-		result := DB.WithContext(ctx).Where("id IN ?", ids).Delete(&StoredVideo{})
-		if result.Error != nil {
-			return deleted, result.Error
+		affected, err := deleteStoredBlobRows(ctx, &StoredVideo{}, ids, 0)
+		if err != nil {
+			return deleted, err
 		}
-		if result.RowsAffected == 0 {
+		if affected == 0 {
 			return deleted, nil
 		}
-		deleted += result.RowsAffected
+		deleted += affected
 	}
 }
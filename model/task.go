@@ -0,0 +1,197 @@
+package model
+
+import (
+	"context"
+	"errors"
+
+	"github.com/zhongruan0522/new-api/common"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"gorm.io/gorm"
+)
+
+const (
+	TaskPlatformVideo = "video"
+)
+
+const (
+	TaskStatusQueued     = "queued"
+	TaskStatusInProgress = "in_progress"
+	TaskStatusCompleted  = "completed"
+	TaskStatusFailed     = "failed"
+)
+
+// Task 异步生成任务（视频等），提交时按预估价格扣费，失败或超时时由后台轮询退款
+type Task struct {
+	Id             int    `json:"id"`
+	TaskId         string `json:"task_id" gorm:"type:varchar(64);uniqueIndex"` // 对外暴露的任务 ID
+	Platform       string `json:"platform" gorm:"type:varchar(32);default:''"`
+	UserId         int    `json:"user_id" gorm:"index"`
+	TokenId        int    `json:"token_id"`
+	TokenKey       string `json:"-" gorm:"type:varchar(128);default:''"`
+	TokenQuotaType int    `json:"-" gorm:"default:0"`
+	TokenName      string `json:"token_name" gorm:"type:varchar(255);default:''"`
+	Group          string `json:"group" gorm:"type:varchar(64);default:''"`
+	ChannelId      int    `json:"channel_id" gorm:"index"`
+	ChannelKeyIdx  int    `json:"-" gorm:"default:0"` // 多 Key 渠道提交时使用的 Key 下标，轮询需使用同一 Key
+	ModelName      string `json:"model_name" gorm:"type:varchar(255);default:''"`
+	UpstreamTaskId string `json:"-" gorm:"type:varchar(255);default:''"`
+	Status         string `json:"status" gorm:"type:varchar(32);index:idx_task_status_poll,priority:1"`
+	Progress       int    `json:"progress" gorm:"default:0"`
+	Quota          int    `json:"quota" gorm:"default:0"`
+	Seconds        int    `json:"seconds" gorm:"default:0"`
+	Size           string `json:"size" gorm:"type:varchar(32);default:''"`
+	FailReason     string `json:"fail_reason" gorm:"type:text"`
+	ResultVideoId  string `json:"-" gorm:"type:varchar(64);default:''"` // 完成后保存到 StoredVideo 的 ID
+	NextPollAt     int64  `json:"-" gorm:"bigint;index:idx_task_status_poll,priority:2"`
+	PollCount      int    `json:"-" gorm:"default:0"`
+	CreatedAt      int64  `json:"created_at" gorm:"bigint;index"`
+	UpdatedAt      int64  `json:"updated_at" gorm:"bigint"`
+	FinishedAt     int64  `json:"finished_at" gorm:"bigint;default:0"`
+	Refunded       bool   `json:"-" gorm:"default:false"` // 失败任务是否已退款，退款失败时由轮询重试
+}
+
+func (t *Task) IsFinished() bool {
+	return t.Status == TaskStatusCompleted || t.Status == TaskStatusFailed
+}
+
+func (t *Task) Insert(ctx context.Context) error {
+	if t.TaskId == "" {
+		return errors.New("task_id is required")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	now := common.GetTimestamp()
+	if t.CreatedAt == 0 {
+		t.CreatedAt = now
+	}
+	t.UpdatedAt = now
+	return DB.WithContext(ctx).Create(t).Error
+}
+
+// GetUserTask 获取用户自己的任务，不存在时返回 gorm.ErrRecordNotFound
+func GetUserTask(ctx context.Context, userId int, taskId string) (*Task, error) {
+	if taskId == "" {
+		return nil, errors.New("task_id is required")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var task Task
+	if err := DB.WithContext(ctx).Where("user_id = ? AND task_id = ?", userId, taskId).First(&task).Error; err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// GetDuePendingTasks 获取已到轮询时间的未完成任务
+func GetDuePendingTasks(ctx context.Context, now int64, limit int) ([]*Task, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var tasks []*Task
+	err := DB.WithContext(ctx).
+		Where("status IN ? AND next_poll_at <= ?", []string{TaskStatusQueued, TaskStatusInProgress}, now).
+		Order("next_poll_at asc").
+		Limit(limit).
+		Find(&tasks).Error
+	return tasks, err
+}
+
+// UpdateTaskProgress 更新进行中的任务，仅在状态未被其他节点改为终态时生效
+func UpdateTaskProgress(ctx context.Context, task *Task, fromStatus string) (bool, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	task.UpdatedAt = common.GetTimestamp()
+	result := DB.WithContext(ctx).Model(&Task{}).
+		Where("id = ? AND status = ?", task.Id, fromStatus).
+		Updates(map[string]interface{}{
+			"status":          task.Status,
+			"progress":        task.Progress,
+			"fail_reason":     task.FailReason,
+			"result_video_id": task.ResultVideoId,
+			"next_poll_at":    task.NextPollAt,
+			"poll_count":      task.PollCount,
+			"updated_at":      task.UpdatedAt,
+			"finished_at":     task.FinishedAt,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetUnrefundedFailedTasks 获取已失败但尚未完成退款的任务，供轮询重试退款
+func GetUnrefundedFailedTasks(ctx context.Context, limit int) ([]*Task, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var tasks []*Task
+	err := DB.WithContext(ctx).
+		Where("status = ? AND refunded = ? AND quota > 0", TaskStatusFailed, false).
+		Order("id asc").
+		Limit(limit).
+		Find(&tasks).Error
+	return tasks, err
+}
+
+// RefundFailedTask 在同一事务中退还失败任务的用户额度、令牌额度和已用额度，并标记任务已退款。
+// 任务已退款或不是失败状态时返回 false，重复调用不会重复退款
+func RefundFailedTask(task *Task) (bool, error) {
+	if task.Quota <= 0 {
+		return false, nil
+	}
+	refunded := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Task{}).
+			Where("id = ? AND status = ? AND refunded = ?", task.Id, TaskStatusFailed, false).
+			Update("refunded", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		if err := tx.Model(&User{}).Where("id = ?", task.UserId).Updates(map[string]interface{}{
+			"quota":      gorm.Expr("quota + ?", task.Quota),
+			"used_quota": gorm.Expr("used_quota - ?", task.Quota),
+		}).Error; err != nil {
+			return err
+		}
+		if task.TokenId > 0 {
+			if err := tx.Model(&Token{}).Where("id = ?", task.TokenId).Updates(tokenRefundUpdates(task.TokenQuotaType, task.Quota)).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&Channel{}).Where("id = ?", task.ChannelId).
+			Update("used_quota", gorm.Expr("used_quota - ?", task.Quota)).Error; err != nil {
+			return err
+		}
+		refunded = true
+		return nil
+	})
+	if err != nil || !refunded {
+		return false, err
+	}
+	task.Refunded = true
+	refundTaskQuotaCache(task)
+	return true, nil
+}
+
+// refundTaskQuotaCache 退款事务提交后同步 Redis 中的用户与令牌额度
+func refundTaskQuotaCache(task *Task) {
+	if !common.RedisEnabled {
+		return
+	}
+	quota := int64(task.Quota)
+	gopool.Go(func() {
+		if err := cacheIncrUserQuota(task.UserId, quota); err != nil {
+			common.SysLog("failed to increase user quota: " + err.Error())
+		}
+		if task.TokenId <= 0 || task.TokenKey == "" {
+			return
+		}
+		if err := cacheRefundTokenQuota(task.TokenKey, task.TokenQuotaType, quota); err != nil {
+			common.SysLog("failed to refund token quota cache: " + err.Error())
+		}
+	})
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -25,8 +26,21 @@ const (
 const (
 	TicketEntryTypeMessage = 1 + iota
 	TicketEntryTypeStatusChange
+	TicketEntryTypeAttachment
+	TicketEntryTypeAssignment
+	TicketEntryTypePriorityChange
 )
 
+const (
+	TicketPriorityLow = 1 + iota
+	TicketPriorityNormal
+	TicketPriorityHigh
+	TicketPriorityUrgent
+)
+
+// maxTicketRequestIds 单个工单最多可关联的请求 ID 数量
+const maxTicketRequestIds = 20
+
 var ErrTicketNotFound = errors.New("工单不存在")
 
 var ticketTypeToName = map[int]string{
@@ -42,6 +56,20 @@ var ticketStatusToName = map[int]string{
 	TicketStatusCompleted:  "completed",
 }
 
+var ticketPriorityToName = map[int]string{
+	TicketPriorityLow:    "low",
+	TicketPriorityNormal: "normal",
+	TicketPriorityHigh:   "high",
+	TicketPriorityUrgent: "urgent",
+}
+
+var ticketNameToPriority = map[string]int{
+	"low":    TicketPriorityLow,
+	"normal": TicketPriorityNormal,
+	"high":   TicketPriorityHigh,
+	"urgent": TicketPriorityUrgent,
+}
+
 var ticketNameToType = map[string]int{
 	"bug":      TicketTypeBug,
 	"feature":  TicketTypeFeature,
@@ -61,10 +89,19 @@ type Ticket struct {
 	Title     string         `json:"title" gorm:"type:varchar(255);not null"`
 	Type      int            `json:"type" gorm:"type:int;default:1"`
 	Status    int            `json:"status" gorm:"type:int;default:1;index:idx_ticket_status_updated_id,priority:1"`
+	Priority  int            `json:"priority" gorm:"type:int;default:2;index"`
 	CreatedAt int64          `json:"created_at" gorm:"bigint;index"`
 	UpdatedAt int64          `json:"updated_at" gorm:"bigint;index:idx_ticket_updated_at_id,priority:1;index:idx_ticket_user_updated_id,priority:2;index:idx_ticket_status_updated_id,priority:2"`
 	ClosedAt  int64          `json:"closed_at" gorm:"bigint;default:0"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	AssigneeId           int    `json:"assignee_id" gorm:"index;default:0"`
+	RequestIds           string `json:"request_ids" gorm:"type:varchar(1500);default:''"` // 关联的日志请求 ID，逗号分隔
+	FirstResponseAt      int64  `json:"first_response_at" gorm:"bigint;default:0"`        // 管理员首次回复时间
+	FirstResponseDueAt   int64  `json:"first_response_due_at" gorm:"bigint;default:0"`    // 首次响应截止时间，0 表示不限
+	ResolutionDueAt      int64  `json:"resolution_due_at" gorm:"bigint;default:0"`        // 解决截止时间，0 表示不限
+	FirstResponseAlerted bool   `json:"first_response_alerted" gorm:"default:false"`
+	ResolutionAlerted    bool   `json:"resolution_alerted" gorm:"default:false"`
 }
 
 type TicketEntry struct {
@@ -81,8 +118,10 @@ type TicketEntry struct {
 }
 
 type TicketListFilter struct {
-	UserId int
-	Status int
+	UserId     int
+	Status     int
+	Priority   int
+	AssigneeId int
 
 	Keyword string
 	Offset  int
@@ -103,6 +142,56 @@ func TicketStatusName(status int) string {
 	return "pending"
 }
 
+func TicketPriorityName(priority int) string {
+	if name, ok := ticketPriorityToName[priority]; ok {
+		return name
+	}
+	return "normal"
+}
+
+func ParseTicketPriority(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return TicketPriorityNormal, nil
+	}
+	priority, ok := ticketNameToPriority[value]
+	if !ok {
+		return 0, errors.New("无效的工单优先级")
+	}
+	return priority, nil
+}
+
+// NormalizeTicketRequestIds 去重并校验关联的请求 ID，返回逗号分隔的存储值
+func NormalizeTicketRequestIds(ids []string) (string, error) {
+	seen := make(map[string]struct{}, len(ids))
+	normalized := make([]string, 0, len(ids))
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if len(id) > 64 || strings.Contains(id, ",") {
+			return "", errors.New("无效的请求 ID")
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		normalized = append(normalized, id)
+	}
+	if len(normalized) > maxTicketRequestIds {
+		return "", fmt.Errorf("最多关联 %d 个请求 ID", maxTicketRequestIds)
+	}
+	return strings.Join(normalized, ","), nil
+}
+
+func (ticket *Ticket) GetRequestIds() []string {
+	if ticket.RequestIds == "" {
+		return []string{}
+	}
+	return strings.Split(ticket.RequestIds, ",")
+}
+
 func ParseTicketType(value string) (int, error) {
 	ticketType, ok := ticketNameToType[strings.TrimSpace(value)]
 	if !ok {
@@ -136,6 +225,12 @@ func buildTicketListQuery(query *gorm.DB, filter TicketListFilter) *gorm.DB {
 	if filter.Status > 0 {
 		query = query.Where("status = ?", filter.Status)
 	}
+	if filter.Priority > 0 {
+		query = query.Where("priority = ?", filter.Priority)
+	}
+	if filter.AssigneeId > 0 {
+		query = query.Where("assignee_id = ?", filter.AssigneeId)
+	}
 
 	keyword := strings.TrimSpace(filter.Keyword)
 	if keyword != "" {
@@ -214,6 +309,53 @@ func UpdateTicketStatusTx(tx *gorm.DB, ticketId int, fromStatus int, values map[
 	return result.RowsAffected > 0, nil
 }
 
+// GetTicketLinkedLogs 查询工单关联的请求日志，userId 大于 0 时仅返回该用户的日志
+func GetTicketLinkedLogs(requestIds []string, userId int) ([]*Log, error) {
+	var logs []*Log
+	if len(requestIds) == 0 {
+		return logs, nil
+	}
+	query := LOG_DB.Where("request_id IN ?", requestIds)
+	if userId > 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	if err := query.Order("id DESC").Limit(maxTicketRequestIds * 2).Find(&logs).Error; err != nil {
+		return nil, errors.New("查询关联日志失败")
+	}
+	if userId > 0 {
+		formatUserLogs(logs, 0)
+	}
+	return logs, nil
+}
+
+// ListTicketsFirstResponseOverdue 查询已超过首次响应时限且尚未提醒的工单
+func ListTicketsFirstResponseOverdue(now int64, limit int) ([]*Ticket, error) {
+	var tickets []*Ticket
+	err := DB.Where("status <> ? AND first_response_at = 0 AND first_response_due_at > 0 AND first_response_due_at <= ? AND first_response_alerted = ?",
+		TicketStatusCompleted, now, false).Order("first_response_due_at ASC").Limit(limit).Find(&tickets).Error
+	return tickets, err
+}
+
+// ListTicketsResolutionOverdue 查询已超过解决时限且尚未提醒的工单
+func ListTicketsResolutionOverdue(now int64, limit int) ([]*Ticket, error) {
+	var tickets []*Ticket
+	err := DB.Where("status <> ? AND resolution_due_at > 0 AND resolution_due_at <= ? AND resolution_alerted = ?",
+		TicketStatusCompleted, now, false).Order("resolution_due_at ASC").Limit(limit).Find(&tickets).Error
+	return tickets, err
+}
+
+// MarkTicketSlaAlerted 标记 SLA 超时提醒已发送，返回是否由本次调用完成标记
+func MarkTicketSlaAlerted(ticketId int, column string) (bool, error) {
+	if column != "first_response_alerted" && column != "resolution_alerted" {
+		return false, errors.New("invalid sla column")
+	}
+	result := DB.Model(&Ticket{}).Where("id = ? AND "+column+" = ?", ticketId, false).Update(column, true)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
 func escapeTicketLikeValue(value string) string {
 	value = strings.ReplaceAll(value, "!", "!!")
 	value = strings.ReplaceAll(value, "%", "!%")
@@ -228,6 +370,7 @@ func NewTicket(title string, userId int, ticketType int) *Ticket {
 		Title:     title,
 		Type:      ticketType,
 		Status:    TicketStatusPending,
+		Priority:  TicketPriorityNormal,
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/zhongruan0522/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+var ErrTicketAttachmentNotFound = errors.New("附件不存在")
+
+// TicketAttachment 工单附件（截图、日志文件），与 StoredImage 一样以二进制形式存储在数据库中
+type TicketAttachment struct {
+	Id        string    `json:"id" gorm:"primaryKey;type:varchar(64)"`
+	TicketId  int       `json:"ticket_id" gorm:"index"`
+	EntryId   int       `json:"entry_id" gorm:"index"`
+	UserId    int       `json:"user_id" gorm:"index"`
+	FileName  string    `json:"file_name" gorm:"type:varchar(255);default:''"`
+	MimeType  string    `json:"mime_type" gorm:"type:varchar(255);default:''"`
+	SizeBytes int       `json:"size_bytes" gorm:"default:0"`
+	Sha256    string    `json:"sha256" gorm:"type:char(64)"`
+	CreatedAt int64     `json:"created_at" gorm:"bigint;index"`
+	Data      LargeBlob `json:"-" gorm:"not null"`
+}
+
+func CreateTicketAttachmentTx(tx *gorm.DB, attachment *TicketAttachment) error {
+	if attachment.Id == "" {
+		attachment.Id = common.GetUUID()
+	}
+	if attachment.CreatedAt == 0 {
+		attachment.CreatedAt = common.GetTimestamp()
+	}
+	return tx.Create(attachment).Error
+}
+
+// GetTicketAttachmentMetas 查询工单下所有附件的元信息（不含文件内容）
+func GetTicketAttachmentMetas(ticketId int) ([]*TicketAttachment, error) {
+	var attachments []*TicketAttachment
+	err := DB.Model(&TicketAttachment{}).
+		Select("id", "ticket_id", "entry_id", "user_id", "file_name", "mime_type", "size_bytes", "sha256", "created_at").
+		Where("ticket_id = ?", ticketId).Order("created_at ASC").Find(&attachments).Error
+	if err != nil {
+		return nil, errors.New("查询工单附件失败")
+	}
+	return attachments, nil
+}
+
+func GetTicketAttachment(ticketId int, id string) (*TicketAttachment, error) {
+	var attachment TicketAttachment
+	if err := DB.Where("id = ? AND ticket_id = ?", id, ticketId).First(&attachment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTicketAttachmentNotFound
+		}
+		return nil, err
+	}
+	return &attachment, nil
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/zhongruan0522/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+var ErrTicketCannedReplyNotFound = errors.New("快捷回复不存在")
+
+// TicketCannedReply 管理员可复用的工单快捷回复
+type TicketCannedReply struct {
+	Id        int    `json:"id"`
+	Title     string `json:"title" gorm:"type:varchar(128);not null"`
+	Content   string `json:"content" gorm:"type:text"`
+	CreatedBy int    `json:"created_by" gorm:"index"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+	UpdatedAt int64  `json:"updated_at" gorm:"bigint"`
+}
+
+func ListTicketCannedReplies() ([]*TicketCannedReply, error) {
+	var replies []*TicketCannedReply
+	if err := DB.Order("id ASC").Find(&replies).Error; err != nil {
+		return nil, errors.New("查询快捷回复失败")
+	}
+	return replies, nil
+}
+
+func GetTicketCannedReplyByID(id int) (*TicketCannedReply, error) {
+	var reply TicketCannedReply
+	if err := DB.First(&reply, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTicketCannedReplyNotFound
+		}
+		return nil, err
+	}
+	return &reply, nil
+}
+
+func (reply *TicketCannedReply) Insert() error {
+	now := common.GetTimestamp()
+	reply.CreatedAt = now
+	reply.UpdatedAt = now
+	return DB.Create(reply).Error
+}
+
+func (reply *TicketCannedReply) Update() error {
+	reply.UpdatedAt = common.GetTimestamp()
+	return DB.Model(reply).Select("title", "content", "updated_at").Updates(reply).Error
+}
+
+func DeleteTicketCannedReplyByID(id int) error {
+	result := DB.Delete(&TicketCannedReply{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTicketCannedReplyNotFound
+	}
+	return nil
+}
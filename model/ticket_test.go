@@ -0,0 +1,96 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/zhongruan0522/new-api/common"
+	"gorm.io/gorm"
+)
+
+func setupTicketTestDB(t *testing.T) {
+	t.Helper()
+
+	oldDB := DB
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite test db: %v", err)
+	}
+	if err := db.AutoMigrate(&Ticket{}, &TicketEntry{}, &TicketAttachment{}); err != nil {
+		t.Fatalf("migrate sqlite test db: %v", err)
+	}
+	DB = db
+
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+		DB = oldDB
+	})
+}
+
+func TestNormalizeTicketRequestIds(t *testing.T) {
+	got, err := NormalizeTicketRequestIds([]string{" req-1 ", "", "req-2", "req-1"})
+	if err != nil {
+		t.Fatalf("NormalizeTicketRequestIds error = %v", err)
+	}
+	if got != "req-1,req-2" {
+		t.Fatalf("NormalizeTicketRequestIds = %q, want %q", got, "req-1,req-2")
+	}
+
+	if _, err := NormalizeTicketRequestIds([]string{"a,b"}); err == nil {
+		t.Fatal("expected error for request id containing comma")
+	}
+
+	tooMany := make([]string, 0, maxTicketRequestIds+1)
+	for i := 0; i <= maxTicketRequestIds; i++ {
+		tooMany = append(tooMany, fmt.Sprintf("req-%d", i))
+	}
+	if _, err := NormalizeTicketRequestIds(tooMany); err == nil {
+		t.Fatal("expected error for too many request ids")
+	}
+	if got := (&Ticket{RequestIds: strings.Join(tooMany[:2], ",")}).GetRequestIds(); len(got) != 2 {
+		t.Fatalf("GetRequestIds len = %d, want 2", len(got))
+	}
+}
+
+func TestTicketSlaOverdueQueries(t *testing.T) {
+	setupTicketTestDB(t)
+
+	now := common.GetTimestamp()
+	overdue := NewTicket("overdue", 1, TicketTypeBug)
+	overdue.FirstResponseDueAt = now - 60
+	overdue.ResolutionDueAt = now - 60
+	responded := NewTicket("responded", 1, TicketTypeBug)
+	responded.FirstResponseDueAt = now - 60
+	responded.FirstResponseAt = now - 120
+	closed := NewTicket("closed", 1, TicketTypeBug)
+	closed.Status = TicketStatusCompleted
+	closed.ResolutionDueAt = now - 60
+	for _, ticket := range []*Ticket{overdue, responded, closed} {
+		if err := CreateTicketTx(DB, ticket); err != nil {
+			t.Fatalf("create ticket: %v", err)
+		}
+	}
+
+	tickets, err := ListTicketsFirstResponseOverdue(now, 10)
+	if err != nil || len(tickets) != 1 || tickets[0].Id != overdue.Id {
+		t.Fatalf("ListTicketsFirstResponseOverdue = (%v, %v), want only ticket %d", tickets, err, overdue.Id)
+	}
+	tickets, err = ListTicketsResolutionOverdue(now, 10)
+	if err != nil || len(tickets) != 1 || tickets[0].Id != overdue.Id {
+		t.Fatalf("ListTicketsResolutionOverdue = (%v, %v), want only ticket %d", tickets, err, overdue.Id)
+	}
+
+	if marked, err := MarkTicketSlaAlerted(overdue.Id, "first_response_alerted"); err != nil || !marked {
+		t.Fatalf("MarkTicketSlaAlerted = (%v, %v), want (true, nil)", marked, err)
+	}
+	if marked, err := MarkTicketSlaAlerted(overdue.Id, "first_response_alerted"); err != nil || marked {
+		t.Fatalf("second MarkTicketSlaAlerted = (%v, %v), want (false, nil)", marked, err)
+	}
+	if tickets, _ := ListTicketsFirstResponseOverdue(now, 10); len(tickets) != 0 {
+		t.Fatalf("overdue tickets after alert = %d, want 0", len(tickets))
+	}
+}
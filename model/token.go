@@ -44,6 +44,8 @@ type Token struct {
 	UsedQuota          int     `json:"used_quota" gorm:"default:0"` // used quota
 	Group              string  `json:"group" gorm:"default:''"`
 	CrossGroupRetry    bool    `json:"cross_group_retry"` // 跨分组重试，仅auto分组有效
+	// 推理输出模式：native/hidden/field/inline，空值沿用渠道设置；请求头 X-Reasoning-Output 优先
+	ReasoningOutput string `json:"reasoning_output" gorm:"type:varchar(16);default:''"`
 
 	// 限额类型：0=无限额度, 1=永久限额, 2=时段限额, 3=时段+周期限额
 	QuotaType int `json:"quota_type" gorm:"default:0"`
@@ -414,7 +416,7 @@ func (token *Token) Update() (err error) {
 		}
 	}()
 	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
-		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry",
+		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry", "reasoning_output",
 		"quota_type", "window_hours", "window_quota", "window_start_hour",
 		"cycle_days", "cycle_quota",
 		"window_used_quota", "window_start_time", "cycle_used_quota", "cycle_start_time").Updates(token).Error
@@ -585,6 +587,45 @@ func updateTokenUsedQuota(id int, delta int) error {
 	).Error
 }
 
+// tokenRefundUpdates 按令牌额度类型返回退还额度时的字段更新，与扣减方式对应。
+// 计费会话退款与异步任务退款共用，任务退款在事务中直接使用
+func tokenRefundUpdates(quotaType int, quota int) map[string]interface{} {
+	updates := map[string]interface{}{
+		"accessed_time": common.GetTimestamp(),
+	}
+	switch quotaType {
+	case 0: // 无限额度，只回滚已用额度，不恢复剩余额度
+		updates["used_quota"] = gorm.Expr("used_quota - ?", quota)
+	case 2: // 时段限额
+		updates["window_used_quota"] = gorm.Expr("window_used_quota - ?", quota)
+	case 3: // 时段+周期限额
+		updates["window_used_quota"] = gorm.Expr("window_used_quota - ?", quota)
+		updates["cycle_used_quota"] = gorm.Expr("cycle_used_quota - ?", quota)
+	default: // 永久限额
+		updates["remain_quota"] = gorm.Expr("remain_quota + ?", quota)
+		updates["used_quota"] = gorm.Expr("used_quota - ?", quota)
+	}
+	return updates
+}
+
+// RefundTokenQuota 按令牌额度类型退还额度并同步缓存，时段与周期额度在同一条更新中退还
+func RefundTokenQuota(id int, key string, quotaType int, quota int) error {
+	if quota < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	if err := DB.Model(&Token{}).Where("id = ?", id).Updates(tokenRefundUpdates(quotaType, quota)).Error; err != nil {
+		return err
+	}
+	if common.RedisEnabled && key != "" {
+		gopool.Go(func() {
+			if err := cacheRefundTokenQuota(key, quotaType, int64(quota)); err != nil {
+				common.SysLog("failed to refund token quota cache: " + err.Error())
+			}
+		})
+	}
+	return nil
+}
+
 // IncreaseWindowQuota 增加窗口已用额度（退还额度时使用）
 func IncreaseWindowQuota(id int, key string, quota int) (err error) {
 	if quota < 0 {
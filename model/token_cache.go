@@ -67,6 +67,26 @@ func cacheIncrCycleUsedQuota(key string, increment int64) error {
 	return nil
 }
 
+// cacheRefundTokenQuota 按令牌额度类型同步 Redis 中被退还的额度，与 tokenRefundUpdates 对应
+func cacheRefundTokenQuota(key string, quotaType int, quota int64) error {
+	switch quotaType {
+	case 0:
+		return cacheIncrTokenUsedQuota(key, -quota)
+	case 2:
+		return cacheIncrWindowUsedQuota(key, -quota)
+	case 3:
+		if err := cacheIncrWindowUsedQuota(key, -quota); err != nil {
+			return err
+		}
+		return cacheIncrCycleUsedQuota(key, -quota)
+	default:
+		if err := cacheIncrTokenQuota(key, quota); err != nil {
+			return err
+		}
+		return cacheIncrTokenUsedQuota(key, -quota)
+	}
+}
+
 func cacheSetTokenField(key string, field string, value string) error {
 	key = common.GenerateHMAC(key)
 	err := common.RedisHSetField(fmt.Sprintf("token:%s", key), field, value)
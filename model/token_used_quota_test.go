@@ -160,3 +160,45 @@ func TestBatchUpdateTokenUsedQuotaLeavesRemainQuotaUnchanged(t *testing.T) {
 		t.Fatalf("used quota = %d, want 70", got.UsedQuota)
 	}
 }
+
+func TestRefundTokenQuotaByQuotaType(t *testing.T) {
+	cleanup := setupTokenUsedQuotaTestDB(t)
+	defer cleanup()
+
+	tests := []struct {
+		quotaType                              int
+		remain, used, windowUsed, cycleUsed    int
+		wantRemain, wantUsed, wantW, wantCycle int
+	}{
+		{quotaType: 0, remain: 500, used: 100, wantRemain: 500, wantUsed: 70},
+		{quotaType: 1, remain: 500, used: 100, wantRemain: 530, wantUsed: 70},
+		{quotaType: 2, remain: 500, used: 100, windowUsed: 80, wantRemain: 500, wantUsed: 100, wantW: 50},
+		{quotaType: 3, remain: 500, used: 100, windowUsed: 80, cycleUsed: 90, wantRemain: 500, wantUsed: 100, wantW: 50, wantCycle: 60},
+	}
+	for _, tt := range tests {
+		token := Token{
+			UserId:          1,
+			Key:             fmt.Sprintf("refund-key-%d", tt.quotaType),
+			Name:            "refund",
+			RemainQuota:     tt.remain,
+			UsedQuota:       tt.used,
+			WindowUsedQuota: tt.windowUsed,
+			CycleUsedQuota:  tt.cycleUsed,
+			QuotaType:       tt.quotaType,
+		}
+		if err := DB.Create(&token).Error; err != nil {
+			t.Fatalf("create token: %v", err)
+		}
+		if err := RefundTokenQuota(token.Id, token.Key, tt.quotaType, 30); err != nil {
+			t.Fatalf("quota type %d: refund token quota: %v", tt.quotaType, err)
+		}
+
+		var got Token
+		if err := DB.First(&got, token.Id).Error; err != nil {
+			t.Fatalf("load token: %v", err)
+		}
+		if got.RemainQuota != tt.wantRemain || got.UsedQuota != tt.wantUsed || got.WindowUsedQuota != tt.wantW || got.CycleUsedQuota != tt.wantCycle {
+			t.Fatalf("quota type %d: remain=%d used=%d window=%d cycle=%d", tt.quotaType, got.RemainQuota, got.UsedQuota, got.WindowUsedQuota, got.CycleUsedQuota)
+		}
+	}
+}
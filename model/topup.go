@@ -6,6 +6,7 @@ import (
 
 	"github.com/zhongruan0522/new-api/common"
 	"github.com/zhongruan0522/new-api/logger"
+	"github.com/zhongruan0522/new-api/setting/operation_setting"
 
 	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
@@ -21,7 +22,12 @@ type TopUp struct {
 	PaymentProvider string  `json:"payment_provider" gorm:"type:varchar(50);default:''"`
 	CreateTime      int64   `json:"create_time"`
 	CompleteTime    int64   `json:"complete_time"`
+	RefundTime      int64   `json:"refund_time" gorm:"bigint;default:0"`
 	Status          string  `json:"status"`
+	CouponId        int     `json:"coupon_id" gorm:"index;default:0"`
+	CouponCode      string  `json:"coupon_code" gorm:"type:varchar(64);default:''"`
+	DiscountMoney   float64 `json:"discount_money" gorm:"default:0"` // 优惠券减免的支付金额
+	BonusQuota      int     `json:"bonus_quota" gorm:"default:0"`    // 优惠券赠送的额度，随订单到账与退款
 }
 
 const (
@@ -41,10 +47,17 @@ var (
 	ErrTopUpStatusInvalid      = errors.New("topup status invalid")
 )
 
+// Insert 创建订单；使用优惠券时在同一事务内占用优惠券次数，次数已满时返回 ErrCouponExhausted / ErrCouponUserLimit
 func (topUp *TopUp) Insert() error {
-	var err error
-	err = DB.Create(topUp).Error
-	return err
+	if topUp.CouponId == 0 {
+		return DB.Create(topUp).Error
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := reserveCouponTx(tx, topUp); err != nil {
+			return err
+		}
+		return tx.Create(topUp).Error
+	})
 }
 
 func (topUp *TopUp) Update() error {
@@ -115,6 +128,12 @@ func UpdatePendingTopUpStatus(tradeNo string, expectedPaymentProvider string, ta
 			return err
 		}
 
+		// 订单失败或过期时归还下单时占用的优惠券次数
+		if targetStatus != common.TopUpStatusSuccess {
+			if err := adjustCouponUsedCountTx(tx, topUp.CouponId, -1); err != nil {
+				return err
+			}
+		}
 		topUp.Status = targetStatus
 		return tx.Save(topUp).Error
 	})
@@ -126,6 +145,7 @@ func Recharge(referenceId string, customerId string) (err error) {
 	}
 
 	var quota float64
+	var commissions []*ReferralCommission
 	topUp := &TopUp{}
 
 	refCol := "`trade_no`"
@@ -151,12 +171,13 @@ func Recharge(referenceId string, customerId string) (err error) {
 		}
 
 		quota = topUp.Money * common.QuotaPerUnit
-		err = tx.Model(&User{}).Where("id = ?", topUp.UserId).Updates(map[string]interface{}{"stripe_customer": customerId, "quota": gorm.Expr("quota + ?", quota)}).Error
+		err = tx.Model(&User{}).Where("id = ?", topUp.UserId).Updates(map[string]interface{}{"stripe_customer": customerId, "quota": gorm.Expr("quota + ?", quota+float64(topUp.BonusQuota))}).Error
 		if err != nil {
 			return err
 		}
 
-		return nil
+		commissions, err = createReferralCommissionsTx(tx, topUp, int(quota))
+		return err
 	})
 
 	if err != nil {
@@ -165,6 +186,8 @@ func Recharge(referenceId string, customerId string) (err error) {
 	}
 
 	RecordLog(topUp.UserId, LogTypeTopup, fmt.Sprintf("使用在线充值成功，充值金额: %v，支付金额：%d", logger.FormatQuota(int(quota)), topUp.Amount))
+	recordTopUpBonusLog(topUp.UserId, topUp.CouponCode, topUp.BonusQuota)
+	recordReferralCommissionLogs(commissions)
 
 	return nil
 }
@@ -182,6 +205,9 @@ func CompleteEpayTopUp(tradeNo string, paymentMethod string, paidMoney string) e
 	var userId int
 	var quotaToAdd int
 	var payMoney float64
+	var couponCode string
+	var bonusQuota int
+	var commissions []*ReferralCommission
 
 	err := DB.Transaction(func(tx *gorm.DB) error {
 		topUp := &TopUp{}
@@ -205,12 +231,20 @@ func CompleteEpayTopUp(tradeNo string, paymentMethod string, paidMoney string) e
 			return err
 		}
 
-		if err := tx.Model(&User{}).Where("id = ?", topUp.UserId).Update("quota", gorm.Expr("quota + ?", quotaToAdd)).Error; err != nil {
+		if err := tx.Model(&User{}).Where("id = ?", topUp.UserId).Update("quota", gorm.Expr("quota + ?", quotaToAdd+topUp.BonusQuota)).Error; err != nil {
+			return err
+		}
+
+		var err error
+		commissions, err = createReferralCommissionsTx(tx, topUp, quotaToAdd)
+		if err != nil {
 			return err
 		}
 
 		userId = topUp.UserId
 		payMoney = topUp.Money
+		couponCode = topUp.CouponCode
+		bonusQuota = topUp.BonusQuota
 		return nil
 	})
 	if err != nil {
@@ -218,6 +252,8 @@ func CompleteEpayTopUp(tradeNo string, paymentMethod string, paidMoney string) e
 	}
 
 	RecordLog(userId, LogTypeTopup, fmt.Sprintf("使用在线充值成功，充值金额: %v，支付金额：%f", logger.LogQuota(quotaToAdd), payMoney))
+	recordTopUpBonusLog(userId, couponCode, bonusQuota)
+	recordReferralCommissionLogs(commissions)
 	return nil
 }
 
@@ -388,6 +424,9 @@ func ManualCompleteTopUp(tradeNo string) error {
 	var userId int
 	var quotaToAdd int
 	var payMoney float64
+	var couponCode string
+	var bonusQuota int
+	var commissions []*ReferralCommission
 
 	err := DB.Transaction(func(tx *gorm.DB) error {
 		topUp := &TopUp{}
@@ -405,17 +444,7 @@ func ManualCompleteTopUp(tradeNo string) error {
 			return errors.New("订单状态不是待支付，无法补单")
 		}
 
-		// 计算应充值额度：
-		// - Stripe 订单：Money 代表经分组倍率换算后的美元数量，直接 * QuotaPerUnit
-		// - 其他订单（如易支付）：Amount 为美元数量，* QuotaPerUnit
-		if topUp.PaymentMethod == "stripe" {
-			dQuotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
-			quotaToAdd = int(decimal.NewFromFloat(topUp.Money).Mul(dQuotaPerUnit).IntPart())
-		} else {
-			dAmount := decimal.NewFromInt(topUp.Amount)
-			dQuotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
-			quotaToAdd = int(dAmount.Mul(dQuotaPerUnit).IntPart())
-		}
+		quotaToAdd = getTopUpCreditQuota(topUp)
 		if quotaToAdd <= 0 {
 			return errors.New("无效的充值额度")
 		}
@@ -428,12 +457,20 @@ func ManualCompleteTopUp(tradeNo string) error {
 		}
 
 		// 增加用户额度（立即写库，保持一致性）
-		if err := tx.Model(&User{}).Where("id = ?", topUp.UserId).Update("quota", gorm.Expr("quota + ?", quotaToAdd)).Error; err != nil {
+		if err := tx.Model(&User{}).Where("id = ?", topUp.UserId).Update("quota", gorm.Expr("quota + ?", quotaToAdd+topUp.BonusQuota)).Error; err != nil {
+			return err
+		}
+
+		var err error
+		commissions, err = createReferralCommissionsTx(tx, topUp, quotaToAdd)
+		if err != nil {
 			return err
 		}
 
 		userId = topUp.UserId
 		payMoney = topUp.Money
+		couponCode = topUp.CouponCode
+		bonusQuota = topUp.BonusQuota
 		return nil
 	})
 
@@ -443,5 +480,101 @@ func ManualCompleteTopUp(tradeNo string) error {
 
 	// 事务外记录日志，避免阻塞
 	RecordLog(userId, LogTypeTopup, fmt.Sprintf("管理员补单成功，充值金额: %v，支付金额：%f", logger.FormatQuota(quotaToAdd), payMoney))
+	recordTopUpBonusLog(userId, couponCode, bonusQuota)
+	recordReferralCommissionLogs(commissions)
+	return nil
+}
+
+// recordTopUpBonusLog 记录阶梯赠送与优惠券赠送的额度
+func recordTopUpBonusLog(userId int, couponCode string, bonusQuota int) {
+	if bonusQuota <= 0 {
+		return
+	}
+	if couponCode == "" {
+		RecordLog(userId, LogTypeTopup, fmt.Sprintf("充值赠送额度: %v", logger.FormatQuota(bonusQuota)))
+		return
+	}
+	RecordLog(userId, LogTypeTopup, fmt.Sprintf("充值赠送额度: %v（优惠券 %s）", logger.FormatQuota(bonusQuota), couponCode))
+}
+
+// ApplyBonusTier 按充值数量命中的阶梯赠送比例累加订单赠送额度，需在设置金额与支付方式后调用
+func (topUp *TopUp) ApplyBonusTier() {
+	ratio := operation_setting.GetTopUpBonusRatio(topUp.Amount)
+	if ratio <= 0 {
+		return
+	}
+	bonus := decimal.NewFromInt(int64(getTopUpCreditQuota(topUp))).Mul(decimal.NewFromFloat(ratio)).IntPart()
+	topUp.BonusQuota += int(bonus)
+}
+
+// getTopUpCreditQuota 计算订单到账额度（不含优惠券赠送额度）：
+// - Stripe 订单：Money 代表经分组倍率换算后的美元数量，直接 * QuotaPerUnit
+// - 其他订单（如易支付）：Amount 为美元数量，* QuotaPerUnit
+func getTopUpCreditQuota(topUp *TopUp) int {
+	dQuotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
+	if topUp.PaymentMethod == PaymentMethodStripe {
+		return int(decimal.NewFromFloat(topUp.Money).Mul(dQuotaPerUnit).IntPart())
+	}
+	return int(decimal.NewFromInt(topUp.Amount).Mul(dQuotaPerUnit).IntPart())
+}
+
+// RefundTopUp 管理员将已完成的订单标记为退款：扣回用户到账额度并追回该订单产生的邀请返佣
+func RefundTopUp(tradeNo string, reason string) error {
+	if tradeNo == "" {
+		return errors.New("未提供订单号")
+	}
+
+	refCol := "`trade_no`"
+	if common.UsingPostgreSQL {
+		refCol = `"trade_no"`
+	}
+
+	var userId int
+	var quotaToDeduct int
+	var reversed []*ReferralCommission
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		topUp := &TopUp{}
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").Where(refCol+" = ?", tradeNo).First(topUp).Error; err != nil {
+			return ErrTopUpNotFound
+		}
+		if topUp.Status != common.TopUpStatusSuccess {
+			return ErrTopUpStatusInvalid
+		}
+
+		quotaToDeduct = getTopUpCreditQuota(topUp) + topUp.BonusQuota
+		topUp.RefundTime = common.GetTimestamp()
+		topUp.Status = common.TopUpStatusRefunded
+		if err := tx.Save(topUp).Error; err != nil {
+			return err
+		}
+
+		if quotaToDeduct > 0 {
+			if err := tx.Model(&User{}).Where("id = ?", topUp.UserId).Update("quota", gorm.Expr("quota - ?", quotaToDeduct)).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := adjustCouponUsedCountTx(tx, topUp.CouponId, -1); err != nil {
+			return err
+		}
+
+		var err error
+		reversed, err = reverseReferralCommissionsTx(tx, topUp.TradeNo, reason)
+		if err != nil {
+			return err
+		}
+		userId = topUp.UserId
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_ = InvalidateUserCache(userId)
+	RecordLog(userId, LogTypeRefund, fmt.Sprintf("充值订单 %s 已退款，扣回额度: %v", tradeNo, logger.FormatQuota(quotaToDeduct)))
+	for _, commission := range reversed {
+		RecordLog(commission.InviterId, LogTypeSystem, fmt.Sprintf("邀请用户充值退款，追回返佣 %s（订单 %s）", logger.LogQuota(commission.Quota), commission.TradeNo))
+	}
 	return nil
 }
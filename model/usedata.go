@@ -32,6 +32,7 @@ func UpdateQuotaData() {
 		if common.DataExportEnabled && time.Since(lastUpdatedAt) >= interval {
 			common.SysLog("正在更新数据看板数据...")
 			SaveQuotaDataCache()
+			SaveCacheUsageDataCache()
 			lastUpdatedAt = time.Now()
 		}
 		time.Sleep(time.Second)
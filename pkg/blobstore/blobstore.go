@@ -0,0 +1,54 @@
+// Package blobstore provides content-addressed storage backends for large
+// binary assets (stored images/videos) so that only metadata stays in the DB.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Store is a minimal key/value blob backend. Keys are content addresses built
+// by ContentKey, so Put is idempotent for identical data.
+type Store interface {
+	Name() string
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Presigner is implemented by backends that can hand out time-limited direct
+// download URLs, allowing callers to redirect instead of proxying bytes.
+type Presigner interface {
+	PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// ContentKey builds the content address for a blob, e.g. "images/ab/ab12...".
+// The two-character fan-out keeps local directories small.
+func ContentKey(kind string, sha256Hex string) (string, error) {
+	sha256Hex = strings.ToLower(strings.TrimSpace(sha256Hex))
+	if len(sha256Hex) != 64 {
+		return "", fmt.Errorf("invalid sha256: %q", sha256Hex)
+	}
+	for _, r := range sha256Hex {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f') {
+			return "", fmt.Errorf("invalid sha256: %q", sha256Hex)
+		}
+	}
+	kind = strings.Trim(strings.TrimSpace(kind), "/")
+	if kind == "" || strings.Contains(kind, "..") {
+		return "", fmt.Errorf("invalid blob kind: %q", kind)
+	}
+	return kind + "/" + sha256Hex[:2] + "/" + sha256Hex, nil
+}
+
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "..") || strings.Contains(key, "\\") {
+		return fmt.Errorf("invalid blob key: %q", key)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testSha = "ab0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcd"
+
+func TestContentKey(t *testing.T) {
+	key, err := ContentKey("images", strings.ToUpper(testSha))
+	if err != nil {
+		t.Fatalf("ContentKey returned error: %v", err)
+	}
+	if key != "images/ab/"+testSha {
+		t.Fatalf("ContentKey = %q", key)
+	}
+	if _, err := ContentKey("images", "../etc/passwd"); err == nil {
+		t.Fatal("expected invalid sha to be rejected")
+	}
+	if _, err := ContentKey("../images", testSha); err == nil {
+		t.Fatal("expected invalid kind to be rejected")
+	}
+}
+
+func TestLocalStoreRoundTrip(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore returned error: %v", err)
+	}
+	ctx := context.Background()
+	key, _ := ContentKey("videos", testSha)
+
+	if _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get missing blob error = %v, want ErrNotFound", err)
+	}
+	if err := store.Put(ctx, key, []byte("hello"), "text/plain"); err != nil {
+		t.Fatalf("Put returned error: %v", err)
+	}
+	data, err := store.Get(ctx, key)
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("Get = (%q, %v)", data, err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete returned error: %v", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete of missing blob returned error: %v", err)
+	}
+}
+
+func TestS3StorePathStyle(t *testing.T) {
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=ak/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = body
+		case http.MethodGet:
+			body, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(body)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	store, err := NewS3Store(S3Config{
+		Endpoint:       server.URL,
+		PublicEndpoint: "https://cdn.example.com",
+		Bucket:         "media",
+		AccessKey:      "ak",
+		SecretKey:      "sk",
+		PathStyle:      true,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Store returned error: %v", err)
+	}
+	ctx := context.Background()
+	key, _ := ContentKey("images", testSha)
+
+	if err := store.Put(ctx, key, []byte("png"), "image/png"); err != nil {
+		t.Fatalf("Put returned error: %v", err)
+	}
+	if _, ok := objects["/media/"+key]; !ok {
+		t.Fatalf("object not stored under path-style key, got %v", objects)
+	}
+	data, err := store.Get(ctx, key)
+	if err != nil || string(data) != "png" {
+		t.Fatalf("Get = (%q, %v)", data, err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete returned error: %v", err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after delete error = %v, want ErrNotFound", err)
+	}
+
+	signed, err := store.PresignGet(ctx, key, time.Hour)
+	if err != nil {
+		t.Fatalf("PresignGet returned error: %v", err)
+	}
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("invalid presigned url: %v", err)
+	}
+	if u.Host != "cdn.example.com" || u.Path != "/media/"+key {
+		t.Fatalf("presigned url = %s", signed)
+	}
+	query := u.Query()
+	if query.Get("X-Amz-Expires") != "3600" || query.Get("X-Amz-Signature") == "" {
+		t.Fatalf("presigned query = %v", query)
+	}
+}
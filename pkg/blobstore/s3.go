@@ -0,0 +1,184 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+const (
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	s3MaxPresignTTL   = 7 * 24 * time.Hour
+)
+
+// S3Config describes an S3-compatible bucket (AWS S3, MinIO, R2, ...).
+type S3Config struct {
+	Endpoint  string // e.g. https://s3.us-east-1.amazonaws.com or http://minio:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle addresses objects as {endpoint}/{bucket}/{key}; required by most MinIO setups.
+	PathStyle bool
+	// PublicEndpoint, if set, is used for presigned URLs so clients can reach the
+	// bucket through a different host than the server does.
+	PublicEndpoint string
+}
+
+// S3Store talks to an S3-compatible API using SigV4-signed plain HTTP requests.
+type S3Store struct {
+	config S3Config
+	signer *v4.Signer
+	client *http.Client
+}
+
+func NewS3Store(config S3Config) (*S3Store, error) {
+	config.Endpoint = strings.TrimRight(strings.TrimSpace(config.Endpoint), "/")
+	config.PublicEndpoint = strings.TrimRight(strings.TrimSpace(config.PublicEndpoint), "/")
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, errors.New("s3 endpoint and bucket are required")
+	}
+	if config.AccessKey == "" || config.SecretKey == "" {
+		return nil, errors.New("s3 access key and secret key are required")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	if _, err := url.Parse(config.Endpoint); err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+	return &S3Store{
+		config: config,
+		signer: v4.NewSigner(func(o *v4.SignerOptions) {
+			o.DisableURIPathEscaping = true
+		}),
+		client: &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+func (s *S3Store) Name() string {
+	return "s3"
+}
+
+func (s *S3Store) credentials() aws.Credentials {
+	return aws.Credentials{AccessKeyID: s.config.AccessKey, SecretAccessKey: s.config.SecretKey}
+}
+
+func (s *S3Store) objectURL(endpoint string, key string) (*url.URL, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if s.config.PathStyle {
+		u.Path = strings.TrimRight(u.Path, "/") + "/" + s.config.Bucket + "/" + key
+	} else {
+		u.Host = s.config.Bucket + "." + u.Host
+		u.Path = strings.TrimRight(u.Path, "/") + "/" + key
+	}
+	return u, nil
+}
+
+func (s *S3Store) do(ctx context.Context, method string, key string, body []byte, contentType string) (*http.Response, error) {
+	u, err := s.objectURL(s.config.Endpoint, key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.ContentLength = int64(len(body))
+	if err := s.signer.SignHTTP(ctx, s.credentials(), req, payloadHash, "s3", s.config.Region, time.Now()); err != nil {
+		return nil, err
+	}
+	return s.client.Do(req)
+}
+
+func s3ResponseError(op string, resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("s3 %s failed: status %d: %s", op, resp.StatusCode, strings.TrimSpace(string(msg)))
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	resp, err := s.do(ctx, http.MethodPut, key, data, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return s3ResponseError("put", resp)
+	}
+	return nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, s3ResponseError("get", resp)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		return s3ResponseError("delete", resp)
+	}
+	return nil
+}
+
+func (s *S3Store) PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if ttl <= 0 {
+		ttl = 15 * time.Minute
+	}
+	if ttl > s3MaxPresignTTL {
+		ttl = s3MaxPresignTTL
+	}
+	endpoint := s.config.Endpoint
+	if s.config.PublicEndpoint != "" {
+		endpoint = s.config.PublicEndpoint
+	}
+	u, err := s.objectURL(endpoint, key)
+	if err != nil {
+		return "", err
+	}
+	u.RawQuery = url.Values{"X-Amz-Expires": []string{strconv.Itoa(int(ttl.Seconds()))}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	signed, _, err := s.signer.PresignHTTP(ctx, s.credentials(), req, s3UnsignedPayload, "s3", s.config.Region, time.Now())
+	return signed, err
+}
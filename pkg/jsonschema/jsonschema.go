@@ -0,0 +1,397 @@
+// Package jsonschema validates decoded JSON values against the subset of JSON
+// Schema used by OpenAI structured outputs and function parameters:
+//
+//   - type (a single name or a list), enum, const, nullable
+//   - properties, required, additionalProperties (bool or schema)
+//   - items, minItems, maxItems
+//   - minLength, maxLength, pattern
+//   - minimum, maximum, exclusiveMinimum, exclusiveMaximum, multipleOf
+//   - anyOf, oneOf, allOf
+//   - local $ref pointers such as "#/$defs/Step" or "#/definitions/Step"
+//
+// Keywords outside this subset (format, dependentSchemas, ...) are ignored.
+// Values are expected to come from encoding/json, i.e. objects decode to
+// map[string]any, arrays to []any and numbers to float64.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxRefDepth bounds $ref resolution so recursive schemas cannot loop forever.
+const maxRefDepth = 64
+
+// ValidationError describes the first mismatch found, with a JSON pointer to
+// the offending value.
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return "$: " + e.Message
+	}
+	return "$" + e.Path + ": " + e.Message
+}
+
+// ValidateJSON decodes instance and validates it against the raw schema.
+func ValidateJSON(schema []byte, instance []byte) error {
+	var schemaValue any
+	if err := json.Unmarshal(schema, &schemaValue); err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	var instanceValue any
+	if err := json.Unmarshal(instance, &instanceValue); err != nil {
+		return &ValidationError{Message: "invalid JSON: " + err.Error()}
+	}
+	return Validate(schemaValue, instanceValue)
+}
+
+// Validate checks a decoded instance against a decoded schema.
+func Validate(schema any, instance any) error {
+	v := &validator{root: schema}
+	return v.validate(schema, instance, "", 0)
+}
+
+type validator struct {
+	root any
+}
+
+func (v *validator) validate(schema any, instance any, path string, depth int) error {
+	switch s := schema.(type) {
+	case bool:
+		if !s {
+			return &ValidationError{Path: path, Message: "no value is allowed here"}
+		}
+		return nil
+	case map[string]any:
+		return v.validateObjectSchema(s, instance, path, depth)
+	case nil:
+		return nil
+	default:
+		return fmt.Errorf("invalid schema at %q: expected object or boolean", path)
+	}
+}
+
+func (v *validator) validateObjectSchema(schema map[string]any, instance any, path string, depth int) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		if depth >= maxRefDepth {
+			return fmt.Errorf("schema $ref nesting exceeds %d", maxRefDepth)
+		}
+		resolved, err := v.resolveRef(ref)
+		if err != nil {
+			return err
+		}
+		if err := v.validate(resolved, instance, path, depth+1); err != nil {
+			return err
+		}
+	}
+
+	if nullable, _ := schema["nullable"].(bool); nullable && instance == nil {
+		return nil
+	}
+	if types, ok := schemaTypes(schema["type"]); ok && !matchesAnyType(instance, types) {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("expected %s, got %s", strings.Join(types, " or "), typeName(instance))}
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		matched := false
+		for _, candidate := range enum {
+			if jsonEqual(candidate, instance) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("value %s is not one of the allowed values", compact(instance))}
+		}
+	}
+	if constant, ok := schema["const"]; ok && !jsonEqual(constant, instance) {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("value must be %s", compact(constant))}
+	}
+
+	switch value := instance.(type) {
+	case map[string]any:
+		if err := v.validateObject(schema, value, path, depth); err != nil {
+			return err
+		}
+	case []any:
+		if err := v.validateArray(schema, value, path, depth); err != nil {
+			return err
+		}
+	case string:
+		if err := validateString(schema, value, path); err != nil {
+			return err
+		}
+	case float64:
+		if err := validateNumber(schema, value, path); err != nil {
+			return err
+		}
+	}
+
+	if allOf, ok := schema["allOf"].([]any); ok {
+		for _, sub := range allOf {
+			if err := v.validate(sub, instance, path, depth); err != nil {
+				return err
+			}
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		var firstErr error
+		matched := false
+		for _, sub := range anyOf {
+			err := v.validate(sub, instance, path, depth)
+			if err == nil {
+				matched = true
+				break
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		if !matched {
+			return &ValidationError{Path: path, Message: "value does not match any allowed schema (first mismatch: " + errorMessage(firstErr) + ")"}
+		}
+	}
+	if oneOf, ok := schema["oneOf"].([]any); ok {
+		matches := 0
+		for _, sub := range oneOf {
+			if v.validate(sub, instance, path, depth) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("value must match exactly one schema, matched %d", matches)}
+		}
+	}
+	return nil
+}
+
+func (v *validator) validateObject(schema map[string]any, value map[string]any, path string, depth int) error {
+	properties, _ := schema["properties"].(map[string]any)
+	if required, ok := schema["required"].([]any); ok {
+		for _, item := range required {
+			name, _ := item.(string)
+			if _, exists := value[name]; name != "" && !exists {
+				return &ValidationError{Path: path, Message: fmt.Sprintf("missing required property %q", name)}
+			}
+		}
+	}
+
+	// 按键排序，保证同一输入总是报告同一个错误
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		childPath := path + "/" + escapePointer(key)
+		if propertySchema, ok := properties[key]; ok {
+			if err := v.validate(propertySchema, value[key], childPath, depth); err != nil {
+				return err
+			}
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return &ValidationError{Path: path, Message: fmt.Sprintf("unexpected property %q", key)}
+			}
+		case map[string]any:
+			if err := v.validate(additional, value[key], childPath, depth); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *validator) validateArray(schema map[string]any, value []any, path string, depth int) error {
+	if minItems, ok := number(schema["minItems"]); ok && float64(len(value)) < minItems {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("expected at least %v items, got %d", minItems, len(value))}
+	}
+	if maxItems, ok := number(schema["maxItems"]); ok && float64(len(value)) > maxItems {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("expected at most %v items, got %d", maxItems, len(value))}
+	}
+	if items, ok := schema["items"]; ok {
+		for i, item := range value {
+			if err := v.validate(items, item, path+"/"+strconv.Itoa(i), depth); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateString(schema map[string]any, value string, path string) error {
+	length := float64(len([]rune(value)))
+	if minLength, ok := number(schema["minLength"]); ok && length < minLength {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("string is shorter than %v characters", minLength)}
+	}
+	if maxLength, ok := number(schema["maxLength"]); ok && length > maxLength {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("string is longer than %v characters", maxLength)}
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid schema pattern %q: %w", pattern, err)
+		}
+		if !re.MatchString(value) {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("string does not match pattern %q", pattern)}
+		}
+	}
+	return nil
+}
+
+func validateNumber(schema map[string]any, value float64, path string) error {
+	if minimum, ok := number(schema["minimum"]); ok && value < minimum {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("value %v is less than minimum %v", value, minimum)}
+	}
+	if maximum, ok := number(schema["maximum"]); ok && value > maximum {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("value %v is greater than maximum %v", value, maximum)}
+	}
+	if exclusiveMinimum, ok := number(schema["exclusiveMinimum"]); ok && value <= exclusiveMinimum {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("value %v must be greater than %v", value, exclusiveMinimum)}
+	}
+	if exclusiveMaximum, ok := number(schema["exclusiveMaximum"]); ok && value >= exclusiveMaximum {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("value %v must be less than %v", value, exclusiveMaximum)}
+	}
+	if multipleOf, ok := number(schema["multipleOf"]); ok && multipleOf > 0 {
+		quotient := value / multipleOf
+		if math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("value %v is not a multiple of %v", value, multipleOf)}
+		}
+	}
+	return nil
+}
+
+func (v *validator) resolveRef(ref string) (any, error) {
+	if ref == "#" {
+		return v.root, nil
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported schema $ref %q: only local references are supported", ref)
+	}
+	current := v.root
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("schema $ref %q cannot be resolved", ref)
+		}
+		if current, ok = object[token]; !ok {
+			return nil, fmt.Errorf("schema $ref %q cannot be resolved", ref)
+		}
+	}
+	return current, nil
+}
+
+func schemaTypes(raw any) ([]string, bool) {
+	switch t := raw.(type) {
+	case string:
+		return []string{t}, true
+	case []any:
+		types := make([]string, 0, len(t))
+		for _, item := range t {
+			if name, ok := item.(string); ok {
+				types = append(types, name)
+			}
+		}
+		return types, len(types) > 0
+	}
+	return nil, false
+}
+
+func matchesAnyType(instance any, types []string) bool {
+	for _, name := range types {
+		if matchesType(instance, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesType(instance any, name string) bool {
+	switch name {
+	case "object":
+		_, ok := instance.(map[string]any)
+		return ok
+	case "array":
+		_, ok := instance.([]any)
+		return ok
+	case "string":
+		_, ok := instance.(string)
+		return ok
+	case "number":
+		_, ok := instance.(float64)
+		return ok
+	case "integer":
+		value, ok := instance.(float64)
+		return ok && value == math.Trunc(value)
+	case "boolean":
+		_, ok := instance.(bool)
+		return ok
+	case "null":
+		return instance == nil
+	}
+	return false
+}
+
+func typeName(instance any) string {
+	switch value := instance.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		if value == math.Trunc(value) {
+			return "integer"
+		}
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", instance)
+}
+
+func number(raw any) (float64, bool) {
+	value, ok := raw.(float64)
+	return value, ok
+}
+
+func jsonEqual(a, b any) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+func compact(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	if len(data) > 80 {
+		return string(data[:80]) + "..."
+	}
+	return string(data)
+}
+
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
+
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,70 @@
+package jsonschema
+
+import (
+	"strings"
+	"testing"
+)
+
+const stepsSchema = `{
+	"type": "object",
+	"properties": {
+		"title": {"type": "string", "minLength": 1},
+		"steps": {"type": "array", "items": {"$ref": "#/$defs/step"}, "minItems": 1},
+		"status": {"enum": ["draft", "done"]},
+		"score": {"type": ["number", "null"], "minimum": 0, "maximum": 1}
+	},
+	"required": ["title", "steps"],
+	"additionalProperties": false,
+	"$defs": {
+		"step": {
+			"type": "object",
+			"properties": {
+				"id": {"type": "integer"},
+				"note": {"anyOf": [{"type": "string", "pattern": "^[a-z ]+$"}, {"type": "null"}]}
+			},
+			"required": ["id"]
+		}
+	}
+}`
+
+func TestValidateJSONAcceptsMatchingInstance(t *testing.T) {
+	instance := `{"title":"plan","steps":[{"id":1,"note":"first step"},{"id":2,"note":null}],"status":"done","score":null}`
+	if err := ValidateJSON([]byte(stepsSchema), []byte(instance)); err != nil {
+		t.Fatalf("ValidateJSON returned error: %v", err)
+	}
+}
+
+func TestValidateJSONReportsFirstMismatch(t *testing.T) {
+	cases := []struct {
+		name     string
+		instance string
+		want     string
+	}{
+		{"missing required", `{"title":"plan"}`, `$: missing required property "steps"`},
+		{"extra property", `{"title":"plan","steps":[{"id":1}],"extra":true}`, `unexpected property "extra"`},
+		{"integer via ref", `{"title":"plan","steps":[{"id":1.5}]}`, `$/steps/0/id: expected integer, got number`},
+		{"enum", `{"title":"plan","steps":[{"id":1}],"status":"open"}`, `$/status: value "open" is not one of the allowed values`},
+		{"maximum", `{"title":"plan","steps":[{"id":1}],"score":2}`, `$/score: value 2 is greater than maximum 1`},
+		{"anyOf", `{"title":"plan","steps":[{"id":1,"note":"UPPER"}]}`, `$/steps/0/note: value does not match any allowed schema`},
+		{"minItems", `{"title":"plan","steps":[]}`, `$/steps: expected at least 1 items, got 0`},
+		{"invalid json", `{"title":`, `invalid JSON`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateJSON([]byte(stepsSchema), []byte(tc.instance))
+			if err == nil {
+				t.Fatal("expected validation error")
+			}
+			if !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("error = %q, want it to contain %q", err.Error(), tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateRejectsUnresolvableRef(t *testing.T) {
+	err := ValidateJSON([]byte(`{"$ref":"https://example.com/schema.json"}`), []byte(`{}`))
+	if err == nil || !strings.Contains(err.Error(), "only local references") {
+		t.Fatalf("expected unsupported $ref error, got %v", err)
+	}
+}
@@ -0,0 +1,274 @@
+// Package scripthook runs small sandboxed Starlark scripts that rewrite relay
+// payloads (converted requests, response bodies and SSE events) per channel.
+//
+// A script defines any of the hook functions below. Each receives the payload
+// (decoded JSON when possible, otherwise a string) and a frozen context dict:
+//
+//	def transform_request(body, ctx):
+//	    body["temperature"] = 0.2
+//
+//	def transform_event(data, ctx):
+//	    if data == "[PING]":
+//	        return ""  # drop the event
+//
+// Returning None keeps the (possibly mutated) payload; returning a string
+// replaces it verbatim; returning a dict or list replaces it with its JSON
+// encoding. Scripts have no I/O, and every run is bounded by Limits.
+package scripthook
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	starlarkjson "go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+const (
+	HookRequest  = "transform_request"
+	HookResponse = "transform_response"
+	HookEvent    = "transform_event"
+)
+
+// Hooks lists every hook name a script may define.
+var Hooks = []string{HookRequest, HookResponse, HookEvent}
+
+// Limits bounds a single hook invocation. Zero values disable the limit.
+type Limits struct {
+	MaxSteps uint64
+	Timeout  time.Duration
+}
+
+// Result is the outcome of a hook invocation.
+type Result struct {
+	Output   []byte
+	Dropped  bool // the hook returned "" for an event
+	Steps    uint64
+	Duration time.Duration
+}
+
+// Program is a compiled script. It is safe for concurrent use; each Run
+// executes the top level in a fresh thread so no state leaks between runs.
+type Program struct {
+	prog  *starlark.Program
+	hooks map[string]bool
+}
+
+var fileOptions = &syntax.FileOptions{
+	Set:             true,
+	While:           true,
+	TopLevelControl: true,
+	GlobalReassign:  true,
+}
+
+var predeclared = starlark.StringDict{
+	"json":     starlarkjson.Module,
+	"re_match": starlark.NewBuiltin("re_match", reMatch),
+	"re_sub":   starlark.NewBuiltin("re_sub", reSub),
+}
+
+// Compile parses and resolves src. Results are cached by content hash.
+func Compile(src string) (*Program, error) {
+	key := sha256.Sum256([]byte(src))
+	cacheMu.Lock()
+	cached, ok := cache[key]
+	cacheMu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	file, prog, err := starlark.SourceProgramOptions(fileOptions, "script.star", src, predeclared.Has)
+	if err != nil {
+		return nil, err
+	}
+	p := &Program{prog: prog, hooks: make(map[string]bool)}
+	for _, stmt := range file.Stmts {
+		if def, ok := stmt.(*syntax.DefStmt); ok {
+			for _, hook := range Hooks {
+				if def.Name.Name == hook {
+					p.hooks[hook] = true
+				}
+			}
+		}
+	}
+	if len(p.hooks) == 0 {
+		return nil, fmt.Errorf("script defines none of %v", Hooks)
+	}
+
+	cacheMu.Lock()
+	if len(cache) >= maxCachedPrograms {
+		cache = make(map[[32]byte]*Program)
+	}
+	cache[key] = p
+	cacheMu.Unlock()
+	return p, nil
+}
+
+const maxCachedPrograms = 256
+
+var (
+	cacheMu sync.Mutex
+	cache   = make(map[[32]byte]*Program)
+)
+
+// Has reports whether the script defines hook.
+func (p *Program) Has(hook string) bool {
+	return p != nil && p.hooks[hook]
+}
+
+// Run invokes hook with payload and ctx. A script that does not define hook
+// returns the payload unchanged.
+func (p *Program) Run(hook string, payload []byte, ctx map[string]any, limits Limits) (*Result, error) {
+	if !p.Has(hook) {
+		return &Result{Output: payload}, nil
+	}
+
+	thread := &starlark.Thread{
+		Name:  hook,
+		Print: func(*starlark.Thread, string) {},
+		Load: func(*starlark.Thread, string) (starlark.StringDict, error) {
+			return nil, errors.New("load is not allowed")
+		},
+	}
+	if limits.MaxSteps > 0 {
+		thread.SetMaxExecutionSteps(limits.MaxSteps)
+	}
+	if limits.Timeout > 0 {
+		timer := time.AfterFunc(limits.Timeout, func() {
+			thread.Cancel(fmt.Sprintf("timeout after %s", limits.Timeout))
+		})
+		defer timer.Stop()
+	}
+
+	start := time.Now()
+	result := &Result{}
+	finish := func() {
+		result.Steps = thread.ExecutionSteps()
+		result.Duration = time.Since(start)
+	}
+
+	globals, err := p.prog.Init(thread, predeclared)
+	if err != nil {
+		finish()
+		return result, describeError(err)
+	}
+	fn, ok := globals[hook].(starlark.Callable)
+	if !ok {
+		finish()
+		return result, fmt.Errorf("%s is not a function", hook)
+	}
+
+	arg, decoded, err := toStarlark(thread, payload)
+	if err != nil {
+		finish()
+		return result, err
+	}
+	ctxValue, _, err := toStarlark(thread, mustMarshal(ctx))
+	if err != nil {
+		finish()
+		return result, err
+	}
+	ctxValue.Freeze()
+
+	ret, err := starlark.Call(thread, fn, starlark.Tuple{arg, ctxValue}, nil)
+	if err != nil {
+		finish()
+		return result, describeError(err)
+	}
+
+	switch v := ret.(type) {
+	case starlark.NoneType:
+		if decoded {
+			result.Output, err = fromStarlark(thread, arg)
+		} else {
+			result.Output = payload
+		}
+	case starlark.String:
+		result.Output = []byte(string(v))
+		result.Dropped = hook == HookEvent && len(v) == 0
+	case *starlark.Dict, *starlark.List:
+		result.Output, err = fromStarlark(thread, v)
+	default:
+		err = fmt.Errorf("%s returned %s, want None, string, dict or list", hook, ret.Type())
+	}
+	finish()
+	return result, err
+}
+
+// toStarlark decodes JSON objects and arrays; any other payload is passed as a string.
+func toStarlark(thread *starlark.Thread, payload []byte) (starlark.Value, bool, error) {
+	trimmed := bytes.TrimSpace(payload)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') || !json.Valid(trimmed) {
+		return starlark.String(payload), false, nil
+	}
+	decode := starlarkjson.Module.Members["decode"]
+	v, err := starlark.Call(thread, decode, starlark.Tuple{starlark.String(trimmed)}, nil)
+	if err != nil {
+		return nil, false, describeError(err)
+	}
+	return v, true, nil
+}
+
+func fromStarlark(thread *starlark.Thread, v starlark.Value) ([]byte, error) {
+	encode := starlarkjson.Module.Members["encode"]
+	out, err := starlark.Call(thread, encode, starlark.Tuple{v}, nil)
+	if err != nil {
+		return nil, describeError(err)
+	}
+	s, ok := out.(starlark.String)
+	if !ok {
+		return nil, fmt.Errorf("json.encode returned %s", out.Type())
+	}
+	return []byte(string(s)), nil
+}
+
+func mustMarshal(v any) []byte {
+	if v == nil {
+		return []byte("{}")
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return []byte("{}")
+	}
+	return data
+}
+
+// describeError keeps the Starlark backtrace, which points at the failing line.
+func describeError(err error) error {
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) {
+		return errors.New(evalErr.Backtrace())
+	}
+	return err
+}
+
+func reMatch(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern, s string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &pattern, &s); err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	return starlark.Bool(re.MatchString(s)), nil
+}
+
+func reSub(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern, repl, s string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 3, &pattern, &repl, &s); err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	return starlark.String(re.ReplaceAllString(s, repl)), nil
+}
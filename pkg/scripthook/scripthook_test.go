@@ -0,0 +1,119 @@
+package scripthook
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunMutatesJSONPayloadInPlace(t *testing.T) {
+	prog, err := Compile(`
+def transform_request(body, ctx):
+    body["model"] = ctx["upstream_model"]
+    body.pop("user")
+`)
+	if err != nil {
+		t.Fatalf("Compile returned error: %v", err)
+	}
+	res, err := prog.Run(HookRequest, []byte(`{"model":"a","user":"u1"}`), map[string]any{"upstream_model": "b"}, Limits{})
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if string(res.Output) != `{"model":"b"}` {
+		t.Fatalf("Output = %s", res.Output)
+	}
+	if res.Steps == 0 {
+		t.Fatal("expected execution steps to be reported")
+	}
+}
+
+func TestRunReturnValues(t *testing.T) {
+	prog, err := Compile(`
+def transform_event(data, ctx):
+    if data == ": keepalive":
+        return ""
+    if type(data) == "string":
+        return re_sub("foo", "bar", data)
+    return {"wrapped": data}
+`)
+	if err != nil {
+		t.Fatalf("Compile returned error: %v", err)
+	}
+	if prog.Has(HookRequest) || !prog.Has(HookEvent) {
+		t.Fatalf("unexpected hooks: %v", prog.hooks)
+	}
+
+	res, err := prog.Run(HookEvent, []byte(": keepalive"), nil, Limits{})
+	if err != nil || !res.Dropped {
+		t.Fatalf("expected event to be dropped, got %+v err=%v", res, err)
+	}
+	res, err = prog.Run(HookEvent, []byte("foo!"), nil, Limits{})
+	if err != nil || string(res.Output) != "bar!" {
+		t.Fatalf("expected string replacement, got %q err=%v", res.Output, err)
+	}
+	res, err = prog.Run(HookEvent, []byte(`[1,2]`), nil, Limits{})
+	if err != nil || string(res.Output) != `{"wrapped":[1,2]}` {
+		t.Fatalf("expected wrapped json, got %s err=%v", res.Output, err)
+	}
+	res, err = prog.Run(HookRequest, []byte(`{"a":1}`), nil, Limits{})
+	if err != nil || string(res.Output) != `{"a":1}` {
+		t.Fatalf("undefined hook should pass through, got %s err=%v", res.Output, err)
+	}
+}
+
+func TestRunEnforcesLimits(t *testing.T) {
+	prog, err := Compile(`
+def transform_response(body, ctx):
+    n = 0
+    while True:
+        n += 1
+`)
+	if err != nil {
+		t.Fatalf("Compile returned error: %v", err)
+	}
+	_, err = prog.Run(HookResponse, []byte(`{}`), nil, Limits{MaxSteps: 10000})
+	if err == nil || !strings.Contains(err.Error(), "too many steps") {
+		t.Fatalf("expected step limit error, got %v", err)
+	}
+	start := time.Now()
+	_, err = prog.Run(HookResponse, []byte(`{}`), nil, Limits{Timeout: 20 * time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatalf("timeout was not enforced promptly: %s", time.Since(start))
+	}
+}
+
+func TestCompileRejectsInvalidScripts(t *testing.T) {
+	if _, err := Compile(`def transform_request(body, ctx) return body`); err == nil {
+		t.Fatal("expected syntax error")
+	}
+	if _, err := Compile(`def other(body, ctx): pass`); err == nil {
+		t.Fatal("expected error for script without hooks")
+	}
+	if _, err := Compile("load('x.star', 'y')\ndef transform_request(body, ctx): pass"); err != nil {
+		t.Fatalf("load statements resolve at compile time: %v", err)
+	}
+}
+
+func TestRunRejectsLoadAndContextMutation(t *testing.T) {
+	prog, err := Compile("load('x.star', 'y')\ndef transform_request(body, ctx): pass")
+	if err != nil {
+		t.Fatalf("Compile returned error: %v", err)
+	}
+	if _, err := prog.Run(HookRequest, []byte(`{}`), nil, Limits{}); err == nil {
+		t.Fatal("expected load to fail at run time")
+	}
+
+	prog, err = Compile(`
+def transform_request(body, ctx):
+    ctx["model"] = "x"
+`)
+	if err != nil {
+		t.Fatalf("Compile returned error: %v", err)
+	}
+	if _, err := prog.Run(HookRequest, []byte(`{}`), map[string]any{"model": "a"}, Limits{}); err == nil || !strings.Contains(err.Error(), "frozen") {
+		t.Fatalf("expected frozen ctx error, got %v", err)
+	}
+}
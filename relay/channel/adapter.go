@@ -1,6 +1,7 @@
 package channel
 
 import (
+	"context"
 	"io"
 	"net/http"
 
@@ -29,3 +30,22 @@ type Adaptor interface {
 	ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeRequest) (any, error)
 	ConvertGeminiRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeminiChatRequest) (any, error)
 }
+
+// TaskInfo is the upstream view of an asynchronous task after submit or poll.
+type TaskInfo struct {
+	UpstreamTaskId string
+	Status         string // one of dto.VideoStatus*
+	Progress       int
+	FailReason     string
+}
+
+// TaskAdaptor relays asynchronous generation APIs: submit once in the request
+// context, then poll and fetch the result from the background poller.
+type TaskAdaptor interface {
+	Init(info *relaycommon.RelayInfo)
+	SubmitTask(c *gin.Context, info *relaycommon.RelayInfo, request *dto.VideoRequest) (*http.Response, error)
+	ParseSubmitResponse(resp *http.Response) (*TaskInfo, error)
+	FetchTask(ctx context.Context, info *relaycommon.RelayInfo, upstreamTaskId string) (*TaskInfo, error)
+	FetchContent(ctx context.Context, info *relaycommon.RelayInfo, upstreamTaskId string) ([]byte, string, error)
+	GetChannelName() string
+}
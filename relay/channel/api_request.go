@@ -23,8 +23,21 @@ import (
 	"github.com/bytedance/gopkg/util/gopool"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/tidwall/gjson"
 )
 
+// GetRequestBodyField 从原始请求体读取渠道专有字段，这些字段不声明在通用 dto 中，避免透传给其它上游
+func GetRequestBodyField(c *gin.Context, path string) gjson.Result {
+	if c == nil {
+		return gjson.Result{}
+	}
+	body, err := common2.GetRequestBody(c)
+	if err != nil {
+		return gjson.Result{}
+	}
+	return gjson.GetBytes(body, path)
+}
+
 func SetupApiRequestHeader(info *common.RelayInfo, c *gin.Context, req *http.Header) {
 	if info.RelayMode == constant.RelayModeAudioTranscription || info.RelayMode == constant.RelayModeAudioTranslation {
 		// multipart/form-data
@@ -40,8 +40,8 @@ func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInf
 }
 
 func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
-	//TODO implement me
-	return nil, errors.New("not implemented")
+	// Claude 没有图像生成模型，Images API 请求应分配到其他渠道
+	return nil, errors.New("image generation is not supported by claude")
 }
 
 func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
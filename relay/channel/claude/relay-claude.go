@@ -14,6 +14,7 @@ import (
 	"github.com/zhongruan0522/new-api/relay/channel/openrouter"
 	relaycommon "github.com/zhongruan0522/new-api/relay/common"
 	"github.com/zhongruan0522/new-api/relay/helper"
+	"github.com/zhongruan0522/new-api/relay/reasoning"
 	"github.com/zhongruan0522/new-api/relay/reasonmap"
 	"github.com/zhongruan0522/new-api/service"
 	"github.com/zhongruan0522/new-api/setting/model_setting"
@@ -143,28 +144,11 @@ func RequestOpenAI2ClaudeMessage(c *gin.Context, textRequest dto.GeneralOpenAIRe
 		claudeRequest.MaxTokens = uint(model_setting.GetClaudeSettings().GetDefaultMaxTokens(textRequest.Model))
 	}
 
-	if textRequest.ReasoningEffort != "" {
-		switch textRequest.ReasoningEffort {
-		case "low":
-			claudeRequest.Thinking = &dto.Thinking{
-				Type:         "enabled",
-				BudgetTokens: common.GetPointer[int](1280),
-			}
-		case "medium":
-			claudeRequest.Thinking = &dto.Thinking{
-				Type:         "enabled",
-				BudgetTokens: common.GetPointer[int](2048),
-			}
-		case "high":
-			claudeRequest.Thinking = &dto.Thinking{
-				Type:         "enabled",
-				BudgetTokens: common.GetPointer[int](4096),
-			}
-		case "xhigh", "max":
-			claudeRequest.Thinking = &dto.Thinking{
-				Type:         "enabled",
-				BudgetTokens: common.GetPointer[int](8192),
-			}
+	// max_tokens 不足以容纳最小思考预算时不开启 thinking
+	if budgetTokens := reasoning.ClaudeEffortToBudget(textRequest.ReasoningEffort); budgetTokens > 0 && claudeRequest.MaxTokens > reasoning.ClaudeMinBudgetTokens {
+		claudeRequest.Thinking = &dto.Thinking{
+			Type:         "enabled",
+			BudgetTokens: common.GetPointer(reasoning.FitBudget(budgetTokens, int(claudeRequest.MaxTokens))),
 		}
 	}
 
@@ -310,7 +294,8 @@ func RequestOpenAI2ClaudeMessage(c *gin.Context, textRequest dto.GeneralOpenAIRe
 			} else {
 				claudeMediaMessages := make([]dto.ClaudeMediaMessage, 0)
 				if message.Role == "assistant" {
-					if message.ReasoningContent != "" || message.ReasoningSignature != "" {
+					// OpenAI 的 encrypted_content 不是 Claude 签名，原样回传会被上游拒绝，整段思考不再转发
+					if (message.ReasoningContent != "" || message.ReasoningSignature != "") && !relaycommon.IsResponsesEncryptedReasoning(message.ReasoningSignature) {
 						claudeThinking := dto.ClaudeMediaMessage{Type: "thinking", Signature: message.ReasoningSignature}
 						claudeThinking.Thinking = common.GetPointer[string](message.ReasoningContent)
 						claudeMediaMessages = append(claudeMediaMessages, claudeThinking)
@@ -539,6 +524,9 @@ type ClaudeResponseInfo struct {
 	Done                      bool
 	ResponsesStreamConverter  relaycommon.OpenAIWireStreamConverter
 	ResponsesCompletedEmitted bool
+	// toolCallIndexByBlock 记录 Claude 内容块序号到 OpenAI 工具调用序号的映射，
+	// 思考块与文本块会占用块序号，工具调用需要从 0 连续编号
+	toolCallIndexByBlock map[int]int
 }
 
 func buildMessageDeltaPatchUsage(claudeResponse *dto.ClaudeResponse, claudeInfo *ClaudeResponseInfo) *dto.ClaudeUsage {
@@ -669,10 +657,35 @@ func FormatClaudeResponseInfo(claudeResponse *dto.ClaudeResponse, oaiResponse *d
 		oaiResponse.Id = claudeInfo.ResponseId
 		oaiResponse.Created = claudeInfo.Created
 		oaiResponse.Model = claudeInfo.Model
+		claudeInfo.remapToolCallIndex(claudeResponse, oaiResponse)
 	}
 	return true
 }
 
+// remapToolCallIndex 将按内容块推算的工具调用序号改写为按工具调用出现顺序的连续序号
+func (claudeInfo *ClaudeResponseInfo) remapToolCallIndex(claudeResponse *dto.ClaudeResponse, oaiResponse *dto.ChatCompletionsStreamResponse) {
+	if claudeResponse.Index == nil {
+		return
+	}
+	for i := range oaiResponse.Choices {
+		toolCalls := oaiResponse.Choices[i].Delta.ToolCalls
+		if len(toolCalls) == 0 {
+			continue
+		}
+		if claudeInfo.toolCallIndexByBlock == nil {
+			claudeInfo.toolCallIndexByBlock = make(map[int]int)
+		}
+		index, ok := claudeInfo.toolCallIndexByBlock[*claudeResponse.Index]
+		if !ok {
+			index = len(claudeInfo.toolCallIndexByBlock)
+			claudeInfo.toolCallIndexByBlock[*claudeResponse.Index] = index
+		}
+		for j := range toolCalls {
+			toolCalls[j].Index = common.GetPointer(index)
+		}
+	}
+}
+
 func HandleStreamResponseData(c *gin.Context, info *relaycommon.RelayInfo, claudeInfo *ClaudeResponseInfo, data string) *types.NewAPIError {
 	var claudeResponse dto.ClaudeResponse
 	err := common.UnmarshalJsonStr(data, &claudeResponse)
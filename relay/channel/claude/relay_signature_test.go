@@ -80,3 +80,36 @@ func TestResponseClaude2OpenAIAggregatesThinkingTextAndTools(t *testing.T) {
 		t.Fatalf("usage = %+v, want prompt=180 total=200", resp.Usage)
 	}
 }
+
+func TestRequestOpenAI2ClaudeMessageDropsOpenAIEncryptedReasoning(t *testing.T) {
+	request := dto.GeneralOpenAIRequest{
+		Model: "claude-sonnet-4",
+		Messages: []dto.Message{
+			{Role: "user", Content: "hi"},
+			{Role: "assistant", Content: "hello", ReasoningContent: "plan", ReasoningSignature: "gAAAAABopenai"},
+			{Role: "user", Content: "again"},
+			{Role: "assistant", Content: "ok", ReasoningContent: "plan", ReasoningSignature: "sig_123"},
+			{Role: "user", Content: "bye"},
+		},
+	}
+	claudeRequest, err := RequestOpenAI2ClaudeMessage(nil, request)
+	if err != nil {
+		t.Fatalf("RequestOpenAI2ClaudeMessage returned error: %v", err)
+	}
+	thinkingBlocks := func(message dto.ClaudeMessage) []dto.ClaudeMediaMessage {
+		blocks, _ := message.Content.([]dto.ClaudeMediaMessage)
+		var thinking []dto.ClaudeMediaMessage
+		for _, block := range blocks {
+			if block.Type == "thinking" {
+				thinking = append(thinking, block)
+			}
+		}
+		return thinking
+	}
+	if got := thinkingBlocks(claudeRequest.Messages[1]); len(got) != 0 {
+		t.Fatalf("expected OpenAI encrypted reasoning to be dropped, got %+v", got)
+	}
+	if got := thinkingBlocks(claudeRequest.Messages[3]); len(got) != 1 || got[0].Signature != "sig_123" {
+		t.Fatalf("expected Claude signature to be forwarded, got %+v", got)
+	}
+}
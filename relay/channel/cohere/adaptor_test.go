@@ -0,0 +1,313 @@
+package cohere
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/zhongruan0522/new-api/common"
+	channelconstant "github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/dto"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/relay/constant"
+	"github.com/zhongruan0522/new-api/service"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+var initHTTPClientOnce sync.Once
+
+type upstreamCapture struct {
+	path          string
+	authorization string
+	body          string
+}
+
+// relayThroughFakeUpstream 走一遍 DoRequest / DoResponse，返回上游收到的请求与客户端收到的响应
+func relayThroughFakeUpstream(t *testing.T, info *relaycommon.RelayInfo, model string, requestBody any, contentType string, upstreamBody string) (*upstreamCapture, *httptest.ResponseRecorder, *dto.Usage) {
+	t.Helper()
+	initHTTPClientOnce.Do(service.InitHttpClient)
+
+	captured := &upstreamCapture{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		captured.path = r.URL.Path
+		captured.authorization = r.Header.Get("Authorization")
+		captured.body = string(body)
+		w.Header().Set("Content-Type", contentType)
+		_, _ = w.Write([]byte(upstreamBody))
+	}))
+	t.Cleanup(upstream.Close)
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "http://example.test/v1/chat/completions", strings.NewReader("{}"))
+
+	info.ChannelMeta = &relaycommon.ChannelMeta{
+		ChannelBaseUrl:    upstream.URL,
+		ApiKey:            "co-key",
+		UpstreamModelName: model,
+	}
+	jsonData, err := common.Marshal(requestBody)
+	if err != nil {
+		t.Fatalf("marshal request failed: %v", err)
+	}
+	adaptor := &Adaptor{}
+	resp, err := adaptor.DoRequest(c, info, strings.NewReader(string(jsonData)))
+	if err != nil {
+		t.Fatalf("DoRequest returned error: %v", err)
+	}
+	usage, apiErr := adaptor.DoResponse(c, resp.(*http.Response), info)
+	if apiErr != nil {
+		t.Fatalf("DoResponse returned error: %v", apiErr)
+	}
+	return captured, recorder, usage.(*dto.Usage)
+}
+
+// newBodyContext 构造携带原始请求体的上下文，Cohere 专有字段从原始请求体读取
+func newBodyContext(body string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "http://example.test/v1/chat/completions", strings.NewReader(body))
+	return c
+}
+
+func TestConvertOpenAIRequestMapsToolsAndDocuments(t *testing.T) {
+	request := &dto.GeneralOpenAIRequest{
+		Model: "command-a-03-2025",
+		Messages: []dto.Message{
+			{Role: "developer", Content: "be brief"},
+			{Role: "user", Content: "weather in Paris?"},
+			{Role: "assistant", ToolCalls: []byte(`[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]`)},
+			{Role: "tool", ToolCallId: "call_1", Content: "sunny"},
+		},
+		Tools: []dto.ToolCallRequest{
+			{Type: "function", Function: dto.FunctionRequest{Name: "get_weather", Parameters: map[string]any{"type": "object"}}},
+			{Type: "function", Function: dto.FunctionRequest{Name: "get_time"}},
+		},
+		ToolChoice: map[string]any{"type": "function", "function": map[string]any{"name": "get_weather"}},
+		Stop:       "END",
+		TopP:       0.5,
+	}
+
+	c := newBodyContext(`{"documents":[{"id":"doc1","data":{"text":"Paris is the capital of France."}}]}`)
+	converted, err := (&Adaptor{}).ConvertOpenAIRequest(c, &relaycommon.RelayInfo{}, request)
+	if err != nil {
+		t.Fatalf("ConvertOpenAIRequest returned error: %v", err)
+	}
+	data, _ := common.Marshal(converted)
+	body := string(data)
+
+	if got := gjson.Get(body, "messages.0.role").String(); got != "system" {
+		t.Fatalf("developer role = %q, want system", got)
+	}
+	if got := gjson.Get(body, "messages.2.tool_calls.0.function.name").String(); got != "get_weather" {
+		t.Fatalf("assistant tool call name = %q", got)
+	}
+	if got := gjson.Get(body, "messages.3.tool_call_id").String(); got != "call_1" {
+		t.Fatalf("tool_call_id = %q", got)
+	}
+	if got := gjson.Get(body, "tools.#").Int(); got != 1 {
+		t.Fatalf("tools count = %d, want only the forced tool", got)
+	}
+	if got := gjson.Get(body, "tool_choice").String(); got != "REQUIRED" {
+		t.Fatalf("tool_choice = %q, want REQUIRED", got)
+	}
+	if got := gjson.Get(body, "documents.0.id").String(); got != "doc1" {
+		t.Fatalf("documents not forwarded: %s", body)
+	}
+	if got := gjson.Get(body, "stop_sequences.0").String(); got != "END" {
+		t.Fatalf("stop_sequences = %q", got)
+	}
+	if got := gjson.Get(body, "p").Float(); got != 0.5 {
+		t.Fatalf("p = %v, want 0.5", got)
+	}
+}
+
+func TestChatNonStreamWithToolCallsAndCitations(t *testing.T) {
+	info := &relaycommon.RelayInfo{
+		RelayMode:   constant.RelayModeChatCompletions,
+		RelayFormat: types.RelayFormatOpenAI,
+	}
+	upstreamBody := `{
+		"id": "c-1",
+		"finish_reason": "TOOL_CALL",
+		"message": {
+			"role": "assistant",
+			"content": [{"type": "text", "text": "Paris is the capital."}],
+			"tool_plan": "I will look up the weather.",
+			"tool_calls": [{"id": "get_weather_1", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Paris\"}"}}],
+			"citations": [{"start": 0, "end": 5, "text": "Paris", "sources": [{"type": "document", "id": "doc1"}]}]
+		},
+		"usage": {"billed_units": {"input_tokens": 12, "output_tokens": 7}, "tokens": {"input_tokens": 200, "output_tokens": 9}}
+	}`
+	captured, recorder, usage := relayThroughFakeUpstream(t, info, "command-a-03-2025", map[string]any{"model": "command-a-03-2025"}, "application/json", upstreamBody)
+
+	if captured.path != "/v2/chat" {
+		t.Fatalf("upstream path = %q, want /v2/chat", captured.path)
+	}
+	if captured.authorization != "Bearer co-key" {
+		t.Fatalf("Authorization = %q", captured.authorization)
+	}
+	if usage.PromptTokens != 12 || usage.CompletionTokens != 7 || usage.TotalTokens != 19 {
+		t.Fatalf("usage = %+v, want billed units 12/7", usage)
+	}
+	body := recorder.Body.String()
+	if got := gjson.Get(body, "choices.0.finish_reason").String(); got != "tool_calls" {
+		t.Fatalf("finish_reason = %q", got)
+	}
+	if got := gjson.Get(body, "choices.0.message.tool_calls.0.function.name").String(); got != "get_weather" {
+		t.Fatalf("tool call name = %q", got)
+	}
+	if got := gjson.Get(body, "choices.0.message.reasoning_content").String(); got != "I will look up the weather." {
+		t.Fatalf("reasoning_content = %q", got)
+	}
+	if got := gjson.Get(body, "choices.0.message.annotations.0.document_citation.sources.0.id").String(); got != "doc1" {
+		t.Fatalf("citation annotation missing: %s", body)
+	}
+}
+
+func TestChatStreamMapsEventsToChunks(t *testing.T) {
+	oldStreamingTimeout := channelconstant.StreamingTimeout
+	channelconstant.StreamingTimeout = 30
+	t.Cleanup(func() {
+		channelconstant.StreamingTimeout = oldStreamingTimeout
+	})
+
+	info := &relaycommon.RelayInfo{
+		RelayMode:   constant.RelayModeChatCompletions,
+		RelayFormat: types.RelayFormatOpenAI,
+		IsStream:    true,
+	}
+	events := []string{
+		`{"type":"message-start","id":"c-2","delta":{"message":{"role":"assistant"}}}`,
+		`{"type":"content-start","index":0,"delta":{"message":{"content":{"type":"text","text":""}}}}`,
+		`{"type":"content-delta","index":0,"delta":{"message":{"content":{"text":"Hello"}}}}`,
+		`{"type":"citation-start","index":0,"delta":{"message":{"citations":{"start":0,"end":5,"text":"Hello","sources":[{"type":"document","id":"doc1"}]}}}}`,
+		`{"type":"tool-call-start","index":1,"delta":{"message":{"tool_calls":{"id":"t1","type":"function","function":{"name":"get_weather","arguments":""}}}}}`,
+		`{"type":"tool-call-delta","index":1,"delta":{"message":{"tool_calls":{"function":{"arguments":"{\"city\":\"Paris\"}"}}}}}`,
+		`{"type":"tool-call-end","index":1}`,
+		`{"type":"message-end","delta":{"finish_reason":"TOOL_CALL","usage":{"billed_units":{"input_tokens":5,"output_tokens":3}}}}`,
+	}
+	var upstreamBody strings.Builder
+	for _, event := range events {
+		upstreamBody.WriteString("event: " + gjson.Get(event, "type").String() + "\n")
+		upstreamBody.WriteString("data: " + event + "\n\n")
+	}
+	_, recorder, usage := relayThroughFakeUpstream(t, info, "command-a-03-2025", map[string]any{"model": "command-a-03-2025", "stream": true}, "text/event-stream", upstreamBody.String())
+
+	if usage.PromptTokens != 5 || usage.CompletionTokens != 3 {
+		t.Fatalf("usage = %+v, want 5/3", usage)
+	}
+	var content, arguments, finishReason string
+	var sawCitation bool
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		delta := gjson.Get(data, "choices.0.delta")
+		content += delta.Get("content").String()
+		arguments += delta.Get("tool_calls.0.function.arguments").String()
+		if delta.Get("annotations.0.document_citation.sources.0.id").String() == "doc1" {
+			sawCitation = true
+		}
+		if reason := gjson.Get(data, "choices.0.finish_reason").String(); reason != "" {
+			finishReason = reason
+		}
+		if name := delta.Get("tool_calls.0.function.name").String(); name != "" && name != "get_weather" {
+			t.Fatalf("tool call name = %q", name)
+		}
+	}
+	if content != "Hello" {
+		t.Fatalf("content = %q, want Hello", content)
+	}
+	if arguments != `{"city":"Paris"}` {
+		t.Fatalf("arguments = %q", arguments)
+	}
+	if !sawCitation {
+		t.Fatalf("citation chunk missing: %s", recorder.Body.String())
+	}
+	if finishReason != "tool_calls" {
+		t.Fatalf("finish_reason = %q, want tool_calls", finishReason)
+	}
+}
+
+func TestEmbedWithEmbeddingTypes(t *testing.T) {
+	info := &relaycommon.RelayInfo{
+		RelayMode: constant.RelayModeEmbeddings,
+	}
+	request, err := (&Adaptor{}).ConvertEmbeddingRequest(newBodyContext(`{"input_type":"search_query"}`), info, dto.EmbeddingRequest{
+		Model:          "embed-v4.0",
+		Input:          []any{"hello", "world"},
+		EncodingFormat: "int8",
+	})
+	if err != nil {
+		t.Fatalf("ConvertEmbeddingRequest returned error: %v", err)
+	}
+	upstreamBody := `{"id":"e-1","embeddings":{"int8":[[1,2],[3,4]]},"texts":["hello","world"],"meta":{"billed_units":{"input_tokens":2}}}`
+	captured, recorder, usage := relayThroughFakeUpstream(t, info, "embed-v4.0", request, "application/json", upstreamBody)
+
+	if captured.path != "/v2/embed" {
+		t.Fatalf("upstream path = %q, want /v2/embed", captured.path)
+	}
+	if got := gjson.Get(captured.body, "embedding_types.0").String(); got != "int8" {
+		t.Fatalf("embedding_types = %q, want int8", got)
+	}
+	if got := gjson.Get(captured.body, "input_type").String(); got != "search_query" {
+		t.Fatalf("input_type = %q", got)
+	}
+	if usage.PromptTokens != 2 {
+		t.Fatalf("prompt tokens = %d, want 2", usage.PromptTokens)
+	}
+	body := recorder.Body.String()
+	if got := gjson.Get(body, "data.1.embedding.1").Int(); got != 4 {
+		t.Fatalf("second embedding = %s", gjson.Get(body, "data.1.embedding").Raw)
+	}
+
+	if _, err := (&Adaptor{}).ConvertEmbeddingRequest(nil, info, dto.EmbeddingRequest{Input: "x", EncodingFormat: "float16"}); err == nil {
+		t.Fatal("expected unsupported encoding_format error")
+	}
+}
+
+func TestRerankReturnsDocuments(t *testing.T) {
+	returnDocuments := true
+	rerankRequest := &dto.RerankRequest{
+		Model:           "rerank-v3.5",
+		Query:           "capital of France",
+		Documents:       []any{"Berlin is in Germany", map[string]any{"text": "Paris is in France"}},
+		TopN:            1,
+		ReturnDocuments: &returnDocuments,
+	}
+	info := &relaycommon.RelayInfo{
+		RelayMode: constant.RelayModeRerank,
+		Request:   rerankRequest,
+		PriceData: types.PriceData{UsePrice: true},
+	}
+	request, _ := (&Adaptor{}).ConvertRerankRequest(nil, info.RelayMode, *rerankRequest)
+	upstreamBody := `{"id":"r-1","results":[{"index":1,"relevance_score":0.98}],"meta":{"billed_units":{"search_units":2}}}`
+	captured, recorder, _ := relayThroughFakeUpstream(t, info, "rerank-v3.5", request, "application/json", upstreamBody)
+
+	if captured.path != "/v2/rerank" {
+		t.Fatalf("upstream path = %q, want /v2/rerank", captured.path)
+	}
+	if got := gjson.Get(captured.body, "documents.1").String(); got != "Paris is in France" {
+		t.Fatalf("structured document = %q", got)
+	}
+	body := recorder.Body.String()
+	if got := gjson.Get(body, "results.0.relevance_score").Float(); got != 0.98 {
+		t.Fatalf("relevance_score = %v", got)
+	}
+	if got := gjson.Get(body, "results.0.document.text").String(); got != "Paris is in France" {
+		t.Fatalf("returned document = %s", gjson.Get(body, "results.0.document").Raw)
+	}
+	if got := info.PriceData.OtherRatios["search_units"]; got != 2 {
+		t.Fatalf("search_units ratio = %v, want 2", got)
+	}
+}
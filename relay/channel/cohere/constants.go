@@ -0,0 +1,18 @@
+package cohere
+
+var ModelList = []string{
+	"command-a-03-2025",
+	"command-a-reasoning-08-2025",
+	"command-a-vision-07-2025",
+	"command-r-plus-08-2024",
+	"command-r-08-2024",
+	"command-r7b-12-2024",
+	"embed-v4.0",
+	"embed-english-v3.0",
+	"embed-multilingual-v3.0",
+	"rerank-v3.5",
+	"rerank-english-v3.0",
+	"rerank-multilingual-v3.0",
+}
+
+var ChannelName = "cohere"
@@ -0,0 +1,165 @@
+package cohere
+
+import "encoding/json"
+
+type ChatRequest struct {
+	Model            string          `json:"model"`
+	Messages         []ChatMessage   `json:"messages"`
+	Tools            []Tool          `json:"tools,omitempty"`
+	Documents        json.RawMessage `json:"documents,omitempty"`
+	ToolChoice       string          `json:"tool_choice,omitempty"`
+	ResponseFormat   *ResponseFormat `json:"response_format,omitempty"`
+	Stream           bool            `json:"stream,omitempty"`
+	MaxTokens        uint            `json:"max_tokens,omitempty"`
+	Temperature      *float64        `json:"temperature,omitempty"`
+	P                float64         `json:"p,omitempty"`
+	K                int             `json:"k,omitempty"`
+	StopSequences    []string        `json:"stop_sequences,omitempty"`
+	Seed             *int64          `json:"seed,omitempty"`
+	FrequencyPenalty float64         `json:"frequency_penalty,omitempty"`
+	PresencePenalty  float64         `json:"presence_penalty,omitempty"`
+}
+
+type ChatMessage struct {
+	Role       string     `json:"role"`
+	Content    any        `json:"content,omitempty"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallId string     `json:"tool_call_id,omitempty"`
+	ToolPlan   string     `json:"tool_plan,omitempty"`
+}
+
+type ContentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	Thinking string    `json:"thinking,omitempty"`
+	ImageUrl *ImageUrl `json:"image_url,omitempty"`
+}
+
+type ImageUrl struct {
+	Url    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+type ToolFunction struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parameters,omitempty"`
+}
+
+type ToolCall struct {
+	Id       string           `json:"id,omitempty"`
+	Type     string           `json:"type,omitempty"`
+	Function ToolCallFunction `json:"function"`
+}
+
+type ToolCallFunction struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+}
+
+type ResponseFormat struct {
+	Type       string `json:"type"`
+	JsonSchema any    `json:"json_schema,omitempty"`
+}
+
+type Citation struct {
+	Start   int               `json:"start"`
+	End     int               `json:"end"`
+	Text    string            `json:"text"`
+	Sources []json.RawMessage `json:"sources,omitempty"`
+	Type    string            `json:"type,omitempty"`
+}
+
+type ResponseMessage struct {
+	Role      string        `json:"role"`
+	Content   []ContentPart `json:"content,omitempty"`
+	ToolPlan  string        `json:"tool_plan,omitempty"`
+	ToolCalls []ToolCall    `json:"tool_calls,omitempty"`
+	Citations []Citation    `json:"citations,omitempty"`
+}
+
+type ChatResponse struct {
+	Id           string          `json:"id"`
+	FinishReason string          `json:"finish_reason"`
+	Message      ResponseMessage `json:"message"`
+	Usage        *Usage          `json:"usage,omitempty"`
+}
+
+type BilledUnits struct {
+	InputTokens     int `json:"input_tokens,omitempty"`
+	OutputTokens    int `json:"output_tokens,omitempty"`
+	SearchUnits     int `json:"search_units,omitempty"`
+	Classifications int `json:"classifications,omitempty"`
+}
+
+type Tokens struct {
+	InputTokens  int `json:"input_tokens,omitempty"`
+	OutputTokens int `json:"output_tokens,omitempty"`
+}
+
+// Usage 同时用于 chat 的 usage 与 embed / rerank 的 meta
+type Usage struct {
+	BilledUnits *BilledUnits `json:"billed_units,omitempty"`
+	Tokens      *Tokens      `json:"tokens,omitempty"`
+}
+
+// StreamEvent v2 流式事件，type 取值如 message-start / content-delta / tool-call-start / citation-start / message-end
+type StreamEvent struct {
+	Type  string       `json:"type"`
+	Id    string       `json:"id,omitempty"`
+	Index int          `json:"index"`
+	Delta *StreamDelta `json:"delta,omitempty"`
+}
+
+type StreamDelta struct {
+	Message      *StreamDeltaMessage `json:"message,omitempty"`
+	FinishReason string              `json:"finish_reason,omitempty"`
+	Usage        *Usage              `json:"usage,omitempty"`
+}
+
+type StreamDeltaMessage struct {
+	Role      string       `json:"role,omitempty"`
+	Content   *ContentPart `json:"content,omitempty"`
+	ToolPlan  string       `json:"tool_plan,omitempty"`
+	ToolCalls *ToolCall    `json:"tool_calls,omitempty"`
+	Citations *Citation    `json:"citations,omitempty"`
+}
+
+type EmbedRequest struct {
+	Model           string   `json:"model"`
+	Texts           []string `json:"texts"`
+	InputType       string   `json:"input_type"`
+	EmbeddingTypes  []string `json:"embedding_types,omitempty"`
+	OutputDimension int      `json:"output_dimension,omitempty"`
+	Truncate        string   `json:"truncate,omitempty"`
+}
+
+type EmbedResponse struct {
+	Id         string                     `json:"id"`
+	Embeddings map[string]json.RawMessage `json:"embeddings"`
+	Meta       *Usage                     `json:"meta,omitempty"`
+}
+
+type RerankRequest struct {
+	Model           string   `json:"model"`
+	Query           string   `json:"query"`
+	Documents       []string `json:"documents"`
+	TopN            int      `json:"top_n,omitempty"`
+	MaxTokensPerDoc int      `json:"max_tokens_per_doc,omitempty"`
+}
+
+type RerankResult struct {
+	Index          int     `json:"index"`
+	RelevanceScore float64 `json:"relevance_score"`
+}
+
+type RerankResponse struct {
+	Id      string         `json:"id"`
+	Results []RerankResult `json:"results"`
+	Meta    *Usage         `json:"meta,omitempty"`
+}
@@ -0,0 +1,579 @@
+package cohere
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/logger"
+	"github.com/zhongruan0522/new-api/relay/channel/openai"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/relay/helper"
+	"github.com/zhongruan0522/new-api/service"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/sjson"
+)
+
+var embeddingTypes = map[string]bool{
+	"float":   true,
+	"int8":    true,
+	"uint8":   true,
+	"binary":  true,
+	"ubinary": true,
+	"base64":  true,
+}
+
+func requestOpenAI2Cohere(request dto.GeneralOpenAIRequest, documents json.RawMessage) (*ChatRequest, error) {
+	cohereReq := &ChatRequest{
+		Model:            request.Model,
+		Documents:        documents,
+		Stream:           request.Stream,
+		MaxTokens:        request.GetMaxTokens(),
+		Temperature:      request.Temperature,
+		P:                request.TopP,
+		K:                request.TopK,
+		FrequencyPenalty: request.FrequencyPenalty,
+		PresencePenalty:  request.PresencePenalty,
+	}
+	if request.Seed != 0 {
+		seed := int64(request.Seed)
+		cohereReq.Seed = &seed
+	}
+	switch stop := request.Stop.(type) {
+	case string:
+		if stop != "" {
+			cohereReq.StopSequences = []string{stop}
+		}
+	case []any:
+		for _, s := range stop {
+			if str, ok := s.(string); ok {
+				cohereReq.StopSequences = append(cohereReq.StopSequences, str)
+			}
+		}
+	}
+
+	for _, message := range request.Messages {
+		cohereReq.Messages = append(cohereReq.Messages, convertMessage(message))
+	}
+
+	for _, tool := range request.Tools {
+		if tool.Type != "function" {
+			continue
+		}
+		cohereReq.Tools = append(cohereReq.Tools, Tool{
+			Type: "function",
+			Function: ToolFunction{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				Parameters:  tool.Function.Parameters,
+			},
+		})
+	}
+	applyToolChoice(cohereReq, request.ToolChoice)
+
+	if request.ResponseFormat != nil {
+		switch request.ResponseFormat.Type {
+		case "json_object":
+			cohereReq.ResponseFormat = &ResponseFormat{Type: "json_object"}
+		case "json_schema":
+			var format dto.FormatJsonSchema
+			if err := common.Unmarshal(request.ResponseFormat.JsonSchema, &format); err != nil {
+				return nil, fmt.Errorf("invalid response_format.json_schema: %w", err)
+			}
+			cohereReq.ResponseFormat = &ResponseFormat{Type: "json_object", JsonSchema: format.Schema}
+		}
+	}
+	return cohereReq, nil
+}
+
+func convertMessage(message dto.Message) ChatMessage {
+	role := message.Role
+	if role == "developer" {
+		role = "system"
+	}
+	cohereMessage := ChatMessage{Role: role}
+	switch role {
+	case "tool":
+		cohereMessage.ToolCallId = message.ToolCallId
+		cohereMessage.Content = message.StringContent()
+	case "assistant":
+		if content := message.StringContent(); content != "" {
+			cohereMessage.Content = content
+		}
+		for _, toolCall := range message.ParseToolCalls() {
+			cohereMessage.ToolCalls = append(cohereMessage.ToolCalls, ToolCall{
+				Id:   toolCall.ID,
+				Type: "function",
+				Function: ToolCallFunction{
+					Name:      toolCall.Function.Name,
+					Arguments: toolCall.Function.Arguments,
+				},
+			})
+		}
+	case "user":
+		if message.IsStringContent() {
+			cohereMessage.Content = message.StringContent()
+			break
+		}
+		var parts []ContentPart
+		for _, content := range message.ParseContent() {
+			switch content.Type {
+			case dto.ContentTypeText:
+				parts = append(parts, ContentPart{Type: "text", Text: content.Text})
+			case dto.ContentTypeImageURL:
+				if image := content.GetImageMedia(); image != nil {
+					parts = append(parts, ContentPart{Type: "image_url", ImageUrl: &ImageUrl{Url: image.Url, Detail: image.Detail}})
+				}
+			}
+		}
+		cohereMessage.Content = parts
+	default:
+		cohereMessage.Content = message.StringContent()
+	}
+	return cohereMessage
+}
+
+// applyToolChoice Cohere 只支持 REQUIRED / NONE，指定函数时仅保留该工具并强制调用
+func applyToolChoice(cohereReq *ChatRequest, toolChoice any) {
+	switch choice := toolChoice.(type) {
+	case string:
+		switch choice {
+		case "required":
+			cohereReq.ToolChoice = "REQUIRED"
+		case "none":
+			cohereReq.ToolChoice = "NONE"
+		}
+	case map[string]any:
+		function, _ := choice["function"].(map[string]any)
+		name, _ := function["name"].(string)
+		if name == "" {
+			return
+		}
+		for _, tool := range cohereReq.Tools {
+			if tool.Function.Name == name {
+				cohereReq.Tools = []Tool{tool}
+				cohereReq.ToolChoice = "REQUIRED"
+				return
+			}
+		}
+	}
+}
+
+func requestOpenAI2Embed(request dto.EmbeddingRequest, inputType string) (*EmbedRequest, error) {
+	texts := request.ParseInput()
+	if len(texts) == 0 {
+		return nil, errors.New("input is empty")
+	}
+	embeddingType := request.EncodingFormat
+	if embeddingType == "" {
+		embeddingType = "float"
+	}
+	if !embeddingTypes[embeddingType] {
+		return nil, fmt.Errorf("unsupported encoding_format: %s", embeddingType)
+	}
+	if inputType == "" {
+		inputType = "search_document"
+	}
+	return &EmbedRequest{
+		Model:           request.Model,
+		Texts:           texts,
+		InputType:       inputType,
+		EmbeddingTypes:  []string{embeddingType},
+		OutputDimension: request.Dimensions,
+	}, nil
+}
+
+func requestRerank2Cohere(request dto.RerankRequest) *RerankRequest {
+	documents := make([]string, 0, len(request.Documents))
+	for _, document := range request.Documents {
+		documents = append(documents, documentText(document))
+	}
+	return &RerankRequest{
+		Model:     request.Model,
+		Query:     request.Query,
+		Documents: documents,
+		TopN:      request.TopN,
+	}
+}
+
+// documentText v2 rerank 只接受字符串文档，结构化文档取 text 字段或序列化为 JSON
+func documentText(document any) string {
+	switch doc := document.(type) {
+	case string:
+		return doc
+	case map[string]any:
+		if text, ok := doc["text"].(string); ok {
+			return text
+		}
+	}
+	data, err := common.Marshal(document)
+	if err != nil {
+		return fmt.Sprintf("%v", document)
+	}
+	return string(data)
+}
+
+// cohereUsage 优先使用计费单位，缺失时回退到实际 tokens
+func cohereUsage(usage *Usage) dto.Usage {
+	var result dto.Usage
+	if usage == nil {
+		return result
+	}
+	if usage.BilledUnits != nil {
+		result.PromptTokens = usage.BilledUnits.InputTokens
+		result.CompletionTokens = usage.BilledUnits.OutputTokens
+	}
+	if result.PromptTokens == 0 && result.CompletionTokens == 0 && usage.Tokens != nil {
+		result.PromptTokens = usage.Tokens.InputTokens
+		result.CompletionTokens = usage.Tokens.OutputTokens
+	}
+	result.TotalTokens = result.PromptTokens + result.CompletionTokens
+	return result
+}
+
+func stopReasonCohere2OpenAI(reason string) string {
+	switch reason {
+	case "MAX_TOKENS":
+		return constant.FinishReasonLength
+	case "TOOL_CALL":
+		return constant.FinishReasonToolCalls
+	default:
+		return constant.FinishReasonStop
+	}
+}
+
+// citationAnnotation 将 Cohere 文档引用转换为 message.annotations 中的条目
+func citationAnnotation(citation Citation) map[string]any {
+	return map[string]any{
+		"type": "document_citation",
+		"document_citation": map[string]any{
+			"start_index": citation.Start,
+			"end_index":   citation.End,
+			"text":        citation.Text,
+			"sources":     citation.Sources,
+		},
+	}
+}
+
+func responseCohere2OpenAI(cohereResp *ChatResponse, model string) *dto.OpenAITextResponse {
+	var content, reasoning strings.Builder
+	for _, part := range cohereResp.Message.Content {
+		switch part.Type {
+		case "text":
+			content.WriteString(part.Text)
+		case "thinking":
+			reasoning.WriteString(part.Thinking)
+		}
+	}
+	if reasoning.Len() == 0 {
+		reasoning.WriteString(cohereResp.Message.ToolPlan)
+	}
+	message := dto.Message{
+		Role:             "assistant",
+		ReasoningContent: reasoning.String(),
+	}
+	message.SetStringContent(content.String())
+	if len(cohereResp.Message.ToolCalls) > 0 {
+		toolCalls := make([]dto.ToolCallResponse, 0, len(cohereResp.Message.ToolCalls))
+		for _, toolCall := range cohereResp.Message.ToolCalls {
+			toolCalls = append(toolCalls, dto.ToolCallResponse{
+				ID:   toolCall.Id,
+				Type: "function",
+				Function: dto.FunctionResponse{
+					Name:      toolCall.Function.Name,
+					Arguments: toolCall.Function.Arguments,
+				},
+			})
+		}
+		message.SetToolCalls(toolCalls)
+	}
+	return &dto.OpenAITextResponse{
+		Id:      cohereResp.Id,
+		Model:   model,
+		Object:  "chat.completion",
+		Created: common.GetTimestamp(),
+		Choices: []dto.OpenAITextResponseChoice{
+			{
+				Index:        0,
+				Message:      message,
+				FinishReason: stopReasonCohere2OpenAI(cohereResp.FinishReason),
+			},
+		},
+		Usage: cohereUsage(cohereResp.Usage),
+	}
+}
+
+func cohereChatHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+	}
+	service.CloseResponseBodyGracefully(resp)
+	if common.DebugEnabled {
+		println(string(responseBody))
+	}
+	var cohereResp ChatResponse
+	if err := common.Unmarshal(responseBody, &cohereResp); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	fullTextResponse := responseCohere2OpenAI(&cohereResp, info.UpstreamModelName)
+	if fullTextResponse.Usage.TotalTokens == 0 {
+		fullTextResponse.Usage = *service.ResponseText2Usage(c, fullTextResponse.Choices[0].StringContent(), info.UpstreamModelName, info.GetEstimatePromptTokens())
+	}
+	usage := fullTextResponse.Usage
+
+	switch info.RelayFormat {
+	case types.RelayFormatClaude:
+		claudeResp := service.ResponseOpenAI2Claude(fullTextResponse, info)
+		responseBody, err = common.Marshal(claudeResp)
+	case types.RelayFormatOpenAIResponses:
+		responsesResp, convertErr := relaycommon.ConvertChatCompletionResponseToResponsesResponseWithToolContext(fullTextResponse, info.OpenAIResponsesToolContext)
+		if convertErr != nil {
+			return nil, types.NewError(convertErr, types.ErrorCodeBadResponseBody)
+		}
+		responseBody, err = common.Marshal(responsesResp)
+	default:
+		responseBody, err = common.Marshal(fullTextResponse)
+		if err == nil && len(cohereResp.Message.Citations) > 0 {
+			annotations := make([]map[string]any, 0, len(cohereResp.Message.Citations))
+			for _, citation := range cohereResp.Message.Citations {
+				annotations = append(annotations, citationAnnotation(citation))
+			}
+			responseBody, err = sjson.SetBytes(responseBody, "choices.0.message.annotations", annotations)
+		}
+	}
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	service.IOCopyBytesGracefully(c, resp, responseBody)
+	return &usage, nil
+}
+
+func newStreamChunk(id string, createAt int64, model string) *dto.ChatCompletionsStreamResponse {
+	return &dto.ChatCompletionsStreamResponse{
+		Id:      id,
+		Object:  "chat.completion.chunk",
+		Created: createAt,
+		Model:   model,
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{Index: 0}},
+	}
+}
+
+func handleStream(c *gin.Context, info *relaycommon.RelayInfo, resp *dto.ChatCompletionsStreamResponse, annotation map[string]any) error {
+	streamData, err := common.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stream response: %w", err)
+	}
+	if annotation != nil {
+		streamData, err = sjson.SetBytes(streamData, "choices.0.delta.annotations", []map[string]any{annotation})
+		if err != nil {
+			return fmt.Errorf("failed to set stream annotations: %w", err)
+		}
+	}
+	if err := openai.HandleStreamFormat(c, info, string(streamData), info.ChannelSetting.ForceFormat, info.ChannelSetting.ThinkingToContent); err != nil {
+		return fmt.Errorf("failed to handle stream format: %w", err)
+	}
+	return nil
+}
+
+func cohereStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	id := helper.GetResponseID(c)
+	createAt := common.GetTimestamp()
+	model := info.UpstreamModelName
+	usage := &dto.Usage{}
+	responseText := strings.Builder{}
+	// Cohere 的 tool call index 与 content index 共用计数，这里重新编号为 OpenAI 的工具下标
+	toolIndexes := make(map[int]int)
+
+	helper.StreamScannerHandler(c, resp, info, func(data string) bool {
+		var event StreamEvent
+		if err := common.UnmarshalJsonStr(data, &event); err != nil {
+			logger.LogError(c, "error unmarshalling cohere stream event: "+err.Error())
+			return true
+		}
+		var message *StreamDeltaMessage
+		if event.Delta != nil {
+			message = event.Delta.Message
+		}
+		chunk := newStreamChunk(id, createAt, model)
+		delta := &chunk.Choices[0].Delta
+		var annotation map[string]any
+
+		switch event.Type {
+		case "message-start":
+			chunk = helper.GenerateStartEmptyResponse(id, createAt, model, nil)
+		case "content-delta":
+			if message == nil || message.Content == nil {
+				return true
+			}
+			if message.Content.Thinking != "" {
+				delta.SetReasoningContent(message.Content.Thinking)
+			} else {
+				responseText.WriteString(message.Content.Text)
+				delta.SetContentString(message.Content.Text)
+			}
+		case "tool-plan-delta":
+			if message == nil || message.ToolPlan == "" {
+				return true
+			}
+			delta.SetReasoningContent(message.ToolPlan)
+		case "tool-call-start", "tool-call-delta":
+			if message == nil || message.ToolCalls == nil {
+				return true
+			}
+			toolIdx, ok := toolIndexes[event.Index]
+			if !ok {
+				toolIdx = len(toolIndexes)
+				toolIndexes[event.Index] = toolIdx
+			}
+			toolCall := dto.ToolCallResponse{
+				Function: dto.FunctionResponse{Arguments: message.ToolCalls.Function.Arguments},
+			}
+			if event.Type == "tool-call-start" {
+				toolCall.ID = message.ToolCalls.Id
+				if toolCall.ID == "" {
+					toolCall.ID = fmt.Sprintf("call_%s", common.GetUUID())
+				}
+				toolCall.Type = "function"
+				toolCall.Function.Name = message.ToolCalls.Function.Name
+			}
+			responseText.WriteString(message.ToolCalls.Function.Name + message.ToolCalls.Function.Arguments)
+			toolCall.SetIndex(toolIdx)
+			delta.ToolCalls = []dto.ToolCallResponse{toolCall}
+		case "citation-start":
+			if message == nil || message.Citations == nil {
+				return true
+			}
+			annotation = citationAnnotation(*message.Citations)
+		case "message-end":
+			if event.Delta == nil {
+				return true
+			}
+			if event.Delta.Usage != nil {
+				*usage = cohereUsage(event.Delta.Usage)
+			}
+			chunk = helper.GenerateStopResponse(id, createAt, model, stopReasonCohere2OpenAI(event.Delta.FinishReason))
+		default:
+			return true
+		}
+
+		if err := handleStream(c, info, chunk, annotation); err != nil {
+			logger.LogError(c, err.Error())
+		}
+		return true
+	})
+
+	if usage.TotalTokens == 0 {
+		usage = service.ResponseText2Usage(c, responseText.String(), model, info.GetEstimatePromptTokens())
+	}
+	finalResponse := helper.GenerateFinalUsageResponse(id, createAt, model, *usage)
+	streamData, err := common.Marshal(finalResponse)
+	if err != nil {
+		common.SysLog("send final response failed: " + err.Error())
+		return usage, nil
+	}
+	openai.HandleFinalResponse(c, info, string(streamData), id, createAt, model, finalResponse.GetSystemFingerprint(), usage, false)
+	return usage, nil
+}
+
+func cohereEmbeddingHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+	}
+	service.CloseResponseBodyGracefully(resp)
+	var embedResp EmbedResponse
+	if err := common.Unmarshal(responseBody, &embedResp); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	// 请求时只指定了一种 embedding_types，响应中取第一个即可
+	var vectors []any
+	for _, raw := range embedResp.Embeddings {
+		if err := common.Unmarshal(raw, &vectors); err != nil {
+			return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+		}
+		break
+	}
+	openAIResp := dto.FlexibleEmbeddingResponse{
+		Object: "list",
+		Data:   make([]dto.FlexibleEmbeddingResponseItem, 0, len(vectors)),
+		Model:  info.UpstreamModelName,
+		Usage:  cohereUsage(embedResp.Meta),
+	}
+	for i, vector := range vectors {
+		openAIResp.Data = append(openAIResp.Data, dto.FlexibleEmbeddingResponseItem{
+			Object:    "embedding",
+			Index:     i,
+			Embedding: vector,
+		})
+	}
+	if openAIResp.Usage.PromptTokens == 0 {
+		openAIResp.Usage.PromptTokens = info.GetEstimatePromptTokens()
+		openAIResp.Usage.TotalTokens = openAIResp.Usage.PromptTokens
+	}
+
+	jsonResponse, err := common.Marshal(openAIResp)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	service.IOCopyBytesGracefully(c, resp, jsonResponse)
+	return &openAIResp.Usage, nil
+}
+
+func cohereRerankHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+	}
+	service.CloseResponseBodyGracefully(resp)
+	var cohereResp RerankResponse
+	if err := common.Unmarshal(responseBody, &cohereResp); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+
+	// rerank 按 search_units 计费而不返回 tokens：按次计价时每个 search unit 计一次，按量计价时按预估的输入 tokens 计量
+	usage := cohereUsage(cohereResp.Meta)
+	if cohereResp.Meta != nil && cohereResp.Meta.BilledUnits != nil && cohereResp.Meta.BilledUnits.SearchUnits > 0 && info.PriceData.UsePrice {
+		info.PriceData.AddOtherRatio("search_units", float64(cohereResp.Meta.BilledUnits.SearchUnits))
+	}
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = info.GetEstimatePromptTokens()
+		usage.TotalTokens = usage.PromptTokens
+	}
+
+	var documents []any
+	if rerankReq, ok := info.Request.(*dto.RerankRequest); ok && rerankReq.GetReturnDocuments() {
+		documents = rerankReq.Documents
+	}
+	rerankResp := &dto.RerankResponse{
+		Results: make([]dto.RerankResponseResult, 0, len(cohereResp.Results)),
+		Usage:   usage,
+	}
+	for _, result := range cohereResp.Results {
+		item := dto.RerankResponseResult{
+			Index:          result.Index,
+			RelevanceScore: result.RelevanceScore,
+		}
+		if result.Index >= 0 && result.Index < len(documents) {
+			// 与上游实际排序的文本一致，结构化文档不再嵌套为 {"text":{"text":...}}
+			item.Document = dto.RerankDocument{Text: documentText(documents[result.Index])}
+		}
+		rerankResp.Results = append(rerankResp.Results, item)
+	}
+
+	jsonResponse, err := common.Marshal(rerankResp)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	c.Writer.Header().Set("Content-Type", "application/json")
+	service.IOCopyBytesGracefully(c, resp, jsonResponse)
+	return &usage, nil
+}
@@ -0,0 +1,132 @@
+package dashscope
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/relay/channel"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/relay/constant"
+	"github.com/zhongruan0522/new-api/service"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Adaptor struct {
+	// multimodal 在转换请求时确定，决定走 text-generation 还是 multimodal-generation
+	multimodal bool
+}
+
+func (a *Adaptor) ConvertGeminiRequest(*gin.Context, *relaycommon.RelayInfo, *dto.GeminiChatRequest) (any, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayInfo, req *dto.ClaudeRequest) (any, error) {
+	oaiReq, err := service.ClaudeToOpenAIRequest(*req, info)
+	if err != nil {
+		return nil, err
+	}
+	return a.ConvertOpenAIRequest(c, info, oaiReq)
+}
+
+func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.AudioRequest) (io.Reader, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
+	if info.RelayMode != constant.RelayModeImagesGenerations {
+		return nil, errors.New("dashscope only supports image generations")
+	}
+	return imageRequestOpenAI2DashScope(request), nil
+}
+
+func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
+	a.multimodal = isMultimodalModel(info.UpstreamModelName)
+}
+
+func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
+	switch info.RelayMode {
+	case constant.RelayModeEmbeddings:
+		return fmt.Sprintf("%s/api/v1/services/embeddings/text-embedding/text-embedding", info.ChannelBaseUrl), nil
+	case constant.RelayModeImagesGenerations:
+		return fmt.Sprintf("%s/api/v1/services/aigc/text2image/image-synthesis", info.ChannelBaseUrl), nil
+	}
+	if a.multimodal {
+		return fmt.Sprintf("%s/api/v1/services/aigc/multimodal-generation/generation", info.ChannelBaseUrl), nil
+	}
+	return fmt.Sprintf("%s/api/v1/services/aigc/text-generation/generation", info.ChannelBaseUrl), nil
+}
+
+func (a *Adaptor) SetupRequestHeader(c *gin.Context, req *http.Header, info *relaycommon.RelayInfo) error {
+	channel.SetupApiRequestHeader(info, c, req)
+	req.Set("Authorization", fmt.Sprintf("Bearer %s", info.ApiKey))
+	if info.IsStream {
+		req.Set("X-DashScope-SSE", "enable")
+		req.Set("Accept", "text/event-stream")
+	}
+	if info.RelayMode == constant.RelayModeImagesGenerations {
+		req.Set("X-DashScope-Async", "enable")
+	}
+	return nil
+}
+
+func (a *Adaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) (any, error) {
+	if request == nil {
+		return nil, errors.New("request is nil")
+	}
+	a.multimodal = a.multimodal || isMultimodalModel(request.Model) || hasMediaContent(request.Messages)
+	return requestOpenAI2DashScope(*request, a.multimodal), nil
+}
+
+func (a *Adaptor) ConvertOpenAIResponsesRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.OpenAIResponsesRequest) (any, error) {
+	// Responses -> Chat -> DashScope，响应在 dashScopeChatHandler / dashScopeStreamHandler 中按 RelayFormat 转回
+	chatReq, toolContext, err := relaycommon.ConvertResponsesRequestToChatCompletionsRequestWithToolContext(&request)
+	if err != nil {
+		return nil, err
+	}
+	if info != nil {
+		info.OpenAIResponsesToolContext = toolContext
+		relaycommon.AppendRequestConversionFromRequest(info, chatReq)
+	}
+	return a.ConvertOpenAIRequest(c, info, chatReq)
+}
+
+func (a *Adaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, requestBody io.Reader) (any, error) {
+	return channel.DoApiRequest(a, c, info, requestBody)
+}
+
+func (a *Adaptor) ConvertRerankRequest(c *gin.Context, relayMode int, request dto.RerankRequest) (any, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) ConvertEmbeddingRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.EmbeddingRequest) (any, error) {
+	return embeddingRequestOpenAI2DashScope(request, channel.GetRequestBodyField(c, "input_type").String())
+}
+
+func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (usage any, err *types.NewAPIError) {
+	switch info.RelayMode {
+	case constant.RelayModeEmbeddings:
+		usage, err = dashScopeEmbeddingHandler(c, info, resp)
+	case constant.RelayModeImagesGenerations:
+		usage, err = dashScopeImageHandler(c, info, resp)
+	default:
+		if info.IsStream {
+			usage, err = dashScopeStreamHandler(c, info, resp)
+		} else {
+			usage, err = dashScopeChatHandler(c, info, resp)
+		}
+	}
+	return
+}
+
+func (a *Adaptor) GetModelList() []string {
+	return ModelList
+}
+
+func (a *Adaptor) GetChannelName() string {
+	return ChannelName
+}
@@ -0,0 +1,350 @@
+package dashscope
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhongruan0522/new-api/common"
+	channelconstant "github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/dto"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/relay/constant"
+	"github.com/zhongruan0522/new-api/service"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+var initHTTPClientOnce sync.Once
+
+func newFakeUpstream(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	initHTTPClientOnce.Do(service.InitHttpClient)
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestContext() (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "http://example.test/v1/chat/completions", strings.NewReader("{}"))
+	return c, recorder
+}
+
+// relayRequest 按 relay handler 的顺序执行 Init / Convert / DoRequest / DoResponse
+func relayRequest(t *testing.T, c *gin.Context, info *relaycommon.RelayInfo, convert func(a *Adaptor) (any, error)) *dto.Usage {
+	t.Helper()
+	adaptor := &Adaptor{}
+	adaptor.Init(info)
+	converted, err := convert(adaptor)
+	if err != nil {
+		t.Fatalf("convert request failed: %v", err)
+	}
+	jsonData, err := common.Marshal(converted)
+	if err != nil {
+		t.Fatalf("marshal request failed: %v", err)
+	}
+	resp, err := adaptor.DoRequest(c, info, strings.NewReader(string(jsonData)))
+	if err != nil {
+		t.Fatalf("DoRequest returned error: %v", err)
+	}
+	usage, apiErr := adaptor.DoResponse(c, resp.(*http.Response), info)
+	if apiErr != nil {
+		t.Fatalf("DoResponse returned error: %v", apiErr)
+	}
+	return usage.(*dto.Usage)
+}
+
+func newTestRelayInfo(baseURL string, model string, relayMode int) *relaycommon.RelayInfo {
+	return &relaycommon.RelayInfo{
+		RelayMode:   relayMode,
+		RelayFormat: types.RelayFormatOpenAI,
+		ChannelMeta: &relaycommon.ChannelMeta{
+			ChannelBaseUrl:    baseURL,
+			ApiKey:            "sk-ds",
+			UpstreamModelName: model,
+		},
+	}
+}
+
+func TestChatNonStreamMapsSearchAndCachedUsage(t *testing.T) {
+	var requestPath, requestBody string
+	server := newFakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requestPath, requestBody = r.URL.Path, string(body)
+		_, _ = w.Write([]byte(`{
+			"request_id": "req-1",
+			"output": {"choices": [{"finish_reason": "stop", "message": {"role": "assistant", "content": "你好", "reasoning_content": "思考"}}]},
+			"usage": {"input_tokens": 30, "output_tokens": 8, "total_tokens": 38, "prompt_tokens_details": {"cached_tokens": 16}, "output_tokens_details": {"reasoning_tokens": 2}}
+		}`))
+	})
+	c, recorder := newTestContext()
+	info := newTestRelayInfo(server.URL, "qwen-plus", constant.RelayModeChatCompletions)
+	usage := relayRequest(t, c, info, func(a *Adaptor) (any, error) {
+		return a.ConvertOpenAIRequest(c, info, &dto.GeneralOpenAIRequest{
+			Model:        "qwen-plus",
+			Messages:     []dto.Message{{Role: "user", Content: "hi"}},
+			EnableSearch: []byte("true"),
+		})
+	})
+
+	if requestPath != "/api/v1/services/aigc/text-generation/generation" {
+		t.Fatalf("request path = %q", requestPath)
+	}
+	if !gjson.Get(requestBody, "parameters.enable_search").Bool() {
+		t.Fatalf("enable_search not forwarded: %s", requestBody)
+	}
+	if got := gjson.Get(requestBody, "parameters.result_format").String(); got != "message" {
+		t.Fatalf("result_format = %q, want message", got)
+	}
+	if got := gjson.Get(requestBody, "input.messages.0.content").String(); got != "hi" {
+		t.Fatalf("message content = %q", got)
+	}
+	if usage.PromptTokens != 30 || usage.CompletionTokens != 8 || usage.PromptTokensDetails.CachedTokens != 16 || usage.CompletionTokenDetails.ReasoningTokens != 2 {
+		t.Fatalf("usage = %+v", usage)
+	}
+	body := recorder.Body.String()
+	if got := gjson.Get(body, "choices.0.message.content").String(); got != "你好" {
+		t.Fatalf("content = %q", got)
+	}
+	if got := gjson.Get(body, "choices.0.message.reasoning_content").String(); got != "思考" {
+		t.Fatalf("reasoning_content = %q", got)
+	}
+}
+
+func TestChatMultimodalUsesMultimodalEndpoint(t *testing.T) {
+	var requestPath, requestBody string
+	server := newFakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requestPath, requestBody = r.URL.Path, string(body)
+		_, _ = w.Write([]byte(`{"output":{"choices":[{"finish_reason":"stop","message":{"role":"assistant","content":[{"text":"一只猫"}]}}]},"usage":{"input_tokens":100,"output_tokens":3,"input_tokens_details":{"image_tokens":90,"text_tokens":10}}}`))
+	})
+	c, recorder := newTestContext()
+	info := newTestRelayInfo(server.URL, "qwen-vl-max", constant.RelayModeChatCompletions)
+	usage := relayRequest(t, c, info, func(a *Adaptor) (any, error) {
+		return a.ConvertOpenAIRequest(c, info, &dto.GeneralOpenAIRequest{
+			Model: "qwen-vl-max",
+			Messages: []dto.Message{{Role: "user", Content: []any{
+				map[string]any{"type": "image_url", "image_url": map[string]any{"url": "https://img.example/cat.png"}},
+				map[string]any{"type": "text", "text": "这是什么"},
+			}}},
+		})
+	})
+
+	if requestPath != "/api/v1/services/aigc/multimodal-generation/generation" {
+		t.Fatalf("request path = %q", requestPath)
+	}
+	if got := gjson.Get(requestBody, "input.messages.0.content.0.image").String(); got != "https://img.example/cat.png" {
+		t.Fatalf("image content = %s", requestBody)
+	}
+	if got := gjson.Get(requestBody, "input.messages.0.content.1.text").String(); got != "这是什么" {
+		t.Fatalf("text content = %s", requestBody)
+	}
+	if usage.PromptTokensDetails.ImageTokens != 90 || usage.TotalTokens != 103 {
+		t.Fatalf("usage = %+v", usage)
+	}
+	if got := gjson.Get(recorder.Body.String(), "choices.0.message.content").String(); got != "一只猫" {
+		t.Fatalf("content = %q", got)
+	}
+}
+
+func TestChatStreamIncrementalOutput(t *testing.T) {
+	oldStreamingTimeout := channelconstant.StreamingTimeout
+	channelconstant.StreamingTimeout = 30
+	t.Cleanup(func() {
+		channelconstant.StreamingTimeout = oldStreamingTimeout
+	})
+
+	events := []string{
+		`{"output":{"choices":[{"finish_reason":"null","message":{"role":"assistant","content":"","reasoning_content":"想一想"}}]},"usage":{"input_tokens":10,"output_tokens":1}}`,
+		`{"output":{"choices":[{"finish_reason":"null","message":{"role":"assistant","content":"北京"}}]},"usage":{"input_tokens":10,"output_tokens":2}}`,
+		`{"output":{"choices":[{"finish_reason":"null","message":{"role":"assistant","content":"","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":"}}]}}]},"usage":{"input_tokens":10,"output_tokens":4}}`,
+		`{"output":{"choices":[{"finish_reason":"tool_calls","message":{"role":"assistant","content":"","tool_calls":[{"index":0,"id":"","type":"function","function":{"arguments":"\"北京\"}"}}]}}]},"usage":{"input_tokens":10,"output_tokens":6,"total_tokens":16,"prompt_tokens_details":{"cached_tokens":4}}}`,
+	}
+	var requestHeader http.Header
+	var requestBody string
+	server := newFakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requestHeader, requestBody = r.Header.Clone(), string(body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i, event := range events {
+			_, _ = io.WriteString(w, "id:"+string(rune('1'+i))+"\nevent:result\n:HTTP_STATUS/200\ndata:"+event+"\n\n")
+		}
+	})
+	c, recorder := newTestContext()
+	info := newTestRelayInfo(server.URL, "qwen-plus", constant.RelayModeChatCompletions)
+	info.IsStream = true
+	usage := relayRequest(t, c, info, func(a *Adaptor) (any, error) {
+		return a.ConvertOpenAIRequest(c, info, &dto.GeneralOpenAIRequest{
+			Model:    "qwen-plus",
+			Stream:   true,
+			Messages: []dto.Message{{Role: "user", Content: "天气"}},
+		})
+	})
+
+	if requestHeader.Get("X-DashScope-SSE") != "enable" {
+		t.Fatalf("X-DashScope-SSE = %q", requestHeader.Get("X-DashScope-SSE"))
+	}
+	if !gjson.Get(requestBody, "parameters.incremental_output").Bool() {
+		t.Fatalf("incremental_output not set: %s", requestBody)
+	}
+	if usage.TotalTokens != 16 || usage.PromptTokensDetails.CachedTokens != 4 {
+		t.Fatalf("usage = %+v", usage)
+	}
+	var content, reasoning, arguments, finishReason string
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		delta := gjson.Get(data, "choices.0.delta")
+		content += delta.Get("content").String()
+		reasoning += delta.Get("reasoning_content").String()
+		arguments += delta.Get("tool_calls.0.function.arguments").String()
+		if reason := gjson.Get(data, "choices.0.finish_reason").String(); reason != "" {
+			finishReason = reason
+		}
+	}
+	if content != "北京" || reasoning != "想一想" {
+		t.Fatalf("content = %q, reasoning = %q", content, reasoning)
+	}
+	if arguments != `{"city":"北京"}` {
+		t.Fatalf("arguments = %q", arguments)
+	}
+	if finishReason != "tool_calls" {
+		t.Fatalf("finish_reason = %q", finishReason)
+	}
+}
+
+func TestEmbedding(t *testing.T) {
+	var requestPath, requestBody string
+	server := newFakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requestPath, requestBody = r.URL.Path, string(body)
+		_, _ = w.Write([]byte(`{"output":{"embeddings":[{"text_index":0,"embedding":[0.1,0.2]},{"text_index":1,"embedding":[0.3,0.4]}]},"usage":{"total_tokens":6}}`))
+	})
+	c, recorder := newTestContext()
+	c.Request = httptest.NewRequest(http.MethodPost, "http://example.test/v1/embeddings", strings.NewReader(`{"input_type":"query"}`))
+	info := newTestRelayInfo(server.URL, "text-embedding-v4", constant.RelayModeEmbeddings)
+	usage := relayRequest(t, c, info, func(a *Adaptor) (any, error) {
+		return a.ConvertEmbeddingRequest(c, info, dto.EmbeddingRequest{
+			Model:      "text-embedding-v4",
+			Input:      []any{"a", "b"},
+			Dimensions: 512,
+		})
+	})
+
+	if requestPath != "/api/v1/services/embeddings/text-embedding/text-embedding" {
+		t.Fatalf("request path = %q", requestPath)
+	}
+	if gjson.Get(requestBody, "input.texts.#").Int() != 2 || gjson.Get(requestBody, "parameters.dimension").Int() != 512 || gjson.Get(requestBody, "parameters.text_type").String() != "query" {
+		t.Fatalf("embedding request = %s", requestBody)
+	}
+	if usage.PromptTokens != 6 || usage.TotalTokens != 6 {
+		t.Fatalf("usage = %+v", usage)
+	}
+	if got := gjson.Get(recorder.Body.String(), "data.1.embedding.1").Float(); got != 0.4 {
+		t.Fatalf("embedding = %s", recorder.Body.String())
+	}
+}
+
+func TestImageGenerationPollsAsyncTask(t *testing.T) {
+	oldInterval := imageTaskPollInterval
+	imageTaskPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() {
+		imageTaskPollInterval = oldInterval
+	})
+
+	var submitHeader http.Header
+	var submitBody string
+	var polls int
+	server := newFakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/services/aigc/text2image/image-synthesis":
+			body, _ := io.ReadAll(r.Body)
+			submitHeader, submitBody = r.Header.Clone(), string(body)
+			_, _ = w.Write([]byte(`{"output":{"task_id":"task-1","task_status":"PENDING"},"request_id":"req"}`))
+		case "/api/v1/tasks/task-1":
+			polls++
+			if polls < 2 {
+				_, _ = w.Write([]byte(`{"output":{"task_id":"task-1","task_status":"RUNNING"}}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"output":{"task_id":"task-1","task_status":"SUCCEEDED","results":[{"url":"https://oss.example/1.png"},{"code":"DataInspectionFailed","message":"blocked"}]},"usage":{"image_count":1}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	c, recorder := newTestContext()
+	info := newTestRelayInfo(server.URL, "wanx2.1-t2i-turbo", constant.RelayModeImagesGenerations)
+	usage := relayRequest(t, c, info, func(a *Adaptor) (any, error) {
+		return a.ConvertImageRequest(c, info, dto.ImageRequest{
+			Model:  "wanx2.1-t2i-turbo",
+			Prompt: "a cat",
+			Size:   "1024x1024",
+			N:      2,
+			Extra:  map[string]json.RawMessage{"negative_prompt": json.RawMessage(`"dog"`)},
+		})
+	})
+
+	if submitHeader.Get("X-DashScope-Async") != "enable" {
+		t.Fatalf("X-DashScope-Async = %q", submitHeader.Get("X-DashScope-Async"))
+	}
+	if gjson.Get(submitBody, "parameters.size").String() != "1024*1024" || gjson.Get(submitBody, "input.negative_prompt").String() != "dog" {
+		t.Fatalf("submit body = %s", submitBody)
+	}
+	if polls != 2 {
+		t.Fatalf("polls = %d, want 2", polls)
+	}
+	if usage.TotalTokens != 1 {
+		t.Fatalf("usage = %+v, want 1 image", usage)
+	}
+	body := recorder.Body.String()
+	if gjson.Get(body, "data.#").Int() != 1 || gjson.Get(body, "data.0.url").String() != "https://oss.example/1.png" {
+		t.Fatalf("image response = %s", body)
+	}
+}
+
+func TestVideoAdaptorMapsTaskStatus(t *testing.T) {
+	server := newFakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/tasks/running":
+			_, _ = w.Write([]byte(`{"output":{"task_id":"running","task_status":"RUNNING"}}`))
+		case "/api/v1/tasks/failed":
+			_, _ = w.Write([]byte(`{"output":{"task_id":"failed","task_status":"FAILED","code":"InternalError","message":"boom"}}`))
+		case "/api/v1/tasks/done":
+			_, _ = w.Write([]byte(`{"output":{"task_id":"done","task_status":"SUCCEEDED","video_url":"` + "http://" + r.Host + `/video.mp4"}}`))
+		case "/video.mp4":
+			if r.Header.Get("Authorization") != "" {
+				t.Errorf("video download should not carry Authorization")
+			}
+			w.Header().Set("Content-Type", "video/mp4")
+			_, _ = w.Write([]byte("mp4-bytes"))
+		}
+	})
+	info := newTestRelayInfo(server.URL, "wanx2.1-t2v-turbo", constant.RelayModeVideoGenerations)
+	adaptor := &VideoAdaptor{}
+
+	taskInfo, err := adaptor.FetchTask(context.Background(), info, "running")
+	if err != nil || taskInfo.Status != dto.VideoStatusInProgress {
+		t.Fatalf("running task = %+v, err = %v", taskInfo, err)
+	}
+	taskInfo, err = adaptor.FetchTask(context.Background(), info, "failed")
+	if err != nil || taskInfo.Status != dto.VideoStatusFailed || taskInfo.FailReason != "boom" {
+		t.Fatalf("failed task = %+v, err = %v", taskInfo, err)
+	}
+	data, mimeType, err := adaptor.FetchContent(context.Background(), info, "done")
+	if err != nil || string(data) != "mp4-bytes" || mimeType != "video/mp4" {
+		t.Fatalf("content = %q, %q, err = %v", data, mimeType, err)
+	}
+}
@@ -0,0 +1,25 @@
+package dashscope
+
+var ModelList = []string{
+	"qwen-max",
+	"qwen-plus",
+	"qwen-turbo",
+	"qwen-long",
+	"qwen3-max",
+	"qwen3-235b-a22b",
+	"qwen3-coder-plus",
+	"qwq-plus",
+	"qwen-vl-max",
+	"qwen-vl-plus",
+	"qwen-audio-turbo",
+	"qwen-omni-turbo",
+	"text-embedding-v3",
+	"text-embedding-v4",
+	"wanx2.1-t2i-turbo",
+	"wanx2.1-t2i-plus",
+	"wan2.2-t2i-flash",
+	"wanx2.1-t2v-turbo",
+	"wan2.2-t2v-plus",
+}
+
+var ChannelName = "dashscope"
@@ -0,0 +1,190 @@
+package dashscope
+
+import (
+	"encoding/json"
+
+	"github.com/zhongruan0522/new-api/dto"
+)
+
+type ChatRequest struct {
+	Model      string          `json:"model"`
+	Input      ChatInput       `json:"input"`
+	Parameters *ChatParameters `json:"parameters,omitempty"`
+}
+
+type ChatInput struct {
+	Messages []ChatMessage `json:"messages"`
+}
+
+type ChatMessage struct {
+	Role       string                `json:"role"`
+	Content    any                   `json:"content"`
+	Name       *string               `json:"name,omitempty"`
+	ToolCalls  []dto.ToolCallRequest `json:"tool_calls,omitempty"`
+	ToolCallId string                `json:"tool_call_id,omitempty"`
+}
+
+// MultimodalContent 多模态接口的 content 元素，每项只填一种模态
+type MultimodalContent struct {
+	Text  string `json:"text,omitempty"`
+	Image string `json:"image,omitempty"`
+	Audio string `json:"audio,omitempty"`
+	Video string `json:"video,omitempty"`
+}
+
+type ChatParameters struct {
+	ResultFormat           string                `json:"result_format,omitempty"`
+	IncrementalOutput      bool                  `json:"incremental_output,omitempty"`
+	EnableSearch           json.RawMessage       `json:"enable_search,omitempty"`
+	EnableThinking         json.RawMessage       `json:"enable_thinking,omitempty"`
+	VlHighResolutionImages json.RawMessage       `json:"vl_high_resolution_images,omitempty"`
+	MaxTokens              uint                  `json:"max_tokens,omitempty"`
+	Temperature            *float64              `json:"temperature,omitempty"`
+	TopP                   float64               `json:"top_p,omitempty"`
+	TopK                   int                   `json:"top_k,omitempty"`
+	Seed                   uint64                `json:"seed,omitempty"`
+	Stop                   any                   `json:"stop,omitempty"`
+	PresencePenalty        float64               `json:"presence_penalty,omitempty"`
+	Tools                  []dto.ToolCallRequest `json:"tools,omitempty"`
+	ToolChoice             any                   `json:"tool_choice,omitempty"`
+	ParallelToolCalls      *bool                 `json:"parallel_tool_calls,omitempty"`
+	ResponseFormat         *dto.ResponseFormat   `json:"response_format,omitempty"`
+}
+
+type ChatResponse struct {
+	RequestId string     `json:"request_id"`
+	Output    ChatOutput `json:"output"`
+	Usage     Usage      `json:"usage"`
+	Code      string     `json:"code,omitempty"`
+	Message   string     `json:"message,omitempty"`
+}
+
+type ChatOutput struct {
+	// result_format=text 时只返回 text 与 finish_reason
+	Text         string       `json:"text,omitempty"`
+	FinishReason string       `json:"finish_reason,omitempty"`
+	Choices      []ChatChoice `json:"choices,omitempty"`
+}
+
+type ChatChoice struct {
+	FinishReason string          `json:"finish_reason"`
+	Message      ResponseMessage `json:"message"`
+}
+
+type ResponseMessage struct {
+	Role             string                 `json:"role"`
+	Content          json.RawMessage        `json:"content"`
+	ReasoningContent string                 `json:"reasoning_content,omitempty"`
+	ToolCalls        []dto.ToolCallResponse `json:"tool_calls,omitempty"`
+}
+
+type Usage struct {
+	InputTokens         int                  `json:"input_tokens"`
+	OutputTokens        int                  `json:"output_tokens"`
+	TotalTokens         int                  `json:"total_tokens"`
+	ImageCount          int                  `json:"image_count,omitempty"`
+	InputTokensDetails  *InputTokensDetails  `json:"input_tokens_details,omitempty"`
+	PromptTokensDetails *PromptTokensDetails `json:"prompt_tokens_details,omitempty"`
+	OutputTokensDetails *OutputTokensDetails `json:"output_tokens_details,omitempty"`
+}
+
+type InputTokensDetails struct {
+	TextTokens  int `json:"text_tokens,omitempty"`
+	ImageTokens int `json:"image_tokens,omitempty"`
+	AudioTokens int `json:"audio_tokens,omitempty"`
+}
+
+type PromptTokensDetails struct {
+	CachedTokens int `json:"cached_tokens,omitempty"`
+}
+
+type OutputTokensDetails struct {
+	ReasoningTokens int `json:"reasoning_tokens,omitempty"`
+}
+
+type EmbeddingRequest struct {
+	Model      string               `json:"model"`
+	Input      EmbeddingInput       `json:"input"`
+	Parameters *EmbeddingParameters `json:"parameters,omitempty"`
+}
+
+type EmbeddingInput struct {
+	Texts []string `json:"texts"`
+}
+
+type EmbeddingParameters struct {
+	TextType   string `json:"text_type,omitempty"`
+	Dimension  int    `json:"dimension,omitempty"`
+	OutputType string `json:"output_type,omitempty"`
+}
+
+type EmbeddingResponse struct {
+	RequestId string `json:"request_id"`
+	Output    struct {
+		Embeddings []struct {
+			TextIndex int       `json:"text_index"`
+			Embedding []float64 `json:"embedding"`
+		} `json:"embeddings"`
+	} `json:"output"`
+	Usage Usage `json:"usage"`
+}
+
+// TaskSubmitRequest 万相文生图 / 文生视频的异步任务请求
+type TaskSubmitRequest struct {
+	Model      string         `json:"model"`
+	Input      TaskInput      `json:"input"`
+	Parameters map[string]any `json:"parameters,omitempty"`
+}
+
+type TaskInput struct {
+	Prompt         string `json:"prompt"`
+	NegativePrompt string `json:"negative_prompt,omitempty"`
+	ImgUrl         string `json:"img_url,omitempty"`
+}
+
+const (
+	TaskStatusPending   = "PENDING"
+	TaskStatusRunning   = "RUNNING"
+	TaskStatusSucceeded = "SUCCEEDED"
+	TaskStatusFailed    = "FAILED"
+	TaskStatusCanceled  = "CANCELED"
+	TaskStatusUnknown   = "UNKNOWN"
+)
+
+type TaskResponse struct {
+	RequestId string     `json:"request_id"`
+	Output    TaskOutput `json:"output"`
+	Usage     Usage      `json:"usage"`
+	Code      string     `json:"code,omitempty"`
+	Message   string     `json:"message,omitempty"`
+}
+
+type TaskOutput struct {
+	TaskId     string       `json:"task_id"`
+	TaskStatus string       `json:"task_status"`
+	Results    []TaskResult `json:"results,omitempty"`
+	VideoUrl   string       `json:"video_url,omitempty"`
+	Code       string       `json:"code,omitempty"`
+	Message    string       `json:"message,omitempty"`
+}
+
+type TaskResult struct {
+	Url     string `json:"url,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+func (t *TaskOutput) IsFinished() bool {
+	switch t.TaskStatus {
+	case TaskStatusSucceeded, TaskStatusFailed, TaskStatusCanceled, TaskStatusUnknown:
+		return true
+	}
+	return false
+}
+
+func (t *TaskOutput) FailReason() string {
+	if t.Message != "" {
+		return t.Message
+	}
+	return "task " + t.TaskStatus
+}
@@ -0,0 +1,427 @@
+package dashscope
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/logger"
+	"github.com/zhongruan0522/new-api/relay/channel/openai"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/relay/helper"
+	"github.com/zhongruan0522/new-api/service"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// isMultimodalModel 视觉、音频与全模态模型只能走 multimodal-generation 接口
+func isMultimodalModel(model string) bool {
+	model = strings.ToLower(model)
+	for _, keyword := range []string{"-vl", "qvq", "-audio", "-omni"} {
+		if strings.Contains(model, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasMediaContent(messages []dto.Message) bool {
+	for i := range messages {
+		if messages[i].IsStringContent() {
+			continue
+		}
+		for _, content := range messages[i].ParseContent() {
+			if content.Type != dto.ContentTypeText {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func requestOpenAI2DashScope(request dto.GeneralOpenAIRequest, multimodal bool) *ChatRequest {
+	resultFormat := request.ResultFormat
+	if resultFormat == "" || len(request.Tools) > 0 {
+		resultFormat = "message"
+	}
+	parameters := &ChatParameters{
+		ResultFormat:           resultFormat,
+		IncrementalOutput:      request.Stream,
+		EnableSearch:           request.EnableSearch,
+		EnableThinking:         request.EnableThinking,
+		VlHighResolutionImages: request.VlHighResolutionImages,
+		MaxTokens:              request.GetMaxTokens(),
+		Temperature:            request.Temperature,
+		TopP:                   request.TopP,
+		TopK:                   request.TopK,
+		Stop:                   request.Stop,
+		PresencePenalty:        request.PresencePenalty,
+		Tools:                  request.Tools,
+		ToolChoice:             request.ToolChoice,
+		ParallelToolCalls:      request.ParallelTooCalls,
+		ResponseFormat:         request.ResponseFormat,
+	}
+	if request.Seed > 0 {
+		parameters.Seed = uint64(request.Seed)
+	}
+
+	messages := make([]ChatMessage, 0, len(request.Messages))
+	for _, message := range request.Messages {
+		role := message.Role
+		if role == "developer" {
+			role = "system"
+		}
+		chatMessage := ChatMessage{
+			Role:       role,
+			Name:       message.Name,
+			ToolCalls:  message.ParseToolCalls(),
+			ToolCallId: message.ToolCallId,
+		}
+		if multimodal {
+			chatMessage.Content = convertMultimodalContent(message)
+		} else {
+			chatMessage.Content = message.StringContent()
+		}
+		messages = append(messages, chatMessage)
+	}
+	return &ChatRequest{
+		Model:      request.Model,
+		Input:      ChatInput{Messages: messages},
+		Parameters: parameters,
+	}
+}
+
+func convertMultimodalContent(message dto.Message) []MultimodalContent {
+	if message.IsStringContent() {
+		return []MultimodalContent{{Text: message.StringContent()}}
+	}
+	contents := make([]MultimodalContent, 0)
+	for _, content := range message.ParseContent() {
+		switch content.Type {
+		case dto.ContentTypeText:
+			contents = append(contents, MultimodalContent{Text: content.Text})
+		case dto.ContentTypeImageURL:
+			if image := content.GetImageMedia(); image != nil {
+				contents = append(contents, MultimodalContent{Image: image.Url})
+			}
+		case dto.ContentTypeInputAudio:
+			if audio := content.GetInputAudio(); audio != nil {
+				contents = append(contents, MultimodalContent{Audio: fmt.Sprintf("data:audio/%s;base64,%s", audio.Format, audio.Data)})
+			}
+		case dto.ContentTypeVideoUrl:
+			if video := content.GetVideoUrl(); video != nil {
+				contents = append(contents, MultimodalContent{Video: video.Url})
+			}
+		}
+	}
+	return contents
+}
+
+func dashScopeUsage(usage Usage) dto.Usage {
+	result := dto.Usage{
+		PromptTokens:     usage.InputTokens,
+		CompletionTokens: usage.OutputTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+	if result.TotalTokens == 0 {
+		result.TotalTokens = result.PromptTokens + result.CompletionTokens
+	}
+	if usage.PromptTokensDetails != nil {
+		result.PromptTokensDetails.CachedTokens = usage.PromptTokensDetails.CachedTokens
+	}
+	if usage.InputTokensDetails != nil {
+		result.PromptTokensDetails.TextTokens = usage.InputTokensDetails.TextTokens
+		result.PromptTokensDetails.ImageTokens = usage.InputTokensDetails.ImageTokens
+		result.PromptTokensDetails.AudioTokens = usage.InputTokensDetails.AudioTokens
+	}
+	if usage.OutputTokensDetails != nil {
+		result.CompletionTokenDetails.ReasoningTokens = usage.OutputTokensDetails.ReasoningTokens
+	}
+	return result
+}
+
+// contentText content 在文本接口中为字符串，在多模态接口中为 [{"text": ...}] 数组
+func contentText(raw []byte) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var text string
+	if err := common.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	var contents []MultimodalContent
+	if err := common.Unmarshal(raw, &contents); err != nil {
+		return ""
+	}
+	var builder strings.Builder
+	for _, content := range contents {
+		builder.WriteString(content.Text)
+	}
+	return builder.String()
+}
+
+func finishReasonDashScope2OpenAI(reason string) string {
+	switch reason {
+	case "", "null":
+		return ""
+	case "length":
+		return constant.FinishReasonLength
+	case "tool_calls":
+		return constant.FinishReasonToolCalls
+	default:
+		return constant.FinishReasonStop
+	}
+}
+
+func responseDashScope2OpenAI(response *ChatResponse, id string, model string) *dto.OpenAITextResponse {
+	fullTextResponse := &dto.OpenAITextResponse{
+		Id:      id,
+		Model:   model,
+		Object:  "chat.completion",
+		Created: common.GetTimestamp(),
+		Choices: make([]dto.OpenAITextResponseChoice, 0, len(response.Output.Choices)),
+		Usage:   dashScopeUsage(response.Usage),
+	}
+	if len(response.Output.Choices) == 0 {
+		message := dto.Message{Role: "assistant"}
+		message.SetStringContent(response.Output.Text)
+		fullTextResponse.Choices = append(fullTextResponse.Choices, dto.OpenAITextResponseChoice{
+			Message:      message,
+			FinishReason: common.GetStringIfEmpty(finishReasonDashScope2OpenAI(response.Output.FinishReason), constant.FinishReasonStop),
+		})
+		return fullTextResponse
+	}
+	for i, choice := range response.Output.Choices {
+		message := dto.Message{
+			Role:             "assistant",
+			ReasoningContent: choice.Message.ReasoningContent,
+		}
+		message.SetStringContent(contentText(choice.Message.Content))
+		if len(choice.Message.ToolCalls) > 0 {
+			message.SetToolCalls(choice.Message.ToolCalls)
+		}
+		fullTextResponse.Choices = append(fullTextResponse.Choices, dto.OpenAITextResponseChoice{
+			Index:        i,
+			Message:      message,
+			FinishReason: common.GetStringIfEmpty(finishReasonDashScope2OpenAI(choice.FinishReason), constant.FinishReasonStop),
+		})
+	}
+	return fullTextResponse
+}
+
+func dashScopeChatHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+	}
+	service.CloseResponseBodyGracefully(resp)
+	if common.DebugEnabled {
+		println(string(responseBody))
+	}
+	var dashScopeResp ChatResponse
+	if err := common.Unmarshal(responseBody, &dashScopeResp); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	if dashScopeResp.Code != "" {
+		return nil, types.NewOpenAIError(fmt.Errorf("%s: %s", dashScopeResp.Code, dashScopeResp.Message), types.ErrorCodeBadResponse, http.StatusInternalServerError)
+	}
+	fullTextResponse := responseDashScope2OpenAI(&dashScopeResp, helper.GetResponseID(c), info.UpstreamModelName)
+	usage := fullTextResponse.Usage
+
+	switch info.RelayFormat {
+	case types.RelayFormatClaude:
+		claudeResp := service.ResponseOpenAI2Claude(fullTextResponse, info)
+		responseBody, err = common.Marshal(claudeResp)
+	case types.RelayFormatOpenAIResponses:
+		responsesResp, convertErr := relaycommon.ConvertChatCompletionResponseToResponsesResponseWithToolContext(fullTextResponse, info.OpenAIResponsesToolContext)
+		if convertErr != nil {
+			return nil, types.NewError(convertErr, types.ErrorCodeBadResponseBody)
+		}
+		responseBody, err = common.Marshal(responsesResp)
+	default:
+		responseBody, err = common.Marshal(fullTextResponse)
+	}
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	service.IOCopyBytesGracefully(c, resp, responseBody)
+	return &usage, nil
+}
+
+func handleStream(c *gin.Context, info *relaycommon.RelayInfo, resp *dto.ChatCompletionsStreamResponse) error {
+	streamData, err := common.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stream response: %w", err)
+	}
+	if err := openai.HandleStreamFormat(c, info, string(streamData), info.ChannelSetting.ForceFormat, info.ChannelSetting.ThinkingToContent); err != nil {
+		return fmt.Errorf("failed to handle stream format: %w", err)
+	}
+	return nil
+}
+
+// dashScopeStreamHandler 依赖 incremental_output=true，每个事件只携带增量内容，usage 为累计值
+func dashScopeStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	id := helper.GetResponseID(c)
+	createAt := common.GetTimestamp()
+	model := info.UpstreamModelName
+	usage := &dto.Usage{}
+	responseText := strings.Builder{}
+	finishReason := ""
+	var streamErr error
+
+	helper.StreamScannerHandler(c, resp, info, func(data string) bool {
+		var event ChatResponse
+		if err := common.UnmarshalJsonStr(data, &event); err != nil {
+			logger.LogError(c, "error unmarshalling dashscope stream response: "+err.Error())
+			return true
+		}
+		if event.Code != "" {
+			streamErr = fmt.Errorf("%s: %s", event.Code, event.Message)
+			return false
+		}
+		if event.Usage.InputTokens > 0 || event.Usage.OutputTokens > 0 {
+			*usage = dashScopeUsage(event.Usage)
+		}
+		if info.SendResponseCount == 0 {
+			if err := handleStream(c, info, helper.GenerateStartEmptyResponse(id, createAt, model, nil)); err != nil {
+				logger.LogError(c, err.Error())
+			}
+		}
+
+		chunk := &dto.ChatCompletionsStreamResponse{
+			Id:      id,
+			Object:  "chat.completion.chunk",
+			Created: createAt,
+			Model:   model,
+			Choices: []dto.ChatCompletionsStreamResponseChoice{{Index: 0}},
+		}
+		delta := &chunk.Choices[0].Delta
+		hasDelta := false
+		if len(event.Output.Choices) > 0 {
+			choice := event.Output.Choices[0]
+			if choice.Message.ReasoningContent != "" {
+				delta.SetReasoningContent(choice.Message.ReasoningContent)
+				hasDelta = true
+			}
+			if text := contentText(choice.Message.Content); text != "" {
+				responseText.WriteString(text)
+				delta.SetContentString(text)
+				hasDelta = true
+			}
+			if len(choice.Message.ToolCalls) > 0 {
+				for i := range choice.Message.ToolCalls {
+					toolCall := &choice.Message.ToolCalls[i]
+					if toolCall.Index == nil {
+						toolCall.SetIndex(i)
+					}
+					responseText.WriteString(toolCall.Function.Name + toolCall.Function.Arguments)
+				}
+				delta.ToolCalls = choice.Message.ToolCalls
+				hasDelta = true
+			}
+			if reason := finishReasonDashScope2OpenAI(choice.FinishReason); reason != "" {
+				finishReason = reason
+			}
+		} else if event.Output.Text != "" {
+			responseText.WriteString(event.Output.Text)
+			delta.SetContentString(event.Output.Text)
+			hasDelta = true
+			if reason := finishReasonDashScope2OpenAI(event.Output.FinishReason); reason != "" {
+				finishReason = reason
+			}
+		}
+		if hasDelta {
+			if err := handleStream(c, info, chunk); err != nil {
+				logger.LogError(c, err.Error())
+			}
+		}
+		return true
+	})
+
+	if streamErr != nil && info.SendResponseCount == 0 {
+		return nil, types.NewOpenAIError(streamErr, types.ErrorCodeBadResponse, http.StatusInternalServerError)
+	}
+	if streamErr != nil {
+		logger.LogError(c, "dashscope stream error: "+streamErr.Error())
+	}
+	if finishReason == "" {
+		finishReason = constant.FinishReasonStop
+	}
+	if err := handleStream(c, info, helper.GenerateStopResponse(id, createAt, model, finishReason)); err != nil {
+		logger.LogError(c, err.Error())
+	}
+
+	if usage.TotalTokens == 0 {
+		usage = service.ResponseText2Usage(c, responseText.String(), model, info.GetEstimatePromptTokens())
+	}
+	finalResponse := helper.GenerateFinalUsageResponse(id, createAt, model, *usage)
+	streamData, err := common.Marshal(finalResponse)
+	if err != nil {
+		common.SysLog("send final response failed: " + err.Error())
+		return usage, nil
+	}
+	openai.HandleFinalResponse(c, info, string(streamData), id, createAt, model, finalResponse.GetSystemFingerprint(), usage, false)
+	return usage, nil
+}
+
+func embeddingRequestOpenAI2DashScope(request dto.EmbeddingRequest, inputType string) (*EmbeddingRequest, error) {
+	texts := request.ParseInput()
+	if len(texts) == 0 {
+		return nil, errors.New("input is empty")
+	}
+	textType := ""
+	switch inputType {
+	case "query", "search_query":
+		textType = "query"
+	case "document", "search_document":
+		textType = "document"
+	}
+	return &EmbeddingRequest{
+		Model: request.Model,
+		Input: EmbeddingInput{Texts: texts},
+		Parameters: &EmbeddingParameters{
+			TextType:   textType,
+			Dimension:  request.Dimensions,
+			OutputType: "dense",
+		},
+	}, nil
+}
+
+func dashScopeEmbeddingHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+	}
+	service.CloseResponseBodyGracefully(resp)
+	var embeddingResp EmbeddingResponse
+	if err := common.Unmarshal(responseBody, &embeddingResp); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	usage := dashScopeUsage(embeddingResp.Usage)
+	usage.PromptTokens = usage.TotalTokens
+	openAIResp := dto.OpenAIEmbeddingResponse{
+		Object: "list",
+		Data:   make([]dto.OpenAIEmbeddingResponseItem, 0, len(embeddingResp.Output.Embeddings)),
+		Model:  info.UpstreamModelName,
+		Usage:  usage,
+	}
+	for _, item := range embeddingResp.Output.Embeddings {
+		openAIResp.Data = append(openAIResp.Data, dto.OpenAIEmbeddingResponseItem{
+			Object:    "embedding",
+			Index:     item.TextIndex,
+			Embedding: item.Embedding,
+		})
+	}
+	jsonResponse, err := common.Marshal(openAIResp)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	service.IOCopyBytesGracefully(c, resp, jsonResponse)
+	return &usage, nil
+}
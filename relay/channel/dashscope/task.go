@@ -0,0 +1,256 @@
+package dashscope
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/relay/channel"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/service"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// imageTaskPollInterval / imageTaskTimeout 控制文生图在请求内轮询的节奏与上限
+	imageTaskPollInterval = 2 * time.Second
+	imageTaskTimeout      = 5 * time.Minute
+)
+
+func authHeader(info *relaycommon.RelayInfo) map[string]string {
+	return map[string]string{
+		"Authorization": "Bearer " + info.ApiKey,
+	}
+}
+
+// dashScopeSize OpenAI 的 1024x1024 在 DashScope 中写作 1024*1024
+func dashScopeSize(size string) string {
+	return strings.ReplaceAll(size, "x", "*")
+}
+
+func imageRequestOpenAI2DashScope(request dto.ImageRequest) *TaskSubmitRequest {
+	submitReq := &TaskSubmitRequest{
+		Model:      request.Model,
+		Input:      TaskInput{Prompt: request.Prompt},
+		Parameters: map[string]any{},
+	}
+	if raw, ok := request.Extra["negative_prompt"]; ok {
+		_ = common.Unmarshal(raw, &submitReq.Input.NegativePrompt)
+	}
+	for _, key := range []string{"seed", "prompt_extend"} {
+		if raw, ok := request.Extra[key]; ok {
+			var value any
+			if err := common.Unmarshal(raw, &value); err == nil {
+				submitReq.Parameters[key] = value
+			}
+		}
+	}
+	if request.Size != "" {
+		submitReq.Parameters["size"] = dashScopeSize(request.Size)
+	}
+	if request.N > 0 {
+		submitReq.Parameters["n"] = request.N
+	}
+	if request.Watermark != nil {
+		submitReq.Parameters["watermark"] = *request.Watermark
+	}
+	return submitReq
+}
+
+func fetchTask(ctx context.Context, info *relaycommon.RelayInfo, taskId string) (*TaskResponse, error) {
+	url := fmt.Sprintf("%s/api/v1/tasks/%s", info.ChannelBaseUrl, taskId)
+	resp, err := channel.DoTaskBackgroundRequest(ctx, info, http.MethodGet, url, authHeader(info))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("fetch task status %d: %s", resp.StatusCode, string(body))
+	}
+	var taskResp TaskResponse
+	if err := common.DecodeJson(resp.Body, &taskResp); err != nil {
+		return nil, fmt.Errorf("decode task response failed: %w", err)
+	}
+	return &taskResp, nil
+}
+
+func parseTaskSubmitResponse(resp *http.Response) (*TaskResponse, error) {
+	defer service.CloseResponseBodyGracefully(resp)
+	var taskResp TaskResponse
+	if err := common.DecodeJson(resp.Body, &taskResp); err != nil {
+		return nil, fmt.Errorf("decode task response failed: %w", err)
+	}
+	if taskResp.Code != "" {
+		return nil, fmt.Errorf("%s: %s", taskResp.Code, taskResp.Message)
+	}
+	if taskResp.Output.TaskId == "" {
+		return nil, errors.New("upstream task id is empty")
+	}
+	return &taskResp, nil
+}
+
+// dashScopeImageHandler 提交接口只返回 task_id，这里在请求内轮询到终态后按 OpenAI 图片格式返回
+func dashScopeImageHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	taskResp, err := parseTaskSubmitResponse(resp)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), imageTaskTimeout)
+	defer cancel()
+	for !taskResp.Output.IsFinished() {
+		select {
+		case <-ctx.Done():
+			return nil, types.NewOpenAIError(fmt.Errorf("image task %s timed out", taskResp.Output.TaskId), types.ErrorCodeBadResponse, http.StatusGatewayTimeout)
+		case <-time.After(imageTaskPollInterval):
+		}
+		taskId := taskResp.Output.TaskId
+		taskResp, err = fetchTask(ctx, info, taskId)
+		if err != nil {
+			return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponse, http.StatusInternalServerError)
+		}
+		if taskResp.Output.TaskId == "" {
+			taskResp.Output.TaskId = taskId
+		}
+	}
+	if taskResp.Output.TaskStatus != TaskStatusSucceeded {
+		return nil, types.NewOpenAIError(fmt.Errorf("image task %s failed: %s", taskResp.Output.TaskId, taskResp.Output.FailReason()), types.ErrorCodeBadResponse, http.StatusInternalServerError)
+	}
+
+	b64Json := false
+	if imageReq, ok := info.Request.(*dto.ImageRequest); ok {
+		b64Json = imageReq.ResponseFormat == "b64_json"
+	}
+	imageResp := dto.ImageResponse{Created: common.GetTimestamp()}
+	for _, result := range taskResp.Output.Results {
+		if result.Url == "" {
+			continue
+		}
+		data := dto.ImageData{Url: result.Url}
+		if b64Json {
+			_, base64Data, err := service.GetImageFromUrl(result.Url)
+			if err != nil {
+				return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponse, http.StatusInternalServerError)
+			}
+			data = dto.ImageData{B64Json: base64Data}
+		}
+		imageResp.Data = append(imageResp.Data, data)
+	}
+	if len(imageResp.Data) == 0 {
+		return nil, types.NewOpenAIError(fmt.Errorf("image task %s returned no image", taskResp.Output.TaskId), types.ErrorCodeEmptyResponse, http.StatusInternalServerError)
+	}
+
+	// 按实际成功生成的图片张数计量
+	imageCount := taskResp.Usage.ImageCount
+	if imageCount == 0 {
+		imageCount = len(imageResp.Data)
+	}
+	usage := &dto.Usage{PromptTokens: imageCount, TotalTokens: imageCount}
+
+	jsonResponse, err := common.Marshal(imageResp)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	// 提交响应的头部已不对应轮询结果，这里不复制上游头部
+	c.Writer.Header().Set("Content-Type", "application/json")
+	service.IOCopyBytesGracefully(c, nil, jsonResponse)
+	return usage, nil
+}
+
+// VideoAdaptor relays Wanx text-to-video through the async task framework.
+type VideoAdaptor struct{}
+
+func (a *VideoAdaptor) Init(info *relaycommon.RelayInfo) {
+}
+
+func (a *VideoAdaptor) GetChannelName() string {
+	return ChannelName
+}
+
+func (a *VideoAdaptor) SubmitTask(c *gin.Context, info *relaycommon.RelayInfo, request *dto.VideoRequest) (*http.Response, error) {
+	submitReq := &TaskSubmitRequest{
+		Model:      request.Model,
+		Input:      TaskInput{Prompt: request.Prompt},
+		Parameters: map[string]any{},
+	}
+	if request.Size != "" {
+		submitReq.Parameters["size"] = dashScopeSize(request.Size)
+	}
+	if request.Seconds > 0 {
+		submitReq.Parameters["duration"] = int(request.Seconds)
+	}
+	jsonData, err := common.Marshal(submitReq)
+	if err != nil {
+		return nil, err
+	}
+	header := authHeader(info)
+	header["X-DashScope-Async"] = "enable"
+	url := fmt.Sprintf("%s/api/v1/services/aigc/video-generation/video-synthesis", info.ChannelBaseUrl)
+	return channel.DoTaskSubmitRequest(c, info, url, "application/json", header, strings.NewReader(string(jsonData)))
+}
+
+func (a *VideoAdaptor) ParseSubmitResponse(resp *http.Response) (*channel.TaskInfo, error) {
+	taskResp, err := parseTaskSubmitResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	return taskResp.Output.toTaskInfo(), nil
+}
+
+func (a *VideoAdaptor) FetchTask(ctx context.Context, info *relaycommon.RelayInfo, upstreamTaskId string) (*channel.TaskInfo, error) {
+	taskResp, err := fetchTask(ctx, info, upstreamTaskId)
+	if err != nil {
+		return nil, err
+	}
+	return taskResp.Output.toTaskInfo(), nil
+}
+
+func (a *VideoAdaptor) FetchContent(ctx context.Context, info *relaycommon.RelayInfo, upstreamTaskId string) ([]byte, string, error) {
+	taskResp, err := fetchTask(ctx, info, upstreamTaskId)
+	if err != nil {
+		return nil, "", err
+	}
+	if taskResp.Output.VideoUrl == "" {
+		return nil, "", fmt.Errorf("task %s has no video_url", upstreamTaskId)
+	}
+	// video_url 是带签名的 OSS 地址，下载时不能携带 DashScope 的鉴权头
+	resp, err := channel.DoTaskBackgroundRequest(ctx, info, http.MethodGet, taskResp.Output.VideoUrl, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("download video status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("read video content failed: %w", err)
+	}
+	return data, common.GetStringIfEmpty(resp.Header.Get("Content-Type"), "video/mp4"), nil
+}
+
+func (t *TaskOutput) toTaskInfo() *channel.TaskInfo {
+	info := &channel.TaskInfo{UpstreamTaskId: t.TaskId}
+	switch t.TaskStatus {
+	case TaskStatusPending:
+		info.Status = dto.VideoStatusQueued
+	case TaskStatusSucceeded:
+		info.Status = dto.VideoStatusCompleted
+		info.Progress = 100
+	case TaskStatusFailed, TaskStatusCanceled, TaskStatusUnknown:
+		info.Status = dto.VideoStatusFailed
+		info.FailReason = t.FailReason()
+	default:
+		info.Status = dto.VideoStatusInProgress
+	}
+	return info
+}
@@ -21,6 +21,7 @@ import (
 )
 
 type Adaptor struct {
+	transcription service.TranscriptionMeta
 }
 
 func (a *Adaptor) ConvertGeminiRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeminiChatRequest) (any, error) {
@@ -59,74 +60,17 @@ func (a *Adaptor) ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayIn
 }
 
 func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.AudioRequest) (io.Reader, error) {
-	//TODO implement me
-	return nil, errors.New("not implemented")
+	return a.convertAudioRequest(c, info, request)
 }
 
 func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
-	if !strings.HasPrefix(info.UpstreamModelName, "imagen") {
-		return nil, errors.New("not supported model for image generation")
-	}
-
-	// convert size to aspect ratio but allow user to specify aspect ratio
-	aspectRatio := "1:1" // default aspect ratio
-	size := strings.TrimSpace(request.Size)
-	if size != "" {
-		if strings.Contains(size, ":") {
-			aspectRatio = size
-		} else {
-			switch size {
-			case "256x256", "512x512", "1024x1024":
-				aspectRatio = "1:1"
-			case "1536x1024":
-				aspectRatio = "3:2"
-			case "1024x1536":
-				aspectRatio = "2:3"
-			case "1024x1792":
-				aspectRatio = "9:16"
-			case "1792x1024":
-				aspectRatio = "16:9"
-			}
-		}
-	}
-
-	// build gemini imagen request
-	geminiRequest := dto.GeminiImageRequest{
-		Instances: []dto.GeminiImageInstance{
-			{
-				Prompt: request.Prompt,
-			},
-		},
-		Parameters: dto.GeminiImageParameters{
-			SampleCount:      int(request.N),
-			AspectRatio:      aspectRatio,
-			PersonGeneration: "allow_adult", // default allow adult
-		},
+	if strings.HasPrefix(info.UpstreamModelName, "imagen") {
+		return convertImagenRequest(c, info, request)
 	}
-
-	// Set imageSize when quality parameter is specified
-	// Map quality parameter to imageSize (only supported by Standard and Ultra models)
-	// quality values: auto, high, medium, low (for gpt-image-1), hd, standard (for dall-e-3)
-	// imageSize values: 1K (default), 2K
-	// https://ai.google.dev/gemini-api/docs/imagen
-	// https://platform.openai.com/docs/api-reference/images/create
-	if request.Quality != "" {
-		imageSize := "1K" // default
-		switch request.Quality {
-		case "hd", "high":
-			imageSize = "2K"
-		case "2K":
-			imageSize = "2K"
-		case "standard", "medium", "low", "auto", "1K":
-			imageSize = "1K"
-		default:
-			// unknown quality value, default to 1K
-			imageSize = "1K"
-		}
-		geminiRequest.Parameters.ImageSize = imageSize
+	if IsGeminiImageModel(info.UpstreamModelName) {
+		return convertGeminiImageRequest(c, info, request)
 	}
-
-	return geminiRequest, nil
+	return nil, errors.New("not supported model for image generation")
 }
 
 func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
@@ -162,6 +106,10 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 
 func (a *Adaptor) SetupRequestHeader(c *gin.Context, req *http.Header, info *relaycommon.RelayInfo) error {
 	channel.SetupApiRequestHeader(info, c, req)
+	// 转写请求由 multipart 转换为 JSON，不能沿用客户端的 Content-Type
+	if info.RelayMode == constant.RelayModeAudioTranscription || info.RelayMode == constant.RelayModeAudioTranslation {
+		req.Set("Content-Type", "application/json")
+	}
 	req.Set("x-goog-api-key", info.ApiKey)
 	return nil
 }
@@ -227,8 +175,21 @@ func (a *Adaptor) ConvertEmbeddingRequest(c *gin.Context, info *relaycommon.Rela
 }
 
 func (a *Adaptor) ConvertOpenAIResponsesRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.OpenAIResponsesRequest) (any, error) {
-	// TODO implement me
-	return nil, errors.New("not implemented")
+	// Responses -> Chat -> Gemini，响应在 GeminiChatHandler / 流式辅助函数中按 RelayFormat 转回 Responses
+	chatReq, toolContext, err := relaycommon.ConvertResponsesRequestToChatCompletionsRequestWithToolContext(&request)
+	if err != nil {
+		return nil, err
+	}
+	if info != nil {
+		info.OpenAIResponsesToolContext = toolContext
+		relaycommon.AppendRequestConversionFromRequest(info, chatReq)
+	}
+	geminiReq, err := a.ConvertOpenAIRequest(c, info, chatReq)
+	if err != nil {
+		return nil, err
+	}
+	relaycommon.AppendRequestConversionFromRequest(info, geminiReq)
+	return geminiReq, nil
 }
 
 func (a *Adaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, requestBody io.Reader) (any, error) {
@@ -248,9 +209,19 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 		}
 	}
 
+	switch info.RelayMode {
+	case constant.RelayModeAudioSpeech:
+		return GeminiSpeechHandler(c, info, resp)
+	case constant.RelayModeAudioTranscription, constant.RelayModeAudioTranslation:
+		return a.GeminiTranscriptionHandler(c, info, resp)
+	}
+
 	if strings.HasPrefix(info.UpstreamModelName, "imagen") {
 		return GeminiImageHandler(c, info, resp)
 	}
+	if constant.IsImageRelayMode(info.RelayMode) {
+		return GeminiImageGenerateContentHandler(c, info, resp)
+	}
 
 	// check if the model is an embedding model
 	if strings.HasPrefix(info.UpstreamModelName, "text-embedding") ||
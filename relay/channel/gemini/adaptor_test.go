@@ -6,6 +6,7 @@ import (
 	"github.com/zhongruan0522/new-api/common"
 	"github.com/zhongruan0522/new-api/dto"
 	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/types"
 )
 
 func TestAdaptorConvertClaudeRequestPreservesThinkingToolChoiceAndToolResults(t *testing.T) {
@@ -86,3 +87,59 @@ func TestAdaptorConvertClaudeRequestPreservesThinkingToolChoiceAndToolResults(t
 		t.Fatalf("function response id = %q, want call_1", toolParts[0].FunctionResponse.GetID())
 	}
 }
+
+func TestAdaptorConvertOpenAIResponsesRequestBuildsGeminiRequest(t *testing.T) {
+	request := dto.OpenAIResponsesRequest{
+		Model:           "gemini-2.5-pro",
+		Input:           []byte(`[{"type":"message","role":"user","content":"weather in Shanghai?"},{"type":"function_call","call_id":"call_1","name":"weather","arguments":"{\"city\":\"Shanghai\"}"},{"type":"function_call_output","call_id":"call_1","output":"{\"temp\":\"20\"}"}]`),
+		Instructions:    []byte(`"follow the system"`),
+		MaxOutputTokens: 512,
+		Tools:           []byte(`[{"type":"function","name":"weather","parameters":{"type":"object","properties":{"city":{"type":"string"}}}}]`),
+	}
+	info := &relaycommon.RelayInfo{
+		RelayFormat:            types.RelayFormatOpenAIResponses,
+		RequestConversionChain: []types.RelayFormat{types.RelayFormatOpenAIResponses},
+		ChannelMeta:            &relaycommon.ChannelMeta{UpstreamModelName: "gemini-2.5-pro"},
+	}
+
+	convertedAny, err := (&Adaptor{}).ConvertOpenAIResponsesRequest(nil, info, request)
+	if err != nil {
+		t.Fatalf("ConvertOpenAIResponsesRequest error = %v", err)
+	}
+	converted, ok := convertedAny.(*dto.GeminiChatRequest)
+	if !ok {
+		t.Fatalf("converted request type = %T, want *dto.GeminiChatRequest", convertedAny)
+	}
+	if converted.SystemInstructions == nil || len(converted.SystemInstructions.Parts) == 0 || converted.SystemInstructions.Parts[0].Text != "follow the system" {
+		t.Fatalf("system instruction = %+v, want follow the system", converted.SystemInstructions)
+	}
+	if converted.GenerationConfig.MaxOutputTokens != 512 {
+		t.Fatalf("maxOutputTokens = %d, want 512", converted.GenerationConfig.MaxOutputTokens)
+	}
+	if len(converted.Tools) == 0 {
+		t.Fatal("converted gemini tools are empty")
+	}
+	var sawCall, sawResult bool
+	for _, content := range converted.Contents {
+		for _, part := range content.Parts {
+			if part.FunctionCall != nil && part.FunctionCall.FunctionName == "weather" {
+				sawCall = true
+			}
+			if part.FunctionResponse != nil && part.FunctionResponse.Name == "weather" {
+				sawResult = true
+			}
+		}
+	}
+	if !sawCall || !sawResult {
+		t.Fatalf("contents = %+v, want functionCall and functionResponse for weather", converted.Contents)
+	}
+	wantChain := []types.RelayFormat{types.RelayFormatOpenAIResponses, types.RelayFormatOpenAI, types.RelayFormatGemini}
+	if len(info.RequestConversionChain) != len(wantChain) {
+		t.Fatalf("RequestConversionChain = %#v, want %#v", info.RequestConversionChain, wantChain)
+	}
+	for i := range wantChain {
+		if info.RequestConversionChain[i] != wantChain[i] {
+			t.Fatalf("RequestConversionChain = %#v, want %#v", info.RequestConversionChain, wantChain)
+		}
+	}
+}
@@ -21,6 +21,9 @@ var ModelList = []string{
 	"gemini-2.5-pro-preview-03-25",
 	// imagen models
 	"imagen-3.0-generate-002",
+	// tts models
+	"gemini-2.5-flash-preview-tts",
+	"gemini-2.5-pro-preview-tts",
 	// embedding models
 	"gemini-embedding-exp-03-07",
 	"text-embedding-004",
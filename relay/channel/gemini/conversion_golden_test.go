@@ -0,0 +1,120 @@
+package gemini
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/service"
+	"github.com/zhongruan0522/new-api/types"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files under testdata")
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("read fixture %s: %v", name, err)
+	}
+	return data
+}
+
+// assertGoldenJSON 按缩进格式与 testdata 下的 golden 文件逐字比较，go test -update 时重写
+func assertGoldenJSON(t *testing.T, name string, got []byte) {
+	t.Helper()
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, got, "", "  "); err != nil {
+		t.Fatalf("indent %s: %v", name, err)
+	}
+	indented.WriteByte('\n')
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.WriteFile(path, indented.Bytes(), 0o644); err != nil {
+			t.Fatalf("write golden %s: %v", name, err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden %s: %v", name, err)
+	}
+	if !bytes.Equal(indented.Bytes(), want) {
+		t.Fatalf("%s mismatch (go test -update to rewrite)\ngot:\n%s\nwant:\n%s", name, indented.Bytes(), want)
+	}
+}
+
+// Responses 客户端 -> Gemini 上游
+func TestResponsesClientToGeminiUpstreamGolden(t *testing.T) {
+	var request dto.OpenAIResponsesRequest
+	if err := common.Unmarshal(readFixture(t, "responses_request.json"), &request); err != nil {
+		t.Fatalf("unmarshal responses request: %v", err)
+	}
+	info := &relaycommon.RelayInfo{
+		RelayFormat:            types.RelayFormatOpenAIResponses,
+		RequestConversionChain: []types.RelayFormat{types.RelayFormatOpenAIResponses},
+		ChannelMeta:            &relaycommon.ChannelMeta{UpstreamModelName: "gemini-2.5-flash"},
+	}
+	converted, err := (&Adaptor{}).ConvertOpenAIResponsesRequest(nil, info, request)
+	if err != nil {
+		t.Fatalf("ConvertOpenAIResponsesRequest error = %v", err)
+	}
+	requestBody, err := common.Marshal(converted)
+	if err != nil {
+		t.Fatalf("marshal gemini request: %v", err)
+	}
+	assertGoldenJSON(t, "responses_request.gemini.golden.json", requestBody)
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+	c.Set(common.RequestIdKey, "golden")
+	upstream := readFixture(t, "gemini_response.json")
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(upstream)),
+	}
+	if _, apiErr := GeminiChatHandler(c, info, resp); apiErr != nil {
+		t.Fatalf("GeminiChatHandler error = %v", apiErr)
+	}
+	var responsesResp dto.OpenAIResponsesResponse
+	if err := common.Unmarshal(recorder.Body.Bytes(), &responsesResp); err != nil {
+		t.Fatalf("unmarshal responses body: %v", err)
+	}
+	responsesResp.CreatedAt = 0
+	responseBody, err := common.Marshal(responsesResp)
+	if err != nil {
+		t.Fatalf("marshal responses body: %v", err)
+	}
+	assertGoldenJSON(t, "gemini_response.responses.golden.json", responseBody)
+
+	// 引用经 Responses 转回 Gemini 后位置与来源不变
+	chatResp, err := relaycommon.ConvertResponsesResponseToChatCompletionResponse(&responsesResp)
+	if err != nil {
+		t.Fatalf("ConvertResponsesResponseToChatCompletionResponse error = %v", err)
+	}
+	back := service.ResponseOpenAI2Gemini(chatResp, info)
+	var original dto.GeminiChatResponse
+	if err := common.Unmarshal(upstream, &original); err != nil {
+		t.Fatalf("unmarshal gemini response: %v", err)
+	}
+	wantSupport := original.Candidates[0].GroundingMetadata.GroundingSupports[0]
+	gotMetadata := back.Candidates[0].GroundingMetadata
+	if gotMetadata == nil || len(gotMetadata.GroundingSupports) != 1 || gotMetadata.GroundingSupports[0].Segment != wantSupport.Segment {
+		t.Fatalf("round-tripped grounding = %+v, want segment %+v", gotMetadata, wantSupport.Segment)
+	}
+	if gotMetadata.GroundingChunks[0].Web.URI != "https://go.dev/doc/go1.26" {
+		t.Fatalf("round-tripped chunk = %+v", gotMetadata.GroundingChunks[0].Web)
+	}
+}
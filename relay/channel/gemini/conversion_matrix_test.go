@@ -0,0 +1,502 @@
+package gemini
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/relay/channel/claude"
+	"github.com/zhongruan0522/new-api/relay/channel/openai"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/relay/helper"
+	"github.com/zhongruan0522/new-api/service"
+	"github.com/zhongruan0522/new-api/types"
+)
+
+// 转换矩阵以 chat 为中间格式：客户端格式 A 由上游格式 B 服务时，请求走 A→chat→B，响应走 B→chat→A。
+// 下面对 Chat、Responses、Claude、Gemini 两两组合做 A→B→A 往返，
+// 校验请求、非流式响应与流式响应中的文本、推理、工具调用与用量在往返后不变。
+var matrixFormats = []types.RelayFormat{
+	types.RelayFormatOpenAI,
+	types.RelayFormatOpenAIResponses,
+	types.RelayFormatClaude,
+	types.RelayFormatGemini,
+}
+
+func forEachMatrixPair(t *testing.T, fn func(t *testing.T, client, upstream types.RelayFormat)) {
+	for _, client := range matrixFormats {
+		for _, upstream := range matrixFormats {
+			if client == upstream {
+				continue
+			}
+			t.Run(fmt.Sprintf("%s->%s->%s", client, upstream, client), func(t *testing.T) {
+				fn(t, client, upstream)
+			})
+		}
+	}
+}
+
+func newMatrixContext() *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+	return c
+}
+
+func newMatrixInfo(format types.RelayFormat) *relaycommon.RelayInfo {
+	return &relaycommon.RelayInfo{
+		RelayFormat:        format,
+		ShouldIncludeUsage: true,
+		ClaudeConvertInfo:  &relaycommon.ClaudeConvertInfo{LastMessagesType: relaycommon.LastMessageTypeNone},
+		GeminiConvertInfo:  &relaycommon.GeminiConvertInfo{},
+		ChannelMeta:        &relaycommon.ChannelMeta{UpstreamModelName: "matrix-model"},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// 请求
+// ---------------------------------------------------------------------------
+
+func matrixChatRequest() *dto.GeneralOpenAIRequest {
+	assistant := dto.Message{Role: "assistant", Content: "Let me check."}
+	assistant.SetToolCalls([]dto.ToolCallRequest{{
+		ID:       "call_weather",
+		Type:     "function",
+		Function: dto.FunctionRequest{Name: "weather", Arguments: `{"city":"Shanghai"}`},
+	}})
+	return &dto.GeneralOpenAIRequest{
+		Model:     "matrix-model",
+		MaxTokens: 256,
+		Messages: []dto.Message{
+			{Role: "system", Content: "Be brief."},
+			{Role: "user", Content: "Weather in Shanghai?"},
+			assistant,
+			{Role: "tool", ToolCallId: "call_weather", Content: "sunny"},
+			{Role: "user", Content: "Thanks"},
+		},
+		Tools: []dto.ToolCallRequest{{
+			Type: "function",
+			Function: dto.FunctionRequest{
+				Name:        "weather",
+				Description: "Get the weather",
+				Parameters:  map[string]any{"type": "object", "properties": map[string]any{"city": map[string]any{"type": "string"}}},
+			},
+		}},
+	}
+}
+
+func requestFromChat(t *testing.T, format types.RelayFormat, request *dto.GeneralOpenAIRequest) any {
+	t.Helper()
+	var (
+		converted any
+		err       error
+	)
+	switch format {
+	case types.RelayFormatOpenAI:
+		converted, err = common.DeepCopy(request)
+	case types.RelayFormatOpenAIResponses:
+		converted, err = relaycommon.ConvertChatCompletionsRequestToResponsesRequest(request)
+	case types.RelayFormatClaude:
+		converted, err = claude.RequestOpenAI2ClaudeMessage(newMatrixContext(), *request)
+	case types.RelayFormatGemini:
+		converted, err = CovertOpenAI2Gemini(newMatrixContext(), *request, newMatrixInfo(format))
+	}
+	if err != nil {
+		t.Fatalf("convert chat request to %s: %v", format, err)
+	}
+	return converted
+}
+
+func requestToChat(t *testing.T, format types.RelayFormat, request any) *dto.GeneralOpenAIRequest {
+	t.Helper()
+	var (
+		converted *dto.GeneralOpenAIRequest
+		err       error
+	)
+	switch format {
+	case types.RelayFormatOpenAI:
+		converted = request.(*dto.GeneralOpenAIRequest)
+	case types.RelayFormatOpenAIResponses:
+		converted, err = relaycommon.ConvertResponsesRequestToChatCompletionsRequest(request.(*dto.OpenAIResponsesRequest))
+	case types.RelayFormatClaude:
+		converted, err = service.ClaudeToOpenAIRequest(*request.(*dto.ClaudeRequest), newMatrixInfo(format))
+	case types.RelayFormatGemini:
+		converted, err = service.GeminiToOpenAIRequest(request.(*dto.GeminiChatRequest), newMatrixInfo(format))
+	}
+	if err != nil {
+		t.Fatalf("convert %s request to chat: %v", format, err)
+	}
+	return converted
+}
+
+// requestDigest 把 chat 请求归一为可比较的行：工具调用 id 换成序号，参数按 JSON 归一
+func requestDigest(t *testing.T, request *dto.GeneralOpenAIRequest) []string {
+	t.Helper()
+	callIndex := make(map[string]int)
+	var lines []string
+	for _, message := range request.Messages {
+		// 转换器可能产出字符串或内容数组，只比较其中的文本
+		var text strings.Builder
+		for _, content := range message.ParseContent() {
+			if content.Type == dto.ContentTypeText {
+				text.WriteString(content.Text)
+			}
+		}
+		line := message.Role + ": " + text.String()
+		for _, call := range message.ParseToolCalls() {
+			callIndex[call.ID] = len(callIndex)
+			line += fmt.Sprintf(" call#%d %s(%s)", callIndex[call.ID], call.Function.Name, normalizeMatrixJSON(t, call.Function.Arguments))
+		}
+		if message.ToolCallId != "" {
+			index, ok := callIndex[message.ToolCallId]
+			if !ok {
+				t.Fatalf("tool result references unknown call %q", message.ToolCallId)
+			}
+			line += fmt.Sprintf(" result-of#%d", index)
+		}
+		lines = append(lines, line)
+	}
+	for _, tool := range request.Tools {
+		lines = append(lines, "tool: "+tool.Function.Name)
+	}
+	return lines
+}
+
+func TestConversionMatrixRequestRoundTrip(t *testing.T) {
+	forEachMatrixPair(t, func(t *testing.T, client, upstream types.RelayFormat) {
+		original := requestFromChat(t, client, matrixChatRequest())
+		want := requestDigest(t, requestToChat(t, client, original))
+
+		upstreamRequest := requestFromChat(t, upstream, requestToChat(t, client, original))
+		back := requestFromChat(t, client, requestToChat(t, upstream, upstreamRequest))
+		got := requestDigest(t, requestToChat(t, client, back))
+
+		if !slices.Equal(got, want) {
+			t.Fatalf("round trip changed the request\ngot:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	})
+}
+
+// ---------------------------------------------------------------------------
+// 非流式响应
+// ---------------------------------------------------------------------------
+
+func matrixChatResponse() *dto.OpenAITextResponse {
+	message := dto.Message{Role: "assistant", Content: "hello", ReasoningContent: "think"}
+	message.SetToolCalls([]dto.ToolCallRequest{{
+		ID:       "call_weather",
+		Type:     "function",
+		Function: dto.FunctionRequest{Name: "weather", Arguments: `{"city":"Shanghai"}`},
+	}})
+	return &dto.OpenAITextResponse{
+		Id:      "chatcmpl_matrix",
+		Object:  "chat.completion",
+		Created: 1700000000,
+		Model:   "matrix-model",
+		Choices: []dto.OpenAITextResponseChoice{{Message: message, FinishReason: "tool_calls"}},
+		Usage:   dto.Usage{PromptTokens: 10, CompletionTokens: 4, TotalTokens: 14},
+	}
+}
+
+func responseFromChat(t *testing.T, format types.RelayFormat, response *dto.OpenAITextResponse) any {
+	t.Helper()
+	switch format {
+	case types.RelayFormatOpenAI:
+		copied, err := common.DeepCopy(response)
+		if err != nil {
+			t.Fatalf("copy chat response: %v", err)
+		}
+		return copied
+	case types.RelayFormatOpenAIResponses:
+		converted, err := relaycommon.ConvertChatCompletionResponseToResponsesResponse(response)
+		if err != nil {
+			t.Fatalf("convert chat response to responses: %v", err)
+		}
+		return converted
+	case types.RelayFormatClaude:
+		return service.ResponseOpenAI2Claude(response, newMatrixInfo(format))
+	default:
+		return service.ResponseOpenAI2Gemini(response, newMatrixInfo(format))
+	}
+}
+
+func responseToChat(t *testing.T, format types.RelayFormat, response any) *dto.OpenAITextResponse {
+	t.Helper()
+	switch format {
+	case types.RelayFormatOpenAI:
+		return response.(*dto.OpenAITextResponse)
+	case types.RelayFormatOpenAIResponses:
+		converted, err := relaycommon.ConvertResponsesResponseToChatCompletionResponse(response.(*dto.OpenAIResponsesResponse))
+		if err != nil {
+			t.Fatalf("convert responses response to chat: %v", err)
+		}
+		return converted
+	case types.RelayFormatClaude:
+		claudeResp := response.(*dto.ClaudeResponse)
+		converted := claude.ResponseClaude2OpenAI(claudeResp)
+		if claudeResp.Usage != nil {
+			converted.Usage = dto.Usage{
+				PromptTokens:     claudeResp.Usage.InputTokens,
+				CompletionTokens: claudeResp.Usage.OutputTokens,
+				TotalTokens:      claudeResp.Usage.InputTokens + claudeResp.Usage.OutputTokens,
+			}
+		}
+		return converted
+	default:
+		geminiResp := response.(*dto.GeminiChatResponse)
+		converted := responseGeminiChat2OpenAI(newMatrixContext(), geminiResp)
+		converted.Usage = service.GeminiUsageMetadataToOpenAIUsage(geminiResp.UsageMetadata)
+		return converted
+	}
+}
+
+func responseDigest(t *testing.T, response *dto.OpenAITextResponse) []string {
+	t.Helper()
+	var lines []string
+	for _, choice := range response.Choices {
+		lines = append(lines,
+			"content: "+choice.StringContent(),
+			"reasoning: "+choice.ReasoningContent,
+			"finish: "+choice.FinishReason,
+		)
+		for _, call := range choice.ParseToolCalls() {
+			lines = append(lines, fmt.Sprintf("call: %s(%s)", call.Function.Name, normalizeMatrixJSON(t, call.Function.Arguments)))
+		}
+	}
+	lines = append(lines, fmt.Sprintf("usage: %d/%d", response.Usage.PromptTokens, response.Usage.CompletionTokens))
+	return lines
+}
+
+func TestConversionMatrixResponseRoundTrip(t *testing.T) {
+	forEachMatrixPair(t, func(t *testing.T, client, upstream types.RelayFormat) {
+		original := responseFromChat(t, client, matrixChatResponse())
+		want := responseDigest(t, responseToChat(t, client, original))
+
+		upstreamResponse := responseFromChat(t, upstream, responseToChat(t, client, original))
+		back := responseFromChat(t, client, responseToChat(t, upstream, upstreamResponse))
+		got := responseDigest(t, responseToChat(t, client, back))
+
+		if !slices.Equal(got, want) {
+			t.Fatalf("round trip changed the response\ngot:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	})
+}
+
+// ---------------------------------------------------------------------------
+// 流式响应
+// ---------------------------------------------------------------------------
+
+func matrixChatStreamChunks() []*dto.ChatCompletionsStreamResponse {
+	newChunk := func(delta dto.ChatCompletionsStreamResponseChoiceDelta, finishReason *string) *dto.ChatCompletionsStreamResponse {
+		return &dto.ChatCompletionsStreamResponse{
+			Id:      "chatcmpl_matrix",
+			Object:  "chat.completion.chunk",
+			Created: 1700000000,
+			Model:   "matrix-model",
+			Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: delta, FinishReason: finishReason}},
+		}
+	}
+	var text dto.ChatCompletionsStreamResponseChoiceDelta
+	text.SetContentString("hello")
+	return []*dto.ChatCompletionsStreamResponse{
+		newChunk(dto.ChatCompletionsStreamResponseChoiceDelta{Role: "assistant", ReasoningContent: common.GetPointer("think")}, nil),
+		newChunk(text, nil),
+		newChunk(dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: []dto.ToolCallResponse{{
+			Index:    common.GetPointer(0),
+			ID:       "call_weather",
+			Type:     "function",
+			Function: dto.FunctionResponse{Name: "weather", Arguments: `{"city":"Shanghai"}`},
+		}}}, nil),
+		newChunk(dto.ChatCompletionsStreamResponseChoiceDelta{}, common.GetPointer("tool_calls")),
+	}
+}
+
+// streamFromChat 用 relay 实际使用的 HandleStreamFormat 把 chat 块写成目标格式的 SSE
+func streamFromChat(t *testing.T, format types.RelayFormat, chunks []*dto.ChatCompletionsStreamResponse) string {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+	info := newMatrixInfo(format)
+	var last string
+	for _, chunk := range chunks {
+		data, err := common.Marshal(chunk)
+		if err != nil {
+			t.Fatalf("marshal chunk: %v", err)
+		}
+		last = string(data)
+		if err := openai.HandleStreamFormat(c, info, last, false, false); err != nil {
+			t.Fatalf("HandleStreamFormat(%s): %v", format, err)
+		}
+	}
+	usage := &dto.Usage{PromptTokens: 10, CompletionTokens: 4, TotalTokens: 14}
+	final, err := common.Marshal(helper.GenerateFinalUsageResponse("chatcmpl_matrix", 1700000000, "matrix-model", *usage))
+	if err != nil {
+		t.Fatalf("marshal final chunk: %v", err)
+	}
+	openai.HandleFinalResponse(c, info, string(final), "chatcmpl_matrix", 1700000000, "matrix-model", "", usage, false)
+	return recorder.Body.String()
+}
+
+type matrixSSEFrame struct {
+	event string
+	data  string
+}
+
+func splitMatrixSSE(stream string) []matrixSSEFrame {
+	var frames []matrixSSEFrame
+	var current matrixSSEFrame
+	scanner := bufio.NewScanner(strings.NewReader(stream))
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if current.data != "" {
+				frames = append(frames, current)
+			}
+			current = matrixSSEFrame{}
+		case strings.HasPrefix(line, "event:"):
+			current.event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			current.data = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		}
+	}
+	if current.data != "" {
+		frames = append(frames, current)
+	}
+	return frames
+}
+
+// streamToChat 把上游格式的 SSE 转回 chat 块，使用各渠道流式处理器中的转换函数
+func streamToChat(t *testing.T, format types.RelayFormat, stream string) []*dto.ChatCompletionsStreamResponse {
+	t.Helper()
+	var chunks []*dto.ChatCompletionsStreamResponse
+	appendChatData := func(data string) {
+		if data == "" || data == "[DONE]" {
+			return
+		}
+		var chunk dto.ChatCompletionsStreamResponse
+		if err := common.UnmarshalJsonStr(data, &chunk); err != nil {
+			t.Fatalf("unmarshal chat chunk %s: %v", data, err)
+		}
+		chunks = append(chunks, &chunk)
+	}
+
+	switch format {
+	case types.RelayFormatOpenAI:
+		for _, frame := range splitMatrixSSE(stream) {
+			appendChatData(frame.data)
+		}
+	case types.RelayFormatOpenAIResponses:
+		converter := relaycommon.NewResponsesToChatStreamConverter(true)
+		for _, frame := range splitMatrixSSE(stream) {
+			raw := "event: " + frame.event + "\ndata: " + frame.data + "\n\n"
+			out, err := converter.ConvertFrame(frame.event, frame.data, raw)
+			if err != nil {
+				t.Fatalf("convert responses frame: %v", err)
+			}
+			for _, converted := range splitMatrixSSE(out) {
+				appendChatData(converted.data)
+			}
+		}
+	case types.RelayFormatClaude:
+		// 与 claude 流处理器一致：转换后再由 FormatClaudeResponseInfo 补全 id 并重排工具调用序号
+		claudeInfo := &claude.ClaudeResponseInfo{}
+		for _, frame := range splitMatrixSSE(stream) {
+			var event dto.ClaudeResponse
+			if err := common.UnmarshalJsonStr(frame.data, &event); err != nil {
+				t.Fatalf("unmarshal claude event %s: %v", frame.data, err)
+			}
+			chunk := claude.StreamResponseClaude2OpenAI(&event)
+			if !claude.FormatClaudeResponseInfo(&event, chunk, claudeInfo) || chunk == nil {
+				continue
+			}
+			chunks = append(chunks, chunk)
+		}
+	case types.RelayFormatGemini:
+		for _, frame := range splitMatrixSSE(stream) {
+			var event dto.GeminiChatResponse
+			if err := common.UnmarshalJsonStr(frame.data, &event); err != nil {
+				t.Fatalf("unmarshal gemini event %s: %v", frame.data, err)
+			}
+			chunk, isStop := streamResponseGeminiChat2OpenAI(&event)
+			if chunk != nil {
+				chunks = append(chunks, chunk)
+			}
+			if isStop {
+				chunks = append(chunks, helper.GenerateStopResponse("chatcmpl_matrix", 1700000000, "matrix-model", "stop"))
+			}
+		}
+	}
+	return chunks
+}
+
+// streamDigest 聚合 chat 块后比较文本、推理与工具调用
+func streamDigest(t *testing.T, chunks []*dto.ChatCompletionsStreamResponse) []string {
+	t.Helper()
+	var content, reasoning strings.Builder
+	type call struct{ name, args string }
+	var calls []call
+	for _, chunk := range chunks {
+		for _, choice := range chunk.Choices {
+			content.WriteString(choice.Delta.GetContentString())
+			reasoning.WriteString(choice.Delta.GetReasoningContent())
+			for _, delta := range choice.Delta.ToolCalls {
+				index := len(calls)
+				if delta.Index != nil {
+					index = *delta.Index
+				}
+				for len(calls) <= index {
+					calls = append(calls, call{})
+				}
+				calls[index].name += delta.Function.Name
+				calls[index].args += delta.Function.Arguments
+			}
+		}
+	}
+	lines := []string{"content: " + content.String(), "reasoning: " + reasoning.String()}
+	for _, c := range calls {
+		lines = append(lines, fmt.Sprintf("call: %s(%s)", c.name, normalizeMatrixJSON(t, c.args)))
+	}
+	return lines
+}
+
+func TestConversionMatrixStreamRoundTrip(t *testing.T) {
+	forEachMatrixPair(t, func(t *testing.T, client, upstream types.RelayFormat) {
+		original := streamFromChat(t, client, matrixChatStreamChunks())
+		want := streamDigest(t, streamToChat(t, client, original))
+
+		upstreamStream := streamFromChat(t, upstream, streamToChat(t, client, original))
+		back := streamFromChat(t, client, streamToChat(t, upstream, upstreamStream))
+		got := streamDigest(t, streamToChat(t, client, back))
+
+		if !slices.Equal(got, want) {
+			t.Fatalf("round trip changed the stream\ngot:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	})
+}
+
+func normalizeMatrixJSON(t *testing.T, raw string) string {
+	t.Helper()
+	if strings.TrimSpace(raw) == "" {
+		return ""
+	}
+	var v any
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		t.Fatalf("invalid JSON %q: %v", raw, err)
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal JSON: %v", err)
+	}
+	return string(out)
+}
@@ -0,0 +1,208 @@
+package gemini
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	relayconstant "github.com/zhongruan0522/new-api/relay/constant"
+	"github.com/zhongruan0522/new-api/service"
+	"github.com/zhongruan0522/new-api/setting/model_setting"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Gemini TTS 输出 24kHz、16-bit、单声道 PCM
+const geminiTTSSampleRate = 24000
+
+const (
+	geminiTranscribePrompt = "Generate a verbatim transcript of the speech in this audio. Output only the transcript text."
+	geminiTranslatePrompt  = "Translate the speech in this audio into English. Output only the English translation."
+)
+
+// convertGeminiSpeechRequest 将 /v1/audio/speech 请求转换为 Gemini 原生 TTS 请求
+func convertGeminiSpeechRequest(request dto.AudioRequest) (*dto.GeminiChatRequest, error) {
+	switch request.ResponseFormat {
+	case "", "wav", "pcm":
+	default:
+		return nil, fmt.Errorf("gemini tts only supports wav and pcm response_format, got %s", request.ResponseFormat)
+	}
+	if request.StreamFormat == "sse" {
+		return nil, errors.New("gemini tts does not support stream_format sse")
+	}
+	text := request.Input
+	// Gemini TTS 通过自然语言控制语气，instructions 直接放在文本前面
+	if instructions := strings.TrimSpace(request.Instructions); instructions != "" {
+		text = instructions + ": " + text
+	}
+
+	speechConfig, err := common.Marshal(map[string]any{
+		"voiceConfig": map[string]any{
+			"prebuiltVoiceConfig": map[string]any{
+				"voiceName": model_setting.GetProviderVoice(ChannelName, request.Voice),
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal speech_config: %w", err)
+	}
+	return &dto.GeminiChatRequest{
+		Contents: []dto.GeminiChatContent{{Role: "user", Parts: []dto.GeminiPart{{Text: text}}}},
+		GenerationConfig: dto.GeminiChatGenerationConfig{
+			ResponseModalities: []string{"AUDIO"},
+			SpeechConfig:       speechConfig,
+		},
+	}, nil
+}
+
+// convertGeminiTranscriptionRequest 利用 Gemini 的音频理解实现转写与翻译
+func convertGeminiTranscriptionRequest(info *relaycommon.RelayInfo, request dto.AudioRequest, input *service.AudioFileInput) (*dto.GeminiChatRequest, error) {
+	if !service.IsSupportedTranscriptionFormat(request.ResponseFormat) {
+		return nil, fmt.Errorf("gemini transcription does not support response_format %s", request.ResponseFormat)
+	}
+	prompt := geminiTranscribePrompt
+	if info.RelayMode == relayconstant.RelayModeAudioTranslation {
+		prompt = geminiTranslatePrompt
+	} else if input.Language != "" {
+		prompt += " The audio language is " + input.Language + "."
+	}
+	if input.Prompt != "" {
+		prompt += "\nContext: " + input.Prompt
+	}
+	temperature := 0.0
+	return &dto.GeminiChatRequest{
+		Contents: []dto.GeminiChatContent{{
+			Role: "user",
+			Parts: []dto.GeminiPart{
+				{InlineData: &dto.GeminiInlineData{MimeType: input.MimeType, Data: base64.StdEncoding.EncodeToString(input.Data)}},
+				{Text: prompt},
+			},
+		}},
+		GenerationConfig: dto.GeminiChatGenerationConfig{
+			Temperature: &temperature,
+		},
+	}, nil
+}
+
+func (a *Adaptor) convertAudioRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.AudioRequest) (io.Reader, error) {
+	var geminiRequest *dto.GeminiChatRequest
+	var err error
+	switch info.RelayMode {
+	case relayconstant.RelayModeAudioSpeech:
+		geminiRequest, err = convertGeminiSpeechRequest(request)
+	case relayconstant.RelayModeAudioTranscription, relayconstant.RelayModeAudioTranslation:
+		input, inputErr := service.GetAudioFileInput(c)
+		if inputErr != nil {
+			return nil, inputErr
+		}
+		a.transcription = input.TranscriptionMeta()
+		geminiRequest, err = convertGeminiTranscriptionRequest(info, request, input)
+	default:
+		return nil, errors.New("unsupported audio relay mode")
+	}
+	if err != nil {
+		return nil, err
+	}
+	jsonData, err := common.Marshal(geminiRequest)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling gemini audio request: %w", err)
+	}
+	return bytes.NewReader(jsonData), nil
+}
+
+func readGeminiAudioResponse(resp *http.Response) (*dto.GeminiChatResponse, *types.NewAPIError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+	}
+	service.CloseResponseBodyGracefully(resp)
+	return parseGeminiAudioResponse(responseBody)
+}
+
+func parseGeminiAudioResponse(responseBody []byte) (*dto.GeminiChatResponse, *types.NewAPIError) {
+	var geminiResponse dto.GeminiChatResponse
+	if err := common.Unmarshal(responseBody, &geminiResponse); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	if len(geminiResponse.Candidates) == 0 {
+		if geminiResponse.PromptFeedback != nil && geminiResponse.PromptFeedback.BlockReason != nil {
+			return nil, types.NewOpenAIError(errors.New("request blocked by Gemini API: "+*geminiResponse.PromptFeedback.BlockReason), types.ErrorCodePromptBlocked, http.StatusBadRequest)
+		}
+		return nil, types.NewOpenAIError(errors.New("empty response from Gemini API"), types.ErrorCodeEmptyResponse, http.StatusInternalServerError)
+	}
+	return &geminiResponse, nil
+}
+
+// geminiPCMSampleRate 从 audio/L16;codec=pcm;rate=24000 中解析采样率
+func geminiPCMSampleRate(mimeType string) int {
+	for _, param := range strings.Split(mimeType, ";") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(param), "rate="); ok {
+			if rate, err := strconv.Atoi(value); err == nil && rate > 0 {
+				return rate
+			}
+		}
+	}
+	return geminiTTSSampleRate
+}
+
+// GeminiSpeechHandler 输出 Gemini TTS 合成的音频，默认封装为 wav，按音频时长计费
+func GeminiSpeechHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	geminiResponse, apiErr := readGeminiAudioResponse(resp)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	var pcm []byte
+	sampleRate := geminiTTSSampleRate
+	for _, part := range geminiResponse.Candidates[0].Content.Parts {
+		if part.InlineData == nil || !strings.HasPrefix(part.InlineData.MimeType, "audio/") {
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(part.InlineData.Data)
+		if err != nil {
+			return nil, types.NewOpenAIError(fmt.Errorf("decode gemini audio failed: %w", err), types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+		}
+		sampleRate = geminiPCMSampleRate(part.InlineData.MimeType)
+		pcm = append(pcm, data...)
+	}
+	if len(pcm) == 0 {
+		return nil, types.NewOpenAIError(errors.New("no audio data in gemini response"), types.ErrorCodeEmptyResponse, http.StatusInternalServerError)
+	}
+
+	format := "wav"
+	if audioReq, ok := info.Request.(*dto.AudioRequest); ok && audioReq.ResponseFormat == "pcm" {
+		format = "pcm"
+	}
+	if format == "pcm" {
+		c.Data(http.StatusOK, "audio/pcm", pcm)
+	} else {
+		c.Data(http.StatusOK, "audio/wav", service.PCMToWAV(pcm, sampleRate, 1))
+	}
+	return service.BuildSpeechUsage(c, info, pcm, "pcm", sampleRate), nil
+}
+
+// GeminiTranscriptionHandler 将 Gemini 的音频理解结果按 OpenAI 转写格式输出，按输入音频时长计费
+func (a *Adaptor) GeminiTranscriptionHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	return service.HandleTranscriptionResponse(c, info, resp, a.transcription, func(body []byte) (string, *types.NewAPIError) {
+		geminiResponse, apiErr := parseGeminiAudioResponse(body)
+		if apiErr != nil {
+			return "", apiErr
+		}
+		var text strings.Builder
+		for _, part := range geminiResponse.Candidates[0].Content.Parts {
+			if part.Text != "" && !part.Thought {
+				text.WriteString(part.Text)
+			}
+		}
+		return strings.TrimSpace(text.String()), nil
+	})
+}
@@ -0,0 +1,271 @@
+package gemini
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/dto"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	relayconstant "github.com/zhongruan0522/new-api/relay/constant"
+	"github.com/zhongruan0522/new-api/service"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const geminiImageVariationPrompt = "Generate a variation of this image."
+
+// IsGeminiImageModel 判断是否为通过 generateContent 输出图片的 Gemini 模型，例如 gemini-2.5-flash-image
+func IsGeminiImageModel(modelName string) bool {
+	return strings.HasPrefix(modelName, "gemini") && strings.Contains(modelName, "image")
+}
+
+// openAISizeToAspectRatio 将 OpenAI 的 size 转换为宽高比，允许直接传入 "16:9" 这样的宽高比
+func openAISizeToAspectRatio(size string) string {
+	size = strings.TrimSpace(size)
+	if size == "" {
+		return ""
+	}
+	if strings.Contains(size, ":") {
+		return size
+	}
+	switch size {
+	case "1536x1024":
+		return "3:2"
+	case "1024x1536":
+		return "2:3"
+	case "1024x1792":
+		return "9:16"
+	case "1792x1024":
+		return "16:9"
+	default:
+		return "1:1"
+	}
+}
+
+// openAIQualityToImageSize 将 quality 映射为 imageSize
+// quality values: auto, high, medium, low (for gpt-image-1), hd, standard (for dall-e-3)
+// imageSize values: 1K (default), 2K
+// https://ai.google.dev/gemini-api/docs/imagen
+// https://platform.openai.com/docs/api-reference/images/create
+func openAIQualityToImageSize(quality string) string {
+	switch quality {
+	case "":
+		return ""
+	case "hd", "high", "2K":
+		return "2K"
+	default:
+		// unknown quality value, default to 1K
+		return "1K"
+	}
+}
+
+// imagenEditModel 是 Imagen 中唯一支持编辑的模型
+const imagenEditModel = "imagen-3.0-capability-001"
+
+func isImagenEditModel(modelName string) bool {
+	return strings.HasPrefix(modelName, "imagen-3.0-capability")
+}
+
+func convertImagenRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (*dto.GeminiImageRequest, error) {
+	aspectRatio := openAISizeToAspectRatio(request.Size)
+	if aspectRatio == "" {
+		aspectRatio = "1:1"
+	}
+	geminiRequest := &dto.GeminiImageRequest{
+		Instances: []dto.GeminiImageInstance{
+			{
+				Prompt: request.Prompt,
+			},
+		},
+		Parameters: dto.GeminiImageParameters{
+			SampleCount:      int(request.N),
+			AspectRatio:      aspectRatio,
+			PersonGeneration: "allow_adult", // default allow adult
+			// imageSize only supported by Standard and Ultra models
+			ImageSize: openAIQualityToImageSize(request.Quality),
+		},
+	}
+
+	switch info.RelayMode {
+	case relayconstant.RelayModeImagesVariations:
+		return nil, errors.New("imagen does not support image variations")
+	case relayconstant.RelayModeImagesEdits:
+		// Imagen 编辑（imagen-3.0-capability-001）只在 Vertex AI 上提供，生成模型不接受参考图
+		if info.ChannelType != constant.ChannelTypeVertexAi {
+			return nil, errors.New("imagen image edits are only supported on Vertex AI")
+		}
+		if !isImagenEditModel(info.UpstreamModelName) {
+			return nil, fmt.Errorf("imagen model %s does not support image edits, use %s", info.UpstreamModelName, imagenEditModel)
+		}
+		images, mask, err := service.GetImageEditInputs(c, &request)
+		if err != nil {
+			return nil, err
+		}
+		references := []dto.GeminiImageReference{{
+			ReferenceType:  "REFERENCE_TYPE_RAW",
+			ReferenceId:    1,
+			ReferenceImage: dto.GeminiImageBytes{BytesBase64Encoded: images[0].Base64},
+		}}
+		editMode := "EDIT_MODE_DEFAULT"
+		if mask != nil {
+			maskData, err := imagenMaskFromOpenAI(mask)
+			if err != nil {
+				return nil, err
+			}
+			references = append(references, dto.GeminiImageReference{
+				ReferenceType:   "REFERENCE_TYPE_MASK",
+				ReferenceId:     2,
+				ReferenceImage:  dto.GeminiImageBytes{BytesBase64Encoded: maskData},
+				MaskImageConfig: &dto.GeminiMaskImageConfig{MaskMode: "MASK_MODE_USER_PROVIDED"},
+			})
+			editMode = "EDIT_MODE_INPAINT_INSERTION"
+		}
+		geminiRequest.Instances[0].ReferenceImages = references
+		geminiRequest.Parameters.EditMode = editMode
+		// 编辑结果沿用原图尺寸
+		geminiRequest.Parameters.AspectRatio = ""
+	}
+	return geminiRequest, nil
+}
+
+// imagenMaskFromOpenAI 转换蒙版语义：OpenAI 以透明区域表示待编辑部分，Imagen 以白色区域表示
+func imagenMaskFromOpenAI(mask *service.ImageEditInput) (string, error) {
+	data, err := mask.Bytes()
+	if err != nil {
+		return "", fmt.Errorf("decode mask failed: %w", err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("mask must be a png image with alpha channel: %w", err)
+	}
+	bounds := img.Bounds()
+	converted := image.NewGray(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, alpha := img.At(x, y).RGBA(); alpha < 0x8000 {
+				converted.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, converted); err != nil {
+		return "", fmt.Errorf("encode mask failed: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// convertGeminiImageRequest 将 Images API 请求转换为 Gemini 图像模型的 generateContent 请求，
+// 编辑和变体时输入图片作为 inlineData 放在提示词之前
+func convertGeminiImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (*dto.GeminiChatRequest, error) {
+	var parts []dto.GeminiPart
+	prompt := strings.TrimSpace(request.Prompt)
+	if info.RelayMode == relayconstant.RelayModeImagesEdits || info.RelayMode == relayconstant.RelayModeImagesVariations {
+		images, mask, err := service.GetImageEditInputs(c, &request)
+		if err != nil {
+			return nil, err
+		}
+		if mask != nil {
+			return nil, errors.New("mask is not supported by gemini image models")
+		}
+		for _, img := range images {
+			parts = append(parts, dto.GeminiPart{InlineData: &dto.GeminiInlineData{MimeType: img.MimeType, Data: img.Base64}})
+		}
+		if prompt == "" && info.RelayMode == relayconstant.RelayModeImagesVariations {
+			prompt = geminiImageVariationPrompt
+		}
+	}
+	if prompt == "" {
+		return nil, errors.New("prompt is required")
+	}
+	parts = append(parts, dto.GeminiPart{Text: prompt})
+
+	geminiRequest := &dto.GeminiChatRequest{
+		Contents: []dto.GeminiChatContent{{Role: "user", Parts: parts}},
+		GenerationConfig: dto.GeminiChatGenerationConfig{
+			ResponseModalities: []string{"TEXT", "IMAGE"},
+		},
+	}
+	imageConfig := make(map[string]any)
+	if aspectRatio := openAISizeToAspectRatio(request.Size); aspectRatio != "" {
+		imageConfig["aspectRatio"] = aspectRatio
+	}
+	// 只有显式指定分辨率时才设置 imageSize，部分 flash 图像模型不支持该参数
+	switch request.Quality {
+	case "1K", "2K", "4K":
+		imageConfig["imageSize"] = request.Quality
+	}
+	if len(imageConfig) > 0 {
+		imageConfigBytes, err := common.Marshal(imageConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal image_config: %w", err)
+		}
+		geminiRequest.GenerationConfig.ImageConfig = imageConfigBytes
+	}
+	return geminiRequest, nil
+}
+
+// GeminiImageGenerateContentHandler 将 Gemini 图像模型的 generateContent 响应转换为 OpenAI Images 响应，
+// 模型附带的文字说明放在 revised_prompt 中
+func GeminiImageGenerateContentHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	responseBody, readErr := io.ReadAll(resp.Body)
+	if readErr != nil {
+		return nil, types.NewOpenAIError(readErr, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	service.CloseResponseBodyGracefully(resp)
+
+	var geminiResponse dto.GeminiChatResponse
+	if jsonErr := common.Unmarshal(responseBody, &geminiResponse); jsonErr != nil {
+		return nil, types.NewOpenAIError(jsonErr, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+
+	openAIResponse := dto.ImageResponse{
+		Created: common.GetTimestamp(),
+	}
+	var text strings.Builder
+	for _, candidate := range geminiResponse.Candidates {
+		for _, part := range candidate.Content.Parts {
+			if part.InlineData != nil && strings.HasPrefix(part.InlineData.MimeType, "image/") {
+				openAIResponse.Data = append(openAIResponse.Data, dto.ImageData{B64Json: part.InlineData.Data})
+			} else if part.Text != "" && !part.Thought {
+				text.WriteString(part.Text)
+			}
+		}
+	}
+	if len(openAIResponse.Data) == 0 {
+		if geminiResponse.PromptFeedback != nil && geminiResponse.PromptFeedback.BlockReason != nil {
+			return nil, types.NewOpenAIError(errors.New("request blocked by Gemini API: "+*geminiResponse.PromptFeedback.BlockReason), types.ErrorCodePromptBlocked, http.StatusBadRequest)
+		}
+		return nil, types.NewOpenAIError(errors.New("no images generated"), types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	if revisedPrompt := strings.TrimSpace(text.String()); revisedPrompt != "" {
+		for i := range openAIResponse.Data {
+			openAIResponse.Data[i].RevisedPrompt = revisedPrompt
+		}
+	}
+
+	jsonResponse, jsonErr := common.Marshal(openAIResponse)
+	if jsonErr != nil {
+		return nil, types.NewError(jsonErr, types.ErrorCodeBadResponseBody)
+	}
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.WriteHeader(resp.StatusCode)
+	_, _ = c.Writer.Write(jsonResponse)
+
+	usage := service.GeminiUsageMetadataToOpenAIUsage(geminiResponse.UsageMetadata)
+	if usage.PromptTokens <= 0 {
+		usage.PromptTokens = info.GetEstimatePromptTokens()
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	return &usage, nil
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/zhongruan0522/new-api/relay/channel/openrouter"
 	relaycommon "github.com/zhongruan0522/new-api/relay/common"
 	"github.com/zhongruan0522/new-api/relay/helper"
+	"github.com/zhongruan0522/new-api/relay/reasoning"
 	"github.com/zhongruan0522/new-api/service"
 	"github.com/zhongruan0522/new-api/setting/model_setting"
 	"github.com/zhongruan0522/new-api/types"
@@ -28,22 +29,7 @@ import (
 
 const thoughtSignatureBypassValue = "context_engineering_is_the_way_to_go"
 
-func reasoningEffortToGeminiThinkingLevel(effort string) string {
-	switch strings.ToLower(strings.TrimSpace(effort)) {
-	case "", "none":
-		return ""
-	case "minimal", "low":
-		return "low"
-	case "medium":
-		return "medium"
-	case "high", "xhigh", "max":
-		return "high"
-	default:
-		return strings.ToLower(strings.TrimSpace(effort))
-	}
-}
-
-func openAIReasoningToGeminiThinkingConfig(textRequest dto.GeneralOpenAIRequest) (*dto.GeminiThinkingConfig, error) {
+func openAIReasoningToGeminiThinkingConfig(textRequest dto.GeneralOpenAIRequest, upstreamModel string) (*dto.GeminiThinkingConfig, error) {
 	if len(textRequest.Reasoning) > 0 {
 		var reasoning openrouter.RequestReasoning
 		if err := common.Unmarshal(textRequest.Reasoning, &reasoning); err != nil {
@@ -57,10 +43,20 @@ func openAIReasoningToGeminiThinkingConfig(textRequest dto.GeneralOpenAIRequest)
 		}
 	}
 
-	if level := reasoningEffortToGeminiThinkingLevel(textRequest.ReasoningEffort); level != "" {
+	if reasoning.UsesGeminiThinkingLevel(upstreamModel) {
+		if level := reasoning.EffortToGeminiThinkingLevel(textRequest.ReasoningEffort); level != "" {
+			return &dto.GeminiThinkingConfig{
+				IncludeThoughts: true,
+				ThinkingLevel:   level,
+			}, nil
+		}
+		return nil, nil
+	}
+	// Gemini 2.x 只接受 thinkingBudget
+	if budget := reasoning.GeminiEffortToBudget(textRequest.ReasoningEffort); budget > 0 {
 		return &dto.GeminiThinkingConfig{
 			IncludeThoughts: true,
-			ThinkingLevel:   level,
+			ThinkingBudget:  common.GetPointer(budget),
 		}, nil
 	}
 	return nil, nil
@@ -198,7 +194,7 @@ func CovertOpenAI2Gemini(c *gin.Context, textRequest dto.GeneralOpenAIRequest, i
 		}
 	}
 	if geminiRequest.GenerationConfig.ThinkingConfig == nil {
-		thinkingConfig, err := openAIReasoningToGeminiThinkingConfig(textRequest)
+		thinkingConfig, err := openAIReasoningToGeminiThinkingConfig(textRequest, info.UpstreamModelName)
 		if err != nil {
 			return nil, err
 		}
@@ -959,10 +955,22 @@ func responseGeminiChat2OpenAI(c *gin.Context, response *dto.GeminiChatResponse)
 		hasToolCalls := false
 		if len(candidate.Content.Parts) > 0 {
 			var texts []string
+			// 各文本 part 在合并后回复中的字符起点，用于换算 groundingMetadata 的引用位置
+			partOffsets := make(map[int]int)
+			textRunes := 0
+			appendText := func(text string) int {
+				if len(texts) > 0 {
+					textRunes++
+				}
+				start := textRunes
+				texts = append(texts, text)
+				textRunes += utf8.RuneCountInString(text)
+				return start
+			}
 			var reasoningTexts []string
 			reasoningSignature := ""
 			var toolCalls []dto.ToolCallResponse
-			for _, part := range candidate.Content.Parts {
+			for i, part := range candidate.Content.Parts {
 				if signature := part.GetThoughtSignature(); signature != "" && reasoningSignature == "" {
 					reasoningSignature = signature
 				}
@@ -970,10 +978,10 @@ func responseGeminiChat2OpenAI(c *gin.Context, response *dto.GeminiChatResponse)
 					// 媒体内容
 					if strings.HasPrefix(part.InlineData.MimeType, "image") {
 						imgText := "![image](data:" + part.InlineData.MimeType + ";base64," + part.InlineData.Data + ")"
-						texts = append(texts, imgText)
+						appendText(imgText)
 					} else {
 						// 其他媒体类型，直接显示链接
-						texts = append(texts, fmt.Sprintf("[media](data:%s;base64,%s)", part.InlineData.MimeType, part.InlineData.Data))
+						appendText(fmt.Sprintf("[media](data:%s;base64,%s)", part.InlineData.MimeType, part.InlineData.Data))
 					}
 				} else if part.FunctionCall != nil {
 					choice.FinishReason = constant.FinishReasonToolCalls
@@ -984,13 +992,13 @@ func responseGeminiChat2OpenAI(c *gin.Context, response *dto.GeminiChatResponse)
 					reasoningTexts = append(reasoningTexts, part.Text)
 				} else {
 					if part.ExecutableCode != nil {
-						texts = append(texts, "```"+part.ExecutableCode.Language+"\n"+part.ExecutableCode.Code+"\n```")
+						appendText("```" + part.ExecutableCode.Language + "\n" + part.ExecutableCode.Code + "\n```")
 					} else if part.CodeExecutionResult != nil {
-						texts = append(texts, "```output\n"+part.CodeExecutionResult.Output+"\n```")
+						appendText("```output\n" + part.CodeExecutionResult.Output + "\n```")
 					} else {
 						// 过滤掉空行
 						if part.Text != "\n" {
-							texts = append(texts, part.Text)
+							partOffsets[i] = appendText(part.Text)
 						}
 					}
 				}
@@ -1007,6 +1015,7 @@ func responseGeminiChat2OpenAI(c *gin.Context, response *dto.GeminiChatResponse)
 				choice.Message.ReasoningSignature = reasoningSignature
 			}
 			choice.Message.SetStringContent(strings.Join(texts, "\n"))
+			choice.Message.Annotations = service.ChatAnnotationsFromGeminiGrounding(candidate.GroundingMetadata, candidate.Content.Parts, partOffsets)
 
 		}
 		if candidate.FinishReason != nil {
@@ -1388,6 +1397,15 @@ func GeminiChatHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.R
 			return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
 		}
 		responseBody = claudeRespStr
+	case types.RelayFormatOpenAIResponses:
+		responsesResp, err := relaycommon.ConvertChatCompletionResponseToResponsesResponseWithToolContext(fullTextResponse, info.OpenAIResponsesToolContext)
+		if err != nil {
+			return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+		}
+		responseBody, err = common.Marshal(responsesResp)
+		if err != nil {
+			return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+		}
 	case types.RelayFormatGemini:
 		break
 	}
@@ -0,0 +1,43 @@
+package gemini
+
+import (
+	"testing"
+
+	"github.com/zhongruan0522/new-api/dto"
+)
+
+func TestConvertGeminiSpeechRequestMapsVoiceAndInstructions(t *testing.T) {
+	converted, err := convertGeminiSpeechRequest(dto.AudioRequest{
+		Model:        "gemini-2.5-flash-preview-tts",
+		Input:        "hello",
+		Voice:        "onyx",
+		Instructions: "Say cheerfully",
+	})
+	if err != nil {
+		t.Fatalf("convertGeminiSpeechRequest returned error: %v", err)
+	}
+	if got := converted.Contents[0].Parts[0].Text; got != "Say cheerfully: hello" {
+		t.Fatalf("unexpected text: %q", got)
+	}
+	if got := string(converted.GenerationConfig.SpeechConfig); got != `{"voiceConfig":{"prebuiltVoiceConfig":{"voiceName":"Charon"}}}` {
+		t.Fatalf("unexpected speech config: %s", got)
+	}
+	if len(converted.GenerationConfig.ResponseModalities) != 1 || converted.GenerationConfig.ResponseModalities[0] != "AUDIO" {
+		t.Fatalf("unexpected response modalities: %v", converted.GenerationConfig.ResponseModalities)
+	}
+}
+
+func TestConvertGeminiSpeechRequestRejectsMP3(t *testing.T) {
+	if _, err := convertGeminiSpeechRequest(dto.AudioRequest{Input: "hello", ResponseFormat: "mp3"}); err == nil {
+		t.Fatal("expected mp3 response_format to be rejected")
+	}
+}
+
+func TestGeminiPCMSampleRate(t *testing.T) {
+	if got := geminiPCMSampleRate("audio/L16;codec=pcm;rate=16000"); got != 16000 {
+		t.Fatalf("geminiPCMSampleRate = %d, want 16000", got)
+	}
+	if got := geminiPCMSampleRate("audio/L16"); got != geminiTTSSampleRate {
+		t.Fatalf("geminiPCMSampleRate default = %d", got)
+	}
+}
@@ -0,0 +1,115 @@
+package gemini
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/dto"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	relayconstant "github.com/zhongruan0522/new-api/relay/constant"
+	"github.com/zhongruan0522/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func encodeTestPNG(t *testing.T, img image.Image) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func newImageTestContext() *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/v1/images/edits", nil)
+	c.Request.Header.Set("Content-Type", "application/json")
+	return c
+}
+
+func TestConvertGeminiImageRequestEditsUsesInlineImages(t *testing.T) {
+	pixel := encodeTestPNG(t, image.NewRGBA(image.Rect(0, 0, 1, 1)))
+	request := dto.ImageRequest{
+		Model:   "gemini-2.5-flash-image",
+		Prompt:  "add a hat",
+		Size:    "1792x1024",
+		Quality: "2K",
+		Images:  []byte(`[{"image_url":"data:image/png;base64,` + pixel + `"}]`),
+	}
+	info := &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeImagesEdits}
+
+	converted, err := convertGeminiImageRequest(newImageTestContext(), info, request)
+	if err != nil {
+		t.Fatalf("convertGeminiImageRequest returned error: %v", err)
+	}
+	parts := converted.Contents[0].Parts
+	if len(parts) != 2 || parts[0].InlineData == nil || parts[0].InlineData.Data != pixel || parts[1].Text != "add a hat" {
+		t.Fatalf("unexpected parts: %+v", parts)
+	}
+	if got := string(converted.GenerationConfig.ImageConfig); got != `{"aspectRatio":"16:9","imageSize":"2K"}` {
+		t.Fatalf("unexpected image config: %s", got)
+	}
+}
+
+func TestConvertImagenRequestEditsRequiresVertex(t *testing.T) {
+	info := &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeImagesEdits}
+	info.ChannelMeta = &relaycommon.ChannelMeta{ChannelType: constant.ChannelTypeGemini}
+	if _, err := convertImagenRequest(newImageTestContext(), info, dto.ImageRequest{Prompt: "x"}); err == nil {
+		t.Fatal("expected imagen edits on gemini channel to fail")
+	}
+}
+
+func TestConvertImagenRequestEditsRequiresCapabilityModel(t *testing.T) {
+	pixel := encodeTestPNG(t, image.NewRGBA(image.Rect(0, 0, 1, 1)))
+	request := dto.ImageRequest{Prompt: "add a hat", Images: []byte(`["data:image/png;base64,` + pixel + `"]`)}
+	newInfo := func(modelName string) *relaycommon.RelayInfo {
+		info := &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeImagesEdits}
+		info.ChannelMeta = &relaycommon.ChannelMeta{ChannelType: constant.ChannelTypeVertexAi, UpstreamModelName: modelName}
+		return info
+	}
+
+	_, err := convertImagenRequest(newImageTestContext(), newInfo("imagen-4.0-generate-001"), request)
+	if err == nil || !strings.Contains(err.Error(), imagenEditModel) {
+		t.Fatalf("expected edits on a generation model to be rejected, got %v", err)
+	}
+
+	converted, err := convertImagenRequest(newImageTestContext(), newInfo(imagenEditModel), request)
+	if err != nil {
+		t.Fatalf("convertImagenRequest returned error: %v", err)
+	}
+	refs := converted.Instances[0].ReferenceImages
+	if len(refs) != 1 || refs[0].ReferenceImage.BytesBase64Encoded != pixel || converted.Parameters.EditMode != "EDIT_MODE_DEFAULT" {
+		t.Fatalf("unexpected imagen edit request: %+v", converted)
+	}
+}
+
+func TestImagenMaskFromOpenAIInvertsTransparency(t *testing.T) {
+	mask := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	mask.Set(0, 0, color.NRGBA{A: 0})
+	mask.Set(1, 0, color.NRGBA{A: 255})
+
+	converted, err := imagenMaskFromOpenAI(&service.ImageEditInput{MimeType: "image/png", Base64: encodeTestPNG(t, mask)})
+	if err != nil {
+		t.Fatalf("imagenMaskFromOpenAI returned error: %v", err)
+	}
+	data, _ := base64.StdEncoding.DecodeString(converted)
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode converted mask: %v", err)
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r != 0xffff {
+		t.Fatalf("transparent pixel should become white, got %d", r)
+	}
+	if r, _, _, _ := img.At(1, 0).RGBA(); r != 0 {
+		t.Fatalf("opaque pixel should stay black, got %d", r)
+	}
+}
@@ -1,9 +1,17 @@
 package gemini
 
 import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/gin-gonic/gin"
+	"github.com/zhongruan0522/new-api/common"
 	"github.com/zhongruan0522/new-api/dto"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/types"
 )
 
 func TestStreamResponseGeminiChat2OpenAIPreservesThoughtAndText(t *testing.T) {
@@ -40,3 +48,56 @@ func TestStreamResponseGeminiChat2OpenAIPreservesThoughtAndText(t *testing.T) {
 		t.Fatalf("finish reason = %v, want nil because STOP is emitted as a separate stop chunk", choice.FinishReason)
 	}
 }
+
+func TestGeminiChatHandlerWritesResponsesFormat(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+	info := &relaycommon.RelayInfo{
+		RelayFormat: types.RelayFormatOpenAIResponses,
+		ChannelMeta: &relaycommon.ChannelMeta{UpstreamModelName: "gemini-2.5-pro"},
+	}
+	body := `{"candidates":[{"index":0,"finishReason":"STOP","content":{"role":"model","parts":[{"text":"plan","thought":true},{"text":"answer"},{"functionCall":{"name":"weather","args":{"city":"Shanghai"}}}]}}],"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":4,"totalTokenCount":14}}`
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+
+	usage, newAPIError := GeminiChatHandler(c, info, resp)
+	if newAPIError != nil {
+		t.Fatalf("GeminiChatHandler error = %v", newAPIError)
+	}
+	if usage.PromptTokens != 10 || usage.CompletionTokens != 4 {
+		t.Fatalf("usage = %+v, want prompt=10 completion=4", usage)
+	}
+
+	var got dto.OpenAIResponsesResponse
+	if err := common.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal responses body error = %v: %s", err, recorder.Body.String())
+	}
+	if got.Object != "response" {
+		t.Fatalf("object = %q, want response", got.Object)
+	}
+	var sawReasoning, sawText, sawTool bool
+	for _, output := range got.Output {
+		switch output.Type {
+		case "reasoning":
+			sawReasoning = true
+		case "message":
+			for _, content := range output.Content {
+				if content.Text == "answer" {
+					sawText = true
+				}
+			}
+		case "function_call":
+			sawTool = output.Name == "weather" && output.CallId != ""
+		}
+	}
+	if !sawReasoning || !sawText || !sawTool {
+		t.Fatalf("output = %+v, want reasoning, message answer and function_call weather", got.Output)
+	}
+	if got.Usage == nil || got.Usage.InputTokens != 10 || got.Usage.OutputTokens != 4 {
+		t.Fatalf("usage = %+v, want input=10 output=4", got.Usage)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/zhongruan0522/new-api/relay/channel/openai"
 	relaycommon "github.com/zhongruan0522/new-api/relay/common"
 	"github.com/zhongruan0522/new-api/relay/constant"
+	"github.com/zhongruan0522/new-api/setting/model_setting"
 	"github.com/zhongruan0522/new-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -36,7 +37,7 @@ func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInf
 		return nil, errors.New("unsupported audio relay mode")
 	}
 
-	voiceID := request.Voice
+	voiceID := model_setting.GetProviderVoice(ChannelName, request.Voice)
 	speed := request.Speed
 	outputFormat := request.ResponseFormat
 
@@ -77,7 +78,7 @@ func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInf
 }
 
 func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
-	return request, nil
+	return convertImageRequest(c, info, request)
 }
 
 func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
@@ -127,6 +128,9 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 	if info.RelayMode == constant.RelayModeAudioSpeech {
 		return handleTTSResponse(c, resp, info)
 	}
+	if constant.IsImageRelayMode(info.RelayMode) {
+		return handleImageResponse(c, resp, info)
+	}
 
 	adaptor := openai.Adaptor{}
 	return adaptor.DoResponse(c, resp, info)
@@ -0,0 +1,165 @@
+package minimax
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/relay/constant"
+	"github.com/zhongruan0522/new-api/service"
+	"github.com/zhongruan0522/new-api/types"
+)
+
+// 文档: https://platform.minimaxi.com/docs/api-reference/image-generation-t2i
+
+type MiniMaxImageRequest struct {
+	Model            string                  `json:"model"`
+	Prompt           string                  `json:"prompt"`
+	AspectRatio      string                  `json:"aspect_ratio,omitempty"`
+	ResponseFormat   string                  `json:"response_format,omitempty"`
+	N                uint                    `json:"n,omitempty"`
+	PromptOptimizer  bool                    `json:"prompt_optimizer,omitempty"`
+	SubjectReference []MiniMaxImageReference `json:"subject_reference,omitempty"`
+}
+
+type MiniMaxImageReference struct {
+	Type      string `json:"type"`
+	ImageFile string `json:"image_file"`
+}
+
+type MiniMaxImageResponse struct {
+	ID       string           `json:"id"`
+	Data     MiniMaxImageData `json:"data"`
+	BaseResp MiniMaxBaseResp  `json:"base_resp"`
+}
+
+type MiniMaxImageData struct {
+	ImageUrls   []string `json:"image_urls"`
+	ImageBase64 []string `json:"image_base64"`
+}
+
+func miniMaxAspectRatio(size string) string {
+	switch size {
+	case "":
+		return ""
+	case "1024x1024", "512x512", "256x256":
+		return "1:1"
+	case "1792x1024":
+		return "16:9"
+	case "1024x1792":
+		return "9:16"
+	case "1536x1024":
+		return "3:2"
+	case "1024x1536":
+		return "2:3"
+	}
+	if strings.Contains(size, ":") {
+		return size
+	}
+	return "1:1"
+}
+
+func convertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (*MiniMaxImageRequest, error) {
+	miniMaxRequest := &MiniMaxImageRequest{
+		Model:          request.Model,
+		Prompt:         request.Prompt,
+		AspectRatio:    miniMaxAspectRatio(request.Size),
+		N:              request.N,
+		ResponseFormat: "base64",
+	}
+	if request.ResponseFormat == "url" {
+		miniMaxRequest.ResponseFormat = "url"
+	}
+
+	// MiniMax 没有独立的编辑接口，编辑与变体通过人物参考图实现
+	if info.RelayMode == constant.RelayModeImagesEdits || info.RelayMode == constant.RelayModeImagesVariations {
+		images, mask, err := service.GetImageEditInputs(c, &request)
+		if err != nil {
+			return nil, err
+		}
+		if mask != nil {
+			return nil, errors.New("mask is not supported by minimax image models")
+		}
+		for _, img := range images {
+			miniMaxRequest.SubjectReference = append(miniMaxRequest.SubjectReference, MiniMaxImageReference{
+				Type:      "character",
+				ImageFile: img.DataURL(),
+			})
+		}
+		if miniMaxRequest.Prompt == "" && info.RelayMode == constant.RelayModeImagesVariations {
+			miniMaxRequest.Prompt = "Generate a variation of the reference image."
+		}
+	}
+	if miniMaxRequest.Prompt == "" {
+		return nil, errors.New("prompt is required")
+	}
+	return miniMaxRequest, nil
+}
+
+func handleImageResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (usage any, err *types.NewAPIError) {
+	body, readErr := io.ReadAll(resp.Body)
+	if readErr != nil {
+		return nil, types.NewErrorWithStatusCode(
+			fmt.Errorf("failed to read minimax response: %w", readErr),
+			types.ErrorCodeReadResponseBodyFailed,
+			http.StatusInternalServerError,
+		)
+	}
+	defer resp.Body.Close()
+
+	openAIResponse, convertErr := convertImageResponse(body)
+	if convertErr != nil {
+		return nil, convertErr
+	}
+	jsonResponse, marshalErr := common.Marshal(openAIResponse)
+	if marshalErr != nil {
+		return nil, types.NewError(marshalErr, types.ErrorCodeBadResponseBody)
+	}
+	c.Data(http.StatusOK, "application/json", jsonResponse)
+
+	return &dto.Usage{
+		PromptTokens: info.GetEstimatePromptTokens(),
+		TotalTokens:  info.GetEstimatePromptTokens(),
+	}, nil
+}
+
+func convertImageResponse(body []byte) (*dto.ImageResponse, *types.NewAPIError) {
+	var miniMaxResp MiniMaxImageResponse
+	if unmarshalErr := json.Unmarshal(body, &miniMaxResp); unmarshalErr != nil {
+		return nil, types.NewErrorWithStatusCode(
+			fmt.Errorf("failed to unmarshal minimax image response: %w", unmarshalErr),
+			types.ErrorCodeBadResponseBody,
+			http.StatusInternalServerError,
+		)
+	}
+	if miniMaxResp.BaseResp.StatusCode != 0 {
+		return nil, types.NewErrorWithStatusCode(
+			fmt.Errorf("minimax image error: %d - %s", miniMaxResp.BaseResp.StatusCode, miniMaxResp.BaseResp.StatusMsg),
+			types.ErrorCodeBadResponse,
+			http.StatusBadRequest,
+		)
+	}
+
+	openAIResponse := &dto.ImageResponse{Created: common.GetTimestamp()}
+	for _, url := range miniMaxResp.Data.ImageUrls {
+		openAIResponse.Data = append(openAIResponse.Data, dto.ImageData{Url: url})
+	}
+	for _, b64 := range miniMaxResp.Data.ImageBase64 {
+		openAIResponse.Data = append(openAIResponse.Data, dto.ImageData{B64Json: b64})
+	}
+	if len(openAIResponse.Data) == 0 {
+		return nil, types.NewErrorWithStatusCode(
+			errors.New("no image data in minimax response"),
+			types.ErrorCodeBadResponse,
+			http.StatusInternalServerError,
+		)
+	}
+	return openAIResponse, nil
+}
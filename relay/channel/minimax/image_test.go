@@ -0,0 +1,23 @@
+package minimax
+
+import (
+	"testing"
+)
+
+func TestConvertImageResponse(t *testing.T) {
+	body := []byte(`{"id":"abc","data":{"image_urls":["https://example.com/a.jpeg"],"image_base64":["aGVsbG8="]},"base_resp":{"status_code":0,"status_msg":"success"}}`)
+	resp, err := convertImageResponse(body)
+	if err != nil {
+		t.Fatalf("convertImageResponse returned error: %v", err)
+	}
+	if len(resp.Data) != 2 || resp.Data[0].Url != "https://example.com/a.jpeg" || resp.Data[1].B64Json != "aGVsbG8=" {
+		t.Fatalf("unexpected data: %+v", resp.Data)
+	}
+}
+
+func TestConvertImageResponseBaseRespError(t *testing.T) {
+	body := []byte(`{"data":{},"base_resp":{"status_code":1026,"status_msg":"input new_sensitive"}}`)
+	if _, err := convertImageResponse(body); err == nil {
+		t.Fatal("expected base_resp error")
+	}
+}
@@ -13,12 +13,15 @@ import (
 	"github.com/zhongruan0522/new-api/relay/channel/claude"
 	"github.com/zhongruan0522/new-api/relay/channel/openai"
 	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/service"
 	"github.com/zhongruan0522/new-api/types"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Adaptor struct {
+	// claudeViaChat 开启工具调用模拟时 Claude 请求改走 chat 兼容端点，响应由 OpenAI 处理器转回 Claude 格式
+	claudeViaChat bool
 }
 
 func (a *Adaptor) ConvertGeminiRequest(*gin.Context, *relaycommon.RelayInfo, *dto.GeminiChatRequest) (any, error) {
@@ -26,6 +29,18 @@ func (a *Adaptor) ConvertGeminiRequest(*gin.Context, *relaycommon.RelayInfo, *dt
 }
 
 func (a *Adaptor) ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeRequest) (any, error) {
+	if relaycommon.ToolCallEmulationEnabled(info) {
+		// 原生 Messages API 无法模拟工具调用，转换为 chat 请求后由 ConvertOpenAIRequest 渲染工具提示词。
+		aiRequest, err := service.ClaudeToOpenAIRequest(*request, info)
+		if err != nil {
+			return nil, err
+		}
+		if info.SupportStreamOptions && info.IsStream {
+			aiRequest.StreamOptions = &dto.StreamOptions{IncludeUsage: true}
+		}
+		a.claudeViaChat = true
+		return a.ConvertOpenAIRequest(c, info, aiRequest)
+	}
 	// Ollama 已经原生支持 Anthropic Messages API，这里直接透传 Claude 请求。
 	return request, nil
 }
@@ -53,26 +68,31 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 	if baseURL == "" {
 		baseURL = channelconstant.ChannelBaseURLs[channelconstant.ChannelTypeOllama]
 	}
+	// 不改写共享的 info.RequestURLPath，避免重试到其他渠道时沿用 chat 路径
+	requestURLPath := info.RequestURLPath
+	if a.claudeViaChat {
+		requestURLPath = "/v1/chat/completions"
+	}
 	specialPlan, hasSpecialPlan := channelconstant.ChannelSpecialBases[baseURL]
 	if hasSpecialPlan {
-		switch info.RelayFormat {
-		case types.RelayFormatClaude:
+		switch {
+		case info.RelayFormat == types.RelayFormatClaude && !a.claudeViaChat:
 			if specialPlan.ClaudeBaseURL != "" {
 				return fmt.Sprintf("%s/v1/messages", specialPlan.ClaudeBaseURL), nil
 			}
 		default:
 			if specialPlan.OpenAIBaseURL != "" {
-				return fmt.Sprintf("%s%s", specialPlan.OpenAIBaseURL, info.RequestURLPath), nil
+				return fmt.Sprintf("%s%s", specialPlan.OpenAIBaseURL, requestURLPath), nil
 			}
 		}
 	}
 	// Ollama 现已支持标准兼容端点，直接转发到客户端请求的原始规范路径。
-	return relaycommon.GetFullRequestURL(baseURL, info.RequestURLPath, info.ChannelType), nil
+	return relaycommon.GetFullRequestURL(baseURL, requestURLPath, info.ChannelType), nil
 }
 
 func (a *Adaptor) SetupRequestHeader(c *gin.Context, req *http.Header, info *relaycommon.RelayInfo) error {
 	channel.SetupApiRequestHeader(info, c, req)
-	if info.RelayFormat == types.RelayFormatClaude {
+	if info.RelayFormat == types.RelayFormatClaude && !a.claudeViaChat {
 		// Anthropic 兼容接口使用 x-api-key 与 anthropic-version 头。
 		req.Del("Authorization")
 		if info.ApiKey != "" {
@@ -96,6 +116,7 @@ func (a *Adaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayIn
 	if request == nil {
 		return nil, errors.New("request is nil")
 	}
+	relaycommon.ApplyToolCallEmulation(info, request)
 	// OpenAI 兼容请求已经可以被 Ollama 原生识别，直接透传。
 	return request, nil
 }
@@ -119,7 +140,7 @@ func (a *Adaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, request
 }
 
 func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (usage any, err *types.NewAPIError) {
-	if info.RelayFormat == types.RelayFormatClaude {
+	if info.RelayFormat == types.RelayFormatClaude && !a.claudeViaChat {
 		delegate := &claude.Adaptor{}
 		delegate.Init(info)
 		return delegate.DoResponse(c, resp, info)
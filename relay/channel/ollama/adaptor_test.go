@@ -289,3 +289,92 @@ func TestFetchOllamaVersionResolvesPlanBaseURL(t *testing.T) {
 		t.Fatalf("version = %q, want %q", version, "0.6.5")
 	}
 }
+
+func TestConvertClaudeRequestWithToolCallEmulation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+
+	info := &relaycommon.RelayInfo{
+		RelayFormat:    types.RelayFormatClaude,
+		RequestURLPath: "/v1/messages",
+		ChannelMeta: &relaycommon.ChannelMeta{
+			ApiKey:               "secret-key",
+			ChannelBaseUrl:       "ollama-coding-plan",
+			ChannelOtherSettings: dto.ChannelOtherSettings{ToolCallEmulation: dto.ToolCallEmulationXML},
+		},
+	}
+	request := &dto.ClaudeRequest{
+		Model:     "qwen3",
+		MaxTokens: 256,
+		Messages:  []dto.ClaudeMessage{{Role: "user", Content: "weather in Paris?"}},
+		Tools: []any{&dto.Tool{
+			Name:        "get_weather",
+			Description: "Get the weather",
+			InputSchema: map[string]any{"type": "object"},
+		}},
+	}
+
+	adaptor := &Adaptor{}
+	converted, err := adaptor.ConvertClaudeRequest(ctx, info, request)
+	if err != nil {
+		t.Fatalf("ConvertClaudeRequest returned error: %v", err)
+	}
+	chatRequest, ok := converted.(*dto.GeneralOpenAIRequest)
+	if !ok {
+		t.Fatalf("converted request type = %T, want *dto.GeneralOpenAIRequest", converted)
+	}
+	if len(chatRequest.Tools) != 0 || info.ToolCallEmulation == nil || !info.ToolCallEmulation.ToolNames["get_weather"] {
+		t.Fatalf("tools should be rendered into the prompt, got tools=%v state=%+v", chatRequest.Tools, info.ToolCallEmulation)
+	}
+
+	url, err := adaptor.GetRequestURL(info)
+	if err != nil {
+		t.Fatalf("GetRequestURL returned error: %v", err)
+	}
+	if url != "https://ollama.com/v1/chat/completions" {
+		t.Fatalf("GetRequestURL = %q", url)
+	}
+	headers := http.Header{}
+	if err := adaptor.SetupRequestHeader(ctx, &headers, info); err != nil {
+		t.Fatalf("SetupRequestHeader returned error: %v", err)
+	}
+	if got := headers.Get("Authorization"); got != "Bearer secret-key" {
+		t.Fatalf("Authorization = %q", got)
+	}
+}
+
+func TestConvertClaudeRequestWithToolCallEmulationKeepsRequestPath(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+
+	info := &relaycommon.RelayInfo{
+		RelayFormat:    types.RelayFormatClaude,
+		RequestURLPath: "/v1/messages",
+		ChannelMeta: &relaycommon.ChannelMeta{
+			ChannelBaseUrl:       "http://localhost:11434",
+			ChannelOtherSettings: dto.ChannelOtherSettings{ToolCallEmulation: dto.ToolCallEmulationXML},
+		},
+	}
+	request := &dto.ClaudeRequest{
+		Model:     "qwen3",
+		MaxTokens: 256,
+		Messages:  []dto.ClaudeMessage{{Role: "user", Content: "hi"}},
+	}
+
+	adaptor := &Adaptor{}
+	if _, err := adaptor.ConvertClaudeRequest(ctx, info, request); err != nil {
+		t.Fatalf("ConvertClaudeRequest returned error: %v", err)
+	}
+	if info.RequestURLPath != "/v1/messages" {
+		t.Fatalf("RequestURLPath should not be rewritten, got %q", info.RequestURLPath)
+	}
+	url, err := adaptor.GetRequestURL(info)
+	if err != nil {
+		t.Fatalf("GetRequestURL returned error: %v", err)
+	}
+	if url != "http://localhost:11434/v1/chat/completions" {
+		t.Fatalf("GetRequestURL = %q", url)
+	}
+}
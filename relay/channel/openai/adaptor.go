@@ -54,7 +54,7 @@ func (a *Adaptor) ConvertGeminiRequest(c *gin.Context, info *relaycommon.RelayIn
 	if err != nil {
 		return nil, err
 	}
-	return a.ConvertOpenAIRequest(c, info, openaiRequest)
+	return a.convertChatRequestForWire(c, info, openaiRequest)
 }
 
 func (a *Adaptor) ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeRequest) (any, error) {
@@ -86,6 +86,12 @@ func (a *Adaptor) ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayIn
 			IncludeUsage: true,
 		}
 	}
+	return a.convertChatRequestForWire(c, info, aiRequest)
+}
+
+// convertChatRequestForWire 将由 Claude/Gemini 转换而来的 chat 请求按渠道 openai_wire_api 发送；
+// 仅支持 Responses 的上游再转换一次并切换到 /v1/responses，响应由 OaiResponses*Handler 转回客户端格式
+func (a *Adaptor) convertChatRequestForWire(c *gin.Context, info *relaycommon.RelayInfo, aiRequest *dto.GeneralOpenAIRequest) (any, error) {
 	wire, ok := info.ChannelSetting.OpenAIWireAPI.Normalize()
 	if !ok {
 		return nil, fmt.Errorf("invalid channel setting openai_wire_api: %q", info.ChannelSetting.OpenAIWireAPI)
@@ -252,6 +258,7 @@ func (a *Adaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayIn
 	if info.ChannelType != constant.ChannelTypeOpenAI && info.ChannelType != constant.ChannelTypeAzure {
 		request.StreamOptions = nil
 	}
+	relaycommon.ApplyToolCallEmulation(info, request)
 	if info.ChannelType == constant.ChannelTypeOpenRouter {
 		if len(request.Usage) == 0 {
 			request.Usage = json.RawMessage(`{"include":true}`)
@@ -419,8 +426,11 @@ func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInf
 }
 
 func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
-	switch info.RelayMode {
-	case relayconstant.RelayModeImagesEdits:
+	// gpt-image 系列只返回 b64_json，携带 response_format 会被上游拒绝；需要 URL 时由网关转存
+	omitResponseFormat := strings.HasPrefix(info.UpstreamModelName, "gpt-image")
+	multipartRequest := strings.Contains(c.Request.Header.Get("Content-Type"), "multipart/form-data") || c.Request.MultipartForm != nil
+	switch {
+	case (info.RelayMode == relayconstant.RelayModeImagesEdits || info.RelayMode == relayconstant.RelayModeImagesVariations) && multipartRequest:
 
 		var requestBody bytes.Buffer
 		writer := multipart.NewWriter(&requestBody)
@@ -438,7 +448,7 @@ func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInf
 		// 写入所有非文件字段
 		if mf != nil {
 			for key, values := range mf.Value {
-				if key == "model" {
+				if key == "model" || (key == "response_format" && omitResponseFormat) {
 					continue
 				}
 				for _, value := range values {
@@ -542,6 +552,9 @@ func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInf
 		return &requestBody, nil
 
 	default:
+		if omitResponseFormat {
+			request.ResponseFormat = ""
+		}
 		return request, nil
 	}
 }
@@ -588,7 +601,8 @@ func (a *Adaptor) ConvertOpenAIResponsesRequest(c *gin.Context, info *relaycommo
 func (a *Adaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, requestBody io.Reader) (any, error) {
 	if info.RelayMode == relayconstant.RelayModeAudioTranscription ||
 		info.RelayMode == relayconstant.RelayModeAudioTranslation ||
-		info.RelayMode == relayconstant.RelayModeImagesEdits {
+		info.RelayMode == relayconstant.RelayModeImagesEdits ||
+		info.RelayMode == relayconstant.RelayModeImagesVariations {
 		return channel.DoFormRequest(a, c, info, requestBody)
 	} else if info.RelayMode == relayconstant.RelayModeRealtime {
 		return channel.DoWssRequest(a, c, info, requestBody)
@@ -607,7 +621,7 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 		fallthrough
 	case relayconstant.RelayModeAudioTranscription:
 		err, usage = OpenaiSTTHandler(c, resp, info, a.ResponseFormat)
-	case relayconstant.RelayModeImagesGenerations, relayconstant.RelayModeImagesEdits:
+	case relayconstant.RelayModeImagesGenerations, relayconstant.RelayModeImagesEdits, relayconstant.RelayModeImagesVariations:
 		usage, err = OpenaiHandlerWithUsage(c, info, resp)
 	case relayconstant.RelayModeRerank:
 		usage, err = common_handler.RerankHandler(c, info, resp)
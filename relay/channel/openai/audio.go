@@ -1,10 +1,8 @@
 package openai
 
 import (
-	"bytes"
 	"fmt"
 	"io"
-	"math"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -76,39 +74,8 @@ func OpenaiTTSHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 		if audioReq, ok := info.Request.(*dto.AudioRequest); ok && audioReq.ResponseFormat != "" {
 			audioFormat = audioReq.ResponseFormat
 		}
-
-		var duration float64
-		var durationErr error
-
-		if audioFormat == "pcm" {
-			// PCM 格式没有文件头，根据 OpenAI TTS 的 PCM 参数计算时长
-			// 采样率: 24000 Hz, 位深度: 16-bit (2 bytes), 声道数: 1
-			const sampleRate = 24000
-			const bytesPerSample = 2
-			const channels = 1
-			duration = float64(len(bodyBytes)) / float64(sampleRate*bytesPerSample*channels)
-		} else {
-			ext := "." + audioFormat
-			reader := bytes.NewReader(bodyBytes)
-			duration, durationErr = common.GetAudioDuration(c.Request.Context(), reader, ext)
-		}
-
-		usage.PromptTokensDetails.TextTokens = usage.PromptTokens
-
-		if durationErr != nil {
-			logger.LogWarn(c, fmt.Sprintf("failed to get audio duration: %v", durationErr))
-			// 如果无法获取时长，则设置保底的 CompletionTokens，根据body大小计算
-			sizeInKB := float64(len(bodyBytes)) / 1000.0
-			estimatedTokens := int(math.Ceil(sizeInKB)) // 粗略估算每KB约等于1 token
-			usage.CompletionTokens = estimatedTokens
-			usage.CompletionTokenDetails.AudioTokens = estimatedTokens
-		} else if duration > 0 {
-			// 计算 token: ceil(duration) / 60.0 * 1000，即每分钟 1000 tokens
-			completionTokens := int(math.Round(math.Ceil(duration) / 60.0 * 1000))
-			usage.CompletionTokens = completionTokens
-			usage.CompletionTokenDetails.AudioTokens = completionTokens
-		}
-		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+		// PCM 格式没有文件头，按 OpenAI TTS 的 PCM 参数（24000 Hz、16-bit、单声道）计算时长
+		return service.BuildSpeechUsage(c, info, bodyBytes, audioFormat, 24000)
 	}
 
 	return usage
@@ -0,0 +1,105 @@
+package openai
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	relayconstant "github.com/zhongruan0522/new-api/relay/constant"
+	"github.com/zhongruan0522/new-api/service"
+	"github.com/zhongruan0522/new-api/types"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files under testdata")
+
+func readFixture(t *testing.T, name string, v any) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("read fixture %s: %v", name, err)
+	}
+	if err := common.Unmarshal(data, v); err != nil {
+		t.Fatalf("unmarshal fixture %s: %v", name, err)
+	}
+}
+
+// assertGoldenJSON 按缩进格式与 testdata 下的 golden 文件逐字比较，go test -update 时重写
+func assertGoldenJSON(t *testing.T, name string, got any) {
+	t.Helper()
+	data, err := common.Marshal(got)
+	if err != nil {
+		t.Fatalf("marshal %s: %v", name, err)
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, data, "", "  "); err != nil {
+		t.Fatalf("indent %s: %v", name, err)
+	}
+	indented.WriteByte('\n')
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.WriteFile(path, indented.Bytes(), 0o644); err != nil {
+			t.Fatalf("write golden %s: %v", name, err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden %s: %v", name, err)
+	}
+	if !bytes.Equal(indented.Bytes(), want) {
+		t.Fatalf("%s mismatch (go test -update to rewrite)\ngot:\n%s\nwant:\n%s", name, indented.Bytes(), want)
+	}
+}
+
+// Gemini 客户端 -> 仅支持 Responses 的上游
+func TestGeminiClientToResponsesUpstreamGolden(t *testing.T) {
+	var request dto.GeminiChatRequest
+	readFixture(t, "gemini_request.json", &request)
+	info := &relaycommon.RelayInfo{
+		RelayFormat:            types.RelayFormatGemini,
+		RelayMode:              relayconstant.RelayModeGemini,
+		RequestConversionChain: []types.RelayFormat{types.RelayFormatGemini},
+		RequestURLPath:         "/v1beta/models/gpt-5:generateContent",
+		ChannelMeta: &relaycommon.ChannelMeta{
+			ChannelSetting:    dto.ChannelSettings{OpenAIWireAPI: dto.OpenAIWireAPIResponses},
+			UpstreamModelName: "gpt-5",
+		},
+	}
+	converted, err := (&Adaptor{}).ConvertGeminiRequest(nil, info, &request)
+	if err != nil {
+		t.Fatalf("ConvertGeminiRequest error = %v", err)
+	}
+	assertGoldenJSON(t, "gemini_request.responses.golden.json", converted)
+
+	var upstream dto.OpenAIResponsesResponse
+	readFixture(t, "responses_response.json", &upstream)
+	usage := &dto.Usage{PromptTokens: 20, CompletionTokens: 12, TotalTokens: 32}
+	body, err := convertResponsesBodyToGeminiBody(&upstream, usage, info)
+	if err != nil {
+		t.Fatalf("convertResponsesBodyToGeminiBody error = %v", err)
+	}
+	var geminiResp dto.GeminiChatResponse
+	if err := common.Unmarshal(body, &geminiResp); err != nil {
+		t.Fatalf("unmarshal gemini response: %v", err)
+	}
+	assertGoldenJSON(t, "responses_response.gemini.golden.json", geminiResp)
+
+	// 引用经 Gemini 转回 Responses 后位置与来源不变
+	chatResp, err := relaycommon.ConvertResponsesResponseToChatCompletionResponse(&upstream)
+	if err != nil {
+		t.Fatalf("ConvertResponsesResponseToChatCompletionResponse error = %v", err)
+	}
+	back := service.ResponseOpenAI2Gemini(chatResp, info)
+	parts := back.Candidates[0].Content.Parts
+	partOffsets := map[int]int{len(parts) - 1: 0}
+	annotations := service.ChatAnnotationsFromGeminiGrounding(back.Candidates[0].GroundingMetadata, parts, partOffsets)
+	if len(annotations) != 1 || *annotations[0].URLCitation != *chatResp.Choices[0].Message.Annotations[0].URLCitation {
+		t.Fatalf("round-tripped annotations = %+v, want %+v", annotations, chatResp.Choices[0].Message.Annotations)
+	}
+}
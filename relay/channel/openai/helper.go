@@ -29,6 +29,8 @@ func HandleStreamFormat(c *gin.Context, info *relaycommon.RelayInfo, data string
 		return handleClaudeFormat(c, data, info)
 	case types.RelayFormatGemini:
 		return handleGeminiFormat(c, data, info)
+	case types.RelayFormatOpenAIResponses:
+		return handleResponsesFormat(c, data, info)
 	}
 	return nil
 }
@@ -75,6 +77,25 @@ func handleGeminiFormat(c *gin.Context, data string, info *relaycommon.RelayInfo
 	return nil
 }
 
+// handleResponsesFormat 将 chat 流式块转换为 Responses 事件，供原生 chat 之外的上游服务 /v1/responses 客户端
+func handleResponsesFormat(c *gin.Context, data string, info *relaycommon.RelayInfo) error {
+	out, err := info.EnsureResponsesStreamConverter().ConvertFrame("", data, "data: "+data+"\n\n")
+	if err != nil {
+		return err
+	}
+	return writeResponsesEvents(c, out)
+}
+
+func writeResponsesEvents(c *gin.Context, out string) error {
+	if out == "" {
+		return nil
+	}
+	if _, err := c.Writer.WriteString(out); err != nil {
+		return err
+	}
+	return helper.FlushWriter(c)
+}
+
 func ProcessStreamResponse(streamResponse dto.ChatCompletionsStreamResponse, responseTextBuilder *strings.Builder, toolCount *int) error {
 	for _, choice := range streamResponse.Choices {
 		responseTextBuilder.WriteString(choice.Delta.GetContentString())
@@ -250,6 +271,22 @@ func HandleFinalResponse(c *gin.Context, info *relaycommon.RelayInfo, lastStream
 		// 发送最终的 Gemini 响应
 		c.Render(-1, &common.CustomEvent{Data: "data: " + string(geminiResponseStr)})
 		_ = helper.FlushWriter(c)
+
+	case types.RelayFormatOpenAIResponses:
+		converter := info.EnsureResponsesStreamConverter()
+		if usage != nil {
+			// 仅含 usage 的块不会产生事件，只用于填充 response.completed 中的用量
+			usageData, err := common.Marshal(helper.GenerateFinalUsageResponse(responseId, createAt, model, *usage))
+			if err == nil {
+				_, _ = converter.ConvertFrame("", string(usageData), "data: "+string(usageData)+"\n\n")
+			}
+		}
+		out, err := converter.ConvertFrame("", "[DONE]", "data: [DONE]\n\n")
+		if err != nil {
+			common.SysLog("error converting responses final event: " + err.Error())
+			return
+		}
+		_ = writeResponsesEvents(c, out)
 	}
 }
 
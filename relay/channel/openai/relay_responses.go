@@ -50,6 +50,12 @@ func OaiResponsesHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http
 			return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
 		}
 	}
+	if info != nil && info.RelayFormat == types.RelayFormatGemini {
+		responseBody, err = convertResponsesBodyToGeminiBody(&responsesResponse, &usage, info)
+		if err != nil {
+			return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+		}
+	}
 
 	// 写入新的 response body
 	service.IOCopyBytesGracefully(c, resp, responseBody)
@@ -90,7 +96,7 @@ func OaiResponsesStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 	var usage = &dto.Usage{}
 	var responseTextBuilder strings.Builder
 	var responsesToChat relaycommon.OpenAIWireStreamConverter
-	if info.RelayFormat == types.RelayFormatClaude {
+	if info.RelayFormat == types.RelayFormatClaude || info.RelayFormat == types.RelayFormatGemini {
 		responsesToChat = relaycommon.NewResponsesToChatStreamConverter(false)
 	}
 
@@ -143,6 +149,13 @@ func OaiResponsesStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 			}
 			return true
 		}
+		if info.RelayFormat == types.RelayFormatGemini {
+			if err := writeResponsesStreamAsGemini(c, info, responsesToChat, data); err != nil {
+				logger.LogError(c, "failed to convert responses stream to gemini: "+err.Error())
+				return false
+			}
+			return true
+		}
 
 		if streamResponse.Type != "" {
 			sendResponsesStreamData(c, streamResponse, data)
@@ -183,6 +196,18 @@ func convertResponsesBodyToClaudeBody(responsesResponse *dto.OpenAIResponsesResp
 	return common.Marshal(claudeResp)
 }
 
+func convertResponsesBodyToGeminiBody(responsesResponse *dto.OpenAIResponsesResponse, usage *dto.Usage, info *relaycommon.RelayInfo) ([]byte, error) {
+	chatResponse, err := relaycommon.ConvertResponsesResponseToChatCompletionResponse(responsesResponse)
+	if err != nil {
+		return nil, err
+	}
+	if usage != nil {
+		chatResponse.Usage = *usage
+	}
+	geminiResp := service.ResponseOpenAI2Gemini(chatResponse, info)
+	return common.Marshal(geminiResp)
+}
+
 func writeResponsesStreamAsClaude(c *gin.Context, info *relaycommon.RelayInfo, converter relaycommon.OpenAIWireStreamConverter, data string) error {
 	if converter == nil {
 		return fmt.Errorf("responses to chat stream converter is nil")
@@ -212,6 +237,26 @@ func writeResponsesStreamAsClaude(c *gin.Context, info *relaycommon.RelayInfo, c
 	return nil
 }
 
+func writeResponsesStreamAsGemini(c *gin.Context, info *relaycommon.RelayInfo, converter relaycommon.OpenAIWireStreamConverter, data string) error {
+	if converter == nil {
+		return fmt.Errorf("responses to chat stream converter is nil")
+	}
+	out, err := converter.ConvertFrame("", data, "data: "+data+"\n\n")
+	if err != nil {
+		return err
+	}
+	for _, chatData := range chatDataFrames(out) {
+		if chatData == "[DONE]" {
+			continue
+		}
+		info.SendResponseCount++
+		if err := handleGeminiFormat(c, chatData, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func chatDataFrames(s string) []string {
 	frames := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n\n")
 	out := make([]string, 0, len(frames))
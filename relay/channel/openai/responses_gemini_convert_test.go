@@ -0,0 +1,169 @@
+package openai
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	relayconstant "github.com/zhongruan0522/new-api/relay/constant"
+	"github.com/zhongruan0522/new-api/types"
+)
+
+func TestConvertGeminiRequestToResponsesUpstreamUsesSharedRules(t *testing.T) {
+	info := &relaycommon.RelayInfo{
+		RelayFormat:            types.RelayFormatGemini,
+		RelayMode:              relayconstant.RelayModeGemini,
+		RequestConversionChain: []types.RelayFormat{types.RelayFormatGemini},
+		IsStream:               true,
+		RequestURLPath:         "/v1beta/models/gpt-5:streamGenerateContent",
+		ChannelMeta: &relaycommon.ChannelMeta{
+			ChannelSetting:    dto.ChannelSettings{OpenAIWireAPI: dto.OpenAIWireAPIResponses},
+			UpstreamModelName: "gpt-5",
+		},
+	}
+	request := &dto.GeminiChatRequest{
+		Contents: []dto.GeminiChatContent{{
+			Role:  "user",
+			Parts: []dto.GeminiPart{{Text: "weather in Shanghai?"}},
+		}},
+		GenerationConfig: dto.GeminiChatGenerationConfig{MaxOutputTokens: 256},
+		Tools:            []byte(`[{"functionDeclarations":[{"name":"weather","description":"Get weather","parameters":{"type":"object","properties":{"city":{"type":"string"}}}}]}]`),
+	}
+
+	convertedAny, err := (&Adaptor{}).ConvertGeminiRequest(nil, info, request)
+	if err != nil {
+		t.Fatalf("ConvertGeminiRequest error = %v", err)
+	}
+	converted, ok := convertedAny.(*dto.OpenAIResponsesRequest)
+	if !ok {
+		t.Fatalf("converted type = %T, want *dto.OpenAIResponsesRequest", convertedAny)
+	}
+	if info.RelayMode != relayconstant.RelayModeResponses || info.RequestURLPath != "/v1/responses" {
+		t.Fatalf("upstream mode/path = %d/%q, want responses /v1/responses", info.RelayMode, info.RequestURLPath)
+	}
+	if !converted.Stream || converted.MaxOutputTokens != 256 {
+		t.Fatalf("converted stream/max_output_tokens = %t/%d, want true/256", converted.Stream, converted.MaxOutputTokens)
+	}
+	if len(converted.Tools) == 0 {
+		t.Fatal("converted responses tools are empty")
+	}
+	wantChain := []types.RelayFormat{types.RelayFormatGemini, types.RelayFormatOpenAI, types.RelayFormatOpenAIResponses}
+	if len(info.RequestConversionChain) != len(wantChain) {
+		t.Fatalf("RequestConversionChain = %#v, want %#v", info.RequestConversionChain, wantChain)
+	}
+	for i := range wantChain {
+		if info.RequestConversionChain[i] != wantChain[i] {
+			t.Fatalf("RequestConversionChain = %#v, want %#v", info.RequestConversionChain, wantChain)
+		}
+	}
+}
+
+func TestConvertResponsesBodyToGeminiBodyPreservesTextToolAndUsage(t *testing.T) {
+	body, err := convertResponsesBodyToGeminiBody(&dto.OpenAIResponsesResponse{
+		ID:        "resp_1",
+		Model:     "gpt-5",
+		CreatedAt: 1700000000,
+		Status:    "completed",
+		Output: []dto.ResponsesOutput{
+			{
+				Type:   "message",
+				ID:     "msg_1",
+				Status: "completed",
+				Role:   "assistant",
+				Content: []dto.ResponsesOutputContent{{
+					Type: "output_text",
+					Text: "hello",
+				}},
+			},
+			{
+				Type:      "function_call",
+				ID:        "fc_1",
+				Status:    "completed",
+				CallId:    "call_weather",
+				Name:      "weather",
+				Arguments: `{"city":"Shanghai"}`,
+			},
+		},
+	}, &dto.Usage{PromptTokens: 10, CompletionTokens: 4, TotalTokens: 14}, &relaycommon.RelayInfo{
+		RelayFormat: types.RelayFormatGemini,
+		ChannelMeta: &relaycommon.ChannelMeta{UpstreamModelName: "gpt-5"},
+	})
+	if err != nil {
+		t.Fatalf("convertResponsesBodyToGeminiBody error = %v", err)
+	}
+
+	var got dto.GeminiChatResponse
+	if err := common.Unmarshal(body, &got); err != nil {
+		t.Fatalf("unmarshal Gemini response error = %v", err)
+	}
+	if len(got.Candidates) != 1 {
+		t.Fatalf("candidates len = %d, want 1: %s", len(got.Candidates), body)
+	}
+	if got.UsageMetadata.PromptTokenCount != 10 || got.UsageMetadata.CandidatesTokenCount != 4 {
+		t.Fatalf("usage = %+v, want prompt=10 candidates=4", got.UsageMetadata)
+	}
+	var sawText, sawTool bool
+	for _, part := range got.Candidates[0].Content.Parts {
+		if part.Text == "hello" {
+			sawText = true
+		}
+		if part.FunctionCall != nil && part.FunctionCall.FunctionName == "weather" {
+			sawTool = true
+		}
+	}
+	if !sawText || !sawTool {
+		t.Fatalf("parts = %+v, want text hello and functionCall weather", got.Candidates[0].Content.Parts)
+	}
+}
+
+func TestWriteResponsesStreamAsGeminiEmitsGeminiChunks(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1beta/models/gpt-5:streamGenerateContent", nil)
+	info := &relaycommon.RelayInfo{
+		RelayFormat: types.RelayFormatGemini,
+		ChannelMeta: &relaycommon.ChannelMeta{UpstreamModelName: "gpt-5"},
+	}
+	converter := relaycommon.NewResponsesToChatStreamConverter(false)
+
+	for _, event := range []dto.ResponsesStreamResponse{
+		{
+			Type:     "response.output_text.delta",
+			Delta:    "hello",
+			Response: &dto.OpenAIResponsesResponse{ID: "resp_1", Model: "gpt-5", CreatedAt: 1700000000},
+		},
+		{
+			Type: "response.completed",
+			Response: &dto.OpenAIResponsesResponse{
+				ID:        "resp_1",
+				Model:     "gpt-5",
+				CreatedAt: 1700000000,
+				Status:    "completed",
+				Usage:     &dto.Usage{InputTokens: 10, OutputTokens: 4, TotalTokens: 14},
+			},
+		},
+	} {
+		data, err := common.Marshal(event)
+		if err != nil {
+			t.Fatalf("marshal %s event error = %v", event.Type, err)
+		}
+		if err := writeResponsesStreamAsGemini(c, info, converter, string(data)); err != nil {
+			t.Fatalf("write %s event error = %v", event.Type, err)
+		}
+	}
+
+	out := recorder.Body.String()
+	for _, want := range []string{
+		`"text":"hello"`,
+		`"finishReason":"STOP"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("stream output missing %q:\n%s", want, out)
+		}
+	}
+}
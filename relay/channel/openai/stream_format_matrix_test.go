@@ -0,0 +1,133 @@
+package openai
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/relay/helper"
+	"github.com/zhongruan0522/new-api/types"
+)
+
+// chat 流式块是所有非原生转换的中间格式，这里对每种客户端格式校验文本、推理、工具调用与用量都能到达客户端
+func TestHandleStreamFormatMatrixPreservesTextReasoningToolsAndUsage(t *testing.T) {
+	cases := []struct {
+		format types.RelayFormat
+		want   []string
+	}{
+		{
+			format: types.RelayFormatOpenAI,
+			want: []string{
+				`"reasoning_content":"think"`,
+				`"content":"hello"`,
+				`"name":"weather"`,
+				"data: [DONE]",
+			},
+		},
+		{
+			format: types.RelayFormatOpenAIResponses,
+			want: []string{
+				"event: response.created",
+				"event: response.reasoning_summary_text.delta",
+				"event: response.output_text.delta",
+				`"delta":"hello"`,
+				`"type":"function_call"`,
+				`"name":"weather"`,
+				"event: response.completed",
+				`"input_tokens":10`,
+				`"output_tokens":4`,
+			},
+		},
+		{
+			format: types.RelayFormatClaude,
+			want: []string{
+				"event: message_start",
+				`"type":"thinking_delta"`,
+				`"text":"hello"`,
+				`"type":"tool_use"`,
+				`"name":"weather"`,
+				"event: message_stop",
+			},
+		},
+		{
+			format: types.RelayFormatGemini,
+			want: []string{
+				`"thought":true`,
+				`"text":"hello"`,
+				`"functionCall":{"name":"weather"`,
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(string(tc.format), func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+			info := &relaycommon.RelayInfo{
+				RelayFormat:        tc.format,
+				ShouldIncludeUsage: true,
+				ClaudeConvertInfo:  &relaycommon.ClaudeConvertInfo{LastMessagesType: relaycommon.LastMessageTypeNone},
+				GeminiConvertInfo:  &relaycommon.GeminiConvertInfo{},
+				ChannelMeta:        &relaycommon.ChannelMeta{UpstreamModelName: "gpt-5"},
+			}
+
+			for _, chunk := range matrixChatStreamChunks() {
+				data, err := common.Marshal(chunk)
+				if err != nil {
+					t.Fatalf("marshal chunk error = %v", err)
+				}
+				if err := HandleStreamFormat(c, info, string(data), false, false); err != nil {
+					t.Fatalf("HandleStreamFormat error = %v", err)
+				}
+			}
+			usage := &dto.Usage{PromptTokens: 10, CompletionTokens: 4, TotalTokens: 14}
+			final := helper.GenerateFinalUsageResponse("chatcmpl_1", 1700000000, "gpt-5", *usage)
+			finalData, err := common.Marshal(final)
+			if err != nil {
+				t.Fatalf("marshal final chunk error = %v", err)
+			}
+			HandleFinalResponse(c, info, string(finalData), "chatcmpl_1", 1700000000, "gpt-5", "", usage, false)
+
+			out := recorder.Body.String()
+			for _, want := range tc.want {
+				if !strings.Contains(out, want) {
+					t.Fatalf("%s stream output missing %q:\n%s", tc.format, want, out)
+				}
+			}
+		})
+	}
+}
+
+func matrixChatStreamChunks() []*dto.ChatCompletionsStreamResponse {
+	newChunk := func(delta dto.ChatCompletionsStreamResponseChoiceDelta, finishReason *string) *dto.ChatCompletionsStreamResponse {
+		return &dto.ChatCompletionsStreamResponse{
+			Id:      "chatcmpl_1",
+			Object:  "chat.completion.chunk",
+			Created: 1700000000,
+			Model:   "gpt-5",
+			Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: delta, FinishReason: finishReason}},
+		}
+	}
+	text := dto.ChatCompletionsStreamResponseChoiceDelta{Role: "assistant"}
+	text.SetContentString("hello")
+	return []*dto.ChatCompletionsStreamResponse{
+		newChunk(dto.ChatCompletionsStreamResponseChoiceDelta{Role: "assistant", ReasoningContent: common.GetPointer("think")}, nil),
+		newChunk(text, nil),
+		newChunk(dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: []dto.ToolCallResponse{{
+			Index: common.GetPointer(0),
+			ID:    "call_weather",
+			Type:  "function",
+			Function: dto.FunctionResponse{
+				Name:      "weather",
+				Arguments: `{"city":"Shanghai"}`,
+			},
+		}}}, nil),
+		newChunk(dto.ChatCompletionsStreamResponseChoiceDelta{}, common.GetPointer("tool_calls")),
+	}
+}
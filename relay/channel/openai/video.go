@@ -0,0 +1,197 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/relay/channel"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VideoAdaptor relays the OpenAI /v1/videos API (submit, retrieve, download content).
+type VideoAdaptor struct{}
+
+func (a *VideoAdaptor) Init(info *relaycommon.RelayInfo) {
+}
+
+func (a *VideoAdaptor) GetChannelName() string {
+	return ChannelName
+}
+
+func (a *VideoAdaptor) videoURL(info *relaycommon.RelayInfo, suffix string) string {
+	return relaycommon.GetFullRequestURL(info.ChannelBaseUrl, "/v1/videos"+suffix, info.ChannelType)
+}
+
+func (a *VideoAdaptor) authHeader(info *relaycommon.RelayInfo) map[string]string {
+	header := map[string]string{
+		"Authorization": "Bearer " + info.ApiKey,
+	}
+	if info.ChannelType == constant.ChannelTypeOpenAI && info.Organization != "" {
+		header["OpenAI-Organization"] = info.Organization
+	}
+	return header
+}
+
+func (a *VideoAdaptor) SubmitTask(c *gin.Context, info *relaycommon.RelayInfo, request *dto.VideoRequest) (*http.Response, error) {
+	var body io.Reader
+	contentType := "application/json"
+	if strings.Contains(c.Request.Header.Get("Content-Type"), "multipart/form-data") {
+		form, err := common.ParseMultipartFormReusable(c)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing multipart form: %w", err)
+		}
+		buf, formContentType, err := buildVideoSubmitForm(form, request)
+		if err != nil {
+			return nil, err
+		}
+		body = buf
+		contentType = formContentType
+	} else {
+		payload := map[string]any{
+			"model":  request.Model,
+			"prompt": request.Prompt,
+		}
+		if request.Seconds > 0 {
+			payload["seconds"] = strconv.Itoa(int(request.Seconds))
+		}
+		if request.Size != "" {
+			payload["size"] = request.Size
+		}
+		jsonData, err := common.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(jsonData)
+	}
+	return channel.DoTaskSubmitRequest(c, info, a.videoURL(info, ""), contentType, a.authHeader(info), body)
+}
+
+// buildVideoSubmitForm copies the client form with the mapped model name, keeping input_reference files.
+func buildVideoSubmitForm(form *multipart.Form, request *dto.VideoRequest) (*bytes.Buffer, string, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	if err := writer.WriteField("model", request.Model); err != nil {
+		return nil, "", err
+	}
+	for key, values := range form.Value {
+		if key == "model" {
+			continue
+		}
+		for _, value := range values {
+			if err := writer.WriteField(key, value); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	for key, files := range form.File {
+		for _, fileHeader := range files {
+			file, err := fileHeader.Open()
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to open file %s: %w", fileHeader.Filename, err)
+			}
+			h := make(textproto.MIMEHeader)
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, key, fileHeader.Filename))
+			h.Set("Content-Type", common.GetStringIfEmpty(fileHeader.Header.Get("Content-Type"), "application/octet-stream"))
+			part, err := writer.CreatePart(h)
+			if err == nil {
+				_, err = io.Copy(part, file)
+			}
+			file.Close()
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to copy file %s: %w", fileHeader.Filename, err)
+			}
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return &buf, writer.FormDataContentType(), nil
+}
+
+type openAIVideoResponse struct {
+	Id       string          `json:"id"`
+	Status   string          `json:"status"`
+	Progress int             `json:"progress"`
+	Error    *dto.VideoError `json:"error,omitempty"`
+}
+
+func (r *openAIVideoResponse) toTaskInfo() *channel.TaskInfo {
+	info := &channel.TaskInfo{
+		UpstreamTaskId: r.Id,
+		Status:         r.Status,
+		Progress:       r.Progress,
+	}
+	switch r.Status {
+	case dto.VideoStatusQueued, dto.VideoStatusInProgress, dto.VideoStatusCompleted:
+	case dto.VideoStatusFailed:
+		info.FailReason = "upstream task failed"
+		if r.Error != nil && r.Error.Message != "" {
+			info.FailReason = r.Error.Message
+		}
+	default:
+		info.Status = dto.VideoStatusInProgress
+	}
+	if info.Status == dto.VideoStatusCompleted {
+		info.Progress = 100
+	}
+	return info
+}
+
+func (a *VideoAdaptor) ParseSubmitResponse(resp *http.Response) (*channel.TaskInfo, error) {
+	defer resp.Body.Close()
+	var video openAIVideoResponse
+	if err := common.DecodeJson(resp.Body, &video); err != nil {
+		return nil, fmt.Errorf("decode video response failed: %w", err)
+	}
+	if video.Id == "" {
+		return nil, errors.New("upstream video id is empty")
+	}
+	return video.toTaskInfo(), nil
+}
+
+func (a *VideoAdaptor) FetchTask(ctx context.Context, info *relaycommon.RelayInfo, upstreamTaskId string) (*channel.TaskInfo, error) {
+	resp, err := channel.DoTaskBackgroundRequest(ctx, info, http.MethodGet, a.videoURL(info, "/"+upstreamTaskId), a.authHeader(info))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("fetch video status %d: %s", resp.StatusCode, string(body))
+	}
+	var video openAIVideoResponse
+	if err := common.DecodeJson(resp.Body, &video); err != nil {
+		return nil, fmt.Errorf("decode video response failed: %w", err)
+	}
+	return video.toTaskInfo(), nil
+}
+
+func (a *VideoAdaptor) FetchContent(ctx context.Context, info *relaycommon.RelayInfo, upstreamTaskId string) ([]byte, string, error) {
+	resp, err := channel.DoTaskBackgroundRequest(ctx, info, http.MethodGet, a.videoURL(info, "/"+upstreamTaskId+"/content"), a.authHeader(info))
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, "", fmt.Errorf("fetch video content status %d: %s", resp.StatusCode, string(body))
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("read video content failed: %w", err)
+	}
+	return data, common.GetStringIfEmpty(resp.Header.Get("Content-Type"), "video/mp4"), nil
+}
@@ -0,0 +1,24 @@
+package openai
+
+import (
+	"testing"
+
+	"github.com/zhongruan0522/new-api/dto"
+)
+
+func TestOpenAIVideoResponseToTaskInfo(t *testing.T) {
+	completed := (&openAIVideoResponse{Id: "video_1", Status: dto.VideoStatusCompleted, Progress: 90}).toTaskInfo()
+	if completed.Status != dto.VideoStatusCompleted || completed.Progress != 100 || completed.UpstreamTaskId != "video_1" {
+		t.Fatalf("completed = %+v", completed)
+	}
+
+	failed := (&openAIVideoResponse{Id: "video_2", Status: dto.VideoStatusFailed, Error: &dto.VideoError{Message: "moderation_blocked"}}).toTaskInfo()
+	if failed.Status != dto.VideoStatusFailed || failed.FailReason != "moderation_blocked" {
+		t.Fatalf("failed = %+v", failed)
+	}
+
+	unknown := (&openAIVideoResponse{Id: "video_3", Status: "processing", Progress: 30}).toTaskInfo()
+	if unknown.Status != dto.VideoStatusInProgress || unknown.Progress != 30 {
+		t.Fatalf("unknown = %+v", unknown)
+	}
+}
@@ -0,0 +1,160 @@
+package selfhosted
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+
+	"github.com/tidwall/gjson"
+)
+
+func marshalConverted(t *testing.T, request *dto.GeneralOpenAIRequest, backend dto.SelfHostedBackend) string {
+	t.Helper()
+	converted, err := convertStructuredOutput(request, backend)
+	if err != nil {
+		t.Fatalf("convertStructuredOutput returned error: %v", err)
+	}
+	data, err := common.Marshal(converted)
+	if err != nil {
+		t.Fatalf("marshal converted request failed: %v", err)
+	}
+	return string(data)
+}
+
+func TestStructuredOutputVLLMPassthrough(t *testing.T) {
+	body := marshalConverted(t, &dto.GeneralOpenAIRequest{
+		Model:       "qwen",
+		GuidedRegex: `\d+`,
+	}, dto.SelfHostedBackendVLLM)
+	if got := gjson.Get(body, "guided_regex").String(); got != `\d+` {
+		t.Fatalf("guided_regex = %q, body = %s", got, body)
+	}
+}
+
+func TestStructuredOutputLlamaCpp(t *testing.T) {
+	body := marshalConverted(t, &dto.GeneralOpenAIRequest{
+		Model:      "qwen",
+		GuidedJson: json.RawMessage(`{"type":"object","properties":{"a":{"type":"integer"}}}`),
+	}, dto.SelfHostedBackendLlamaCpp)
+	if gjson.Get(body, "guided_json").Exists() {
+		t.Fatalf("guided_json should be removed: %s", body)
+	}
+	if gjson.Get(body, "response_format.type").String() != "json_schema" || gjson.Get(body, "response_format.json_schema.schema.properties.a.type").String() != "integer" {
+		t.Fatalf("response_format = %s", body)
+	}
+
+	body = marshalConverted(t, &dto.GeneralOpenAIRequest{
+		Model:        "qwen",
+		GuidedChoice: json.RawMessage(`["yes","say \"no\""]`),
+	}, dto.SelfHostedBackendLlamaCpp)
+	if got := gjson.Get(body, "grammar").String(); got != `root ::= "yes" | "say \"no\""` {
+		t.Fatalf("grammar = %q", got)
+	}
+
+	if _, err := convertStructuredOutput(&dto.GeneralOpenAIRequest{GuidedRegex: `\d+`}, dto.SelfHostedBackendLlamaCpp); err == nil {
+		t.Fatal("guided_regex should be rejected for llama.cpp")
+	}
+}
+
+func TestStructuredOutputTGI(t *testing.T) {
+	body := marshalConverted(t, &dto.GeneralOpenAIRequest{
+		Model:       "qwen",
+		GuidedRegex: `[a-z]+`,
+	}, dto.SelfHostedBackendTGI)
+	if gjson.Get(body, "response_format.type").String() != "regex" || gjson.Get(body, "response_format.value").String() != "[a-z]+" {
+		t.Fatalf("response_format = %s", body)
+	}
+
+	body = marshalConverted(t, &dto.GeneralOpenAIRequest{
+		Model:      "qwen",
+		GuidedJson: json.RawMessage(`"{\"type\":\"object\"}"`),
+	}, dto.SelfHostedBackendTGI)
+	if gjson.Get(body, "response_format.type").String() != "json_object" || gjson.Get(body, "response_format.value.type").String() != "object" {
+		t.Fatalf("response_format = %s", body)
+	}
+
+	if _, err := convertStructuredOutput(&dto.GeneralOpenAIRequest{GuidedRegex: "a", GuidedGrammar: "b"}, dto.SelfHostedBackendTGI); err == nil {
+		t.Fatal("multiple guided params should be rejected")
+	}
+}
+
+func TestFetchServerMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/metrics":
+			if r.Header.Get("X-Backend") == "" {
+				_, _ = w.Write([]byte(`# HELP vllm:num_requests_waiting Number of requests waiting.
+# TYPE vllm:num_requests_waiting gauge
+vllm:num_requests_waiting{engine="0",model_name="qwen"} 3.0
+vllm:num_requests_waiting{engine="1",model_name="qwen"} 6.0
+vllm:num_requests_running{engine="0",model_name="qwen"} 12.0
+vllm:gpu_cache_usage_perc{engine="0",model_name="qwen"} 0.42
+tgi_queue_size 2
+tgi_batch_current_size 4
+llamacpp:kv_cache_usage_ratio 0.5
+llamacpp:requests_deferred 1
+`))
+			}
+		case "/slots":
+			_, _ = w.Write([]byte(`[{"id":0,"n_ctx":4096,"is_processing":true},{"id":1,"n_ctx":4096,"is_processing":false}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	metrics, err := FetchServerMetrics(server.URL+"/", "", "", dto.SelfHostedBackendVLLM)
+	if err != nil {
+		t.Fatalf("vllm metrics error: %v", err)
+	}
+	if metrics.Waiting != 9 || metrics.Running != 12 || metrics.KVCacheUsage != 0.42 {
+		t.Fatalf("vllm metrics = %+v", metrics)
+	}
+	if !metrics.IsSaturated(dto.ChannelOtherSettings{}) {
+		t.Fatal("9 waiting requests should exceed the default queue limit")
+	}
+	if metrics.IsSaturated(dto.ChannelOtherSettings{SelfHostedMaxQueue: -1}) {
+		t.Fatal("negative queue limit should disable the queue check")
+	}
+
+	metrics, err = FetchServerMetrics(server.URL, "", "", dto.SelfHostedBackendLlamaCpp)
+	if err != nil {
+		t.Fatalf("llama.cpp metrics error: %v", err)
+	}
+	if metrics.TotalSlots != 2 || metrics.Running != 1 || metrics.Waiting != 1 || metrics.KVCacheUsage != 0.5 {
+		t.Fatalf("llama.cpp metrics = %+v", metrics)
+	}
+	if metrics.IsSaturated(dto.ChannelOtherSettings{}) {
+		t.Fatal("llama.cpp with a free slot should not be saturated")
+	}
+	if !metrics.IsSaturated(dto.ChannelOtherSettings{SelfHostedMaxKVCacheUsage: 0.5}) {
+		t.Fatal("KV cache usage at the limit should be saturated")
+	}
+
+	metrics, err = FetchServerMetrics(server.URL, "", "", dto.SelfHostedBackendTGI)
+	if err != nil {
+		t.Fatalf("tgi metrics error: %v", err)
+	}
+	if metrics.Waiting != 2 || metrics.Running != 4 {
+		t.Fatalf("tgi metrics = %+v", metrics)
+	}
+
+	// 配置了渠道代理时指标请求经过代理
+	var proxiedHost string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedHost = r.URL.Host
+		_, _ = w.Write([]byte("tgi_queue_size 5\n"))
+	}))
+	defer proxy.Close()
+	metrics, err = FetchServerMetrics("http://self-hosted.internal:8080", "", proxy.URL, dto.SelfHostedBackendTGI)
+	if err != nil {
+		t.Fatalf("proxied tgi metrics error: %v", err)
+	}
+	if proxiedHost != "self-hosted.internal:8080" || metrics.Waiting != 5 {
+		t.Fatalf("proxied host = %q, metrics = %+v", proxiedHost, metrics)
+	}
+}
@@ -0,0 +1,8 @@
+package selfhosted
+
+// ModelList 自部署服务的模型由管理员通过 /v1/models 拉取，这里只保留占位
+var ModelList = []string{
+	"Qwen/Qwen2.5-7B-Instruct",
+}
+
+var ChannelName = "selfhosted"
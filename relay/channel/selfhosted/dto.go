@@ -0,0 +1,51 @@
+package selfhosted
+
+import (
+	"encoding/json"
+
+	"github.com/zhongruan0522/new-api/dto"
+)
+
+// ChatRequest 在 OpenAI 请求上追加 llama.cpp / TGI 私有的结构化输出字段。
+// ResponseFormat 与内嵌请求同名，序列化时覆盖内嵌字段。
+type ChatRequest struct {
+	*dto.GeneralOpenAIRequest
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+	// llama.cpp GBNF 语法
+	Grammar string `json:"grammar,omitempty"`
+}
+
+// ResponseFormat 兼容 OpenAI 的 json_schema 以及 TGI 的 {type, value} 写法
+type ResponseFormat struct {
+	Type       string          `json:"type"`
+	JsonSchema json.RawMessage `json:"json_schema,omitempty"`
+	Value      any             `json:"value,omitempty"`
+}
+
+// SlotInfo llama.cpp /slots 返回的单个槽位，新版使用 is_processing，旧版使用 state (0 空闲, 1 处理中)
+type SlotInfo struct {
+	Id           int   `json:"id"`
+	NCtx         int   `json:"n_ctx"`
+	IsProcessing *bool `json:"is_processing,omitempty"`
+	State        *int  `json:"state,omitempty"`
+}
+
+func (s SlotInfo) busy() bool {
+	if s.IsProcessing != nil {
+		return *s.IsProcessing
+	}
+	return s.State != nil && *s.State != 0
+}
+
+// ServerMetrics 自部署推理服务的负载快照
+type ServerMetrics struct {
+	Backend dto.SelfHostedBackend `json:"backend"`
+	// Running 正在处理的请求数，Waiting 排队等待的请求数
+	Running int `json:"running"`
+	Waiting int `json:"waiting"`
+	// TotalSlots llama.cpp 的并发槽位数，其他后端为 0
+	TotalSlots int `json:"total_slots,omitempty"`
+	// KVCacheUsage KV cache 占用比例 (0-1)，后端未暴露时为 0
+	KVCacheUsage float64 `json:"kv_cache_usage"`
+	FetchedAt    int64   `json:"fetched_at"`
+}
@@ -0,0 +1,188 @@
+package selfhosted
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/service"
+)
+
+const (
+	// 未配置阈值时的默认值：排队超过 8 个请求或 KV cache 占用超过 95% 视为饱和
+	defaultMaxQueue          = 8
+	defaultMaxKVCacheUsage   = 0.95
+	metricsRequestTimeout    = 5 * time.Second
+	metricsResponseSizeLimit = 4 << 20
+)
+
+// IsSaturated 根据渠道配置的阈值判断后端是否已饱和，饱和的渠道在选渠道时会被跳过
+func (m *ServerMetrics) IsSaturated(settings dto.ChannelOtherSettings) bool {
+	maxQueue := settings.SelfHostedMaxQueue
+	if maxQueue == 0 {
+		maxQueue = defaultMaxQueue
+	}
+	maxKVCacheUsage := settings.SelfHostedMaxKVCacheUsage
+	if maxKVCacheUsage == 0 {
+		maxKVCacheUsage = defaultMaxKVCacheUsage
+	}
+	if maxQueue > 0 {
+		if m.Waiting >= maxQueue {
+			return true
+		}
+		// llama.cpp 没有空闲槽位时新请求只能等待
+		if m.TotalSlots > 0 && m.Running >= m.TotalSlots {
+			return true
+		}
+	}
+	return maxKVCacheUsage > 0 && m.KVCacheUsage >= maxKVCacheUsage
+}
+
+// FetchServerMetrics 抓取自部署服务的负载：vLLM / TGI 读取 Prometheus /metrics，llama.cpp 读取 /slots。
+// 与转发请求一样经过渠道配置的代理
+func FetchServerMetrics(baseURL, apiKey, proxyURL string, backend dto.SelfHostedBackend) (*ServerMetrics, error) {
+	client, err := service.NewProxyHttpClient(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("创建HTTP客户端失败: %v", err)
+	}
+	fetchEndpoint := func(url string) ([]byte, error) {
+		return fetchMetricsEndpoint(client, url, apiKey)
+	}
+	baseURL = strings.TrimRight(baseURL, "/")
+	metrics := &ServerMetrics{Backend: backend}
+	switch backend {
+	case dto.SelfHostedBackendLlamaCpp:
+		body, err := fetchEndpoint(baseURL + "/slots")
+		if err != nil {
+			return nil, err
+		}
+		var slots []SlotInfo
+		if err := common.Unmarshal(body, &slots); err != nil {
+			return nil, fmt.Errorf("解析 /slots 响应失败: %v", err)
+		}
+		metrics.TotalSlots = len(slots)
+		for _, slot := range slots {
+			if slot.busy() {
+				metrics.Running++
+			}
+		}
+		// 排队数与 KV cache 占用只在以 --metrics 启动时提供，缺失时忽略
+		if body, err := fetchEndpoint(baseURL + "/metrics"); err == nil {
+			samples := parsePrometheusText(body)
+			metrics.Waiting = int(sumSamples(samples["llamacpp:requests_deferred"]))
+			metrics.KVCacheUsage = maxSamples(samples["llamacpp:kv_cache_usage_ratio"])
+		}
+	case dto.SelfHostedBackendTGI:
+		body, err := fetchEndpoint(baseURL + "/metrics")
+		if err != nil {
+			return nil, err
+		}
+		samples := parsePrometheusText(body)
+		metrics.Running = int(sumSamples(samples["tgi_batch_current_size"]))
+		metrics.Waiting = int(sumSamples(samples["tgi_queue_size"]))
+	default:
+		body, err := fetchEndpoint(baseURL + "/metrics")
+		if err != nil {
+			return nil, err
+		}
+		samples := parsePrometheusText(body)
+		metrics.Running = int(sumSamples(samples["vllm:num_requests_running"]))
+		metrics.Waiting = int(sumSamples(samples["vllm:num_requests_waiting"]))
+		// 新版本 vLLM 将 gpu_cache_usage_perc 更名为 kv_cache_usage_perc
+		metrics.KVCacheUsage = maxSamples(samples["vllm:kv_cache_usage_perc"])
+		if _, ok := samples["vllm:kv_cache_usage_perc"]; !ok {
+			metrics.KVCacheUsage = maxSamples(samples["vllm:gpu_cache_usage_perc"])
+		}
+	}
+	metrics.FetchedAt = common.GetTimestamp()
+	return metrics, nil
+}
+
+func fetchMetricsEndpoint(client *http.Client, url, apiKey string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), metricsRequestTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	if apiKey != "" {
+		request.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %v", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, metricsResponseSizeLimit))
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %v", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("服务器返回错误 %d: %s", response.StatusCode, string(body))
+	}
+	return body, nil
+}
+
+// parsePrometheusText 解析 Prometheus 文本格式，按指标名汇总所有标签组合的取值
+func parsePrometheusText(body []byte) map[string][]float64 {
+	samples := make(map[string][]float64)
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), metricsResponseSizeLimit)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var name, rest string
+		if idx := strings.IndexByte(line, '{'); idx >= 0 {
+			end := strings.LastIndexByte(line, '}')
+			if end < idx {
+				continue
+			}
+			name, rest = line[:idx], line[end+1:]
+		} else {
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+			name, rest = fields[0], strings.Join(fields[1:], " ")
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		samples[name] = append(samples[name], value)
+	}
+	return samples
+}
+
+func sumSamples(values []float64) float64 {
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+	return sum
+}
+
+func maxSamples(values []float64) float64 {
+	result := 0.0
+	for _, value := range values {
+		if value > result {
+			result = value
+		}
+	}
+	return result
+}
@@ -0,0 +1,134 @@
+package selfhosted
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+)
+
+// guidedDecoding vLLM 风格的 guided_* 参数，同一请求只允许设置一种
+type guidedDecoding struct {
+	json    json.RawMessage
+	regex   string
+	choice  []string
+	grammar string
+}
+
+func takeGuidedDecoding(request *dto.GeneralOpenAIRequest) (*guidedDecoding, error) {
+	guided := &guidedDecoding{
+		json:    request.GuidedJson,
+		regex:   request.GuidedRegex,
+		grammar: request.GuidedGrammar,
+	}
+	set := 0
+	if len(guided.json) > 0 {
+		set++
+	}
+	if guided.regex != "" {
+		set++
+	}
+	if len(request.GuidedChoice) > 0 {
+		if err := common.Unmarshal(request.GuidedChoice, &guided.choice); err != nil || len(guided.choice) == 0 {
+			return nil, errors.New("guided_choice must be a non-empty array of strings")
+		}
+		set++
+	}
+	if guided.grammar != "" {
+		set++
+	}
+	if set == 0 {
+		return nil, nil
+	}
+	if set > 1 {
+		return nil, errors.New("only one of guided_json, guided_regex, guided_choice and guided_grammar can be set")
+	}
+	// guided_json 既可以是 schema 对象，也可以是 schema 的 JSON 字符串
+	if common.GetJsonType(guided.json) == "string" {
+		var schema string
+		if err := common.Unmarshal(guided.json, &schema); err != nil {
+			return nil, fmt.Errorf("invalid guided_json: %w", err)
+		}
+		guided.json = json.RawMessage(schema)
+	}
+	if len(guided.json) > 0 && !json.Valid(guided.json) {
+		return nil, errors.New("guided_json is not a valid JSON schema")
+	}
+
+	request.GuidedJson = nil
+	request.GuidedRegex = ""
+	request.GuidedChoice = nil
+	request.GuidedGrammar = ""
+	return guided, nil
+}
+
+// convertStructuredOutput 将 vLLM 的 guided_* 参数映射为各后端原生的结构化输出写法。
+// vLLM 原样透传；llama.cpp 使用 json_schema response_format 与 GBNF grammar；TGI 使用 {type, value} response_format。
+func convertStructuredOutput(request *dto.GeneralOpenAIRequest, backend dto.SelfHostedBackend) (any, error) {
+	if backend == dto.SelfHostedBackendVLLM {
+		return request, nil
+	}
+	guided, err := takeGuidedDecoding(request)
+	if err != nil {
+		return nil, err
+	}
+	if guided == nil {
+		return request, nil
+	}
+
+	chatReq := &ChatRequest{GeneralOpenAIRequest: request}
+	switch backend {
+	case dto.SelfHostedBackendLlamaCpp:
+		switch {
+		case len(guided.json) > 0:
+			jsonSchema, err := common.Marshal(dto.FormatJsonSchema{Name: "guided_json", Schema: guided.json})
+			if err != nil {
+				return nil, err
+			}
+			chatReq.ResponseFormat = &ResponseFormat{Type: "json_schema", JsonSchema: jsonSchema}
+		case len(guided.choice) > 0:
+			chatReq.Grammar = choiceGrammar(guided.choice)
+		case guided.grammar != "":
+			chatReq.Grammar = guided.grammar
+		default:
+			return nil, errors.New("guided_regex is not supported by llama.cpp backend")
+		}
+	case dto.SelfHostedBackendTGI:
+		switch {
+		case len(guided.json) > 0:
+			chatReq.ResponseFormat = &ResponseFormat{Type: "json_object", Value: guided.json}
+		case guided.regex != "":
+			chatReq.ResponseFormat = &ResponseFormat{Type: "regex", Value: guided.regex}
+		case len(guided.choice) > 0:
+			chatReq.ResponseFormat = &ResponseFormat{Type: "regex", Value: choiceRegex(guided.choice)}
+		default:
+			return nil, errors.New("guided_grammar is not supported by TGI backend")
+		}
+	}
+	if chatReq.ResponseFormat == nil && request.ResponseFormat != nil {
+		chatReq.ResponseFormat = &ResponseFormat{Type: request.ResponseFormat.Type, JsonSchema: request.ResponseFormat.JsonSchema}
+	}
+	return chatReq, nil
+}
+
+// choiceGrammar 生成只允许输出给定选项之一的 GBNF 语法
+func choiceGrammar(choices []string) string {
+	quoted := make([]string, 0, len(choices))
+	for _, choice := range choices {
+		escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(choice)
+		quoted = append(quoted, `"`+escaped+`"`)
+	}
+	return "root ::= " + strings.Join(quoted, " | ")
+}
+
+func choiceRegex(choices []string) string {
+	quoted := make([]string, 0, len(choices))
+	for _, choice := range choices {
+		quoted = append(quoted, regexp.QuoteMeta(choice))
+	}
+	return "(" + strings.Join(quoted, "|") + ")"
+}
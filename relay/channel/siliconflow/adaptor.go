@@ -12,12 +12,16 @@ import (
 	"github.com/zhongruan0522/new-api/relay/channel/openai"
 	relaycommon "github.com/zhongruan0522/new-api/relay/common"
 	"github.com/zhongruan0522/new-api/relay/constant"
+	"github.com/zhongruan0522/new-api/service"
 	"github.com/zhongruan0522/new-api/types"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Adaptor struct {
+	transcription service.TranscriptionMeta
+	// 转写请求重新拼装的 multipart Content-Type
+	formContentType string
 }
 
 func (a *Adaptor) ConvertGeminiRequest(*gin.Context, *relaycommon.RelayInfo, *dto.GeminiChatRequest) (any, error) {
@@ -31,8 +35,7 @@ func (a *Adaptor) ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayIn
 }
 
 func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.AudioRequest) (io.Reader, error) {
-	adaptor := openai.Adaptor{}
-	return adaptor.ConvertAudioRequest(c, info, request)
+	return a.convertAudioRequest(c, info, request)
 }
 
 func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
@@ -56,6 +59,24 @@ func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInf
 		sfRequest.BatchSize = request.N
 	}
 
+	// 编辑与变体统一走生图接口，输入图片依次填入 image、image2、image3
+	if info.RelayMode == constant.RelayModeImagesEdits || info.RelayMode == constant.RelayModeImagesVariations {
+		images, mask, err := service.GetImageEditInputs(c, &request)
+		if err != nil {
+			return nil, err
+		}
+		if mask != nil {
+			return nil, errors.New("mask is not supported by siliconflow image models")
+		}
+		if len(images) > 3 {
+			return nil, errors.New("siliconflow supports at most 3 input images")
+		}
+		targets := []*string{&sfRequest.Image, &sfRequest.Image2, &sfRequest.Image3}
+		for i, img := range images {
+			*targets[i] = img.DataURL()
+		}
+	}
+
 	return sfRequest, nil
 }
 
@@ -66,12 +87,18 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 	if info.RelayMode == constant.RelayModeRerank {
 		return fmt.Sprintf("%s/v1/rerank", info.ChannelBaseUrl), nil
 	}
+	if info.RelayMode == constant.RelayModeImagesEdits || info.RelayMode == constant.RelayModeImagesVariations {
+		return fmt.Sprintf("%s/v1/images/generations", info.ChannelBaseUrl), nil
+	}
 	return relaycommon.GetFullRequestURL(info.ChannelBaseUrl, info.RequestURLPath, info.ChannelType), nil
 }
 
 func (a *Adaptor) SetupRequestHeader(c *gin.Context, req *http.Header, info *relaycommon.RelayInfo) error {
 	channel.SetupApiRequestHeader(info, c, req)
 	req.Set("Authorization", fmt.Sprintf("Bearer %s", info.ApiKey))
+	if a.formContentType != "" {
+		req.Set("Content-Type", a.formContentType)
+	}
 	return nil
 }
 
@@ -86,6 +113,7 @@ func (a *Adaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayIn
 			},
 		}
 	}
+	relaycommon.ApplyToolCallEmulation(info, request)
 	return request, nil
 }
 
@@ -111,6 +139,8 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 	switch info.RelayMode {
 	case constant.RelayModeRerank:
 		usage, err = siliconflowRerankHandler(c, info, resp)
+	case constant.RelayModeAudioTranscription:
+		usage, err = a.sfTranscriptionHandler(c, resp, info)
 	default:
 		adaptor := openai.Adaptor{}
 		usage, err = adaptor.DoResponse(c, resp, info)
@@ -0,0 +1,96 @@
+package siliconflow
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/relay/constant"
+	"github.com/zhongruan0522/new-api/service"
+	"github.com/zhongruan0522/new-api/setting/model_setting"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 固定 pcm 采样率，与按时长计费时的换算保持一致
+const sfSpeechPCMSampleRate = 24000
+
+type SFSpeechRequest struct {
+	Model          string  `json:"model"`
+	Input          string  `json:"input"`
+	Voice          string  `json:"voice"`
+	ResponseFormat string  `json:"response_format,omitempty"`
+	SampleRate     int     `json:"sample_rate,omitempty"`
+	Speed          float64 `json:"speed,omitempty"`
+	Stream         bool    `json:"stream"`
+}
+
+// sfVoice CosyVoice 的系统音色需要带上模型前缀，例如 FunAudioLLM/CosyVoice2-0.5B:alex
+func sfVoice(model string, voice string) string {
+	voice = model_setting.GetProviderVoice(ChannelName, voice)
+	if voice == "" || strings.Contains(voice, ":") {
+		return voice
+	}
+	return model + ":" + voice
+}
+
+func (a *Adaptor) convertAudioRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.AudioRequest) (io.Reader, error) {
+	switch info.RelayMode {
+	case constant.RelayModeAudioSpeech:
+		switch request.ResponseFormat {
+		case "", "mp3", "opus", "wav", "pcm":
+		default:
+			return nil, fmt.Errorf("siliconflow tts does not support response_format %s", request.ResponseFormat)
+		}
+		sfRequest := SFSpeechRequest{
+			Model:          request.Model,
+			Input:          request.Input,
+			Voice:          sfVoice(request.Model, request.Voice),
+			ResponseFormat: request.ResponseFormat,
+			Speed:          request.Speed,
+		}
+		if request.ResponseFormat == "pcm" {
+			sfRequest.SampleRate = sfSpeechPCMSampleRate
+		}
+		jsonData, err := common.Marshal(sfRequest)
+		if err != nil {
+			return nil, fmt.Errorf("error marshalling siliconflow speech request: %w", err)
+		}
+		return bytes.NewReader(jsonData), nil
+	case constant.RelayModeAudioTranscription:
+		// SenseVoice 只返回纯文本，其余格式由网关拼装
+		if !service.IsSupportedTranscriptionFormat(request.ResponseFormat) {
+			return nil, fmt.Errorf("siliconflow transcription does not support response_format %s", request.ResponseFormat)
+		}
+		input, err := service.GetAudioFileInput(c)
+		if err != nil {
+			return nil, err
+		}
+		a.transcription = input.TranscriptionMeta()
+		body, contentType, err := service.BuildAudioFileForm(input, map[string]string{"model": request.Model})
+		if err != nil {
+			return nil, err
+		}
+		a.formContentType = contentType
+		return body, nil
+	default:
+		return nil, errors.New("siliconflow does not support audio translations")
+	}
+}
+
+func (a *Adaptor) sfTranscriptionHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.Usage, *types.NewAPIError) {
+	return service.HandleTranscriptionResponse(c, info, resp, a.transcription, func(body []byte) (string, *types.NewAPIError) {
+		var sfResp dto.AudioResponse
+		if err := common.Unmarshal(body, &sfResp); err != nil {
+			return "", types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+		}
+		return sfResp.Text, nil
+	})
+}
@@ -0,0 +1,75 @@
+package siliconflow
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zhongruan0522/new-api/dto"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/relay/constant"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSFVoice(t *testing.T) {
+	const model = "FunAudioLLM/CosyVoice2-0.5B"
+	tests := map[string]string{
+		"alloy":                            model + ":alex",
+		"":                                 model + ":alex",
+		"benjamin":                         model + ":benjamin",
+		"speech:my-voice:abc":              "speech:my-voice:abc",
+		"FunAudioLLM/CosyVoice2-0.5B:anna": model + ":anna",
+	}
+	for voice, want := range tests {
+		if got := sfVoice(model, voice); got != want {
+			t.Fatalf("sfVoice(%q) = %q, want %q", voice, got, want)
+		}
+	}
+}
+
+func TestConvertTranscriptionRequestDropsResponseFormat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	_ = writer.WriteField("model", "FunAudioLLM/SenseVoiceSmall")
+	_ = writer.WriteField("response_format", "verbose_json")
+	part, _ := writer.CreateFormFile("file", "speech.mp3")
+	_, _ = part.Write([]byte("audio bytes"))
+	_ = writer.Close()
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/audio/transcriptions", &body)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+
+	a := &Adaptor{}
+	info := &relaycommon.RelayInfo{RelayMode: constant.RelayModeAudioTranscription}
+	reader, err := a.ConvertAudioRequest(c, info, dto.AudioRequest{Model: "FunAudioLLM/SenseVoiceSmall", ResponseFormat: "verbose_json"})
+	if err != nil {
+		t.Fatalf("ConvertAudioRequest returned error: %v", err)
+	}
+
+	_, params, err := mime.ParseMediaType(a.formContentType)
+	if err != nil {
+		t.Fatalf("form content type %q: %v", a.formContentType, err)
+	}
+	form, err := multipart.NewReader(reader, params["boundary"]).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatalf("read rebuilt form: %v", err)
+	}
+	if _, ok := form.Value["response_format"]; ok {
+		t.Fatal("response_format should not be forwarded to siliconflow")
+	}
+	if form.Value["model"][0] != "FunAudioLLM/SenseVoiceSmall" || len(form.File["file"]) != 1 {
+		t.Fatalf("rebuilt form = %v, files = %v", form.Value, form.File)
+	}
+	file, _ := form.File["file"][0].Open()
+	data, _ := io.ReadAll(file)
+	if string(data) != "audio bytes" {
+		t.Fatalf("file = %q", data)
+	}
+}
@@ -41,6 +41,7 @@ var ModelList = []string{
 	"Pro/mistralai/Mistral-7B-Instruct-v0.2",
 	"black-forest-labs/FLUX.1-schnell",
 	"FunAudioLLM/SenseVoiceSmall",
+	"FunAudioLLM/CosyVoice2-0.5B",
 	"netease-youdao/bce-embedding-base_v1",
 	"BAAI/bge-m3",
 	"internlm/internlm2_5-20b-chat",
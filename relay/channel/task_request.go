@@ -0,0 +1,64 @@
+package channel
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	common2 "github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DoTaskSubmitRequest sends a task submit request in the client request context,
+// applying the channel header override like DoApiRequest.
+func DoTaskSubmitRequest(c *gin.Context, info *common.RelayInfo, fullRequestURL string, contentType string, header map[string]string, requestBody io.Reader) (*http.Response, error) {
+	if common2.DebugEnabled {
+		println("fullRequestURL:", fullRequestURL)
+	}
+	req, err := http.NewRequest(http.MethodPost, fullRequestURL, requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("new request failed: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	headerOverride, err := processHeaderOverride(info, c)
+	if err != nil {
+		return nil, err
+	}
+	applyHeaderOverrideToRequest(req, headerOverride)
+	resp, err := doRequest(c, req, info)
+	if err != nil {
+		return nil, fmt.Errorf("do request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// DoTaskBackgroundRequest sends a poll or fetch request outside any client request,
+// so only the channel proxy is honored.
+func DoTaskBackgroundRequest(ctx context.Context, info *common.RelayInfo, method string, fullRequestURL string, header map[string]string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, fullRequestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("new request failed: %w", err)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	client := service.GetHttpClient()
+	if info.ChannelSetting.Proxy != "" {
+		client, err = service.NewProxyHttpClient(info.ChannelSetting.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("new proxy http client failed: %w", err)
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request failed: %w", err)
+	}
+	return resp, nil
+}
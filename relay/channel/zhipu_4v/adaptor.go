@@ -14,12 +14,16 @@ import (
 	"github.com/zhongruan0522/new-api/relay/channel/openai"
 	relaycommon "github.com/zhongruan0522/new-api/relay/common"
 	relayconstant "github.com/zhongruan0522/new-api/relay/constant"
+	"github.com/zhongruan0522/new-api/service"
 	"github.com/zhongruan0522/new-api/types"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Adaptor struct {
+	transcription service.TranscriptionMeta
+	// 转写请求重新拼装的 multipart Content-Type
+	formContentType string
 }
 
 func (a *Adaptor) ConvertGeminiRequest(*gin.Context, *relaycommon.RelayInfo, *dto.GeminiChatRequest) (any, error) {
@@ -32,11 +36,13 @@ func (a *Adaptor) ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayIn
 }
 
 func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.AudioRequest) (io.Reader, error) {
-	//TODO implement me
-	return nil, errors.New("not implemented")
+	return a.convertAudioRequest(c, info, request)
 }
 
 func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
+	if info.RelayMode != relayconstant.RelayModeImagesGenerations {
+		return nil, errors.New("zhipu only supports image generations")
+	}
 	return request, nil
 }
 
@@ -63,6 +69,10 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 				return fmt.Sprintf("%s/embeddings", specialPlan.OpenAIBaseURL), nil
 			}
 			return fmt.Sprintf("%s/api/paas/v4/embeddings", baseURL), nil
+		case relayconstant.RelayModeAudioSpeech:
+			return fmt.Sprintf("%s/api/paas/v4/audio/speech", baseURL), nil
+		case relayconstant.RelayModeAudioTranscription:
+			return fmt.Sprintf("%s/api/paas/v4/audio/transcriptions", baseURL), nil
 		case relayconstant.RelayModeImagesGenerations:
 			if hasSpecialPlan && specialPlan.OpenAIBaseURL != "" {
 				return fmt.Sprintf("%s/images/generations", specialPlan.OpenAIBaseURL), nil
@@ -84,6 +94,9 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 
 func (a *Adaptor) SetupRequestHeader(c *gin.Context, req *http.Header, info *relaycommon.RelayInfo) error {
 	channel.SetupApiRequestHeader(info, c, req)
+	if a.formContentType != "" {
+		req.Set("Content-Type", a.formContentType)
+	}
 	req.Set("Authorization", "Bearer "+info.ApiKey)
 	return nil
 }
@@ -120,8 +133,13 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 		adaptor := claude.Adaptor{}
 		return adaptor.DoResponse(c, resp, info)
 	default:
-		if info.RelayMode == relayconstant.RelayModeImagesGenerations {
+		switch info.RelayMode {
+		case relayconstant.RelayModeImagesGenerations:
 			return zhipu4vImageHandler(c, resp, info)
+		case relayconstant.RelayModeAudioSpeech:
+			return zhipuSpeechHandler(c, resp, info)
+		case relayconstant.RelayModeAudioTranscription:
+			return a.zhipuTranscriptionHandler(c, resp, info)
 		}
 		adaptor := openai.Adaptor{}
 		return adaptor.DoResponse(c, resp, info)
@@ -0,0 +1,119 @@
+package zhipu_4v
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	relayconstant "github.com/zhongruan0522/new-api/relay/constant"
+	"github.com/zhongruan0522/new-api/service"
+	"github.com/zhongruan0522/new-api/setting/model_setting"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GLM-TTS 输出 24kHz、16-bit、单声道 PCM
+const zhipuTTSSampleRate = 24000
+
+type zhipuSpeechRequest struct {
+	Model          string  `json:"model"`
+	Input          string  `json:"input"`
+	Voice          string  `json:"voice"`
+	ResponseFormat string  `json:"response_format,omitempty"`
+	Speed          float64 `json:"speed,omitempty"`
+}
+
+type zhipuTranscriptionResponse struct {
+	ID        string `json:"id"`
+	RequestID string `json:"request_id"`
+	Model     string `json:"model"`
+	Text      string `json:"text"`
+}
+
+func speechFormat(request dto.AudioRequest) string {
+	if request.ResponseFormat == "" {
+		return "wav"
+	}
+	return request.ResponseFormat
+}
+
+func (a *Adaptor) convertAudioRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.AudioRequest) (io.Reader, error) {
+	switch info.RelayMode {
+	case relayconstant.RelayModeAudioSpeech:
+		format := speechFormat(request)
+		if format != "wav" && format != "pcm" {
+			return nil, fmt.Errorf("zhipu tts only supports wav and pcm response_format, got %s", format)
+		}
+		if request.StreamFormat == "sse" {
+			return nil, errors.New("zhipu tts does not support stream_format sse")
+		}
+		jsonData, err := common.Marshal(zhipuSpeechRequest{
+			Model:          request.Model,
+			Input:          request.Input,
+			Voice:          model_setting.GetProviderVoice(ChannelName, request.Voice),
+			ResponseFormat: format,
+			Speed:          request.Speed,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error marshalling zhipu speech request: %w", err)
+		}
+		return bytes.NewReader(jsonData), nil
+	case relayconstant.RelayModeAudioTranscription:
+		if !service.IsSupportedTranscriptionFormat(request.ResponseFormat) {
+			return nil, fmt.Errorf("zhipu transcription does not support response_format %s", request.ResponseFormat)
+		}
+		input, err := service.GetAudioFileInput(c)
+		if err != nil {
+			return nil, err
+		}
+		a.transcription = input.TranscriptionMeta()
+
+		// 只转发 GLM-ASR 支持的字段，避免 OpenAI 专有参数被上游拒绝
+		body, contentType, err := service.BuildAudioFileForm(input, map[string]string{
+			"model":  request.Model,
+			"prompt": input.Prompt,
+		})
+		if err != nil {
+			return nil, err
+		}
+		a.formContentType = contentType
+		return body, nil
+	default:
+		return nil, errors.New("zhipu does not support audio translations")
+	}
+}
+
+func zhipuSpeechHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.Usage, *types.NewAPIError) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+	}
+	service.CloseResponseBodyGracefully(resp)
+
+	format := "wav"
+	if audioReq, ok := info.Request.(*dto.AudioRequest); ok {
+		format = speechFormat(*audioReq)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = "audio/" + format
+	}
+	c.Data(http.StatusOK, contentType, body)
+	return service.BuildSpeechUsage(c, info, body, format, zhipuTTSSampleRate), nil
+}
+
+func (a *Adaptor) zhipuTranscriptionHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.Usage, *types.NewAPIError) {
+	return service.HandleTranscriptionResponse(c, info, resp, a.transcription, func(body []byte) (string, *types.NewAPIError) {
+		var zhipuResp zhipuTranscriptionResponse
+		if err := common.Unmarshal(body, &zhipuResp); err != nil {
+			return "", types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+		}
+		return zhipuResp.Text, nil
+	})
+}
@@ -0,0 +1,59 @@
+package zhipu_4v
+
+import (
+	"bytes"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zhongruan0522/new-api/dto"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	relayconstant "github.com/zhongruan0522/new-api/relay/constant"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestConvertTranscriptionRequestRebuildsMultipartForm(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	_ = writer.WriteField("model", "glm-asr")
+	_ = writer.WriteField("prompt", "会议记录")
+	_ = writer.WriteField("language", "zh")
+	_ = writer.WriteField("response_format", "verbose_json")
+	_ = writer.WriteField("timestamp_granularities[]", "word")
+	part, _ := writer.CreateFormFile("file", "meeting.wav")
+	_, _ = part.Write([]byte("audio bytes"))
+	_ = writer.Close()
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/audio/transcriptions", &body)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+
+	a := &Adaptor{}
+	info := &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeAudioTranscription}
+	reader, err := a.ConvertAudioRequest(c, info, dto.AudioRequest{Model: "glm-asr", ResponseFormat: "verbose_json"})
+	if err != nil {
+		t.Fatalf("ConvertAudioRequest returned error: %v", err)
+	}
+	if a.transcription.Language != "zh" {
+		t.Fatalf("transcription language = %q, want zh", a.transcription.Language)
+	}
+
+	_, params, err := mime.ParseMediaType(a.formContentType)
+	if err != nil {
+		t.Fatalf("form content type %q: %v", a.formContentType, err)
+	}
+	form, err := multipart.NewReader(reader, params["boundary"]).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatalf("read rebuilt form: %v", err)
+	}
+	if len(form.Value) != 2 || form.Value["model"][0] != "glm-asr" || form.Value["prompt"][0] != "会议记录" {
+		t.Fatalf("rebuilt form fields = %v, want only model and prompt", form.Value)
+	}
+	if files := form.File["file"]; len(files) != 1 || files[0].Filename != "meeting.wav" || files[0].Size != int64(len("audio bytes")) {
+		t.Fatalf("rebuilt form files = %v", form.File)
+	}
+}
@@ -2,6 +2,7 @@ package zhipu_4v
 
 var ModelList = []string{
 	"glm-4", "glm-4v", "glm-3-turbo", "glm-4-alltools", "glm-4-plus", "glm-4-0520", "glm-4-air", "glm-4-airx", "glm-4-long", "glm-4-flash", "glm-4v-plus", "glm-4.6",
+	"glm-asr", "glm-tts",
 }
 
 var ChannelName = "zhipu_4v"
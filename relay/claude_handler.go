@@ -21,6 +21,8 @@ import (
 func ClaudeHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types.NewAPIError) {
 
 	info.InitChannelMeta(c)
+	// RelayInfo 在重试间复用，上一次尝试的工具调用模拟状态不能带到新渠道
+	info.ToolCallEmulation = nil
 
 	claudeReq, ok := info.Request.(*dto.ClaudeRequest)
 
@@ -80,6 +82,12 @@ func ClaudeHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *typ
 			}
 		}
 
+		// apply channel script
+		jsonData, err = relaycommon.ApplyChannelScriptToRequest(info, jsonData)
+		if err != nil {
+			return types.NewError(err, types.ErrorCodeChannelScriptFailed, types.ErrOptionWithSkipRetry())
+		}
+
 		if common.DebugEnabled {
 			println("requestBody: ", string(jsonData))
 		}
@@ -104,6 +112,22 @@ func ClaudeHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *typ
 		}
 	}
 
+	// apply response override
+	if err := relaycommon.ApplyResponseParamOverride(info, httpResp); err != nil {
+		return types.NewError(err, types.ErrorCodeChannelParamOverrideInvalid, types.ErrOptionWithSkipRetry())
+	}
+	defer relaycommon.ApplyResponseHeaderOverride(c, info)()
+
+	// apply channel script
+	if err := relaycommon.ApplyChannelScriptToResponse(info, httpResp); err != nil {
+		return types.NewError(err, types.ErrorCodeChannelScriptFailed, types.ErrOptionWithSkipRetry())
+	}
+
+	// restore emulated tool calls
+	if err := relaycommon.ApplyToolCallEmulationToResponse(info, httpResp); err != nil {
+		return types.NewError(err, types.ErrorCodeBadResponseBody, types.ErrOptionWithSkipRetry())
+	}
+
 	usage, newAPIError := adaptor.DoResponse(c, httpResp, info)
 	//log.Printf("usage: %v", usage)
 	if newAPIError != nil {
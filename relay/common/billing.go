@@ -1,6 +1,10 @@
 package common
 
-import "github.com/gin-gonic/gin"
+import (
+	"github.com/zhongruan0522/new-api/dto"
+
+	"github.com/gin-gonic/gin"
+)
 
 // BillingSettler 抽象计费会话的生命周期操作。
 // 由 service.BillingSession 实现，存储在 RelayInfo 上以避免循环引用。
@@ -18,4 +22,10 @@ type BillingSettler interface {
 
 	// GetPreConsumedQuota 返回实际预扣的额度值（信任用户可能为 0）。
 	GetPreConsumedQuota() int
+
+	// AddUsage 累加一轮上游用量，供网关工具循环等多轮请求结束后统一结算。
+	AddUsage(usage *dto.Usage)
+
+	// GetUsage 返回已累加的上游用量。
+	GetUsage() dto.Usage
 }
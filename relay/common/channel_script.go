@@ -0,0 +1,165 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/pkg/scripthook"
+	"github.com/zhongruan0522/new-api/setting/operation_setting"
+)
+
+// ChannelScriptLimits 返回当前配置的脚本执行限制
+func ChannelScriptLimits() scripthook.Limits {
+	setting := operation_setting.GetChannelScriptSetting()
+	return scripthook.Limits{
+		MaxSteps: setting.GetMaxExecutionSteps(),
+		Timeout:  setting.GetTimeout(),
+	}
+}
+
+// BuildChannelScriptContext 在 BuildParamOverrideContext 的基础上补充脚本可用的渠道与请求信息
+func BuildChannelScriptContext(info *RelayInfo) map[string]interface{} {
+	ctx := BuildParamOverrideContext(info)
+	if ctx == nil {
+		ctx = make(map[string]interface{})
+	}
+	ctx["relay_format"] = string(info.RelayFormat)
+	ctx["is_stream"] = info.IsStream
+	if info.ChannelMeta != nil {
+		ctx["channel_id"] = info.ChannelId
+		ctx["channel_type"] = info.ChannelType
+	}
+	return ctx
+}
+
+// channelScript 返回渠道配置的脚本；未配置或全局关闭时返回 nil
+func channelScript(info *RelayInfo) (*scripthook.Program, error) {
+	if info == nil || info.ChannelMeta == nil || strings.TrimSpace(info.ChannelOtherSettings.TransformScript) == "" {
+		return nil, nil
+	}
+	if !operation_setting.GetChannelScriptSetting().Enabled {
+		return nil, nil
+	}
+	prog, err := scripthook.Compile(info.ChannelOtherSettings.TransformScript)
+	if err != nil {
+		return nil, fmt.Errorf("compile channel script failed: %w", err)
+	}
+	return prog, nil
+}
+
+// ApplyChannelScriptToRequest 用渠道脚本的 transform_request 改写转换后的上游请求体
+func ApplyChannelScriptToRequest(info *RelayInfo, jsonData []byte) ([]byte, error) {
+	prog, err := channelScript(info)
+	if err != nil || !prog.Has(scripthook.HookRequest) {
+		return jsonData, err
+	}
+	res, err := prog.Run(scripthook.HookRequest, jsonData, BuildChannelScriptContext(info), ChannelScriptLimits())
+	if err != nil {
+		return nil, fmt.Errorf("channel script %s failed: %w", scripthook.HookRequest, err)
+	}
+	return res.Output, nil
+}
+
+// ApplyChannelScriptToResponse 在适配器解析前改写上游响应：非流式响应整体交给 transform_response，
+// 流式响应的每个 SSE data 交给 transform_event。流式中途脚本出错时记录日志并透传原事件，避免已发送的响应被截断。
+func ApplyChannelScriptToResponse(info *RelayInfo, resp *http.Response) error {
+	if resp == nil || resp.Body == nil {
+		return nil
+	}
+	prog, err := channelScript(info)
+	if err != nil {
+		return err
+	}
+	isStream := info.IsStream || strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+	if isStream {
+		if !prog.Has(scripthook.HookEvent) {
+			return nil
+		}
+		ctx := BuildChannelScriptContext(info)
+		limits := ChannelScriptLimits()
+		resp.Body = newEventRewriteBody(resp.Body, func(data string) (string, bool) {
+			res, err := prog.Run(scripthook.HookEvent, []byte(data), ctx, limits)
+			if err != nil {
+				common.SysError(fmt.Sprintf("channel script %s failed, forwarding original event: %s", scripthook.HookEvent, err.Error()))
+				return data, false
+			}
+			return string(res.Output), res.Dropped
+		})
+		return nil
+	}
+	if !prog.Has(scripthook.HookResponse) {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("read response body failed: %w", err)
+	}
+	res, err := prog.Run(scripthook.HookResponse, body, BuildChannelScriptContext(info), ChannelScriptLimits())
+	if err != nil {
+		return fmt.Errorf("channel script %s failed: %w", scripthook.HookResponse, err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(res.Output))
+	resp.ContentLength = int64(len(res.Output))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(res.Output)))
+	return nil
+}
+
+// eventRewriteBody 逐行读取上游 SSE，对每个 data 行（[DONE] 除外）调用 rewrite；rewrite 返回 drop 时丢弃该行
+type eventRewriteBody struct {
+	source  io.ReadCloser
+	reader  *bufio.Reader
+	rewrite func(data string) (string, bool)
+	buf     bytes.Buffer
+	err     error
+}
+
+func newEventRewriteBody(source io.ReadCloser, rewrite func(data string) (string, bool)) *eventRewriteBody {
+	return &eventRewriteBody{
+		source:  source,
+		reader:  bufio.NewReaderSize(source, 64*1024),
+		rewrite: rewrite,
+	}
+}
+
+func (b *eventRewriteBody) Read(p []byte) (int, error) {
+	for b.buf.Len() == 0 && b.err == nil {
+		line, err := b.reader.ReadString('\n')
+		if len(line) > 0 {
+			b.buf.WriteString(b.rewriteLine(line))
+		}
+		b.err = err
+	}
+	if b.buf.Len() > 0 {
+		return b.buf.Read(p)
+	}
+	return 0, b.err
+}
+
+func (b *eventRewriteBody) Close() error {
+	return b.source.Close()
+}
+
+func (b *eventRewriteBody) rewriteLine(line string) string {
+	content := strings.TrimRight(line, "\r\n")
+	data, ok := strings.CutPrefix(content, "data:")
+	if !ok {
+		return line
+	}
+	data = strings.TrimPrefix(data, " ")
+	if data == "[DONE]" {
+		return line
+	}
+	out, drop := b.rewrite(data)
+	if drop {
+		return ""
+	}
+	return "data: " + strings.ReplaceAll(out, "\n", "") + line[len(content):]
+}
@@ -0,0 +1,87 @@
+package common
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/zhongruan0522/new-api/dto"
+)
+
+const testChannelScript = `
+def transform_request(body, ctx):
+    body["model"] = ctx["upstream_model"]
+
+def transform_response(body, ctx):
+    body["object"] = "chat.completion"
+
+def transform_event(data, ctx):
+    if type(data) == "string":
+        return ""
+    data["object"] = "chat.completion.chunk"
+`
+
+func newChannelScriptTestInfo(script string) *RelayInfo {
+	return &RelayInfo{
+		OriginModelName: "alias",
+		ChannelMeta: &ChannelMeta{
+			UpstreamModelName:    "upstream",
+			ChannelOtherSettings: dto.ChannelOtherSettings{TransformScript: script},
+		},
+	}
+}
+
+func TestApplyChannelScriptToRequest(t *testing.T) {
+	out, err := ApplyChannelScriptToRequest(newChannelScriptTestInfo(testChannelScript), []byte(`{"model":"alias"}`))
+	if err != nil {
+		t.Fatalf("ApplyChannelScriptToRequest error = %v", err)
+	}
+	if string(out) != `{"model":"upstream"}` {
+		t.Fatalf("request = %s", out)
+	}
+
+	body := []byte(`{"model":"alias"}`)
+	out, err = ApplyChannelScriptToRequest(newChannelScriptTestInfo(""), body)
+	if err != nil || string(out) != string(body) {
+		t.Fatalf("no script should pass through, got %s err=%v", out, err)
+	}
+
+	_, err = ApplyChannelScriptToRequest(newChannelScriptTestInfo("def transform_request(body, ctx):\n    fail('boom')\n"), body)
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected script failure, got %v", err)
+	}
+}
+
+func TestApplyChannelScriptToResponse(t *testing.T) {
+	resp := &http.Response{
+		Header: http.Header{"Content-Type": []string{"application/json"}},
+		Body:   io.NopCloser(strings.NewReader(`{"object":"quirky"}`)),
+	}
+	if err := ApplyChannelScriptToResponse(newChannelScriptTestInfo(testChannelScript), resp); err != nil {
+		t.Fatalf("ApplyChannelScriptToResponse error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != `{"object":"chat.completion"}` || resp.ContentLength != int64(len(body)) {
+		t.Fatalf("response = %s (content-length %d)", body, resp.ContentLength)
+	}
+}
+
+func TestApplyChannelScriptToStreamResponse(t *testing.T) {
+	upstream := "data: {\"object\":\"quirky\"}\n\n: keepalive\n\ndata: PING\n\ndata: [DONE]\n\n"
+	resp := &http.Response{
+		Header: http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:   io.NopCloser(strings.NewReader(upstream)),
+	}
+	if err := ApplyChannelScriptToResponse(newChannelScriptTestInfo(testChannelScript), resp); err != nil {
+		t.Fatalf("ApplyChannelScriptToResponse error = %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read stream error = %v", err)
+	}
+	want := "data: {\"object\":\"chat.completion.chunk\"}\n\n: keepalive\n\n\ndata: [DONE]\n\n"
+	if string(body) != want {
+		t.Fatalf("stream = %q, want %q", body, want)
+	}
+}
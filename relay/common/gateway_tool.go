@@ -0,0 +1,70 @@
+package common
+
+import "github.com/zhongruan0522/new-api/dto"
+
+// GatewayToolCallTrace 单次网关工具调用记录，写入消费日志 other.gateway_tool_trace
+type GatewayToolCallTrace struct {
+	Turn       int     `json:"turn"`
+	CallId     string  `json:"call_id"`
+	Name       string  `json:"name"`
+	Arguments  string  `json:"arguments"`
+	Output     string  `json:"output"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs int64   `json:"duration_ms"`
+	Price      float64 `json:"price"` // 单次调用价格（美元），来自 ToolBillingRule
+}
+
+// GatewayToolSession 记录 model→tool→model 循环的轮数与工具调用，各轮上游用量累加在 BillingSession 中
+type GatewayToolSession struct {
+	// Collecting 为 true 时 TextHelper 不结算，只累加本轮用量
+	Collecting bool
+	Turns      int
+	Calls      []GatewayToolCallTrace
+	// unbilledUsage 没有计费会话（免费模型）时累加的用量
+	unbilledUsage dto.Usage
+}
+
+// AddUsage 将一轮上游用量累加到计费会话；免费模型没有计费会话时记在工具会话上
+func (s *GatewayToolSession) AddUsage(billing BillingSettler, usage *dto.Usage) {
+	s.Turns++
+	if billing != nil {
+		billing.AddUsage(usage)
+		return
+	}
+	AccumulateUsage(&s.unbilledUsage, usage)
+}
+
+// Usage 返回各轮累加的上游用量
+func (s *GatewayToolSession) Usage(billing BillingSettler) dto.Usage {
+	if billing != nil {
+		return billing.GetUsage()
+	}
+	return s.unbilledUsage
+}
+
+// AccumulateUsage 将一轮上游用量累加到 dst，供需要多轮请求后统一结算的流程使用
+func AccumulateUsage(dst *dto.Usage, usage *dto.Usage) {
+	if usage == nil {
+		return
+	}
+	dst.PromptTokens += usage.PromptTokens
+	dst.CompletionTokens += usage.CompletionTokens
+	dst.TotalTokens += usage.TotalTokens
+	dst.PromptTokensDetails.CachedTokens += usage.PromptTokensDetails.CachedTokens
+	dst.PromptTokensDetails.CachedCreationTokens += usage.PromptTokensDetails.CachedCreationTokens
+	dst.PromptTokensDetails.AudioTokens += usage.PromptTokensDetails.AudioTokens
+	dst.PromptTokensDetails.TextTokens += usage.PromptTokensDetails.TextTokens
+	dst.PromptTokensDetails.ImageTokens += usage.PromptTokensDetails.ImageTokens
+	dst.CompletionTokenDetails.ReasoningTokens += usage.CompletionTokenDetails.ReasoningTokens
+	dst.CompletionTokenDetails.AudioTokens += usage.CompletionTokenDetails.AudioTokens
+	dst.CompletionTokenDetails.TextTokens += usage.CompletionTokenDetails.TextTokens
+}
+
+// TotalPrice 返回所有工具调用的价格之和（美元）
+func (s *GatewayToolSession) TotalPrice() float64 {
+	total := 0.0
+	for _, call := range s.Calls {
+		total += call.Price
+	}
+	return total
+}
@@ -79,14 +79,27 @@ func buildResponsesInputItemsFromChatMessage(msg dto.Message, toolOutputItemType
 	}
 
 	items := make([]map[string]any, 0, 1+len(toolCalls))
-	if reasoning := normalizeChatMessageReasoning(msg); reasoning != "" {
-		items = append(items, map[string]any{
-			"type": openAIResponsesInputItemTypeReasoning,
-			"summary": []map[string]any{{
+	reasoning := normalizeChatMessageReasoning(msg)
+	signature := ""
+	if IsResponsesEncryptedReasoning(msg.ReasoningSignature) {
+		signature = msg.ReasoningSignature
+	}
+	if reasoning != "" || signature != "" {
+		summary := make([]map[string]any, 0, 1)
+		if reasoning != "" {
+			summary = append(summary, map[string]any{
 				"type": openAIResponsesSummaryTextType,
 				"text": reasoning,
-			}},
-		})
+			})
+		}
+		item := map[string]any{
+			"type":    openAIResponsesInputItemTypeReasoning,
+			"summary": summary,
+		}
+		if signature != "" {
+			item["encrypted_content"] = signature
+		}
+		items = append(items, item)
 	}
 	item, ok, err := buildResponsesMessageItemFromChatMessage(role, msg, len(toolCalls) > 0)
 	if err != nil {
@@ -231,6 +244,13 @@ func normalizeChatMessageReasoning(msg dto.Message) string {
 	return strings.TrimSpace(msg.Reasoning)
 }
 
+// IsResponsesEncryptedReasoning reports whether a reasoning signature is an
+// OpenAI encrypted_content blob (a Fernet token), as opposed to e.g. a Claude
+// thinking signature, which the Responses API would reject.
+func IsResponsesEncryptedReasoning(signature string) bool {
+	return strings.HasPrefix(signature, "gAAAAA")
+}
+
 func responsesTextPartTypeForRole(role string) string {
 	if role == "assistant" {
 		return openAIResponsesOutputTypeText
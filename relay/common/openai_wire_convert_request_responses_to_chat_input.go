@@ -30,8 +30,9 @@ type responsesFunctionCallOutputInput struct {
 }
 
 type responsesReasoningInput struct {
-	Type    string                     `json:"type"`
-	Summary []dto.ResponsesContentPart `json:"summary,omitempty"`
+	Type             string                     `json:"type"`
+	Summary          []dto.ResponsesContentPart `json:"summary,omitempty"`
+	EncryptedContent string                     `json:"encrypted_content,omitempty"`
 }
 
 func buildChatMessagesFromResponsesInput(raw json.RawMessage) ([]dto.Message, error) {
@@ -64,6 +65,7 @@ func buildChatMessagesFromResponsesInputArray(raw json.RawMessage) ([]dto.Messag
 
 	out := make([]dto.Message, 0, len(items))
 	pendingReasoning := ""
+	pendingSignature := ""
 	pendingToolCalls := make([]dto.ToolCallResponse, 0)
 	flushToolCalls := func() error {
 		if len(pendingToolCalls) == 0 {
@@ -73,7 +75,11 @@ func buildChatMessagesFromResponsesInputArray(raw json.RawMessage) ([]dto.Messag
 			if err := appendToolCallsToChatAssistantMessage(&out[len(out)-1], pendingReasoning, pendingToolCalls); err != nil {
 				return err
 			}
+			if pendingSignature != "" {
+				out[len(out)-1].ReasoningSignature = pendingSignature
+			}
 			pendingReasoning = ""
+			pendingSignature = ""
 			pendingToolCalls = pendingToolCalls[:0]
 			return nil
 		}
@@ -81,8 +87,10 @@ func buildChatMessagesFromResponsesInputArray(raw json.RawMessage) ([]dto.Messag
 		if err != nil {
 			return err
 		}
+		msg.ReasoningSignature = pendingSignature
 		out = append(out, msg)
 		pendingReasoning = ""
+		pendingSignature = ""
 		pendingToolCalls = pendingToolCalls[:0]
 		return nil
 	}
@@ -95,13 +103,16 @@ func buildChatMessagesFromResponsesInputArray(raw json.RawMessage) ([]dto.Messag
 			itemType = openAIResponsesInputItemTypeMessage
 		}
 		if itemType == openAIResponsesInputItemTypeReasoning {
-			reasoning, err := extractResponsesReasoningSummary(itemRaw)
+			reasoning, signature, err := extractResponsesReasoningSummary(itemRaw)
 			if err != nil {
 				return nil, fmt.Errorf("input[%d]: %w", i, err)
 			}
 			if strings.TrimSpace(reasoning) != "" {
 				pendingReasoning = appendReasoningSummary(pendingReasoning, reasoning)
 			}
+			if signature != "" {
+				pendingSignature = signature
+			}
 			continue
 		}
 		if itemType == openAIResponsesInputItemTypeFunctionCall ||
@@ -122,17 +133,18 @@ func buildChatMessagesFromResponsesInputArray(raw json.RawMessage) ([]dto.Messag
 		if err != nil {
 			return nil, fmt.Errorf("input[%d]: %w", i, err)
 		}
-		if strings.TrimSpace(pendingReasoning) != "" {
-			attachReasoningToMessages(&msgs, pendingReasoning)
+		if strings.TrimSpace(pendingReasoning) != "" || pendingSignature != "" {
+			attachReasoningToMessages(&msgs, pendingReasoning, pendingSignature)
 			pendingReasoning = ""
+			pendingSignature = ""
 		}
 		out = append(out, msgs...)
 	}
 	if err := flushToolCalls(); err != nil {
 		return nil, err
 	}
-	if strings.TrimSpace(pendingReasoning) != "" {
-		out = append(out, dto.Message{Role: "assistant", ReasoningContent: pendingReasoning})
+	if strings.TrimSpace(pendingReasoning) != "" || pendingSignature != "" {
+		out = append(out, dto.Message{Role: "assistant", ReasoningContent: pendingReasoning, ReasoningSignature: pendingSignature})
 	}
 	return out, nil
 }
@@ -142,14 +154,14 @@ func buildChatMessagesFromResponsesInputItemByType(itemType string, raw json.Raw
 	case openAIResponsesInputItemTypeMessage:
 		return buildChatMessagesFromResponsesMessageItem(raw)
 	case openAIResponsesInputItemTypeReasoning:
-		reasoning, err := extractResponsesReasoningSummary(raw)
+		reasoning, signature, err := extractResponsesReasoningSummary(raw)
 		if err != nil {
 			return nil, err
 		}
-		if strings.TrimSpace(reasoning) == "" {
+		if strings.TrimSpace(reasoning) == "" && signature == "" {
 			return nil, nil
 		}
-		return []dto.Message{{Role: "assistant", ReasoningContent: reasoning}}, nil
+		return []dto.Message{{Role: "assistant", ReasoningContent: reasoning, ReasoningSignature: signature}}, nil
 	case openAIResponsesInputItemTypeFunctionCall, openAIResponsesInputItemTypeCustomToolCall, openAIResponsesInputItemTypeToolSearchCall:
 		msg, err := buildChatToolCallMessageFromResponsesFunctionCall(raw)
 		if err != nil {
@@ -449,10 +461,12 @@ func appendReasoningSummary(existing string, next string) string {
 	return existing + "\n" + next
 }
 
-func extractResponsesReasoningSummary(raw json.RawMessage) (string, error) {
+// extractResponsesReasoningSummary returns the summary text and the encrypted_content,
+// which maps to reasoning_signature on Chat messages.
+func extractResponsesReasoningSummary(raw json.RawMessage) (string, string, error) {
 	var item responsesReasoningInput
 	if err := common.Unmarshal(raw, &item); err != nil {
-		return "", fmt.Errorf("unmarshal reasoning item failed: %w", err)
+		return "", "", fmt.Errorf("unmarshal reasoning item failed: %w", err)
 	}
 	parts := make([]string, 0, len(item.Summary))
 	for _, summary := range item.Summary {
@@ -460,20 +474,21 @@ func extractResponsesReasoningSummary(raw json.RawMessage) (string, error) {
 			parts = append(parts, summary.Text)
 		}
 	}
-	return strings.Join(parts, "\n"), nil
+	return strings.Join(parts, "\n"), strings.TrimSpace(item.EncryptedContent), nil
 }
 
-func attachReasoningToMessages(msgs *[]dto.Message, reasoning string) {
+func attachReasoningToMessages(msgs *[]dto.Message, reasoning string, signature string) {
 	if msgs == nil || len(*msgs) == 0 {
 		return
 	}
 	for i := range *msgs {
 		if strings.EqualFold((*msgs)[i].Role, "assistant") {
 			(*msgs)[i].ReasoningContent = reasoning
+			(*msgs)[i].ReasoningSignature = signature
 			return
 		}
 	}
-	*msgs = append([]dto.Message{{Role: "assistant", ReasoningContent: reasoning}}, (*msgs)...)
+	*msgs = append([]dto.Message{{Role: "assistant", ReasoningContent: reasoning, ReasoningSignature: signature}}, (*msgs)...)
 }
 
 func extractResponsesFile(part map[string]any) *dto.MessageFile {
@@ -279,3 +279,53 @@ func TestConvertChatCompletionsRequestToResponsesRequest_CustomToolHistory(t *te
 		t.Fatalf("custom output item = %#v, want custom_tool_call_output", items[2])
 	}
 }
+
+// Test encrypted reasoning round trips because stateless multi-turn requests
+// fail upstream when the reasoning signature of a prior turn is dropped.
+func TestResponsesReasoningEncryptedContentRoundTrip(t *testing.T) {
+	inputRaw, err := common.Marshal([]map[string]any{
+		{"type": "message", "role": "user", "content": "Weather?"},
+		{"type": "reasoning", "summary": []any{}, "encrypted_content": "gAAAAABsig"},
+		{"type": "function_call", "call_id": "call_1", "name": "get_weather", "arguments": `{"city":"Paris"}`},
+		{"type": "function_call_output", "call_id": "call_1", "output": "18C"},
+	})
+	if err != nil {
+		t.Fatalf("marshal input error = %v", err)
+	}
+
+	chatReq, err := ConvertResponsesRequestToChatCompletionsRequest(&dto.OpenAIResponsesRequest{Model: "gpt-5", Input: inputRaw})
+	if err != nil {
+		t.Fatalf("ConvertResponsesRequestToChatCompletionsRequest() error = %v", err)
+	}
+	if len(chatReq.Messages) != 3 || chatReq.Messages[1].ReasoningSignature != "gAAAAABsig" {
+		t.Fatalf("messages = %+v, want signature on the assistant tool call message", chatReq.Messages)
+	}
+
+	back, err := ConvertChatCompletionsRequestToResponsesRequest(chatReq)
+	if err != nil {
+		t.Fatalf("ConvertChatCompletionsRequestToResponsesRequest() error = %v", err)
+	}
+	var items []map[string]any
+	if err := common.Unmarshal(back.Input, &items); err != nil {
+		t.Fatalf("unmarshal input error = %v", err)
+	}
+	if len(items) < 2 || items[1]["type"] != "reasoning" || items[1]["encrypted_content"] != "gAAAAABsig" {
+		t.Fatalf("input items = %+v, want reasoning item with encrypted_content", items)
+	}
+
+	// Claude thinking signatures must not be sent upstream as encrypted_content.
+	chatReq.Messages[1].ReasoningSignature = "EqQBCkYIBxgCKkB"
+	back, err = ConvertChatCompletionsRequestToResponsesRequest(chatReq)
+	if err != nil {
+		t.Fatalf("ConvertChatCompletionsRequestToResponsesRequest() error = %v", err)
+	}
+	items = nil
+	if err := common.Unmarshal(back.Input, &items); err != nil {
+		t.Fatalf("unmarshal input error = %v", err)
+	}
+	for _, item := range items {
+		if item["type"] == "reasoning" {
+			t.Fatalf("input items = %+v, foreign signature must not produce a reasoning item", items)
+		}
+	}
+}
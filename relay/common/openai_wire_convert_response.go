@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/zhongruan0522/new-api/common"
 	"github.com/zhongruan0522/new-api/dto"
@@ -25,7 +26,7 @@ func ConvertResponsesResponseToChatCompletionResponse(responsesResp *dto.OpenAIR
 		return nil, fmt.Errorf("responses response is nil")
 	}
 
-	content, reasoning, toolCalls, err := extractChatMessageFromResponsesOutput(responsesResp.Output)
+	content, reasoning, signature, toolCalls, err := extractChatMessageFromResponsesOutput(responsesResp.Output)
 	if err != nil {
 		return nil, err
 	}
@@ -35,6 +36,8 @@ func ConvertResponsesResponseToChatCompletionResponse(responsesResp *dto.OpenAIR
 	if err != nil {
 		return nil, err
 	}
+	assistantMsg.ReasoningSignature = signature
+	assistantMsg.Annotations = extractChatAnnotationsFromResponsesOutput(responsesResp.Output)
 
 	out := &dto.OpenAITextResponse{
 		Id:      responsesResp.ID,
@@ -93,7 +96,8 @@ func getSingleChatChoice(choices []dto.OpenAITextResponseChoice) (dto.OpenAIText
 
 // extractChatMessageFromResponsesOutput merges Responses output items into a
 // single Chat assistant message while preserving reasoning and tool calls.
-func extractChatMessageFromResponsesOutput(output []dto.ResponsesOutput) (content string, reasoning string, toolCalls []dto.ToolCallResponse, err error) {
+// The encrypted_content of the last reasoning item is returned as the reasoning signature.
+func extractChatMessageFromResponsesOutput(output []dto.ResponsesOutput) (content string, reasoning string, signature string, toolCalls []dto.ToolCallResponse, err error) {
 	var builder strings.Builder
 	var reasoningBuilder strings.Builder
 	var calls []dto.ToolCallResponse
@@ -101,6 +105,9 @@ func extractChatMessageFromResponsesOutput(output []dto.ResponsesOutput) (conten
 		itemType := strings.TrimSpace(item.Type)
 		switch itemType {
 		case openAIResponsesOutputTypeReasoning:
+			if encrypted := strings.TrimSpace(item.EncryptedContent); encrypted != "" {
+				signature = encrypted
+			}
 			for _, part := range item.Summary {
 				if strings.TrimSpace(part.Text) == "" {
 					continue
@@ -113,7 +120,7 @@ func extractChatMessageFromResponsesOutput(output []dto.ResponsesOutput) (conten
 		case openAIResponsesOutputTypeMessage:
 			for _, part := range item.Content {
 				if strings.TrimSpace(part.Type) != openAIResponsesOutputContentTypeText {
-					return "", "", nil, fmt.Errorf("unsupported responses message content type: %q", part.Type)
+					return "", "", "", nil, fmt.Errorf("unsupported responses message content type: %q", part.Type)
 				}
 				builder.WriteString(part.Text)
 			}
@@ -128,7 +135,7 @@ func extractChatMessageFromResponsesOutput(output []dto.ResponsesOutput) (conten
 			if itemType == openAIResponsesOutputTypeCustomToolCall {
 				custom, marshalErr := common.Marshal(map[string]any{"name": item.Name, "input": item.Input})
 				if marshalErr != nil {
-					return "", "", nil, fmt.Errorf("marshal custom tool call failed: %w", marshalErr)
+					return "", "", "", nil, fmt.Errorf("marshal custom tool call failed: %w", marshalErr)
 				}
 				calls = append(calls, dto.ToolCallResponse{ID: callID, Type: dto.CustomType, Custom: custom})
 				continue
@@ -141,7 +148,7 @@ func extractChatMessageFromResponsesOutput(output []dto.ResponsesOutput) (conten
 			}
 			arguments, argErr := ResponsesArgumentsToChatString(item.Arguments)
 			if argErr != nil {
-				return "", "", nil, fmt.Errorf("marshal %s.arguments failed: %w", itemType, argErr)
+				return "", "", "", nil, fmt.Errorf("marshal %s.arguments failed: %w", itemType, argErr)
 			}
 			calls = append(calls, dto.ToolCallResponse{
 				ID:   callID,
@@ -152,10 +159,71 @@ func extractChatMessageFromResponsesOutput(output []dto.ResponsesOutput) (conten
 				},
 			})
 		default:
-			return "", "", nil, fmt.Errorf("unsupported responses output item type: %q", itemType)
+			return "", "", "", nil, fmt.Errorf("unsupported responses output item type: %q", itemType)
+		}
+	}
+	return builder.String(), reasoningBuilder.String(), signature, calls, nil
+}
+
+// extractChatAnnotationsFromResponsesOutput converts output_text url_citation
+// annotations, whose indices are relative to their part, into annotations on the
+// merged chat content built by extractChatMessageFromResponsesOutput.
+func extractChatAnnotationsFromResponsesOutput(output []dto.ResponsesOutput) []dto.ChatAnnotation {
+	var annotations []dto.ChatAnnotation
+	offset := 0
+	for _, item := range output {
+		if strings.TrimSpace(item.Type) != openAIResponsesOutputTypeMessage {
+			continue
 		}
+		for _, part := range item.Content {
+			for _, raw := range part.Annotations {
+				citation, ok := parseResponsesURLCitation(raw)
+				if !ok {
+					continue
+				}
+				annotations = append(annotations, dto.ChatAnnotation{
+					Type: dto.AnnotationTypeURLCitation,
+					URLCitation: &dto.ChatURLCitation{
+						StartIndex: offset + citation.StartIndex,
+						EndIndex:   offset + citation.EndIndex,
+						URL:        citation.URL,
+						Title:      citation.Title,
+					},
+				})
+			}
+			offset += utf8.RuneCountInString(part.Text)
+		}
+	}
+	return annotations
+}
+
+func parseResponsesURLCitation(raw any) (dto.ResponsesURLCitation, bool) {
+	var citation dto.ResponsesURLCitation
+	data, err := common.Marshal(raw)
+	if err != nil || common.Unmarshal(data, &citation) != nil {
+		return citation, false
 	}
-	return builder.String(), reasoningBuilder.String(), calls, nil
+	return citation, citation.Type == dto.AnnotationTypeURLCitation && citation.URL != ""
+}
+
+// buildResponsesAnnotationsFromChat converts chat url_citation annotations into
+// output_text annotations. The chat content becomes a single part, so indices carry over.
+func buildResponsesAnnotationsFromChat(annotations []dto.ChatAnnotation) []interface{} {
+	var out []interface{}
+	for _, annotation := range annotations {
+		citation := annotation.URLCitation
+		if annotation.Type != dto.AnnotationTypeURLCitation || citation == nil || citation.URL == "" {
+			continue
+		}
+		out = append(out, dto.ResponsesURLCitation{
+			Type:       dto.AnnotationTypeURLCitation,
+			StartIndex: citation.StartIndex,
+			EndIndex:   citation.EndIndex,
+			URL:        citation.URL,
+			Title:      citation.Title,
+		})
+	}
+	return out
 }
 
 func mapResponsesStatusToChatFinishReason(status string, sawToolCalls bool) string {
@@ -222,16 +290,20 @@ func extractChatMessageTextOnly(msg dto.Message) (string, error) {
 
 func buildResponsesOutputFromChat(msg dto.Message, text string, rawToolCalls json.RawMessage, toolContext *OpenAIWireToolContext) ([]dto.ResponsesOutput, error) {
 	output := make([]dto.ResponsesOutput, 0, 2)
-	if reasoning := normalizeChatResponseReasoning(msg); reasoning != "" {
-		output = append(output, dto.ResponsesOutput{
-			Type:   openAIResponsesOutputTypeReasoning,
-			ID:     "rs_0",
-			Status: "completed",
-			Summary: []dto.ResponsesContentPart{{
+	if reasoning := normalizeChatResponseReasoning(msg); reasoning != "" || msg.ReasoningSignature != "" {
+		item := dto.ResponsesOutput{
+			Type:             openAIResponsesOutputTypeReasoning,
+			ID:               "rs_0",
+			Status:           "completed",
+			EncryptedContent: msg.ReasoningSignature,
+		}
+		if reasoning != "" {
+			item.Summary = append(item.Summary, dto.ResponsesContentPart{
 				Type: openAIResponsesSummaryTextType,
 				Text: reasoning,
-			}},
-		})
+			})
+		}
+		output = append(output, item)
 	}
 	if strings.TrimSpace(text) != "" {
 		output = append(output, dto.ResponsesOutput{
@@ -240,7 +312,7 @@ func buildResponsesOutputFromChat(msg dto.Message, text string, rawToolCalls jso
 			Status: "completed",
 			Role:   "assistant",
 			Content: []dto.ResponsesOutputContent{
-				{Type: openAIResponsesOutputContentTypeText, Text: text},
+				{Type: openAIResponsesOutputContentTypeText, Text: text, Annotations: buildResponsesAnnotationsFromChat(msg.Annotations)},
 			},
 		})
 	}
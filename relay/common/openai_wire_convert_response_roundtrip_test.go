@@ -322,3 +322,31 @@ func TestResponsesCustomToolProxyRoundTrip_GenericCustomName(t *testing.T) {
 		t.Fatalf("custom output arguments = %#v, want nil", got.Output[0].Arguments)
 	}
 }
+
+// Test signature mapping because Claude thinking signatures have to survive a
+// Chat -> Responses -> Chat trip for clients that replay prior turns.
+func TestResponsesReasoningSignatureMapsToEncryptedContent(t *testing.T) {
+	chatResp := &dto.OpenAITextResponse{
+		Id:     "chatcmpl_1",
+		Object: "chat.completion",
+		Choices: []dto.OpenAITextResponseChoice{{
+			Message:      dto.Message{Role: "assistant", Content: "Done", ReasoningContent: "Plan", ReasoningSignature: "EqQBCkYIBxgCKkB"},
+			FinishReason: "stop",
+		}},
+	}
+	responsesResp, err := ConvertChatCompletionResponseToResponsesResponse(chatResp)
+	if err != nil {
+		t.Fatalf("ConvertChatCompletionResponseToResponsesResponse() error = %v", err)
+	}
+	if responsesResp.Output[0].Type != "reasoning" || responsesResp.Output[0].EncryptedContent != "EqQBCkYIBxgCKkB" {
+		t.Fatalf("output[0] = %+v, want reasoning item with encrypted_content", responsesResp.Output[0])
+	}
+
+	got, err := ConvertResponsesResponseToChatCompletionResponse(responsesResp)
+	if err != nil {
+		t.Fatalf("ConvertResponsesResponseToChatCompletionResponse() error = %v", err)
+	}
+	if msg := got.Choices[0].Message; msg.ReasoningSignature != "EqQBCkYIBxgCKkB" || msg.ReasoningContent != "Plan" {
+		t.Fatalf("message = %+v, want reasoning and signature preserved", msg)
+	}
+}
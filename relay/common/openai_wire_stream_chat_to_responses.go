@@ -24,6 +24,7 @@ type chatToResponsesStreamConverter struct {
 	reasoningDone      bool
 	finishReason       string
 	reasoningBuilder   strings.Builder
+	reasoningSignature string
 	textBuilder        strings.Builder
 	toolCallsByID      map[string]*chatToResponsesToolCallState
 	toolCallIDByIndex  map[int]string
@@ -160,6 +161,9 @@ func (c *chatToResponsesStreamConverter) convertChoice(choice dto.ChatCompletion
 	c.captureFinishReason(choice)
 
 	var out strings.Builder
+	if choice.Delta.ReasoningSignature != nil && *choice.Delta.ReasoningSignature != "" {
+		c.reasoningSignature = *choice.Delta.ReasoningSignature
+	}
 	if delta := strings.TrimSpace(choice.Delta.GetReasoningContent()); delta != "" {
 		frame, err := c.emitReasoningDelta(delta)
 		if err != nil {
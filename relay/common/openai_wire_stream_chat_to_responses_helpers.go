@@ -39,6 +39,7 @@ func (c *chatToResponsesStreamConverter) buildOutput() ([]dto.ResponsesOutput, e
 				Type: "summary_text",
 				Text: reasoning,
 			}},
+			EncryptedContent: c.reasoningSignature,
 		})
 	}
 	text := strings.TrimSpace(c.textBuilder.String())
@@ -328,6 +329,7 @@ func (c *chatToResponsesStreamConverter) emitReasoningDoneIfAny() (string, error
 				Type: "summary_text",
 				Text: reasoning,
 			}},
+			EncryptedContent: c.reasoningSignature,
 		},
 		ItemID: chatToResponsesReasoningItemID,
 	})
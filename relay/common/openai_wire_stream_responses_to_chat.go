@@ -100,6 +100,9 @@ func (c *responsesToChatStreamConverter) ConvertFrame(event string, data string,
 	case "response.custom_tool_call_input.done":
 		return c.emitToolCallDone(stream)
 	case "response.output_item.done":
+		if stream.Item != nil && strings.TrimSpace(stream.Item.Type) == "reasoning" {
+			return c.emitReasoningSignature(stream.Item.EncryptedContent)
+		}
 		c.captureToolCallMeta(stream)
 		return c.emitToolCallAdded(stream)
 	case "response.incomplete":
@@ -231,6 +234,27 @@ func (c *responsesToChatStreamConverter) emitReasoningDelta(delta string) (strin
 	return encodeChatSSEChunk(chunk)
 }
 
+// emitReasoningSignature forwards the encrypted_content of a finished reasoning
+// item as reasoning_signature so multi-turn clients can send it back.
+func (c *responsesToChatStreamConverter) emitReasoningSignature(signature string) (string, error) {
+	signature = strings.TrimSpace(signature)
+	if signature == "" {
+		return "", nil
+	}
+
+	chunk := c.newChatChunk()
+	choice := dto.ChatCompletionsStreamResponseChoice{
+		Index: 0,
+		Delta: dto.ChatCompletionsStreamResponseChoiceDelta{ReasoningSignature: &signature},
+	}
+	if !c.sentRole {
+		choice.Delta.Role = "assistant"
+		c.sentRole = true
+	}
+	chunk.Choices = []dto.ChatCompletionsStreamResponseChoice{choice}
+	return encodeChatSSEChunk(chunk)
+}
+
 func (c *responsesToChatStreamConverter) emitToolCallAdded(stream dto.ResponsesStreamResponse) (string, error) {
 	callID, name, ok := c.getToolCallMeta(stream)
 	if !ok {
@@ -276,7 +300,7 @@ func (c *responsesToChatStreamConverter) emitToolCallAddedByID(callID string, na
 	var builder strings.Builder
 	builder.WriteString(frame)
 	if buffered := c.toolCallBufferedArgsByID[callID]; buffered != "" {
-		deltaFrame, err := c.emitStartedToolCallArguments(callID, idx, name, buffered)
+		deltaFrame, err := c.emitStartedToolCallArguments(callID, idx, buffered)
 		if err != nil {
 			return "", err
 		}
@@ -330,7 +354,7 @@ func (c *responsesToChatStreamConverter) emitToolCallDelta(stream dto.ResponsesS
 	}
 
 	idx := c.getToolCallIndex(callID)
-	frame, err := c.emitStartedToolCallArguments(callID, idx, name, delta)
+	frame, err := c.emitStartedToolCallArguments(callID, idx, delta)
 	if err != nil {
 		return "", err
 	}
@@ -381,7 +405,7 @@ func (c *responsesToChatStreamConverter) emitToolCallDone(stream dto.ResponsesSt
 	}
 	c.toolCallArgsByID[callID] += remaining
 	idx := c.getToolCallIndex(callID)
-	return c.emitStartedToolCallArguments(callID, idx, name, remaining)
+	return c.emitStartedToolCallArguments(callID, idx, remaining)
 }
 
 func (c *responsesToChatStreamConverter) emitFinal() (string, error) {
@@ -517,7 +541,9 @@ func (c *responsesToChatStreamConverter) rekeyToolCallState(from string, to stri
 	delete(c.toolCallTypeByID, from)
 }
 
-func (c *responsesToChatStreamConverter) emitStartedToolCallArguments(callID string, idx int, name string, delta string) (string, error) {
+// emitStartedToolCallArguments sends only the argument delta; the name already
+// went out with the first tool call chunk, matching OpenAI's stream format.
+func (c *responsesToChatStreamConverter) emitStartedToolCallArguments(callID string, idx int, delta string) (string, error) {
 	if delta == "" {
 		return "", nil
 	}
@@ -533,7 +559,6 @@ func (c *responsesToChatStreamConverter) emitStartedToolCallArguments(callID str
 	toolCall := dto.ToolCallResponse{
 		Index: common.GetPointer(idx),
 		Function: dto.FunctionResponse{
-			Name:      name,
 			Arguments: delta,
 		},
 	}
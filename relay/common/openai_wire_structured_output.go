@@ -0,0 +1,243 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/pkg/jsonschema"
+)
+
+const defaultStructuredOutputToolName = "structured_output"
+
+var structuredOutputToolNameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// StructuredOutputSpec 客户端通过 response_format 要求的结构化输出
+type StructuredOutputSpec struct {
+	Name        string
+	Description string
+	// Schema 为空表示 json_object，只要求返回 JSON 对象
+	Schema json.RawMessage
+
+	decoded any
+}
+
+// StructuredOutputSession 记录结构化输出模拟的多轮请求用量与校验结果
+type StructuredOutputSession struct {
+	// Collecting 为 true 时 TextHelper 不结算，只把本轮用量累加到 Usage
+	Collecting bool
+	Mode       dto.StructuredOutputEmulation
+	Turns      int
+	Usage      dto.Usage
+	// ValidationError 最终结果仍未通过 schema 校验时的错误信息
+	ValidationError string
+}
+
+// AddUsage 累加一轮上游返回的用量
+func (s *StructuredOutputSession) AddUsage(usage *dto.Usage) {
+	s.Turns++
+	AccumulateUsage(&s.Usage, usage)
+}
+
+// ParseStructuredOutputSpec 解析 response_format；非 json_schema / json_object 时返回 nil
+func ParseStructuredOutputSpec(format *dto.ResponseFormat) (*StructuredOutputSpec, error) {
+	if format == nil {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(format.Type)) {
+	case "json_object":
+		spec := &StructuredOutputSpec{Name: defaultStructuredOutputToolName}
+		spec.decoded = map[string]any{"type": "object"}
+		return spec, nil
+	case "json_schema":
+	default:
+		return nil, nil
+	}
+
+	if len(format.JsonSchema) == 0 {
+		return nil, errors.New("response_format.json_schema is required")
+	}
+	var jsonSchema struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Schema      json.RawMessage `json:"schema"`
+	}
+	if err := common.Unmarshal(format.JsonSchema, &jsonSchema); err != nil {
+		return nil, fmt.Errorf("invalid response_format.json_schema: %w", err)
+	}
+	spec := &StructuredOutputSpec{
+		Name:        sanitizeStructuredOutputToolName(jsonSchema.Name),
+		Description: jsonSchema.Description,
+		Schema:      jsonSchema.Schema,
+	}
+	if len(spec.Schema) == 0 || common.GetJsonType(spec.Schema) != "object" {
+		return nil, errors.New("response_format.json_schema.schema must be an object")
+	}
+	if err := common.Unmarshal(spec.Schema, &spec.decoded); err != nil {
+		return nil, fmt.Errorf("invalid response_format.json_schema.schema: %w", err)
+	}
+	return spec, nil
+}
+
+// IsJSONObject 是否为 json_object 模式（无 schema）
+func (spec *StructuredOutputSpec) IsJSONObject() bool {
+	return len(spec.Schema) == 0
+}
+
+// Validate 校验模型输出的 JSON 文本是否满足 schema
+func (spec *StructuredOutputSpec) Validate(output string) error {
+	var value any
+	if err := common.UnmarshalJsonStr(output, &value); err != nil {
+		return fmt.Errorf("output is not valid JSON: %v", err)
+	}
+	return jsonschema.Validate(spec.decoded, value)
+}
+
+// ApplyStructuredOutputEmulation 去掉 response_format，并按模式把 schema 转成强制工具调用或系统指令。
+// 客户端自带 tools 或仅要求 json_object 时工具模式无法使用，回退为系统指令模式；返回实际生效的模式。
+func ApplyStructuredOutputEmulation(request *dto.GeneralOpenAIRequest, spec *StructuredOutputSpec, mode dto.StructuredOutputEmulation) dto.StructuredOutputEmulation {
+	if mode == dto.StructuredOutputEmulationTool && (len(request.Tools) > 0 || spec.IsJSONObject()) {
+		mode = dto.StructuredOutputEmulationPrompt
+	}
+	request.ResponseFormat = nil
+
+	if mode == dto.StructuredOutputEmulationTool {
+		description := spec.Description
+		if description == "" {
+			description = "Return the final answer by calling this function. The arguments are the answer."
+		}
+		request.Tools = []dto.ToolCallRequest{{
+			Type: "function",
+			Function: dto.FunctionRequest{
+				Name:        spec.Name,
+				Description: description,
+				Parameters:  spec.Schema,
+			},
+		}}
+		request.ToolChoice = map[string]any{
+			"type":     "function",
+			"function": map[string]any{"name": spec.Name},
+		}
+		return mode
+	}
+
+	prependSystemInstruction(request, structuredOutputInstruction(spec))
+	return mode
+}
+
+func structuredOutputInstruction(spec *StructuredOutputSpec) string {
+	if spec.IsJSONObject() {
+		return "Respond with a single valid JSON object only. Do not wrap it in markdown code fences and do not add any text before or after it."
+	}
+	var b strings.Builder
+	b.WriteString("Respond with a single valid JSON value that conforms to the following JSON Schema. ")
+	b.WriteString("Do not wrap it in markdown code fences and do not add any text before or after it.")
+	if spec.Description != "" {
+		b.WriteString("\nDescription: ")
+		b.WriteString(spec.Description)
+	}
+	b.WriteString("\nJSON Schema:\n")
+	b.Write(spec.Schema)
+	return b.String()
+}
+
+// prependSystemInstruction 优先追加到已有的纯文本 system 消息，避免部分上游不支持多条 system 消息
+func prependSystemInstruction(request *dto.GeneralOpenAIRequest, instruction string) {
+	if len(request.Messages) > 0 {
+		first := &request.Messages[0]
+		if (first.Role == "system" || first.Role == "developer") && first.IsStringContent() {
+			first.SetStringContent(first.StringContent() + "\n\n" + instruction)
+			return
+		}
+	}
+	system := dto.Message{Role: "system"}
+	system.SetStringContent(instruction)
+	request.Messages = append([]dto.Message{system}, request.Messages...)
+}
+
+// ExtractStructuredOutput 从模型回复中取出 JSON 文本：工具模式优先取强制工具调用的参数，
+// 否则取正文并去掉 markdown 代码块等包裹
+func ExtractStructuredOutput(message *dto.Message, spec *StructuredOutputSpec, mode dto.StructuredOutputEmulation) string {
+	if mode == dto.StructuredOutputEmulationTool {
+		for _, call := range message.ParseToolCalls() {
+			if call.Function.Name == spec.Name {
+				return strings.TrimSpace(call.Function.Arguments)
+			}
+		}
+	}
+	return extractJSONText(message.StringContent())
+}
+
+func extractJSONText(content string) string {
+	text := strings.TrimSpace(content)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```")
+		if idx := strings.IndexByte(text, '\n'); idx >= 0 {
+			text = text[idx+1:]
+		}
+		if idx := strings.LastIndex(text, "```"); idx >= 0 {
+			text = text[:idx]
+		}
+		text = strings.TrimSpace(text)
+	}
+	if json.Valid([]byte(text)) {
+		return text
+	}
+	// 模型在 JSON 前后夹带了说明文字时，取第一个 { 到最后一个 } 之间的内容
+	start := strings.IndexAny(text, "{[")
+	end := strings.LastIndexAny(text, "}]")
+	if start >= 0 && end > start && json.Valid([]byte(text[start:end+1])) {
+		return text[start : end+1]
+	}
+	return text
+}
+
+// BuildStructuredOutputRetryMessages 生成重试时追加的消息：保留模型上一轮回复并告知校验错误
+func BuildStructuredOutputRetryMessages(message dto.Message, spec *StructuredOutputSpec, mode dto.StructuredOutputEmulation, validationErr error) []dto.Message {
+	message.Role = "assistant"
+	if mode == dto.StructuredOutputEmulationTool {
+		for _, call := range message.ParseToolCalls() {
+			if call.Function.Name != spec.Name {
+				continue
+			}
+			message.SetToolCalls([]dto.ToolCallRequest{call})
+			toolMessage := dto.Message{Role: "tool", ToolCallId: call.ID}
+			toolMessage.SetStringContent(fmt.Sprintf("Error: the arguments do not match the schema: %s. Call %s again with corrected arguments.", validationErr.Error(), spec.Name))
+			return []dto.Message{message, toolMessage}
+		}
+	}
+
+	message.ToolCalls = nil
+	userMessage := dto.Message{Role: "user"}
+	if mode == dto.StructuredOutputEmulationTool {
+		userMessage.SetStringContent(fmt.Sprintf("Your reply did not match the required schema: %s. Call %s with the corrected answer.", validationErr.Error(), spec.Name))
+	} else {
+		userMessage.SetStringContent(fmt.Sprintf("Your reply did not match the required schema: %s. Reply again with only the corrected JSON.", validationErr.Error()))
+	}
+	return []dto.Message{message, userMessage}
+}
+
+// FinalizeStructuredOutputChoice 把结构化结果写回 message.content，去掉模拟用的工具调用
+func FinalizeStructuredOutputChoice(choice *dto.OpenAITextResponseChoice, output string) {
+	choice.Message.Role = "assistant"
+	choice.Message.SetStringContent(output)
+	choice.Message.ToolCalls = nil
+	if choice.FinishReason == "" || choice.FinishReason == "tool_calls" || choice.FinishReason == "function_call" {
+		choice.FinishReason = "stop"
+	}
+}
+
+func sanitizeStructuredOutputToolName(name string) string {
+	name = structuredOutputToolNameInvalidChars.ReplaceAllString(strings.TrimSpace(name), "_")
+	if name == "" {
+		return defaultStructuredOutputToolName
+	}
+	if len(name) > openAIResponsesChatToolNameMaxLen {
+		name = name[:openAIResponsesChatToolNameMaxLen]
+	}
+	return name
+}
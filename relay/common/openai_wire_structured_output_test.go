@@ -0,0 +1,133 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+
+	"github.com/tidwall/gjson"
+)
+
+func structuredOutputTestRequest(t *testing.T) (*dto.GeneralOpenAIRequest, *StructuredOutputSpec) {
+	t.Helper()
+	request := &dto.GeneralOpenAIRequest{
+		Model: "glm-4",
+		ResponseFormat: &dto.ResponseFormat{
+			Type:       "json_schema",
+			JsonSchema: json.RawMessage(`{"name":"weather report","strict":true,"schema":{"type":"object","properties":{"city":{"type":"string"},"temp":{"type":"number"}},"required":["city","temp"],"additionalProperties":false}}`),
+		},
+	}
+	user := dto.Message{Role: "user"}
+	user.SetStringContent("Weather in Paris?")
+	request.Messages = []dto.Message{user}
+
+	spec, err := ParseStructuredOutputSpec(request.ResponseFormat)
+	if err != nil || spec == nil {
+		t.Fatalf("ParseStructuredOutputSpec returned spec=%v err=%v", spec, err)
+	}
+	return request, spec
+}
+
+func TestApplyStructuredOutputEmulationToolMode(t *testing.T) {
+	request, spec := structuredOutputTestRequest(t)
+	if spec.Name != "weather_report" {
+		t.Fatalf("spec.Name = %q", spec.Name)
+	}
+
+	mode := ApplyStructuredOutputEmulation(request, spec, dto.StructuredOutputEmulationTool)
+	if mode != dto.StructuredOutputEmulationTool {
+		t.Fatalf("mode = %q", mode)
+	}
+	body, err := common.Marshal(request)
+	if err != nil {
+		t.Fatalf("marshal request failed: %v", err)
+	}
+	if gjson.GetBytes(body, "response_format").Exists() {
+		t.Fatalf("response_format should be removed: %s", body)
+	}
+	if gjson.GetBytes(body, "tools.0.function.name").String() != "weather_report" ||
+		gjson.GetBytes(body, "tools.0.function.parameters.required.1").String() != "temp" {
+		t.Fatalf("tools = %s", body)
+	}
+	if gjson.GetBytes(body, "tool_choice.function.name").String() != "weather_report" {
+		t.Fatalf("tool_choice = %s", body)
+	}
+
+	message := dto.Message{Role: "assistant"}
+	message.SetToolCalls([]dto.ToolCallRequest{{
+		ID:       "call_1",
+		Type:     "function",
+		Function: dto.FunctionRequest{Name: "weather_report", Arguments: `{"city":"Paris","temp":"warm"}`},
+	}})
+	output := ExtractStructuredOutput(&message, spec, mode)
+	validationErr := spec.Validate(output)
+	if validationErr == nil || !strings.Contains(validationErr.Error(), "$/temp: expected number, got string") {
+		t.Fatalf("validation error = %v", validationErr)
+	}
+
+	retry := BuildStructuredOutputRetryMessages(message, spec, mode, validationErr)
+	if len(retry) != 2 || retry[1].Role != "tool" || retry[1].ToolCallId != "call_1" ||
+		!strings.Contains(retry[1].StringContent(), "expected number") {
+		t.Fatalf("retry messages = %+v", retry)
+	}
+
+	choice := dto.OpenAITextResponseChoice{Message: message, FinishReason: "tool_calls"}
+	FinalizeStructuredOutputChoice(&choice, `{"city":"Paris","temp":21}`)
+	if choice.StringContent() != `{"city":"Paris","temp":21}` || choice.ToolCalls != nil || choice.FinishReason != "stop" {
+		t.Fatalf("finalized choice = %+v", choice)
+	}
+}
+
+func TestApplyStructuredOutputEmulationPromptMode(t *testing.T) {
+	request, spec := structuredOutputTestRequest(t)
+	request.Tools = []dto.ToolCallRequest{{Type: "function", Function: dto.FunctionRequest{Name: "lookup"}}}
+
+	// 客户端自带工具时不能强制调用，回退为系统指令
+	mode := ApplyStructuredOutputEmulation(request, spec, dto.StructuredOutputEmulationTool)
+	if mode != dto.StructuredOutputEmulationPrompt {
+		t.Fatalf("mode = %q", mode)
+	}
+	if len(request.Tools) != 1 || request.ToolChoice != nil || request.ResponseFormat != nil {
+		t.Fatalf("request tools should be untouched: %+v", request)
+	}
+	if len(request.Messages) != 2 || request.Messages[0].Role != "system" ||
+		!strings.Contains(request.Messages[0].StringContent(), `"required":["city","temp"]`) {
+		t.Fatalf("messages = %+v", request.Messages)
+	}
+
+	message := dto.Message{Role: "assistant"}
+	message.SetStringContent("Here you go:\n```json\n{\"city\":\"Paris\",\"temp\":21}\n```")
+	output := ExtractStructuredOutput(&message, spec, mode)
+	if output != `{"city":"Paris","temp":21}` {
+		t.Fatalf("output = %q", output)
+	}
+	if err := spec.Validate(output); err != nil {
+		t.Fatalf("Validate returned error: %v", err)
+	}
+
+	retry := BuildStructuredOutputRetryMessages(message, spec, mode, errors.New("$: missing required property \"temp\""))
+	if len(retry) != 2 || retry[0].Role != "assistant" || retry[1].Role != "user" {
+		t.Fatalf("retry messages = %+v", retry)
+	}
+}
+
+func TestParseStructuredOutputSpecJSONObject(t *testing.T) {
+	spec, err := ParseStructuredOutputSpec(&dto.ResponseFormat{Type: "json_object"})
+	if err != nil || spec == nil || !spec.IsJSONObject() {
+		t.Fatalf("spec=%v err=%v", spec, err)
+	}
+	if err := spec.Validate(`[1,2]`); err == nil {
+		t.Fatal("json_object should reject arrays")
+	}
+
+	if spec, err := ParseStructuredOutputSpec(&dto.ResponseFormat{Type: "text"}); spec != nil || err != nil {
+		t.Fatalf("text format should be ignored, got spec=%v err=%v", spec, err)
+	}
+	if _, err := ParseStructuredOutputSpec(&dto.ResponseFormat{Type: "json_schema", JsonSchema: json.RawMessage(`{"name":"x"}`)}); err == nil {
+		t.Fatal("json_schema without schema should be rejected")
+	}
+}
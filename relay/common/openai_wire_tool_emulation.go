@@ -0,0 +1,719 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+)
+
+const (
+	toolCallXMLOpenPrefix = "<tool_call"
+	toolCallXMLClose      = "</tool_call>"
+	toolCallFence         = "```"
+	toolCallFenceLang     = "tool_call"
+)
+
+// ToolCallEmulationState 以提示词模拟工具调用时，解析上游响应所需的状态
+type ToolCallEmulationState struct {
+	Format    dto.ToolCallEmulation
+	ToolNames map[string]bool
+}
+
+// ToolCallEmulationEnabled 渠道是否开启了工具调用模拟
+func ToolCallEmulationEnabled(info *RelayInfo) bool {
+	if info == nil || info.ChannelMeta == nil {
+		return false
+	}
+	format, _ := info.ChannelOtherSettings.ToolCallEmulation.Normalize()
+	return format != dto.ToolCallEmulationOff
+}
+
+// ApplyToolCallEmulation 把 tools 渲染进系统提示词，并把历史中的 tool_calls / tool 消息改写为纯文本，
+// 上游只会收到不含工具字段的普通对话。请求以 Responses 格式发往上游时不处理。
+func ApplyToolCallEmulation(info *RelayInfo, request *dto.GeneralOpenAIRequest) {
+	info.ToolCallEmulation = nil
+	if !ToolCallEmulationEnabled(info) {
+		return
+	}
+	if wire, _ := info.ChannelSetting.OpenAIWireAPI.Normalize(); wire == dto.OpenAIWireAPIResponses {
+		return
+	}
+	if len(request.Tools) == 0 && !hasToolHistory(request.Messages) {
+		return
+	}
+	format, _ := info.ChannelOtherSettings.ToolCallEmulation.Normalize()
+
+	toolNames := make(map[string]bool, len(request.Tools))
+	functions := make([]dto.FunctionRequest, 0, len(request.Tools))
+	for _, tool := range request.Tools {
+		// custom 等非函数工具无法用文本模拟，直接丢弃
+		if (tool.Type != "" && tool.Type != "function") || tool.Function.Name == "" {
+			continue
+		}
+		toolNames[tool.Function.Name] = true
+		functions = append(functions, tool.Function)
+	}
+	choice, forced := parseEmulatedToolChoice(request.ToolChoice)
+
+	request.Messages = renderToolHistory(request.Messages, format)
+	request.Tools = nil
+	request.ToolChoice = nil
+	request.ParallelTooCalls = nil
+	if len(functions) == 0 || choice == "none" {
+		return
+	}
+	if forced != "" && !toolNames[forced] {
+		forced = ""
+	}
+	prependSystemInstruction(request, toolCallEmulationInstruction(functions, format, choice, forced))
+	info.ToolCallEmulation = &ToolCallEmulationState{Format: format, ToolNames: toolNames}
+}
+
+func hasToolHistory(messages []dto.Message) bool {
+	for _, message := range messages {
+		if message.Role == "tool" || len(message.ToolCalls) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func parseEmulatedToolChoice(choice any) (string, string) {
+	switch v := choice.(type) {
+	case string:
+		return strings.ToLower(strings.TrimSpace(v)), ""
+	case map[string]any:
+		name, _ := v["name"].(string)
+		if name == "" {
+			name = getToolChoiceName(v, "function")
+		}
+		return "required", strings.TrimSpace(name)
+	}
+	return "auto", ""
+}
+
+func toolCallEmulationInstruction(functions []dto.FunctionRequest, format dto.ToolCallEmulation, choice string, forced string) string {
+	var b strings.Builder
+	b.WriteString("You can call the following tools to help answer the user.\n\n# Tools\n")
+	for _, fn := range functions {
+		b.WriteString("\n## ")
+		b.WriteString(fn.Name)
+		b.WriteString("\n")
+		if fn.Description != "" {
+			b.WriteString(fn.Description)
+			b.WriteString("\n")
+		}
+		parameters := "{}"
+		if fn.Parameters != nil {
+			if raw, err := common.Marshal(fn.Parameters); err == nil {
+				parameters = string(raw)
+			}
+		}
+		b.WriteString("Parameters (JSON Schema): ")
+		b.WriteString(parameters)
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n# How to call tools\n")
+	if format == dto.ToolCallEmulationJSON {
+		b.WriteString("To call a tool, output one fenced block per call in exactly this format:\n")
+		b.WriteString("```tool_call\n{\"name\": \"TOOL_NAME\", \"arguments\": {\"param\": \"value\"}}\n```\n")
+	} else {
+		b.WriteString("To call a tool, output one block per call in exactly this format:\n")
+		b.WriteString("<tool_call>\n<name>TOOL_NAME</name>\n<arguments>{\"param\": \"value\"}</arguments>\n</tool_call>\n")
+	}
+	b.WriteString("The arguments must be a single JSON object that matches the tool's parameters. ")
+	b.WriteString("You may call several tools at once. After the tool calls, stop and wait: the results will be sent back inside <tool_result> blocks.\n")
+	switch {
+	case forced != "":
+		b.WriteString("You must call the tool " + forced + " now.")
+	case choice == "required":
+		b.WriteString("You must call at least one tool now.")
+	default:
+		b.WriteString("If no tool is needed, answer directly without any tool call block.")
+	}
+	return b.String()
+}
+
+// renderToolHistory 将历史中的 assistant tool_calls 渲染为调用块文本，连续的 tool 消息合并为一条 user 消息
+func renderToolHistory(messages []dto.Message, format dto.ToolCallEmulation) []dto.Message {
+	names := make(map[string]string)
+	out := make([]dto.Message, 0, len(messages))
+	lastWasToolResult := false
+	for _, message := range messages {
+		if message.Role == "tool" {
+			result := renderToolResult(names[message.ToolCallId], message.ToolCallId, message.StringContent())
+			if n := len(out); lastWasToolResult && n > 0 {
+				out[n-1].SetStringContent(out[n-1].StringContent() + "\n" + result)
+				continue
+			}
+			user := dto.Message{Role: "user"}
+			user.SetStringContent(result)
+			out = append(out, user)
+			lastWasToolResult = true
+			continue
+		}
+		lastWasToolResult = false
+
+		calls := message.ParseToolCalls()
+		if message.Role != "assistant" || len(calls) == 0 {
+			out = append(out, message)
+			continue
+		}
+		var b strings.Builder
+		b.WriteString(strings.TrimSpace(message.StringContent()))
+		for _, call := range calls {
+			names[call.ID] = call.Function.Name
+			if b.Len() > 0 {
+				b.WriteString("\n")
+			}
+			b.WriteString(renderToolCall(call.Function.Name, call.Function.Arguments, format))
+		}
+		rendered := message
+		rendered.ToolCalls = nil
+		rendered.SetStringContent(b.String())
+		out = append(out, rendered)
+	}
+	return out
+}
+
+func renderToolCall(name string, arguments string, format dto.ToolCallEmulation) string {
+	args := strings.TrimSpace(arguments)
+	if args == "" {
+		args = "{}"
+	}
+	var compacted bytes.Buffer
+	if json.Compact(&compacted, []byte(args)) == nil {
+		args = compacted.String()
+	} else {
+		args = strconv.Quote(args)
+	}
+	if format == dto.ToolCallEmulationJSON {
+		return toolCallFence + toolCallFenceLang + "\n{\"name\":" + strconv.Quote(name) + ",\"arguments\":" + args + "}\n" + toolCallFence
+	}
+	return "<tool_call>\n<name>" + name + "</name>\n<arguments>" + args + "</arguments>\n" + toolCallXMLClose
+}
+
+func renderToolResult(name string, callId string, content string) string {
+	var b strings.Builder
+	b.WriteString("<tool_result")
+	if name != "" {
+		b.WriteString(" name=" + strconv.Quote(name))
+	}
+	if callId != "" {
+		b.WriteString(" tool_call_id=" + strconv.Quote(callId))
+	}
+	b.WriteString(">\n")
+	b.WriteString(content)
+	b.WriteString("\n</tool_result>")
+	return b.String()
+}
+
+// ToolCallTextParser 从模型输出中增量识别文本形式的工具调用（XML 块或 ```tool_call / ```json 代码块）。
+// 普通文本尽快放行，疑似调用块在闭合前暂存；名称不在工具列表中或参数不是合法 JSON 的块按普通文本返回。
+type ToolCallTextParser struct {
+	toolNames map[string]bool
+	pending   string
+	sawCall   bool
+}
+
+func NewToolCallTextParser(toolNames map[string]bool) *ToolCallTextParser {
+	return &ToolCallTextParser{toolNames: toolNames}
+}
+
+// Feed 追加一段模型输出，返回可以立即下发的文本与已识别的工具调用
+func (p *ToolCallTextParser) Feed(delta string) (string, []dto.ToolCallRequest) {
+	p.pending += delta
+	return p.drain(false)
+}
+
+// Flush 在输出结束时处理暂存内容，未闭合的调用块也会尝试解析
+func (p *ToolCallTextParser) Flush() (string, []dto.ToolCallRequest) {
+	return p.drain(true)
+}
+
+func (p *ToolCallTextParser) drain(final bool) (string, []dto.ToolCallRequest) {
+	var text strings.Builder
+	var calls []dto.ToolCallRequest
+	for p.pending != "" {
+		start := indexToolCallStart(p.pending)
+		if start < 0 {
+			keep := 0
+			if !final {
+				keep = partialToolCallMarkerLen(p.pending)
+			}
+			text.WriteString(p.pending[:len(p.pending)-keep])
+			p.pending = p.pending[len(p.pending)-keep:]
+			break
+		}
+		text.WriteString(p.pending[:start])
+		p.pending = p.pending[start:]
+
+		end, call, ok := p.matchBlock(p.pending, final)
+		if end < 0 {
+			break
+		}
+		if ok {
+			calls = append(calls, call)
+		} else {
+			text.WriteString(p.pending[:end])
+		}
+		p.pending = p.pending[end:]
+	}
+
+	out := text.String()
+	// 调用块之后模型常输出换行等空白，不再下发
+	if (p.sawCall || len(calls) > 0) && strings.TrimSpace(out) == "" {
+		out = ""
+	}
+	if len(calls) > 0 {
+		p.sawCall = true
+	}
+	return out, calls
+}
+
+// matchBlock 解析以调用块起始标记开头的内容，返回块的结束位置；块尚未闭合且未结束时返回 -1
+func (p *ToolCallTextParser) matchBlock(s string, final bool) (int, dto.ToolCallRequest, bool) {
+	if strings.HasPrefix(s, toolCallXMLOpenPrefix) {
+		openEnd := strings.IndexByte(s, '>')
+		if openEnd < 0 {
+			if final {
+				return len(s), dto.ToolCallRequest{}, false
+			}
+			return -1, dto.ToolCallRequest{}, false
+		}
+		rest := s[openEnd+1:]
+		end := len(s)
+		if closeIdx := strings.Index(rest, toolCallXMLClose); closeIdx >= 0 {
+			rest = rest[:closeIdx]
+			end = openEnd + 1 + closeIdx + len(toolCallXMLClose)
+		} else if !final {
+			return -1, dto.ToolCallRequest{}, false
+		}
+		call, ok := p.parseInvocation(rest)
+		return end, call, ok
+	}
+
+	newline := strings.IndexByte(s[len(toolCallFence):], '\n')
+	if newline < 0 {
+		if final {
+			return len(s), dto.ToolCallRequest{}, false
+		}
+		return -1, dto.ToolCallRequest{}, false
+	}
+	lang := strings.ToLower(strings.TrimSpace(s[len(toolCallFence) : len(toolCallFence)+newline]))
+	bodyStart := len(toolCallFence) + newline + 1
+	body := s[bodyStart:]
+	end := len(s)
+	if closeIdx := strings.Index(body, toolCallFence); closeIdx >= 0 {
+		body = body[:closeIdx]
+		end = bodyStart + closeIdx + len(toolCallFence)
+	} else if !final {
+		return -1, dto.ToolCallRequest{}, false
+	}
+	if lang != "" && lang != toolCallFenceLang && lang != "json" {
+		return end, dto.ToolCallRequest{}, false
+	}
+	call, ok := p.parseInvocation(body)
+	return end, call, ok
+}
+
+func (p *ToolCallTextParser) parseInvocation(inner string) (dto.ToolCallRequest, bool) {
+	inner = strings.TrimSpace(inner)
+	var name, args string
+	if tagged, ok := extractTag(inner, "name"); ok {
+		name = tagged
+		args, _ = extractTag(inner, "arguments")
+		if args == "" {
+			args, _ = extractTag(inner, "parameters")
+		}
+	} else {
+		var invocation struct {
+			Name       string          `json:"name"`
+			Arguments  json.RawMessage `json:"arguments"`
+			Parameters json.RawMessage `json:"parameters"`
+		}
+		if err := common.UnmarshalJsonStr(inner, &invocation); err != nil {
+			return dto.ToolCallRequest{}, false
+		}
+		name = invocation.Name
+		args = string(invocation.Arguments)
+		if args == "" {
+			args = string(invocation.Parameters)
+		}
+	}
+
+	name = strings.TrimSpace(name)
+	if !p.toolNames[name] {
+		return dto.ToolCallRequest{}, false
+	}
+	args = strings.TrimSpace(args)
+	if args == "" || args == "null" {
+		args = "{}"
+	}
+	// 部分模型会把参数写成 JSON 字符串
+	if strings.HasPrefix(args, `"`) {
+		var unquoted string
+		if err := common.UnmarshalJsonStr(args, &unquoted); err == nil {
+			args = strings.TrimSpace(unquoted)
+		}
+	}
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, []byte(args)); err != nil {
+		return dto.ToolCallRequest{}, false
+	}
+	return dto.ToolCallRequest{
+		ID:   "call_" + common.GetRandomString(24),
+		Type: "function",
+		Function: dto.FunctionRequest{
+			Name:      name,
+			Arguments: compacted.String(),
+		},
+	}, true
+}
+
+func extractTag(s string, tag string) (string, bool) {
+	open := "<" + tag + ">"
+	start := strings.Index(s, open)
+	if start < 0 {
+		return "", false
+	}
+	rest := s[start+len(open):]
+	if end := strings.Index(rest, "</"+tag+">"); end >= 0 {
+		rest = rest[:end]
+	}
+	return strings.TrimSpace(rest), true
+}
+
+func indexToolCallStart(s string) int {
+	xml := strings.Index(s, toolCallXMLOpenPrefix)
+	fence := strings.Index(s, toolCallFence)
+	switch {
+	case xml < 0:
+		return fence
+	case fence < 0:
+		return xml
+	default:
+		return min(xml, fence)
+	}
+}
+
+// partialToolCallMarkerLen 返回结尾处可能是调用块起始标记前缀的长度，这部分需要等待后续输出
+func partialToolCallMarkerLen(s string) int {
+	for _, marker := range []string{toolCallXMLOpenPrefix, toolCallFence} {
+		for k := min(len(marker)-1, len(s)); k > 0; k-- {
+			if strings.HasSuffix(s, marker[:k]) {
+				return k
+			}
+		}
+	}
+	return 0
+}
+
+// ApplyToolCallEmulationToResponse 在适配器解析前把上游 chat 响应中的文本工具调用还原为 tool_calls，
+// Claude / Responses 等客户端格式因此可以沿用现有的转换逻辑
+func ApplyToolCallEmulationToResponse(info *RelayInfo, resp *http.Response) error {
+	if info.ToolCallEmulation == nil || resp == nil || resp.Body == nil || resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	toolNames := info.ToolCallEmulation.ToolNames
+	if info.IsStream || strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		resp.Body = newToolCallEmulationStreamBody(resp.Body, toolNames)
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("read response body failed: %w", err)
+	}
+	body = rewriteEmulatedToolCallResponse(body, toolNames)
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+func rewriteEmulatedToolCallResponse(body []byte, toolNames map[string]bool) []byte {
+	var response map[string]any
+	if err := common.Unmarshal(body, &response); err != nil {
+		return body
+	}
+	choices, _ := response["choices"].([]any)
+	modified := false
+	for _, item := range choices {
+		choice, _ := item.(map[string]any)
+		message, _ := choice["message"].(map[string]any)
+		content, _ := message["content"].(string)
+		if content == "" {
+			continue
+		}
+		parser := NewToolCallTextParser(toolNames)
+		text, calls := parser.Feed(content)
+		rest, more := parser.Flush()
+		calls = append(calls, more...)
+		if len(calls) == 0 {
+			continue
+		}
+		if text = strings.TrimSpace(text + rest); text == "" {
+			message["content"] = nil
+		} else {
+			message["content"] = text
+		}
+		message["tool_calls"] = calls
+		if reason, _ := choice["finish_reason"].(string); reason == "" || reason == "stop" {
+			choice["finish_reason"] = "tool_calls"
+		}
+		modified = true
+	}
+	if !modified {
+		return body
+	}
+	rewritten, err := common.Marshal(response)
+	if err != nil {
+		return body
+	}
+	return rewritten
+}
+
+type emulatedToolCallChoiceState struct {
+	parser   *ToolCallTextParser
+	calls    int
+	finished bool
+}
+
+// toolCallEmulationStreamBody 逐个改写上游 SSE chunk：正文交给 ToolCallTextParser，识别出的调用以 tool_calls delta 下发，
+// 上游结束时补发暂存的文本与 finish_reason
+type toolCallEmulationStreamBody struct {
+	source    io.ReadCloser
+	reader    *bufio.Reader
+	toolNames map[string]bool
+	choices   map[int]*emulatedToolCallChoiceState
+	order     []int
+	template  map[string]any
+	flushed   bool
+	buf       bytes.Buffer
+	err       error
+}
+
+func newToolCallEmulationStreamBody(source io.ReadCloser, toolNames map[string]bool) *toolCallEmulationStreamBody {
+	return &toolCallEmulationStreamBody{
+		source:    source,
+		reader:    bufio.NewReaderSize(source, 64*1024),
+		toolNames: toolNames,
+		choices:   make(map[int]*emulatedToolCallChoiceState),
+	}
+}
+
+func (b *toolCallEmulationStreamBody) Read(p []byte) (int, error) {
+	for b.buf.Len() == 0 && b.err == nil {
+		line, err := b.reader.ReadString('\n')
+		if len(line) > 0 {
+			b.rewriteLine(line)
+		}
+		if err != nil {
+			b.writeEvents(b.flush())
+		}
+		b.err = err
+	}
+	if b.buf.Len() > 0 {
+		return b.buf.Read(p)
+	}
+	return 0, b.err
+}
+
+func (b *toolCallEmulationStreamBody) Close() error {
+	return b.source.Close()
+}
+
+func (b *toolCallEmulationStreamBody) rewriteLine(line string) {
+	content := strings.TrimRight(line, "\r\n")
+	data, ok := strings.CutPrefix(content, "data:")
+	if !ok {
+		b.buf.WriteString(line)
+		return
+	}
+	data = strings.TrimSpace(data)
+	if data == "[DONE]" {
+		b.writeEvents(b.flush())
+		b.buf.WriteString(line)
+		return
+	}
+	b.writeEvents(b.rewriteChunk(data))
+}
+
+func (b *toolCallEmulationStreamBody) writeEvents(events []string) {
+	for _, event := range events {
+		b.buf.WriteString("data: ")
+		b.buf.WriteString(event)
+		b.buf.WriteString("\n\n")
+	}
+}
+
+func (b *toolCallEmulationStreamBody) state(index int) *emulatedToolCallChoiceState {
+	state, ok := b.choices[index]
+	if !ok {
+		state = &emulatedToolCallChoiceState{parser: NewToolCallTextParser(b.toolNames)}
+		b.choices[index] = state
+		b.order = append(b.order, index)
+	}
+	return state
+}
+
+func (b *toolCallEmulationStreamBody) rewriteChunk(data string) []string {
+	var chunk map[string]any
+	if err := common.UnmarshalJsonStr(data, &chunk); err != nil {
+		return []string{data}
+	}
+	choices, _ := chunk["choices"].([]any)
+	if len(choices) == 0 {
+		return []string{data}
+	}
+	usage, hasUsage := chunk["usage"]
+	delete(chunk, "usage")
+	delete(chunk, "choices")
+	b.template = chunk
+
+	var events []map[string]any
+	for _, item := range choices {
+		choice, _ := item.(map[string]any)
+		if choice == nil {
+			continue
+		}
+		index := 0
+		if v, ok := choice["index"].(float64); ok {
+			index = int(v)
+		}
+		state := b.state(index)
+		delta, _ := choice["delta"].(map[string]any)
+		finishReason, _ := choice["finish_reason"].(string)
+
+		var text string
+		var calls []dto.ToolCallRequest
+		if content, ok := delta["content"].(string); ok && content != "" {
+			text, calls = state.parser.Feed(content)
+		}
+		if finishReason != "" {
+			rest, more := state.parser.Flush()
+			text += rest
+			calls = append(calls, more...)
+			state.finished = true
+		}
+		if delta != nil {
+			if text == "" {
+				delete(delta, "content")
+			} else {
+				delta["content"] = text
+			}
+		}
+		if len(delta) > 0 {
+			choice["finish_reason"] = nil
+			events = append(events, b.chunkWithChoice(choice))
+		}
+		events = append(events, b.toolCallChunks(index, state, calls)...)
+		if finishReason != "" {
+			events = append(events, b.finishChunk(index, state, finishReason))
+		}
+	}
+
+	if hasUsage && usage != nil {
+		if len(events) == 0 {
+			events = append(events, b.chunkWithChoices([]any{}))
+		}
+		events[len(events)-1]["usage"] = usage
+	}
+	return marshalEmulatedToolCallChunks(events)
+}
+
+// flush 上游未给出 finish_reason 就结束时补发暂存的内容
+func (b *toolCallEmulationStreamBody) flush() []string {
+	if b.flushed || b.template == nil {
+		return nil
+	}
+	b.flushed = true
+	var events []map[string]any
+	for _, index := range b.order {
+		state := b.choices[index]
+		if state.finished {
+			continue
+		}
+		text, calls := state.parser.Flush()
+		if text != "" {
+			events = append(events, b.chunkWithChoice(map[string]any{
+				"index":         index,
+				"delta":         map[string]any{"content": text},
+				"finish_reason": nil,
+			}))
+		}
+		events = append(events, b.toolCallChunks(index, state, calls)...)
+		if state.calls > 0 {
+			events = append(events, b.finishChunk(index, state, "stop"))
+		}
+		state.finished = true
+	}
+	return marshalEmulatedToolCallChunks(events)
+}
+
+func (b *toolCallEmulationStreamBody) toolCallChunks(index int, state *emulatedToolCallChoiceState, calls []dto.ToolCallRequest) []map[string]any {
+	events := make([]map[string]any, 0, len(calls))
+	for _, call := range calls {
+		events = append(events, b.chunkWithChoice(map[string]any{
+			"index": index,
+			"delta": map[string]any{
+				"tool_calls": []dto.ToolCallResponse{{
+					Index:    common.GetPointer(state.calls),
+					ID:       call.ID,
+					Type:     call.Type,
+					Function: dto.FunctionResponse{Name: call.Function.Name, Arguments: call.Function.Arguments},
+				}},
+			},
+			"finish_reason": nil,
+		}))
+		state.calls++
+	}
+	return events
+}
+
+func (b *toolCallEmulationStreamBody) finishChunk(index int, state *emulatedToolCallChoiceState, finishReason string) map[string]any {
+	if state.calls > 0 && finishReason == "stop" {
+		finishReason = "tool_calls"
+	}
+	return b.chunkWithChoice(map[string]any{
+		"index":         index,
+		"delta":         map[string]any{},
+		"finish_reason": finishReason,
+	})
+}
+
+func (b *toolCallEmulationStreamBody) chunkWithChoice(choice map[string]any) map[string]any {
+	return b.chunkWithChoices([]any{choice})
+}
+
+func (b *toolCallEmulationStreamBody) chunkWithChoices(choices []any) map[string]any {
+	chunk := make(map[string]any, len(b.template)+1)
+	for k, v := range b.template {
+		chunk[k] = v
+	}
+	chunk["choices"] = choices
+	return chunk
+}
+
+func marshalEmulatedToolCallChunks(events []map[string]any) []string {
+	out := make([]string, 0, len(events))
+	for _, event := range events {
+		data, err := common.Marshal(event)
+		if err != nil {
+			continue
+		}
+		out = append(out, string(data))
+	}
+	return out
+}
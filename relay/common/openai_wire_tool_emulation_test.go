@@ -0,0 +1,205 @@
+package common
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+
+	"github.com/tidwall/gjson"
+)
+
+func toolEmulationTestInfo(format dto.ToolCallEmulation) *RelayInfo {
+	return &RelayInfo{ChannelMeta: &ChannelMeta{
+		ChannelOtherSettings: dto.ChannelOtherSettings{ToolCallEmulation: format},
+	}}
+}
+
+func TestApplyToolCallEmulationRendersToolsAndHistory(t *testing.T) {
+	info := toolEmulationTestInfo(dto.ToolCallEmulationJSON)
+	request := &dto.GeneralOpenAIRequest{
+		Model: "qwen2.5",
+		Tools: []dto.ToolCallRequest{{
+			Type: "function",
+			Function: dto.FunctionRequest{
+				Name:        "get_weather",
+				Description: "Get the current weather",
+				Parameters:  map[string]any{"type": "object", "properties": map[string]any{"city": map[string]any{"type": "string"}}},
+			},
+		}},
+		ToolChoice: "required",
+	}
+	system := dto.Message{Role: "system"}
+	system.SetStringContent("Be brief.")
+	user := dto.Message{Role: "user"}
+	user.SetStringContent("Weather in Paris and Rome?")
+	assistant := dto.Message{Role: "assistant"}
+	assistant.SetToolCalls([]dto.ToolCallRequest{
+		{ID: "call_a", Type: "function", Function: dto.FunctionRequest{Name: "get_weather", Arguments: `{"city": "Paris"}`}},
+		{ID: "call_b", Type: "function", Function: dto.FunctionRequest{Name: "get_weather", Arguments: `{"city": "Rome"}`}},
+	})
+	resultA := dto.Message{Role: "tool", ToolCallId: "call_a"}
+	resultA.SetStringContent("18C")
+	resultB := dto.Message{Role: "tool", ToolCallId: "call_b"}
+	resultB.SetStringContent("24C")
+	request.Messages = []dto.Message{system, user, assistant, resultA, resultB}
+
+	ApplyToolCallEmulation(info, request)
+
+	if request.Tools != nil || request.ToolChoice != nil {
+		t.Fatalf("tools should be removed: %+v", request)
+	}
+	if info.ToolCallEmulation == nil || !info.ToolCallEmulation.ToolNames["get_weather"] {
+		t.Fatalf("emulation state = %+v", info.ToolCallEmulation)
+	}
+	if len(request.Messages) != 4 {
+		t.Fatalf("expected tool results to be merged, got %d messages", len(request.Messages))
+	}
+	prompt := request.Messages[0].StringContent()
+	if !strings.HasPrefix(prompt, "Be brief.\n\n") || !strings.Contains(prompt, "## get_weather") ||
+		!strings.Contains(prompt, "```tool_call") || !strings.Contains(prompt, "You must call at least one tool now.") {
+		t.Fatalf("system prompt = %s", prompt)
+	}
+	history := request.Messages[2]
+	if len(history.ToolCalls) != 0 || !strings.Contains(history.StringContent(), "```tool_call\n{\"name\":\"get_weather\",\"arguments\":{\"city\":\"Rome\"}}\n```") {
+		t.Fatalf("assistant history = %q", history.StringContent())
+	}
+	results := request.Messages[3]
+	if results.Role != "user" || !strings.Contains(results.StringContent(), `<tool_result name="get_weather" tool_call_id="call_a">`+"\n18C") ||
+		!strings.Contains(results.StringContent(), `tool_call_id="call_b">`+"\n24C") {
+		t.Fatalf("tool results = %q", results.StringContent())
+	}
+
+	disabled := toolEmulationTestInfo("")
+	untouched := &dto.GeneralOpenAIRequest{Tools: []dto.ToolCallRequest{{Type: "function", Function: dto.FunctionRequest{Name: "x"}}}}
+	ApplyToolCallEmulation(disabled, untouched)
+	if len(untouched.Tools) != 1 || disabled.ToolCallEmulation != nil {
+		t.Fatal("emulation must stay off unless enabled on the channel")
+	}
+}
+
+func TestToolCallTextParserIncremental(t *testing.T) {
+	parser := NewToolCallTextParser(map[string]bool{"get_weather": true})
+	output := "Let me check.\n<tool_call>\n<name>get_weather</name>\n<arguments>{\"city\": \"Paris\"}</arguments>\n</tool_call>\n" +
+		"```python\nprint(1)\n```"
+
+	var text strings.Builder
+	var calls []dto.ToolCallRequest
+	for i := 0; i < len(output); i += 3 {
+		end := min(i+3, len(output))
+		chunkText, chunkCalls := parser.Feed(output[i:end])
+		if strings.Contains(chunkText, "<tool") || strings.Contains(chunkText, "</") {
+			t.Fatalf("tool call markup leaked into text: %q", chunkText)
+		}
+		text.WriteString(chunkText)
+		calls = append(calls, chunkCalls...)
+	}
+	rest, more := parser.Flush()
+	text.WriteString(rest)
+	calls = append(calls, more...)
+
+	if len(calls) != 1 || calls[0].Function.Name != "get_weather" || calls[0].Function.Arguments != `{"city":"Paris"}` || !strings.HasPrefix(calls[0].ID, "call_") {
+		t.Fatalf("calls = %+v", calls)
+	}
+	if text.String() != "Let me check.\n```python\nprint(1)\n```" {
+		t.Fatalf("text = %q", text.String())
+	}
+
+	// 未知工具名与非法参数按普通文本返回
+	parser = NewToolCallTextParser(map[string]bool{"get_weather": true})
+	plain := "<tool_call>{\"name\":\"rm_rf\",\"arguments\":{}}</tool_call>"
+	got, gotCalls := parser.Feed(plain)
+	if len(gotCalls) != 0 || got != plain {
+		t.Fatalf("unknown tool should stay text, got %q %+v", got, gotCalls)
+	}
+}
+
+func TestApplyToolCallEmulationToNonStreamResponse(t *testing.T) {
+	info := toolEmulationTestInfo(dto.ToolCallEmulationXML)
+	info.ToolCallEmulation = &ToolCallEmulationState{ToolNames: map[string]bool{"get_weather": true}}
+	body := `{"id":"chatcmpl-1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"` +
+		"```tool_call\\n{\\\"name\\\": \\\"get_weather\\\", \\\"arguments\\\": \\\"{\\\\\\\"city\\\\\\\": \\\\\\\"Paris\\\\\\\"}\\\"}\\n```" +
+		`"},"finish_reason":"stop"}],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`
+	if !json.Valid([]byte(body)) {
+		t.Fatalf("invalid test body: %s", body)
+	}
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}
+	if err := ApplyToolCallEmulationToResponse(info, resp); err != nil {
+		t.Fatalf("ApplyToolCallEmulationToResponse returned error: %v", err)
+	}
+	rewritten, _ := io.ReadAll(resp.Body)
+	result := gjson.ParseBytes(rewritten)
+	if result.Get("choices.0.finish_reason").String() != "tool_calls" || result.Get("choices.0.message.content").Type != gjson.Null {
+		t.Fatalf("rewritten = %s", rewritten)
+	}
+	if result.Get("choices.0.message.tool_calls.0.function.arguments").String() != `{"city":"Paris"}` || result.Get("usage.total_tokens").Int() != 15 {
+		t.Fatalf("rewritten = %s", rewritten)
+	}
+}
+
+func TestApplyToolCallEmulationToStreamResponse(t *testing.T) {
+	info := toolEmulationTestInfo(dto.ToolCallEmulationXML)
+	info.IsStream = true
+	info.ToolCallEmulation = &ToolCallEmulationState{ToolNames: map[string]bool{"get_weather": true}}
+
+	deltas := []string{"Checking", " now.\n<tool", "_call><name>get_weather</name><arguments>{\"city\":", "\"Paris\"}</arguments></tool_call>"}
+	var upstream strings.Builder
+	for i, delta := range deltas {
+		chunk := map[string]any{
+			"id": "chatcmpl-1", "object": "chat.completion.chunk", "model": "qwen",
+			"choices": []any{map[string]any{"index": 0, "delta": map[string]any{"content": delta}, "finish_reason": nil}},
+		}
+		if i == 0 {
+			chunk["choices"].([]any)[0].(map[string]any)["delta"].(map[string]any)["role"] = "assistant"
+		}
+		data, _ := common.Marshal(chunk)
+		upstream.WriteString("data: " + string(data) + "\n\n")
+	}
+	upstream.WriteString(`data: {"id":"chatcmpl-1","object":"chat.completion.chunk","model":"qwen","choices":[{"index":0,"delta":{},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":4,"total_tokens":7}}` + "\n\n")
+	upstream.WriteString("data: [DONE]\n\n")
+
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": []string{"text/event-stream"}}, Body: io.NopCloser(strings.NewReader(upstream.String()))}
+	if err := ApplyToolCallEmulationToResponse(info, resp); err != nil {
+		t.Fatalf("ApplyToolCallEmulationToResponse returned error: %v", err)
+	}
+	rewritten, _ := io.ReadAll(resp.Body)
+
+	var content strings.Builder
+	var toolCalls []gjson.Result
+	var finishReason string
+	var usageTotal int64
+	sawDone := false
+	for _, line := range strings.Split(string(rewritten), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		if data == "[DONE]" {
+			sawDone = true
+			continue
+		}
+		chunk := gjson.Parse(data)
+		content.WriteString(chunk.Get("choices.0.delta.content").String())
+		toolCalls = append(toolCalls, chunk.Get("choices.0.delta.tool_calls").Array()...)
+		if reason := chunk.Get("choices.0.finish_reason").String(); reason != "" {
+			finishReason = reason
+		}
+		if total := chunk.Get("usage.total_tokens").Int(); total > 0 {
+			usageTotal = total
+		}
+	}
+	if content.String() != "Checking now.\n" {
+		t.Fatalf("content = %q\n%s", content.String(), rewritten)
+	}
+	if len(toolCalls) != 1 || toolCalls[0].Get("index").Int() != 0 || toolCalls[0].Get("function.name").String() != "get_weather" ||
+		toolCalls[0].Get("function.arguments").String() != `{"city":"Paris"}` {
+		t.Fatalf("tool calls = %v\n%s", toolCalls, rewritten)
+	}
+	if finishReason != "tool_calls" || usageTotal != 7 || !sawDone {
+		t.Fatalf("finish=%q usage=%d done=%v\n%s", finishReason, usageTotal, sawDone, rewritten)
+	}
+}
@@ -15,7 +15,7 @@ var negativeIndexRegexp = regexp.MustCompile(`\.(-\d+)`)
 
 type ConditionOperation struct {
 	Path           string      `json:"path"`             // JSON路径
-	Mode           string      `json:"mode"`             // full, prefix, suffix, contains, gt, gte, lt, lte
+	Mode           string      `json:"mode"`             // full, prefix, suffix, contains, gt, gte, lt, lte, exists
 	Value          interface{} `json:"value"`            // 匹配的值
 	Invert         bool        `json:"invert"`           // 反选功能，true表示取反结果
 	PassMissingKey bool        `json:"pass_missing_key"` // 未获取到json key时的行为
@@ -33,6 +33,12 @@ type ParamOperation struct {
 }
 
 func ApplyParamOverride(jsonData []byte, paramOverride map[string]interface{}, conditionContext map[string]interface{}) ([]byte, error) {
+	return applyParamOverride(jsonData, paramOverride, conditionContext, false)
+}
+
+// applyParamOverride 中 copyFromContext 为 true 时，copy 的源路径在 JSON 中不存在则从条件上下文取值，
+// 仅用于响应改写（例如把响应中的 model 改回 original_model），请求改写的 copy 只读取请求 JSON
+func applyParamOverride(jsonData []byte, paramOverride map[string]interface{}, conditionContext map[string]interface{}, copyFromContext bool) ([]byte, error) {
 	if len(paramOverride) == 0 {
 		return jsonData, nil
 	}
@@ -40,7 +46,7 @@ func ApplyParamOverride(jsonData []byte, paramOverride map[string]interface{}, c
 	// 尝试断言为操作格式
 	if operations, ok := tryParseOperations(paramOverride); ok {
 		// 使用新方法
-		result, err := applyOperations(string(jsonData), operations, conditionContext)
+		result, err := applyOperations(string(jsonData), operations, conditionContext, copyFromContext)
 		return []byte(result), err
 	}
 
@@ -162,6 +168,13 @@ func checkSingleCondition(jsonStr, contextJSON string, condition ConditionOperat
 	if !value.Exists() && contextJSON != "" {
 		value = gjson.Get(contextJSON, condition.Path)
 	}
+	if strings.EqualFold(condition.Mode, "exists") {
+		result := value.Exists()
+		if condition.Invert {
+			result = !result
+		}
+		return result, nil
+	}
 	if !value.Exists() {
 		if condition.PassMissingKey {
 			return true, nil
@@ -308,7 +321,7 @@ func applyOperationsLegacy(jsonData []byte, paramOverride map[string]interface{}
 	return common.Marshal(reqMap)
 }
 
-func applyOperations(jsonStr string, operations []ParamOperation, conditionContext map[string]interface{}) (string, error) {
+func applyOperations(jsonStr string, operations []ParamOperation, conditionContext map[string]interface{}, copyFromContext bool) (string, error) {
 	var contextJSON string
 	if conditionContext != nil && len(conditionContext) > 0 {
 		ctxBytes, err := common.Marshal(conditionContext)
@@ -349,6 +362,12 @@ func applyOperations(jsonStr string, operations []ParamOperation, conditionConte
 			}
 			opFrom := processNegativeIndex(result, op.From)
 			opTo := processNegativeIndex(result, op.To)
+			if copyFromContext && !gjson.Get(result, opFrom).Exists() && contextJSON != "" {
+				if ctxValue := gjson.Get(contextJSON, op.From); ctxValue.Exists() {
+					result, err = sjson.Set(result, opTo, ctxValue.Value())
+					break
+				}
+			}
 			result, err = copyValue(result, opFrom, opTo)
 		case "prepend":
 			result, err = modifyValue(result, opPath, op.Value, op.KeepOrigin, true)
@@ -572,6 +591,7 @@ func mergeObjects(jsonStr, path string, value interface{}, keepOrigin bool) (str
 // 目前内置以下字段：
 //   - upstream_model/model：始终为通道映射后的上游模型名。
 //   - original_model：请求最初指定的模型名。
+//   - virtual_model：请求使用虚拟模型时的虚拟模型名，此时 original_model 为实际使用的目标模型。
 //   - request_path：请求路径
 //   - is_channel_test：是否为渠道测试请求（同 is_test）。
 func BuildParamOverrideContext(info *RelayInfo) map[string]interface{} {
@@ -591,6 +611,10 @@ func BuildParamOverrideContext(info *RelayInfo) map[string]interface{} {
 		}
 	}
 
+	if info.VirtualModelName != "" {
+		ctx["virtual_model"] = info.VirtualModelName
+	}
+
 	if info.RequestURLPath != "" {
 		requestPath := info.RequestURLPath
 		if requestPath != "" {
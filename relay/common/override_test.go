@@ -591,6 +591,31 @@ func TestApplyParamOverrideCopyMissingSource(t *testing.T) {
 	}
 }
 
+func TestApplyParamOverrideCopyIgnoresContextForRequests(t *testing.T) {
+	input := []byte(`{"temperature":0.7}`)
+	override := map[string]interface{}{
+		"operations": []interface{}{
+			map[string]interface{}{
+				"mode": "copy",
+				"from": "original_model",
+				"to":   "model",
+			},
+		},
+	}
+	conditionContext := map[string]interface{}{"original_model": "alias"}
+
+	// 请求改写只读取请求 JSON，源路径不存在时报错而不是从上下文取值
+	if _, err := ApplyParamOverride(input, override, conditionContext); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	out, err := applyParamOverride(input, override, conditionContext, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertJSONEqual(t, `{"model":"alias","temperature":0.7}`, string(out))
+}
+
 func TestApplyParamOverrideCopyRequiresFromTo(t *testing.T) {
 	// copy requires from/to example:
 	// {"operations":[{"mode":"copy"}]}
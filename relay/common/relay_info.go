@@ -109,6 +109,7 @@ type RelayInfo struct {
 	UsePrice               bool
 	RelayMode              int
 	OriginModelName        string
+	VirtualModelName       string // 客户端请求的虚拟模型名，此时 OriginModelName 为实际使用的目标模型
 	RequestURLPath         string
 	ShouldIncludeUsage     bool
 	DisablePing            bool // 是否禁止向下游发送自定义 Ping
@@ -126,7 +127,8 @@ type RelayInfo struct {
 	RelayFormat            types.RelayFormat
 	SendResponseCount      int
 	ReceivedResponseCount  int
-	FinalPreConsumedQuota  int // 最终预消耗的配额
+	FinalPreConsumedQuota  int        // 最终预消耗的配额
+	FinalUsage             *dto.Usage // 结算时的最终用量，供灰度/影子对比采样读取
 	// Billing 是计费会话，封装了预扣费/结算/退款的统一生命周期。
 	// 免费模型和按次计费（MJ/Task）时为 nil。
 	Billing BillingSettler
@@ -149,8 +151,16 @@ type RelayInfo struct {
 	// metadata across multi-hop conversions such as Responses -> Chat -> Claude
 	// and Claude -> Chat -> Responses.
 	OpenAIResponsesToolContext *OpenAIWireToolContext
+	// ResponsesStreamConverter 非 OpenAI 上游以 chat 流式块中转给 Responses 客户端时的转换状态
+	ResponsesStreamConverter OpenAIWireStreamConverter
 	// 最终请求到上游的格式 TODO: 当前仅设置了Claude
 	FinalRequestRelayFormat types.RelayFormat
+	// GatewayTools 网关托管工具的多轮调用状态；非空时各轮只累计用量，由工具循环结束后统一结算
+	GatewayTools *GatewayToolSession
+	// StructuredOutput 结构化输出模拟的多轮请求状态；非空时各轮只累计用量，由模拟流程结束后统一结算
+	StructuredOutput *StructuredOutputSession
+	// ToolCallEmulation 本次上游请求以提示词模拟工具调用时的解析状态；非空时响应中的文本工具调用会被还原为 tool_calls
+	ToolCallEmulation *ToolCallEmulationState
 
 	ThinkingContentInfo
 	TokenCountMeta
@@ -290,14 +300,17 @@ func (info *RelayInfo) ToString() string {
 
 // 定义支持流式选项的通道类型
 var streamSupportedChannels = map[int]bool{
-	constant.ChannelTypeOpenAI:    true,
-	constant.ChannelTypeAnthropic: true,
-	constant.ChannelTypeAws:       true,
-	constant.ChannelTypeGemini:    true,
-	constant.ChannelTypeAzure:     true,
-	constant.ChannelTypeOllama:    true,
-	constant.ChannelTypeDeepSeek:  true,
-	constant.ChannelTypeZhipu_v4:  true,
+	constant.ChannelTypeOpenAI:     true,
+	constant.ChannelTypeAnthropic:  true,
+	constant.ChannelTypeAws:        true,
+	constant.ChannelTypeGemini:     true,
+	constant.ChannelTypeAzure:      true,
+	constant.ChannelTypeOllama:     true,
+	constant.ChannelTypeDeepSeek:   true,
+	constant.ChannelTypeZhipu_v4:   true,
+	constant.ChannelTypeCohere:     true,
+	constant.ChannelTypeDashScope:  true,
+	constant.ChannelTypeSelfHosted: true,
 }
 
 func GenRelayInfoWs(c *gin.Context, ws *websocket.Conn) *RelayInfo {
@@ -343,6 +356,12 @@ func GenRelayInfoOpenAIAudio(c *gin.Context, request dto.Request) *RelayInfo {
 	return info
 }
 
+func GenRelayInfoVideo(c *gin.Context, request dto.Request) *RelayInfo {
+	info := genBaseRelayInfo(c, request)
+	info.RelayFormat = types.RelayFormatOpenAIVideo
+	return info
+}
+
 func GenRelayInfoEmbedding(c *gin.Context, request dto.Request) *RelayInfo {
 	info := genBaseRelayInfo(c, request)
 	info.RelayFormat = types.RelayFormatEmbedding
@@ -436,7 +455,8 @@ func genBaseRelayInfo(c *gin.Context, request dto.Request) *RelayInfo {
 		UserQuota:  common.GetContextKeyInt(c, constant.ContextKeyUserQuota),
 		UserEmail:  common.GetContextKeyString(c, constant.ContextKeyUserEmail),
 
-		OriginModelName: common.GetContextKeyString(c, constant.ContextKeyOriginalModel),
+		OriginModelName:  common.GetContextKeyString(c, constant.ContextKeyOriginalModel),
+		VirtualModelName: common.GetContextKeyString(c, constant.ContextKeyVirtualModel),
 
 		TokenId:        common.GetContextKeyInt(c, constant.ContextKeyTokenId),
 		TokenKey:       common.GetContextKeyString(c, constant.ContextKeyTokenKey),
@@ -491,6 +511,8 @@ func GenRelayInfo(c *gin.Context, relayFormat types.RelayFormat, request dto.Req
 		info = GenRelayInfoImage(c, request)
 	case types.RelayFormatOpenAIRealtime:
 		info = GenRelayInfoWs(c, ws)
+	case types.RelayFormatOpenAIVideo:
+		info = GenRelayInfoVideo(c, request)
 	case types.RelayFormatClaude:
 		info = GenRelayInfoClaude(c, request)
 	case types.RelayFormatRerank:
@@ -592,6 +614,14 @@ func (info *RelayInfo) HasSendResponse() bool {
 	return info.FirstResponseTime.After(info.StartTime)
 }
 
+// EnsureResponsesStreamConverter 返回本次请求的 chat→Responses 流式转换器，首次调用时创建
+func (info *RelayInfo) EnsureResponsesStreamConverter() OpenAIWireStreamConverter {
+	if info.ResponsesStreamConverter == nil {
+		info.ResponsesStreamConverter = NewChatToResponsesStreamConverter(info.OpenAIResponsesToolContext)
+	}
+	return info.ResponsesStreamConverter
+}
+
 // RemoveDisabledFields 从请求 JSON 数据中移除渠道设置中禁用的字段
 // service_tier: 服务层级字段，可能导致额外计费（OpenAI、Claude、Responses API 支持）
 // store: 数据存储授权字段，涉及用户隐私（仅 OpenAI、Responses API 支持，默认允许透传，禁用后可能导致 Codex 无法使用）
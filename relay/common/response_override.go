@@ -0,0 +1,118 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/zhongruan0522/new-api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ApplyResponseParamOverride 在适配器解析前按渠道 response_param_override 改写上游响应，
+// 非流式响应整体改写，流式响应逐个 chunk 改写；规则格式与条件引擎与请求侧 param_override 相同，
+// 另外 copy 的源路径在响应中不存在时从条件上下文取值（如 original_model）。
+// 流式 chunk 改写失败时记录日志并透传原 chunk。
+func ApplyResponseParamOverride(info *RelayInfo, resp *http.Response) error {
+	if resp == nil || resp.Body == nil || info == nil || info.ChannelMeta == nil {
+		return nil
+	}
+	override := info.ChannelOtherSettings.ResponseParamOverride
+	if len(override) == 0 {
+		return nil
+	}
+	conditionContext := BuildParamOverrideContext(info)
+
+	isStream := info.IsStream || strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+	if isStream {
+		resp.Body = newEventRewriteBody(resp.Body, func(data string) (string, bool) {
+			if !common.IsJsonObject(data) {
+				return data, false
+			}
+			out, err := applyParamOverride([]byte(data), override, conditionContext, true)
+			if err != nil {
+				common.SysError("apply response param override to stream chunk failed, forwarding original chunk: " + err.Error())
+				return data, false
+			}
+			return string(out), false
+		})
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("read response body failed: %w", err)
+	}
+	out, err := applyParamOverride(body, override, conditionContext, true)
+	if err != nil {
+		return fmt.Errorf("apply response param override failed: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(out))
+	resp.ContentLength = int64(len(out))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(out)))
+	return nil
+}
+
+// ApplyResponseHeaderOverride 按渠道 response_header_override 改写返回给客户端的响应头，
+// 返回的函数用于恢复原始 Writer。值为字符串时设置该头，为 null 或空字符串时删除该头。
+func ApplyResponseHeaderOverride(c *gin.Context, info *RelayInfo) func() {
+	if c == nil || info == nil || info.ChannelMeta == nil || len(info.ChannelOtherSettings.ResponseHeaderOverride) == 0 {
+		return func() {}
+	}
+	base := c.Writer
+	c.Writer = &responseHeaderOverrideWriter{ResponseWriter: base, headers: info.ChannelOtherSettings.ResponseHeaderOverride}
+	return func() { c.Writer = base }
+}
+
+// responseHeaderOverrideWriter 在响应头发出前应用覆盖，适配器写入的上游响应头同样会被覆盖
+type responseHeaderOverrideWriter struct {
+	gin.ResponseWriter
+	headers map[string]interface{}
+	applied bool
+}
+
+func (w *responseHeaderOverrideWriter) apply() {
+	if w.applied {
+		return
+	}
+	w.applied = true
+	header := w.ResponseWriter.Header()
+	for k, v := range w.headers {
+		value, _ := v.(string)
+		if value == "" {
+			header.Del(k)
+			continue
+		}
+		header.Set(k, value)
+	}
+}
+
+func (w *responseHeaderOverrideWriter) WriteHeader(code int) {
+	w.apply()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseHeaderOverrideWriter) WriteHeaderNow() {
+	w.apply()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *responseHeaderOverrideWriter) Write(p []byte) (int, error) {
+	w.apply()
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *responseHeaderOverrideWriter) WriteString(s string) (int, error) {
+	w.apply()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *responseHeaderOverrideWriter) Flush() {
+	w.apply()
+	w.ResponseWriter.Flush()
+}
@@ -0,0 +1,110 @@
+package common
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zhongruan0522/new-api/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 去除厂商字段、reasoning 重命名为 reasoning_content、model 改回客户端请求的别名
+var testResponseParamOverride = map[string]interface{}{
+	"operations": []interface{}{
+		map[string]interface{}{"path": "vendor_trace", "mode": "delete"},
+		map[string]interface{}{
+			"mode": "move",
+			"from": "choices.0.delta.reasoning",
+			"to":   "choices.0.delta.reasoning_content",
+			"conditions": []interface{}{
+				map[string]interface{}{"path": "choices.0.delta.reasoning", "mode": "exists"},
+			},
+		},
+		map[string]interface{}{"mode": "copy", "from": "original_model", "to": "model"},
+	},
+}
+
+func newResponseOverrideTestInfo(isStream bool) *RelayInfo {
+	return &RelayInfo{
+		OriginModelName: "alias",
+		IsStream:        isStream,
+		ChannelMeta: &ChannelMeta{
+			UpstreamModelName: "upstream",
+			ChannelOtherSettings: dto.ChannelOtherSettings{
+				ResponseParamOverride: testResponseParamOverride,
+				ResponseHeaderOverride: map[string]interface{}{
+					"X-Vendor-Id": nil,
+					"X-Gateway":   "new-api",
+				},
+			},
+		},
+	}
+}
+
+func TestApplyResponseParamOverride(t *testing.T) {
+	resp := &http.Response{
+		Header: http.Header{"Content-Type": []string{"application/json"}},
+		Body:   io.NopCloser(strings.NewReader(`{"model":"upstream","vendor_trace":"abc","choices":[{"delta":{"content":"hi"}}]}`)),
+	}
+	if err := ApplyResponseParamOverride(newResponseOverrideTestInfo(false), resp); err != nil {
+		t.Fatalf("ApplyResponseParamOverride error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	assertJSONEqual(t, `{"model":"alias","choices":[{"delta":{"content":"hi"}}]}`, string(body))
+	if resp.ContentLength != int64(len(body)) {
+		t.Fatalf("content-length = %d, want %d", resp.ContentLength, len(body))
+	}
+}
+
+func TestApplyResponseParamOverrideToStreamChunks(t *testing.T) {
+	upstream := "data: {\"model\":\"upstream\",\"vendor_trace\":\"abc\",\"choices\":[{\"delta\":{\"reasoning\":\"think\"}}]}\n\n" +
+		"data: {\"model\":\"upstream\",\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n" +
+		"data: PING\n\ndata: [DONE]\n\n"
+	resp := &http.Response{
+		Header: http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:   io.NopCloser(strings.NewReader(upstream)),
+	}
+	if err := ApplyResponseParamOverride(newResponseOverrideTestInfo(true), resp); err != nil {
+		t.Fatalf("ApplyResponseParamOverride error = %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read stream error = %v", err)
+	}
+	want := "data: {\"model\":\"alias\",\"choices\":[{\"delta\":{\"reasoning_content\":\"think\"}}]}\n\n" +
+		"data: {\"model\":\"alias\",\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n" +
+		"data: PING\n\ndata: [DONE]\n\n"
+	if string(body) != want {
+		t.Fatalf("stream = %q, want %q", body, want)
+	}
+}
+
+func TestApplyResponseHeaderOverride(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	base := c.Writer
+
+	restore := ApplyResponseHeaderOverride(c, newResponseOverrideTestInfo(false))
+	c.Writer.Header().Set("X-Vendor-Id", "v-1")
+	c.Writer.Header().Set("X-Request-Id", "r-1")
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+	restore()
+
+	if c.Writer != base {
+		t.Fatal("restore should put back the original writer")
+	}
+	if got := recorder.Header().Get("X-Vendor-Id"); got != "" {
+		t.Fatalf("X-Vendor-Id = %q, want removed", got)
+	}
+	if got := recorder.Header().Get("X-Gateway"); got != "new-api" {
+		t.Fatalf("X-Gateway = %q", got)
+	}
+	if got := recorder.Header().Get("X-Request-Id"); got != "r-1" {
+		t.Fatalf("X-Request-Id = %q", got)
+	}
+}
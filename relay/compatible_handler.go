@@ -31,6 +31,8 @@ import (
 
 func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types.NewAPIError) {
 	info.InitChannelMeta(c)
+	// RelayInfo 在重试间复用，上一次尝试的工具调用模拟状态不能带到新渠道
+	info.ToolCallEmulation = nil
 
 	textReq, ok := info.Request.(*dto.GeneralOpenAIRequest)
 	if !ok {
@@ -42,6 +44,10 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 		return types.NewError(fmt.Errorf("failed to copy request to GeneralOpenAIRequest: %w", err), types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
 	}
 
+	if _, err := resolveReasoningOutputMode(c); err != nil {
+		return types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+
 	if request.WebSearchOptions != nil {
 		c.Set("chat_completion_web_search_context_size", request.WebSearchOptions.SearchContextSize)
 	}
@@ -66,6 +72,7 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 		// 跟踪本次请求中新增存储的图片/视频数量（去重命中不计入）
 		newImageCount := 0
 		newVideoCount := 0
+		var storedImageIDs, storedVideoIDs []string
 
 		resolveURL := func(rawURL string, mediaContentType string) (string, error) {
 			rawURL = strings.TrimSpace(rawURL)
@@ -122,6 +129,7 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 			if isImage {
 				// Cross-request dedupe: same user + same sha -> reuse existing asset URL.
 				if existing, err := model.GetStoredImageByUserAndSha(c.Request.Context(), info.UserId, sha); err == nil && existing != nil && existing.Id != "" {
+					storedImageIDs = append(storedImageIDs, existing.Id)
 					u := buildStoredImageURL(c, existing.Id)
 					storedURLBySHA[cacheKey] = u
 					return u, nil
@@ -140,7 +148,9 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 				if err := img.Insert(c.Request.Context()); err != nil {
 					return "", types.NewError(fmt.Errorf("store image failed: %w", err), types.ErrorCodeUpdateDataError, types.ErrOptionWithSkipRetry())
 				}
-				if _, err := model.EnsureStoredImagesPoolLimit(c.Request.Context(), imagePoolMaxBytes, 100); err != nil {
+				// 本次请求已引用的图片不参与淘汰，避免后写入的图片挤掉前面刚生成的链接
+				storedImageIDs = append(storedImageIDs, img.Id)
+				if _, err := model.EnsureStoredImagesPoolLimit(c.Request.Context(), imagePoolMaxBytes, 100, storedImageIDs...); err != nil {
 					return "", types.NewError(fmt.Errorf("enforce stored image pool limit failed: %w", err), types.ErrorCodeUpdateDataError, types.ErrOptionWithSkipRetry())
 				}
 
@@ -151,6 +161,7 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 			}
 
 			if existing, err := model.GetStoredVideoByUserAndSha(c.Request.Context(), info.UserId, sha); err == nil && existing != nil && existing.Id != "" {
+				storedVideoIDs = append(storedVideoIDs, existing.Id)
 				u := buildStoredVideoURL(c, existing.Id)
 				storedURLBySHA[cacheKey] = u
 				return u, nil
@@ -169,7 +180,8 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 			if err := v.Insert(c.Request.Context()); err != nil {
 				return "", types.NewError(fmt.Errorf("store video failed: %w", err), types.ErrorCodeUpdateDataError, types.ErrOptionWithSkipRetry())
 			}
-			if _, err := model.EnsureStoredVideosPoolLimit(c.Request.Context(), videoPoolMaxBytes, 50); err != nil {
+			storedVideoIDs = append(storedVideoIDs, v.Id)
+			if _, err := model.EnsureStoredVideosPoolLimit(c.Request.Context(), videoPoolMaxBytes, 50, storedVideoIDs...); err != nil {
 				return "", types.NewError(fmt.Errorf("enforce stored video pool limit failed: %w", err), types.ErrorCodeUpdateDataError, types.ErrOptionWithSkipRetry())
 			}
 
@@ -263,6 +275,12 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 			}
 		}
 
+		// apply channel script
+		jsonData, err = relaycommon.ApplyChannelScriptToRequest(info, jsonData)
+		if err != nil {
+			return types.NewError(err, types.ErrorCodeChannelScriptFailed, types.ErrOptionWithSkipRetry())
+		}
+
 		logger.LogDebug(c, fmt.Sprintf("text request body: %s", string(jsonData)))
 
 		requestBody = bytes.NewBuffer(jsonData)
@@ -287,13 +305,42 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 		}
 	}
 
+	// apply response override
+	if err := relaycommon.ApplyResponseParamOverride(info, httpResp); err != nil {
+		return types.NewError(err, types.ErrorCodeChannelParamOverrideInvalid, types.ErrOptionWithSkipRetry())
+	}
+	defer relaycommon.ApplyResponseHeaderOverride(c, info)()
+
+	// apply channel script
+	if err := relaycommon.ApplyChannelScriptToResponse(info, httpResp); err != nil {
+		return types.NewError(err, types.ErrorCodeChannelScriptFailed, types.ErrOptionWithSkipRetry())
+	}
+
+	// restore emulated tool calls
+	if err := relaycommon.ApplyToolCallEmulationToResponse(info, httpResp); err != nil {
+		return types.NewError(err, types.ErrorCodeBadResponseBody, types.ErrOptionWithSkipRetry())
+	}
+
+	// normalize reasoning output for chat completions clients
+	finishReasoningOutput := wrapReasoningOutputWriter(c, info)
 	usage, newApiErr := adaptor.DoResponse(c, httpResp, info)
+	finishReasoningOutput()
 	if newApiErr != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(newApiErr, statusCodeMappingStr)
 		return newApiErr
 	}
 
+	// 网关工具循环中的中间轮次只累计用量，由循环结束后统一结算
+	if info.GatewayTools != nil && info.GatewayTools.Collecting {
+		info.GatewayTools.AddUsage(info.Billing, usage.(*dto.Usage))
+		return nil
+	}
+	if info.StructuredOutput != nil && info.StructuredOutput.Collecting {
+		info.StructuredOutput.AddUsage(usage.(*dto.Usage))
+		return nil
+	}
+
 	var containAudioTokens = usage.(*dto.Usage).CompletionTokenDetails.AudioTokens > 0 || usage.(*dto.Usage).PromptTokensDetails.AudioTokens > 0
 	var containsAudioRatios = ratio_setting.ContainsAudioRatio(info.OriginModelName) || ratio_setting.ContainsAudioCompletionRatio(info.OriginModelName)
 
@@ -319,10 +366,7 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 		}
 		extraContent = append(extraContent, "上游无计费信息")
 	}
-
-	if originUsage != nil {
-		service.ObserveChannelAffinityUsageCacheFromContext(ctx, usage)
-	}
+	relayInfo.FinalUsage = usage
 
 	adminRejectReason := common.GetContextKeyString(ctx, constant.ContextKeyAdminRejectReason)
 
@@ -357,6 +401,27 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 		}
 	}
 
+	if originUsage != nil {
+		uncachedTokens := promptTokens
+		if !isClaudeUsageSemantic {
+			uncachedTokens -= cacheTokens + cachedCreationTokens
+		}
+		// 与 PostClaudeConsumeQuota 一致，命中率按含缓存读写的全部输入计算
+		service.ObserveChannelAffinityUsageCacheFromContext(ctx, usage, uncachedTokens+cacheTokens+cachedCreationTokens)
+		promptRatio := modelRatio * groupRatio
+		if relayInfo.PriceData.UsePrice {
+			promptRatio = 0
+		}
+		service.ObserveCacheUsage(ctx, relayInfo, service.CacheUsageObservation{
+			UncachedTokens:           uncachedTokens,
+			CacheReadTokens:          cacheTokens,
+			CacheWriteTokens:         cachedCreationTokens,
+			CacheWriteWeightedTokens: float64(cachedCreationTokens) * cachedCreationRatio,
+			CacheRatio:               cacheRatio,
+			PromptRatio:              promptRatio,
+		})
+	}
+
 	// Convert values to decimal for precise calculation
 	dPromptTokens := decimal.NewFromInt(int64(promptTokens))
 	dCacheTokens := decimal.NewFromInt(int64(cacheTokens))
@@ -472,6 +537,14 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 		extraContent = append(extraContent, fmt.Sprintf("Image Generation Call 花费 %s", dImageGenerationCallQuota.String()))
 	}
 
+	// 网关托管工具计费（各次调用价格来自 ToolBillingRule）
+	var dGatewayToolQuota decimal.Decimal
+	if relayInfo.GatewayTools != nil && len(relayInfo.GatewayTools.Calls) > 0 {
+		dGatewayToolQuota = decimal.NewFromFloat(relayInfo.GatewayTools.TotalPrice()).Mul(dGroupRatio).Mul(dQuotaPerUnit)
+		extraContent = append(extraContent, fmt.Sprintf("网关工具调用 %d 次（%d 轮），调用花费 %s",
+			len(relayInfo.GatewayTools.Calls), relayInfo.GatewayTools.Turns, dGatewayToolQuota.String()))
+	}
+
 	var quotaCalculateDecimal decimal.Decimal
 
 	var audioInputQuota decimal.Decimal
@@ -528,6 +601,8 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 	quotaCalculateDecimal = quotaCalculateDecimal.Add(audioInputQuota)
 	// 添加 image generation call 计费
 	quotaCalculateDecimal = quotaCalculateDecimal.Add(dImageGenerationCallQuota)
+	// 添加网关工具调用计费
+	quotaCalculateDecimal = quotaCalculateDecimal.Add(dGatewayToolQuota)
 
 	if len(relayInfo.PriceData.OtherRatios) > 0 {
 		for key, otherRatio := range relayInfo.PriceData.OtherRatios {
@@ -552,7 +627,7 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 		}
 		// 上游没有返回 token 信息（可能是超时或错误），但如果有工具调用费用，仍需扣费
 		toolQuota := dWebSearchQuota.Add(dClaudeWebSearchQuota).Add(dGeminiWebSearchQuota).
-			Add(dFileSearchQuota).Add(dImageGenerationCallQuota).Add(audioInputQuota)
+			Add(dFileSearchQuota).Add(dImageGenerationCallQuota).Add(audioInputQuota).Add(dGatewayToolQuota)
 		if toolQuota.GreaterThan(decimal.Zero) {
 			quota = int(toolQuota.Round(0).IntPart())
 			extraContent = append(extraContent, "上游没有返回计费信息，但工具调用费用仍需扣除")
@@ -642,6 +717,18 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 		other["image_generation_call"] = true
 		other["image_generation_call_price"] = imageGenerationCallPrice
 	}
+	if relayInfo.GatewayTools != nil && relayInfo.GatewayTools.Turns > 0 {
+		other["gateway_tool_turns"] = relayInfo.GatewayTools.Turns
+		other["gateway_tool_trace"] = relayInfo.GatewayTools.Calls
+		other["gateway_tool_price"] = relayInfo.GatewayTools.TotalPrice()
+	}
+	if relayInfo.StructuredOutput != nil && relayInfo.StructuredOutput.Turns > 0 {
+		other["structured_output_emulation"] = relayInfo.StructuredOutput.Mode
+		other["structured_output_turns"] = relayInfo.StructuredOutput.Turns
+		if relayInfo.StructuredOutput.ValidationError != "" {
+			other["structured_output_error"] = relayInfo.StructuredOutput.ValidationError
+		}
+	}
 	// 共享流式日志指标，确保 OpenAI 兼容与 Claude 消费日志展示一致。
 	service.AppendStreamMetrics(other, relayInfo, useTimeMs, completionTokens)
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
@@ -27,6 +27,10 @@ const (
 	RelayModeGemini
 
 	RelayModeResponsesCompact
+
+	RelayModeVideoGenerations
+
+	RelayModeImagesVariations
 )
 
 func Path2RelayMode(path string) int {
@@ -45,6 +49,8 @@ func Path2RelayMode(path string) int {
 		relayMode = RelayModeImagesGenerations
 	} else if strings.HasPrefix(path, "/v1/images/edits") {
 		relayMode = RelayModeImagesEdits
+	} else if strings.HasPrefix(path, "/v1/images/variations") {
+		relayMode = RelayModeImagesVariations
 	} else if strings.HasPrefix(path, "/v1/edits") {
 		relayMode = RelayModeEdits
 	} else if strings.HasPrefix(path, "/v1/responses/compact") {
@@ -59,6 +65,8 @@ func Path2RelayMode(path string) int {
 		relayMode = RelayModeAudioTranslation
 	} else if strings.HasPrefix(path, "/v1/rerank") {
 		relayMode = RelayModeRerank
+	} else if strings.HasPrefix(path, "/v1/videos") {
+		relayMode = RelayModeVideoGenerations
 	} else if strings.HasPrefix(path, "/v1/realtime") {
 		relayMode = RelayModeRealtime
 	} else if strings.HasPrefix(path, "/v1beta/models") || strings.HasPrefix(path, "/v1/models") {
@@ -66,3 +74,12 @@ func Path2RelayMode(path string) int {
 	}
 	return relayMode
 }
+
+// IsImageRelayMode 判断是否为 OpenAI Images API（生成、编辑、变体）的请求
+func IsImageRelayMode(relayMode int) bool {
+	switch relayMode {
+	case RelayModeImagesGenerations, RelayModeImagesEdits, RelayModeImagesVariations:
+		return true
+	}
+	return false
+}
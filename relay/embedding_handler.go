@@ -58,6 +58,12 @@ func EmbeddingHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *
 		}
 	}
 
+	// apply channel script
+	jsonData, err = relaycommon.ApplyChannelScriptToRequest(info, jsonData)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeChannelScriptFailed, types.ErrOptionWithSkipRetry())
+	}
+
 	logger.LogDebug(c, fmt.Sprintf("converted embedding request body: %s", string(jsonData)))
 	requestBody := bytes.NewBuffer(jsonData)
 	statusCodeMappingStr := c.GetString("status_code_mapping")
@@ -77,6 +83,17 @@ func EmbeddingHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *
 		}
 	}
 
+	// apply response override
+	if err := relaycommon.ApplyResponseParamOverride(info, httpResp); err != nil {
+		return types.NewError(err, types.ErrorCodeChannelParamOverrideInvalid, types.ErrOptionWithSkipRetry())
+	}
+	defer relaycommon.ApplyResponseHeaderOverride(c, info)()
+
+	// apply channel script
+	if err := relaycommon.ApplyChannelScriptToResponse(info, httpResp); err != nil {
+		return types.NewError(err, types.ErrorCodeChannelScriptFailed, types.ErrOptionWithSkipRetry())
+	}
+
 	usage, newAPIError := adaptor.DoResponse(c, httpResp, info)
 	if newAPIError != nil {
 		// reset status code 重置状态码
@@ -0,0 +1,415 @@
+package relay
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/logger"
+	"github.com/zhongruan0522/new-api/model"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/relay/helper"
+	"github.com/zhongruan0522/new-api/service"
+	"github.com/zhongruan0522/new-api/setting/operation_setting"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GatewayToolsHeader 客户端通过该请求头按名称启用非自动注入的网关工具（逗号分隔）
+const GatewayToolsHeader = "X-Gateway-Tools"
+
+// 单次请求最多进行的 model→tool→model 轮数，超出后返回最后一轮结果（去掉其中的网关工具调用）
+const gatewayToolMaxTurns = 8
+
+// 写入日志 trace 的工具参数/输出最大长度
+const gatewayToolTraceMaxLen = 2000
+
+// resolveGatewayTools 返回本次请求需要注入的网关工具；客户端自带同名函数时以客户端为准
+func resolveGatewayTools(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) []*model.GatewayTool {
+	tools, err := model.GetEnabledGatewayTools()
+	if err != nil {
+		logger.LogError(c, "load gateway tools failed: "+err.Error())
+		return nil
+	}
+	if len(tools) == 0 {
+		return nil
+	}
+
+	requested := make(map[string]bool)
+	for _, name := range strings.Split(c.Request.Header.Get(GatewayToolsHeader), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			requested[name] = true
+		}
+	}
+	clientTools := make(map[string]bool, len(request.Tools))
+	for _, tool := range request.Tools {
+		clientTools[tool.Function.Name] = true
+	}
+
+	var selected []*model.GatewayTool
+	for _, tool := range tools {
+		if !tool.AutoInject && !requested[tool.Name] {
+			continue
+		}
+		if clientTools[tool.Name] || !tool.MatchModel(info.OriginModelName) {
+			continue
+		}
+		selected = append(selected, tool)
+	}
+	return selected
+}
+
+// relayChatWithGatewayTools 注入网关工具并在服务端循环执行 model→tool→model，
+// 各轮上游用量累加到计费会话，最终答案返回后统一结算一次。
+// 流式客户端的每一轮都以流式请求上游，文本增量实时转发，最终答案直接从上游流式返回
+func relayChatWithGatewayTools(c *gin.Context, info *relaycommon.RelayInfo, tools []*model.GatewayTool) *types.NewAPIError {
+	chatReq, ok := info.Request.(*dto.GeneralOpenAIRequest)
+	if !ok {
+		return types.NewErrorWithStatusCode(
+			fmt.Errorf("invalid request type, expected dto.GeneralOpenAIRequest, got %T", info.Request),
+			types.ErrorCodeInvalidRequest,
+			http.StatusBadRequest,
+			types.ErrOptionWithSkipRetry(),
+		)
+	}
+
+	request, err := common.DeepCopy(chatReq)
+	if err != nil {
+		return types.NewError(fmt.Errorf("failed to copy request to GeneralOpenAIRequest: %w", err), types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
+	}
+	clientStream := request.Stream
+	includeUsage := request.StreamOptions != nil && request.StreamOptions.IncludeUsage
+
+	toolByName := make(map[string]*model.GatewayTool, len(tools))
+	for _, tool := range tools {
+		toolByName[tool.Name] = tool
+		request.Tools = append(request.Tools, service.GatewayToolDefinition(tool))
+	}
+	// 用量与结束块由循环统一补发，流式轮次总是向上游要求用量
+	request.StreamOptions = nil
+	if clientStream {
+		request.StreamOptions = &dto.StreamOptions{IncludeUsage: true}
+	}
+
+	snapshot := takeRelayInfoSnapshot(info)
+	defer snapshot.restore(info)
+
+	bodySnap, err := takeRequestBodySnapshot(c)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeReadRequestBodyFailed, types.ErrOptionWithSkipRetry())
+	}
+	defer bodySnap.restore(c)
+
+	session := &relaycommon.GatewayToolSession{Collecting: true}
+	info.GatewayTools = session
+	defer func() { info.GatewayTools = nil }()
+
+	base := c.Writer
+	defer func() { c.Writer = base }()
+	var streamWriter *gatewayToolStreamWriter
+	if clientStream {
+		streamWriter = newGatewayToolStreamWriter(base)
+	}
+
+	for turn := 1; ; turn++ {
+		bodyBytes, err := common.Marshal(request)
+		if err != nil {
+			return failGatewayToolLoop(c, info, types.NewError(err, types.ErrorCodeJsonMarshalFailed, types.ErrOptionWithSkipRetry()))
+		}
+		setTemporaryRequestBody(c, bodyBytes)
+		info.Request = request
+		info.IsStream = clientStream
+
+		resp, newAPIError := relayGatewayToolTurn(c, info, base, streamWriter)
+		if newAPIError != nil {
+			return failGatewayToolLoop(c, info, newAPIError)
+		}
+		if len(resp.Choices) == 0 {
+			return finishGatewayToolLoop(c, info, resp, streamWriter, includeUsage)
+		}
+		message := resp.Choices[0].Message
+		toolCalls := message.ParseToolCalls()
+		if len(toolCalls) == 0 {
+			return finishGatewayToolLoop(c, info, resp, streamWriter, includeUsage)
+		}
+		if turn >= gatewayToolMaxTurns {
+			// 达到轮数上限：客户端无法执行网关工具，去掉这些调用并以 length 结束
+			stripGatewayToolCalls(resp, toolByName, constant.FinishReasonLength)
+			return finishGatewayToolLoop(c, info, resp, streamWriter, includeUsage)
+		}
+		if !allGatewayToolCalls(toolCalls, toolByName) {
+			// 同时调用了客户端自己的工具：只把客户端工具调用交还给客户端
+			stripGatewayToolCalls(resp, toolByName, constant.FinishReasonStop)
+			return finishGatewayToolLoop(c, info, resp, streamWriter, includeUsage)
+		}
+
+		message.Role = "assistant"
+		request.Messages = append(request.Messages, message)
+		for _, call := range toolCalls {
+			tool := toolByName[call.Function.Name]
+			start := time.Now()
+			output, execErr := service.ExecuteGatewayTool(c.Request.Context(), tool, call.Function.Arguments)
+			trace := relaycommon.GatewayToolCallTrace{
+				Turn:       turn,
+				CallId:     call.ID,
+				Name:       tool.Name,
+				Arguments:  truncateGatewayToolTrace(call.Function.Arguments),
+				DurationMs: time.Since(start).Milliseconds(),
+			}
+			if execErr != nil {
+				// 工具失败时把错误作为工具输出交给模型，由模型决定如何继续；失败的调用不计费
+				trace.Error = execErr.Error()
+				output = "Error: " + execErr.Error()
+				logger.LogWarn(c, fmt.Sprintf("gateway tool %s failed: %s", tool.Name, execErr.Error()))
+			} else {
+				trace.Output = truncateGatewayToolTrace(output)
+				if price, ok := operation_setting.GetGatewayToolBillingPrice(tool.Name, info.OriginModelName); ok {
+					trace.Price = price
+				}
+			}
+			session.Calls = append(session.Calls, trace)
+
+			toolMessage := dto.Message{Role: "tool", ToolCallId: call.ID}
+			toolMessage.SetStringContent(output)
+			request.Messages = append(request.Messages, toolMessage)
+		}
+	}
+}
+
+// relayGatewayToolTurn 执行一轮上游请求并返回该轮完整响应；流式轮次的文本增量已实时写给客户端
+func relayGatewayToolTurn(c *gin.Context, info *relaycommon.RelayInfo, base gin.ResponseWriter, streamWriter *gatewayToolStreamWriter) (*dto.OpenAITextResponse, *types.NewAPIError) {
+	if streamWriter != nil {
+		c.Writer = streamWriter
+		newAPIError := TextHelper(c, info)
+		c.Writer = base
+		if newAPIError != nil {
+			return nil, newAPIError
+		}
+		return streamWriter.takeTurn(), nil
+	}
+
+	captured := newOpenAIWireCaptureWriter(base)
+	c.Writer = captured
+	newAPIError := TextHelper(c, info)
+	c.Writer = base
+	if newAPIError != nil {
+		return nil, newAPIError
+	}
+	var resp dto.OpenAITextResponse
+	if err := common.Unmarshal(captured.BodyBytes(), &resp); err != nil {
+		return nil, types.NewError(fmt.Errorf("unmarshal chat completion response failed: %w", err), types.ErrorCodeBadResponseBody, types.ErrOptionWithSkipRetry())
+	}
+	return &resp, nil
+}
+
+func allGatewayToolCalls(calls []dto.ToolCallRequest, toolByName map[string]*model.GatewayTool) bool {
+	for _, call := range calls {
+		if _, ok := toolByName[call.Function.Name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// stripGatewayToolCalls 从各 choice 中去掉网关工具调用；去掉后不再有工具调用的 choice 以 finishReason 结束
+func stripGatewayToolCalls(resp *dto.OpenAITextResponse, toolByName map[string]*model.GatewayTool, finishReason string) {
+	for i := range resp.Choices {
+		choice := &resp.Choices[i]
+		calls := choice.ParseToolCalls()
+		if len(calls) == 0 {
+			continue
+		}
+		clientCalls := make([]dto.ToolCallRequest, 0, len(calls))
+		for _, call := range calls {
+			if _, ok := toolByName[call.Function.Name]; !ok {
+				clientCalls = append(clientCalls, call)
+			}
+		}
+		if len(clientCalls) == len(calls) {
+			continue
+		}
+		if len(clientCalls) == 0 {
+			choice.ToolCalls = nil
+			choice.FinishReason = finishReason
+			continue
+		}
+		choice.SetToolCalls(clientCalls)
+	}
+}
+
+func truncateGatewayToolTrace(s string) string {
+	if len(s) <= gatewayToolTraceMaxLen {
+		return s
+	}
+	return s[:gatewayToolTraceMaxLen] + "...[truncated]"
+}
+
+// failGatewayToolLoop 已完成至少一轮时先结算已产生的用量与工具费用，再返回错误且不重试
+func failGatewayToolLoop(c *gin.Context, info *relaycommon.RelayInfo, newAPIError *types.NewAPIError) *types.NewAPIError {
+	session := info.GatewayTools
+	if session == nil || session.Turns == 0 {
+		return newAPIError
+	}
+	session.Collecting = false
+	usage := session.Usage(info.Billing)
+	if apiErr := postConsumeQuota(c, info, &usage, fmt.Sprintf("网关工具循环第 %d 轮失败", session.Turns+1)); apiErr != nil {
+		logger.LogError(c, "settle gateway tool loop failed: "+apiErr.Error())
+	}
+	types.ErrOptionWithSkipRetry()(newAPIError)
+	return newAPIError
+}
+
+// finishGatewayToolLoop 将最终答案按客户端要求的格式返回，并以累计用量结算；
+// 流式客户端的文本已在各轮实时转发，这里只补发工具调用、结束块与用量
+func finishGatewayToolLoop(c *gin.Context, info *relaycommon.RelayInfo, resp *dto.OpenAITextResponse, streamWriter *gatewayToolStreamWriter, includeUsage bool) *types.NewAPIError {
+	session := info.GatewayTools
+	session.Collecting = false
+	usage := session.Usage(info.Billing)
+	resp.Usage = usage
+
+	var err error
+	if streamWriter != nil {
+		err = writeGatewayToolStreamTail(c, streamWriter, resp, includeUsage)
+	} else {
+		err = writeCollectedChatResponse(c, resp, false, includeUsage)
+	}
+	if err != nil {
+		logger.LogError(c, "write gateway tool response failed: "+err.Error())
+	}
+
+	info.IsStream = streamWriter != nil
+	return postConsumeQuota(c, info, &usage)
+}
+
+// writeCollectedChatResponse 将多轮流程收集到的非流式结果按客户端要求以 JSON 或 SSE 返回
+func writeCollectedChatResponse(c *gin.Context, resp *dto.OpenAITextResponse, clientStream bool, includeUsage bool) error {
+	if clientStream {
+		return writeGatewayToolStreamResponse(c, resp, includeUsage)
+	}
+	body, err := common.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.WriteHeader(http.StatusOK)
+	_, err = c.Writer.Write(body)
+	return err
+}
+
+// writeGatewayToolStreamResponse 将非流式的最终答案拆成 chat.completion.chunk 事件返回
+func writeGatewayToolStreamResponse(c *gin.Context, resp *dto.OpenAITextResponse, includeUsage bool) error {
+	helper.SetEventStreamHeaders(c)
+	id := resp.Id
+	if id == "" {
+		id = helper.GetResponseID(c)
+	}
+	created := common.GetTimestamp()
+	if v, ok := resp.Created.(float64); ok {
+		created = int64(v)
+	}
+
+	for _, choice := range resp.Choices {
+		delta := dto.ChatCompletionsStreamResponseChoiceDelta{Role: "assistant"}
+		if choice.ReasoningContent != "" {
+			delta.ReasoningContent = common.GetPointer(choice.ReasoningContent)
+		}
+		delta.SetContentString(choice.StringContent())
+		delta.ToolCalls = toolCallStreamDeltas(choice.ParseToolCalls())
+		finishReason := choice.FinishReason
+		chunk := &dto.ChatCompletionsStreamResponse{
+			Id:      id,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   resp.Model,
+			Choices: []dto.ChatCompletionsStreamResponseChoice{
+				{Index: choice.Index, Delta: delta},
+			},
+		}
+		if err := helper.ObjectData(c, chunk); err != nil {
+			return err
+		}
+		stop := helper.GenerateStopResponse(id, created, resp.Model, finishReason)
+		stop.Choices[0].Index = choice.Index
+		if err := helper.ObjectData(c, stop); err != nil {
+			return err
+		}
+	}
+	if includeUsage {
+		if err := helper.ObjectData(c, helper.GenerateFinalUsageResponse(id, created, resp.Model, resp.Usage)); err != nil {
+			return err
+		}
+	}
+	helper.Done(c)
+	return nil
+}
+
+// writeGatewayToolStreamTail 流式客户端的结尾：补发被拦下的客户端工具调用、各 choice 的结束块、累计用量与 [DONE]
+func writeGatewayToolStreamTail(c *gin.Context, w *gatewayToolStreamWriter, resp *dto.OpenAITextResponse, includeUsage bool) error {
+	helper.SetEventStreamHeaders(c)
+	id := w.id
+	if id == "" {
+		id = helper.GetResponseID(c)
+	}
+	created := w.created
+	if created == 0 {
+		created = common.GetTimestamp()
+	}
+
+	for _, choice := range resp.Choices {
+		if calls := choice.ParseToolCalls(); len(calls) > 0 {
+			delta := dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: toolCallStreamDeltas(calls)}
+			if !w.roleSent[choice.Index] {
+				delta.Role = "assistant"
+			}
+			chunk := &dto.ChatCompletionsStreamResponse{
+				Id:      id,
+				Object:  "chat.completion.chunk",
+				Created: created,
+				Model:   w.model,
+				Choices: []dto.ChatCompletionsStreamResponseChoice{
+					{Index: choice.Index, Delta: delta},
+				},
+			}
+			if err := helper.ObjectData(c, chunk); err != nil {
+				return err
+			}
+		}
+		finishReason := choice.FinishReason
+		if finishReason == "" {
+			finishReason = constant.FinishReasonStop
+		}
+		stop := helper.GenerateStopResponse(id, created, w.model, finishReason)
+		stop.Choices[0].Index = choice.Index
+		if err := helper.ObjectData(c, stop); err != nil {
+			return err
+		}
+	}
+	if includeUsage {
+		if err := helper.ObjectData(c, helper.GenerateFinalUsageResponse(id, created, w.model, resp.Usage)); err != nil {
+			return err
+		}
+	}
+	helper.Done(c)
+	return nil
+}
+
+func toolCallStreamDeltas(calls []dto.ToolCallRequest) []dto.ToolCallResponse {
+	deltas := make([]dto.ToolCallResponse, 0, len(calls))
+	for i, call := range calls {
+		deltas = append(deltas, dto.ToolCallResponse{
+			Index: common.GetPointer(i),
+			ID:    call.ID,
+			Type:  call.Type,
+			Function: dto.FunctionResponse{
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+			},
+		})
+	}
+	return deltas
+}
@@ -0,0 +1,156 @@
+package relay
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/model"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newGatewayToolTestContext() (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	return c, recorder
+}
+
+func TestGatewayToolStreamWriterForwardsTextAndHoldsToolCalls(t *testing.T) {
+	c, recorder := newGatewayToolTestContext()
+	w := newGatewayToolStreamWriter(c.Writer)
+
+	turn1 := `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"role":"assistant","content":"Let me check. "}}]}` + "\n\n" +
+		`data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"web_search","arguments":"{\"q\":"}}]}}]}` + "\n\n" +
+		`data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"go\"}"}}]}}]}` + "\n\n" +
+		`data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}` + "\n\n" +
+		`data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"m","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}` + "\n\n" +
+		"data: [DONE]\n\n"
+	if _, err := w.WriteString(turn1); err != nil {
+		t.Fatalf("write turn 1: %v", err)
+	}
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"content":"Let me check. "`) || !strings.Contains(body, `"role":"assistant"`) {
+		t.Fatalf("text delta should be forwarded, got %s", body)
+	}
+	for _, leaked := range []string{"tool_calls", "finish_reason\":\"", "usage\":{", "[DONE]"} {
+		if strings.Contains(body, leaked) {
+			t.Fatalf("%s should be held back, got %s", leaked, body)
+		}
+	}
+
+	resp := w.takeTurn()
+	if len(resp.Choices) != 1 || resp.Choices[0].FinishReason != constant.FinishReasonToolCalls {
+		t.Fatalf("turn 1 response = %+v", resp)
+	}
+	calls := resp.Choices[0].ParseToolCalls()
+	if len(calls) != 1 || calls[0].ID != "call_1" || calls[0].Function.Name != "web_search" || calls[0].Function.Arguments != `{"q":"go"}` {
+		t.Fatalf("tool calls = %+v", calls)
+	}
+
+	// 第二轮使用上游新的 id，转发时仍沿用第一轮的 id，且不再重复发送 role
+	recorder.Body.Reset()
+	turn2 := `data: {"id":"chatcmpl-2","object":"chat.completion.chunk","created":2,"model":"m","choices":[{"index":0,"delta":{"role":"assistant","content":"Go is a language."},"finish_reason":"stop"}]}` + "\n\n"
+	if _, err := w.WriteString(turn2); err != nil {
+		t.Fatalf("write turn 2: %v", err)
+	}
+	body = recorder.Body.String()
+	if !strings.Contains(body, `"id":"chatcmpl-1"`) || strings.Contains(body, `"role"`) {
+		t.Fatalf("turn 2 chunk = %s", body)
+	}
+	resp = w.takeTurn()
+	if resp.Choices[0].StringContent() != "Go is a language." || resp.Choices[0].FinishReason != constant.FinishReasonStop {
+		t.Fatalf("turn 2 response = %+v", resp.Choices[0])
+	}
+}
+
+func TestStripGatewayToolCalls(t *testing.T) {
+	toolByName := map[string]*model.GatewayTool{"web_search": {Name: "web_search"}}
+	newResp := func(calls ...dto.ToolCallRequest) *dto.OpenAITextResponse {
+		message := dto.Message{Role: "assistant"}
+		message.SetToolCalls(calls)
+		return &dto.OpenAITextResponse{Choices: []dto.OpenAITextResponseChoice{
+			{Message: message, FinishReason: constant.FinishReasonToolCalls},
+		}}
+	}
+	gatewayCall := dto.ToolCallRequest{ID: "call_g", Type: "function", Function: dto.FunctionRequest{Name: "web_search"}}
+	clientCall := dto.ToolCallRequest{ID: "call_c", Type: "function", Function: dto.FunctionRequest{Name: "get_weather"}}
+
+	mixed := newResp(gatewayCall, clientCall)
+	stripGatewayToolCalls(mixed, toolByName, constant.FinishReasonStop)
+	calls := mixed.Choices[0].ParseToolCalls()
+	if len(calls) != 1 || calls[0].ID != "call_c" || mixed.Choices[0].FinishReason != constant.FinishReasonToolCalls {
+		t.Fatalf("mixed turn should keep only client calls, got %+v finish=%s", calls, mixed.Choices[0].FinishReason)
+	}
+
+	gatewayOnly := newResp(gatewayCall)
+	stripGatewayToolCalls(gatewayOnly, toolByName, constant.FinishReasonLength)
+	if len(gatewayOnly.Choices[0].ParseToolCalls()) != 0 || gatewayOnly.Choices[0].FinishReason != constant.FinishReasonLength {
+		t.Fatalf("gateway-only turn should end with length, got %+v", gatewayOnly.Choices[0])
+	}
+}
+
+func TestWriteGatewayToolStreamTail(t *testing.T) {
+	c, recorder := newGatewayToolTestContext()
+	w := newGatewayToolStreamWriter(c.Writer)
+	w.id, w.created, w.model = "chatcmpl-1", 1, "m"
+	w.roleSent[0] = true
+
+	message := dto.Message{Role: "assistant"}
+	message.SetToolCalls([]dto.ToolCallRequest{{ID: "call_c", Type: "function", Function: dto.FunctionRequest{Name: "get_weather", Arguments: "{}"}}})
+	resp := &dto.OpenAITextResponse{
+		Choices: []dto.OpenAITextResponseChoice{{Message: message, FinishReason: constant.FinishReasonToolCalls}},
+		Usage:   dto.Usage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5},
+	}
+	if err := writeGatewayToolStreamTail(c, w, resp, true); err != nil {
+		t.Fatalf("writeGatewayToolStreamTail returned error: %v", err)
+	}
+
+	body := recorder.Body.String()
+	for _, want := range []string{`"name":"get_weather"`, `"finish_reason":"tool_calls"`, `"total_tokens":5`, "data: [DONE]"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("stream tail missing %s: %s", want, body)
+		}
+	}
+	if strings.Contains(body, `"role"`) {
+		t.Fatalf("role was already sent, got %s", body)
+	}
+}
+
+type gatewayToolTestBilling struct {
+	relaycommon.BillingSettler
+	usage dto.Usage
+}
+
+func (b *gatewayToolTestBilling) AddUsage(usage *dto.Usage) {
+	relaycommon.AccumulateUsage(&b.usage, usage)
+}
+
+func (b *gatewayToolTestBilling) GetUsage() dto.Usage {
+	return b.usage
+}
+
+func TestGatewayToolSessionAccumulatesUsageInBilling(t *testing.T) {
+	billing := &gatewayToolTestBilling{}
+	session := &relaycommon.GatewayToolSession{Collecting: true}
+	session.AddUsage(billing, &dto.Usage{PromptTokens: 3, CompletionTokens: 1, TotalTokens: 4})
+	session.AddUsage(billing, &dto.Usage{PromptTokens: 5, CompletionTokens: 2, TotalTokens: 7})
+
+	if session.Turns != 2 || billing.usage.TotalTokens != 11 || session.Usage(billing).PromptTokens != 8 {
+		t.Fatalf("turns=%d billing usage=%+v", session.Turns, billing.usage)
+	}
+
+	// 免费模型没有计费会话时记在工具会话上
+	free := &relaycommon.GatewayToolSession{Collecting: true}
+	free.AddUsage(nil, &dto.Usage{PromptTokens: 2, TotalTokens: 2})
+	if free.Usage(nil).TotalTokens != 2 {
+		t.Fatalf("unbilled usage = %+v", free.Usage(nil))
+	}
+}
@@ -0,0 +1,195 @@
+package relay
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gatewayToolStreamChoice 一轮流式响应中单个 choice 的累积结果
+type gatewayToolStreamChoice struct {
+	content      strings.Builder
+	reasoning    strings.Builder
+	toolCalls    []dto.ToolCallRequest
+	finishReason string
+}
+
+// gatewayToolStreamWriter 网关工具循环中流式客户端的写入器：
+// 文本与推理增量立即转发给客户端，工具调用增量、结束块、用量块与 [DONE] 被拦下并累积，
+// 每轮结束后由工具循环决定执行网关工具还是补发结尾
+type gatewayToolStreamWriter struct {
+	gin.ResponseWriter
+
+	pending []byte
+	lastErr error
+
+	// 多轮共用同一个 id/created/model，客户端看到的是一条连续的流
+	id      string
+	created int64
+	model   string
+	choices map[int]*gatewayToolStreamChoice
+	// roleSent 跨轮记录各 choice 是否已发送过 role
+	roleSent map[int]bool
+}
+
+func newGatewayToolStreamWriter(base gin.ResponseWriter) *gatewayToolStreamWriter {
+	return &gatewayToolStreamWriter{
+		ResponseWriter: base,
+		choices:        make(map[int]*gatewayToolStreamChoice),
+		roleSent:       make(map[int]bool),
+	}
+}
+
+func (w *gatewayToolStreamWriter) Write(p []byte) (int, error) {
+	if w.lastErr != nil {
+		return 0, w.lastErr
+	}
+	w.pending = append(w.pending, p...)
+	for {
+		frame, rest, ok := splitSSEFrame(w.pending)
+		if !ok {
+			break
+		}
+		w.pending = rest
+
+		_, data, _, _ := parseSSEFrame(frame)
+		if err := w.handleData(strings.TrimSpace(data)); err != nil {
+			w.lastErr = err
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *gatewayToolStreamWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *gatewayToolStreamWriter) handleData(data string) error {
+	if data == "" || data == "[DONE]" {
+		return nil
+	}
+	var chunk dto.ChatCompletionsStreamResponse
+	if err := common.UnmarshalJsonStr(data, &chunk); err != nil {
+		// 无法解析的帧不影响工具循环，忽略
+		return nil
+	}
+	if w.id == "" {
+		w.id = chunk.Id
+	}
+	if w.created == 0 {
+		w.created = chunk.Created
+	}
+	if w.model == "" {
+		w.model = chunk.Model
+	}
+
+	forward := make([]dto.ChatCompletionsStreamResponseChoice, 0, len(chunk.Choices))
+	for _, choice := range chunk.Choices {
+		acc := w.choice(choice.Index)
+		delta := choice.Delta
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			acc.finishReason = *choice.FinishReason
+		}
+		for _, call := range delta.ToolCalls {
+			acc.addToolCallDelta(call)
+		}
+
+		content := delta.GetContentString()
+		reasoning := delta.GetReasoningContent()
+		acc.content.WriteString(content)
+		acc.reasoning.WriteString(reasoning)
+		if content == "" && reasoning == "" {
+			continue
+		}
+		out := dto.ChatCompletionsStreamResponseChoiceDelta{
+			Content:          delta.Content,
+			ReasoningContent: delta.ReasoningContent,
+			Reasoning:        delta.Reasoning,
+		}
+		if !w.roleSent[choice.Index] {
+			out.Role = "assistant"
+			w.roleSent[choice.Index] = true
+		}
+		forward = append(forward, dto.ChatCompletionsStreamResponseChoice{Index: choice.Index, Delta: out})
+	}
+	if len(forward) == 0 {
+		return nil
+	}
+	body, err := common.Marshal(&dto.ChatCompletionsStreamResponse{
+		Id:      w.id,
+		Object:  "chat.completion.chunk",
+		Created: w.created,
+		Model:   w.model,
+		Choices: forward,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.ResponseWriter.Write([]byte("data: " + string(body) + "\n\n"))
+	w.ResponseWriter.Flush()
+	return err
+}
+
+func (w *gatewayToolStreamWriter) choice(index int) *gatewayToolStreamChoice {
+	acc, ok := w.choices[index]
+	if !ok {
+		acc = &gatewayToolStreamChoice{}
+		w.choices[index] = acc
+	}
+	return acc
+}
+
+func (c *gatewayToolStreamChoice) addToolCallDelta(delta dto.ToolCallResponse) {
+	index := len(c.toolCalls) - 1
+	if delta.Index != nil {
+		index = *delta.Index
+	} else if delta.ID != "" || index < 0 {
+		index = len(c.toolCalls)
+	}
+	for len(c.toolCalls) <= index {
+		c.toolCalls = append(c.toolCalls, dto.ToolCallRequest{Type: "function"})
+	}
+	call := &c.toolCalls[index]
+	if delta.ID != "" {
+		call.ID = delta.ID
+	}
+	if typ, ok := delta.Type.(string); ok && typ != "" {
+		call.Type = typ
+	}
+	call.Function.Name += delta.Function.Name
+	call.Function.Arguments += delta.Function.Arguments
+}
+
+// takeTurn 将本轮累积的增量组装为非流式响应，供工具循环按与非流式相同的逻辑处理，并清空本轮状态
+func (w *gatewayToolStreamWriter) takeTurn() *dto.OpenAITextResponse {
+	resp := &dto.OpenAITextResponse{
+		Id:      w.id,
+		Model:   w.model,
+		Object:  "chat.completion",
+		Created: w.created,
+	}
+	indexes := make([]int, 0, len(w.choices))
+	for index := range w.choices {
+		indexes = append(indexes, index)
+	}
+	slices.Sort(indexes)
+	for _, index := range indexes {
+		acc := w.choices[index]
+		message := dto.Message{Role: "assistant", Content: acc.content.String(), ReasoningContent: acc.reasoning.String()}
+		if len(acc.toolCalls) > 0 {
+			message.SetToolCalls(acc.toolCalls)
+		}
+		resp.Choices = append(resp.Choices, dto.OpenAITextResponseChoice{
+			Index:        index,
+			Message:      message,
+			FinishReason: acc.finishReason,
+		})
+	}
+	w.choices = make(map[int]*gatewayToolStreamChoice)
+	return resp
+}
@@ -85,6 +85,12 @@ func GeminiHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *typ
 			}
 		}
 
+		// apply channel script
+		jsonData, err = relaycommon.ApplyChannelScriptToRequest(info, jsonData)
+		if err != nil {
+			return types.NewError(err, types.ErrorCodeChannelScriptFailed, types.ErrOptionWithSkipRetry())
+		}
+
 		logger.LogDebug(c, "Gemini request body: "+string(jsonData))
 
 		requestBody = bytes.NewReader(jsonData)
@@ -110,6 +116,17 @@ func GeminiHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *typ
 		}
 	}
 
+	// apply response override
+	if err := relaycommon.ApplyResponseParamOverride(info, httpResp); err != nil {
+		return types.NewError(err, types.ErrorCodeChannelParamOverrideInvalid, types.ErrOptionWithSkipRetry())
+	}
+	defer relaycommon.ApplyResponseHeaderOverride(c, info)()
+
+	// apply channel script
+	if err := relaycommon.ApplyChannelScriptToResponse(info, httpResp); err != nil {
+		return types.NewError(err, types.ErrorCodeChannelScriptFailed, types.ErrOptionWithSkipRetry())
+	}
+
 	usage, openaiErr := adaptor.DoResponse(c, resp.(*http.Response), info)
 	if openaiErr != nil {
 		service.ResetStatusCode(openaiErr, statusCodeMappingStr)
@@ -47,6 +47,8 @@ func GetAndValidateRequest(c *gin.Context, format types.RelayFormat) (request dt
 		request, err = GetAndValidAudioRequest(c, relayMode)
 	case types.RelayFormatOpenAIRealtime:
 		request = &dto.BaseRequest{}
+	case types.RelayFormatOpenAIVideo:
+		request, err = GetAndValidateVideoRequest(c)
 	default:
 		return nil, fmt.Errorf("unsupported relay format: %s", format)
 	}
@@ -75,6 +77,23 @@ func GetAndValidAudioRequest(c *gin.Context, relayMode int) (*dto.AudioRequest,
 	return audioRequest, nil
 }
 
+func GetAndValidateVideoRequest(c *gin.Context) (*dto.VideoRequest, error) {
+	videoRequest := &dto.VideoRequest{}
+	if err := common.UnmarshalBodyReusable(c, videoRequest); err != nil {
+		return nil, err
+	}
+	if videoRequest.Model == "" {
+		return nil, errors.New("model is required")
+	}
+	if strings.TrimSpace(videoRequest.Prompt) == "" {
+		return nil, errors.New("prompt is required")
+	}
+	if videoRequest.Seconds < 0 {
+		return nil, errors.New("seconds must be positive")
+	}
+	return videoRequest, nil
+}
+
 func GetAndValidateRerankRequest(c *gin.Context) (*dto.RerankRequest, error) {
 	var rerankRequest *dto.RerankRequest
 	err := common.UnmarshalBodyReusable(c, &rerankRequest)
@@ -142,7 +161,7 @@ func GetAndValidOpenAIImageRequest(c *gin.Context, relayMode int) (*dto.ImageReq
 	imageRequest := &dto.ImageRequest{}
 
 	switch relayMode {
-	case relayconstant.RelayModeImagesEdits:
+	case relayconstant.RelayModeImagesEdits, relayconstant.RelayModeImagesVariations:
 		if strings.Contains(c.Request.Header.Get("Content-Type"), "multipart/form-data") {
 			_, err := c.MultipartForm()
 			if err != nil {
@@ -154,9 +173,13 @@ func GetAndValidOpenAIImageRequest(c *gin.Context, relayMode int) (*dto.ImageReq
 			imageRequest.N = uint(common.String2Int(formData.Get("n")))
 			imageRequest.Quality = formData.Get("quality")
 			imageRequest.Size = formData.Get("size")
+			imageRequest.ResponseFormat = formData.Get("response_format")
 			if imageValue := formData.Get("image"); imageValue != "" {
 				imageRequest.Image, _ = json.Marshal(imageValue)
 			}
+			if relayMode == relayconstant.RelayModeImagesVariations && imageRequest.Model == "" {
+				imageRequest.Model = "dall-e-2"
+			}
 
 			if imageRequest.Model == "gpt-image-1" {
 				if imageRequest.Quality == "" {
@@ -13,9 +13,11 @@ import (
 	relaycommon "github.com/zhongruan0522/new-api/relay/common"
 	"github.com/zhongruan0522/new-api/relay/helper"
 	"github.com/zhongruan0522/new-api/service"
+	"github.com/zhongruan0522/new-api/setting/operation_setting"
 	"github.com/zhongruan0522/new-api/types"
 
 	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
 )
 
 func ImageHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types.NewAPIError) {
@@ -61,6 +63,8 @@ func ImageHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *type
 		case *bytes.Buffer:
 			requestBody = convertedRequest.(io.Reader)
 		default:
+			// multipart 的编辑 / 变体请求被转换为 JSON 后，需要同步修正转发给上游的 Content-Type
+			c.Request.Header.Set("Content-Type", "application/json")
 			jsonData, err := common.Marshal(convertedRequest)
 			if err != nil {
 				return types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
@@ -74,6 +78,12 @@ func ImageHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *type
 				}
 			}
 
+			// apply channel script
+			jsonData, err = relaycommon.ApplyChannelScriptToRequest(info, jsonData)
+			if err != nil {
+				return types.NewError(err, types.ErrorCodeChannelScriptFailed, types.ErrOptionWithSkipRetry())
+			}
+
 			if common.DebugEnabled {
 				logger.LogDebug(c, fmt.Sprintf("image request body: %s", string(jsonData)))
 			}
@@ -99,13 +109,51 @@ func ImageHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *type
 		}
 	}
 
+	// apply response override
+	if err := relaycommon.ApplyResponseParamOverride(info, httpResp); err != nil {
+		return types.NewError(err, types.ErrorCodeChannelParamOverrideInvalid, types.ErrOptionWithSkipRetry())
+	}
+	defer relaycommon.ApplyResponseHeaderOverride(c, info)()
+
+	// apply channel script
+	if err := relaycommon.ApplyChannelScriptToResponse(info, httpResp); err != nil {
+		return types.NewError(err, types.ErrorCodeChannelScriptFailed, types.ErrOptionWithSkipRetry())
+	}
+
+	// 非流式响应先捕获下来，以便把 b64_json 转存为 url 并统计实际生成的图片数量
+	var captured *openAIWireCaptureWriter
+	if !info.IsStream {
+		captured = newOpenAIWireCaptureWriter(c.Writer)
+		c.Writer = captured
+	}
 	usage, newAPIError := adaptor.DoResponse(c, httpResp, info)
+	if captured != nil {
+		c.Writer = captured.ResponseWriter
+	}
 	if newAPIError != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(newAPIError, statusCodeMappingStr)
 		return newAPIError
 	}
 
+	imageCount := int(request.N)
+	if captured != nil {
+		body := captured.BodyBytes()
+		if count := gjson.GetBytes(body, "data.#").Int(); count > 0 {
+			imageCount = int(count)
+		}
+		if request.ResponseFormat == "url" {
+			body, newAPIError = convertImageResponseToStoredURLs(c, info, body)
+			if newAPIError != nil {
+				return newAPIError
+			}
+		}
+		copyHeaders(c.Writer.Header(), captured.Header())
+		c.Writer.Header().Del("Content-Length")
+		c.Writer.WriteHeader(captured.Status())
+		_, _ = c.Writer.Write(body)
+	}
+
 	if usage.(*dto.Usage).TotalTokens == 0 {
 		usage.(*dto.Usage).TotalTokens = int(request.N)
 	}
@@ -130,6 +178,18 @@ func ImageHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *type
 		logContent = append(logContent, fmt.Sprintf("生成数量 %d", request.N))
 	}
 
+	// 命中工具计费规则时按图计费，规则中未填写的品质与尺寸视为通配；优先于模型倍率与固定价格
+	if imageCount > 0 {
+		if pricePerImage, ok := operation_setting.GetToolBillingPrice(operation_setting.ToolTypeImageGeneration, info.OriginModelName, adaptor.GetChannelName(), request.Quality, request.Size); ok {
+			info.PriceData.UsePrice = true
+			info.PriceData.ModelPrice = pricePerImage * float64(imageCount)
+			actualQuota := int(info.PriceData.ModelPrice * common.QuotaPerUnit * info.PriceData.GroupRatioInfo.GroupRatio)
+			// 预扣费按模型倍率估算，结算时按实际张数与单价补扣或返还差额
+			logContent = append(logContent, fmt.Sprintf("按图计费 %d 张，单价 $%.4f，预扣 %s，结算差额 %s", imageCount, pricePerImage,
+				logger.FormatQuota(info.FinalPreConsumedQuota), logger.FormatQuota(actualQuota-info.FinalPreConsumedQuota)))
+		}
+	}
+
 	if apiErr := postConsumeQuota(c, info, usage.(*dto.Usage), logContent...); apiErr != nil {
 		return apiErr
 	}
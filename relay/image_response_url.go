@@ -0,0 +1,85 @@
+package relay
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/model"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"gorm.io/gorm"
+)
+
+// convertImageResponseToStoredURLs 将 Images API 响应中的 b64_json 存入图片池，并替换为带签名的 url。
+// 只有上游原生不支持 url（例如 Gemini、gpt-image 系列）时才会出现这种情况。
+func convertImageResponseToStoredURLs(c *gin.Context, info *relaycommon.RelayInfo, body []byte) ([]byte, *types.NewAPIError) {
+	items := gjson.GetBytes(body, "data")
+	if !items.IsArray() {
+		return body, nil
+	}
+	poolMaxBytes := int64(constant.StoredImagePoolMB) * 1024 * 1024
+	newImageCount := 0
+	imageIDs := make([]string, 0, len(items.Array()))
+
+	var err error
+	for i, item := range items.Array() {
+		b64 := strings.TrimSpace(item.Get("b64_json").String())
+		if b64 == "" || item.Get("url").String() != "" {
+			continue
+		}
+		data, decodeErr := base64.StdEncoding.DecodeString(b64)
+		if decodeErr != nil {
+			return nil, types.NewError(fmt.Errorf("decode image base64 failed: %w", decodeErr), types.ErrorCodeBadResponseBody, types.ErrOptionWithSkipRetry())
+		}
+
+		sha := hex.EncodeToString(common.Sha256Raw(data))
+		imageID := ""
+		if existing, queryErr := model.GetStoredImageByUserAndSha(c.Request.Context(), info.UserId, sha); queryErr == nil && existing != nil && existing.Id != "" {
+			imageID = existing.Id
+		} else if queryErr != nil && !errors.Is(queryErr, gorm.ErrRecordNotFound) {
+			return nil, types.NewError(fmt.Errorf("query stored image failed: %w", queryErr), types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+		}
+		if imageID == "" {
+			img := &model.StoredImage{
+				UserId:    info.UserId,
+				ChannelId: info.ChannelId,
+				MimeType:  http.DetectContentType(data),
+				SizeBytes: len(data),
+				Sha256:    sha,
+				Data:      model.LargeBlob(data),
+			}
+			if insertErr := img.Insert(c.Request.Context()); insertErr != nil {
+				return nil, types.NewError(fmt.Errorf("store image failed: %w", insertErr), types.ErrorCodeUpdateDataError, types.ErrOptionWithSkipRetry())
+			}
+			imageID = img.Id
+			newImageCount++
+		}
+		imageIDs = append(imageIDs, imageID)
+
+		prefix := fmt.Sprintf("data.%d.", i)
+		if body, err = sjson.SetBytes(body, prefix+"url", buildStoredImageURL(c, imageID)); err != nil {
+			return nil, types.NewError(err, types.ErrorCodeBadResponseBody, types.ErrOptionWithSkipRetry())
+		}
+		if body, err = sjson.DeleteBytes(body, prefix+"b64_json"); err != nil {
+			return nil, types.NewError(err, types.ErrorCodeBadResponseBody, types.ErrOptionWithSkipRetry())
+		}
+	}
+
+	if newImageCount > 0 {
+		// 本次响应返回的图片不参与淘汰，否则池已满时新链接会立即失效
+		if _, poolErr := model.EnsureStoredImagesPoolLimit(c.Request.Context(), poolMaxBytes, 100, imageIDs...); poolErr != nil {
+			return nil, types.NewError(fmt.Errorf("enforce stored image pool limit failed: %w", poolErr), types.ErrorCodeUpdateDataError, types.ErrOptionWithSkipRetry())
+		}
+	}
+	return body, nil
+}
@@ -0,0 +1,158 @@
+package relay
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/model"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+	"gorm.io/gorm"
+)
+
+func setupStoredImageTestDB(t *testing.T) {
+	t.Helper()
+	oldDB := model.DB
+	oldPoolMB := constant.StoredImagePoolMB
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite test db: %v", err)
+	}
+	if err := db.AutoMigrate(&model.StoredImage{}); err != nil {
+		t.Fatalf("migrate sqlite test db: %v", err)
+	}
+	model.DB = db
+	t.Cleanup(func() {
+		model.DB = oldDB
+		constant.StoredImagePoolMB = oldPoolMB
+	})
+}
+
+func newImageResponseTestContext() *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "http://gateway.test/v1/images/generations", nil)
+	return c
+}
+
+func TestConvertImageResponseToStoredURLsDedupesAndSignsURLs(t *testing.T) {
+	setupStoredImageTestDB(t)
+	constant.StoredImagePoolMB = 512
+
+	image := base64.StdEncoding.EncodeToString([]byte("same image bytes"))
+	body := []byte(`{"created":1,"data":[{"b64_json":"` + image + `"},{"b64_json":"` + image + `"},{"url":"https://cdn.example/keep.png"}]}`)
+	info := &relaycommon.RelayInfo{UserId: 7, ChannelMeta: &relaycommon.ChannelMeta{ChannelId: 3}}
+
+	converted, apiErr := convertImageResponseToStoredURLs(newImageResponseTestContext(), info, body)
+	if apiErr != nil {
+		t.Fatalf("convertImageResponseToStoredURLs returned error: %v", apiErr)
+	}
+	var stored []model.StoredImage
+	model.DB.Find(&stored)
+	if len(stored) != 1 || stored[0].UserId != 7 || stored[0].ChannelId != 3 {
+		t.Fatalf("stored images = %+v, want one deduplicated image", stored)
+	}
+
+	first := gjson.GetBytes(converted, "data.0.url").String()
+	second := gjson.GetBytes(converted, "data.1.url").String()
+	if gjson.GetBytes(converted, "data.0.b64_json").Exists() || gjson.GetBytes(converted, "data.1.b64_json").Exists() {
+		t.Fatalf("b64_json should be removed: %s", converted)
+	}
+	if gjson.GetBytes(converted, "data.2.url").String() != "https://cdn.example/keep.png" {
+		t.Fatalf("upstream url should be kept: %s", converted)
+	}
+	for _, rawURL := range []string{first, second} {
+		parsed, err := url.Parse(rawURL)
+		if err != nil || parsed.Path != "/mcp/image/"+stored[0].Id {
+			t.Fatalf("stored image url = %s", rawURL)
+		}
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, parsed.RequestURI(), nil)
+		if _, _, ok := verifyStoredAssetSignature(c, "stored_image", stored[0].Id); !ok {
+			t.Fatalf("signature of %s should verify", rawURL)
+		}
+	}
+
+	// 同一用户再次生成相同图片时复用已有记录
+	if _, apiErr := convertImageResponseToStoredURLs(newImageResponseTestContext(), info, body); apiErr != nil {
+		t.Fatalf("second conversion returned error: %v", apiErr)
+	}
+	var count int64
+	model.DB.Model(&model.StoredImage{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("stored images = %d, want 1", count)
+	}
+}
+
+func TestConvertImageResponseToStoredURLsEnforcesPoolLimit(t *testing.T) {
+	setupStoredImageTestDB(t)
+	constant.StoredImagePoolMB = 1
+
+	old := &model.StoredImage{Id: "old", UserId: 7, CreatedAt: common.GetTimestamp() - 3600, SizeBytes: 1024 * 1024, Sha256: strings.Repeat("0", 64), Data: model.LargeBlob("old")}
+	if err := old.Insert(context.Background()); err != nil {
+		t.Fatalf("insert old image: %v", err)
+	}
+
+	body := []byte(`{"data":[{"b64_json":"` + base64.StdEncoding.EncodeToString([]byte("new image")) + `"}]}`)
+	info := &relaycommon.RelayInfo{UserId: 7, ChannelMeta: &relaycommon.ChannelMeta{}}
+	converted, apiErr := convertImageResponseToStoredURLs(newImageResponseTestContext(), info, body)
+	if apiErr != nil {
+		t.Fatalf("convertImageResponseToStoredURLs returned error: %v", apiErr)
+	}
+
+	var stored []model.StoredImage
+	model.DB.Find(&stored)
+	if len(stored) != 1 || stored[0].Id == "old" {
+		t.Fatalf("stored images = %+v, want only the new image", stored)
+	}
+	if !strings.Contains(gjson.GetBytes(converted, "data.0.url").String(), "/mcp/image/"+stored[0].Id) {
+		t.Fatalf("url should point to the new image: %s", converted)
+	}
+}
+
+func TestConvertImageResponseToStoredURLsKeepsCurrentImagesOverPoolLimit(t *testing.T) {
+	setupStoredImageTestDB(t)
+	constant.StoredImagePoolMB = 1
+
+	old := &model.StoredImage{Id: "old", UserId: 7, CreatedAt: common.GetTimestamp() - 3600, SizeBytes: 1024, Sha256: strings.Repeat("0", 64), Data: model.LargeBlob("old")}
+	if err := old.Insert(context.Background()); err != nil {
+		t.Fatalf("insert old image: %v", err)
+	}
+
+	// 两张新图合计超出图片池上限，但都属于本次响应，不能被淘汰
+	first := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("a", 600*1024)))
+	second := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("b", 600*1024)))
+	body := []byte(`{"data":[{"b64_json":"` + first + `"},{"b64_json":"` + second + `"}]}`)
+	info := &relaycommon.RelayInfo{UserId: 7, ChannelMeta: &relaycommon.ChannelMeta{}}
+	converted, apiErr := convertImageResponseToStoredURLs(newImageResponseTestContext(), info, body)
+	if apiErr != nil {
+		t.Fatalf("convertImageResponseToStoredURLs returned error: %v", apiErr)
+	}
+
+	var stored []model.StoredImage
+	model.DB.Select("id").Find(&stored)
+	ids := make(map[string]bool, len(stored))
+	for _, img := range stored {
+		ids[img.Id] = true
+	}
+	if len(stored) != 2 || ids["old"] {
+		t.Fatalf("stored images = %+v, want only the two new images", stored)
+	}
+	for _, path := range []string{"data.0.url", "data.1.url"} {
+		parsed, err := url.Parse(gjson.GetBytes(converted, path).String())
+		if err != nil || !ids[strings.TrimPrefix(parsed.Path, "/mcp/image/")] {
+			t.Fatalf("%s should point to a stored image: %s", path, gjson.GetBytes(converted, path).String())
+		}
+	}
+}
@@ -36,12 +36,24 @@ func OpenAIWireHelper(c *gin.Context, info *relaycommon.RelayInfo) *types.NewAPI
 		if wire == dto.OpenAIWireAPIResponses {
 			return relayChatDownstreamToResponsesUpstream(c, info)
 		}
+		if chatReq, ok := info.Request.(*dto.GeneralOpenAIRequest); ok {
+			// 结构化输出模拟与网关工具循环都需要接管多轮请求，二者同时命中时以结构化输出为准
+			if spec, mode, err := resolveStructuredOutputEmulation(info, chatReq); err != nil {
+				return types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+			} else if spec != nil {
+				return relayChatWithStructuredOutputEmulation(c, info, spec, mode)
+			}
+			if tools := resolveGatewayTools(c, info, chatReq); len(tools) > 0 {
+				return relayChatWithGatewayTools(c, info, tools)
+			}
+		}
 		return TextHelper(c, info)
 	case relayconstant.RelayModeResponses:
-		if wire == dto.OpenAIWireAPIChat {
-			return relayResponsesDownstreamToChatUpstream(c, info)
+		// 工具调用模拟只解析 chat 响应，Responses 请求经 chat 转换后再发往上游
+		if wire == dto.OpenAIWireAPIBoth && relaycommon.ToolCallEmulationEnabled(info) {
+			wire = dto.OpenAIWireAPIChat
 		}
-		return ResponsesHelper(c, info)
+		return relayResponsesWithGatewayStore(c, info, wire)
 	case relayconstant.RelayModeResponsesCompact:
 		if wire == dto.OpenAIWireAPIChat {
 			return types.NewErrorWithStatusCode(
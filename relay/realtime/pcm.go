@@ -0,0 +1,141 @@
+package realtime
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// DurationSeconds 返回 size 字节 24kHz PCM16 音频的时长（秒）
+func DurationSeconds(size int) float64 {
+	return float64(size) / float64(SampleRate*bytesPerSample)
+}
+
+// EncodeWAV 为 PCM16 单声道数据加上 WAV 头，供 Whisper 兼容的转写接口上传
+func EncodeWAV(pcm []byte, sampleRate int) []byte {
+	var buf bytes.Buffer
+	buf.Grow(44 + len(pcm))
+	buf.WriteString("RIFF")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(36+len(pcm)))
+	buf.WriteString("WAVEfmt ")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(16))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(1)) // PCM
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(1)) // 单声道
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(sampleRate*bytesPerSample))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(bytesPerSample))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(16))
+	buf.WriteString("data")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(len(pcm)))
+	buf.Write(pcm)
+	return buf.Bytes()
+}
+
+// DecodeSpeechAudio 将 TTS 返回的音频统一为 24kHz 单声道 PCM16：
+// WAV 会被解析并重采样，无头数据按 24kHz PCM16 处理，mp3/ogg/flac 等压缩格式无法解码直接报错
+func DecodeSpeechAudio(data []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("RIFF")):
+		pcm, sampleRate, channels, err := parseWAV(data)
+		if err != nil {
+			return nil, err
+		}
+		return Resample(downmix(pcm, channels), sampleRate, SampleRate), nil
+	case bytes.HasPrefix(data, []byte("ID3")), len(data) > 1 && data[0] == 0xFF && data[1]&0xE0 == 0xE0:
+		return nil, errors.New("mp3 audio is not supported, request pcm or wav output")
+	case bytes.HasPrefix(data, []byte("OggS")), bytes.HasPrefix(data, []byte("fLaC")):
+		return nil, errors.New("compressed audio is not supported, request pcm or wav output")
+	}
+	if len(data)%bytesPerSample != 0 {
+		data = data[:len(data)-1]
+	}
+	return data, nil
+}
+
+func parseWAV(data []byte) ([]byte, int, int, error) {
+	if len(data) < 12 || string(data[8:12]) != "WAVE" {
+		return nil, 0, 0, errors.New("invalid wav header")
+	}
+	var (
+		sampleRate, channels int
+		bitsPerSample        int
+		format               uint16
+	)
+	offset := 12
+	for offset+8 <= len(data) {
+		id := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4:]))
+		body := offset + 8
+		switch id {
+		case "fmt ":
+			if body+16 > len(data) {
+				return nil, 0, 0, errors.New("invalid wav fmt chunk")
+			}
+			format = binary.LittleEndian.Uint16(data[body:])
+			channels = int(binary.LittleEndian.Uint16(data[body+2:]))
+			sampleRate = int(binary.LittleEndian.Uint32(data[body+4:]))
+			bitsPerSample = int(binary.LittleEndian.Uint16(data[body+14:]))
+		case "data":
+			if sampleRate == 0 {
+				return nil, 0, 0, errors.New("wav data chunk before fmt chunk")
+			}
+			// 0xFFFE 为 WAVE_FORMAT_EXTENSIBLE
+			if (format != 1 && format != 0xFFFE) || bitsPerSample != 16 || channels <= 0 {
+				return nil, 0, 0, fmt.Errorf("unsupported wav format %d with %d bits", format, bitsPerSample)
+			}
+			// 流式 TTS 常把 size 写为 0 或超长，按实际剩余长度截取
+			end := body + size
+			if size == 0 || end > len(data) {
+				end = len(data)
+			}
+			return data[body:end], sampleRate, channels, nil
+		}
+		offset = body + size + size%2
+	}
+	return nil, 0, 0, errors.New("wav data chunk not found")
+}
+
+// downmix 多声道 PCM16 取各声道平均值转为单声道
+func downmix(pcm []byte, channels int) []byte {
+	if channels <= 1 {
+		return pcm
+	}
+	frameBytes := channels * bytesPerSample
+	out := make([]byte, 0, len(pcm)/channels)
+	for i := 0; i+frameBytes <= len(pcm); i += frameBytes {
+		sum := 0
+		for ch := 0; ch < channels; ch++ {
+			sum += int(int16(binary.LittleEndian.Uint16(pcm[i+ch*bytesPerSample:])))
+		}
+		out = binary.LittleEndian.AppendUint16(out, uint16(int16(sum/channels)))
+	}
+	return out
+}
+
+// Resample 对单声道 PCM16 做线性插值重采样
+func Resample(pcm []byte, from, to int) []byte {
+	if from == to || from <= 0 || to <= 0 {
+		return pcm
+	}
+	inSamples := len(pcm) / bytesPerSample
+	if inSamples == 0 {
+		return nil
+	}
+	outSamples := int(int64(inSamples) * int64(to) / int64(from))
+	out := make([]byte, 0, outSamples*bytesPerSample)
+	sampleAt := func(i int) float64 {
+		return float64(int16(binary.LittleEndian.Uint16(pcm[i*bytesPerSample:])))
+	}
+	for i := 0; i < outSamples; i++ {
+		pos := float64(i) * float64(from) / float64(to)
+		idx := int(pos)
+		frac := pos - float64(idx)
+		value := sampleAt(idx)
+		if idx+1 < inSamples {
+			value += (sampleAt(idx+1) - value) * frac
+		}
+		out = binary.LittleEndian.AppendUint16(out, uint16(int16(value)))
+	}
+	return out
+}
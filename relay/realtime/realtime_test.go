@@ -0,0 +1,73 @@
+package realtime
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func tone(ms int, amplitude float64) []byte {
+	samples := ms * SampleRate / 1000
+	out := make([]byte, 0, samples*bytesPerSample)
+	for i := 0; i < samples; i++ {
+		value := amplitude * 32767 * math.Sin(2*math.Pi*440*float64(i)/SampleRate)
+		out = binary.LittleEndian.AppendUint16(out, uint16(int16(value)))
+	}
+	return out
+}
+
+func TestVADDetectsSpeechTurn(t *testing.T) {
+	vad := NewVAD(VADConfig{Threshold: 0.5, PrefixPaddingMs: 100, SilenceDurationMs: 300})
+
+	var events []VADEvent
+	events = append(events, vad.Feed(tone(500, 0))...)
+	// 按不对齐帧长的小块输入语音
+	speech := tone(400, 0.3)
+	for i := 0; i < len(speech); i += 1000 {
+		events = append(events, vad.Feed(speech[i:min(i+1000, len(speech))])...)
+	}
+	if len(events) != 1 || events[0].Type != VADSpeechStarted || events[0].AudioMs != 500 || !vad.Speaking() {
+		t.Fatalf("expected speech start at 500ms, got %+v", events)
+	}
+
+	events = vad.Feed(tone(200, 0))
+	if len(events) != 0 {
+		t.Fatalf("short pause must not end the turn: %+v", events)
+	}
+	events = vad.Feed(tone(200, 0))
+	if len(events) != 1 || events[0].Type != VADSpeechStopped || events[0].AudioMs != 1200 {
+		t.Fatalf("expected speech stop at 1200ms, got %+v", events)
+	}
+	// 前置填充 100ms + 语音 400ms + 静音 300ms
+	if got := DurationSeconds(len(events[0].Audio)); math.Abs(got-0.8) > 0.001 {
+		t.Fatalf("turn audio = %.3fs", got)
+	}
+}
+
+func TestVADIgnoresClicksAndNoise(t *testing.T) {
+	vad := NewVAD(DefaultVADConfig())
+	audio := append(tone(300, 0.01), tone(40, 0.5)...)
+	audio = append(audio, tone(300, 0.01)...)
+	if events := vad.Feed(audio); len(events) != 0 {
+		t.Fatalf("noise and a 40ms click must not start speech: %+v", events)
+	}
+}
+
+func TestDecodeSpeechAudio(t *testing.T) {
+	pcm16k := tone(100, 0.2)[:3200]
+	decoded, err := DecodeSpeechAudio(EncodeWAV(pcm16k, 16000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(pcm16k)*3/2 {
+		t.Fatalf("16kHz wav should be resampled to 24kHz, got %d bytes", len(decoded))
+	}
+
+	raw := tone(20, 0.2)
+	if decoded, err := DecodeSpeechAudio(raw); err != nil || len(decoded) != len(raw) {
+		t.Fatalf("raw pcm should pass through, got %d bytes, %v", len(decoded), err)
+	}
+	if _, err := DecodeSpeechAudio([]byte("ID3\x04\x00")); err == nil {
+		t.Fatal("mp3 should be rejected")
+	}
+}
@@ -0,0 +1,164 @@
+package realtime
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+const (
+	// SampleRate OpenAI Realtime 的 pcm16 固定为 24kHz 单声道小端
+	SampleRate     = 24000
+	bytesPerSample = 2
+	bytesPerMs     = SampleRate * bytesPerSample / 1000
+
+	vadFrameMs    = 20
+	vadFrameBytes = vadFrameMs * bytesPerMs
+	// 连续达到阈值的时长超过该值才判定为开始说话，过滤按键、咔哒声等短促噪声
+	vadMinSpeechMs = 60
+	// threshold 取值 0-1，乘以该系数后作为帧 RMS（满幅为 1）的判定阈值，默认 0.5 约为 -26dBFS
+	vadThresholdScale = 0.1
+)
+
+// VADConfig 对应 OpenAI Realtime turn_detection 中 server_vad 的参数
+type VADConfig struct {
+	Threshold         float64
+	PrefixPaddingMs   int
+	SilenceDurationMs int
+}
+
+func DefaultVADConfig() VADConfig {
+	return VADConfig{Threshold: 0.5, PrefixPaddingMs: 300, SilenceDurationMs: 500}
+}
+
+func (cfg VADConfig) normalize() VADConfig {
+	def := DefaultVADConfig()
+	if cfg.Threshold <= 0 || cfg.Threshold > 1 {
+		cfg.Threshold = def.Threshold
+	}
+	if cfg.PrefixPaddingMs < 0 {
+		cfg.PrefixPaddingMs = def.PrefixPaddingMs
+	}
+	if cfg.SilenceDurationMs <= 0 {
+		cfg.SilenceDurationMs = def.SilenceDurationMs
+	}
+	return cfg
+}
+
+type VADEventType int
+
+const (
+	VADSpeechStarted VADEventType = iota + 1
+	VADSpeechStopped
+)
+
+type VADEvent struct {
+	Type VADEventType
+	// AudioMs 相对会话音频起点的毫秒数
+	AudioMs int
+	// Audio 仅 VADSpeechStopped 时有值，为本轮语音（含前置填充）的 PCM16 数据
+	Audio []byte
+}
+
+// VAD 基于短时能量的服务端语音活动检测，输入为 24kHz PCM16，按 20ms 分帧判定
+type VAD struct {
+	cfg VADConfig
+
+	pending []byte
+	// 未说话时保留最近一段音频，开始说话时作为前置填充
+	history []byte
+	speech  []byte
+
+	speaking    bool
+	voicedMs    int
+	silenceMs   int
+	processedMs int
+}
+
+func NewVAD(cfg VADConfig) *VAD {
+	return &VAD{cfg: cfg.normalize()}
+}
+
+// Speaking 返回当前是否处于说话状态
+func (v *VAD) Speaking() bool {
+	return v.speaking
+}
+
+// Reset 丢弃已缓冲的音频，用于 input_audio_buffer.clear
+func (v *VAD) Reset() {
+	v.pending = nil
+	v.history = nil
+	v.speech = nil
+	v.speaking = false
+	v.voicedMs = 0
+	v.silenceMs = 0
+}
+
+// Feed 输入一段 PCM16 音频，返回期间检测到的开始/结束说话事件
+func (v *VAD) Feed(pcm []byte) []VADEvent {
+	var events []VADEvent
+	v.pending = append(v.pending, pcm...)
+	for len(v.pending) >= vadFrameBytes {
+		frame := v.pending[:vadFrameBytes]
+		v.pending = v.pending[vadFrameBytes:]
+		if event := v.feedFrame(frame); event != nil {
+			events = append(events, *event)
+		}
+	}
+	return events
+}
+
+func (v *VAD) feedFrame(frame []byte) *VADEvent {
+	v.processedMs += vadFrameMs
+	voiced := FrameRMS(frame) >= v.cfg.Threshold*vadThresholdScale
+
+	if !v.speaking {
+		v.history = append(v.history, frame...)
+		if voiced {
+			v.voicedMs += vadFrameMs
+		} else {
+			v.voicedMs = 0
+		}
+		keep := (v.cfg.PrefixPaddingMs + v.voicedMs) * bytesPerMs
+		if len(v.history) > keep {
+			v.history = v.history[len(v.history)-keep:]
+		}
+		if v.voicedMs < vadMinSpeechMs {
+			return nil
+		}
+		v.speaking = true
+		v.silenceMs = 0
+		v.speech = append([]byte(nil), v.history...)
+		v.history = nil
+		return &VADEvent{Type: VADSpeechStarted, AudioMs: v.processedMs - v.voicedMs}
+	}
+
+	v.speech = append(v.speech, frame...)
+	if voiced {
+		v.silenceMs = 0
+		return nil
+	}
+	v.silenceMs += vadFrameMs
+	if v.silenceMs < v.cfg.SilenceDurationMs {
+		return nil
+	}
+	audio := v.speech
+	v.speech = nil
+	v.speaking = false
+	v.voicedMs = 0
+	v.silenceMs = 0
+	return &VADEvent{Type: VADSpeechStopped, AudioMs: v.processedMs, Audio: audio}
+}
+
+// FrameRMS 计算 PCM16 帧的均方根能量，满幅为 1
+func FrameRMS(frame []byte) float64 {
+	samples := len(frame) / bytesPerSample
+	if samples == 0 {
+		return 0
+	}
+	var sum float64
+	for i := 0; i < samples; i++ {
+		sample := float64(int16(binary.LittleEndian.Uint16(frame[i*bytesPerSample:]))) / 32768
+		sum += sample * sample
+	}
+	return math.Sqrt(sum / float64(samples))
+}
@@ -0,0 +1,627 @@
+package relay
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/logger"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/relay/helper"
+	"github.com/zhongruan0522/new-api/relay/realtime"
+	"github.com/zhongruan0522/new-api/service"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// 桥接只处理 24kHz PCM16，与 OpenAI Realtime 默认格式一致
+	realtimeBridgeAudioFormat = "pcm16"
+	// 每个 response.audio.delta 携带 200ms 音频
+	realtimeBridgeAudioChunkBytes = realtime.SampleRate * 2 / 5
+	realtimeBridgeTurnQueueSize   = 16
+	// 后端调用失败时错误事件使用的 code
+	realtimeBridgeErrorCode = "bridge_error"
+)
+
+// realtimeBridgeBackend 实时桥接的后端，由会话的 worker 串行调用
+type realtimeBridgeBackend interface {
+	// runTurn 把本轮用户输入加入上下文；resp 不为空时生成回复并通过 resp 推送，
+	// ctx 在用户打断或 response.cancel 时取消
+	runTurn(ctx context.Context, turn *realtimeBridgeTurn, resp *realtimeBridgeResponse) error
+	close()
+}
+
+// resolveRealtimeBridgeMode 渠道配置了 realtime_bridge 时使用配置的模式，Gemini 渠道默认走 Gemini Live
+func resolveRealtimeBridgeMode(info *relaycommon.RelayInfo) dto.RealtimeBridgeMode {
+	if bridge := info.ChannelOtherSettings.RealtimeBridge; bridge != nil {
+		if mode, _ := bridge.Mode.Normalize(); mode != "" {
+			return mode
+		}
+	}
+	if info.ApiType == constant.APITypeGemini {
+		return dto.RealtimeBridgeModeGeminiLive
+	}
+	return ""
+}
+
+// RealtimeBridgeHelper 对客户端保持 OpenAI Realtime 事件协议，在服务端做 VAD 断句并驱动非 OpenAI 语音后端，
+// 按音频时长与文本 token 计费
+func RealtimeBridgeHelper(c *gin.Context, info *relaycommon.RelayInfo, mode dto.RealtimeBridgeMode) *types.NewAPIError {
+	if err := helper.ModelMappedHelper(c, info, nil); err != nil {
+		return types.NewError(err, types.ErrorCodeChannelModelMappedError, types.ErrOptionWithSkipRetry())
+	}
+	var settings dto.RealtimeBridgeSettings
+	if info.ChannelOtherSettings.RealtimeBridge != nil {
+		settings = *info.ChannelOtherSettings.RealtimeBridge
+	}
+
+	session := newRealtimeBridgeSession(c, info, settings)
+	defer session.stop()
+	var err error
+	switch mode {
+	case dto.RealtimeBridgeModePipeline:
+		session.backend, err = newRealtimeBridgePipeline(session)
+		session.stageBilling = true
+	case dto.RealtimeBridgeModeGeminiLive:
+		session.backend, err = newRealtimeBridgeGeminiLive(session)
+	default:
+		err = fmt.Errorf("unsupported realtime bridge mode: %s", mode)
+	}
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeDoRequestFailed)
+	}
+	defer session.backend.close()
+
+	usage := session.run()
+	extra := fmt.Sprintf("实时桥接 %s，输入音频 %.1f 秒，输出音频 %.1f 秒", mode, session.inputSeconds, session.outputSeconds)
+	if session.stageBilling {
+		service.PostRealtimeStagesConsumeQuota(c, info, usage, session.stageQuotas, extra)
+		return nil
+	}
+	return service.PostWssConsumeQuota(c, info, info.UpstreamModelName, usage, extra)
+}
+
+type realtimeBridgeConfig struct {
+	instructions   string
+	voice          string
+	modalities     []string
+	temperature    *float64
+	turnDetection  bool
+	createResponse bool
+	vad            realtime.VADConfig
+}
+
+func (cfg realtimeBridgeConfig) audioOutput() bool {
+	for _, modality := range cfg.modalities {
+		if modality == "audio" {
+			return true
+		}
+	}
+	return false
+}
+
+type realtimeBridgeTurn struct {
+	itemId string
+	// audio 为本轮用户语音（24kHz PCM16），text 为 conversation.item.create 提交的文本
+	audio   []byte
+	text    string
+	respond bool
+	config  realtimeBridgeConfig
+
+	textInputTokens  int
+	textOutputTokens int
+	// pipeline 模式下各阶段按自身模型价格计算的额度
+	stageQuota  int
+	stageQuotas map[string]int
+}
+
+func (t *realtimeBridgeTurn) addTextUsage(input, output int) {
+	t.textInputTokens += input
+	t.textOutputTokens += output
+}
+
+func (t *realtimeBridgeTurn) addStageQuota(model string, quota int) {
+	if quota <= 0 {
+		return
+	}
+	if t.stageQuotas == nil {
+		t.stageQuotas = map[string]int{}
+	}
+	t.stageQuota += quota
+	t.stageQuotas[model] += quota
+}
+
+type realtimeBridgeSession struct {
+	c        *gin.Context
+	info     *relaycommon.RelayInfo
+	settings dto.RealtimeBridgeSettings
+	backend  realtimeBridgeBackend
+	client   *websocket.Conn
+	writeMu  sync.Mutex
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// config 由读协程写入，入队时快照到 turn 中供 worker 使用
+	mu             sync.Mutex
+	config         realtimeBridgeConfig
+	cancelResponse context.CancelFunc
+
+	// 以下仅在读协程中访问
+	vad          *realtime.VAD
+	buffer       []byte
+	speechItemId string
+
+	turns chan *realtimeBridgeTurn
+
+	// stageBilling 为 true 时按各阶段模型的价格扣费，而不是实时模型的倍率
+	stageBilling bool
+
+	// 以下仅在 worker 中访问，run 返回后由调用方读取
+	usage         dto.RealtimeUsage
+	inputSeconds  float64
+	outputSeconds float64
+	stageQuotas   map[string]int
+}
+
+func newRealtimeBridgeSession(c *gin.Context, info *relaycommon.RelayInfo, settings dto.RealtimeBridgeSettings) *realtimeBridgeSession {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	config := realtimeBridgeConfig{
+		voice:          settings.Voice,
+		modalities:     []string{"text", "audio"},
+		turnDetection:  true,
+		createResponse: true,
+		vad:            realtime.DefaultVADConfig(),
+	}
+	return &realtimeBridgeSession{
+		c:        c,
+		info:     info,
+		settings: settings,
+		client:   info.ClientWs,
+		ctx:      ctx,
+		cancel:   cancel,
+		config:   config,
+		vad:      realtime.NewVAD(config.vad),
+		turns:    make(chan *realtimeBridgeTurn, realtimeBridgeTurnQueueSize),
+	}
+}
+
+func (s *realtimeBridgeSession) stop() {
+	s.cancel()
+}
+
+// run 处理客户端事件直到连接关闭，返回整个会话的用量
+func (s *realtimeBridgeSession) run() *dto.RealtimeUsage {
+	s.info.IsStream = true
+	s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventTypeSessionCreated, Session: s.sessionPayload()})
+
+	workerDone := make(chan struct{})
+	gopool.Go(func() {
+		defer close(workerDone)
+		defer func() {
+			if r := recover(); r != nil {
+				logger.LogError(s.c, fmt.Sprintf("panic in realtime bridge worker: %v", r))
+				s.cancel()
+				_ = s.client.Close()
+			}
+		}()
+		for turn := range s.turns {
+			if s.ctx.Err() != nil {
+				continue
+			}
+			s.processTurn(turn)
+		}
+	})
+
+	for s.ctx.Err() == nil {
+		_, message, err := s.client.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) && s.ctx.Err() == nil {
+				logger.LogError(s.c, "realtime bridge read error: "+err.Error())
+			}
+			break
+		}
+		s.handleClientEvent(message)
+	}
+
+	s.cancel()
+	close(s.turns)
+	<-workerDone
+	return &s.usage
+}
+
+func (s *realtimeBridgeSession) send(event *dto.RealtimeEvent) {
+	if event.EventId == "" {
+		event.EventId = "event_" + common.GetRandomString(16)
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err := helper.WssObject(s.c, s.client, event); err != nil && s.ctx.Err() == nil {
+		logger.LogError(s.c, "realtime bridge write error: "+err.Error())
+	}
+}
+
+func (s *realtimeBridgeSession) sendError(code string, err error) {
+	errType := "invalid_request_error"
+	if code == realtimeBridgeErrorCode {
+		errType = "server_error"
+	}
+	s.send(&dto.RealtimeEvent{
+		Type:  dto.RealtimeEventTypeError,
+		Error: &types.OpenAIError{Message: err.Error(), Type: errType, Code: code},
+	})
+}
+
+func newRealtimeBridgeItemId() string {
+	return "item_" + common.GetRandomString(16)
+}
+
+func (s *realtimeBridgeSession) handleClientEvent(message []byte) {
+	event := &dto.RealtimeEvent{}
+	if err := common.Unmarshal(message, event); err != nil {
+		s.sendError("invalid_event", fmt.Errorf("invalid realtime event: %w", err))
+		return
+	}
+	switch event.Type {
+	case dto.RealtimeEventTypeSessionUpdate:
+		if err := s.updateSession(gjson.GetBytes(message, "session")); err != nil {
+			s.sendError("invalid_session", err)
+			return
+		}
+		s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventTypeSessionUpdated, Session: s.sessionPayload()})
+	case dto.RealtimeEventInputAudioBufferAppend:
+		pcm, err := base64.StdEncoding.DecodeString(event.Audio)
+		if err != nil {
+			s.sendError("invalid_audio", fmt.Errorf("invalid base64 audio: %w", err))
+			return
+		}
+		s.appendAudio(pcm)
+	case dto.RealtimeEventInputAudioBufferCommit:
+		if len(s.buffer) == 0 {
+			s.sendError("input_audio_buffer_commit_empty", errors.New("input audio buffer is empty"))
+			return
+		}
+		audio := s.buffer
+		s.buffer = nil
+		s.commitAudio(newRealtimeBridgeItemId(), audio, false)
+	case dto.RealtimeEventInputAudioBufferClear:
+		s.buffer = nil
+		s.vad.Reset()
+		s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventInputAudioBufferCleared})
+	case dto.RealtimeEventTypeConversationCreate:
+		s.createItem(event.Item)
+	case dto.RealtimeEventTypeResponseCreate:
+		s.enqueue(&realtimeBridgeTurn{respond: true})
+	case dto.RealtimeEventTypeResponseCancel:
+		s.cancelCurrentResponse()
+	default:
+		s.sendError("unsupported_event", fmt.Errorf("event type %q is not supported by the realtime bridge", event.Type))
+	}
+}
+
+// updateSession 按 session.update 中实际出现的字段增量更新会话配置
+func (s *realtimeBridgeSession) updateSession(session gjson.Result) error {
+	for _, field := range []string{"input_audio_format", "output_audio_format"} {
+		if format := session.Get(field); format.Exists() && format.String() != realtimeBridgeAudioFormat {
+			return fmt.Errorf("%s %q is not supported by the realtime bridge, use pcm16", field, format.String())
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if value := session.Get("instructions"); value.Exists() {
+		s.config.instructions = value.String()
+	}
+	if value := session.Get("voice"); value.Exists() && s.settings.Voice == "" {
+		s.config.voice = value.String()
+	}
+	if value := session.Get("modalities"); value.IsArray() {
+		var modalities []string
+		for _, modality := range value.Array() {
+			modalities = append(modalities, modality.String())
+		}
+		s.config.modalities = modalities
+	}
+	if value := session.Get("temperature"); value.Exists() {
+		temperature := value.Float()
+		s.config.temperature = &temperature
+	}
+	if value := session.Get("turn_detection"); value.Exists() {
+		if value.Type == gjson.Null {
+			s.config.turnDetection = false
+		} else {
+			s.config.turnDetection = true
+			vad := realtime.DefaultVADConfig()
+			if threshold := value.Get("threshold"); threshold.Exists() {
+				vad.Threshold = threshold.Float()
+			}
+			if padding := value.Get("prefix_padding_ms"); padding.Exists() {
+				vad.PrefixPaddingMs = int(padding.Int())
+			}
+			if silence := value.Get("silence_duration_ms"); silence.Exists() {
+				vad.SilenceDurationMs = int(silence.Int())
+			}
+			s.config.vad = vad
+			s.config.createResponse = !value.Get("create_response").Exists() || value.Get("create_response").Bool()
+		}
+		s.vad = realtime.NewVAD(s.config.vad)
+		s.buffer = nil
+	}
+	return nil
+}
+
+func (s *realtimeBridgeSession) snapshotConfig() realtimeBridgeConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.config
+}
+
+func (s *realtimeBridgeSession) sessionPayload() *dto.RealtimeSession {
+	cfg := s.snapshotConfig()
+	session := &dto.RealtimeSession{
+		Modalities:        cfg.modalities,
+		Instructions:      cfg.instructions,
+		Voice:             cfg.voice,
+		InputAudioFormat:  realtimeBridgeAudioFormat,
+		OutputAudioFormat: realtimeBridgeAudioFormat,
+	}
+	if cfg.temperature != nil {
+		session.Temperature = *cfg.temperature
+	}
+	if cfg.turnDetection {
+		session.TurnDetection = map[string]any{
+			"type":                "server_vad",
+			"threshold":           cfg.vad.Threshold,
+			"prefix_padding_ms":   cfg.vad.PrefixPaddingMs,
+			"silence_duration_ms": cfg.vad.SilenceDurationMs,
+			"create_response":     cfg.createResponse,
+		}
+	}
+	return session
+}
+
+func (s *realtimeBridgeSession) appendAudio(pcm []byte) {
+	cfg := s.snapshotConfig()
+	if !cfg.turnDetection {
+		s.buffer = append(s.buffer, pcm...)
+		return
+	}
+	for _, event := range s.vad.Feed(pcm) {
+		audioMs := event.AudioMs
+		switch event.Type {
+		case realtime.VADSpeechStarted:
+			s.speechItemId = newRealtimeBridgeItemId()
+			// 用户开始说话时打断正在进行的回复
+			s.cancelCurrentResponse()
+			s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventInputAudioBufferSpeechStarted, ItemId: s.speechItemId, AudioStartMs: &audioMs})
+		case realtime.VADSpeechStopped:
+			s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventInputAudioBufferSpeechStopped, ItemId: s.speechItemId, AudioEndMs: &audioMs})
+			s.commitAudio(s.speechItemId, event.Audio, cfg.createResponse)
+		}
+	}
+}
+
+func (s *realtimeBridgeSession) commitAudio(itemId string, audio []byte, respond bool) {
+	s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventInputAudioBufferCommitted, ItemId: itemId})
+	s.send(&dto.RealtimeEvent{
+		Type: dto.RealtimeEventConversationItemCreated,
+		Item: &dto.RealtimeItem{Id: itemId, Type: "message", Status: "completed", Role: "user",
+			Content: []dto.RealtimeContent{{Type: "input_audio"}}},
+	})
+	s.enqueue(&realtimeBridgeTurn{itemId: itemId, audio: audio, respond: respond})
+}
+
+func (s *realtimeBridgeSession) createItem(item *dto.RealtimeItem) {
+	if item == nil || item.Type != "message" || item.Role != "user" {
+		s.sendError("unsupported_item", errors.New("the realtime bridge only accepts user message items"))
+		return
+	}
+	var text strings.Builder
+	for _, content := range item.Content {
+		if content.Type == "input_text" || content.Type == "text" {
+			text.WriteString(content.Text)
+		}
+	}
+	if text.Len() == 0 {
+		s.sendError("unsupported_item", errors.New("the realtime bridge only accepts input_text content"))
+		return
+	}
+	if item.Id == "" {
+		item.Id = newRealtimeBridgeItemId()
+	}
+	item.Status = "completed"
+	s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventConversationItemCreated, Item: item})
+	s.enqueue(&realtimeBridgeTurn{itemId: item.Id, text: text.String()})
+}
+
+func (s *realtimeBridgeSession) enqueue(turn *realtimeBridgeTurn) {
+	turn.config = s.snapshotConfig()
+	select {
+	case s.turns <- turn:
+	case <-s.ctx.Done():
+	}
+}
+
+func (s *realtimeBridgeSession) cancelCurrentResponse() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancelResponse != nil {
+		s.cancelResponse()
+	}
+}
+
+func (s *realtimeBridgeSession) setCancelResponse(cancel context.CancelFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancelResponse = cancel
+}
+
+func (s *realtimeBridgeSession) sendInputTranscript(itemId string, transcript string) {
+	s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventInputAudioTranscriptionComplete, ItemId: itemId, Transcript: transcript})
+}
+
+func (s *realtimeBridgeSession) processTurn(turn *realtimeBridgeTurn) {
+	var resp *realtimeBridgeResponse
+	ctx, cancel := context.WithCancel(s.ctx)
+	// 回复结束后才释放 ctx，finish 依据 ctx 是否已取消判断回复状态
+	defer cancel()
+	if turn.respond {
+		s.setCancelResponse(cancel)
+		resp = s.newResponse(ctx, turn.config)
+	}
+	err := s.backend.runTurn(ctx, turn, resp)
+	s.setCancelResponse(nil)
+	if err != nil && ctx.Err() == nil {
+		logger.LogError(s.c, "realtime bridge turn failed: "+err.Error())
+	}
+
+	usage := s.billTurn(turn, resp)
+	if resp != nil {
+		resp.finish(err, usage)
+	} else if err != nil && s.ctx.Err() == nil {
+		s.sendError(realtimeBridgeErrorCode, err)
+	}
+}
+
+// billTurn 按本轮输入/输出音频时长与文本 token 预扣费，pipeline 模式下改为扣除各阶段的额度；额度不足时结束会话
+func (s *realtimeBridgeSession) billTurn(turn *realtimeBridgeTurn, resp *realtimeBridgeResponse) *dto.RealtimeUsage {
+	inputSeconds := realtime.DurationSeconds(len(turn.audio))
+	outputSeconds := 0.0
+	if resp != nil {
+		outputSeconds = realtime.DurationSeconds(resp.audioBytes)
+	}
+	usage := &dto.RealtimeUsage{}
+	usage.InputTokenDetails.AudioTokens = service.AudioInputTokensForDuration(inputSeconds)
+	usage.InputTokenDetails.TextTokens = turn.textInputTokens
+	usage.OutputTokenDetails.AudioTokens = service.AudioOutputTokensForDuration(outputSeconds)
+	usage.OutputTokenDetails.TextTokens = turn.textOutputTokens
+	usage.InputTokens = usage.InputTokenDetails.AudioTokens + usage.InputTokenDetails.TextTokens
+	usage.OutputTokens = usage.OutputTokenDetails.AudioTokens + usage.OutputTokenDetails.TextTokens
+	usage.TotalTokens = usage.InputTokens + usage.OutputTokens
+	if usage.TotalTokens == 0 && turn.stageQuota == 0 {
+		return usage
+	}
+
+	s.inputSeconds += inputSeconds
+	s.outputSeconds += outputSeconds
+	s.usage.TotalTokens += usage.TotalTokens
+	s.usage.InputTokens += usage.InputTokens
+	s.usage.OutputTokens += usage.OutputTokens
+	s.usage.InputTokenDetails.AudioTokens += usage.InputTokenDetails.AudioTokens
+	s.usage.InputTokenDetails.TextTokens += usage.InputTokenDetails.TextTokens
+	s.usage.OutputTokenDetails.AudioTokens += usage.OutputTokenDetails.AudioTokens
+	s.usage.OutputTokenDetails.TextTokens += usage.OutputTokenDetails.TextTokens
+	var err error
+	if s.stageBilling {
+		if turn.stageQuota > 0 {
+			err = service.PreConsumeRealtimeQuota(s.c, s.info, turn.stageQuota)
+		}
+		if err == nil {
+			if s.stageQuotas == nil {
+				s.stageQuotas = map[string]int{}
+			}
+			for model, quota := range turn.stageQuotas {
+				s.stageQuotas[model] += quota
+			}
+		}
+	} else {
+		err = service.PreWssConsumeQuota(s.c, s.info, usage)
+	}
+	if err != nil {
+		s.sendError("insufficient_quota", err)
+		s.cancel()
+		_ = s.client.Close()
+	}
+	return usage
+}
+
+// realtimeBridgeResponse 将后端输出转换为 OpenAI Realtime 的 response.* 事件
+type realtimeBridgeResponse struct {
+	s      *realtimeBridgeSession
+	ctx    context.Context
+	id     string
+	itemId string
+	audio  bool
+
+	transcript strings.Builder
+	audioBytes int
+}
+
+func (s *realtimeBridgeSession) newResponse(ctx context.Context, cfg realtimeBridgeConfig) *realtimeBridgeResponse {
+	resp := &realtimeBridgeResponse{
+		s:      s,
+		ctx:    ctx,
+		id:     "resp_" + common.GetRandomString(16),
+		itemId: newRealtimeBridgeItemId(),
+		audio:  cfg.audioOutput(),
+	}
+	s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseCreated,
+		Response: &dto.RealtimeResponse{Id: resp.id, Object: "realtime.response", Status: "in_progress"}})
+	s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseOutputItemAdded, ResponseId: resp.id,
+		Item: &dto.RealtimeItem{Id: resp.itemId, Type: "message", Status: "in_progress", Role: "assistant"}})
+	return resp
+}
+
+func (r *realtimeBridgeResponse) textDelta(delta string) {
+	if delta == "" || r.ctx.Err() != nil {
+		return
+	}
+	r.transcript.WriteString(delta)
+	eventType := dto.RealtimeEventResponseTextDelta
+	if r.audio {
+		eventType = dto.RealtimeEventResponseAudioTranscriptionDelta
+	}
+	r.s.send(&dto.RealtimeEvent{Type: eventType, ResponseId: r.id, ItemId: r.itemId, Delta: delta})
+}
+
+func (r *realtimeBridgeResponse) audioDelta(pcm []byte) {
+	if !r.audio {
+		return
+	}
+	for len(pcm) > 0 && r.ctx.Err() == nil {
+		chunk := pcm[:min(len(pcm), realtimeBridgeAudioChunkBytes)]
+		pcm = pcm[len(chunk):]
+		r.audioBytes += len(chunk)
+		r.s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseAudioDelta, ResponseId: r.id, ItemId: r.itemId,
+			Delta: base64.StdEncoding.EncodeToString(chunk)})
+	}
+}
+
+func (r *realtimeBridgeResponse) finish(err error, usage *dto.RealtimeUsage) {
+	status := "completed"
+	switch {
+	case r.ctx.Err() != nil:
+		status = "cancelled"
+	case err != nil:
+		status = "failed"
+		r.s.sendError(realtimeBridgeErrorCode, err)
+	}
+	transcript := r.transcript.String()
+	content := dto.RealtimeContent{Type: "text", Text: transcript}
+	if r.audio {
+		content = dto.RealtimeContent{Type: "audio", Transcript: transcript}
+		r.s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseAudioDone, ResponseId: r.id, ItemId: r.itemId})
+		r.s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseAudioTranscriptionDone, ResponseId: r.id, ItemId: r.itemId, Transcript: transcript})
+	} else {
+		r.s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseTextDone, ResponseId: r.id, ItemId: r.itemId, Text: transcript})
+	}
+	item := dto.RealtimeItem{Id: r.itemId, Type: "message", Status: "completed", Role: "assistant", Content: []dto.RealtimeContent{content}}
+	if status != "completed" {
+		item.Status = "incomplete"
+	}
+	r.s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseOutputItemDone, ResponseId: r.id, Item: &item})
+	r.s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventTypeResponseDone, Response: &dto.RealtimeResponse{
+		Id: r.id, Object: "realtime.response", Status: status, Output: []dto.RealtimeItem{item}, Usage: usage,
+	}})
+}
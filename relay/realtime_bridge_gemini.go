@@ -0,0 +1,351 @@
+package relay
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/service"
+
+	"github.com/gorilla/websocket"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	geminiLivePath = "/ws/google.ai.generativelanguage.v1beta.GenerativeService.BidiGenerateContent"
+	// Gemini Live 接受任意采样率的 PCM16，直接按 OpenAI Realtime 的 24kHz 发送
+	geminiLiveInputMimeType = "audio/pcm;rate=24000"
+	// 每条 realtimeInput 携带 100ms 音频
+	geminiLiveAudioChunkBytes = 4800
+	geminiLiveSetupTimeout    = 15 * time.Second
+	geminiLiveReadTimeout     = 60 * time.Second
+)
+
+// realtimeBridgeGeminiLive 通过 Gemini Live 的 BidiGenerateContent 完成语音对话。
+// 断句由桥接的 VAD 负责，因此关闭 Gemini 的自动活动检测，改为显式发送 activityStart / activityEnd
+type realtimeBridgeGeminiLive struct {
+	s    *realtimeBridgeSession
+	conn *websocket.Conn
+	// setupKey 记录建连时使用的会话配置；首轮对话前配置变化会重新建连，之后上下文已在服务端，保持连接不变
+	setupKey string
+	started  bool
+	// 手动提交但尚未请求回复的音频，等到 response.create 时一并发送
+	pendingAudio []byte
+
+	// writeMu 保护 conn 的写入与 activityOpen：打断信号由 ctx 回调发出，可能与 worker 并发
+	writeMu sync.Mutex
+	// activityOpen 表示打断时已发送 activityStart，下一次发送音频时不再重复发送
+	activityOpen bool
+}
+
+func newRealtimeBridgeGeminiLive(s *realtimeBridgeSession) (*realtimeBridgeGeminiLive, error) {
+	g := &realtimeBridgeGeminiLive{s: s}
+	// 先以默认配置建连，上游不可用时可在会话开始前切换渠道重试
+	if err := g.connect(s.snapshotConfig()); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (g *realtimeBridgeGeminiLive) close() {
+	g.writeMu.Lock()
+	defer g.writeMu.Unlock()
+	if g.conn != nil {
+		_ = g.conn.Close()
+		g.conn = nil
+	}
+}
+
+func geminiLiveURL(baseURL string, apiKey string) (string, error) {
+	if baseURL == "" {
+		baseURL = constant.ChannelBaseURLs[constant.ChannelTypeGemini]
+	}
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return "", fmt.Errorf("invalid gemini base url: %w", err)
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	case "http":
+		u.Scheme = "ws"
+	}
+	u.Path += geminiLivePath
+	u.RawQuery = url.Values{"key": []string{apiKey}}.Encode()
+	return u.String(), nil
+}
+
+func geminiLiveSetup(model string, cfg realtimeBridgeConfig, voice string) map[string]any {
+	generationConfig := map[string]any{"responseModalities": []string{"TEXT"}}
+	if cfg.audioOutput() {
+		generationConfig["responseModalities"] = []string{"AUDIO"}
+		if voice != "" {
+			generationConfig["speechConfig"] = map[string]any{
+				"voiceConfig": map[string]any{"prebuiltVoiceConfig": map[string]any{"voiceName": voice}},
+			}
+		}
+	}
+	if cfg.temperature != nil {
+		generationConfig["temperature"] = *cfg.temperature
+	}
+	setup := map[string]any{
+		"model":                    "models/" + strings.TrimPrefix(model, "models/"),
+		"generationConfig":         generationConfig,
+		"realtimeInputConfig":      map[string]any{"automaticActivityDetection": map[string]any{"disabled": true}},
+		"inputAudioTranscription":  map[string]any{},
+		"outputAudioTranscription": map[string]any{},
+	}
+	if cfg.instructions != "" {
+		setup["systemInstruction"] = map[string]any{"parts": []map[string]any{{"text": cfg.instructions}}}
+	}
+	return map[string]any{"setup": setup}
+}
+
+func (g *realtimeBridgeGeminiLive) configKey(cfg realtimeBridgeConfig) string {
+	temperature := ""
+	if cfg.temperature != nil {
+		temperature = fmt.Sprintf("%g", *cfg.temperature)
+	}
+	return fmt.Sprintf("%s\x00%v\x00%s", cfg.instructions, cfg.audioOutput(), temperature)
+}
+
+func (g *realtimeBridgeGeminiLive) connect(cfg realtimeBridgeConfig) error {
+	g.close()
+	info := g.s.info
+	liveURL, err := geminiLiveURL(info.ChannelBaseUrl, info.ApiKey)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(g.s.ctx, geminiLiveSetupTimeout)
+	defer cancel()
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, liveURL, nil)
+	if err != nil {
+		return fmt.Errorf("dial gemini live failed: %w", err)
+	}
+	// Gemini Live 的音色独立于 OpenAI，只使用渠道配置的音色
+	if err := conn.WriteJSON(geminiLiveSetup(info.UpstreamModelName, cfg, g.s.settings.Voice)); err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("send gemini live setup failed: %w", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(geminiLiveSetupTimeout))
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			_ = conn.Close()
+			return fmt.Errorf("gemini live setup failed: %w", err)
+		}
+		if gjson.GetBytes(message, "setupComplete").Exists() {
+			break
+		}
+		if errMsg := gjson.GetBytes(message, "error.message"); errMsg.Exists() {
+			_ = conn.Close()
+			return fmt.Errorf("gemini live setup failed: %s", errMsg.String())
+		}
+	}
+	g.writeMu.Lock()
+	g.conn = conn
+	g.activityOpen = false
+	g.writeMu.Unlock()
+	g.setupKey = g.configKey(cfg)
+	return nil
+}
+
+func (g *realtimeBridgeGeminiLive) send(payload map[string]any) error {
+	g.writeMu.Lock()
+	defer g.writeMu.Unlock()
+	return g.sendLocked(payload)
+}
+
+func (g *realtimeBridgeGeminiLive) sendLocked(payload map[string]any) error {
+	data, err := common.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return g.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// interrupt 在关闭自动活动检测时，activityStart 会让 Gemini 立即停止当前生成并返回 interrupted
+func (g *realtimeBridgeGeminiLive) interrupt(conn *websocket.Conn) {
+	g.writeMu.Lock()
+	defer g.writeMu.Unlock()
+	if g.conn != conn || g.activityOpen {
+		return
+	}
+	if err := g.sendLocked(map[string]any{"realtimeInput": map[string]any{"activityStart": map[string]any{}}}); err == nil {
+		g.activityOpen = true
+	}
+}
+
+// closeActivity 结束打断时打开的活动，之后才能以 clientContent 提交文本
+func (g *realtimeBridgeGeminiLive) closeActivity() error {
+	g.writeMu.Lock()
+	defer g.writeMu.Unlock()
+	if !g.activityOpen {
+		return nil
+	}
+	g.activityOpen = false
+	return g.sendLocked(map[string]any{"realtimeInput": map[string]any{"activityEnd": map[string]any{}}})
+}
+
+func (g *realtimeBridgeGeminiLive) sendAudio(pcm []byte) error {
+	g.writeMu.Lock()
+	activityOpen := g.activityOpen
+	g.activityOpen = false
+	g.writeMu.Unlock()
+	if !activityOpen {
+		if err := g.send(map[string]any{"realtimeInput": map[string]any{"activityStart": map[string]any{}}}); err != nil {
+			return err
+		}
+	}
+	for len(pcm) > 0 {
+		chunk := pcm[:min(len(pcm), geminiLiveAudioChunkBytes)]
+		pcm = pcm[len(chunk):]
+		err := g.send(map[string]any{"realtimeInput": map[string]any{
+			"audio": map[string]any{"data": base64.StdEncoding.EncodeToString(chunk), "mimeType": geminiLiveInputMimeType},
+		}})
+		if err != nil {
+			return err
+		}
+	}
+	return g.send(map[string]any{"realtimeInput": map[string]any{"activityEnd": map[string]any{}}})
+}
+
+func (g *realtimeBridgeGeminiLive) runTurn(ctx context.Context, turn *realtimeBridgeTurn, resp *realtimeBridgeResponse) error {
+	if !g.started && g.configKey(turn.config) != g.setupKey {
+		if err := g.connect(turn.config); err != nil {
+			return err
+		}
+	}
+	g.started = true
+	if g.conn == nil {
+		return errors.New("gemini live connection is closed")
+	}
+
+	g.pendingAudio = append(g.pendingAudio, turn.audio...)
+	if turn.text != "" {
+		if err := g.closeActivity(); err != nil {
+			return err
+		}
+		err := g.send(map[string]any{"clientContent": map[string]any{
+			"turns":        []map[string]any{{"role": "user", "parts": []map[string]any{{"text": turn.text}}}},
+			"turnComplete": resp != nil && len(g.pendingAudio) == 0,
+		}})
+		if err != nil {
+			return err
+		}
+	}
+	if resp == nil {
+		return nil
+	}
+
+	switch {
+	case len(g.pendingAudio) > 0:
+		audio := g.pendingAudio
+		g.pendingAudio = nil
+		if err := g.sendAudio(audio); err != nil {
+			return err
+		}
+	case turn.text == "":
+		if err := g.closeActivity(); err != nil {
+			return err
+		}
+		if err := g.send(map[string]any{"clientContent": map[string]any{"turnComplete": true}}); err != nil {
+			return err
+		}
+	}
+	return g.readResponse(ctx, turn, resp)
+}
+
+// readResponse 读取本轮回复直到 turnComplete。用户打断或 response.cancel 时通知 Gemini 停止生成，
+// 并读完剩余消息（不再推送给客户端）以保持连接上的轮次对齐；会话结束时直接关闭上游连接，避免阻塞在读取上
+func (g *realtimeBridgeGeminiLive) readResponse(ctx context.Context, turn *realtimeBridgeTurn, resp *realtimeBridgeResponse) error {
+	conn := g.conn
+	stop := context.AfterFunc(ctx, func() {
+		if g.s.ctx.Err() != nil {
+			_ = conn.Close()
+			return
+		}
+		g.interrupt(conn)
+	})
+	defer stop()
+
+	var inputTranscript, outputTranscript strings.Builder
+	usageReported := false
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(geminiLiveReadTimeout))
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			g.close()
+			return fmt.Errorf("gemini live read failed: %w", err)
+		}
+		result := gjson.ParseBytes(message)
+		if errMsg := result.Get("error.message"); errMsg.Exists() {
+			return fmt.Errorf("gemini live error: %s", errMsg.String())
+		}
+		if result.Get("goAway").Exists() {
+			g.close()
+			return errors.New("gemini live session closed by upstream")
+		}
+		if usage := result.Get("usageMetadata"); usage.Exists() {
+			turn.textInputTokens, turn.textOutputTokens = geminiLiveTextTokens(usage)
+			usageReported = true
+		}
+
+		content := result.Get("serverContent")
+		if text := content.Get("inputTranscription.text").String(); text != "" {
+			inputTranscript.WriteString(text)
+		}
+		if text := content.Get("outputTranscription.text").String(); text != "" {
+			outputTranscript.WriteString(text)
+			resp.textDelta(text)
+		}
+		for _, part := range content.Get("modelTurn.parts").Array() {
+			if data := part.Get("inlineData.data").String(); data != "" {
+				if pcm, err := base64.StdEncoding.DecodeString(data); err == nil {
+					resp.audioDelta(pcm)
+				}
+				continue
+			}
+			// 纯文本模式下回复在 parts 中，音频模式的文字来自 outputTranscription
+			if text := part.Get("text").String(); text != "" && !resp.audio && !part.Get("thought").Bool() {
+				outputTranscript.WriteString(text)
+				resp.textDelta(text)
+			}
+		}
+
+		if content.Get("turnComplete").Bool() || content.Get("interrupted").Bool() {
+			break
+		}
+	}
+
+	if turn.itemId != "" && inputTranscript.Len() > 0 {
+		g.s.sendInputTranscript(turn.itemId, strings.TrimSpace(inputTranscript.String()))
+	}
+	if !usageReported {
+		model := g.s.info.UpstreamModelName
+		turn.addTextUsage(service.CountTextToken(turn.config.instructions+inputTranscript.String(), model),
+			service.CountTextToken(outputTranscript.String(), model))
+	}
+	return nil
+}
+
+// geminiLiveTextTokens 从 usageMetadata 中取出文本模态的 token 数，音频部分按时长计费
+func geminiLiveTextTokens(usage gjson.Result) (int, int) {
+	count := func(details gjson.Result) int {
+		total := 0
+		for _, detail := range details.Array() {
+			if strings.EqualFold(detail.Get("modality").String(), "TEXT") {
+				total += int(detail.Get("tokenCount").Int())
+			}
+		}
+		return total
+	}
+	return count(usage.Get("promptTokensDetails")), count(usage.Get("responseTokensDetails"))
+}
@@ -0,0 +1,221 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/zhongruan0522/new-api/dto"
+	relayconstant "github.com/zhongruan0522/new-api/relay/constant"
+	"github.com/zhongruan0522/new-api/relay/realtime"
+	"github.com/zhongruan0522/new-api/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// 保留的最近对话条数，避免长会话的上下文无限增长
+	realtimeBridgeMaxHistory = 40
+	// 回复按句切分后逐段合成语音，过短的句子与后文合并，减少 TTS 调用次数
+	realtimeBridgeMinSegmentRunes = 24
+	realtimeBridgeDefaultVoice    = "alloy"
+)
+
+// RealtimeStageInvoker 以内部请求的方式把桥接中的 STT / chat / TTS 阶段路由到用户分组下提供对应模型的渠道，
+// 返回按阶段模型价格计算的额度，由会话统一扣费；由 controller 在启动时注入
+var RealtimeStageInvoker func(c *gin.Context, stage *RealtimeStage) (*RealtimeStageResult, error)
+
+type RealtimeStage struct {
+	Ctx context.Context
+	// RelayMode 为 RelayModeAudioTranscription / RelayModeChatCompletions / RelayModeAudioSpeech 之一
+	RelayMode int
+	Model     string
+	Group     string
+
+	// Audio 为转写阶段上传的 WAV 音频
+	Audio  []byte
+	Chat   *dto.GeneralOpenAIRequest
+	Speech *dto.AudioRequest
+}
+
+type RealtimeStageResult struct {
+	Body  []byte
+	Usage *dto.Usage
+	// Quota 为按阶段模型价格计算的额度，ChannelId 为实际处理该阶段的渠道
+	Quota     int
+	ChannelId int
+}
+
+// realtimeBridgePipeline 依次调用 STT、chat、TTS 三个模型完成一轮语音对话
+type realtimeBridgePipeline struct {
+	s       *realtimeBridgeSession
+	history []dto.Message
+}
+
+func newRealtimeBridgePipeline(s *realtimeBridgeSession) (*realtimeBridgePipeline, error) {
+	if RealtimeStageInvoker == nil {
+		return nil, errors.New("realtime stage invoker is not configured")
+	}
+	if s.settings.STTModel == "" || s.settings.ChatModel == "" || s.settings.TTSModel == "" {
+		return nil, errors.New("realtime_bridge pipeline requires stt_model, chat_model and tts_model")
+	}
+	return &realtimeBridgePipeline{s: s}, nil
+}
+
+func (p *realtimeBridgePipeline) close() {}
+
+func (p *realtimeBridgePipeline) runTurn(ctx context.Context, turn *realtimeBridgeTurn, resp *realtimeBridgeResponse) error {
+	if len(turn.audio) > 0 {
+		// 转写使用会话级 ctx，用户打断只取消回复，不丢弃已说完的话
+		transcript, err := p.transcribe(p.s.ctx, turn, turn.audio)
+		if err != nil {
+			return fmt.Errorf("transcription failed: %w", err)
+		}
+		p.s.sendInputTranscript(turn.itemId, transcript)
+		turn.text = transcript
+	}
+	if text := strings.TrimSpace(turn.text); text != "" {
+		p.appendHistory(dto.Message{Role: "user", Content: text})
+	}
+	if resp == nil {
+		return nil
+	}
+
+	reply, err := p.chat(ctx, turn)
+	if err != nil {
+		return fmt.Errorf("chat failed: %w", err)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	p.appendHistory(dto.Message{Role: "assistant", Content: reply})
+
+	voice := turn.config.voice
+	if voice == "" {
+		voice = realtimeBridgeDefaultVoice
+	}
+	for _, segment := range splitSpeechSegments(reply) {
+		resp.textDelta(segment)
+		if !resp.audio || strings.TrimSpace(segment) == "" {
+			continue
+		}
+		pcm, err := p.speak(ctx, turn, segment, voice)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("speech synthesis failed: %w", err)
+		}
+		resp.audioDelta(pcm)
+	}
+	return nil
+}
+
+func (p *realtimeBridgePipeline) appendHistory(message dto.Message) {
+	p.history = append(p.history, message)
+	if len(p.history) > realtimeBridgeMaxHistory {
+		p.history = p.history[len(p.history)-realtimeBridgeMaxHistory:]
+	}
+}
+
+func (p *realtimeBridgePipeline) invoke(turn *realtimeBridgeTurn, stage *RealtimeStage) (*RealtimeStageResult, error) {
+	stage.Group = p.s.info.TokenGroup
+	result, err := RealtimeStageInvoker(p.s.c, stage)
+	if err != nil {
+		return nil, err
+	}
+	turn.addStageQuota(stage.Model, result.Quota)
+	return result, nil
+}
+
+func (p *realtimeBridgePipeline) transcribe(ctx context.Context, turn *realtimeBridgeTurn, pcm []byte) (string, error) {
+	result, err := p.invoke(turn, &RealtimeStage{
+		Ctx:       ctx,
+		RelayMode: relayconstant.RelayModeAudioTranscription,
+		Model:     p.s.settings.STTModel,
+		Audio:     realtime.EncodeWAV(pcm, realtime.SampleRate),
+	})
+	if err != nil {
+		return "", err
+	}
+	if text := gjson.GetBytes(result.Body, "text"); text.Exists() {
+		return strings.TrimSpace(text.String()), nil
+	}
+	return strings.TrimSpace(string(result.Body)), nil
+}
+
+func (p *realtimeBridgePipeline) chat(ctx context.Context, turn *realtimeBridgeTurn) (string, error) {
+	messages := make([]dto.Message, 0, len(p.history)+1)
+	if turn.config.instructions != "" {
+		messages = append(messages, dto.Message{Role: "system", Content: turn.config.instructions})
+	}
+	messages = append(messages, p.history...)
+	request := &dto.GeneralOpenAIRequest{
+		Model:       p.s.settings.ChatModel,
+		Messages:    messages,
+		Temperature: turn.config.temperature,
+	}
+	result, err := p.invoke(turn, &RealtimeStage{
+		Ctx:       ctx,
+		RelayMode: relayconstant.RelayModeChatCompletions,
+		Model:     p.s.settings.ChatModel,
+		Chat:      request,
+	})
+	if err != nil {
+		return "", err
+	}
+	reply := strings.TrimSpace(gjson.GetBytes(result.Body, "choices.0.message.content").String())
+	if result.Usage != nil && result.Usage.TotalTokens > 0 {
+		turn.addTextUsage(result.Usage.PromptTokens, result.Usage.CompletionTokens)
+	} else {
+		prompt := ""
+		for _, message := range messages {
+			prompt += message.StringContent() + "\n"
+		}
+		turn.addTextUsage(service.CountTextToken(prompt, p.s.settings.ChatModel), service.CountTextToken(reply, p.s.settings.ChatModel))
+	}
+	return reply, nil
+}
+
+func (p *realtimeBridgePipeline) speak(ctx context.Context, turn *realtimeBridgeTurn, text string, voice string) ([]byte, error) {
+	result, err := p.invoke(turn, &RealtimeStage{
+		Ctx:       ctx,
+		RelayMode: relayconstant.RelayModeAudioSpeech,
+		Model:     p.s.settings.TTSModel,
+		Speech: &dto.AudioRequest{
+			Model:          p.s.settings.TTSModel,
+			Input:          text,
+			Voice:          voice,
+			ResponseFormat: "pcm",
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return realtime.DecodeSpeechAudio(result.Body)
+}
+
+// splitSpeechSegments 按句末标点切分回复，便于边合成边播放
+func splitSpeechSegments(text string) []string {
+	var (
+		segments []string
+		current  strings.Builder
+	)
+	for _, r := range text {
+		current.WriteRune(r)
+		switch r {
+		case '。', '！', '？', '；', '.', '!', '?', ';', '\n':
+			if utf8.RuneCountInString(current.String()) >= realtimeBridgeMinSegmentRunes {
+				segments = append(segments, current.String())
+				current.Reset()
+			}
+		}
+	}
+	if current.Len() > 0 {
+		segments = append(segments, current.String())
+	}
+	return segments
+}
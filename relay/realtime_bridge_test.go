@@ -0,0 +1,256 @@
+package relay
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/dto"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/relay/realtime"
+	"github.com/zhongruan0522/new-api/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+type fakeRealtimeBridgeBackend struct {
+	turns []*realtimeBridgeTurn
+}
+
+func (f *fakeRealtimeBridgeBackend) runTurn(ctx context.Context, turn *realtimeBridgeTurn, resp *realtimeBridgeResponse) error {
+	f.turns = append(f.turns, turn)
+	turn.addTextUsage(10, 5)
+	if len(turn.audio) > 0 {
+		resp.s.sendInputTranscript(turn.itemId, "hello")
+	}
+	if resp != nil {
+		resp.textDelta("hi there")
+		resp.audioDelta(make([]byte, realtime.SampleRate*2))
+	}
+	return nil
+}
+
+func (f *fakeRealtimeBridgeBackend) close() {}
+
+func bridgeTestAudio(ms int, amplitude float64) []byte {
+	samples := ms * realtime.SampleRate / 1000
+	out := make([]byte, 0, samples*2)
+	for i := 0; i < samples; i++ {
+		value := amplitude * 32767 * math.Sin(2*math.Pi*440*float64(i)/realtime.SampleRate)
+		out = binary.LittleEndian.AppendUint16(out, uint16(int16(value)))
+	}
+	return out
+}
+
+func TestRealtimeBridgeSessionVADTurn(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	backend := &fakeRealtimeBridgeBackend{}
+	usageCh := make(chan *dto.RealtimeUsage, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = r
+		info := &relaycommon.RelayInfo{ClientWs: ws, ChannelMeta: &relaycommon.ChannelMeta{}}
+		// 按次计价时预扣费直接跳过，避免测试访问数据库
+		info.PriceData.UsePrice = true
+		session := newRealtimeBridgeSession(c, info, dto.RealtimeBridgeSettings{})
+		session.backend = backend
+		usageCh <- session.run()
+	}))
+	defer server.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	readEvent := func() *dto.RealtimeEvent {
+		event := &dto.RealtimeEvent{}
+		if err := client.ReadJSON(event); err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		return event
+	}
+	if event := readEvent(); event.Type != dto.RealtimeEventTypeSessionCreated {
+		t.Fatalf("first event = %s", event.Type)
+	}
+
+	_ = client.WriteJSON(map[string]any{"type": "session.update", "session": map[string]any{
+		"instructions":   "be brief",
+		"turn_detection": map[string]any{"type": "server_vad", "silence_duration_ms": 200},
+	}})
+	if event := readEvent(); event.Type != dto.RealtimeEventTypeSessionUpdated || event.Session.Instructions != "be brief" {
+		t.Fatalf("session.updated = %+v", event)
+	}
+
+	audio := append(bridgeTestAudio(200, 0), bridgeTestAudio(300, 0.3)...)
+	audio = append(audio, bridgeTestAudio(300, 0)...)
+	_ = client.WriteJSON(map[string]any{"type": "input_audio_buffer.append", "audio": base64.StdEncoding.EncodeToString(audio)})
+
+	var got []string
+	var done *dto.RealtimeEvent
+	for done == nil {
+		event := readEvent()
+		got = append(got, event.Type)
+		if event.Type == dto.RealtimeEventTypeResponseDone {
+			done = event
+		}
+	}
+	want := []string{
+		dto.RealtimeEventInputAudioBufferSpeechStarted,
+		dto.RealtimeEventInputAudioBufferSpeechStopped,
+		dto.RealtimeEventInputAudioBufferCommitted,
+		dto.RealtimeEventConversationItemCreated,
+		dto.RealtimeEventResponseCreated,
+		dto.RealtimeEventResponseOutputItemAdded,
+		dto.RealtimeEventInputAudioTranscriptionComplete,
+		dto.RealtimeEventResponseAudioTranscriptionDelta,
+		dto.RealtimeEventResponseAudioDelta,
+		dto.RealtimeEventResponseAudioDelta,
+		dto.RealtimeEventResponseAudioDelta,
+		dto.RealtimeEventResponseAudioDelta,
+		dto.RealtimeEventResponseAudioDelta,
+		dto.RealtimeEventResponseAudioDone,
+		dto.RealtimeEventResponseAudioTranscriptionDone,
+		dto.RealtimeEventResponseOutputItemDone,
+		dto.RealtimeEventTypeResponseDone,
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("events =\n%v\nwant\n%v", got, want)
+	}
+	if done.Response.Status != "completed" || done.Response.Output[0].Content[0].Transcript != "hi there" {
+		t.Fatalf("response.done = %+v", done.Response)
+	}
+	if got := done.Response.Usage.OutputTokenDetails.AudioTokens; got != service.AudioOutputTokensForDuration(1) {
+		t.Fatalf("output audio tokens = %d", got)
+	}
+	if len(backend.turns) != 1 || backend.turns[0].config.instructions != "be brief" {
+		t.Fatalf("backend turns = %+v", backend.turns)
+	}
+
+	_ = client.Close()
+	select {
+	case usage := <-usageCh:
+		if usage.InputTokenDetails.TextTokens != 10 || usage.OutputTokenDetails.TextTokens != 5 || usage.InputTokenDetails.AudioTokens == 0 {
+			t.Fatalf("session usage = %+v", usage)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("session did not finish after the client closed")
+	}
+}
+
+func TestResolveRealtimeBridgeMode(t *testing.T) {
+	info := &relaycommon.RelayInfo{ChannelMeta: &relaycommon.ChannelMeta{ApiType: constant.APITypeGemini}}
+	if resolveRealtimeBridgeMode(info) != dto.RealtimeBridgeModeGeminiLive {
+		t.Fatal("gemini channels should default to gemini_live")
+	}
+	info.ChannelOtherSettings.RealtimeBridge = &dto.RealtimeBridgeSettings{Mode: "Pipeline"}
+	if resolveRealtimeBridgeMode(info) != dto.RealtimeBridgeModePipeline {
+		t.Fatal("configured mode should win")
+	}
+	info = &relaycommon.RelayInfo{ChannelMeta: &relaycommon.ChannelMeta{}}
+	if resolveRealtimeBridgeMode(info) != "" {
+		t.Fatal("openai channels keep proxying natively")
+	}
+}
+
+// newGeminiLiveTestConn 启动模拟的 Gemini Live 上游，onMessage 处理收到的每条消息
+func newGeminiLiveTestConn(t *testing.T, onMessage func(ws *websocket.Conn, message []byte)) *websocket.Conn {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		for {
+			_, message, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			onMessage(ws, message)
+		}
+	}))
+	t.Cleanup(server.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestGeminiLiveInterruptsGenerationOnBargeIn(t *testing.T) {
+	received := make(chan string, 4)
+	conn := newGeminiLiveTestConn(t, func(ws *websocket.Conn, message []byte) {
+		received <- string(message)
+		if strings.Contains(string(message), "activityStart") {
+			_ = ws.WriteMessage(websocket.TextMessage, []byte(`{"serverContent":{"interrupted":true}}`))
+		}
+	})
+
+	sessionCtx, cancelSession := context.WithCancel(context.Background())
+	defer cancelSession()
+	session := &realtimeBridgeSession{ctx: sessionCtx, info: &relaycommon.RelayInfo{ChannelMeta: &relaycommon.ChannelMeta{}}}
+	g := &realtimeBridgeGeminiLive{s: session, conn: conn}
+
+	ctx, cancel := context.WithCancel(sessionCtx)
+	time.AfterFunc(50*time.Millisecond, cancel)
+	turn := &realtimeBridgeTurn{}
+	if err := g.readResponse(ctx, turn, &realtimeBridgeResponse{s: session, ctx: ctx}); err != nil {
+		t.Fatalf("readResponse error = %v", err)
+	}
+	select {
+	case message := <-received:
+		if !strings.Contains(message, "activityStart") {
+			t.Fatalf("upstream message = %s, want activityStart", message)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("barge-in did not send an interrupt upstream")
+	}
+	g.writeMu.Lock()
+	activityOpen := g.activityOpen
+	g.writeMu.Unlock()
+	if !activityOpen {
+		t.Fatal("activity opened by the interrupt should be reused by the next audio turn")
+	}
+}
+
+func TestGeminiLiveClosesUpstreamWhenSessionEnds(t *testing.T) {
+	// 上游不再回复，模拟生成过程中客户端断开
+	conn := newGeminiLiveTestConn(t, func(ws *websocket.Conn, message []byte) {})
+
+	sessionCtx, cancelSession := context.WithCancel(context.Background())
+	session := &realtimeBridgeSession{ctx: sessionCtx, info: &relaycommon.RelayInfo{ChannelMeta: &relaycommon.ChannelMeta{}}}
+	g := &realtimeBridgeGeminiLive{s: session, conn: conn}
+
+	ctx, cancel := context.WithCancel(sessionCtx)
+	defer cancel()
+	time.AfterFunc(50*time.Millisecond, cancelSession)
+	done := make(chan error, 1)
+	go func() {
+		done <- g.readResponse(ctx, &realtimeBridgeTurn{}, &realtimeBridgeResponse{s: session, ctx: ctx})
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("readResponse should fail once the upstream connection is closed")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("readResponse kept blocking after the session ended")
+	}
+}
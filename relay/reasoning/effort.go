@@ -0,0 +1,117 @@
+package reasoning
+
+import "strings"
+
+// 各协议的推理强度在此统一换算：
+// OpenAI reasoning_effort <-> Claude thinking.budget_tokens <-> Gemini thinkingBudget / thinkingLevel
+const (
+	EffortNone    = "none"
+	EffortMinimal = "minimal"
+	EffortLow     = "low"
+	EffortMedium  = "medium"
+	EffortHigh    = "high"
+	EffortXHigh   = "xhigh"
+)
+
+// ClaudeMinBudgetTokens Claude 要求 thinking.budget_tokens 不小于 1024
+const ClaudeMinBudgetTokens = 1024
+
+// claudeEffortBudgets 沿用既有的 OpenAI -> Claude 换算；minimal 不开启 thinking
+var claudeEffortBudgets = map[string]int{
+	EffortLow:    1280,
+	EffortMedium: 2048,
+	EffortHigh:   4096,
+	EffortXHigh:  8192,
+}
+
+// geminiEffortBudgets 供只接受 thinkingBudget 的 Gemini 2.x 使用，与 GeminiBudgetToEffort 的分档对齐
+var geminiEffortBudgets = map[string]int{
+	EffortMinimal: 512,
+	EffortLow:     1024,
+	EffortMedium:  8192,
+	EffortHigh:    24576,
+	EffortXHigh:   32768,
+}
+
+// NormalizeEffort 统一大小写与别名，"max" 视为 "xhigh"；未知取值原样（小写）返回
+func NormalizeEffort(effort string) string {
+	effort = strings.ToLower(strings.TrimSpace(effort))
+	if effort == "max" {
+		return EffortXHigh
+	}
+	return effort
+}
+
+// ClaudeEffortToBudget 将推理强度换算为 Claude thinking.budget_tokens，none、minimal 或未知取值返回 0
+func ClaudeEffortToBudget(effort string) int {
+	return claudeEffortBudgets[NormalizeEffort(effort)]
+}
+
+// ClaudeBudgetToEffort 将 Claude thinking.budget_tokens 换算为推理强度，<=0 返回空串。
+// 只输出 low / medium / high 三档，保证大多数 OpenAI 兼容上游都能识别
+func ClaudeBudgetToEffort(budget int) string {
+	return budgetToEffort(budget, claudeEffortBudgets)
+}
+
+// GeminiEffortToBudget 将推理强度换算为 Gemini thinkingBudget，none 或未知取值返回 0
+func GeminiEffortToBudget(effort string) int {
+	return geminiEffortBudgets[NormalizeEffort(effort)]
+}
+
+// GeminiBudgetToEffort 将 Gemini thinkingBudget 换算为推理强度，<=0（关闭或动态预算）返回空串
+func GeminiBudgetToEffort(budget int) string {
+	return budgetToEffort(budget, geminiEffortBudgets)
+}
+
+func budgetToEffort(budget int, budgets map[string]int) string {
+	switch {
+	case budget <= 0:
+		return ""
+	case budget <= budgets[EffortLow]:
+		return EffortLow
+	case budget <= budgets[EffortMedium]:
+		return EffortMedium
+	default:
+		return EffortHigh
+	}
+}
+
+// FitBudget 保证预算小于 max_tokens（Claude 的硬性要求），同时不低于 Claude 的最小预算
+func FitBudget(budget int, maxTokens int) int {
+	if maxTokens > 0 && budget >= maxTokens {
+		budget = maxTokens - 1
+	}
+	return max(budget, ClaudeMinBudgetTokens)
+}
+
+// EffortToGeminiThinkingLevel 将推理强度换算为 Gemini 3 的 thinkingLevel，minimal 归入 low，xhigh 归入 high
+func EffortToGeminiThinkingLevel(effort string) string {
+	return coarseEffort(effort)
+}
+
+// GeminiThinkingLevelToEffort 将 Gemini thinkingLevel 换算为推理强度
+func GeminiThinkingLevelToEffort(level string) string {
+	return coarseEffort(level)
+}
+
+func coarseEffort(effort string) string {
+	switch effort = NormalizeEffort(effort); effort {
+	case "", EffortNone:
+		return ""
+	case EffortMinimal, EffortLow:
+		return EffortLow
+	case EffortHigh, EffortXHigh:
+		return EffortHigh
+	default:
+		return effort
+	}
+}
+
+// UsesGeminiThinkingLevel Gemini 3 起使用 thinkingLevel，之前的型号只接受 thinkingBudget
+func UsesGeminiThinkingLevel(model string) bool {
+	model = strings.ToLower(model)
+	if idx := strings.LastIndex(model, "/"); idx >= 0 {
+		model = model[idx+1:]
+	}
+	return strings.HasPrefix(model, "gemini-") && !strings.HasPrefix(model, "gemini-1") && !strings.HasPrefix(model, "gemini-2")
+}
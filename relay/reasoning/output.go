@@ -0,0 +1,280 @@
+package reasoning
+
+import (
+	"strings"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+)
+
+const (
+	thinkOpenTag  = "<think>"
+	thinkCloseTag = "</think>"
+)
+
+// 推理内容在 Chat Completions 响应中可能出现的字段
+var reasoningTextFields = []string{"reasoning_content", "reasoning"}
+
+var reasoningMetaFields = []string{"reasoning_signature", "redacted_reasoning_content"}
+
+// RewriteChatResponse 按输出模式改写非流式 chat.completion 响应体，无法解析时原样返回
+func RewriteChatResponse(mode dto.ReasoningOutputMode, body []byte) []byte {
+	if mode == dto.ReasoningOutputNative || mode == "" {
+		return body
+	}
+	var payload map[string]any
+	if err := common.Unmarshal(body, &payload); err != nil {
+		return body
+	}
+	choices, _ := payload["choices"].([]any)
+	if len(choices) == 0 {
+		return body
+	}
+	for _, rawChoice := range choices {
+		choice, _ := rawChoice.(map[string]any)
+		message, _ := choice["message"].(map[string]any)
+		if message == nil {
+			continue
+		}
+		rewriteChatMessage(mode, message)
+	}
+	rewritten, err := common.Marshal(payload)
+	if err != nil {
+		return body
+	}
+	return rewritten
+}
+
+func rewriteChatMessage(mode dto.ReasoningOutputMode, message map[string]any) {
+	reasoningText := takeReasoningText(message)
+	content, isString := message["content"].(string)
+	if isString {
+		var inlineReasoning string
+		inlineReasoning, content = splitInlineThink(content)
+		reasoningText = joinReasoning(reasoningText, inlineReasoning)
+	}
+
+	switch mode {
+	case dto.ReasoningOutputHidden:
+		for _, field := range reasoningMetaFields {
+			delete(message, field)
+		}
+	case dto.ReasoningOutputField:
+		if reasoningText != "" {
+			message["reasoning_content"] = reasoningText
+		}
+	case dto.ReasoningOutputInline:
+		if reasoningText != "" {
+			content = thinkOpenTag + "\n" + reasoningText + "\n" + thinkCloseTag + "\n" + content
+			isString = true
+		}
+	}
+	if isString {
+		message["content"] = content
+	}
+}
+
+// takeReasoningText 取出并删除 reasoning_content / reasoning 字段中的推理文本
+func takeReasoningText(message map[string]any) string {
+	text := ""
+	for _, field := range reasoningTextFields {
+		if value, ok := message[field].(string); ok && text == "" {
+			text = value
+		}
+		delete(message, field)
+	}
+	return text
+}
+
+func joinReasoning(a, b string) string {
+	if a == "" {
+		return b
+	}
+	if b == "" {
+		return a
+	}
+	return a + "\n" + b
+}
+
+// splitInlineThink 拆分以 <think>...</think> 开头的内容，未闭合或不以标签开头时视为普通内容
+func splitInlineThink(content string) (string, string) {
+	trimmed := strings.TrimLeft(content, " \t\r\n")
+	if !strings.HasPrefix(trimmed, thinkOpenTag) {
+		return "", content
+	}
+	inner, rest, found := strings.Cut(trimmed[len(thinkOpenTag):], thinkCloseTag)
+	if !found {
+		return "", content
+	}
+	return strings.Trim(inner, "\n"), strings.TrimLeft(rest, "\n")
+}
+
+// ChatStreamRewriter 按输出模式逐个改写 chat.completion.chunk，需按顺序喂入同一个流的全部数据块
+type ChatStreamRewriter struct {
+	mode    dto.ReasoningOutputMode
+	choices map[int]*streamChoiceState
+}
+
+type thinkPhase int
+
+const (
+	thinkPhaseUndecided thinkPhase = iota
+	thinkPhaseInside
+	thinkPhaseContent
+)
+
+type streamChoiceState struct {
+	// inline 模式下是否已输出 <think> 且尚未闭合
+	thinkingOpen bool
+
+	// field / hidden 模式下识别 content 开头的 <think> 块
+	phase        thinkPhase
+	pending      string
+	trimNewlines bool
+}
+
+func NewChatStreamRewriter(mode dto.ReasoningOutputMode) *ChatStreamRewriter {
+	return &ChatStreamRewriter{mode: mode, choices: make(map[int]*streamChoiceState)}
+}
+
+// Rewrite 改写一个数据块的 JSON，无法解析的数据原样返回
+func (r *ChatStreamRewriter) Rewrite(data []byte) []byte {
+	if r.mode == dto.ReasoningOutputNative || r.mode == "" {
+		return data
+	}
+	var chunk map[string]any
+	if err := common.Unmarshal(data, &chunk); err != nil {
+		return data
+	}
+	choices, _ := chunk["choices"].([]any)
+	if len(choices) == 0 {
+		return data
+	}
+	for _, rawChoice := range choices {
+		choice, _ := rawChoice.(map[string]any)
+		delta, _ := choice["delta"].(map[string]any)
+		if delta == nil {
+			continue
+		}
+		index := 0
+		if value, ok := choice["index"].(float64); ok {
+			index = int(value)
+		}
+		state := r.choices[index]
+		if state == nil {
+			state = &streamChoiceState{}
+			r.choices[index] = state
+		}
+		finished := choice["finish_reason"] != nil
+		r.rewriteDelta(state, delta, finished)
+	}
+	rewritten, err := common.Marshal(chunk)
+	if err != nil {
+		return data
+	}
+	return rewritten
+}
+
+func (r *ChatStreamRewriter) rewriteDelta(state *streamChoiceState, delta map[string]any, finished bool) {
+	reasoningText := takeReasoningText(delta)
+	content, hasContent := delta["content"].(string)
+
+	if r.mode == dto.ReasoningOutputInline {
+		var out strings.Builder
+		if reasoningText != "" {
+			if !state.thinkingOpen {
+				out.WriteString(thinkOpenTag + "\n")
+				state.thinkingOpen = true
+			}
+			out.WriteString(reasoningText)
+		}
+		_, hasToolCalls := delta["tool_calls"]
+		if state.thinkingOpen && (content != "" || hasToolCalls || finished) {
+			out.WriteString("\n" + thinkCloseTag + "\n")
+			state.thinkingOpen = false
+		}
+		out.WriteString(content)
+		if out.Len() > 0 || hasContent {
+			delta["content"] = out.String()
+		}
+		return
+	}
+
+	inlineReasoning, content := state.feedContent(content, finished)
+	reasoningText += inlineReasoning
+	switch r.mode {
+	case dto.ReasoningOutputHidden:
+		for _, field := range reasoningMetaFields {
+			delete(delta, field)
+		}
+	case dto.ReasoningOutputField:
+		if reasoningText != "" {
+			delta["reasoning_content"] = reasoningText
+		}
+	}
+	if hasContent || content != "" {
+		delta["content"] = content
+	}
+}
+
+// feedContent 增量识别 content 开头的 <think> 块，返回本次可输出的推理文本与正文；
+// 可能是标签前缀的片段会暂存到下一个数据块，finished 时全部输出
+func (s *streamChoiceState) feedContent(text string, finished bool) (string, string) {
+	var reasoningOut, contentOut strings.Builder
+	buf := s.pending + text
+	s.pending = ""
+
+	if s.phase == thinkPhaseUndecided {
+		trimmed := strings.TrimLeft(buf, " \t\r\n")
+		switch {
+		case strings.HasPrefix(trimmed, thinkOpenTag):
+			s.phase = thinkPhaseInside
+			buf = strings.TrimLeft(trimmed[len(thinkOpenTag):], "\n")
+		case trimmed == "" || strings.HasPrefix(thinkOpenTag, trimmed):
+			if finished {
+				contentOut.WriteString(buf)
+			} else {
+				s.pending = buf
+			}
+			return "", contentOut.String()
+		default:
+			s.phase = thinkPhaseContent
+		}
+	}
+
+	if s.phase == thinkPhaseInside {
+		if before, after, found := strings.Cut(buf, thinkCloseTag); found {
+			reasoningOut.WriteString(strings.TrimRight(before, "\n"))
+			s.phase = thinkPhaseContent
+			s.trimNewlines = true
+			buf = after
+		} else {
+			hold := 0
+			if !finished {
+				hold = partialSuffixLen(buf, thinkCloseTag)
+			}
+			reasoningOut.WriteString(buf[:len(buf)-hold])
+			s.pending = buf[len(buf)-hold:]
+			return reasoningOut.String(), ""
+		}
+	}
+
+	if s.trimNewlines {
+		buf = strings.TrimLeft(buf, "\n")
+		if buf != "" {
+			s.trimNewlines = false
+		}
+	}
+	contentOut.WriteString(buf)
+	return reasoningOut.String(), contentOut.String()
+}
+
+// partialSuffixLen 返回 s 末尾与 tag 前缀重合的最大长度
+func partialSuffixLen(s string, tag string) int {
+	for n := min(len(s), len(tag)-1); n > 0; n-- {
+		if strings.HasSuffix(s, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,132 @@
+package reasoning
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zhongruan0522/new-api/dto"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestEffortBudgetTranslation(t *testing.T) {
+	for _, effort := range []string{EffortLow, EffortMedium, EffortHigh} {
+		if got := ClaudeBudgetToEffort(ClaudeEffortToBudget(effort)); got != effort {
+			t.Fatalf("ClaudeBudgetToEffort(ClaudeEffortToBudget(%q)) = %q", effort, got)
+		}
+		if got := GeminiBudgetToEffort(GeminiEffortToBudget(effort)); got != effort {
+			t.Fatalf("GeminiBudgetToEffort(GeminiEffortToBudget(%q)) = %q", effort, got)
+		}
+	}
+	// Claude 沿用既有的预算与分档，避免改变已有转换的费用
+	claudeBudgets := map[string]int{EffortMinimal: 0, EffortLow: 1280, EffortMedium: 2048, EffortHigh: 4096, "MAX": 8192, EffortNone: 0, "bogus": 0}
+	for effort, want := range claudeBudgets {
+		if got := ClaudeEffortToBudget(effort); got != want {
+			t.Fatalf("ClaudeEffortToBudget(%q) = %d, want %d", effort, got, want)
+		}
+	}
+	if ClaudeBudgetToEffort(-1) != "" || ClaudeBudgetToEffort(1280) != EffortLow || ClaudeBudgetToEffort(2048) != EffortMedium || ClaudeBudgetToEffort(4096) != EffortHigh {
+		t.Fatal("unexpected effort for claude budgets")
+	}
+	if GeminiBudgetToEffort(-1) != "" || GeminiBudgetToEffort(1024) != EffortLow || GeminiBudgetToEffort(8192) != EffortMedium || GeminiBudgetToEffort(10000) != EffortHigh {
+		t.Fatal("unexpected effort for gemini budgets")
+	}
+	if FitBudget(16384, 8192) != 8191 || FitBudget(512, 0) != ClaudeMinBudgetTokens {
+		t.Fatal("FitBudget must stay below max_tokens and above the Claude minimum")
+	}
+	if EffortToGeminiThinkingLevel(EffortXHigh) != EffortHigh || GeminiThinkingLevelToEffort("minimal") != EffortLow {
+		t.Fatal("unexpected gemini thinking level mapping")
+	}
+	if !UsesGeminiThinkingLevel("models/gemini-3-pro-preview") || UsesGeminiThinkingLevel("gemini-2.5-flash") {
+		t.Fatal("only Gemini 3+ models use thinkingLevel")
+	}
+}
+
+func TestRewriteChatResponse(t *testing.T) {
+	fieldBody := []byte(`{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":"<think>\nplan\n</think>\n\nanswer"},"finish_reason":"stop"}]}`)
+	got := gjson.ParseBytes(RewriteChatResponse(dto.ReasoningOutputField, fieldBody))
+	if got.Get("choices.0.message.reasoning_content").String() != "plan" || got.Get("choices.0.message.content").String() != "answer" {
+		t.Fatalf("field = %s", got.Raw)
+	}
+
+	nativeBody := []byte(`{"choices":[{"index":0,"message":{"role":"assistant","content":"answer","reasoning":"plan","reasoning_signature":"sig"}}]}`)
+	got = gjson.ParseBytes(RewriteChatResponse(dto.ReasoningOutputInline, nativeBody))
+	if got.Get("choices.0.message.content").String() != "<think>\nplan\n</think>\nanswer" || got.Get("choices.0.message.reasoning").Exists() ||
+		got.Get("choices.0.message.reasoning_signature").String() != "sig" {
+		t.Fatalf("inline = %s", got.Raw)
+	}
+
+	got = gjson.ParseBytes(RewriteChatResponse(dto.ReasoningOutputHidden, nativeBody))
+	if got.Get("choices.0.message.content").String() != "answer" || got.Get("choices.0.message.reasoning").Exists() ||
+		got.Get("choices.0.message.reasoning_signature").Exists() {
+		t.Fatalf("hidden = %s", got.Raw)
+	}
+
+	if string(RewriteChatResponse(dto.ReasoningOutputNative, nativeBody)) != string(nativeBody) {
+		t.Fatal("native mode must not touch the body")
+	}
+}
+
+func streamChunk(delta string, finish string) []byte {
+	chunk := `{"id":"1","choices":[{"index":0,"delta":` + delta + `,"finish_reason":null}]}`
+	if finish != "" {
+		chunk = strings.Replace(chunk, `"finish_reason":null`, `"finish_reason":"`+finish+`"`, 1)
+	}
+	return []byte(chunk)
+}
+
+func collectStream(t *testing.T, mode dto.ReasoningOutputMode, chunks [][]byte) (string, string) {
+	t.Helper()
+	rewriter := NewChatStreamRewriter(mode)
+	var reasoningText, content strings.Builder
+	for _, chunk := range chunks {
+		got := gjson.ParseBytes(rewriter.Rewrite(chunk))
+		if got.Get("choices.0.delta.reasoning").Exists() {
+			t.Fatalf("reasoning field should be normalized away: %s", got.Raw)
+		}
+		reasoningText.WriteString(got.Get("choices.0.delta.reasoning_content").String())
+		content.WriteString(got.Get("choices.0.delta.content").String())
+	}
+	return reasoningText.String(), content.String()
+}
+
+func TestChatStreamRewriterSplitsInlineThink(t *testing.T) {
+	chunks := [][]byte{
+		streamChunk(`{"role":"assistant","content":"  <thi"}`, ""),
+		streamChunk(`{"content":"nk>\nstep one"}`, ""),
+		streamChunk(`{"content":" step two</th"}`, ""),
+		streamChunk(`{"content":"ink>\n\n"}`, ""),
+		streamChunk(`{"content":"\nanswer"}`, ""),
+		streamChunk(`{}`, "stop"),
+	}
+	reasoningText, content := collectStream(t, dto.ReasoningOutputField, chunks)
+	if reasoningText != "step one step two" || content != "answer" {
+		t.Fatalf("field reasoning=%q content=%q", reasoningText, content)
+	}
+
+	reasoningText, content = collectStream(t, dto.ReasoningOutputHidden, chunks)
+	if reasoningText != "" || content != "answer" {
+		t.Fatalf("hidden reasoning=%q content=%q", reasoningText, content)
+	}
+
+	// 不以 <think> 开头的正文原样输出
+	reasoningText, content = collectStream(t, dto.ReasoningOutputField, [][]byte{
+		streamChunk(`{"content":"<"}`, ""),
+		streamChunk(`{"content":"b>bold</b>"}`, ""),
+	})
+	if reasoningText != "" || content != "<b>bold</b>" {
+		t.Fatalf("plain reasoning=%q content=%q", reasoningText, content)
+	}
+}
+
+func TestChatStreamRewriterInline(t *testing.T) {
+	reasoningText, content := collectStream(t, dto.ReasoningOutputInline, [][]byte{
+		streamChunk(`{"role":"assistant","reasoning_content":"plan"}`, ""),
+		streamChunk(`{"reasoning":" more"}`, ""),
+		streamChunk(`{"content":"answer"}`, ""),
+		streamChunk(`{}`, "stop"),
+	})
+	if reasoningText != "" || content != "<think>\nplan more\n</think>\nanswer" {
+		t.Fatalf("inline reasoning=%q content=%q", reasoningText, content)
+	}
+}
@@ -0,0 +1,166 @@
+package relay
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/dto"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	relayconstant "github.com/zhongruan0522/new-api/relay/constant"
+	"github.com/zhongruan0522/new-api/relay/reasoning"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reasoningOutputHeader 单次请求指定推理输出模式，优先级高于令牌设置
+const reasoningOutputHeader = "X-Reasoning-Output"
+
+// resolveReasoningOutputMode 按 请求头 > 令牌设置 的顺序确定推理输出模式，均未设置时返回空串沿用渠道原有行为
+func resolveReasoningOutputMode(c *gin.Context) (dto.ReasoningOutputMode, error) {
+	if raw := c.GetHeader(reasoningOutputHeader); raw != "" {
+		mode, ok := dto.ReasoningOutputMode(raw).Normalize()
+		if !ok {
+			return "", fmt.Errorf("invalid %s header: %q", reasoningOutputHeader, raw)
+		}
+		return mode, nil
+	}
+	if raw := common.GetContextKeyString(c, constant.ContextKeyTokenReasoningOutput); raw != "" {
+		// 令牌设置在保存时已校验，这里无法识别时按 native 处理
+		mode, _ := dto.ReasoningOutputMode(raw).Normalize()
+		return mode, nil
+	}
+	return "", nil
+}
+
+// wrapReasoningOutputWriter 对直接返回给 Chat Completions 客户端的响应按推理输出模式改写，
+// 返回的函数需在 DoResponse 之后调用以输出缓冲内容并还原 c.Writer。
+// 网关工具、结构化输出等多轮流程以及 Responses 转换时的中间结果不做改写。
+// 请求头取值已在 TextHelper 入口校验
+func wrapReasoningOutputWriter(c *gin.Context, info *relaycommon.RelayInfo) func() {
+	mode, _ := resolveReasoningOutputMode(c)
+	if mode == "" {
+		return func() {}
+	}
+	// 显式指定模式后由统一层处理，关闭渠道级 thinking_to_content 避免重复改写
+	info.ChannelSetting.ThinkingToContent = false
+	if mode == dto.ReasoningOutputNative || info.RelayFormat != types.RelayFormatOpenAI || info.RelayMode != relayconstant.RelayModeChatCompletions {
+		return func() {}
+	}
+	// 这些写入器收到的 chat 响应还会被转换或汇总，并非直接返回给 Chat Completions 客户端：
+	// Responses 等格式的客户端经 chat 上游转换、网关工具循环的流式轮次
+	switch c.Writer.(type) {
+	case *openAIWireCaptureWriter, *openAIWireStreamWriter, *gatewayToolStreamWriter:
+		return func() {}
+	}
+
+	base := c.Writer
+	writer := &reasoningOutputWriter{ResponseWriter: base, mode: mode, stream: info.IsStream, status: http.StatusOK}
+	if info.IsStream {
+		writer.rewriter = reasoning.NewChatStreamRewriter(mode)
+	}
+	c.Writer = writer
+	return func() {
+		writer.finish()
+		c.Writer = base
+	}
+}
+
+// reasoningOutputWriter 流式响应逐行改写 data 数据块，非流式响应缓冲完整响应体后一次性改写
+type reasoningOutputWriter struct {
+	gin.ResponseWriter
+	mode     dto.ReasoningOutputMode
+	stream   bool
+	rewriter *reasoning.ChatStreamRewriter
+
+	// 流式：尚未读到换行符的半行数据
+	partial []byte
+
+	// 非流式：延迟到 finish 时再写状态码，以便修正 Content-Length
+	status   int
+	body     bytes.Buffer
+	finished bool
+}
+
+func (w *reasoningOutputWriter) WriteHeader(code int) {
+	if w.stream {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *reasoningOutputWriter) WriteHeaderNow() {
+	if w.stream {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *reasoningOutputWriter) Write(p []byte) (int, error) {
+	if !w.stream {
+		return w.body.Write(p)
+	}
+	w.partial = append(w.partial, p...)
+	for {
+		idx := bytes.IndexByte(w.partial, '\n')
+		if idx < 0 {
+			break
+		}
+		line := w.partial[:idx+1]
+		if _, err := w.ResponseWriter.Write(w.rewriteLine(line)); err != nil {
+			return 0, err
+		}
+		w.partial = w.partial[idx+1:]
+	}
+	return len(p), nil
+}
+
+func (w *reasoningOutputWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *reasoningOutputWriter) rewriteLine(line []byte) []byte {
+	data, ok := bytes.CutPrefix(line, []byte("data:"))
+	if !ok {
+		return line
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		return line
+	}
+	rewritten := w.rewriter.Rewrite(data)
+	out := make([]byte, 0, len(rewritten)+8)
+	out = append(out, "data: "...)
+	out = append(out, rewritten...)
+	return append(out, '\n')
+}
+
+func (w *reasoningOutputWriter) finish() {
+	if w.finished {
+		return
+	}
+	w.finished = true
+	if w.stream {
+		if len(w.partial) > 0 {
+			_, _ = w.ResponseWriter.Write(w.partial)
+			w.partial = nil
+		}
+		return
+	}
+	if w.body.Len() == 0 {
+		return
+	}
+	body := w.body.Bytes()
+	if w.status < http.StatusBadRequest {
+		body = reasoning.RewriteChatResponse(w.mode, body)
+	}
+	w.ResponseWriter.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.WriteHeader(w.status)
+	_, _ = w.ResponseWriter.Write(body)
+}
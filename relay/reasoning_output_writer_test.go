@@ -0,0 +1,118 @@
+package relay
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/dto"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	relayconstant "github.com/zhongruan0522/new-api/relay/constant"
+	"github.com/zhongruan0522/new-api/relay/helper"
+	"github.com/zhongruan0522/new-api/service"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newReasoningOutputTestContext(header string, tokenMode string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	if header != "" {
+		c.Request.Header.Set(reasoningOutputHeader, header)
+	}
+	if tokenMode != "" {
+		common.SetContextKey(c, constant.ContextKeyTokenReasoningOutput, tokenMode)
+	}
+	return c, recorder
+}
+
+func newReasoningOutputTestInfo(stream bool) *relaycommon.RelayInfo {
+	return &relaycommon.RelayInfo{
+		RelayFormat: types.RelayFormatOpenAI,
+		RelayMode:   relayconstant.RelayModeChatCompletions,
+		IsStream:    stream,
+		ChannelMeta: &relaycommon.ChannelMeta{},
+	}
+}
+
+func TestResolveReasoningOutputMode(t *testing.T) {
+	c, _ := newReasoningOutputTestContext("Hidden", string(dto.ReasoningOutputInline))
+	if mode, err := resolveReasoningOutputMode(c); err != nil || mode != dto.ReasoningOutputHidden {
+		t.Fatalf("header should win over token setting, got %q %v", mode, err)
+	}
+	c, _ = newReasoningOutputTestContext("", string(dto.ReasoningOutputInline))
+	if mode, err := resolveReasoningOutputMode(c); err != nil || mode != dto.ReasoningOutputInline {
+		t.Fatalf("token setting should apply, got %q %v", mode, err)
+	}
+	c, _ = newReasoningOutputTestContext("verbose", "")
+	if _, err := resolveReasoningOutputMode(c); err == nil {
+		t.Fatal("invalid header value should be rejected")
+	}
+}
+
+func TestReasoningOutputWriterStream(t *testing.T) {
+	c, recorder := newReasoningOutputTestContext(string(dto.ReasoningOutputHidden), "")
+	info := newReasoningOutputTestInfo(true)
+	info.ChannelSetting.ThinkingToContent = true
+
+	finish := wrapReasoningOutputWriter(c, info)
+	if info.ChannelSetting.ThinkingToContent {
+		t.Fatal("explicit mode should disable channel thinking_to_content")
+	}
+	_ = helper.StringData(c, `{"choices":[{"index":0,"delta":{"reasoning_content":"plan"},"finish_reason":null}]}`)
+	_ = helper.StringData(c, `{"choices":[{"index":0,"delta":{"content":"answer"},"finish_reason":null}]}`)
+	helper.Done(c)
+	finish()
+
+	body := recorder.Body.String()
+	if strings.Contains(body, "plan") || !strings.Contains(body, `"content":"answer"`) || !strings.Contains(body, "data: [DONE]") {
+		t.Fatalf("stream body = %s", body)
+	}
+}
+
+func TestReasoningOutputWriterNonStream(t *testing.T) {
+	c, recorder := newReasoningOutputTestContext(string(dto.ReasoningOutputField), "")
+	info := newReasoningOutputTestInfo(false)
+
+	finish := wrapReasoningOutputWriter(c, info)
+	upstream := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": []string{"application/json"}}}
+	service.IOCopyBytesGracefully(c, upstream, []byte(`{"choices":[{"index":0,"message":{"role":"assistant","content":"<think>plan</think>answer"},"finish_reason":"stop"}]}`))
+	finish()
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"reasoning_content":"plan"`) || !strings.Contains(body, `"content":"answer"`) {
+		t.Fatalf("body = %s", body)
+	}
+	if recorder.Header().Get("Content-Length") != strconv.Itoa(len(body)) {
+		t.Fatalf("Content-Length = %s, body length %d", recorder.Header().Get("Content-Length"), len(body))
+	}
+}
+
+func TestReasoningOutputWriterSkipsResponsesStreamConversion(t *testing.T) {
+	// Responses 流式客户端经 chat 上游转换时，c.Writer 是转换写入器，chat 分块不应按 chat 客户端改写
+	c, recorder := newReasoningOutputTestContext(string(dto.ReasoningOutputHidden), "")
+	writer, err := newOpenAIWireStreamWriter(c.Writer, dto.OpenAIWireAPIChat, dto.OpenAIWireAPIResponses, openAIWireStreamOptions{})
+	if err != nil {
+		t.Fatalf("newOpenAIWireStreamWriter returned error: %v", err)
+	}
+	c.Writer = writer
+	info := newReasoningOutputTestInfo(true)
+
+	finish := wrapReasoningOutputWriter(c, info)
+	if c.Writer != writer {
+		t.Fatal("conversion writer should not be wrapped")
+	}
+	_ = helper.StringData(c, `{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"reasoning_content":"plan"},"finish_reason":null}]}`)
+	finish()
+
+	if body := recorder.Body.String(); !strings.Contains(body, "plan") {
+		t.Fatalf("reasoning should reach the Responses conversion unchanged, got %s", body)
+	}
+}
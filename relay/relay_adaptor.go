@@ -5,12 +5,15 @@ import (
 	"github.com/zhongruan0522/new-api/relay/channel"
 	"github.com/zhongruan0522/new-api/relay/channel/aws"
 	"github.com/zhongruan0522/new-api/relay/channel/claude"
+	"github.com/zhongruan0522/new-api/relay/channel/cohere"
+	"github.com/zhongruan0522/new-api/relay/channel/dashscope"
 	"github.com/zhongruan0522/new-api/relay/channel/deepseek"
 	"github.com/zhongruan0522/new-api/relay/channel/gemini"
 	"github.com/zhongruan0522/new-api/relay/channel/minimax"
 	"github.com/zhongruan0522/new-api/relay/channel/moonshot"
 	"github.com/zhongruan0522/new-api/relay/channel/ollama"
 	"github.com/zhongruan0522/new-api/relay/channel/openai"
+	"github.com/zhongruan0522/new-api/relay/channel/selfhosted"
 	"github.com/zhongruan0522/new-api/relay/channel/siliconflow"
 	"github.com/zhongruan0522/new-api/relay/channel/vertex"
 	"github.com/zhongruan0522/new-api/relay/channel/xiaomi"
@@ -45,6 +48,23 @@ func GetAdaptor(apiType int) channel.Adaptor {
 		return &minimax.Adaptor{}
 	case constant.APITypeXiaomi:
 		return &xiaomi.Adaptor{}
+	case constant.APITypeCohere:
+		return &cohere.Adaptor{}
+	case constant.APITypeDashScope:
+		return &dashscope.Adaptor{}
+	case constant.APITypeSelfHosted:
+		return &selfhosted.Adaptor{}
+	}
+	return nil
+}
+
+// GetTaskAdaptor returns the asynchronous task adaptor for the api type, or nil when unsupported.
+func GetTaskAdaptor(apiType int) channel.TaskAdaptor {
+	switch apiType {
+	case constant.APITypeOpenAI:
+		return &openai.VideoAdaptor{}
+	case constant.APITypeDashScope:
+		return &dashscope.VideoAdaptor{}
 	}
 	return nil
 }
@@ -0,0 +1,262 @@
+package relay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/logger"
+	"github.com/zhongruan0522/new-api/model"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/setting/operation_setting"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 单个响应记录的最大字节数，超出时不保存（避免超大流式响应占用内存）
+const storedResponseMaxBytes = 16 << 20
+
+// responsesRecordWriter 透传写入的同时记录响应体，供网关侧保存 Responses 输出
+type responsesRecordWriter struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	overflow bool
+}
+
+func newResponsesRecordWriter(base gin.ResponseWriter) *responsesRecordWriter {
+	return &responsesRecordWriter{ResponseWriter: base}
+}
+
+func (w *responsesRecordWriter) record(p []byte) {
+	if w.overflow {
+		return
+	}
+	if w.body.Len()+len(p) > storedResponseMaxBytes {
+		w.overflow = true
+		w.body.Reset()
+		return
+	}
+	w.body.Write(p)
+}
+
+func (w *responsesRecordWriter) Write(p []byte) (int, error) {
+	w.record(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *responsesRecordWriter) WriteString(s string) (int, error) {
+	w.record([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// relayResponsesWithGatewayStore 在转发 /v1/responses 前用网关存储展开 previous_response_id，
+// 成功后按配置保存本轮输入与输出，使 chat 上游与跨渠道请求也能使用有状态的 Responses API
+func relayResponsesWithGatewayStore(c *gin.Context, info *relaycommon.RelayInfo, wire dto.OpenAIWireAPI) *types.NewAPIError {
+	relay := func() *types.NewAPIError {
+		if wire == dto.OpenAIWireAPIChat {
+			return relayResponsesDownstreamToChatUpstream(c, info)
+		}
+		return ResponsesHelper(c, info)
+	}
+
+	req, ok := info.Request.(*dto.OpenAIResponsesRequest)
+	if !ok {
+		return relay()
+	}
+	setting := operation_setting.GetResponsesStoreSetting()
+	previousId := strings.TrimSpace(req.PreviousResponseID)
+	store := setting.Enabled && !info.ChannelOtherSettings.DisableStore && !responsesStoreDisabled(req.Store)
+	if previousId == "" && !store {
+		return relay()
+	}
+
+	turnInput, err := normalizeResponsesInputItems(req.Input)
+	if err != nil {
+		return types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+
+	if previousId != "" {
+		notBefore := common.GetTimestamp() - setting.RetentionSeconds()
+		history, err := model.GetStoredResponseHistory(c.Request.Context(), info.UserId, previousId, notBefore, setting.GetMaxChainDepth())
+		switch {
+		case err == nil:
+			expanded := *req
+			expanded.PreviousResponseID = ""
+			expanded.Input, err = common.Marshal(append(history, turnInput...))
+			if err != nil {
+				return types.NewError(err, types.ErrorCodeJsonMarshalFailed, types.ErrOptionWithSkipRetry())
+			}
+			bodyBytes, err := common.Marshal(&expanded)
+			if err != nil {
+				return types.NewError(err, types.ErrorCodeJsonMarshalFailed, types.ErrOptionWithSkipRetry())
+			}
+
+			snapshot := takeRelayInfoSnapshot(info)
+			defer snapshot.restore(info)
+			bodySnap, err := takeRequestBodySnapshot(c)
+			if err != nil {
+				return types.NewError(err, types.ErrorCodeReadRequestBodyFailed, types.ErrOptionWithSkipRetry())
+			}
+			defer bodySnap.restore(c)
+
+			info.Request = &expanded
+			setTemporaryRequestBody(c, bodyBytes)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			// 网关未保存该响应：原生 Responses 上游可能自行保存了，继续透传；chat 上游无法处理，直接报错
+			if wire == dto.OpenAIWireAPIChat {
+				return types.NewErrorWithStatusCode(
+					fmt.Errorf("previous response with id '%s' not found", previousId),
+					types.ErrorCodeInvalidRequest,
+					http.StatusNotFound,
+					types.ErrOptionWithSkipRetry(),
+				)
+			}
+		default:
+			return types.NewError(fmt.Errorf("query stored response failed: %w", err), types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+		}
+	}
+
+	if !store {
+		return relay()
+	}
+
+	base := c.Writer
+	recorder := newResponsesRecordWriter(base)
+	c.Writer = recorder
+	newAPIError := relay()
+	c.Writer = base
+	if newAPIError != nil {
+		return newAPIError
+	}
+	if recorder.overflow {
+		logger.LogWarn(c, "response too large, skip storing")
+		return nil
+	}
+	if err := saveStoredResponse(c, info, req, previousId, turnInput, recorder.body.Bytes()); err != nil {
+		logger.LogError(c, "store response failed: "+err.Error())
+	}
+	return nil
+}
+
+// responsesStoreDisabled 请求显式传入 store: false 时不保存，未传时按 OpenAI 默认值保存
+func responsesStoreDisabled(raw json.RawMessage) bool {
+	return strings.TrimSpace(string(raw)) == "false"
+}
+
+// normalizeResponsesInputItems 将 input 统一为输入项数组，字符串输入视为一条用户消息
+func normalizeResponsesInputItems(raw json.RawMessage) ([]json.RawMessage, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	switch common.GetJsonType(raw) {
+	case "string":
+		var text string
+		if err := common.Unmarshal(raw, &text); err != nil {
+			return nil, fmt.Errorf("unmarshal input string failed: %w", err)
+		}
+		item, err := common.Marshal(map[string]string{"type": "message", "role": "user", "content": text})
+		if err != nil {
+			return nil, err
+		}
+		return []json.RawMessage{item}, nil
+	case "array":
+		var items []json.RawMessage
+		if err := common.Unmarshal(raw, &items); err != nil {
+			return nil, fmt.Errorf("unmarshal input array failed: %w", err)
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unsupported input type: %s", common.GetJsonType(raw))
+	}
+}
+
+// extractResponsesObject 从非流式响应体或流式 response.completed 事件中取出完整响应对象
+func extractResponsesObject(body []byte) (json.RawMessage, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return trimmed, nil
+	}
+	var completed json.RawMessage
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), storedResponseMaxBytes)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var event struct {
+			Type     string          `json:"type"`
+			Response json.RawMessage `json:"response"`
+		}
+		if err := common.UnmarshalJsonStr(strings.TrimSpace(data), &event); err != nil {
+			continue
+		}
+		if event.Type == "response.completed" || event.Type == "response.incomplete" {
+			completed = event.Response
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(completed) == 0 {
+		return nil, errors.New("response.completed event not found")
+	}
+	return completed, nil
+}
+
+func saveStoredResponse(c *gin.Context, info *relaycommon.RelayInfo, req *dto.OpenAIResponsesRequest, previousId string, turnInput []json.RawMessage, body []byte) error {
+	object, err := extractResponsesObject(body)
+	if err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := common.Unmarshal(object, &fields); err != nil {
+		return fmt.Errorf("unmarshal response failed: %w", err)
+	}
+	var id string
+	if err := common.Unmarshal(fields["id"], &id); err != nil || id == "" {
+		return errors.New("response id is empty")
+	}
+	output := fields["output"]
+	if len(output) == 0 {
+		output = json.RawMessage("[]")
+	}
+	// 上游看到的是展开后的输入，这里恢复客户端视角的字段
+	if previousId != "" {
+		fields["previous_response_id"], _ = common.Marshal(previousId)
+	}
+	fields["store"] = json.RawMessage("true")
+	response, err := common.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	input, err := common.Marshal(turnInput)
+	if err != nil {
+		return err
+	}
+
+	modelName := req.Model
+	if modelName == "" {
+		modelName = info.OriginModelName
+	}
+	stored := &model.StoredResponse{
+		Id:                 id,
+		UserId:             info.UserId,
+		TokenId:            info.TokenId,
+		ChannelId:          info.ChannelId,
+		Model:              modelName,
+		PreviousResponseId: previousId,
+		Input:              input,
+		Output:             model.LargeBlob(output),
+		Response:           response,
+	}
+	return stored.Save(c.Request.Context())
+}
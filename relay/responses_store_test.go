@@ -0,0 +1,39 @@
+package relay
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNormalizeResponsesInputItems(t *testing.T) {
+	items, err := normalizeResponsesInputItems(json.RawMessage(`"hello"`))
+	if err != nil {
+		t.Fatalf("normalizeResponsesInputItems returned error: %v", err)
+	}
+	if len(items) != 1 || string(items[0]) != `{"content":"hello","role":"user","type":"message"}` {
+		t.Fatalf("string input normalized to %s", items)
+	}
+
+	items, err = normalizeResponsesInputItems(json.RawMessage(`[{"role":"user","content":"a"},{"type":"function_call_output","call_id":"c1","output":"x"}]`))
+	if err != nil || len(items) != 2 {
+		t.Fatalf("array input = %v, %v", items, err)
+	}
+}
+
+func TestExtractResponsesObject(t *testing.T) {
+	obj, err := extractResponsesObject([]byte(`{"id":"resp_1","output":[]}`))
+	if err != nil || string(obj) != `{"id":"resp_1","output":[]}` {
+		t.Fatalf("non-stream body = %s, %v", obj, err)
+	}
+
+	stream := "event: response.created\ndata: {\"type\":\"response.created\",\"response\":{\"id\":\"resp_1\"}}\n\n" +
+		"event: response.completed\ndata: {\"type\":\"response.completed\",\"response\":{\"id\":\"resp_1\",\"output\":[{\"type\":\"message\"}]}}\n\n"
+	obj, err = extractResponsesObject([]byte(stream))
+	if err != nil || string(obj) != `{"id":"resp_1","output":[{"type":"message"}]}` {
+		t.Fatalf("stream body = %s, %v", obj, err)
+	}
+
+	if _, err := extractResponsesObject([]byte("data: [DONE]\n\n")); err == nil {
+		t.Fatal("stream without response.completed should fail")
+	}
+}
@@ -29,6 +29,17 @@ func RelayStoredImage(c *gin.Context) {
 		return
 	}
 
+	if model.StoredBlobPresignEnabled() {
+		if meta, err := model.GetStoredImageMetaByID(c.Request.Context(), id); err == nil && meta.BlobKey != "" {
+			if u, ok := model.PresignStoredBlob(c.Request.Context(), meta.BlobKey); ok {
+				// The presigned URL carries its own expiry; do not let clients cache the redirect.
+				c.Writer.Header().Set("Cache-Control", "no-store")
+				c.Redirect(http.StatusFound, u)
+				return
+			}
+		}
+	}
+
 	img, err := model.GetStoredImageByID(c.Request.Context(), id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -0,0 +1,163 @@
+package relay
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/logger"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 首轮结果未通过 schema 校验时最多再请求一次
+const structuredOutputMaxTurns = 2
+
+// resolveStructuredOutputEmulation 渠道开启结构化输出模拟且请求带有 json_schema / json_object 时返回解析后的 schema
+func resolveStructuredOutputEmulation(info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) (*relaycommon.StructuredOutputSpec, dto.StructuredOutputEmulation, error) {
+	if info.ChannelMeta == nil {
+		return nil, "", nil
+	}
+	mode, _ := info.ChannelOtherSettings.StructuredOutputEmulation.Normalize()
+	if mode == dto.StructuredOutputEmulationOff {
+		return nil, "", nil
+	}
+	spec, err := relaycommon.ParseStructuredOutputSpec(request.ResponseFormat)
+	if err != nil || spec == nil {
+		return nil, "", err
+	}
+	return spec, mode, nil
+}
+
+// relayChatWithStructuredOutputEmulation 将 response_format 转为强制工具调用或系统指令后请求上游，
+// 校验每个 choice 返回的 JSON，不符合 schema 时带上校验错误重试一次，最终以 message.content 返回并统一结算；
+// 重试后仍不符合时返回错误
+func relayChatWithStructuredOutputEmulation(c *gin.Context, info *relaycommon.RelayInfo, spec *relaycommon.StructuredOutputSpec, mode dto.StructuredOutputEmulation) *types.NewAPIError {
+	chatReq, ok := info.Request.(*dto.GeneralOpenAIRequest)
+	if !ok {
+		return types.NewErrorWithStatusCode(
+			fmt.Errorf("invalid request type, expected dto.GeneralOpenAIRequest, got %T", info.Request),
+			types.ErrorCodeInvalidRequest,
+			http.StatusBadRequest,
+			types.ErrOptionWithSkipRetry(),
+		)
+	}
+
+	request, err := common.DeepCopy(chatReq)
+	if err != nil {
+		return types.NewError(fmt.Errorf("failed to copy request to GeneralOpenAIRequest: %w", err), types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
+	}
+	clientStream := request.Stream
+	includeUsage := request.StreamOptions != nil && request.StreamOptions.IncludeUsage
+
+	mode = relaycommon.ApplyStructuredOutputEmulation(request, spec, mode)
+	request.Stream = false
+	request.StreamOptions = nil
+
+	snapshot := takeRelayInfoSnapshot(info)
+	defer snapshot.restore(info)
+
+	bodySnap, err := takeRequestBodySnapshot(c)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeReadRequestBodyFailed, types.ErrOptionWithSkipRetry())
+	}
+	defer bodySnap.restore(c)
+
+	session := &relaycommon.StructuredOutputSession{Collecting: true, Mode: mode}
+	info.StructuredOutput = session
+	defer func() { info.StructuredOutput = nil }()
+
+	base := c.Writer
+	defer func() { c.Writer = base }()
+
+	for turn := 1; ; turn++ {
+		bodyBytes, err := common.Marshal(request)
+		if err != nil {
+			return failStructuredOutputEmulation(c, info, types.NewError(err, types.ErrorCodeJsonMarshalFailed, types.ErrOptionWithSkipRetry()))
+		}
+		setTemporaryRequestBody(c, bodyBytes)
+		info.Request = request
+		info.IsStream = false
+
+		captured := newOpenAIWireCaptureWriter(base)
+		c.Writer = captured
+		newAPIError := TextHelper(c, info)
+		c.Writer = base
+		if newAPIError != nil {
+			return failStructuredOutputEmulation(c, info, newAPIError)
+		}
+
+		var resp dto.OpenAITextResponse
+		if err := common.Unmarshal(captured.BodyBytes(), &resp); err != nil {
+			return failStructuredOutputEmulation(c, info, types.NewError(fmt.Errorf("unmarshal chat completion response failed: %w", err), types.ErrorCodeBadResponseBody, types.ErrOptionWithSkipRetry()))
+		}
+		if len(resp.Choices) == 0 {
+			return finishStructuredOutputEmulation(c, info, &resp, clientStream, includeUsage)
+		}
+
+		// 每个 choice 都需要通过校验，任一不符合时以该 choice 的结果重试
+		outputs := make([]string, len(resp.Choices))
+		var validationErr error
+		var invalidMessage dto.Message
+		for i := range resp.Choices {
+			outputs[i] = relaycommon.ExtractStructuredOutput(&resp.Choices[i].Message, spec, mode)
+			if err := spec.Validate(outputs[i]); err != nil && validationErr == nil {
+				validationErr = err
+				invalidMessage = resp.Choices[i].Message
+			}
+		}
+		if validationErr == nil {
+			for i := range resp.Choices {
+				relaycommon.FinalizeStructuredOutputChoice(&resp.Choices[i], outputs[i])
+			}
+			return finishStructuredOutputEmulation(c, info, &resp, clientStream, includeUsage)
+		}
+		if turn >= structuredOutputMaxTurns {
+			// 重试后仍不符合时返回错误而不是把不合规的结果交给客户端，已产生的用量照常结算
+			session.ValidationError = validationErr.Error()
+			logger.LogWarn(c, "structured output emulation result does not match schema: "+validationErr.Error())
+			return failStructuredOutputEmulation(c, info, types.NewErrorWithStatusCode(
+				fmt.Errorf("structured output does not match the json schema after %d attempts: %w", turn, validationErr),
+				types.ErrorCodeBadResponse,
+				http.StatusBadGateway,
+			))
+		}
+
+		request.Messages = append(request.Messages, relaycommon.BuildStructuredOutputRetryMessages(invalidMessage, spec, mode, validationErr)...)
+	}
+}
+
+// failStructuredOutputEmulation 已完成至少一轮时先结算已产生的用量，再返回错误且不重试
+func failStructuredOutputEmulation(c *gin.Context, info *relaycommon.RelayInfo, newAPIError *types.NewAPIError) *types.NewAPIError {
+	session := info.StructuredOutput
+	if session == nil || session.Turns == 0 {
+		return newAPIError
+	}
+	session.Collecting = false
+	content := fmt.Sprintf("结构化输出模拟第 %d 轮失败", session.Turns+1)
+	if session.ValidationError != "" {
+		content = fmt.Sprintf("结构化输出模拟 %d 轮后仍未通过 schema 校验", session.Turns)
+	}
+	if apiErr := postConsumeQuota(c, info, &session.Usage, content); apiErr != nil {
+		logger.LogError(c, "settle structured output emulation failed: "+apiErr.Error())
+	}
+	types.ErrOptionWithSkipRetry()(newAPIError)
+	return newAPIError
+}
+
+// finishStructuredOutputEmulation 将最终结果按客户端要求的格式返回，并以累计用量结算
+func finishStructuredOutputEmulation(c *gin.Context, info *relaycommon.RelayInfo, resp *dto.OpenAITextResponse, clientStream bool, includeUsage bool) *types.NewAPIError {
+	session := info.StructuredOutput
+	session.Collecting = false
+	resp.Usage = session.Usage
+
+	if err := writeCollectedChatResponse(c, resp, clientStream, includeUsage); err != nil {
+		logger.LogError(c, "write structured output response failed: "+err.Error())
+	}
+
+	info.IsStream = clientStream
+	return postConsumeQuota(c, info, &session.Usage)
+}
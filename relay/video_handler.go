@@ -0,0 +1,286 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/logger"
+	"github.com/zhongruan0522/new-api/model"
+	"github.com/zhongruan0522/new-api/relay/channel"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/relay/helper"
+	"github.com/zhongruan0522/new-api/service"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VideoSubmitHelper submits an asynchronous video task upstream and records it for the
+// background poller. The task is charged at submit time and refunded if it finally fails.
+func VideoSubmitHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types.NewAPIError) {
+	info.InitChannelMeta(c)
+
+	videoReq, ok := info.Request.(*dto.VideoRequest)
+	if !ok {
+		return types.NewErrorWithStatusCode(fmt.Errorf("invalid request type, expected dto.VideoRequest, got %T", info.Request), types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	if !info.PriceData.UsePrice && !info.PriceData.FreeModel {
+		return types.NewErrorWithStatusCode(fmt.Errorf("video model %s must be priced per second with a fixed model price", info.OriginModelName), types.ErrorCodeModelPriceError, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+
+	request, err := common.DeepCopy(videoReq)
+	if err != nil {
+		return types.NewError(fmt.Errorf("failed to copy request to VideoRequest: %w", err), types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
+	}
+	err = helper.ModelMappedHelper(c, info, request)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeChannelModelMappedError, types.ErrOptionWithSkipRetry())
+	}
+
+	adaptor := GetTaskAdaptor(info.ApiType)
+	if adaptor == nil {
+		return types.NewError(fmt.Errorf("channel api type %d does not support video generation", info.ApiType), types.ErrorCodeInvalidApiType)
+	}
+	adaptor.Init(info)
+
+	statusCodeMappingStr := c.GetString("status_code_mapping")
+	resp, err := adaptor.SubmitTask(c, info, request)
+	if err != nil {
+		return types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+	}
+	if resp.StatusCode != http.StatusOK {
+		newAPIError = service.RelayErrorHandler(c.Request.Context(), resp, false)
+		service.ResetStatusCode(newAPIError, statusCodeMappingStr)
+		return newAPIError
+	}
+	taskInfo, err := adaptor.ParseSubmitResponse(resp)
+	if err != nil {
+		return types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+
+	now := common.GetTimestamp()
+	task := &model.Task{
+		TaskId:         "video_" + common.GetUUID(),
+		Platform:       model.TaskPlatformVideo,
+		UserId:         info.UserId,
+		TokenId:        info.TokenId,
+		TokenKey:       info.TokenKey,
+		TokenQuotaType: info.TokenQuotaType,
+		TokenName:      c.GetString("token_name"),
+		Group:          info.UsingGroup,
+		ChannelId:      info.ChannelId,
+		ChannelKeyIdx:  info.ChannelMultiKeyIndex,
+		ModelName:      info.OriginModelName,
+		UpstreamTaskId: taskInfo.UpstreamTaskId,
+		Status:         taskInfo.Status,
+		Progress:       taskInfo.Progress,
+		Seconds:        request.GetSeconds(),
+		Size:           request.Size,
+		CreatedAt:      now,
+		NextPollAt:     service.NextTaskPollAt(now, 0),
+	}
+	if !info.PriceData.FreeModel {
+		task.Quota = int(info.PriceData.ModelPrice * common.QuotaPerUnit * info.PriceData.GroupRatioInfo.GroupRatio)
+	}
+	if task.Status == dto.VideoStatusCompleted || task.Status == dto.VideoStatusFailed {
+		// 上游同步给出终态时仍交给轮询统一处理下载与退款
+		task.Status = model.TaskStatusInProgress
+		task.NextPollAt = now
+	}
+	if err := task.Insert(c.Request.Context()); err != nil {
+		return types.NewError(err, types.ErrorCodeUpdateDataError, types.ErrOptionWithSkipRetry())
+	}
+
+	postConsumeTaskQuota(c, info, task)
+	c.JSON(http.StatusOK, BuildVideoObject(task))
+	return nil
+}
+
+func postConsumeTaskQuota(c *gin.Context, info *relaycommon.RelayInfo, task *model.Task) {
+	if task.Quota > 0 {
+		model.UpdateUserUsedQuotaAndRequestCount(info.UserId, task.Quota)
+		model.UpdateChannelUsedQuota(info.ChannelId, task.Quota)
+	}
+	if err := service.SettleBilling(c, info, task.Quota); err != nil {
+		logger.LogError(c, "error settling billing: "+err.Error())
+	}
+
+	groupRatio := info.PriceData.GroupRatioInfo.GroupRatio
+	dynamicRatio := info.PriceData.GroupRatioInfo.DynamicRatio
+	if dynamicRatio > 0 {
+		groupRatio = groupRatio / dynamicRatio
+	}
+	other := service.GenerateTextOtherInfo(c, info, 0, groupRatio, 0, 0, 0, info.PriceData.ModelPrice, info.PriceData.GroupRatioInfo.GroupSpecialRatio, dynamicRatio)
+	other["task_id"] = task.TaskId
+	other["video_seconds"] = task.Seconds
+	model.RecordConsumeLog(c, info.UserId, model.RecordConsumeLogParams{
+		ChannelId: info.ChannelId,
+		ModelName: info.OriginModelName,
+		TokenName: task.TokenName,
+		Quota:     task.Quota,
+		Content:   fmt.Sprintf("视频生成任务 %s，时长 %d 秒，失败时自动退款", task.TaskId, task.Seconds),
+		TokenId:   info.TokenId,
+		UseTimeMs: int(time.Since(info.StartTime).Milliseconds()),
+		Group:     info.UsingGroup,
+		Other:     other,
+	})
+}
+
+// BuildVideoObject renders a task as the OpenAI video resource.
+func BuildVideoObject(task *model.Task) *dto.VideoObject {
+	video := &dto.VideoObject{
+		Id:        task.TaskId,
+		Object:    "video",
+		Model:     task.ModelName,
+		Status:    task.Status,
+		Progress:  task.Progress,
+		CreatedAt: task.CreatedAt,
+		Size:      task.Size,
+	}
+	if task.Seconds > 0 {
+		video.Seconds = strconv.Itoa(task.Seconds)
+	}
+	switch task.Status {
+	case model.TaskStatusCompleted:
+		video.CompletedAt = task.FinishedAt
+	case model.TaskStatusFailed:
+		video.Error = &dto.VideoError{Code: "video_generation_failed", Message: task.FailReason}
+	}
+	return video
+}
+
+// PollVideoTask polls one pending task; on completion the video is saved into StoredVideo,
+// on failure or timeout the charged quota is refunded.
+func PollVideoTask(ctx context.Context, task *model.Task) error {
+	fromStatus := task.Status
+	now := common.GetTimestamp()
+	task.PollCount++
+	task.NextPollAt = service.NextTaskPollAt(now, task.PollCount)
+
+	taskInfo, err := fetchVideoTask(ctx, task)
+	if err != nil {
+		if !service.IsTaskTimedOut(task, now) {
+			common.SysLog(fmt.Sprintf("poll task %s failed: %s", task.TaskId, err.Error()))
+			_, updateErr := model.UpdateTaskProgress(ctx, task, fromStatus)
+			return updateErr
+		}
+		taskInfo = &channel.TaskInfo{Status: dto.VideoStatusFailed, FailReason: "task timed out: " + err.Error()}
+	}
+
+	switch taskInfo.Status {
+	case dto.VideoStatusCompleted:
+		videoId, err := saveTaskVideo(ctx, task)
+		if err != nil {
+			if !service.IsTaskTimedOut(task, now) {
+				common.SysLog(fmt.Sprintf("save task %s video failed: %s", task.TaskId, err.Error()))
+				_, updateErr := model.UpdateTaskProgress(ctx, task, fromStatus)
+				return updateErr
+			}
+			return finishTaskFailed(ctx, task, fromStatus, "download video failed: "+err.Error())
+		}
+		task.Status = model.TaskStatusCompleted
+		task.Progress = 100
+		task.ResultVideoId = videoId
+		task.FinishedAt = now
+		_, err = model.UpdateTaskProgress(ctx, task, fromStatus)
+		return err
+	case dto.VideoStatusFailed:
+		return finishTaskFailed(ctx, task, fromStatus, taskInfo.FailReason)
+	default:
+		if service.IsTaskTimedOut(task, now) {
+			return finishTaskFailed(ctx, task, fromStatus, "task timed out")
+		}
+		task.Status = taskInfo.Status
+		task.Progress = taskInfo.Progress
+		_, err := model.UpdateTaskProgress(ctx, task, fromStatus)
+		return err
+	}
+}
+
+func finishTaskFailed(ctx context.Context, task *model.Task, fromStatus string, reason string) error {
+	task.Status = model.TaskStatusFailed
+	task.FailReason = reason
+	task.FinishedAt = common.GetTimestamp()
+	updated, err := model.UpdateTaskProgress(ctx, task, fromStatus)
+	if err != nil {
+		return err
+	}
+	if updated {
+		// 退款失败时任务保持未退款状态，由轮询重试
+		return service.RefundTask(task)
+	}
+	return nil
+}
+
+func fetchVideoTask(ctx context.Context, task *model.Task) (*channel.TaskInfo, error) {
+	adaptor, info, err := getTaskAdaptorForChannel(task)
+	if err != nil {
+		return nil, err
+	}
+	return adaptor.FetchTask(ctx, info, task.UpstreamTaskId)
+}
+
+func saveTaskVideo(ctx context.Context, task *model.Task) (string, error) {
+	adaptor, info, err := getTaskAdaptorForChannel(task)
+	if err != nil {
+		return "", err
+	}
+	data, mimeType, err := adaptor.FetchContent(ctx, info, task.UpstreamTaskId)
+	if err != nil {
+		return "", err
+	}
+	video := &model.StoredVideo{
+		UserId:    task.UserId,
+		ChannelId: task.ChannelId,
+		MimeType:  mimeType,
+		SizeBytes: len(data),
+		Data:      data,
+	}
+	if err := video.Insert(ctx); err != nil {
+		return "", err
+	}
+	return video.Id, nil
+}
+
+// getTaskAdaptorForChannel rebuilds the channel meta of the submitting channel outside a request.
+func getTaskAdaptorForChannel(task *model.Task) (channel.TaskAdaptor, *relaycommon.RelayInfo, error) {
+	ch, err := model.GetChannelById(task.ChannelId, true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get channel %d failed: %w", task.ChannelId, err)
+	}
+	apiType, _ := common.ChannelType2APIType(ch.Type)
+	adaptor := GetTaskAdaptor(apiType)
+	if adaptor == nil {
+		return nil, nil, fmt.Errorf("channel api type %d does not support async tasks", apiType)
+	}
+	keys := ch.GetKeys()
+	if len(keys) == 0 {
+		return nil, nil, fmt.Errorf("channel %d has no key", ch.Id)
+	}
+	key := keys[0]
+	if task.ChannelKeyIdx > 0 && task.ChannelKeyIdx < len(keys) {
+		key = keys[task.ChannelKeyIdx]
+	}
+	info := &relaycommon.RelayInfo{
+		UserId:          task.UserId,
+		OriginModelName: task.ModelName,
+		ChannelMeta: &relaycommon.ChannelMeta{
+			ChannelType:    ch.Type,
+			ChannelId:      ch.Id,
+			ChannelBaseUrl: ch.GetBaseURL(),
+			ApiType:        apiType,
+			ApiKey:         key,
+			ChannelSetting: ch.GetSetting(),
+		},
+	}
+	if ch.OpenAIOrganization != nil {
+		info.Organization = *ch.OpenAIOrganization
+	}
+	adaptor.Init(info)
+	return adaptor, info, nil
+}
@@ -0,0 +1,143 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/model"
+	"github.com/zhongruan0522/new-api/service"
+	"gorm.io/gorm"
+)
+
+func setupVideoTaskTestDB(t *testing.T) {
+	t.Helper()
+	oldDB, oldLogDB := model.DB, model.LOG_DB
+	oldRedisEnabled, oldBatchUpdateEnabled := common.RedisEnabled, common.BatchUpdateEnabled
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite test db: %v", err)
+	}
+	if err := db.AutoMigrate(&model.User{}, &model.Token{}, &model.Channel{}, &model.Task{}, &model.Log{}); err != nil {
+		t.Fatalf("migrate sqlite test db: %v", err)
+	}
+	model.DB, model.LOG_DB = db, db
+	common.RedisEnabled, common.BatchUpdateEnabled = false, false
+	service.InitHttpClient()
+	t.Cleanup(func() {
+		model.DB, model.LOG_DB = oldDB, oldLogDB
+		common.RedisEnabled, common.BatchUpdateEnabled = oldRedisEnabled, oldBatchUpdateEnabled
+	})
+}
+
+// createChargedVideoTask 构造提交成功并已扣费 100 后的用户、令牌、渠道与任务
+func createChargedVideoTask(t *testing.T, baseURL string, status string) *model.Task {
+	t.Helper()
+	user := model.User{Id: 1, Username: "alice", Quota: 900, UsedQuota: 100}
+	token := model.Token{Id: 1, UserId: 1, Key: "token-key", Name: "t", RemainQuota: 900, UsedQuota: 100, QuotaType: 1}
+	ch := model.Channel{Id: 1, Type: constant.ChannelTypeOpenAI, Key: "sk-test", BaseURL: &baseURL, UsedQuota: 100}
+	for _, row := range []any{&user, &token, &ch} {
+		if err := model.DB.Create(row).Error; err != nil {
+			t.Fatalf("create %T: %v", row, err)
+		}
+	}
+	task := &model.Task{
+		TaskId:         "video_1",
+		Platform:       model.TaskPlatformVideo,
+		UserId:         1,
+		TokenId:        1,
+		TokenKey:       "token-key",
+		TokenQuotaType: 1,
+		ChannelId:      1,
+		ModelName:      "sora-2",
+		UpstreamTaskId: "up_1",
+		Status:         status,
+		Quota:          100,
+		CreatedAt:      common.GetTimestamp(),
+	}
+	if err := task.Insert(context.Background()); err != nil {
+		t.Fatalf("insert task: %v", err)
+	}
+	return task
+}
+
+func assertTaskRefunded(t *testing.T) {
+	t.Helper()
+	var user model.User
+	var token model.Token
+	var ch model.Channel
+	var task model.Task
+	model.DB.First(&user, 1)
+	model.DB.First(&token, 1)
+	model.DB.First(&ch, 1)
+	model.DB.First(&task, "task_id = ?", "video_1")
+	if user.Quota != 1000 || user.UsedQuota != 0 {
+		t.Fatalf("user quota = %d, used = %d, want 1000, 0", user.Quota, user.UsedQuota)
+	}
+	if token.RemainQuota != 1000 || token.UsedQuota != 0 {
+		t.Fatalf("token remain = %d, used = %d, want 1000, 0", token.RemainQuota, token.UsedQuota)
+	}
+	if ch.UsedQuota != 0 {
+		t.Fatalf("channel used quota = %d, want 0", ch.UsedQuota)
+	}
+	if task.Status != model.TaskStatusFailed || !task.Refunded {
+		t.Fatalf("task status = %s, refunded = %v", task.Status, task.Refunded)
+	}
+}
+
+func TestPollVideoTaskRefundsFailedTaskOnce(t *testing.T) {
+	setupVideoTaskTestDB(t)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/videos/up_1" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"up_1","status":"failed","error":{"message":"moderation blocked"}}`))
+	}))
+	defer upstream.Close()
+
+	task := createChargedVideoTask(t, upstream.URL, model.TaskStatusInProgress)
+	if err := PollVideoTask(context.Background(), task); err != nil {
+		t.Fatalf("PollVideoTask returned error: %v", err)
+	}
+	if task.FailReason != "moderation blocked" {
+		t.Fatalf("FailReason = %q", task.FailReason)
+	}
+	assertTaskRefunded(t)
+
+	// 重复退款不会再次加额度
+	if err := service.RefundTask(task); err != nil {
+		t.Fatalf("second RefundTask returned error: %v", err)
+	}
+	assertTaskRefunded(t)
+	var refundLogs int64
+	model.DB.Model(&model.Log{}).Where("type = ?", model.LogTypeRefund).Count(&refundLogs)
+	if refundLogs != 1 {
+		t.Fatalf("refund logs = %d, want 1", refundLogs)
+	}
+}
+
+func TestUnrefundedFailedTaskIsRetried(t *testing.T) {
+	setupVideoTaskTestDB(t)
+	// 任务已标记失败但退款未完成，例如退款事务执行时数据库出错
+	createChargedVideoTask(t, "http://127.0.0.1", model.TaskStatusFailed)
+
+	tasks, err := model.GetUnrefundedFailedTasks(context.Background(), 10)
+	if err != nil || len(tasks) != 1 {
+		t.Fatalf("GetUnrefundedFailedTasks = %v, %v", tasks, err)
+	}
+	if err := service.RefundTask(tasks[0]); err != nil {
+		t.Fatalf("RefundTask returned error: %v", err)
+	}
+	assertTaskRefunded(t)
+
+	tasks, err = model.GetUnrefundedFailedTasks(context.Background(), 10)
+	if err != nil || len(tasks) != 0 {
+		t.Fatalf("refunded task should not be retried: %v, %v", tasks, err)
+	}
+}
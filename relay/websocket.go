@@ -3,6 +3,7 @@ package relay
 import (
 	"fmt"
 
+	"github.com/zhongruan0522/new-api/constant"
 	"github.com/zhongruan0522/new-api/dto"
 	relaycommon "github.com/zhongruan0522/new-api/relay/common"
 	"github.com/zhongruan0522/new-api/service"
@@ -15,6 +16,14 @@ import (
 func WssHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types.NewAPIError) {
 	info.InitChannelMeta(c)
 
+	if mode := resolveRealtimeBridgeMode(info); mode != "" {
+		return RealtimeBridgeHelper(c, info, mode)
+	}
+	// 只有 OpenAI 类渠道原生支持 Realtime 协议，其余渠道需配置 realtime_bridge
+	if info.ApiType != constant.APITypeOpenAI {
+		return types.NewError(fmt.Errorf("api type %d does not support realtime, configure realtime_bridge on the channel", info.ApiType), types.ErrorCodeInvalidApiType, types.ErrOptionWithSkipRetry())
+	}
+
 	adaptor := GetAdaptor(info.ApiType)
 	if adaptor == nil {
 		return types.NewError(fmt.Errorf("invalid api type: %d", info.ApiType), types.ErrorCodeInvalidApiType, types.ErrOptionWithSkipRetry())
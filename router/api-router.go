@@ -40,6 +40,7 @@ func SetApiRouter(router *gin.Engine) {
 		apiRouter.GET("/oauth/:provider", middleware.CriticalRateLimit(), controller.HandleOAuth)
 
 		apiRouter.POST("/stripe/webhook", controller.StripeWebhook)
+		apiRouter.POST("/ticket/inbound_email", middleware.CriticalRateLimit(), controller.InboundTicketEmail)
 
 		// Universal secure verification routes
 		apiRouter.POST("/verify", middleware.UserAuth(), middleware.CriticalRateLimit(), controller.UniversalVerify)
@@ -83,6 +84,8 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.POST("/stripe/pay", middleware.CriticalRateLimit(), controller.RequestStripePay)
 				selfRoute.POST("/stripe/amount", controller.RequestStripeAmount)
 				selfRoute.POST("/aff_transfer", controller.TransferAffQuota)
+				selfRoute.GET("/self/referral", controller.GetSelfReferral)
+				selfRoute.GET("/self/referral/commissions", controller.GetSelfReferralCommissions)
 				selfRoute.PUT("/setting", controller.UpdateUserSetting)
 
 				// 2FA routes
@@ -104,6 +107,8 @@ func SetApiRouter(router *gin.Engine) {
 				adminRoute.GET("/", controller.GetAllUsers)
 				adminRoute.GET("/topup", controller.GetAllTopUps)
 				adminRoute.POST("/topup/complete", controller.AdminCompleteTopUp)
+				adminRoute.POST("/topup/refund", middleware.RootAuth(), controller.AdminRefundTopUp)
+				adminRoute.GET("/referral/commissions", controller.GetAllReferralCommissions)
 				adminRoute.GET("/search", controller.SearchUsers)
 				adminRoute.GET("/:id", controller.GetUser)
 				adminRoute.POST("/", controller.CreateUser)
@@ -161,6 +166,10 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.GET("/test/:id", controller.TestChannel)
 			channelRoute.GET("/update_balance", controller.UpdateAllChannelsBalance)
 			channelRoute.GET("/update_balance/:id", controller.UpdateChannelBalance)
+			channelRoute.GET("/balance_history/:id", controller.GetChannelBalanceHistory)
+			channelRoute.GET("/traffic_report/:id", controller.GetChannelTrafficReport)
+			channelRoute.DELETE("/traffic_report/:id", controller.ClearChannelTrafficSamples)
+			channelRoute.POST("/script/test", controller.TestChannelScript)
 			channelRoute.POST("/", controller.AddChannel)
 			channelRoute.PUT("/", controller.UpdateChannel)
 			channelRoute.DELETE("/disabled", controller.DeleteDisabledChannel)
@@ -176,6 +185,7 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.POST("/ollama/pull/stream", controller.OllamaPullModelStream)
 			channelRoute.DELETE("/ollama/delete", controller.OllamaDeleteModel)
 			channelRoute.GET("/ollama/version/:id", controller.OllamaVersion)
+			channelRoute.GET("/self_hosted/metrics/:id", controller.SelfHostedMetrics)
 			channelRoute.POST("/batch/tag", controller.BatchSetChannelTag)
 			channelRoute.GET("/tag/models", controller.GetTagModels)
 			channelRoute.POST("/copy/:id", controller.CopyChannel)
@@ -209,6 +219,16 @@ func SetApiRouter(router *gin.Engine) {
 			ticketRoute.POST("/:id/reply", controller.ReplyTicket)
 			ticketRoute.POST("/:id/close", controller.CloseTicket)
 			ticketRoute.POST("/:id/status", controller.UpdateTicketStatus)
+			ticketRoute.POST("/:id/priority", controller.UpdateTicketPriority)
+			ticketRoute.POST("/:id/assign", controller.AssignTicket)
+			ticketRoute.POST("/:id/request_ids", controller.UpdateTicketRequestIds)
+			ticketRoute.GET("/:id/logs", controller.GetTicketLogs)
+			ticketRoute.POST("/:id/attachment", controller.UploadTicketAttachment)
+			ticketRoute.GET("/:id/attachment/:attachment_id", controller.GetTicketAttachment)
+			ticketRoute.GET("/canned_reply", controller.GetTicketCannedReplies)
+			ticketRoute.POST("/canned_reply", controller.CreateTicketCannedReply)
+			ticketRoute.PUT("/canned_reply/:reply_id", controller.UpdateTicketCannedReply)
+			ticketRoute.DELETE("/canned_reply/:reply_id", controller.DeleteTicketCannedReply)
 		}
 
 		usageRoute := apiRouter.Group("/usage")
@@ -232,6 +252,25 @@ func SetApiRouter(router *gin.Engine) {
 			redemptionRoute.DELETE("/invalid", controller.DeleteInvalidRedemption)
 			redemptionRoute.DELETE("/:id", controller.DeleteRedemption)
 		}
+
+		couponRoute := apiRouter.Group("/coupon")
+		couponRoute.Use(middleware.AdminAuth())
+		{
+			couponRoute.GET("/", controller.GetAllCoupons)
+			couponRoute.GET("/:id", controller.GetCoupon)
+			couponRoute.POST("/", controller.AddCoupon)
+			couponRoute.PUT("/", controller.UpdateCoupon)
+			couponRoute.DELETE("/:id", controller.DeleteCoupon)
+		}
+		gatewayToolRoute := apiRouter.Group("/gateway_tool")
+		gatewayToolRoute.Use(middleware.AdminAuth())
+		{
+			gatewayToolRoute.GET("/", controller.GetAllGatewayTools)
+			gatewayToolRoute.GET("/:id", controller.GetGatewayTool)
+			gatewayToolRoute.POST("/", controller.AddGatewayTool)
+			gatewayToolRoute.PUT("/", controller.UpdateGatewayTool)
+			gatewayToolRoute.DELETE("/:id", controller.DeleteGatewayTool)
+		}
 		logRoute := apiRouter.Group("/log")
 		logRoute.GET("/", middleware.AdminAuth(), controller.GetAllLogs)
 		logRoute.DELETE("/", middleware.AdminAuth(), controller.DeleteHistoryLogs)
@@ -247,6 +286,7 @@ func SetApiRouter(router *gin.Engine) {
 		dataRoute.GET("/users", middleware.AdminAuth(), controller.GetQuotaDataGroupByUser)
 		dataRoute.GET("/self", middleware.UserAuth(), controller.GetUserQuotaDates)
 		dataRoute.GET("/media_convert_stats", middleware.AdminAuth(), controller.GetAllMediaConvertStats)
+		dataRoute.GET("/cache_economics", middleware.AdminAuth(), controller.GetCacheEconomicsReport)
 		dataRoute.GET("/self/media_convert_stats", middleware.UserAuth(), controller.GetUserMediaConvertStats)
 		dataRoute.POST("/recalculate", middleware.AdminAuth(), controller.RecalculateQuotaData)
 
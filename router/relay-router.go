@@ -5,6 +5,7 @@ import (
 	"github.com/zhongruan0522/new-api/controller"
 	"github.com/zhongruan0522/new-api/middleware"
 	"github.com/zhongruan0522/new-api/relay"
+	"github.com/zhongruan0522/new-api/service"
 	"github.com/zhongruan0522/new-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -21,6 +22,12 @@ func SetRelayRouter(router *gin.Engine) {
 		mcpRouter.GET("/image/:id", relay.RelayStoredImage)
 		mcpRouter.GET("/video/:id", relay.RelayStoredVideo)
 	}
+	// Built-in MCP server (Streamable HTTP). Tool calls are replayed through this
+	// engine so they go through the regular relay, billing and logging path.
+	service.SetMcpRelayHandler(router)
+	router.POST("/mcp", middleware.SystemPerformanceCheck(), middleware.TokenAuth(), controller.McpServer)
+	router.GET("/mcp", controller.McpServer)
+	router.DELETE("/mcp", controller.McpServer)
 
 	// https://platform.openai.com/docs/api-reference/introduction
 	modelsRouter := router.Group("/v1/models")
@@ -82,6 +89,17 @@ func SetRelayRouter(router *gin.Engine) {
 			controller.Relay(c, types.RelayFormatOpenAIRealtime)
 		})
 	}
+	{
+		// 网关侧保存的 Responses（不经过渠道分发）
+		relayV1Router.GET("/responses/:id", controller.GetStoredResponse)
+		relayV1Router.DELETE("/responses/:id", controller.DeleteStoredResponse)
+		relayV1Router.GET("/responses/:id/input_items", controller.ListStoredResponseInputItems)
+	}
+	{
+		// 异步视频任务查询（不经过渠道分发）
+		relayV1Router.GET("/videos/:id", controller.GetVideo)
+		relayV1Router.GET("/videos/:id/content", controller.GetVideoContent)
+	}
 	{
 		//http router
 		httpRouter := relayV1Router.Group("")
@@ -118,6 +136,14 @@ func SetRelayRouter(router *gin.Engine) {
 		httpRouter.POST("/images/edits", func(c *gin.Context) {
 			controller.Relay(c, types.RelayFormatOpenAIImage)
 		})
+		httpRouter.POST("/images/variations", func(c *gin.Context) {
+			controller.Relay(c, types.RelayFormatOpenAIImage)
+		})
+
+		// video related routes
+		httpRouter.POST("/videos", func(c *gin.Context) {
+			controller.Relay(c, types.RelayFormatOpenAIVideo)
+		})
 
 		// embedding related routes
 		httpRouter.POST("/embeddings", func(c *gin.Context) {
@@ -154,7 +180,6 @@ func SetRelayRouter(router *gin.Engine) {
 		})
 
 		// not implemented
-		httpRouter.POST("/images/variations", controller.RelayNotImplemented)
 		httpRouter.GET("/files", controller.RelayNotImplemented)
 		httpRouter.POST("/files", controller.RelayNotImplemented)
 		httpRouter.DELETE("/files/:id", controller.RelayNotImplemented)
@@ -1,9 +1,28 @@
 package service
 
 import (
+	"bytes"
 	"encoding/base64"
+	"encoding/binary"
 	"fmt"
+	"io"
+	"maps"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"slices"
 	"strings"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/logger"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	relayconstant "github.com/zhongruan0522/new-api/relay/constant"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/gin-gonic/gin"
 )
 
 func parseAudio(audioBase64 string, format string) (duration float64, err error) {
@@ -46,3 +65,227 @@ func DecodeBase64AudioData(audioBase64 string) (string, error) {
 
 	return audioBase64, nil
 }
+
+// AudioDurationToTokens 按时长折算 token：一分钟 1000 token，与 $price / minute 对齐
+func AudioDurationToTokens(duration float64) int {
+	return int(math.Round(math.Ceil(duration) / 60.0 * 1000))
+}
+
+// PCMDuration 计算无文件头的 16-bit PCM 数据时长
+func PCMDuration(size int, sampleRate int, channels int) float64 {
+	if sampleRate <= 0 || channels <= 0 {
+		return 0
+	}
+	return float64(size) / float64(sampleRate*2*channels)
+}
+
+// PCMToWAV 为 16-bit PCM 数据加上 WAV 文件头
+func PCMToWAV(pcm []byte, sampleRate int, channels int) []byte {
+	var buf bytes.Buffer
+	byteRate := sampleRate * channels * 2
+	buf.WriteString("RIFF")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(36+len(pcm)))
+	buf.WriteString("WAVEfmt ")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(16))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(1))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(channels))
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(byteRate))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(channels*2))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(16))
+	buf.WriteString("data")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(len(pcm)))
+	buf.Write(pcm)
+	return buf.Bytes()
+}
+
+// BuildSpeechUsage 按合成音频的时长计算 TTS 的 usage。
+// pcm 格式没有文件头，需要传入 pcmSampleRate；无法解析时长时按音频大小估算
+func BuildSpeechUsage(c *gin.Context, info *relaycommon.RelayInfo, audio []byte, format string, pcmSampleRate int) *dto.Usage {
+	usage := &dto.Usage{}
+	usage.PromptTokens = info.GetEstimatePromptTokens()
+	usage.PromptTokensDetails.TextTokens = usage.PromptTokens
+
+	var duration float64
+	var durationErr error
+	if format == "pcm" {
+		duration = PCMDuration(len(audio), pcmSampleRate, 1)
+	} else {
+		duration, durationErr = common.GetAudioDuration(c.Request.Context(), bytes.NewReader(audio), "."+format)
+	}
+
+	if durationErr != nil {
+		logger.LogWarn(c, fmt.Sprintf("failed to get audio duration: %v", durationErr))
+		// 如果无法获取时长，则设置保底的 CompletionTokens，根据body大小计算
+		sizeInKB := float64(len(audio)) / 1000.0
+		estimatedTokens := int(math.Ceil(sizeInKB)) // 粗略估算每KB约等于1 token
+		usage.CompletionTokens = estimatedTokens
+		usage.CompletionTokenDetails.AudioTokens = estimatedTokens
+	} else if duration > 0 {
+		completionTokens := AudioDurationToTokens(duration)
+		usage.CompletionTokens = completionTokens
+		usage.CompletionTokenDetails.AudioTokens = completionTokens
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
+
+// AudioFileInput 是转写 / 翻译请求中上传的音频文件
+type AudioFileInput struct {
+	Filename string
+	MimeType string
+	Data     []byte
+	// Duration 为 0 表示无法解析时长
+	Duration float64
+	// Language 与 Prompt 来自同一表单，供需要自行拼装请求的渠道使用
+	Language string
+	Prompt   string
+}
+
+// TranscriptionMeta 是转写请求的输入音频时长与语言，用于计费和 verbose_json 输出
+type TranscriptionMeta struct {
+	Duration float64
+	Language string
+}
+
+// TranscriptionMeta 返回上传音频的转写元信息
+func (input *AudioFileInput) TranscriptionMeta() TranscriptionMeta {
+	return TranscriptionMeta{Duration: input.Duration, Language: input.Language}
+}
+
+// BuildAudioFileForm 只用给定字段和音频文件重建 multipart 表单，避免上游不支持的 OpenAI 参数被转发
+func BuildAudioFileForm(input *AudioFileInput, fields map[string]string) (*bytes.Buffer, string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		if fields[key] == "" {
+			continue
+		}
+		if err := writer.WriteField(key, fields[key]); err != nil {
+			return nil, "", fmt.Errorf("error writing form field %s: %w", key, err)
+		}
+	}
+	part, err := writer.CreateFormFile("file", input.Filename)
+	if err != nil {
+		return nil, "", fmt.Errorf("error creating form file: %w", err)
+	}
+	if _, err := part.Write(input.Data); err != nil {
+		return nil, "", fmt.Errorf("error writing form file: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return &body, writer.FormDataContentType(), nil
+}
+
+// GetAudioFileInput 读取 /v1/audio/transcriptions 与 /v1/audio/translations 上传的音频文件
+func GetAudioFileInput(c *gin.Context) (*AudioFileInput, error) {
+	form, err := common.ParseMultipartFormReusable(c)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing multipart form: %w", err)
+	}
+	fileHeaders := form.File["file"]
+	if len(fileHeaders) == 0 {
+		return nil, fmt.Errorf("file is required")
+	}
+	fileHeader := fileHeaders[0]
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, fmt.Errorf("error opening audio file: %w", err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading audio file: %w", err)
+	}
+
+	input := &AudioFileInput{
+		Filename: fileHeader.Filename,
+		MimeType: fileHeader.Header.Get("Content-Type"),
+		Data:     data,
+	}
+	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+	if !strings.HasPrefix(input.MimeType, "audio/") && !strings.HasPrefix(input.MimeType, "video/") {
+		if byExt := mime.TypeByExtension(ext); byExt != "" {
+			input.MimeType = byExt
+		} else {
+			input.MimeType = http.DetectContentType(data)
+		}
+	}
+	if duration, err := common.GetAudioDuration(c.Request.Context(), bytes.NewReader(data), ext); err == nil {
+		input.Duration = duration
+	}
+	if values := form.Value["language"]; len(values) > 0 {
+		input.Language = values[0]
+	}
+	if values := form.Value["prompt"]; len(values) > 0 {
+		input.Prompt = values[0]
+	}
+	return input, nil
+}
+
+// BuildTranscriptionUsage 按输入音频时长计算转写 usage，无法解析时长时沿用预估值
+func BuildTranscriptionUsage(info *relaycommon.RelayInfo, duration float64) *dto.Usage {
+	usage := &dto.Usage{}
+	usage.PromptTokens = info.GetEstimatePromptTokens()
+	if duration > 0 {
+		usage.PromptTokens = AudioDurationToTokens(duration)
+	}
+	usage.TotalTokens = usage.PromptTokens
+	return usage
+}
+
+// IsSupportedTranscriptionFormat 判断网关自行拼装转写结果时是否支持该 response_format
+func IsSupportedTranscriptionFormat(responseFormat string) bool {
+	switch responseFormat {
+	case "", "json", "text", "verbose_json":
+		return true
+	default:
+		return false
+	}
+}
+
+// WriteTranscriptionResponse 按 OpenAI 的 response_format 输出转写结果
+func WriteTranscriptionResponse(c *gin.Context, text string, responseFormat string, task string, language string, duration float64) {
+	switch responseFormat {
+	case "text":
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(text))
+	case "verbose_json":
+		c.JSON(http.StatusOK, dto.WhisperVerboseJSONResponse{
+			Task:     task,
+			Language: language,
+			Duration: duration,
+			Text:     text,
+		})
+	default:
+		c.JSON(http.StatusOK, dto.AudioResponse{Text: text})
+	}
+}
+
+// HandleTranscriptionResponse 读取上游转写响应，由 extractText 取出文本后按请求的 response_format 输出，按输入音频时长计费
+func HandleTranscriptionResponse(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response, meta TranscriptionMeta, extractText func(body []byte) (string, *types.NewAPIError)) (*dto.Usage, *types.NewAPIError) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+	}
+	CloseResponseBodyGracefully(resp)
+
+	text, apiErr := extractText(body)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	responseFormat := ""
+	if audioReq, ok := info.Request.(*dto.AudioRequest); ok {
+		responseFormat = audioReq.ResponseFormat
+	}
+	WriteTranscriptionResponse(c, text, responseFormat, TranscriptionTask(info.RelayMode), meta.Language, meta.Duration)
+	return BuildTranscriptionUsage(info, meta.Duration), nil
+}
+
+// TranscriptionTask 返回 verbose_json 中的 task 字段
+func TranscriptionTask(relayMode int) string {
+	if relayMode == relayconstant.RelayModeAudioTranslation {
+		return "translate"
+	}
+	return "transcribe"
+}
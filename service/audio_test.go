@@ -0,0 +1,33 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"testing"
+
+	"github.com/zhongruan0522/new-api/common"
+)
+
+func TestPCMToWAVDurationMatchesPCM(t *testing.T) {
+	// 1.5 秒 24kHz 单声道 16-bit PCM
+	pcm := make([]byte, 24000*2*3/2)
+	wav := PCMToWAV(pcm, 24000, 1)
+
+	duration, err := common.GetAudioDuration(context.Background(), bytes.NewReader(wav), ".wav")
+	if err != nil {
+		t.Fatalf("GetAudioDuration returned error: %v", err)
+	}
+	if math.Abs(duration-1.5) > 0.001 || math.Abs(PCMDuration(len(pcm), 24000, 1)-1.5) > 0.001 {
+		t.Fatalf("unexpected duration: wav=%f pcm=%f", duration, PCMDuration(len(pcm), 24000, 1))
+	}
+}
+
+func TestAudioDurationToTokens(t *testing.T) {
+	cases := map[float64]int{0.2: 17, 1.5: 33, 60: 1000, 90.1: 1517}
+	for duration, want := range cases {
+		if got := AudioDurationToTokens(duration); got != want {
+			t.Errorf("AudioDurationToTokens(%v) = %d, want %d", duration, got, want)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
 	"github.com/zhongruan0522/new-api/logger"
 	"github.com/zhongruan0522/new-api/model"
 	relaycommon "github.com/zhongruan0522/new-api/relay/common"
@@ -23,7 +24,9 @@ type BillingSession struct {
 	fundingSettled   bool
 	settled          bool
 	refunded         bool
-	mu               sync.Mutex
+	// usage 多轮请求（网关工具循环）累加的上游用量
+	usage dto.Usage
+	mu    sync.Mutex
 }
 
 func (s *BillingSession) Settle(actualQuota int) error {
@@ -113,6 +116,18 @@ func (s *BillingSession) GetPreConsumedQuota() int {
 	return s.preConsumedQuota
 }
 
+func (s *BillingSession) AddUsage(usage *dto.Usage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	relaycommon.AccumulateUsage(&s.usage, usage)
+}
+
+func (s *BillingSession) GetUsage() dto.Usage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.usage
+}
+
 func (s *BillingSession) preConsume(c *gin.Context, quota int) *types.NewAPIError {
 	effectiveQuota := quota
 	if s.shouldTrust(c) {
@@ -209,58 +224,13 @@ func (s *BillingSession) decreaseTokenQuota(quota int) error {
 
 // increaseTokenQuota 根据配额类型退还 token 额度
 func (s *BillingSession) increaseTokenQuota(quota int) error {
-	quotaType := s.relayInfo.TokenQuotaType
-	tokenId := s.relayInfo.TokenId
-	tokenKey := s.relayInfo.TokenKey
-
-	switch quotaType {
-	case 0: // 无限额度，只回滚已用额度，不恢复剩余额度
-		return model.UpdateTokenUsedQuota(tokenId, tokenKey, -quota)
-	case 1: // 永久限额
-		return model.IncreaseTokenQuota(tokenId, tokenKey, quota)
-	case 2: // 时段限额
-		return model.IncreaseWindowQuota(tokenId, tokenKey, quota)
-	case 3: // 时段+周期限额
-		if err := model.IncreaseWindowQuota(tokenId, tokenKey, quota); err != nil {
-			return err
-		}
-		if err := model.IncreaseCycleQuota(tokenId, tokenKey, quota); err != nil {
-			if rollbackErr := model.DecreaseWindowQuota(tokenId, tokenKey, quota); rollbackErr != nil {
-				common.SysError(fmt.Sprintf("rollback window quota failed after cycle increase error: %v (rollback: %v)", err, rollbackErr))
-			}
-			return err
-		}
-		return nil
-	default:
-		return model.IncreaseTokenQuota(tokenId, tokenKey, quota)
-	}
+	return s.increaseTokenQuotaByAmount(s.relayInfo.TokenId, s.relayInfo.TokenKey, quota)
 }
 
-// increaseTokenQuotaByAmount 退还 token 额度（用于退款场景，使用独立的 tokenId/tokenKey 参数）
+// increaseTokenQuotaByAmount 退还 token 额度（用于退款场景，使用独立的 tokenId/tokenKey 参数）。
+// 按额度类型退还的规则在 model.RefundTokenQuota 中，异步任务退款也使用同一规则
 func (s *BillingSession) increaseTokenQuotaByAmount(tokenId int, tokenKey string, quota int) error {
-	quotaType := s.relayInfo.TokenQuotaType
-
-	switch quotaType {
-	case 0:
-		return model.UpdateTokenUsedQuota(tokenId, tokenKey, -quota)
-	case 1:
-		return model.IncreaseTokenQuota(tokenId, tokenKey, quota)
-	case 2:
-		return model.IncreaseWindowQuota(tokenId, tokenKey, quota)
-	case 3:
-		if err := model.IncreaseWindowQuota(tokenId, tokenKey, quota); err != nil {
-			return err
-		}
-		if err := model.IncreaseCycleQuota(tokenId, tokenKey, quota); err != nil {
-			if rollbackErr := model.DecreaseWindowQuota(tokenId, tokenKey, quota); rollbackErr != nil {
-				common.SysError(fmt.Sprintf("rollback window quota failed after cycle increase error: %v (rollback: %v)", err, rollbackErr))
-			}
-			return err
-		}
-		return nil
-	default:
-		return model.IncreaseTokenQuota(tokenId, tokenKey, quota)
-	}
+	return model.RefundTokenQuota(tokenId, tokenKey, s.relayInfo.TokenQuotaType, quota)
 }
 
 func NewBillingSession(c *gin.Context, relayInfo *relaycommon.RelayInfo, preConsumedQuota int) (*BillingSession, *types.NewAPIError) {
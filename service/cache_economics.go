@@ -0,0 +1,200 @@
+package service
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/model"
+	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	CacheEconomicsGroupByChannel = "channel"
+	CacheEconomicsGroupByModel   = "model"
+	CacheEconomicsGroupByRule    = "rule"
+)
+
+// CacheUsageObservation 一次请求的提示词缓存用量，倍率由结算方按实际计费口径传入
+type CacheUsageObservation struct {
+	UncachedTokens   int
+	CacheReadTokens  int
+	CacheWriteTokens int
+	// CacheWriteWeightedTokens 缓存写入 tokens 乘以对应写入倍率之和（Claude 5m/1h 写入倍率不同）
+	CacheWriteWeightedTokens float64
+	CacheRatio               float64
+	// PromptRatio 模型倍率 × 分组倍率；按次计费时为 0，只统计 tokens
+	PromptRatio float64
+}
+
+// ObserveCacheUsage 在结算时记录缓存读写用量与费用，并区分亲和渠道保持、重试切走和故障改选三种落点
+func ObserveCacheUsage(c *gin.Context, info *relaycommon.RelayInfo, obs CacheUsageObservation) {
+	if !common.DataExportEnabled || info == nil {
+		return
+	}
+	obs.UncachedTokens = max(obs.UncachedTokens, 0)
+	inputTokens := obs.UncachedTokens + obs.CacheReadTokens + obs.CacheWriteTokens
+	if inputTokens <= 0 {
+		return
+	}
+	data := buildCacheUsageData(obs)
+	data.ChannelId = info.ChannelId
+	data.ModelName = info.OriginModelName
+	if meta, ok := getChannelAffinityMeta(c); ok {
+		data.RuleName = meta.RuleName
+		preferred := c.GetInt(ginKeyChannelAffinityPreferredChannel)
+		_, affinityUsed := c.Get(ginKeyChannelAffinityLogInfo)
+		expectedRate, _ := c.Get(ginKeyChannelAffinityExpectedCacheRate)
+		rate, _ := expectedRate.(float64)
+		applyCacheAffinityOutcome(data, obs, preferred, info.ChannelId, affinityUsed, rate)
+	}
+	model.LogCacheUsageData(data, common.GetTimestamp())
+}
+
+func buildCacheUsageData(obs CacheUsageObservation) *model.CacheUsageData {
+	inputTokens := obs.UncachedTokens + obs.CacheReadTokens + obs.CacheWriteTokens
+	data := &model.CacheUsageData{
+		Requests:         1,
+		UncachedTokens:   int64(obs.UncachedTokens),
+		CacheReadTokens:  int64(obs.CacheReadTokens),
+		CacheWriteTokens: int64(obs.CacheWriteTokens),
+		NoCacheCost:      float64(inputTokens) * obs.PromptRatio,
+		UncachedCost:     float64(obs.UncachedTokens) * obs.PromptRatio,
+		CacheReadCost:    float64(obs.CacheReadTokens) * obs.CacheRatio * obs.PromptRatio,
+		CacheWriteCost:   obs.CacheWriteWeightedTokens * obs.PromptRatio,
+	}
+	if obs.CacheReadTokens > 0 {
+		data.CacheHitRequests = 1
+	}
+	return data
+}
+
+// applyCacheAffinityOutcome 亲和缓存中已有会话渠道时，统计本次是否落在该渠道；
+// 未落在该渠道的请求按会话历史命中率估算少命中的缓存 tokens 与多付的费用
+func applyCacheAffinityOutcome(data *model.CacheUsageData, obs CacheUsageObservation, preferredChannelId int, finalChannelId int, affinityUsed bool, expectedRate float64) {
+	if preferredChannelId <= 0 {
+		return
+	}
+	if preferredChannelId == finalChannelId {
+		data.AffinityKeptRequests = 1
+		return
+	}
+	inputTokens := obs.UncachedTokens + obs.CacheReadTokens + obs.CacheWriteTokens
+	lostTokens := max(int64(math.Round(expectedRate*float64(inputTokens)))-int64(obs.CacheReadTokens), 0)
+	lostCost := float64(lostTokens) * (1 - obs.CacheRatio) * obs.PromptRatio
+	if affinityUsed {
+		// 先选中了亲和渠道，失败后重试到其他渠道
+		data.RetryBrokenRequests = 1
+		data.RetryLostTokens = lostTokens
+		data.RetryLostCost = lostCost
+		return
+	}
+	// 亲和渠道已禁用或不可用，分发时直接改选了其他渠道
+	data.FailoverBrokenRequests = 1
+	data.FailoverLostTokens = lostTokens
+	data.FailoverLostCost = lostCost
+}
+
+// CacheEconomicsRow 一个维度取值下的缓存经济性汇总，费用为额度单位
+type CacheEconomicsRow struct {
+	Key string `json:"key"`
+	model.CacheUsageData
+	InputTokens  int64   `json:"input_tokens"`
+	CacheHitRate float64 `json:"cache_hit_rate"` // 缓存读取 tokens 占输入 tokens 的比例
+	ActualCost   float64 `json:"actual_cost"`
+	Savings      float64 `json:"savings"` // 相比不使用缓存节省的费用，写入溢价可能使其为负
+	SavingsRate  float64 `json:"savings_rate"`
+	LostCost     float64 `json:"lost_cost"` // 亲和断开估算多付的费用
+}
+
+type CacheEconomicsReport struct {
+	GroupBy string              `json:"group_by"`
+	Total   CacheEconomicsRow   `json:"total"`
+	Rows    []CacheEconomicsRow `json:"rows"`
+}
+
+func IsValidCacheEconomicsGroupBy(groupBy string) bool {
+	switch groupBy {
+	case CacheEconomicsGroupByChannel, CacheEconomicsGroupByModel, CacheEconomicsGroupByRule:
+		return true
+	}
+	return false
+}
+
+// BuildCacheEconomicsReport 按渠道、模型或亲和规则汇总缓存用量，按节省额降序排列
+func BuildCacheEconomicsReport(data []*model.CacheUsageData, groupBy string) CacheEconomicsReport {
+	report := CacheEconomicsReport{
+		GroupBy: groupBy,
+		Rows:    []CacheEconomicsRow{},
+	}
+	rows := make(map[string]*CacheEconomicsRow)
+	for _, item := range data {
+		key := cacheEconomicsKey(item, groupBy)
+		row, ok := rows[key]
+		if !ok {
+			row = &CacheEconomicsRow{Key: key}
+			rows[key] = row
+		}
+		row.add(item)
+		report.Total.add(item)
+	}
+	for _, row := range rows {
+		row.finalize()
+		report.Rows = append(report.Rows, *row)
+	}
+	report.Total.Key = "total"
+	report.Total.finalize()
+	sort.Slice(report.Rows, func(i, j int) bool {
+		if report.Rows[i].Savings != report.Rows[j].Savings {
+			return report.Rows[i].Savings > report.Rows[j].Savings
+		}
+		return report.Rows[i].Key < report.Rows[j].Key
+	})
+	return report
+}
+
+func cacheEconomicsKey(item *model.CacheUsageData, groupBy string) string {
+	switch groupBy {
+	case CacheEconomicsGroupByModel:
+		return item.ModelName
+	case CacheEconomicsGroupByRule:
+		return item.RuleName
+	default:
+		return strconv.Itoa(item.ChannelId)
+	}
+}
+
+func (r *CacheEconomicsRow) add(item *model.CacheUsageData) {
+	r.Requests += item.Requests
+	r.CacheHitRequests += item.CacheHitRequests
+	r.UncachedTokens += item.UncachedTokens
+	r.CacheReadTokens += item.CacheReadTokens
+	r.CacheWriteTokens += item.CacheWriteTokens
+	r.NoCacheCost += item.NoCacheCost
+	r.UncachedCost += item.UncachedCost
+	r.CacheReadCost += item.CacheReadCost
+	r.CacheWriteCost += item.CacheWriteCost
+	r.AffinityKeptRequests += item.AffinityKeptRequests
+	r.RetryBrokenRequests += item.RetryBrokenRequests
+	r.FailoverBrokenRequests += item.FailoverBrokenRequests
+	r.RetryLostTokens += item.RetryLostTokens
+	r.FailoverLostTokens += item.FailoverLostTokens
+	r.RetryLostCost += item.RetryLostCost
+	r.FailoverLostCost += item.FailoverLostCost
+}
+
+func (r *CacheEconomicsRow) finalize() {
+	r.InputTokens = r.UncachedTokens + r.CacheReadTokens + r.CacheWriteTokens
+	if r.InputTokens > 0 {
+		r.CacheHitRate = float64(r.CacheReadTokens) / float64(r.InputTokens)
+	}
+	r.ActualCost = r.UncachedCost + r.CacheReadCost + r.CacheWriteCost
+	r.Savings = r.NoCacheCost - r.ActualCost
+	if r.NoCacheCost > 0 {
+		r.SavingsRate = r.Savings / r.NoCacheCost
+	}
+	r.LostCost = r.RetryLostCost + r.FailoverLostCost
+}
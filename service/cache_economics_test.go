@@ -0,0 +1,90 @@
+package service
+
+import (
+	"math"
+	"testing"
+
+	"github.com/zhongruan0522/new-api/model"
+)
+
+func TestCacheAffinityOutcome(t *testing.T) {
+	obs := CacheUsageObservation{UncachedTokens: 800, CacheReadTokens: 200, CacheRatio: 0.1, PromptRatio: 2}
+
+	kept := buildCacheUsageData(obs)
+	applyCacheAffinityOutcome(kept, obs, 3, 3, true, 0.9)
+	if kept.AffinityKeptRequests != 1 || kept.RetryBrokenRequests != 0 || kept.RetryLostTokens != 0 {
+		t.Fatalf("kept = %+v", kept)
+	}
+
+	retry := buildCacheUsageData(obs)
+	applyCacheAffinityOutcome(retry, obs, 3, 5, true, 0.9)
+	// expected 900 cached tokens, got 200
+	if retry.RetryBrokenRequests != 1 || retry.RetryLostTokens != 700 {
+		t.Fatalf("retry = %+v", retry)
+	}
+	if math.Abs(retry.RetryLostCost-700*0.9*2) > 1e-9 {
+		t.Fatalf("RetryLostCost = %v", retry.RetryLostCost)
+	}
+
+	failover := buildCacheUsageData(obs)
+	applyCacheAffinityOutcome(failover, obs, 3, 5, false, 0.1)
+	if failover.FailoverBrokenRequests != 1 || failover.FailoverLostTokens != 0 {
+		t.Fatalf("failover = %+v", failover)
+	}
+
+	cold := buildCacheUsageData(obs)
+	applyCacheAffinityOutcome(cold, obs, 0, 5, false, 0)
+	if cold.AffinityKeptRequests+cold.RetryBrokenRequests+cold.FailoverBrokenRequests != 0 {
+		t.Fatalf("cold = %+v", cold)
+	}
+}
+
+func TestAffinityExpectedCacheRateUsesTotalInputTokens(t *testing.T) {
+	// Claude 语义：prompt_tokens 只含未缓存部分，缓存读 900、写 50
+	claude := ChannelAffinityUsageCacheStats{PromptTokens: 50, InputTokens: 1000, CachedTokens: 900}
+	if rate := affinityExpectedCacheRate(claude); math.Abs(rate-0.9) > 1e-9 {
+		t.Fatalf("claude rate = %v, want 0.9", rate)
+	}
+	// 旧的统计数据没有 input_tokens 时退回 prompt_tokens
+	legacy := ChannelAffinityUsageCacheStats{PromptTokens: 1000, CachedTokens: 300}
+	if rate := affinityExpectedCacheRate(legacy); math.Abs(rate-0.3) > 1e-9 {
+		t.Fatalf("legacy rate = %v, want 0.3", rate)
+	}
+	if rate := affinityExpectedCacheRate(ChannelAffinityUsageCacheStats{}); rate != 0 {
+		t.Fatalf("empty rate = %v, want 0", rate)
+	}
+}
+
+func TestBuildCacheEconomicsReport(t *testing.T) {
+	data := []*model.CacheUsageData{
+		{ChannelId: 1, ModelName: "claude", RuleName: "codex", Requests: 2, UncachedTokens: 100, CacheReadTokens: 900,
+			NoCacheCost: 1000, UncachedCost: 100, CacheReadCost: 90, RetryLostCost: 30},
+		{ChannelId: 1, ModelName: "gpt", Requests: 1, UncachedTokens: 500, CacheWriteTokens: 500,
+			NoCacheCost: 1000, UncachedCost: 500, CacheWriteCost: 625},
+		{ChannelId: 2, ModelName: "claude", RuleName: "codex", Requests: 1, UncachedTokens: 1000,
+			NoCacheCost: 1000, UncachedCost: 1000, FailoverLostCost: 20},
+	}
+
+	report := BuildCacheEconomicsReport(data, CacheEconomicsGroupByModel)
+	if len(report.Rows) != 2 || report.Rows[0].Key != "claude" {
+		t.Fatalf("rows = %+v", report.Rows)
+	}
+	claude := report.Rows[0]
+	if claude.Requests != 3 || claude.InputTokens != 2000 || claude.Savings != 810 || claude.LostCost != 50 {
+		t.Fatalf("claude = %+v", claude)
+	}
+	if math.Abs(claude.CacheHitRate-0.45) > 1e-9 {
+		t.Fatalf("CacheHitRate = %v", claude.CacheHitRate)
+	}
+	if gpt := report.Rows[1]; gpt.Savings != -125 {
+		t.Fatalf("gpt savings = %v, want cache write premium", gpt.Savings)
+	}
+	if report.Total.Requests != 4 || report.Total.Savings != 685 {
+		t.Fatalf("total = %+v", report.Total)
+	}
+
+	byChannel := BuildCacheEconomicsReport(data, CacheEconomicsGroupByChannel)
+	if len(byChannel.Rows) != 2 || byChannel.Rows[0].Key != "1" {
+		t.Fatalf("channel rows = %+v", byChannel.Rows)
+	}
+}
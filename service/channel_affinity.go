@@ -9,13 +9,13 @@ import (
 	"sync"
 	"time"
 
+	"github.com/gin-gonic/gin"
+	"github.com/samber/hot"
+	"github.com/tidwall/gjson"
 	"github.com/zhongruan0522/new-api/common"
 	"github.com/zhongruan0522/new-api/dto"
 	"github.com/zhongruan0522/new-api/pkg/cachex"
 	"github.com/zhongruan0522/new-api/setting/operation_setting"
-	"github.com/gin-gonic/gin"
-	"github.com/samber/hot"
-	"github.com/tidwall/gjson"
 )
 
 const (
@@ -24,6 +24,9 @@ const (
 	ginKeyChannelAffinityMeta       = "channel_affinity_meta"
 	ginKeyChannelAffinityLogInfo    = "channel_affinity_log_info"
 	ginKeyChannelAffinitySkipRetry  = "channel_affinity_skip_retry_on_failure"
+	// 亲和缓存命中的渠道及该会话此前的缓存命中率，用于统计亲和断开造成的缓存损失
+	ginKeyChannelAffinityPreferredChannel  = "channel_affinity_preferred_channel"
+	ginKeyChannelAffinityExpectedCacheRate = "channel_affinity_expected_cache_rate"
 
 	channelAffinityCacheNamespace           = "new-api:channel_affinity:v1"
 	channelAffinityUsageCacheStatsNamespace = "new-api:channel_affinity_usage_cache_stats:v1"
@@ -478,6 +481,8 @@ func GetPreferredChannelByAffinity(c *gin.Context, modelName string, usingGroup
 			return 0, false
 		}
 		if found {
+			c.Set(ginKeyChannelAffinityPreferredChannel, channelID)
+			c.Set(ginKeyChannelAffinityExpectedCacheRate, affinityExpectedCacheRate(GetChannelAffinityUsageCacheStats(rule.Name, usingGroup, modelName, affinityFingerprint(affinityValue))))
 			return channelID, true
 		}
 		return 0, false
@@ -485,6 +490,20 @@ func GetPreferredChannelByAffinity(c *gin.Context, modelName string, usingGroup
 	return 0, false
 }
 
+// affinityExpectedCacheRate 按会话在亲和窗口内的历史用量估算保持亲和时的缓存命中率
+func affinityExpectedCacheRate(stats ChannelAffinityUsageCacheStats) float64 {
+	// Claude 语义的 prompt_tokens 不含缓存，分母使用含缓存读写的输入 tokens
+	inputTokens := stats.InputTokens
+	if inputTokens <= 0 {
+		inputTokens = stats.PromptTokens
+	}
+	if inputTokens <= 0 {
+		return 0
+	}
+	hitTokens := max(stats.CachedTokens, stats.PromptCacheHitTokens)
+	return min(float64(hitTokens)/float64(inputTokens), 1)
+}
+
 func ShouldSkipRetryAfterChannelAffinityFailure(c *gin.Context) bool {
 	if c == nil {
 		return false
@@ -577,6 +596,7 @@ type ChannelAffinityUsageCacheStats struct {
 	WindowSeconds int64 `json:"window_seconds"`
 
 	PromptTokens         int64 `json:"prompt_tokens"`
+	InputTokens          int64 `json:"input_tokens"`
 	CompletionTokens     int64 `json:"completion_tokens"`
 	TotalTokens          int64 `json:"total_tokens"`
 	CachedTokens         int64 `json:"cached_tokens"`
@@ -590,6 +610,7 @@ type ChannelAffinityUsageCacheCounters struct {
 	WindowSeconds int64 `json:"window_seconds"`
 
 	PromptTokens         int64 `json:"prompt_tokens"`
+	InputTokens          int64 `json:"input_tokens"`
 	CompletionTokens     int64 `json:"completion_tokens"`
 	TotalTokens          int64 `json:"total_tokens"`
 	CachedTokens         int64 `json:"cached_tokens"`
@@ -599,12 +620,13 @@ type ChannelAffinityUsageCacheCounters struct {
 
 var channelAffinityUsageCacheStatsLocks [64]sync.Mutex
 
-func ObserveChannelAffinityUsageCacheFromContext(c *gin.Context, usage *dto.Usage) {
+// ObserveChannelAffinityUsageCacheFromContext 记录会话用量，inputTokens 为含缓存读写的输入 tokens
+func ObserveChannelAffinityUsageCacheFromContext(c *gin.Context, usage *dto.Usage, inputTokens int) {
 	statsCtx, ok := GetChannelAffinityStatsContext(c)
 	if !ok {
 		return
 	}
-	observeChannelAffinityUsageCache(statsCtx, usage)
+	observeChannelAffinityUsageCache(statsCtx, usage, inputTokens)
 }
 
 func GetChannelAffinityUsageCacheStats(ruleName, usingGroup, modelName, keyFp string) ChannelAffinityUsageCacheStats {
@@ -642,6 +664,7 @@ func GetChannelAffinityUsageCacheStats(ruleName, usingGroup, modelName, keyFp st
 		Total:                v.Total,
 		WindowSeconds:        v.WindowSeconds,
 		PromptTokens:         v.PromptTokens,
+		InputTokens:          v.InputTokens,
 		CompletionTokens:     v.CompletionTokens,
 		TotalTokens:          v.TotalTokens,
 		CachedTokens:         v.CachedTokens,
@@ -650,7 +673,7 @@ func GetChannelAffinityUsageCacheStats(ruleName, usingGroup, modelName, keyFp st
 	}
 }
 
-func observeChannelAffinityUsageCache(statsCtx ChannelAffinityStatsContext, usage *dto.Usage) {
+func observeChannelAffinityUsageCache(statsCtx ChannelAffinityStatsContext, usage *dto.Usage, inputTokens int) {
 	entryKey := channelAffinityUsageCacheEntryKey(statsCtx.RuleName, statsCtx.UsingGroup, statsCtx.ModelName, statsCtx.KeyFingerprint)
 	if entryKey == "" {
 		return
@@ -686,6 +709,7 @@ func observeChannelAffinityUsageCache(statsCtx ChannelAffinityStatsContext, usag
 	next.CachedTokens += cachedTokens
 	next.PromptCacheHitTokens += promptCacheHitTokens
 	next.PromptTokens += int64(usagePromptTokens(usage))
+	next.InputTokens += int64(inputTokens)
 	next.CompletionTokens += int64(usageCompletionTokens(usage))
 	next.TotalTokens += int64(usageTotalTokens(usage))
 	_ = cache.SetWithTTL(entryKey, next, ttl)
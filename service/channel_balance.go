@@ -0,0 +1,170 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/model"
+)
+
+// 渠道 other_info 中记录低余额处理状态的字段
+const (
+	lowBalanceStateKey            = "low_balance_action"
+	lowBalanceOriginalPriorityKey = "low_balance_original_priority"
+	lowBalanceTimeKey             = "low_balance_time"
+	lowBalanceDisableReasonKey    = "low_balance_disable_reason"
+)
+
+// ChannelBalanceStats 渠道余额历史与消耗预测
+type ChannelBalanceStats struct {
+	History        []*model.ChannelBalanceHistory `json:"history"`
+	Balance        float64                        `json:"balance"`
+	BurnRatePerDay float64                        `json:"burn_rate_per_day"`
+	// DaysRemaining 按当前消耗速度预计可用天数，-1 表示无法预测（无消耗或数据不足）
+	DaysRemaining float64 `json:"days_remaining"`
+}
+
+func formatLowBalanceNotifyType(channelId int) string {
+	return fmt.Sprintf("channel_low_balance_%d", channelId)
+}
+
+// IsChannelDisabledByLowBalance 判断渠道是否因低余额被自动禁用，用于余额恢复后重新启用。
+// 低余额禁用后若又因其他原因被自动禁用，禁用原因会被覆盖，此时不再视为低余额禁用
+func IsChannelDisabledByLowBalance(channel *model.Channel) bool {
+	if channel.Status != common.ChannelStatusAutoDisabled {
+		return false
+	}
+	info := channel.GetOtherInfo()
+	action, _ := info[lowBalanceStateKey].(string)
+	if dto.LowBalanceAction(action) != dto.LowBalanceActionDisable {
+		return false
+	}
+	reason, _ := info[lowBalanceDisableReasonKey].(string)
+	statusReason, _ := info["status_reason"].(string)
+	return reason != "" && reason == statusReason
+}
+
+// CheckChannelLowBalance 根据渠道的低余额阈值降低优先级、禁用渠道或仅通知，余额恢复后撤销处理
+func CheckChannelLowBalance(channel *model.Channel, balance float64) {
+	settings := channel.GetOtherSettings()
+	info := channel.GetOtherInfo()
+	state, _ := info[lowBalanceStateKey].(string)
+	triggered := state != ""
+
+	if settings.LowBalanceThreshold > 0 && balance < settings.LowBalanceThreshold {
+		if !triggered {
+			handleChannelLowBalance(channel, settings, balance)
+		}
+		return
+	}
+	if triggered {
+		restoreChannelLowBalance(channel, dto.LowBalanceAction(state), info, balance)
+	}
+}
+
+func handleChannelLowBalance(channel *model.Channel, settings dto.ChannelOtherSettings, balance float64) {
+	action := settings.LowBalanceAction.Normalize()
+	originalPriority := channel.GetPriority()
+	result := "仅通知"
+	disableReason := ""
+
+	switch action {
+	case dto.LowBalanceActionLowerPriority:
+		if err := model.UpdateChannelPriority(channel.Id, settings.LowBalancePriority); err != nil {
+			common.SysError(fmt.Sprintf("failed to lower channel priority: channel_id=%d, error=%v", channel.Id, err))
+			return
+		}
+		result = fmt.Sprintf("优先级已从 %d 调整为 %d", originalPriority, settings.LowBalancePriority)
+	case dto.LowBalanceActionDisable:
+		disableReason = fmt.Sprintf("余额 %.2f 低于阈值 %.2f", balance, settings.LowBalanceThreshold)
+		if !model.UpdateChannelStatus(channel.Id, "", common.ChannelStatusAutoDisabled, disableReason) {
+			return
+		}
+		result = "渠道已被禁用"
+	}
+
+	err := model.UpdateChannelOtherInfo(channel.Id, func(info map[string]interface{}) {
+		info[lowBalanceStateKey] = string(action)
+		info[lowBalanceOriginalPriorityKey] = originalPriority
+		info[lowBalanceTimeKey] = common.GetTimestamp()
+		if disableReason != "" {
+			info[lowBalanceDisableReasonKey] = disableReason
+		}
+	})
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to save low balance state: channel_id=%d, error=%v", channel.Id, err))
+	}
+
+	subject := fmt.Sprintf("通道「%s」（#%d）余额不足", channel.Name, channel.Id)
+	content := fmt.Sprintf("通道「%s」（#%d）余额 %.2f 低于阈值 %.2f，%s", channel.Name, channel.Id, balance, settings.LowBalanceThreshold, result)
+	NotifyRootUser(formatLowBalanceNotifyType(channel.Id), subject, content)
+}
+
+func restoreChannelLowBalance(channel *model.Channel, action dto.LowBalanceAction, info map[string]interface{}, balance float64) {
+	result := ""
+	switch action {
+	case dto.LowBalanceActionLowerPriority:
+		if priority, ok := info[lowBalanceOriginalPriorityKey].(float64); ok {
+			if err := model.UpdateChannelPriority(channel.Id, int64(priority)); err != nil {
+				common.SysError(fmt.Sprintf("failed to restore channel priority: channel_id=%d, error=%v", channel.Id, err))
+				return
+			}
+			result = fmt.Sprintf("，优先级已恢复为 %d", int64(priority))
+		}
+	case dto.LowBalanceActionDisable:
+		// 只恢复仍处于低余额禁用状态的渠道，其他原因导致的自动禁用保持不变
+		if IsChannelDisabledByLowBalance(channel) {
+			if model.UpdateChannelStatus(channel.Id, "", common.ChannelStatusEnabled, "") {
+				result = "，渠道已重新启用"
+			}
+		}
+	}
+
+	err := model.UpdateChannelOtherInfo(channel.Id, func(info map[string]interface{}) {
+		delete(info, lowBalanceStateKey)
+		delete(info, lowBalanceOriginalPriorityKey)
+		delete(info, lowBalanceTimeKey)
+		delete(info, lowBalanceDisableReasonKey)
+	})
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to clear low balance state: channel_id=%d, error=%v", channel.Id, err))
+	}
+
+	subject := fmt.Sprintf("通道「%s」（#%d）余额已恢复", channel.Name, channel.Id)
+	content := fmt.Sprintf("通道「%s」（#%d）余额已恢复至 %.2f%s", channel.Name, channel.Id, balance, result)
+	NotifyRootUser(formatLowBalanceNotifyType(channel.Id), subject, content)
+}
+
+// BuildChannelBalanceStats 根据余额历史计算日均消耗与预计可用天数；充值带来的余额上涨不计入消耗
+func BuildChannelBalanceStats(histories []*model.ChannelBalanceHistory) ChannelBalanceStats {
+	stats := ChannelBalanceStats{
+		History:       histories,
+		DaysRemaining: -1,
+	}
+	if len(histories) == 0 {
+		return stats
+	}
+	stats.Balance = histories[len(histories)-1].Balance
+	if len(histories) < 2 {
+		return stats
+	}
+
+	consumed := 0.0
+	for i := 1; i < len(histories); i++ {
+		if delta := histories[i-1].Balance - histories[i].Balance; delta > 0 {
+			consumed += delta
+		}
+	}
+	elapsed := histories[len(histories)-1].CreatedAt - histories[0].CreatedAt
+	if elapsed <= 0 || consumed <= 0 {
+		return stats
+	}
+	stats.BurnRatePerDay = consumed / (float64(elapsed) / 86400)
+	if stats.Balance > 0 {
+		stats.DaysRemaining = stats.Balance / stats.BurnRatePerDay
+	} else {
+		stats.DaysRemaining = 0
+	}
+	return stats
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"math"
+	"testing"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/model"
+)
+
+func TestBuildChannelBalanceStatsIgnoresTopUps(t *testing.T) {
+	histories := []*model.ChannelBalanceHistory{
+		{Balance: 100, CreatedAt: 0},
+		{Balance: 90, CreatedAt: 43200},
+		{Balance: 150, CreatedAt: 86400}, // top-up
+		{Balance: 140, CreatedAt: 172800},
+	}
+
+	stats := BuildChannelBalanceStats(histories)
+	if stats.Balance != 140 {
+		t.Fatalf("Balance = %v, want 140", stats.Balance)
+	}
+	if math.Abs(stats.BurnRatePerDay-10) > 1e-9 {
+		t.Fatalf("BurnRatePerDay = %v, want 10", stats.BurnRatePerDay)
+	}
+	if math.Abs(stats.DaysRemaining-14) > 1e-9 {
+		t.Fatalf("DaysRemaining = %v, want 14", stats.DaysRemaining)
+	}
+}
+
+func TestBuildChannelBalanceStatsWithoutConsumption(t *testing.T) {
+	stats := BuildChannelBalanceStats([]*model.ChannelBalanceHistory{{Balance: 50, CreatedAt: 100}})
+	if stats.DaysRemaining != -1 || stats.BurnRatePerDay != 0 {
+		t.Fatalf("stats = %+v, want no projection", stats)
+	}
+}
+
+func TestIsChannelDisabledByLowBalanceRequiresLowBalanceReason(t *testing.T) {
+	newChannel := func(statusReason string) *model.Channel {
+		channel := &model.Channel{Status: common.ChannelStatusAutoDisabled}
+		channel.SetOtherInfo(map[string]interface{}{
+			lowBalanceStateKey:         string(dto.LowBalanceActionDisable),
+			lowBalanceDisableReasonKey: "余额 1.00 低于阈值 5.00",
+			"status_reason":            statusReason,
+		})
+		return channel
+	}
+
+	if !IsChannelDisabledByLowBalance(newChannel("余额 1.00 低于阈值 5.00")) {
+		t.Fatal("channel disabled for low balance should be restorable")
+	}
+	// 低余额禁用后又因上游错误被自动禁用，余额恢复时不能重新启用
+	if IsChannelDisabledByLowBalance(newChannel("status code 401")) {
+		t.Fatal("channel auto-disabled for another reason should not be restorable")
+	}
+
+	manual := newChannel("余额 1.00 低于阈值 5.00")
+	manual.Status = common.ChannelStatusManuallyDisabled
+	if IsChannelDisabledByLowBalance(manual) {
+		t.Fatal("manually disabled channel should not be restorable")
+	}
+}
@@ -168,5 +168,11 @@ func CacheGetRandomSatisfiedChannel(param *RetryParam) (*model.Channel, string,
 			return nil, param.TokenGroup, err
 		}
 	}
+	// 灰度渠道只在首次选渠道时按比例采样，重试时回到正式渠道
+	if channel != nil && param.GetRetry() == 0 {
+		if canary := pickCanaryChannel(param, selectGroup, channel); canary != nil {
+			return canary, selectGroup, nil
+		}
+	}
 	return channel, selectGroup, nil
 }
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"math/rand"
+	"slices"
+	"sort"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/model"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+// 报表中展示的错误信息条数
+const channelTrafficTopErrors = 5
+
+// ChannelTrafficTrace 记录本次请求在灰度采样中的角色，relay 首次尝试结束后据此写入采样记录
+type ChannelTrafficTrace struct {
+	CandidateChannelId int
+	Role               string
+	Recorded           bool
+}
+
+// ChannelTrafficStats 一组采样的汇总指标；延迟与用量只统计成功请求
+type ChannelTrafficStats struct {
+	Requests            int                   `json:"requests"`
+	Success             int                   `json:"success"`
+	SuccessRate         float64               `json:"success_rate"`
+	AvgLatencyMs        float64               `json:"avg_latency_ms"`
+	P50LatencyMs        int64                 `json:"p50_latency_ms"`
+	P95LatencyMs        int64                 `json:"p95_latency_ms"`
+	AvgPromptTokens     float64               `json:"avg_prompt_tokens"`
+	AvgCompletionTokens float64               `json:"avg_completion_tokens"`
+	TopErrors           []ChannelTrafficError `json:"top_errors"`
+}
+
+type ChannelTrafficError struct {
+	Message string `json:"message"`
+	Count   int    `json:"count"`
+}
+
+// ChannelTrafficReport 候选渠道（灰度/影子）与同批正式渠道请求的对比报表
+type ChannelTrafficReport struct {
+	ChannelId        int                           `json:"channel_id"`
+	Mode             dto.ChannelTrafficMode        `json:"mode"`
+	Percent          float64                       `json:"percent"`
+	Candidate        ChannelTrafficStats           `json:"candidate"`
+	Baseline         ChannelTrafficStats           `json:"baseline"`
+	SuccessRateDelta float64                       `json:"success_rate_delta"`
+	LatencyDeltaMs   float64                       `json:"latency_delta_ms"`
+	Recent           []*model.ChannelTrafficSample `json:"recent"`
+}
+
+func trafficSampled(percent float64) bool {
+	return percent > 0 && rand.Float64()*100 < percent
+}
+
+// pickCanaryChannel 按灰度比例采样：命中时返回灰度渠道；未命中时按同一比例把本次请求标记为对照样本。
+// 每个请求只采样一次，重试与虚拟模型回退都回到正式渠道
+func pickCanaryChannel(param *RetryParam, group string, selected *model.Channel) *model.Channel {
+	c := param.Ctx
+	if c == nil {
+		return nil
+	}
+	if _, exists := common.GetContextKey(c, constant.ContextKeyChannelTrafficTrace); exists {
+		return nil
+	}
+	canaries := eligibleCanaryChannels(model.GetTrafficChannels(group, param.ModelName, dto.ChannelTrafficModeCanary), param, selected)
+	if len(canaries) == 0 {
+		return nil
+	}
+	for _, canary := range canaries {
+		_, percent := canary.GetTrafficMode()
+		if trafficSampled(percent) {
+			common.SetContextKey(c, constant.ContextKeyChannelTrafficTrace, &ChannelTrafficTrace{
+				CandidateChannelId: canary.Id,
+				Role:               model.ChannelTrafficRoleCandidate,
+			})
+			return canary
+		}
+	}
+	for _, canary := range canaries {
+		_, percent := canary.GetTrafficMode()
+		if trafficSampled(percent) {
+			common.SetContextKey(c, constant.ContextKeyChannelTrafficTrace, &ChannelTrafficTrace{
+				CandidateChannelId: canary.Id,
+				Role:               model.ChannelTrafficRoleBaseline,
+			})
+			break
+		}
+	}
+	return nil
+}
+
+// eligibleCanaryChannels 对灰度渠道应用与常规选渠道相同的排除与请求格式偏好：
+// 跳过被排除的渠道；正式渠道匹配请求格式时，不匹配的灰度渠道不参与采样
+func eligibleCanaryChannels(canaries []*model.Channel, param *RetryParam, selected *model.Channel) []*model.Channel {
+	eligible := make([]*model.Channel, 0, len(canaries))
+	for _, canary := range canaries {
+		if param.ExcludeChannelId > 0 && canary.Id == param.ExcludeChannelId {
+			continue
+		}
+		eligible = append(eligible, canary)
+	}
+	if len(eligible) == 0 {
+		return nil
+	}
+	preferred := model.PreferChannelsByRequestFormat(append([]*model.Channel{selected}, eligible...),
+		types.RelayFormatToPreferredAPIType(param.RelayFormat), param.RelayFormat)
+	return slices.DeleteFunc(eligible, func(canary *model.Channel) bool {
+		return !slices.Contains(preferred, canary)
+	})
+}
+
+func GetChannelTrafficTrace(c *gin.Context) *ChannelTrafficTrace {
+	trace, _ := common.GetContextKeyType[*ChannelTrafficTrace](c, constant.ContextKeyChannelTrafficTrace)
+	return trace
+}
+
+// SupportsShadowTraffic 影子请求需在后台完整重放，仅支持可独立转换的文本类请求
+func SupportsShadowTraffic(relayFormat types.RelayFormat) bool {
+	switch relayFormat {
+	case types.RelayFormatOpenAI, types.RelayFormatOpenAIResponses, types.RelayFormatClaude,
+		types.RelayFormatGemini, types.RelayFormatEmbedding, types.RelayFormatRerank:
+		return true
+	}
+	return false
+}
+
+// PickShadowChannels 按影子比例采样本次请求需要镜像到的影子渠道
+func PickShadowChannels(c *gin.Context, relayFormat types.RelayFormat, group string, modelName string) {
+	if !SupportsShadowTraffic(relayFormat) {
+		return
+	}
+	var picked []*model.Channel
+	for _, shadow := range model.GetTrafficChannels(group, modelName, dto.ChannelTrafficModeShadow) {
+		_, percent := shadow.GetTrafficMode()
+		if trafficSampled(percent) {
+			picked = append(picked, shadow)
+		}
+	}
+	if len(picked) > 0 {
+		common.SetContextKey(c, constant.ContextKeyShadowChannels, picked)
+	}
+}
+
+func GetShadowChannels(c *gin.Context) []*model.Channel {
+	channels, _ := common.GetContextKeyType[[]*model.Channel](c, constant.ContextKeyShadowChannels)
+	return channels
+}
+
+// RecordChannelTrafficSample 异步写入采样记录，避免阻塞请求
+func RecordChannelTrafficSample(sample *model.ChannelTrafficSample) {
+	gopool.Go(func() {
+		model.RecordChannelTrafficSample(sample)
+	})
+}
+
+// BuildChannelTrafficReport 按候选/对照角色汇总采样记录
+func BuildChannelTrafficReport(channel *model.Channel, samples []*model.ChannelTrafficSample) ChannelTrafficReport {
+	mode, percent := channel.GetTrafficMode()
+	report := ChannelTrafficReport{
+		ChannelId: channel.Id,
+		Mode:      mode,
+		Percent:   percent,
+	}
+	var candidates, baselines []*model.ChannelTrafficSample
+	for _, sample := range samples {
+		if sample.Role == model.ChannelTrafficRoleBaseline {
+			baselines = append(baselines, sample)
+		} else {
+			candidates = append(candidates, sample)
+		}
+	}
+	report.Candidate = buildChannelTrafficStats(candidates)
+	report.Baseline = buildChannelTrafficStats(baselines)
+	if report.Candidate.Requests > 0 && report.Baseline.Requests > 0 {
+		report.SuccessRateDelta = report.Candidate.SuccessRate - report.Baseline.SuccessRate
+		report.LatencyDeltaMs = report.Candidate.AvgLatencyMs - report.Baseline.AvgLatencyMs
+	}
+	report.Recent = candidates
+	if len(report.Recent) > 20 {
+		report.Recent = report.Recent[:20]
+	}
+	return report
+}
+
+func buildChannelTrafficStats(samples []*model.ChannelTrafficSample) ChannelTrafficStats {
+	stats := ChannelTrafficStats{
+		Requests:  len(samples),
+		TopErrors: []ChannelTrafficError{},
+	}
+	if len(samples) == 0 {
+		return stats
+	}
+	latencies := make([]int64, 0, len(samples))
+	var totalLatency int64
+	var promptTokens, completionTokens int
+	errorCounts := map[string]int{}
+	for _, sample := range samples {
+		if !sample.Success {
+			errorCounts[sample.ErrorMessage]++
+			continue
+		}
+		latencies = append(latencies, sample.LatencyMs)
+		totalLatency += sample.LatencyMs
+		promptTokens += sample.PromptTokens
+		completionTokens += sample.CompletionTokens
+	}
+	stats.Success = len(latencies)
+	stats.SuccessRate = float64(stats.Success) / float64(stats.Requests)
+	if stats.Success > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		stats.AvgLatencyMs = float64(totalLatency) / float64(stats.Success)
+		stats.P50LatencyMs = latencyPercentile(latencies, 0.5)
+		stats.P95LatencyMs = latencyPercentile(latencies, 0.95)
+		stats.AvgPromptTokens = float64(promptTokens) / float64(stats.Success)
+		stats.AvgCompletionTokens = float64(completionTokens) / float64(stats.Success)
+	}
+	for message, count := range errorCounts {
+		stats.TopErrors = append(stats.TopErrors, ChannelTrafficError{Message: message, Count: count})
+	}
+	sort.Slice(stats.TopErrors, func(i, j int) bool {
+		if stats.TopErrors[i].Count != stats.TopErrors[j].Count {
+			return stats.TopErrors[i].Count > stats.TopErrors[j].Count
+		}
+		return stats.TopErrors[i].Message < stats.TopErrors[j].Message
+	})
+	if len(stats.TopErrors) > channelTrafficTopErrors {
+		stats.TopErrors = stats.TopErrors[:channelTrafficTopErrors]
+	}
+	return stats
+}
+
+// latencyPercentile 使用最近秩法计算分位数，sorted 需已升序排列
+func latencyPercentile(sorted []int64, p float64) int64 {
+	idx := int(float64(len(sorted))*p+0.999999) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
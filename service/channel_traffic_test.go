@@ -0,0 +1,79 @@
+package service
+
+import (
+	"math"
+	"testing"
+
+	"github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/model"
+	"github.com/zhongruan0522/new-api/types"
+)
+
+func TestBuildChannelTrafficReport(t *testing.T) {
+	channel := &model.Channel{Id: 7, OtherSettings: `{"traffic_mode":"canary","traffic_percent":5}`}
+	samples := []*model.ChannelTrafficSample{
+		{Role: model.ChannelTrafficRoleCandidate, Success: true, LatencyMs: 100, PromptTokens: 10, CompletionTokens: 20},
+		{Role: model.ChannelTrafficRoleCandidate, Success: true, LatencyMs: 300, PromptTokens: 30, CompletionTokens: 40},
+		{Role: model.ChannelTrafficRoleCandidate, Success: false, ErrorMessage: "timeout"},
+		{Role: model.ChannelTrafficRoleCandidate, Success: false, ErrorMessage: "timeout"},
+		{Role: model.ChannelTrafficRoleBaseline, Success: true, LatencyMs: 150},
+		{Role: model.ChannelTrafficRoleBaseline, Success: true, LatencyMs: 250},
+	}
+
+	report := BuildChannelTrafficReport(channel, samples)
+	if report.Mode != dto.ChannelTrafficModeCanary || report.Percent != 5 {
+		t.Fatalf("mode/percent = %q/%v", report.Mode, report.Percent)
+	}
+	if report.Candidate.Requests != 4 || report.Candidate.SuccessRate != 0.5 {
+		t.Fatalf("candidate = %+v", report.Candidate)
+	}
+	if report.Candidate.AvgLatencyMs != 200 || report.Candidate.P50LatencyMs != 100 || report.Candidate.P95LatencyMs != 300 {
+		t.Fatalf("candidate latency = %+v", report.Candidate)
+	}
+	if report.Candidate.AvgPromptTokens != 20 || report.Candidate.AvgCompletionTokens != 30 {
+		t.Fatalf("candidate tokens = %+v", report.Candidate)
+	}
+	if len(report.Candidate.TopErrors) != 1 || report.Candidate.TopErrors[0].Count != 2 {
+		t.Fatalf("candidate errors = %+v", report.Candidate.TopErrors)
+	}
+	if math.Abs(report.SuccessRateDelta+0.5) > 1e-9 || report.LatencyDeltaMs != 0 {
+		t.Fatalf("deltas = %v/%v", report.SuccessRateDelta, report.LatencyDeltaMs)
+	}
+}
+
+func TestBuildChannelTrafficReportWithoutSamples(t *testing.T) {
+	report := BuildChannelTrafficReport(&model.Channel{Id: 1}, nil)
+	if report.Mode != "" || report.Candidate.Requests != 0 || report.Baseline.SuccessRate != 0 {
+		t.Fatalf("report = %+v", report)
+	}
+}
+
+func TestEligibleCanaryChannelsFollowsExcludeAndRequestFormat(t *testing.T) {
+	canarySettings := `{"traffic_mode":"canary","traffic_percent":5}`
+	openaiCanary := &model.Channel{Id: 11, Type: constant.ChannelTypeOpenAI, OtherSettings: canarySettings}
+	claudeCanary := &model.Channel{Id: 12, Type: constant.ChannelTypeAnthropic, OtherSettings: canarySettings}
+	failedCanary := &model.Channel{Id: 13, Type: constant.ChannelTypeAnthropic, OtherSettings: canarySettings}
+	canaries := func() []*model.Channel {
+		return []*model.Channel{openaiCanary, claudeCanary, failedCanary}
+	}
+
+	param := &RetryParam{RelayFormat: types.RelayFormatClaude, ExcludeChannelId: failedCanary.Id}
+	claudeSelected := &model.Channel{Id: 1, Type: constant.ChannelTypeAnthropic}
+	got := eligibleCanaryChannels(canaries(), param, claudeSelected)
+	if len(got) != 1 || got[0] != claudeCanary {
+		t.Fatalf("eligible canaries = %+v, want only channel 12", got)
+	}
+
+	// 正式渠道同样不匹配请求格式时不额外限制灰度渠道
+	param = &RetryParam{RelayFormat: types.RelayFormatGemini}
+	openaiSelected := &model.Channel{Id: 2, Type: constant.ChannelTypeOpenAI}
+	if got := eligibleCanaryChannels(canaries(), param, openaiSelected); len(got) != 3 {
+		t.Fatalf("eligible canaries = %+v, want all 3", got)
+	}
+
+	param = &RetryParam{RelayFormat: types.RelayFormatClaude, ExcludeChannelId: openaiCanary.Id}
+	if got := eligibleCanaryChannels([]*model.Channel{openaiCanary}, param, claudeSelected); len(got) != 0 {
+		t.Fatalf("excluded canary should not be eligible: %+v", got)
+	}
+}
@@ -3,29 +3,20 @@ package service
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/zhongruan0522/new-api/common"
 	"github.com/zhongruan0522/new-api/constant"
 	"github.com/zhongruan0522/new-api/dto"
 	"github.com/zhongruan0522/new-api/relay/channel/openrouter"
 	relaycommon "github.com/zhongruan0522/new-api/relay/common"
+	"github.com/zhongruan0522/new-api/relay/reasoning"
 	"github.com/zhongruan0522/new-api/relay/reasonmap"
 )
 
-func claudeThinkingBudgetToReasoningEffort(budget int) string {
-	switch {
-	case budget <= 0:
-		return ""
-	case budget <= 1280:
-		return "low"
-	case budget <= 2048:
-		return "medium"
-	default:
-		return "high"
-	}
-}
-
 func claudeWebSearchMaxUsesToContextSize(maxUses int) string {
 	switch {
 	case maxUses <= 0:
@@ -48,10 +39,7 @@ func extractClaudeOutputConfigEffort(outputConfig json.RawMessage) string {
 	if err := common.Unmarshal(outputConfig, &config); err != nil {
 		return ""
 	}
-	if config.Effort == "max" {
-		return "xhigh"
-	}
-	return config.Effort
+	return reasoning.NormalizeEffort(config.Effort)
 }
 
 func extractClaudeReasoningEffort(claudeRequest dto.ClaudeRequest) string {
@@ -62,37 +50,9 @@ func extractClaudeReasoningEffort(claudeRequest dto.ClaudeRequest) string {
 		return ""
 	}
 	if claudeRequest.Thinking.Type == "adaptive" {
-		return "high"
-	}
-	return claudeThinkingBudgetToReasoningEffort(claudeRequest.Thinking.GetBudgetTokens())
-}
-
-func geminiThinkingBudgetToReasoningEffort(budget int) string {
-	switch {
-	case budget <= 0:
-		return ""
-	case budget <= 1024:
-		return "low"
-	case budget <= 8192:
-		return "medium"
-	default:
-		return "high"
-	}
-}
-
-func geminiThinkingLevelToReasoningEffort(level string) string {
-	switch strings.ToLower(strings.TrimSpace(level)) {
-	case "", "none":
-		return ""
-	case "minimal", "low":
-		return "low"
-	case "medium":
-		return "medium"
-	case "high", "xhigh", "max":
-		return "high"
-	default:
-		return strings.ToLower(strings.TrimSpace(level))
+		return reasoning.EffortHigh
 	}
+	return reasoning.ClaudeBudgetToEffort(claudeRequest.Thinking.GetBudgetTokens())
 }
 
 func buildOpenAIReasoningPayload(maxTokens int) (json.RawMessage, error) {
@@ -399,7 +359,8 @@ func ClaudeToOpenAIRequest(claudeRequest dto.ClaudeRequest, info *relaycommon.Re
 				openAIMessage.SetToolCalls(toolCalls)
 			}
 
-			if len(mediaMessages) > 0 && len(toolCalls) == 0 {
+			// assistant 消息可以同时带文本和工具调用，两者都要保留
+			if len(mediaMessages) > 0 {
 				openAIMessage.SetMediaContent(mediaMessages)
 			}
 		}
@@ -413,6 +374,20 @@ func ClaudeToOpenAIRequest(claudeRequest dto.ClaudeRequest, info *relaycommon.Re
 	return &openAIRequest, nil
 }
 
+// geminiFunctionResponseContent 还原 OpenAI 转 Gemini 时对工具结果的包装：
+// 纯文本包装为 {"content": ...}，数组包装为 {"result": [...]}，其余对象原样序列化
+func geminiFunctionResponseContent(response map[string]interface{}) string {
+	if len(response) == 1 {
+		if text, ok := response["content"].(string); ok {
+			return text
+		}
+		if result, ok := response["result"].([]interface{}); ok {
+			return toJSONString(result)
+		}
+	}
+	return toJSONString(response)
+}
+
 func generateStopBlock(index int) *dto.ClaudeResponse {
 	return &dto.ClaudeResponse{
 		Type:  "content_block_stop",
@@ -966,12 +941,12 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 		Stream: info.IsStream,
 	}
 	if geminiRequest.GenerationConfig.ThinkingConfig != nil && geminiRequest.GenerationConfig.ThinkingConfig.IncludeThoughts {
-		if effort := geminiThinkingLevelToReasoningEffort(geminiRequest.GenerationConfig.ThinkingConfig.ThinkingLevel); effort != "" {
+		if effort := reasoning.GeminiThinkingLevelToEffort(geminiRequest.GenerationConfig.ThinkingConfig.ThinkingLevel); effort != "" {
 			openaiRequest.ReasoningEffort = effort
 		}
 		if geminiRequest.GenerationConfig.ThinkingConfig.ThinkingBudget != nil {
 			if openaiRequest.ReasoningEffort == "" {
-				openaiRequest.ReasoningEffort = geminiThinkingBudgetToReasoningEffort(*geminiRequest.GenerationConfig.ThinkingConfig.ThinkingBudget)
+				openaiRequest.ReasoningEffort = reasoning.GeminiBudgetToEffort(*geminiRequest.GenerationConfig.ThinkingConfig.ThinkingBudget)
 			}
 			reasoningPayload, err := buildOpenAIReasoningPayload(*geminiRequest.GenerationConfig.ThinkingConfig.ThinkingBudget)
 			if err != nil {
@@ -1046,21 +1021,21 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 				toolCallIDsByName[part.FunctionCall.FunctionName] = append(toolCallIDsByName[part.FunctionCall.FunctionName], toolCallID)
 				toolCalls = append(toolCalls, toolCall)
 			} else if part.FunctionResponse != nil {
-				// 处理 Gemini 的工具响应，创建单独的 tool 消息
+				// 处理 Gemini 的工具响应，创建单独的 tool 消息。
+				// functionCall 不带 id，上面为其生成了 call_N，因此优先按名称依次匹配本请求中的调用，
+				// 历史中没有对应调用时才使用 functionResponse 自带的 id
 				toolCallID := part.FunctionResponse.GetID()
-				if toolCallID == "" {
-					queuedIDs := toolCallIDsByName[part.FunctionResponse.Name]
-					if len(queuedIDs) == 0 {
-						return nil, fmt.Errorf("functionResponse for %s is missing a matching tool call id", part.FunctionResponse.Name)
-					}
+				if queuedIDs := toolCallIDsByName[part.FunctionResponse.Name]; len(queuedIDs) > 0 {
 					toolCallID = queuedIDs[0]
 					toolCallIDsByName[part.FunctionResponse.Name] = queuedIDs[1:]
+				} else if toolCallID == "" {
+					return nil, fmt.Errorf("functionResponse for %s is missing a matching tool call id", part.FunctionResponse.Name)
 				}
 				toolMessage := dto.Message{
 					Role:       "tool",
 					ToolCallId: toolCallID,
 				}
-				toolMessage.SetStringContent(toJSONString(part.FunctionResponse.Response))
+				toolMessage.SetStringContent(geminiFunctionResponseContent(part.FunctionResponse.Response))
 				messages = append(messages, toolMessage)
 			}
 		}
@@ -1071,11 +1046,11 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 			message.ReasoningSignature = reasoningSignature
 		}
 
-		// 设置消息内容
+		// 设置消息内容，工具调用与文本可以同时存在
 		if len(toolCalls) > 0 {
-			// 如果有工具调用，设置工具调用
 			message.SetToolCalls(toolCalls)
-		} else if len(mediaContents) == 1 && mediaContents[0].Type == "text" {
+		}
+		if len(mediaContents) == 1 && mediaContents[0].Type == "text" {
 			// 如果只有一个文本内容，直接设置字符串
 			message.Content = mediaContents[0].Text
 		} else if len(mediaContents) > 0 {
@@ -1225,6 +1200,7 @@ func ResponseOpenAI2Gemini(openAIResponse *dto.OpenAITextResponse, info *relayco
 		}
 
 		if textContent := choice.Message.StringContent(); textContent != "" {
+			candidate.GroundingMetadata = GeminiGroundingFromAnnotations(textContent, len(content.Parts), choice.Message.Annotations)
 			content.Parts = append(content.Parts, dto.GeminiPart{Text: textContent})
 		}
 
@@ -1244,6 +1220,95 @@ func ResponseOpenAI2Gemini(openAIResponse *dto.OpenAITextResponse, info *relayco
 	return geminiResponse
 }
 
+// GeminiGroundingFromAnnotations 将 chat 回复的 url_citation 转为 Gemini 的 groundingMetadata，
+// text 为引用所在 part 的文本，字符下标换算为 Gemini 使用的字节下标
+func GeminiGroundingFromAnnotations(text string, partIndex int, annotations []dto.ChatAnnotation) *dto.GeminiGroundingMetadata {
+	metadata := &dto.GeminiGroundingMetadata{}
+	chunkIndices := make(map[string]int)
+	for _, annotation := range annotations {
+		citation := annotation.URLCitation
+		if annotation.Type != dto.AnnotationTypeURLCitation || citation == nil || citation.URL == "" {
+			continue
+		}
+		chunkIndex, ok := chunkIndices[citation.URL]
+		if !ok {
+			chunkIndex = len(metadata.GroundingChunks)
+			chunkIndices[citation.URL] = chunkIndex
+			metadata.GroundingChunks = append(metadata.GroundingChunks, dto.GeminiGroundingChunk{
+				Web: &dto.GeminiGroundingWeb{URI: citation.URL, Title: citation.Title},
+			})
+		}
+		start := runeIndexToByteIndex(text, citation.StartIndex)
+		end := max(runeIndexToByteIndex(text, citation.EndIndex), start)
+		metadata.GroundingSupports = append(metadata.GroundingSupports, dto.GeminiGroundingSupport{
+			Segment: dto.GeminiGroundingSegment{
+				PartIndex:  partIndex,
+				StartIndex: start,
+				EndIndex:   end,
+				Text:       text[start:end],
+			},
+			GroundingChunkIndices: []int{chunkIndex},
+		})
+	}
+	if len(metadata.GroundingChunks) == 0 {
+		return nil
+	}
+	return metadata
+}
+
+// ChatAnnotationsFromGeminiGrounding 将 Gemini 的 groundingMetadata 转为 chat 回复的 url_citation。
+// partOffsets 为各文本 part 在合并后回复中的字符起点，未出现在其中的 part 不会转换
+func ChatAnnotationsFromGeminiGrounding(metadata *dto.GeminiGroundingMetadata, parts []dto.GeminiPart, partOffsets map[int]int) []dto.ChatAnnotation {
+	if metadata == nil {
+		return nil
+	}
+	var annotations []dto.ChatAnnotation
+	for _, support := range metadata.GroundingSupports {
+		partIndex := support.Segment.PartIndex
+		offset, ok := partOffsets[partIndex]
+		if !ok || partIndex >= len(parts) {
+			continue
+		}
+		text := parts[partIndex].Text
+		start := offset + byteIndexToRuneIndex(text, support.Segment.StartIndex)
+		end := offset + byteIndexToRuneIndex(text, support.Segment.EndIndex)
+		for _, chunkIndex := range support.GroundingChunkIndices {
+			if chunkIndex < 0 || chunkIndex >= len(metadata.GroundingChunks) || metadata.GroundingChunks[chunkIndex].Web == nil {
+				continue
+			}
+			web := metadata.GroundingChunks[chunkIndex].Web
+			annotations = append(annotations, dto.ChatAnnotation{
+				Type: dto.AnnotationTypeURLCitation,
+				URLCitation: &dto.ChatURLCitation{
+					StartIndex: start,
+					EndIndex:   end,
+					URL:        web.URI,
+					Title:      web.Title,
+				},
+			})
+		}
+	}
+	return annotations
+}
+
+func runeIndexToByteIndex(s string, runeIndex int) int {
+	if runeIndex <= 0 {
+		return 0
+	}
+	count := 0
+	for i := range s {
+		if count == runeIndex {
+			return i
+		}
+		count++
+	}
+	return len(s)
+}
+
+func byteIndexToRuneIndex(s string, byteIndex int) int {
+	return utf8.RuneCountInString(s[:min(max(byteIndex, 0), len(s))])
+}
+
 func ensureGeminiConvertInfo(info *relaycommon.RelayInfo) *relaycommon.GeminiConvertInfo {
 	if info == nil {
 		return &relaycommon.GeminiConvertInfo{
@@ -1346,6 +1411,19 @@ func StreamResponseOpenAI2Gemini(openAIResponse *dto.ChatCompletionsStreamRespon
 		argsState := ensureGeminiChoiceToolCallState(toolState.ToolCallArguments, choice.Index)
 		nameState := ensureGeminiChoiceToolCallState(toolState.ToolCallNames, choice.Index)
 		idState := ensureGeminiChoiceToolCallState(toolState.ToolCallIDs, choice.Index)
+		flushToolCall := func(toolIndex int) {
+			functionName := nameState[toolIndex]
+			if functionName == "" {
+				return
+			}
+			content.Parts = append(content.Parts, dto.GeminiPart{
+				FunctionCall: &dto.FunctionCall{
+					FunctionName: functionName,
+					Arguments:    parseOpenAIFunctionArguments(strings.TrimSpace(argsState[toolIndex])),
+				},
+			})
+			delete(argsState, toolIndex)
+		}
 		for toolOffset, toolCall := range choice.Delta.ToolCalls {
 			toolIndex := toolOffset
 			if toolCall.Index != nil {
@@ -1357,34 +1435,20 @@ func StreamResponseOpenAI2Gemini(openAIResponse *dto.ChatCompletionsStreamRespon
 			if toolCall.ID != "" {
 				idState[toolIndex] = toolCall.ID
 			}
-			if toolCall.Function.Arguments != "" {
-				argsState[toolIndex] += toolCall.Function.Arguments
-			}
+			// 即使参数为空也登记为待发送，OpenAI 流的首个增量通常只带名称
+			argsState[toolIndex] += toolCall.Function.Arguments
 
-			aggregatedArgs := strings.TrimSpace(argsState[toolIndex])
-			shouldFlush := aggregatedArgs == "" || json.Valid([]byte(aggregatedArgs))
-			if choice.FinishReason != nil && *choice.FinishReason == "tool_calls" {
-				shouldFlush = true
-			}
-			if !shouldFlush {
-				continue
+			// 参数拼成完整 JSON 后即可作为一个 functionCall 发出，空参数等到结束时再发
+			if aggregatedArgs := strings.TrimSpace(argsState[toolIndex]); aggregatedArgs != "" && json.Valid([]byte(aggregatedArgs)) {
+				flushToolCall(toolIndex)
 			}
-
-			functionName := nameState[toolIndex]
-			if functionName == "" {
-				functionName = toolCall.Function.Name
-			}
-			if functionName == "" {
-				continue
+		}
+		// 结束时补发尚未发出的工具调用（无参数或参数不完整）
+		if choice.FinishReason != nil && len(argsState) > 0 {
+			pending := slices.Sorted(maps.Keys(argsState))
+			for _, toolIndex := range pending {
+				flushToolCall(toolIndex)
 			}
-
-			content.Parts = append(content.Parts, dto.GeminiPart{
-				FunctionCall: &dto.FunctionCall{
-					FunctionName: functionName,
-					Arguments:    parseOpenAIFunctionArguments(aggregatedArgs),
-				},
-			})
-			delete(argsState, toolIndex)
 		}
 
 		if len(content.Parts) == 0 && candidate.FinishReason == nil {
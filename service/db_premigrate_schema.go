@@ -40,6 +40,14 @@ func autoMigrateTargetMainSchema(db *gorm.DB) error {
 		&model.TwoFA{},
 		&model.TwoFABackupCode{},
 		&model.Checkin{},
+		&model.ReferralCommission{},
+		&model.Coupon{},
+		&model.ChannelBalanceHistory{},
+		&model.GatewayTool{},
+		&model.StoredResponse{},
+		&model.ChannelTrafficSample{},
+		&model.CacheUsageData{},
+		&model.Task{},
 	); err != nil {
 		return err
 	}
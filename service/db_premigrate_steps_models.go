@@ -21,6 +21,14 @@ var dbPreMigrateMainSteps = []dbPreMigrateStep{
 	gormTableCopyStep[model.TwoFA]{name: "two_fas", batchSize: dbPreMigrateBatchDefault},
 	gormTableCopyStep[model.TwoFABackupCode]{name: "two_fa_backup_codes", batchSize: dbPreMigrateBatchDefault},
 	gormTableCopyStep[model.Checkin]{name: "checkins", batchSize: dbPreMigrateBatchDefault},
+	gormTableCopyStep[model.ReferralCommission]{name: "referral_commissions", batchSize: dbPreMigrateBatchDefault},
+	gormTableCopyStep[model.Coupon]{name: "coupons", batchSize: dbPreMigrateBatchDefault},
+	gormTableCopyStep[model.ChannelBalanceHistory]{name: "channel_balance_histories", batchSize: dbPreMigrateBatchDefault},
+	gormTableCopyStep[model.GatewayTool]{name: "gateway_tools", batchSize: dbPreMigrateBatchDefault},
+	gormTableCopyStep[model.StoredResponse]{name: "stored_responses", batchSize: dbPreMigrateBatchBlob},
+	gormTableCopyStep[model.ChannelTrafficSample]{name: "channel_traffic_samples", batchSize: dbPreMigrateBatchDefault},
+	gormTableCopyStep[model.CacheUsageData]{name: "cache_usage_data", batchSize: dbPreMigrateBatchDefault},
+	gormTableCopyStep[model.Task]{name: "tasks", batchSize: dbPreMigrateBatchDefault},
 }
 
 var dbPreMigrateLogStep = gormTableCopyStep[model.Log]{name: "logs", batchSize: dbPreMigrateBatchLog}
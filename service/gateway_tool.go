@@ -0,0 +1,261 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/model"
+)
+
+// 工具输出最大长度，超出部分截断后再交给模型
+const gatewayToolMaxOutputBytes = 64 * 1024
+
+// GatewayToolDefinition 将网关工具转换为 OpenAI function 工具定义
+func GatewayToolDefinition(tool *model.GatewayTool) dto.ToolCallRequest {
+	return dto.ToolCallRequest{
+		Type: "function",
+		Function: dto.FunctionRequest{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Parameters:  tool.GetParameters(),
+		},
+	}
+}
+
+// ExecuteGatewayTool 在服务端执行一次工具调用，返回交给模型的文本输出
+func ExecuteGatewayTool(ctx context.Context, tool *model.GatewayTool, arguments string) (string, error) {
+	arguments = strings.TrimSpace(arguments)
+	if arguments == "" {
+		arguments = "{}"
+	}
+	var args map[string]any
+	if err := common.UnmarshalJsonStr(arguments, &args); err != nil {
+		return "", fmt.Errorf("工具参数不是合法的 JSON 对象：%w", err)
+	}
+
+	timeout := time.Duration(tool.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var (
+		output string
+		err    error
+	)
+	switch tool.Type {
+	case model.GatewayToolTypeMCP:
+		output, err = callRemoteMcpTool(ctx, tool, args)
+	default:
+		output, err = callHttpGatewayTool(ctx, tool, []byte(arguments))
+	}
+	if err != nil {
+		return "", err
+	}
+	return truncateGatewayToolOutput(output), nil
+}
+
+func truncateGatewayToolOutput(output string) string {
+	if len(output) <= gatewayToolMaxOutputBytes {
+		return output
+	}
+	// 回退到字符边界，避免截断多字节字符产生非法 UTF-8
+	end := gatewayToolMaxOutputBytes
+	for end > 0 && !utf8.RuneStart(output[end]) {
+		end--
+	}
+	return output[:end] + "\n...[truncated]"
+}
+
+func newGatewayToolRequest(ctx context.Context, tool *model.GatewayTool, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tool.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range tool.GetHeaders() {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+// callHttpGatewayTool POST 参数 JSON 到工具地址，2xx 响应体即工具输出
+func callHttpGatewayTool(ctx context.Context, tool *model.GatewayTool, body []byte) (string, error) {
+	req, err := newGatewayToolRequest(ctx, tool, body)
+	if err != nil {
+		return "", err
+	}
+	resp, err := GetHttpClient().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("调用工具失败：%w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, gatewayToolMaxOutputBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("读取工具响应失败：%w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("工具返回状态码 %d：%s", resp.StatusCode, truncateGatewayToolOutput(string(respBody)))
+	}
+	return string(respBody), nil
+}
+
+// callRemoteMcpTool 以最简 MCP 客户端流程调用远程工具：initialize → notifications/initialized → tools/call
+func callRemoteMcpTool(ctx context.Context, tool *model.GatewayTool, args map[string]any) (string, error) {
+	initResp, sessionId, err := postMcpMessage(ctx, tool, "", dto.JsonRpcRequest{
+		JsonRpc: dto.JsonRpcVersion,
+		Id:      []byte("1"),
+		Method:  "initialize",
+		Params: mustMarshalMcpParams(dto.McpInitializeParams{
+			ProtocolVersion: "2025-06-18",
+			Capabilities:    map[string]any{},
+			ClientInfo:      &dto.McpServerInfo{Name: "new-api", Version: common.Version},
+		}),
+	})
+	if err != nil {
+		return "", err
+	}
+	if initResp != nil && initResp.Error != nil {
+		return "", fmt.Errorf("MCP 初始化失败：%s", initResp.Error.Message)
+	}
+	_, _, _ = postMcpMessage(ctx, tool, sessionId, dto.JsonRpcRequest{
+		JsonRpc: dto.JsonRpcVersion,
+		Method:  "notifications/initialized",
+	})
+
+	callResp, _, err := postMcpMessage(ctx, tool, sessionId, dto.JsonRpcRequest{
+		JsonRpc: dto.JsonRpcVersion,
+		Id:      []byte("2"),
+		Method:  "tools/call",
+		Params: mustMarshalMcpParams(dto.McpCallToolParams{
+			Name:      tool.GetRemoteName(),
+			Arguments: args,
+		}),
+	})
+	if err != nil {
+		return "", err
+	}
+	if callResp == nil {
+		return "", errors.New("MCP 服务未返回结果")
+	}
+	if callResp.Error != nil {
+		return "", fmt.Errorf("MCP 工具调用失败：%s", callResp.Error.Message)
+	}
+
+	resultBytes, err := common.Marshal(callResp.Result)
+	if err != nil {
+		return "", err
+	}
+	var result dto.McpCallToolResult
+	if err := common.Unmarshal(resultBytes, &result); err != nil {
+		return "", fmt.Errorf("解析 MCP 工具结果失败：%w", err)
+	}
+	var sb strings.Builder
+	for _, content := range result.Content {
+		if content.Type != "text" {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(content.Text)
+	}
+	if result.IsError {
+		return "", fmt.Errorf("MCP 工具返回错误：%s", sb.String())
+	}
+	return sb.String(), nil
+}
+
+func mustMarshalMcpParams(params any) []byte {
+	data, _ := common.Marshal(params)
+	return data
+}
+
+// postMcpMessage 发送一条 JSON-RPC 消息，兼容 JSON 与 SSE 两种响应格式；通知类消息返回 nil 响应
+func postMcpMessage(ctx context.Context, tool *model.GatewayTool, sessionId string, message dto.JsonRpcRequest) (*dto.JsonRpcResponse, string, error) {
+	body, err := common.Marshal(message)
+	if err != nil {
+		return nil, "", err
+	}
+	req, err := newGatewayToolRequest(ctx, tool, body)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if sessionId != "" {
+		req.Header.Set("Mcp-Session-Id", sessionId)
+	}
+	resp, err := GetHttpClient().Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("连接 MCP 服务失败：%w", err)
+	}
+	defer resp.Body.Close()
+	if newSessionId := resp.Header.Get("Mcp-Session-Id"); newSessionId != "" {
+		sessionId = newSessionId
+	}
+	if resp.StatusCode == http.StatusAccepted || message.IsNotification() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, sessionId, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, sessionId, fmt.Errorf("MCP 服务返回状态码 %d：%s", resp.StatusCode, string(respBody))
+	}
+
+	var payload []byte
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		payload, err = readMcpSseResponse(resp.Body, message.Id)
+	} else {
+		payload, err = io.ReadAll(io.LimitReader(resp.Body, 4*gatewayToolMaxOutputBytes))
+	}
+	if err != nil {
+		return nil, sessionId, err
+	}
+	var rpcResp dto.JsonRpcResponse
+	if err := common.Unmarshal(payload, &rpcResp); err != nil {
+		return nil, sessionId, fmt.Errorf("解析 MCP 响应失败：%w", err)
+	}
+	return &rpcResp, sessionId, nil
+}
+
+// readMcpSseResponse 从 SSE 流中找到与请求 id 对应的 JSON-RPC 响应
+func readMcpSseResponse(r io.Reader, id []byte) ([]byte, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*gatewayToolMaxOutputBytes)
+	for scanner.Scan() {
+		line := scanner.Text()
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		var probe struct {
+			Id     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := common.UnmarshalJsonStr(data, &probe); err != nil {
+			continue
+		}
+		// 跳过服务端在同一流中推送的通知、请求以及其它请求的响应
+		if probe.Method != "" || !bytes.Equal(bytes.TrimSpace(probe.Id), bytes.TrimSpace(id)) {
+			continue
+		}
+		return []byte(data), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("MCP 响应流中未找到 id 为 %s 的结果", string(id))
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/model"
+)
+
+func setupGatewayToolHttpClient(t *testing.T) {
+	t.Helper()
+	old := httpClient
+	httpClient = &http.Client{}
+	t.Cleanup(func() {
+		httpClient = old
+	})
+}
+
+func TestExecuteGatewayToolHttp(t *testing.T) {
+	setupGatewayToolHttpClient(t)
+	var gotBody, gotKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		gotKey = r.Header.Get("X-Api-Key")
+		_, _ = w.Write([]byte(`{"temp":21}`))
+	}))
+	defer server.Close()
+
+	tool := &model.GatewayTool{
+		Name:     "weather",
+		Type:     model.GatewayToolTypeHTTP,
+		Endpoint: server.URL,
+		Headers:  `{"X-Api-Key":"secret"}`,
+	}
+	output, err := ExecuteGatewayTool(context.Background(), tool, `{"city":"Paris"}`)
+	if err != nil {
+		t.Fatalf("ExecuteGatewayTool() error = %v", err)
+	}
+	if output != `{"temp":21}` {
+		t.Errorf("output = %q", output)
+	}
+	if gotBody != `{"city":"Paris"}` || gotKey != "secret" {
+		t.Errorf("upstream got body %q key %q", gotBody, gotKey)
+	}
+
+	if _, err := ExecuteGatewayTool(context.Background(), tool, `not json`); err == nil {
+		t.Error("ExecuteGatewayTool(invalid arguments) should return error")
+	}
+}
+
+func TestExecuteGatewayToolMcp(t *testing.T) {
+	setupGatewayToolHttpClient(t)
+	var calledName, calledSession string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req dto.JsonRpcRequest
+		body, _ := io.ReadAll(r.Body)
+		_ = common.Unmarshal(body, &req)
+		switch req.Method {
+		case "initialize":
+			w.Header().Set("Mcp-Session-Id", "sess-1")
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"2025-06-18"}}`))
+		case "notifications/initialized":
+			w.WriteHeader(http.StatusAccepted)
+		case "tools/call":
+			var params dto.McpCallToolParams
+			_ = common.Unmarshal(req.Params, &params)
+			calledName = params.Name
+			calledSession = r.Header.Get("Mcp-Session-Id")
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n" +
+				"event: message\ndata: {\"jsonrpc\":\"2.0\",\"id\":99,\"result\":{\"content\":[{\"type\":\"text\",\"text\":\"stale\"}]}}\n\n" +
+				"event: message\ndata: {\"jsonrpc\":\"2.0\",\"id\":2,\"result\":{\"content\":[{\"type\":\"text\",\"text\":\"sunny\"}]}}\n\n"))
+		}
+	}))
+	defer server.Close()
+
+	tool := &model.GatewayTool{
+		Name:       "weather",
+		Type:       model.GatewayToolTypeMCP,
+		Endpoint:   server.URL,
+		RemoteName: "get_weather",
+	}
+	output, err := ExecuteGatewayTool(context.Background(), tool, `{"city":"Paris"}`)
+	if err != nil {
+		t.Fatalf("ExecuteGatewayTool() error = %v", err)
+	}
+	if output != "sunny" {
+		t.Errorf("output = %q, want sunny", output)
+	}
+	if calledName != "get_weather" || calledSession != "sess-1" {
+		t.Errorf("tools/call name %q session %q", calledName, calledSession)
+	}
+}
+
+func TestTruncateGatewayToolOutput(t *testing.T) {
+	long := strings.Repeat("a", gatewayToolMaxOutputBytes+10)
+	if got := truncateGatewayToolOutput(long); !strings.HasSuffix(got, "[truncated]") {
+		t.Error("long output should be truncated")
+	}
+	// 截断位置落在多字节字符中间时回退到字符边界
+	multibyte := strings.Repeat("a", gatewayToolMaxOutputBytes-1) + strings.Repeat("晴", 4)
+	if got := truncateGatewayToolOutput(multibyte); !utf8.ValidString(got) {
+		t.Error("truncated output should be valid UTF-8")
+	}
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+// ImageEditInput 是图片编辑 / 变体请求中的一张输入图片
+type ImageEditInput struct {
+	MimeType string
+	// Base64 不带 data: 前缀
+	Base64 string
+}
+
+// DataURL 以 data URL 形式返回图片，供只接受 URL 字段的上游使用
+func (i *ImageEditInput) DataURL() string {
+	return fmt.Sprintf("data:%s;base64,%s", i.MimeType, i.Base64)
+}
+
+// Bytes 返回解码后的图片内容
+func (i *ImageEditInput) Bytes() ([]byte, error) {
+	return base64.StdEncoding.DecodeString(i.Base64)
+}
+
+// GetImageEditInputs 读取 /v1/images/edits 与 /v1/images/variations 的输入图片和蒙版，
+// 同时兼容 multipart 上传的文件以及 JSON 请求中的 URL / data URL / base64
+func GetImageEditInputs(c *gin.Context, request *dto.ImageRequest) ([]ImageEditInput, *ImageEditInput, error) {
+	if strings.Contains(c.Request.Header.Get("Content-Type"), "multipart/form-data") || c.Request.MultipartForm != nil {
+		return getMultipartImageEditInputs(c)
+	}
+
+	var refs []string
+	for _, raw := range []json.RawMessage{request.Image, request.Images} {
+		refs = append(refs, imageRefsFromJSON(gjson.ParseBytes(raw))...)
+	}
+	if len(refs) == 0 {
+		return nil, nil, errors.New("image is required")
+	}
+	images := make([]ImageEditInput, 0, len(refs))
+	for _, ref := range refs {
+		input, err := loadImageEditInput(c, ref)
+		if err != nil {
+			return nil, nil, err
+		}
+		images = append(images, *input)
+	}
+
+	var mask *ImageEditInput
+	if maskRefs := imageRefsFromJSON(gjson.ParseBytes(request.Mask)); len(maskRefs) > 0 {
+		input, err := loadImageEditInput(c, maskRefs[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		mask = input
+	}
+	return images, mask, nil
+}
+
+// imageRefsFromJSON 支持 "..."、{"image_url": "..."}、{"image_url": {"url": "..."}} 以及它们组成的数组
+func imageRefsFromJSON(value gjson.Result) []string {
+	switch {
+	case value.IsArray():
+		var refs []string
+		for _, item := range value.Array() {
+			refs = append(refs, imageRefsFromJSON(item)...)
+		}
+		return refs
+	case value.IsObject():
+		if url := value.Get("image_url.url"); url.Exists() {
+			return imageRefsFromJSON(url)
+		}
+		if url := value.Get("image_url"); url.Exists() {
+			return imageRefsFromJSON(url)
+		}
+		return imageRefsFromJSON(value.Get("url"))
+	case value.Type == gjson.String && strings.TrimSpace(value.String()) != "":
+		return []string{strings.TrimSpace(value.String())}
+	}
+	return nil
+}
+
+func loadImageEditInput(c *gin.Context, ref string) (*ImageEditInput, error) {
+	var source *types.FileSource
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") {
+		source = types.NewURLFileSource(ref)
+	} else {
+		mimeType, data, err := DecodeBase64FileData(ref)
+		if err != nil {
+			return nil, fmt.Errorf("decode image failed: %w", err)
+		}
+		source = types.NewBase64FileSource(data, mimeType)
+	}
+	data, mimeType, err := GetBase64Data(c, source, "image_edit")
+	if err != nil {
+		return nil, err
+	}
+	return &ImageEditInput{MimeType: mimeType, Base64: data}, nil
+}
+
+func getMultipartImageEditInputs(c *gin.Context) ([]ImageEditInput, *ImageEditInput, error) {
+	form := c.Request.MultipartForm
+	if form == nil {
+		var err error
+		if form, err = c.MultipartForm(); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse multipart form: %w", err)
+		}
+	}
+
+	// 与 OpenAI 一致，图片字段可以是 image、image[] 或 image[0] 等数组写法
+	var files []*multipart.FileHeader
+	files = append(files, form.File["image"]...)
+	files = append(files, form.File["image[]"]...)
+	var indexed []string
+	for name := range form.File {
+		if strings.HasPrefix(name, "image[") && name != "image[]" {
+			indexed = append(indexed, name)
+		}
+	}
+	sort.Strings(indexed)
+	for _, name := range indexed {
+		files = append(files, form.File[name]...)
+	}
+	if len(files) == 0 {
+		return nil, nil, errors.New("image is required")
+	}
+
+	images := make([]ImageEditInput, 0, len(files))
+	for _, header := range files {
+		input, err := readImageEditFile(header)
+		if err != nil {
+			return nil, nil, err
+		}
+		images = append(images, *input)
+	}
+
+	var mask *ImageEditInput
+	if headers := form.File["mask"]; len(headers) > 0 {
+		input, err := readImageEditFile(headers[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		mask = input
+	}
+	return images, mask, nil
+}
+
+func readImageEditFile(header *multipart.FileHeader) (*ImageEditInput, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, fmt.Errorf("open image file %s failed: %w", header.Filename, err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("read image file %s failed: %w", header.Filename, err)
+	}
+	mimeType := header.Header.Get("Content-Type")
+	if !strings.HasPrefix(mimeType, "image/") {
+		mimeType = http.DetectContentType(data)
+	}
+	return &ImageEditInput{MimeType: mimeType, Base64: base64.StdEncoding.EncodeToString(data)}, nil
+}
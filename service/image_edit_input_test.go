@@ -0,0 +1,96 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+
+	"github.com/zhongruan0522/new-api/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 1x1 透明 PNG
+var testImagePNG, _ = base64.StdEncoding.DecodeString("iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNkYPhfDwAChwGA60e6kgAAAABJRU5ErkJggg==")
+
+func newImageEditTestContext(body *bytes.Buffer, contentType string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/images/edits", body)
+	c.Request.Header.Set("Content-Type", contentType)
+	return c
+}
+
+func writeImageEditFile(t *testing.T, writer *multipart.Writer, field string, filename string, contentType string, data []byte) {
+	t.Helper()
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="`+field+`"; filename="`+filename+`"`)
+	header.Set("Content-Type", contentType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		t.Fatalf("create part: %v", err)
+	}
+	_, _ = part.Write(data)
+}
+
+func TestGetImageEditInputsFromMultipart(t *testing.T) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	_ = writer.WriteField("prompt", "add a hat")
+	writeImageEditFile(t, writer, "image[]", "a.png", "image/png", testImagePNG)
+	// 未声明图片类型时按内容识别
+	writeImageEditFile(t, writer, "image[]", "b.png", "application/octet-stream", testImagePNG)
+	writeImageEditFile(t, writer, "mask", "mask.png", "image/png", testImagePNG)
+	_ = writer.Close()
+
+	c := newImageEditTestContext(&body, writer.FormDataContentType())
+	images, mask, err := GetImageEditInputs(c, &dto.ImageRequest{})
+	if err != nil {
+		t.Fatalf("GetImageEditInputs returned error: %v", err)
+	}
+	if len(images) != 2 {
+		t.Fatalf("images = %d, want 2", len(images))
+	}
+	for i, image := range images {
+		data, _ := image.Bytes()
+		if image.MimeType != "image/png" || !bytes.Equal(data, testImagePNG) {
+			t.Fatalf("image %d = %s, %d bytes", i, image.MimeType, len(data))
+		}
+	}
+	if mask == nil || mask.MimeType != "image/png" {
+		t.Fatalf("mask = %+v", mask)
+	}
+}
+
+func TestGetImageEditInputsFromJSON(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString(testImagePNG)
+	request := &dto.ImageRequest{
+		Image:  []byte(`"data:image/png;base64,` + encoded + `"`),
+		Images: []byte(`[{"image_url":{"url":"data:image/png;base64,` + encoded + `"}},{"image_url":"data:image/png;base64,` + encoded + `"}]`),
+		Mask:   []byte(`{"image_url":"data:image/png;base64,` + encoded + `"}`),
+	}
+	c := newImageEditTestContext(bytes.NewBufferString(`{}`), "application/json")
+	images, mask, err := GetImageEditInputs(c, request)
+	if err != nil {
+		t.Fatalf("GetImageEditInputs returned error: %v", err)
+	}
+	if len(images) != 3 {
+		t.Fatalf("images = %d, want 3", len(images))
+	}
+	for i, image := range images {
+		if image.Base64 != encoded || image.DataURL() != "data:image/png;base64,"+encoded {
+			t.Fatalf("image %d = %+v", i, image)
+		}
+	}
+	if mask == nil || mask.Base64 != encoded {
+		t.Fatalf("mask = %+v", mask)
+	}
+
+	if _, _, err := GetImageEditInputs(c, &dto.ImageRequest{}); err == nil {
+		t.Fatal("expected error when no image is provided")
+	}
+}
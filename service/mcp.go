@@ -0,0 +1,519 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/setting/operation_setting"
+)
+
+const (
+	McpToolDescribeImage   = "describe_image"
+	McpToolTranscribeAudio = "transcribe_audio"
+	McpToolGenerateImage   = "generate_image"
+	McpToolEmbedText       = "embed_text"
+	McpToolListModels      = "list_models"
+
+	defaultMcpDescribePrompt = "请详细描述这张图片的内容。"
+)
+
+// mcpRelayHandler 为 gin 引擎本身，MCP 工具调用通过它在进程内重放为普通的 /v1 请求，
+// 从而完整经过令牌鉴权、限流、渠道分发、计费与日志流程
+var mcpRelayHandler http.Handler
+
+// 透传给内部请求的请求头：令牌与客户端 IP 相关头用于鉴权与令牌 IP 限制，
+// x-api-key 与 x-goog-api-key 为 Claude 与 Gemini 客户端的令牌头
+var mcpForwardHeaders = []string{
+	"Authorization",
+	"X-Api-Key",
+	"X-Goog-Api-Key",
+	"User-Agent",
+	"X-Forwarded-For",
+	"X-Real-IP",
+	"CF-Connecting-IP",
+	"True-Client-IP",
+}
+
+func SetMcpRelayHandler(handler http.Handler) {
+	mcpRelayHandler = handler
+}
+
+type mcpToolHandler func(origin *http.Request, args map[string]any) (*dto.McpCallToolResult, error)
+
+type mcpToolEntry struct {
+	tool    dto.McpTool
+	handler mcpToolHandler
+}
+
+var mcpToolEntries = []mcpToolEntry{
+	{
+		tool: dto.McpTool{
+			Name:        McpToolDescribeImage,
+			Description: "使用视觉模型描述或分析一张图片，支持 http(s) 图片链接或 data URI。",
+			InputSchema: mcpObjectSchema(map[string]any{
+				"image_url": mcpStringSchema("图片链接（http/https）或 data:image/...;base64,... 数据"),
+				"prompt":    mcpStringSchema("对图片提出的问题，默认要求详细描述图片内容"),
+				"model":     mcpStringSchema("使用的视觉模型，默认使用系统配置"),
+			}, "image_url"),
+		},
+		handler: mcpDescribeImage,
+	},
+	{
+		tool: dto.McpTool{
+			Name:        McpToolTranscribeAudio,
+			Description: "将音频转写为文字，支持 http(s) 音频链接或 data URI。",
+			InputSchema: mcpObjectSchema(map[string]any{
+				"audio_url": mcpStringSchema("音频链接（http/https）或 data:audio/...;base64,... 数据"),
+				"language":  mcpStringSchema("音频语言（ISO-639-1，例如 zh、en），可选"),
+				"prompt":    mcpStringSchema("提示词，可用于指定专有名词拼写，可选"),
+				"model":     mcpStringSchema("使用的语音识别模型，默认使用系统配置"),
+			}, "audio_url"),
+		},
+		handler: mcpTranscribeAudio,
+	},
+	{
+		tool: dto.McpTool{
+			Name:        McpToolGenerateImage,
+			Description: "根据文字描述生成图片。",
+			InputSchema: mcpObjectSchema(map[string]any{
+				"prompt": mcpStringSchema("图片描述"),
+				"size":   mcpStringSchema("图片尺寸，例如 1024x1024，可选"),
+				"model":  mcpStringSchema("使用的绘图模型，默认使用系统配置"),
+			}, "prompt"),
+		},
+		handler: mcpGenerateImage,
+	},
+	{
+		tool: dto.McpTool{
+			Name:        McpToolEmbedText,
+			Description: "计算文本的向量表示（embedding）。",
+			InputSchema: mcpObjectSchema(map[string]any{
+				"input": map[string]any{
+					"description": "要计算向量的文本，或文本数组",
+					"anyOf": []any{
+						map[string]any{"type": "string"},
+						map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+					},
+				},
+				"model": mcpStringSchema("使用的向量模型，默认使用系统配置"),
+			}, "input"),
+		},
+		handler: mcpEmbedText,
+	},
+	{
+		tool: dto.McpTool{
+			Name:        McpToolListModels,
+			Description: "列出当前令牌可以使用的模型。",
+			InputSchema: mcpObjectSchema(map[string]any{}),
+		},
+		handler: mcpListModels,
+	},
+}
+
+func mcpObjectSchema(properties map[string]any, required ...string) map[string]any {
+	schema := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func mcpStringSchema(description string) map[string]any {
+	return map[string]any{"type": "string", "description": description}
+}
+
+// ListMcpTools 返回 MCP tools/list 的工具列表
+func ListMcpTools() []dto.McpTool {
+	tools := make([]dto.McpTool, 0, len(mcpToolEntries))
+	for _, entry := range mcpToolEntries {
+		tools = append(tools, entry.tool)
+	}
+	return tools
+}
+
+// CallMcpTool 执行 MCP 工具；工具本身的错误以 isError 结果返回给模型，而不是 JSON-RPC 错误
+func CallMcpTool(origin *http.Request, name string, args map[string]any) (*dto.McpCallToolResult, bool) {
+	for _, entry := range mcpToolEntries {
+		if entry.tool.Name != name {
+			continue
+		}
+		if args == nil {
+			args = map[string]any{}
+		}
+		result, err := entry.handler(origin, args)
+		if err != nil {
+			return mcpErrorResult(err), true
+		}
+		return result, true
+	}
+	return nil, false
+}
+
+func mcpTextResult(text string) *dto.McpCallToolResult {
+	return &dto.McpCallToolResult{Content: []dto.McpContent{{Type: "text", Text: text}}}
+}
+
+func mcpErrorResult(err error) *dto.McpCallToolResult {
+	return &dto.McpCallToolResult{
+		Content: []dto.McpContent{{Type: "text", Text: err.Error()}},
+		IsError: true,
+	}
+}
+
+func mcpStringArg(args map[string]any, key string) string {
+	value, _ := args[key].(string)
+	return strings.TrimSpace(value)
+}
+
+func mcpModelArg(args map[string]any, fallback string) (string, error) {
+	if model := mcpStringArg(args, "model"); model != "" {
+		return model, nil
+	}
+	if fallback == "" {
+		return "", errors.New("未指定模型，且系统未配置默认模型")
+	}
+	return fallback, nil
+}
+
+// mcpRelay 在进程内将请求转发给 relay 路由，非 2xx 响应转换为错误
+func mcpRelay(origin *http.Request, method string, requestPath string, contentType string, body []byte) ([]byte, error) {
+	if mcpRelayHandler == nil {
+		return nil, errors.New("MCP relay handler is not initialized")
+	}
+	req, err := http.NewRequestWithContext(origin.Context(), method, requestPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for _, header := range mcpForwardHeaders {
+		if value := origin.Header.Get(header); value != "" {
+			req.Header.Set(header, value)
+		}
+	}
+	// 内部请求走 /v1 的 OpenAI 路由，只识别 Authorization，令牌放在其他头中时改写为 Bearer
+	if req.Header.Get("Authorization") == "" {
+		for _, header := range []string{"X-Api-Key", "X-Goog-Api-Key"} {
+			if value := req.Header.Get(header); value != "" {
+				req.Header.Set("Authorization", "Bearer "+value)
+				break
+			}
+		}
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.RemoteAddr = origin.RemoteAddr
+	req.Host = origin.Host
+
+	writer := common.NewInternalResponseWriter()
+	mcpRelayHandler.ServeHTTP(writer, req)
+	respBody := writer.Body()
+	if writer.Status()/100 != 2 {
+		return nil, mcpRelayError(writer.Status(), respBody)
+	}
+	return respBody, nil
+}
+
+func mcpRelayJSON(origin *http.Request, requestPath string, payload any, out any) error {
+	body, err := common.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	respBody, err := mcpRelay(origin, http.MethodPost, requestPath, "application/json", body)
+	if err != nil {
+		return err
+	}
+	return common.Unmarshal(respBody, out)
+}
+
+func mcpRelayError(status int, body []byte) error {
+	var openAIError struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+		Message string `json:"message"`
+	}
+	if err := common.Unmarshal(body, &openAIError); err == nil {
+		if openAIError.Error.Message != "" {
+			return fmt.Errorf("请求失败（HTTP %d）：%s", status, openAIError.Error.Message)
+		}
+		if openAIError.Message != "" {
+			return fmt.Errorf("请求失败（HTTP %d）：%s", status, openAIError.Message)
+		}
+	}
+	return fmt.Errorf("请求失败（HTTP %d）", status)
+}
+
+func mcpDescribeImage(origin *http.Request, args map[string]any) (*dto.McpCallToolResult, error) {
+	imageURL := mcpStringArg(args, "image_url")
+	if imageURL == "" {
+		return nil, errors.New("image_url 不能为空")
+	}
+	model, err := mcpModelArg(args, operation_setting.GetMcpSetting().VisionModel)
+	if err != nil {
+		return nil, err
+	}
+	prompt := mcpStringArg(args, "prompt")
+	if prompt == "" {
+		prompt = defaultMcpDescribePrompt
+	}
+
+	payload := map[string]any{
+		"model":  model,
+		"stream": false,
+		"messages": []any{
+			map[string]any{
+				"role": "user",
+				"content": []any{
+					map[string]any{"type": "text", "text": prompt},
+					map[string]any{"type": "image_url", "image_url": map[string]any{"url": imageURL}},
+				},
+			},
+		},
+	}
+	var resp dto.OpenAITextResponse
+	if err := mcpRelayJSON(origin, "/v1/chat/completions", payload, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, errors.New("模型未返回内容")
+	}
+	return mcpTextResult(resp.Choices[0].Message.StringContent()), nil
+}
+
+func mcpTranscribeAudio(origin *http.Request, args map[string]any) (*dto.McpCallToolResult, error) {
+	audioURL := mcpStringArg(args, "audio_url")
+	if audioURL == "" {
+		return nil, errors.New("audio_url 不能为空")
+	}
+	model, err := mcpModelArg(args, operation_setting.GetMcpSetting().TranscriptionModel)
+	if err != nil {
+		return nil, err
+	}
+	data, fileName, err := loadMcpAudio(audioURL)
+	if err != nil {
+		return nil, err
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	fields := map[string]string{
+		"model":           model,
+		"response_format": "json",
+		"language":        mcpStringArg(args, "language"),
+		"prompt":          mcpStringArg(args, "prompt"),
+	}
+	for key, value := range fields {
+		if value == "" {
+			continue
+		}
+		if err := writer.WriteField(key, value); err != nil {
+			return nil, err
+		}
+	}
+	part, err := writer.CreateFormFile("file", fileName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	respBody, err := mcpRelay(origin, http.MethodPost, "/v1/audio/transcriptions", writer.FormDataContentType(), body.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	var resp dto.AudioResponse
+	if err := common.Unmarshal(respBody, &resp); err != nil {
+		return nil, err
+	}
+	return mcpTextResult(resp.Text), nil
+}
+
+// loadMcpAudio 读取 data URI 或下载远程音频，返回内容与带扩展名的文件名（语音识别接口依赖扩展名判断格式）
+func loadMcpAudio(audioURL string) ([]byte, string, error) {
+	maxBytes := int64(constant.MaxFileDownloadMB) * 1024 * 1024
+	if strings.HasPrefix(audioURL, "data:") {
+		mimeType, b64, err := DecodeBase64FileData(audioURL)
+		if err != nil {
+			return nil, "", fmt.Errorf("解析音频数据失败：%w", err)
+		}
+		data, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil {
+			return nil, "", fmt.Errorf("解析音频数据失败：%w", err)
+		}
+		if maxBytes > 0 && int64(len(data)) > maxBytes {
+			return nil, "", fmt.Errorf("音频大小超过 %dMB 限制", constant.MaxFileDownloadMB)
+		}
+		return data, "audio." + mcpAudioExtension(mimeType, ""), nil
+	}
+	if !strings.HasPrefix(audioURL, "http://") && !strings.HasPrefix(audioURL, "https://") {
+		return nil, "", errors.New("audio_url 必须是 http(s) 链接或 data URI")
+	}
+
+	resp, err := DoDownloadRequest(audioURL, "mcp transcribe_audio")
+	if err != nil {
+		return nil, "", fmt.Errorf("下载音频失败：%w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("下载音频失败：HTTP %d", resp.StatusCode)
+	}
+	reader := io.Reader(resp.Body)
+	if maxBytes > 0 {
+		reader = io.LimitReader(resp.Body, maxBytes+1)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("下载音频失败：%w", err)
+	}
+	if maxBytes > 0 && int64(len(data)) > maxBytes {
+		return nil, "", fmt.Errorf("音频大小超过 %dMB 限制", constant.MaxFileDownloadMB)
+	}
+	urlPath := audioURL
+	if idx := strings.IndexAny(urlPath, "?#"); idx >= 0 {
+		urlPath = urlPath[:idx]
+	}
+	return data, "audio." + mcpAudioExtension(resp.Header.Get("Content-Type"), path.Ext(urlPath)), nil
+}
+
+func mcpAudioExtension(mimeType string, urlExt string) string {
+	switch ext := strings.ToLower(strings.TrimPrefix(urlExt, ".")); ext {
+	case "mp3", "mp4", "mpeg", "mpga", "m4a", "wav", "webm", "ogg", "oga", "flac":
+		return ext
+	}
+	mimeType = strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+	switch mimeType {
+	case "audio/wav", "audio/x-wav", "audio/wave":
+		return "wav"
+	case "audio/mp4", "audio/m4a", "audio/x-m4a":
+		return "m4a"
+	case "audio/webm":
+		return "webm"
+	case "audio/ogg":
+		return "ogg"
+	case "audio/flac", "audio/x-flac":
+		return "flac"
+	default:
+		return "mp3"
+	}
+}
+
+func mcpGenerateImage(origin *http.Request, args map[string]any) (*dto.McpCallToolResult, error) {
+	prompt := mcpStringArg(args, "prompt")
+	if prompt == "" {
+		return nil, errors.New("prompt 不能为空")
+	}
+	model, err := mcpModelArg(args, operation_setting.GetMcpSetting().ImageModel)
+	if err != nil {
+		return nil, err
+	}
+	payload := map[string]any{
+		"model":  model,
+		"prompt": prompt,
+		"n":      1,
+	}
+	if size := mcpStringArg(args, "size"); size != "" {
+		payload["size"] = size
+	}
+	var resp dto.ImageResponse
+	if err := mcpRelayJSON(origin, "/v1/images/generations", payload, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Data) == 0 {
+		return nil, errors.New("模型未返回图片")
+	}
+
+	result := &dto.McpCallToolResult{}
+	for _, image := range resp.Data {
+		switch {
+		case image.B64Json != "":
+			result.Content = append(result.Content, dto.McpContent{Type: "image", Data: image.B64Json, MimeType: "image/png"})
+		case image.Url != "":
+			result.Content = append(result.Content, dto.McpContent{Type: "text", Text: image.Url})
+		}
+		if image.RevisedPrompt != "" {
+			result.Content = append(result.Content, dto.McpContent{Type: "text", Text: "revised_prompt: " + image.RevisedPrompt})
+		}
+	}
+	return result, nil
+}
+
+func mcpEmbedText(origin *http.Request, args map[string]any) (*dto.McpCallToolResult, error) {
+	var input any
+	switch value := args["input"].(type) {
+	case string:
+		if strings.TrimSpace(value) != "" {
+			input = value
+		}
+	case []any:
+		texts := make([]string, 0, len(value))
+		for _, item := range value {
+			text, ok := item.(string)
+			if !ok {
+				return nil, errors.New("input 数组只能包含字符串")
+			}
+			texts = append(texts, text)
+		}
+		if len(texts) > 0 {
+			input = texts
+		}
+	}
+	if input == nil {
+		return nil, errors.New("input 不能为空")
+	}
+	model, err := mcpModelArg(args, operation_setting.GetMcpSetting().EmbeddingModel)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp dto.OpenAIEmbeddingResponse
+	if err := mcpRelayJSON(origin, "/v1/embeddings", map[string]any{"model": model, "input": input}, &resp); err != nil {
+		return nil, err
+	}
+	embeddings := make([][]float64, 0, len(resp.Data))
+	for _, item := range resp.Data {
+		embeddings = append(embeddings, item.Embedding)
+	}
+	text, err := common.Marshal(map[string]any{
+		"model":      resp.Model,
+		"embeddings": embeddings,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mcpTextResult(string(text)), nil
+}
+
+func mcpListModels(origin *http.Request, _ map[string]any) (*dto.McpCallToolResult, error) {
+	respBody, err := mcpRelay(origin, http.MethodGet, "/v1/models", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Data []dto.OpenAIModels `json:"data"`
+	}
+	if err := common.Unmarshal(respBody, &resp); err != nil {
+		return nil, err
+	}
+	models := make([]string, 0, len(resp.Data))
+	for _, item := range resp.Data {
+		models = append(models, item.Id)
+	}
+	return mcpTextResult(strings.Join(models, "\n")), nil
+}
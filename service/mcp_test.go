@@ -0,0 +1,110 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/setting/operation_setting"
+)
+
+func setupMcpRelayHandler(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	old := mcpRelayHandler
+	SetMcpRelayHandler(handler)
+	t.Cleanup(func() {
+		mcpRelayHandler = old
+	})
+}
+
+func TestCallMcpToolDescribeImageUsesRelay(t *testing.T) {
+	var gotPath, gotAuth string
+	var gotBody map[string]any
+	setupMcpRelayHandler(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		_ = common.Unmarshal(body, &gotBody)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"index":0,"message":{"role":"assistant","content":"a cat"}}]}`))
+	})
+
+	origin := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+	origin.Header.Set("Authorization", "Bearer sk-test")
+	result, ok := CallMcpTool(origin, McpToolDescribeImage, map[string]any{"image_url": "https://example.com/cat.png"})
+	if !ok {
+		t.Fatal("describe_image tool not found")
+	}
+	if result.IsError || len(result.Content) != 1 || result.Content[0].Text != "a cat" {
+		t.Fatalf("result = %+v", result)
+	}
+	if gotPath != "/v1/chat/completions" || gotAuth != "Bearer sk-test" {
+		t.Fatalf("relay request = %s with auth %q", gotPath, gotAuth)
+	}
+	if gotBody["model"] != operation_setting.GetMcpSetting().VisionModel {
+		t.Fatalf("model = %v, want configured vision model", gotBody["model"])
+	}
+}
+
+func TestMcpRelayForwardsAPIKeyHeaders(t *testing.T) {
+	var gotAuth, gotAPIKey string
+	setupMcpRelayHandler(t, func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotAPIKey = r.Header.Get("x-api-key")
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	})
+
+	for _, header := range []string{"x-api-key", "x-goog-api-key"} {
+		gotAuth, gotAPIKey = "", ""
+		origin := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		origin.Header.Set(header, "sk-test")
+		if _, err := mcpRelay(origin, http.MethodGet, "/v1/models", "", nil); err != nil {
+			t.Fatalf("mcpRelay with %s returned error: %v", header, err)
+		}
+		if gotAuth != "Bearer sk-test" {
+			t.Fatalf("%s: relay Authorization = %q", header, gotAuth)
+		}
+		if header == "x-api-key" && gotAPIKey != "sk-test" {
+			t.Fatalf("x-api-key should be forwarded, got %q", gotAPIKey)
+		}
+	}
+}
+
+func TestCallMcpToolRelayErrorIsToolError(t *testing.T) {
+	setupMcpRelayHandler(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"error":{"message":"该令牌无权使用模型"}}`))
+	})
+
+	origin := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+	result, ok := CallMcpTool(origin, McpToolEmbedText, map[string]any{"input": []any{"hello"}, "model": "m"})
+	if !ok {
+		t.Fatal("embed_text tool not found")
+	}
+	if !result.IsError || !strings.Contains(result.Content[0].Text, "该令牌无权使用模型") {
+		t.Fatalf("result = %+v", result)
+	}
+
+	if _, ok := CallMcpTool(origin, "unknown_tool", nil); ok {
+		t.Fatal("expected unknown tool to be rejected")
+	}
+}
+
+func TestMcpAudioExtension(t *testing.T) {
+	cases := []struct {
+		mimeType, urlExt, want string
+	}{
+		{"audio/wav", "", "wav"},
+		{"application/octet-stream", ".M4A", "m4a"},
+		{"audio/ogg; codecs=opus", "", "ogg"},
+		{"", "", "mp3"},
+	}
+	for _, tc := range cases {
+		if got := mcpAudioExtension(tc.mimeType, tc.urlExt); got != tc.want {
+			t.Fatalf("mcpAudioExtension(%q, %q) = %q, want %q", tc.mimeType, tc.urlExt, got, tc.want)
+		}
+	}
+}
@@ -98,14 +98,34 @@ func calculateAudioQuota(info QuotaInfo) int {
 	return int(quota.Round(0).IntPart())
 }
 
+// CalculateUsageQuota 按价格数据计算单次调用的额度，文本与音频 token 分别计价；
+// 用于实时桥接阶段调用等不经过完整结算流程的内部请求
+func CalculateUsageQuota(priceData types.PriceData, usage *dto.Usage) int {
+	quotaInfo := QuotaInfo{
+		UsePrice:             priceData.UsePrice,
+		ModelPrice:           priceData.ModelPrice,
+		ModelRatio:           priceData.ModelRatio,
+		GroupRatio:           priceData.GroupRatioInfo.GroupRatio,
+		CompletionRatio:      priceData.CompletionRatio,
+		AudioRatio:           priceData.AudioRatio,
+		AudioCompletionRatio: priceData.AudioCompletionRatio,
+	}
+	if usage != nil {
+		quotaInfo.InputDetails.AudioTokens = usage.PromptTokensDetails.AudioTokens
+		quotaInfo.InputDetails.TextTokens = max(usage.PromptTokens-usage.PromptTokensDetails.AudioTokens, 0)
+		quotaInfo.OutputDetails.AudioTokens = usage.CompletionTokenDetails.AudioTokens
+		quotaInfo.OutputDetails.TextTokens = max(usage.CompletionTokens-usage.CompletionTokenDetails.AudioTokens, 0)
+	}
+	if !quotaInfo.UsePrice && quotaInfo.InputDetails == (TokenDetails{}) && quotaInfo.OutputDetails == (TokenDetails{}) {
+		return 0
+	}
+	return calculateAudioQuota(quotaInfo)
+}
+
 func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.RealtimeUsage) error {
 	if relayInfo.PriceData.UsePrice {
 		return nil
 	}
-	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
-	if err != nil {
-		return err
-	}
 
 	modelName := relayInfo.OriginModelName
 	textInputTokens := usage.InputTokenDetails.TextTokens
@@ -141,8 +161,15 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 		AudioCompletionRatio: relayInfo.PriceData.AudioCompletionRatio,
 	}
 
-	quota := calculateAudioQuota(quotaInfo)
+	return PreConsumeRealtimeQuota(ctx, relayInfo, calculateAudioQuota(quotaInfo))
+}
 
+// PreConsumeRealtimeQuota 校验余额后扣除实时会话本轮的额度，计入 FinalPreConsumedQuota
+func PreConsumeRealtimeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, quota int) error {
+	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+	if err != nil {
+		return err
+	}
 	if userQuota < quota {
 		return fmt.Errorf("user quota is not enough, user quota: %s, need quota: %s", logger.FormatQuota(userQuota), logger.FormatQuota(quota))
 	}
@@ -152,8 +179,7 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 		return fmt.Errorf("token quota is not enough, token remain quota: %s, need quota: %s", logger.FormatQuota(relayInfo.TokenQuota), logger.FormatQuota(quota))
 	}
 
-	err = PostConsumeQuota(relayInfo, quota, 0, false)
-	if err != nil {
+	if err := PostConsumeQuota(relayInfo, quota, 0, false); err != nil {
 		return err
 	}
 	relayInfo.FinalPreConsumedQuota += quota
@@ -267,7 +293,49 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 	return nil
 }
 
+// PostRealtimeStagesConsumeQuota 记录实时桥接 pipeline 会话的消费日志。
+// 额度已在会话中按 STT / 对话 / TTS 各阶段模型的价格逐轮扣除，渠道已用额度也已计入处理各阶段的渠道
+func PostRealtimeStagesConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.RealtimeUsage,
+	stageQuotas map[string]int, extraContent string) {
+	useTimeMs := time.Since(relayInfo.StartTime).Milliseconds()
+	quota := 0
+	for _, stageQuota := range stageQuotas {
+		quota += stageQuota
+	}
+	if quota > 0 {
+		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
+	}
+
+	logContent := "按各阶段模型价格计费"
+	if extraContent != "" {
+		logContent += ", " + extraContent
+	}
+	other := map[string]interface{}{
+		"ws":           true,
+		"stage_quota":  stageQuotas,
+		"audio_input":  usage.InputTokenDetails.AudioTokens,
+		"audio_output": usage.OutputTokenDetails.AudioTokens,
+		"text_input":   usage.InputTokenDetails.TextTokens,
+		"text_output":  usage.OutputTokenDetails.TextTokens,
+	}
+	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
+		ChannelId:        relayInfo.ChannelId,
+		PromptTokens:     usage.InputTokens,
+		CompletionTokens: usage.OutputTokens,
+		ModelName:        relayInfo.UpstreamModelName,
+		TokenName:        ctx.GetString("token_name"),
+		Quota:            quota,
+		Content:          logContent,
+		TokenId:          relayInfo.TokenId,
+		UseTimeMs:        int(useTimeMs),
+		IsStream:         relayInfo.IsStream,
+		Group:            relayInfo.UsingGroup,
+		Other:            other,
+	})
+}
+
 func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage) *types.NewAPIError {
+	relayInfo.FinalUsage = usage
 
 	useTimeMs := time.Since(relayInfo.StartTime).Milliseconds()
 	promptTokens := usage.PromptTokens
@@ -319,6 +387,23 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		}
 	}
 
+	ObserveChannelAffinityUsageCacheFromContext(ctx, usage, promptTokens+cacheTokens+cacheCreationTokens)
+	promptRatio := modelRatio * groupRatio
+	if relayInfo.PriceData.UsePrice {
+		promptRatio = 0
+	}
+	remainingCreationTokens := max(cacheCreationTokens-cacheCreationTokens5m-cacheCreationTokens1h, 0)
+	ObserveCacheUsage(ctx, relayInfo, CacheUsageObservation{
+		UncachedTokens:   promptTokens,
+		CacheReadTokens:  cacheTokens,
+		CacheWriteTokens: cacheCreationTokens,
+		CacheWriteWeightedTokens: float64(cacheCreationTokens5m)*cacheCreationRatio5m +
+			float64(cacheCreationTokens1h)*cacheCreationRatio1h +
+			float64(remainingCreationTokens)*cacheCreationRatio,
+		CacheRatio:  cacheRatio,
+		PromptRatio: promptRatio,
+	})
+
 	calculateQuota := 0.0
 	if !relayInfo.PriceData.UsePrice {
 		calculateQuota = float64(promptTokens)
@@ -419,6 +504,7 @@ func CalcOpenRouterCacheCreateTokens(usage dto.Usage, priceData types.PriceData)
 }
 
 func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage, extraContent string) *types.NewAPIError {
+	relayInfo.FinalUsage = usage
 
 	useTimeMs := time.Since(relayInfo.StartTime).Milliseconds()
 	textInputTokens := usage.PromptTokensDetails.TextTokens
@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
 	relaycommon "github.com/zhongruan0522/new-api/relay/common"
 	"github.com/zhongruan0522/new-api/setting/operation_setting"
 	"github.com/zhongruan0522/new-api/types"
@@ -34,3 +36,36 @@ func TestNewEmptyUsageRetryErrorSkipsConvertedRequest(t *testing.T) {
 		t.Fatalf("expected converted empty usage not to force retry, got %v", apiErr)
 	}
 }
+
+func TestCalculateUsageQuotaPricesStageByOwnModel(t *testing.T) {
+	priceData := types.PriceData{
+		ModelRatio:           2,
+		CompletionRatio:      3,
+		AudioRatio:           4,
+		AudioCompletionRatio: 2,
+		GroupRatioInfo:       types.GroupRatioInfo{GroupRatio: 1},
+	}
+
+	chat := &dto.Usage{PromptTokens: 100, CompletionTokens: 10}
+	if got := CalculateUsageQuota(priceData, chat); got != (100+10*3)*2 {
+		t.Fatalf("chat quota = %d, want %d", got, (100+10*3)*2)
+	}
+
+	speech := &dto.Usage{PromptTokens: 20, CompletionTokens: 50}
+	speech.CompletionTokenDetails.AudioTokens = 50
+	if got := CalculateUsageQuota(priceData, speech); got != (20+50*4*2)*2 {
+		t.Fatalf("speech quota = %d, want %d", got, (20+50*4*2)*2)
+	}
+
+	if got := CalculateUsageQuota(priceData, nil); got != 0 {
+		t.Fatalf("quota without usage = %d, want 0", got)
+	}
+
+	oldQuotaPerUnit := common.QuotaPerUnit
+	common.QuotaPerUnit = 500
+	t.Cleanup(func() { common.QuotaPerUnit = oldQuotaPerUnit })
+	perCall := types.PriceData{UsePrice: true, ModelPrice: 0.01, GroupRatioInfo: types.GroupRatioInfo{GroupRatio: 2}}
+	if got := CalculateUsageQuota(perCall, nil); got != 10 {
+		t.Fatalf("per-call quota = %d, want 10", got)
+	}
+}
@@ -0,0 +1,25 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/model"
+	"github.com/zhongruan0522/new-api/setting/operation_setting"
+)
+
+// RunStoredResponseCleaner 定时清理超过保留天数的 Responses 存储
+func RunStoredResponseCleaner() {
+	for {
+		setting := operation_setting.GetResponsesStoreSetting()
+		target := common.GetTimestamp() - setting.RetentionSeconds()
+		if count, err := model.DeleteExpiredStoredResponses(context.Background(), target, 500); err != nil {
+			common.SysError("failed to clean stored responses: " + err.Error())
+		} else if count > 0 {
+			common.SysLog(fmt.Sprintf("cleaned %d expired stored responses", count))
+		}
+		time.Sleep(time.Hour)
+	}
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/zhongruan0522/new-api/logger"
+	"github.com/zhongruan0522/new-api/model"
+)
+
+const (
+	taskPollBaseIntervalSeconds = 10
+	taskPollMaxIntervalSeconds  = 5 * 60
+	// TaskTimeoutSeconds 任务提交后超过该时长仍未完成则判定失败并退款
+	TaskTimeoutSeconds = 6 * 60 * 60
+)
+
+// NextTaskPollAt 按轮询次数指数退避计算下次轮询时间，上限 5 分钟
+func NextTaskPollAt(now int64, pollCount int) int64 {
+	interval := int64(taskPollMaxIntervalSeconds)
+	if pollCount < 10 {
+		interval = min(int64(taskPollBaseIntervalSeconds)<<pollCount, taskPollMaxIntervalSeconds)
+	}
+	return now + interval
+}
+
+func IsTaskTimedOut(task *model.Task, now int64) bool {
+	return now-task.CreatedAt > TaskTimeoutSeconds
+}
+
+// RefundTask 任务最终失败时退还提交时扣除的用户与令牌额度，并记录退款日志。
+// 退款在一个事务内完成且只会生效一次，失败时返回错误，由轮询重试
+func RefundTask(task *model.Task) error {
+	refunded, err := model.RefundFailedTask(task)
+	if err != nil {
+		return fmt.Errorf("refund task %s failed: %w", task.TaskId, err)
+	}
+	if !refunded {
+		return nil
+	}
+	model.RecordLog(task.UserId, model.LogTypeRefund, fmt.Sprintf("异步任务 %s（%s）失败，退还 %s：%s",
+		task.TaskId, task.ModelName, logger.FormatQuota(task.Quota), task.FailReason))
+	return nil
+}
@@ -0,0 +1,26 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/zhongruan0522/new-api/model"
+)
+
+func TestNextTaskPollAt(t *testing.T) {
+	cases := map[int]int64{0: 10, 1: 20, 3: 80, 5: 300, 9: 300, 40: 300}
+	for pollCount, want := range cases {
+		if got := NextTaskPollAt(1000, pollCount) - 1000; got != want {
+			t.Fatalf("pollCount %d: interval = %d, want %d", pollCount, got, want)
+		}
+	}
+}
+
+func TestIsTaskTimedOut(t *testing.T) {
+	task := &model.Task{CreatedAt: 1000}
+	if IsTaskTimedOut(task, 1000+TaskTimeoutSeconds) {
+		t.Fatal("task should not time out at the boundary")
+	}
+	if !IsTaskTimedOut(task, 1001+TaskTimeoutSeconds) {
+		t.Fatal("task should time out after the timeout")
+	}
+}
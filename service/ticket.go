@@ -16,51 +16,73 @@ const (
 )
 
 type TicketSummary struct {
-	Id        int    `json:"id"`
-	Title     string `json:"title"`
-	Type      string `json:"type"`
-	Status    string `json:"status"`
-	CreatedAt int64  `json:"created_at"`
-	UpdatedAt int64  `json:"updated_at"`
+	Id                 int    `json:"id"`
+	Title              string `json:"title"`
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	Priority           string `json:"priority"`
+	AssigneeId         int    `json:"assignee_id"`
+	CreatedAt          int64  `json:"created_at"`
+	UpdatedAt          int64  `json:"updated_at"`
+	FirstResponseDueAt int64  `json:"first_response_due_at"`
+	ResolutionDueAt    int64  `json:"resolution_due_at"`
 }
 
 type TicketMessage struct {
-	Id       int    `json:"id"`
-	Type     string `json:"type"`
-	Role     string `json:"role"`
-	Username string `json:"username"`
-	Content  string `json:"content,omitempty"`
-	Value    string `json:"value,omitempty"`
-	Time     int64  `json:"time"`
+	Id          int                    `json:"id"`
+	Type        string                 `json:"type"`
+	Role        string                 `json:"role"`
+	Username    string                 `json:"username"`
+	Content     string                 `json:"content,omitempty"`
+	Value       string                 `json:"value,omitempty"`
+	Attachments []TicketAttachmentInfo `json:"attachments,omitempty"`
+	Time        int64                  `json:"time"`
+}
+
+type TicketAttachmentInfo struct {
+	Id        string `json:"id"`
+	FileName  string `json:"file_name"`
+	MimeType  string `json:"mime_type"`
+	SizeBytes int    `json:"size_bytes"`
+	CreatedAt int64  `json:"created_at"`
 }
 
 type TicketDetail struct {
-	Id        int             `json:"id"`
-	Title     string          `json:"title"`
-	Type      string          `json:"type"`
-	Status    string          `json:"status"`
-	CreatedAt int64           `json:"created_at"`
-	UpdatedAt int64           `json:"updated_at"`
-	ClosedAt  int64           `json:"closed_at"`
-	Messages  []TicketMessage `json:"messages"`
+	Id                 int             `json:"id"`
+	Title              string          `json:"title"`
+	Type               string          `json:"type"`
+	Status             string          `json:"status"`
+	Priority           string          `json:"priority"`
+	AssigneeId         int             `json:"assignee_id"`
+	RequestIds         []string        `json:"request_ids"`
+	CreatedAt          int64           `json:"created_at"`
+	UpdatedAt          int64           `json:"updated_at"`
+	ClosedAt           int64           `json:"closed_at"`
+	FirstResponseAt    int64           `json:"first_response_at"`
+	FirstResponseDueAt int64           `json:"first_response_due_at"`
+	ResolutionDueAt    int64           `json:"resolution_due_at"`
+	Messages           []TicketMessage `json:"messages"`
 }
 
 type CreateTicketInput struct {
-	UserId   int
-	Username string
-	Role     int
-	Title    string
-	Type     string
-	Content  string
+	UserId     int
+	Username   string
+	Role       int
+	Title      string
+	Type       string
+	Priority   string
+	Content    string
+	RequestIds []string
 }
 
 type ReplyTicketInput struct {
-	TicketId  int
-	UserId    int
-	Username  string
-	Role      int
-	Content   string
-	NewStatus string
+	TicketId      int
+	UserId        int
+	Username      string
+	Role          int
+	Content       string
+	CannedReplyId int
+	NewStatus     string
 }
 
 func ListUserTickets(userId int, page, pageSize int, status string, keyword string) ([]TicketSummary, int64, error) {
@@ -75,7 +97,7 @@ func ListUserTickets(userId int, page, pageSize int, status string, keyword stri
 	return buildTicketSummaries(tickets), total, nil
 }
 
-func ListAdminTickets(role int, page, pageSize int, status string, keyword string) ([]TicketSummary, int64, error) {
+func ListAdminTickets(role int, page, pageSize int, status string, keyword string, priority string, assigneeId int) ([]TicketSummary, int64, error) {
 	if !canManageAllTickets(role) {
 		return nil, 0, errors.New("无权进行此操作")
 	}
@@ -83,6 +105,13 @@ func ListAdminTickets(role int, page, pageSize int, status string, keyword strin
 	if err != nil {
 		return nil, 0, err
 	}
+	if priority != "" && priority != "all" {
+		filter.Priority, err = model.ParseTicketPriority(priority)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	filter.AssigneeId = assigneeId
 	tickets, total, err := model.ListAdminTickets(filter)
 	if err != nil {
 		return nil, 0, err
@@ -103,8 +132,19 @@ func CreateTicket(input CreateTicketInput) (*TicketDetail, error) {
 	if err != nil {
 		return nil, err
 	}
+	priority, err := model.ParseTicketPriority(input.Priority)
+	if err != nil {
+		return nil, err
+	}
+	requestIds, err := model.NormalizeTicketRequestIds(input.RequestIds)
+	if err != nil {
+		return nil, err
+	}
 
 	ticket := model.NewTicket(title, input.UserId, ticketType)
+	ticket.Priority = priority
+	ticket.RequestIds = requestIds
+	ticket.FirstResponseDueAt, ticket.ResolutionDueAt = computeTicketSlaDueAt(priority, ticket.CreatedAt)
 	entry := &model.TicketEntry{
 		EntryType:    model.TicketEntryTypeMessage,
 		SenderUserId: input.UserId,
@@ -128,6 +168,7 @@ func CreateTicket(input CreateTicketInput) (*TicketDetail, error) {
 		return nil, errors.New("创建工单失败")
 	}
 
+	notifyTicketCreated(ticket, input.Username, input.Role, content)
 	return buildTicketDetail(ticket, []*model.TicketEntry{entry}), nil
 }
 
@@ -143,17 +184,33 @@ func GetTicketDetail(ticketId int, userId int, role int) (*TicketDetail, error)
 	if err != nil {
 		return nil, err
 	}
-	return buildTicketDetail(ticket, entries), nil
+	attachments, err := model.GetTicketAttachmentMetas(ticketId)
+	if err != nil {
+		return nil, err
+	}
+	return buildTicketDetail(ticket, entries, attachments...), nil
 }
 
 func ReplyTicket(input ReplyTicketInput) error {
-	content, err := validateTicketText(input.Content, maxTicketContentRunes, "回复内容不能为空", "回复内容过长")
+	rawContent := input.Content
+	if input.CannedReplyId > 0 && strings.TrimSpace(rawContent) == "" {
+		if !canManageAllTickets(input.Role) {
+			return errors.New("无权进行此操作")
+		}
+		cannedReply, err := model.GetTicketCannedReplyByID(input.CannedReplyId)
+		if err != nil {
+			return err
+		}
+		rawContent = cannedReply.Content
+	}
+	content, err := validateTicketText(rawContent, maxTicketContentRunes, "回复内容不能为空", "回复内容过长")
 	if err != nil {
 		return err
 	}
 
-	return model.DB.Transaction(func(tx *gorm.DB) error {
-		ticket, err := model.GetTicketByIDForUpdate(tx, input.TicketId)
+	var ticket *model.Ticket
+	err = model.DB.Transaction(func(tx *gorm.DB) error {
+		ticket, err = model.GetTicketByIDForUpdate(tx, input.TicketId)
 		if err != nil {
 			return err
 		}
@@ -174,11 +231,21 @@ func ReplyTicket(input ReplyTicketInput) error {
 		if err := model.CreateTicketEntryTx(tx, entry); err != nil {
 			return errors.New("发送回复失败")
 		}
-		if err := model.UpdateTicketFieldsTx(tx, ticket.Id, map[string]any{"updated_at": now}); err != nil {
+		values := map[string]any{"updated_at": now}
+		if isTicketFirstResponse(ticket, input.UserId, input.Role) {
+			values["first_response_at"] = now
+		}
+		if err := model.UpdateTicketFieldsTx(tx, ticket.Id, values); err != nil {
 			return errors.New("发送回复失败")
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	notifyTicketReplied(ticket, input.UserId, input.Username, input.Role, content)
+	return nil
 }
 
 func CloseTicket(ticketId int, userId int, role int, username string) error {
@@ -196,12 +263,191 @@ func UpdateTicketStatus(ticketId int, userId int, role int, username string, sta
 	return changeTicketStatus(ticketId, userId, role, username, targetStatus)
 }
 
-func changeTicketStatus(ticketId int, userId int, role int, username string, targetStatus int) error {
+// AssignTicket 将工单指派给管理员，assigneeId 为 0 时取消指派
+func AssignTicket(ticketId int, userId int, role int, username string, assigneeId int) error {
+	if !canManageAllTickets(role) {
+		return errors.New("无权进行此操作")
+	}
+	assigneeName := ""
+	if assigneeId > 0 {
+		assignee, err := model.GetUserById(assigneeId, false)
+		if err != nil {
+			return errors.New("指派的用户不存在")
+		}
+		if !canManageAllTickets(assignee.Role) {
+			return errors.New("只能指派给管理员")
+		}
+		assigneeName = assignee.Username
+	}
+
+	return model.DB.Transaction(func(tx *gorm.DB) error {
+		ticket, err := model.GetTicketByIDForUpdate(tx, ticketId)
+		if err != nil {
+			return err
+		}
+		if ticket.AssigneeId == assigneeId {
+			return nil
+		}
+
+		now := common.GetTimestamp()
+		if err := model.UpdateTicketFieldsTx(tx, ticket.Id, map[string]any{"assignee_id": assigneeId, "updated_at": now}); err != nil {
+			return errors.New("指派工单失败")
+		}
+		entry := &model.TicketEntry{
+			TicketId:     ticket.Id,
+			EntryType:    model.TicketEntryTypeAssignment,
+			SenderUserId: userId,
+			SenderName:   username,
+			SenderRole:   role,
+			Content:      assigneeName,
+			CreatedAt:    now,
+		}
+		if err := model.CreateTicketEntryTx(tx, entry); err != nil {
+			return errors.New("指派工单失败")
+		}
+		return nil
+	})
+}
+
+// UpdateTicketPriority 调整工单优先级，并按新优先级重新计算 SLA 截止时间
+func UpdateTicketPriority(ticketId int, userId int, role int, username string, priority string) error {
+	if !canManageAllTickets(role) {
+		return errors.New("无权进行此操作")
+	}
+	targetPriority, err := model.ParseTicketPriority(priority)
+	if err != nil {
+		return err
+	}
+
 	return model.DB.Transaction(func(tx *gorm.DB) error {
 		ticket, err := model.GetTicketByIDForUpdate(tx, ticketId)
 		if err != nil {
 			return err
 		}
+		if ticket.Priority == targetPriority {
+			return nil
+		}
+
+		now := common.GetTimestamp()
+		firstResponseDueAt, resolutionDueAt := computeTicketSlaDueAt(targetPriority, ticket.CreatedAt)
+		values := map[string]any{
+			"priority":               targetPriority,
+			"updated_at":             now,
+			"first_response_due_at":  firstResponseDueAt,
+			"resolution_due_at":      resolutionDueAt,
+			"first_response_alerted": false,
+			"resolution_alerted":     false,
+		}
+		if err := model.UpdateTicketFieldsTx(tx, ticket.Id, values); err != nil {
+			return errors.New("更新工单优先级失败")
+		}
+		entry := &model.TicketEntry{
+			TicketId:     ticket.Id,
+			EntryType:    model.TicketEntryTypePriorityChange,
+			SenderUserId: userId,
+			SenderName:   username,
+			SenderRole:   role,
+			FromStatus:   ticket.Priority,
+			ToStatus:     targetPriority,
+			CreatedAt:    now,
+		}
+		if err := model.CreateTicketEntryTx(tx, entry); err != nil {
+			return errors.New("更新工单优先级失败")
+		}
+		return nil
+	})
+}
+
+// UpdateTicketRequestIds 更新工单关联的请求 ID 列表
+func UpdateTicketRequestIds(ticketId int, userId int, role int, requestIds []string) error {
+	normalized, err := model.NormalizeTicketRequestIds(requestIds)
+	if err != nil {
+		return err
+	}
+	ticket, err := model.GetTicketByID(ticketId)
+	if err != nil {
+		return err
+	}
+	if err := ensureTicketAccess(ticket, userId, role); err != nil {
+		return err
+	}
+	if err := model.UpdateTicketFieldsTx(model.DB, ticket.Id, map[string]any{"request_ids": normalized}); err != nil {
+		return errors.New("更新关联请求失败")
+	}
+	return nil
+}
+
+// GetTicketLinkedLogs 返回工单关联的请求日志；普通用户只能看到自己的日志
+func GetTicketLinkedLogs(ticketId int, userId int, role int) ([]*model.Log, error) {
+	ticket, err := model.GetTicketByID(ticketId)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureTicketAccess(ticket, userId, role); err != nil {
+		return nil, err
+	}
+	logUserId := ticket.UserId
+	if canManageAllTickets(role) {
+		logUserId = 0
+	}
+	return model.GetTicketLinkedLogs(ticket.GetRequestIds(), logUserId)
+}
+
+func ListTicketCannedReplies(role int) ([]*model.TicketCannedReply, error) {
+	if !canManageAllTickets(role) {
+		return nil, errors.New("无权进行此操作")
+	}
+	return model.ListTicketCannedReplies()
+}
+
+func SaveTicketCannedReply(role int, userId int, id int, title string, content string) (*model.TicketCannedReply, error) {
+	if !canManageAllTickets(role) {
+		return nil, errors.New("无权进行此操作")
+	}
+	title, err := validateTicketText(title, 128, "快捷回复标题不能为空", "快捷回复标题过长")
+	if err != nil {
+		return nil, err
+	}
+	content, err = validateTicketText(content, maxTicketContentRunes, "快捷回复内容不能为空", "快捷回复内容过长")
+	if err != nil {
+		return nil, err
+	}
+
+	if id == 0 {
+		reply := &model.TicketCannedReply{Title: title, Content: content, CreatedBy: userId}
+		if err := reply.Insert(); err != nil {
+			return nil, errors.New("保存快捷回复失败")
+		}
+		return reply, nil
+	}
+	reply, err := model.GetTicketCannedReplyByID(id)
+	if err != nil {
+		return nil, err
+	}
+	reply.Title = title
+	reply.Content = content
+	if err := reply.Update(); err != nil {
+		return nil, errors.New("保存快捷回复失败")
+	}
+	return reply, nil
+}
+
+func DeleteTicketCannedReply(role int, id int) error {
+	if !canManageAllTickets(role) {
+		return errors.New("无权进行此操作")
+	}
+	return model.DeleteTicketCannedReplyByID(id)
+}
+
+func changeTicketStatus(ticketId int, userId int, role int, username string, targetStatus int) error {
+	var ticket *model.Ticket
+	changed := false
+	err := model.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		ticket, err = model.GetTicketByIDForUpdate(tx, ticketId)
+		if err != nil {
+			return err
+		}
 		if err := ensureTicketAccess(ticket, userId, role); err != nil {
 			return err
 		}
@@ -239,8 +485,17 @@ func changeTicketStatus(ticketId int, userId int, role int, username string, tar
 		if err := model.CreateTicketEntryTx(tx, entry); err != nil {
 			return errors.New("更新工单状态失败")
 		}
+		changed = true
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	if changed {
+		notifyTicketStatusChanged(ticket, userId, username, role, targetStatus)
+	}
+	return nil
 }
 
 func buildTicketListFilter(userId int, page int, pageSize int, status string, keyword string) (model.TicketListFilter, error) {
@@ -275,18 +530,33 @@ func buildTicketSummaries(tickets []*model.Ticket) []TicketSummary {
 	items := make([]TicketSummary, 0, len(tickets))
 	for _, ticket := range tickets {
 		items = append(items, TicketSummary{
-			Id:        ticket.Id,
-			Title:     ticket.Title,
-			Type:      model.TicketTypeName(ticket.Type),
-			Status:    model.TicketStatusName(ticket.Status),
-			CreatedAt: ticket.CreatedAt,
-			UpdatedAt: ticket.UpdatedAt,
+			Id:                 ticket.Id,
+			Title:              ticket.Title,
+			Type:               model.TicketTypeName(ticket.Type),
+			Status:             model.TicketStatusName(ticket.Status),
+			Priority:           model.TicketPriorityName(ticket.Priority),
+			AssigneeId:         ticket.AssigneeId,
+			CreatedAt:          ticket.CreatedAt,
+			UpdatedAt:          ticket.UpdatedAt,
+			FirstResponseDueAt: ticket.FirstResponseDueAt,
+			ResolutionDueAt:    ticket.ResolutionDueAt,
 		})
 	}
 	return items
 }
 
-func buildTicketDetail(ticket *model.Ticket, entries []*model.TicketEntry) *TicketDetail {
+func buildTicketDetail(ticket *model.Ticket, entries []*model.TicketEntry, attachments ...*model.TicketAttachment) *TicketDetail {
+	attachmentsByEntry := make(map[int][]TicketAttachmentInfo)
+	for _, attachment := range attachments {
+		attachmentsByEntry[attachment.EntryId] = append(attachmentsByEntry[attachment.EntryId], TicketAttachmentInfo{
+			Id:        attachment.Id,
+			FileName:  attachment.FileName,
+			MimeType:  attachment.MimeType,
+			SizeBytes: attachment.SizeBytes,
+			CreatedAt: attachment.CreatedAt,
+		})
+	}
+
 	messages := make([]TicketMessage, 0, len(entries))
 	for _, entry := range entries {
 		message := TicketMessage{
@@ -295,10 +565,21 @@ func buildTicketDetail(ticket *model.Ticket, entries []*model.TicketEntry) *Tick
 			Role:     buildMessageRole(entry.SenderRole),
 			Time:     entry.CreatedAt,
 		}
-		if entry.EntryType == model.TicketEntryTypeStatusChange {
+		switch entry.EntryType {
+		case model.TicketEntryTypeStatusChange:
 			message.Type = "status"
 			message.Value = model.TicketStatusName(entry.ToStatus)
-		} else {
+		case model.TicketEntryTypeAssignment:
+			message.Type = "assignment"
+			message.Value = entry.Content
+		case model.TicketEntryTypePriorityChange:
+			message.Type = "priority"
+			message.Value = model.TicketPriorityName(entry.ToStatus)
+		case model.TicketEntryTypeAttachment:
+			message.Type = "attachment"
+			message.Content = entry.Content
+			message.Attachments = attachmentsByEntry[entry.Id]
+		default:
 			message.Type = "message"
 			message.Content = entry.Content
 		}
@@ -306,17 +587,28 @@ func buildTicketDetail(ticket *model.Ticket, entries []*model.TicketEntry) *Tick
 	}
 
 	return &TicketDetail{
-		Id:        ticket.Id,
-		Title:     ticket.Title,
-		Type:      model.TicketTypeName(ticket.Type),
-		Status:    model.TicketStatusName(ticket.Status),
-		CreatedAt: ticket.CreatedAt,
-		UpdatedAt: ticket.UpdatedAt,
-		ClosedAt:  ticket.ClosedAt,
-		Messages:  messages,
+		Id:                 ticket.Id,
+		Title:              ticket.Title,
+		Type:               model.TicketTypeName(ticket.Type),
+		Status:             model.TicketStatusName(ticket.Status),
+		Priority:           model.TicketPriorityName(ticket.Priority),
+		AssigneeId:         ticket.AssigneeId,
+		RequestIds:         ticket.GetRequestIds(),
+		CreatedAt:          ticket.CreatedAt,
+		UpdatedAt:          ticket.UpdatedAt,
+		ClosedAt:           ticket.ClosedAt,
+		FirstResponseAt:    ticket.FirstResponseAt,
+		FirstResponseDueAt: ticket.FirstResponseDueAt,
+		ResolutionDueAt:    ticket.ResolutionDueAt,
+		Messages:           messages,
 	}
 }
 
+// isTicketFirstResponse 判断本次回复是否为管理员对他人工单的首次响应
+func isTicketFirstResponse(ticket *model.Ticket, userId int, role int) bool {
+	return ticket.FirstResponseAt == 0 && canManageAllTickets(role) && ticket.UserId != userId
+}
+
 func buildMessageRole(role int) string {
 	if canManageAllTickets(role) {
 		return "admin"
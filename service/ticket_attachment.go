@@ -0,0 +1,148 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/model"
+	"gorm.io/gorm"
+)
+
+const maxTicketAttachmentNameRunes = 255
+
+type UploadTicketAttachmentInput struct {
+	TicketId int
+	UserId   int
+	Username string
+	Role     int
+	FileName string
+	Content  string
+	Data     []byte
+}
+
+// TicketAttachmentMaxBytes 工单附件大小上限，与图片转存（MAX_IMAGE_UPLOAD_MB）保持一致
+func TicketAttachmentMaxBytes() int64 {
+	return int64(constant.MaxImageUploadMB) * 1024 * 1024
+}
+
+// detectTicketAttachmentMimeType 仅允许图片与纯文本日志文件，返回规范化后的 MIME 类型
+func detectTicketAttachmentMimeType(data []byte) (string, error) {
+	mimeType := http.DetectContentType(data)
+	switch {
+	case mimeType == "image/png", mimeType == "image/jpeg", mimeType == "image/gif", mimeType == "image/webp":
+		return mimeType, nil
+	case strings.HasPrefix(mimeType, "text/plain"):
+		return "text/plain; charset=utf-8", nil
+	default:
+		return "", errors.New("仅支持上传图片或文本日志文件")
+	}
+}
+
+func normalizeTicketAttachmentName(name string) string {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	if utf8.RuneCountInString(name) > maxTicketAttachmentNameRunes {
+		runes := []rune(name)
+		name = string(runes[len(runes)-maxTicketAttachmentNameRunes:])
+	}
+	return name
+}
+
+// UploadTicketAttachment 上传附件并在工单中追加一条附件记录
+func UploadTicketAttachment(input UploadTicketAttachmentInput) (*TicketAttachmentInfo, error) {
+	if len(input.Data) == 0 {
+		return nil, errors.New("附件内容不能为空")
+	}
+	if maxBytes := TicketAttachmentMaxBytes(); maxBytes > 0 && int64(len(input.Data)) > maxBytes {
+		return nil, fmt.Errorf("附件大小超过限制 %d MB", constant.MaxImageUploadMB)
+	}
+	mimeType, err := detectTicketAttachmentMimeType(input.Data)
+	if err != nil {
+		return nil, err
+	}
+	content := strings.TrimSpace(input.Content)
+	if utf8.RuneCountInString(content) > maxTicketContentRunes {
+		return nil, errors.New("回复内容过长")
+	}
+
+	sum := sha256.Sum256(input.Data)
+	attachment := &model.TicketAttachment{
+		TicketId:  input.TicketId,
+		UserId:    input.UserId,
+		FileName:  normalizeTicketAttachmentName(input.FileName),
+		MimeType:  mimeType,
+		SizeBytes: len(input.Data),
+		Sha256:    hex.EncodeToString(sum[:]),
+		Data:      model.LargeBlob(input.Data),
+	}
+
+	err = model.DB.Transaction(func(tx *gorm.DB) error {
+		ticket, err := model.GetTicketByIDForUpdate(tx, input.TicketId)
+		if err != nil {
+			return err
+		}
+		if err := ensureTicketAccess(ticket, input.UserId, input.Role); err != nil {
+			return err
+		}
+
+		now := common.GetTimestamp()
+		entry := &model.TicketEntry{
+			TicketId:     ticket.Id,
+			EntryType:    model.TicketEntryTypeAttachment,
+			SenderUserId: input.UserId,
+			SenderName:   input.Username,
+			SenderRole:   input.Role,
+			Content:      content,
+			CreatedAt:    now,
+		}
+		if err := model.CreateTicketEntryTx(tx, entry); err != nil {
+			return errors.New("上传附件失败")
+		}
+		attachment.EntryId = entry.Id
+		attachment.CreatedAt = now
+		if err := model.CreateTicketAttachmentTx(tx, attachment); err != nil {
+			return errors.New("上传附件失败")
+		}
+		values := map[string]any{"updated_at": now}
+		if isTicketFirstResponse(ticket, input.UserId, input.Role) {
+			values["first_response_at"] = now
+		}
+		if err := model.UpdateTicketFieldsTx(tx, ticket.Id, values); err != nil {
+			return errors.New("上传附件失败")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TicketAttachmentInfo{
+		Id:        attachment.Id,
+		FileName:  attachment.FileName,
+		MimeType:  attachment.MimeType,
+		SizeBytes: attachment.SizeBytes,
+		CreatedAt: attachment.CreatedAt,
+	}, nil
+}
+
+// GetTicketAttachmentFile 获取附件内容，校验工单访问权限
+func GetTicketAttachmentFile(ticketId int, attachmentId string, userId int, role int) (*model.TicketAttachment, error) {
+	ticket, err := model.GetTicketByID(ticketId)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureTicketAccess(ticket, userId, role); err != nil {
+		return nil, err
+	}
+	return model.GetTicketAttachment(ticket.Id, strings.TrimSpace(attachmentId))
+}
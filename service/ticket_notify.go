@@ -0,0 +1,233 @@
+package service
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"html"
+	"net/mail"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/model"
+	"github.com/zhongruan0522/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	ticketReplyAddressTag     = "ticket"
+	ticketReplySignatureLen   = 20
+	maxTicketNotifySnippetLen = 500
+)
+
+var (
+	ErrInvalidTicketReplyAddress = errors.New("无效的工单回信地址")
+	ErrTicketReplySenderMismatch = errors.New("发件人与工单回信地址对应的用户不一致")
+)
+
+// InboundTicketEmail 收信 Webhook 传入的邮件内容，兼容 JSON 与表单（Mailgun 等）格式
+type InboundTicketEmail struct {
+	Recipient    string `json:"recipient" form:"recipient"`
+	Sender       string `json:"sender" form:"sender"`
+	Subject      string `json:"subject" form:"subject"`
+	Text         string `json:"text" form:"body-plain"`
+	StrippedText string `json:"stripped_text" form:"stripped-text"`
+}
+
+func signTicketReply(ticketId int, userId int) string {
+	return common.GenerateHMAC(fmt.Sprintf("ticket-reply:%d:%d", ticketId, userId))[:ticketReplySignatureLen]
+}
+
+// BuildTicketReplyAddress 生成带签名的工单回信地址，例如 support+ticket-12-3-<sig>@example.com；未启用收信时返回空
+func BuildTicketReplyAddress(ticketId int, userId int) string {
+	setting := operation_setting.GetTicketSetting()
+	if !setting.InboundEmailEnabled {
+		return ""
+	}
+	local, domain, ok := strings.Cut(strings.TrimSpace(setting.InboundReplyAddress), "@")
+	if !ok || local == "" || domain == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s+%s-%d-%d-%s@%s", local, ticketReplyAddressTag, ticketId, userId, signTicketReply(ticketId, userId), domain)
+}
+
+// ParseTicketReplyAddress 从收件人列表中找到工单回信地址并校验签名
+func ParseTicketReplyAddress(recipients string) (ticketId int, userId int, err error) {
+	local, domain, ok := strings.Cut(strings.TrimSpace(operation_setting.GetTicketSetting().InboundReplyAddress), "@")
+	if !ok {
+		return 0, 0, ErrInvalidTicketReplyAddress
+	}
+	addresses, err := mail.ParseAddressList(recipients)
+	if err != nil {
+		return 0, 0, ErrInvalidTicketReplyAddress
+	}
+	prefix := strings.ToLower(local + "+" + ticketReplyAddressTag + "-")
+	for _, address := range addresses {
+		addrLocal, addrDomain, ok := strings.Cut(address.Address, "@")
+		if !ok || !strings.EqualFold(addrDomain, domain) || !strings.HasPrefix(strings.ToLower(addrLocal), prefix) {
+			continue
+		}
+		parts := strings.Split(addrLocal[len(prefix):], "-")
+		if len(parts) != 3 {
+			continue
+		}
+		tid, err1 := strconv.Atoi(parts[0])
+		uid, err2 := strconv.Atoi(parts[1])
+		if err1 != nil || err2 != nil || tid <= 0 || uid <= 0 {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(strings.ToLower(parts[2])), []byte(signTicketReply(tid, uid))) != 1 {
+			continue
+		}
+		return tid, uid, nil
+	}
+	return 0, 0, ErrInvalidTicketReplyAddress
+}
+
+// VerifyInboundTicketWebhook 校验收信 Webhook 的访问密钥
+func VerifyInboundTicketWebhook(token string) bool {
+	setting := operation_setting.GetTicketSetting()
+	if !setting.InboundEmailEnabled || setting.InboundWebhookSecret == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(setting.InboundWebhookSecret)) == 1
+}
+
+// HandleInboundTicketEmail 将对通知邮件的回复写入工单
+func HandleInboundTicketEmail(email InboundTicketEmail) error {
+	if !operation_setting.GetTicketSetting().InboundEmailEnabled {
+		return errors.New("未启用邮件回复工单")
+	}
+	ticketId, userId, err := ParseTicketReplyAddress(email.Recipient)
+	if err != nil {
+		return err
+	}
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		return errors.New("用户不存在")
+	}
+	if user.Status != common.UserStatusEnabled {
+		return errors.New("用户已被封禁")
+	}
+	// 回信地址可能被转发或猜到，只接受该用户自己的邮箱发来的回复
+	if !ticketReplySenderMatches(email.Sender, user) {
+		common.SysLog(fmt.Sprintf("rejected inbound ticket reply for ticket %d: sender does not match user %d", ticketId, user.Id))
+		return ErrTicketReplySenderMismatch
+	}
+
+	body := email.StrippedText
+	if strings.TrimSpace(body) == "" {
+		body = email.Text
+	}
+	return ReplyTicket(ReplyTicketInput{
+		TicketId: ticketId,
+		UserId:   user.Id,
+		Username: user.Username,
+		Role:     user.Role,
+		Content:  stripQuotedEmailReply(body),
+	})
+}
+
+// ticketReplySenderMatches 校验发件人是否为用户的账号邮箱或通知邮箱
+func ticketReplySenderMatches(sender string, user *model.User) bool {
+	address, err := mail.ParseAddress(strings.TrimSpace(sender))
+	if err != nil {
+		return false
+	}
+	for _, email := range []string{user.Email, user.GetSetting().NotificationEmail} {
+		email = strings.TrimSpace(email)
+		if email != "" && strings.EqualFold(address.Address, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripQuotedEmailReply 去掉邮件客户端附带的引用原文，只保留本次回复内容
+func stripQuotedEmailReply(body string) string {
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, ">") ||
+			strings.HasPrefix(trimmed, "-----Original Message-----") ||
+			(strings.HasPrefix(trimmed, "On ") && strings.HasSuffix(trimmed, "wrote:")) ||
+			(strings.HasPrefix(trimmed, "在") && (strings.HasSuffix(trimmed, "写道：") || strings.HasSuffix(trimmed, "写道:"))) {
+			break
+		}
+		kept = append(kept, line)
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
+
+// ticketNotifyRecipients 管理员操作通知工单提交人，用户操作通知指派的管理员（未指派时通知 root）
+func ticketNotifyRecipients(ticket *model.Ticket, actorUserId int, actorRole int) []int {
+	if canManageAllTickets(actorRole) && actorUserId != ticket.UserId {
+		return []int{ticket.UserId}
+	}
+	if ticket.AssigneeId > 0 && ticket.AssigneeId != actorUserId {
+		return []int{ticket.AssigneeId}
+	}
+	if root := model.GetRootUser(); root != nil && root.Id != actorUserId {
+		return []int{root.Id}
+	}
+	return nil
+}
+
+func notifyTicketEvent(ticket *model.Ticket, actorUserId int, actorRole int, title string, content string) {
+	if !operation_setting.GetTicketSetting().NotifyEnabled {
+		return
+	}
+	recipients := ticketNotifyRecipients(ticket, actorUserId, actorRole)
+	if len(recipients) == 0 {
+		return
+	}
+	gopool.Go(func() {
+		for _, recipientId := range recipients {
+			user, err := model.GetUserById(recipientId, false)
+			if err != nil {
+				continue
+			}
+			data := dto.NewNotify(dto.NotifyTypeTicket, title, content, nil)
+			data.ReplyTo = BuildTicketReplyAddress(ticket.Id, user.Id)
+			if data.ReplyTo != "" {
+				data.Content += "<br/><br/>直接回复此邮件即可回复工单。"
+			}
+			if err := NotifyUser(user.Id, user.Email, user.GetSetting(), data); err != nil {
+				common.SysLog(fmt.Sprintf("failed to send ticket notification to user %d: %s", user.Id, err.Error()))
+			}
+		}
+	})
+}
+
+func formatTicketNotifySnippet(content string) string {
+	if utf8.RuneCountInString(content) > maxTicketNotifySnippetLen {
+		content = string([]rune(content)[:maxTicketNotifySnippetLen]) + "..."
+	}
+	return strings.ReplaceAll(html.EscapeString(content), "\n", "<br/>")
+}
+
+func notifyTicketCreated(ticket *model.Ticket, username string, role int, content string) {
+	notifyTicketEvent(ticket, ticket.UserId, role,
+		fmt.Sprintf("新工单 #%d：%s", ticket.Id, ticket.Title),
+		fmt.Sprintf("用户 %s 提交了新工单「%s」（优先级 %s）：<br/><br/>%s",
+			html.EscapeString(username), html.EscapeString(ticket.Title), model.TicketPriorityName(ticket.Priority), formatTicketNotifySnippet(content)))
+}
+
+func notifyTicketReplied(ticket *model.Ticket, userId int, username string, role int, content string) {
+	notifyTicketEvent(ticket, userId, role,
+		fmt.Sprintf("工单 #%d 有新回复", ticket.Id),
+		fmt.Sprintf("%s 回复了工单「%s」：<br/><br/>%s",
+			html.EscapeString(username), html.EscapeString(ticket.Title), formatTicketNotifySnippet(content)))
+}
+
+func notifyTicketStatusChanged(ticket *model.Ticket, userId int, username string, role int, status int) {
+	notifyTicketEvent(ticket, userId, role,
+		fmt.Sprintf("工单 #%d 状态已更新", ticket.Id),
+		fmt.Sprintf("%s 将工单「%s」的状态更新为 %s",
+			html.EscapeString(username), html.EscapeString(ticket.Title), model.TicketStatusName(status)))
+}
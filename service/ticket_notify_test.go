@@ -0,0 +1,85 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/model"
+	"github.com/zhongruan0522/new-api/setting/operation_setting"
+)
+
+func setupTicketInboundSetting(t *testing.T) {
+	t.Helper()
+
+	setting := operation_setting.GetTicketSetting()
+	old := *setting
+	setting.InboundEmailEnabled = true
+	setting.InboundReplyAddress = "support@example.com"
+	setting.InboundWebhookSecret = "inbound-secret"
+	t.Cleanup(func() {
+		*operation_setting.GetTicketSetting() = old
+	})
+}
+
+func TestTicketReplyAddressRoundTrip(t *testing.T) {
+	setupTicketInboundSetting(t)
+
+	address := BuildTicketReplyAddress(12, 3)
+	if !strings.HasPrefix(address, "support+ticket-12-3-") || !strings.HasSuffix(address, "@example.com") {
+		t.Fatalf("BuildTicketReplyAddress = %q", address)
+	}
+
+	ticketId, userId, err := ParseTicketReplyAddress("Other <other@example.com>, Support <" + address + ">")
+	if err != nil || ticketId != 12 || userId != 3 {
+		t.Fatalf("ParseTicketReplyAddress = (%d, %d, %v), want (12, 3, nil)", ticketId, userId, err)
+	}
+
+	forged := strings.Replace(address, "ticket-12-3-", "ticket-12-4-", 1)
+	if _, _, err := ParseTicketReplyAddress(forged); err != ErrInvalidTicketReplyAddress {
+		t.Fatalf("forged address error = %v, want %v", err, ErrInvalidTicketReplyAddress)
+	}
+}
+
+func TestVerifyInboundTicketWebhook(t *testing.T) {
+	setupTicketInboundSetting(t)
+
+	if !VerifyInboundTicketWebhook("inbound-secret") {
+		t.Fatal("expected matching token to be accepted")
+	}
+	if VerifyInboundTicketWebhook("wrong") || VerifyInboundTicketWebhook("") {
+		t.Fatal("expected mismatched token to be rejected")
+	}
+}
+
+func TestStripQuotedEmailReply(t *testing.T) {
+	body := "Thanks, it works now.\r\n\r\nOn Mon, Jan 1, 2026 at 10:00 AM Support <support@example.com> wrote:\r\n> old content\r\n"
+	if got := stripQuotedEmailReply(body); got != "Thanks, it works now." {
+		t.Fatalf("stripQuotedEmailReply = %q", got)
+	}
+	if got := stripQuotedEmailReply("已解决\n在 2026年1月1日 写道：\n> 原文"); got != "已解决" {
+		t.Fatalf("stripQuotedEmailReply = %q", got)
+	}
+}
+
+func TestTicketReplySenderMatches(t *testing.T) {
+	user := &model.User{Email: "Alice@Example.com"}
+	user.SetSetting(dto.UserSetting{NotificationEmail: "alerts@example.com"})
+
+	cases := []struct {
+		sender string
+		want   bool
+	}{
+		{"alice@example.com", true},
+		{"Alice <ALICE@example.com>", true},
+		{"alerts@example.com", true},
+		{"mallory@example.com", false},
+		{"", false},
+		{"not an address", false},
+	}
+	for _, tc := range cases {
+		if got := ticketReplySenderMatches(tc.sender, user); got != tc.want {
+			t.Fatalf("ticketReplySenderMatches(%q) = %v, want %v", tc.sender, got, tc.want)
+		}
+	}
+}
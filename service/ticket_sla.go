@@ -0,0 +1,86 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/dto"
+	"github.com/zhongruan0522/new-api/model"
+	"github.com/zhongruan0522/new-api/setting/operation_setting"
+)
+
+const ticketSlaCheckBatchSize = 100
+
+// computeTicketSlaDueAt 按优先级计算首次响应与解决截止时间，未配置时限时返回 0
+func computeTicketSlaDueAt(priority int, createdAt int64) (firstResponseDueAt int64, resolutionDueAt int64) {
+	firstResponseMinutes, resolutionMinutes := operation_setting.GetTicketSlaMinutes(model.TicketPriorityName(priority))
+	if firstResponseMinutes > 0 {
+		firstResponseDueAt = createdAt + int64(firstResponseMinutes)*60
+	}
+	if resolutionMinutes > 0 {
+		resolutionDueAt = createdAt + int64(resolutionMinutes)*60
+	}
+	return firstResponseDueAt, resolutionDueAt
+}
+
+// CheckTicketSla 检查超过 SLA 时限的工单并通知管理员，每个工单的每类超时只提醒一次
+func CheckTicketSla() (int, error) {
+	now := common.GetTimestamp()
+	notified := 0
+
+	tickets, err := model.ListTicketsFirstResponseOverdue(now, ticketSlaCheckBatchSize)
+	if err != nil {
+		return notified, err
+	}
+	for _, ticket := range tickets {
+		marked, err := model.MarkTicketSlaAlerted(ticket.Id, "first_response_alerted")
+		if err != nil {
+			return notified, err
+		}
+		if !marked {
+			continue
+		}
+		NotifyRootUser(dto.NotifyTypeTicketSla, "工单首次响应超时",
+			fmt.Sprintf("工单 #%d「%s」（优先级 %s）已超过首次响应时限，截止时间 %s",
+				ticket.Id, ticket.Title, model.TicketPriorityName(ticket.Priority), formatTicketSlaTime(ticket.FirstResponseDueAt)))
+		notified++
+	}
+
+	tickets, err = model.ListTicketsResolutionOverdue(now, ticketSlaCheckBatchSize)
+	if err != nil {
+		return notified, err
+	}
+	for _, ticket := range tickets {
+		marked, err := model.MarkTicketSlaAlerted(ticket.Id, "resolution_alerted")
+		if err != nil {
+			return notified, err
+		}
+		if !marked {
+			continue
+		}
+		NotifyRootUser(dto.NotifyTypeTicketSla, "工单解决超时",
+			fmt.Sprintf("工单 #%d「%s」（优先级 %s）已超过解决时限，截止时间 %s",
+				ticket.Id, ticket.Title, model.TicketPriorityName(ticket.Priority), formatTicketSlaTime(ticket.ResolutionDueAt)))
+		notified++
+	}
+	return notified, nil
+}
+
+// RunTicketSlaChecker 定时检查工单 SLA
+func RunTicketSlaChecker() {
+	for {
+		if operation_setting.GetTicketSetting().SlaEnabled {
+			if count, err := CheckTicketSla(); err != nil {
+				common.SysError("failed to check ticket sla: " + err.Error())
+			} else if count > 0 {
+				common.SysLog(fmt.Sprintf("sent %d ticket sla alerts", count))
+			}
+		}
+		time.Sleep(time.Minute)
+	}
+}
+
+func formatTicketSlaTime(timestamp int64) string {
+	return time.Unix(timestamp, 0).Format("2006-01-02 15:04:05")
+}
@@ -212,8 +212,7 @@ func EstimateRequestToken(c *gin.Context, meta *types.TokenCountMeta, info *rela
 			if err != nil {
 				return 0, fmt.Errorf("error getting audio duration: %v", err)
 			}
-			// 一分钟 1000 token，与 $price / minute 对齐
-			totalAudioToken += int(math.Round(math.Ceil(duration) / 60.0 * 1000))
+			totalAudioToken += AudioDurationToTokens(duration)
 		}
 		return totalAudioToken, nil
 	}
@@ -382,7 +381,7 @@ func CountAudioTokenInput(audioBase64 string, audioFormat string) (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	return int(duration / 60 * 100 / 0.06), nil
+	return AudioInputTokensForDuration(duration), nil
 }
 
 func CountAudioTokenOutput(audioBase64 string, audioFormat string) (int, error) {
@@ -393,7 +392,17 @@ func CountAudioTokenOutput(audioBase64 string, audioFormat string) (int, error)
 	if err != nil {
 		return 0, err
 	}
-	return int(duration / 60 * 200 / 0.24), nil
+	return AudioOutputTokensForDuration(duration), nil
+}
+
+// AudioInputTokensForDuration 按时长（秒）折算实时音频输入 token，与 OpenAI Realtime 的计量口径一致
+func AudioInputTokensForDuration(seconds float64) int {
+	return int(seconds / 60 * 100 / 0.06)
+}
+
+// AudioOutputTokensForDuration 按时长（秒）折算实时音频输出 token
+func AudioOutputTokensForDuration(seconds float64) int {
+	return int(seconds / 60 * 200 / 0.24)
 }
 
 // CountTextToken 统计文本的token数量，仅OpenAI模型使用tokenizer，其余模型使用估算
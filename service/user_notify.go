@@ -86,7 +86,7 @@ func sendEmailNotify(userEmail string, data dto.Notify) error {
 	for _, value := range data.Values {
 		content = strings.Replace(content, dto.ContentValueParam, fmt.Sprintf("%v", value), 1)
 	}
-	return common.SendEmail(data.Title, userEmail, content)
+	return common.SendEmailWithReplyTo(data.Title, userEmail, content, data.ReplyTo)
 }
 
 func sendBarkNotify(barkURL string, data dto.Notify) error {
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"math/rand"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/constant"
+	"github.com/zhongruan0522/new-api/model"
+	"github.com/zhongruan0522/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResolveVirtualModelTargets 返回虚拟模型本次请求的目标尝试顺序；name 不是虚拟模型时返回 false。
+// 配置了权重时按权重随机选出首选目标，其余目标保持配置顺序作为兜底。
+func ResolveVirtualModelTargets(name string) ([]string, bool) {
+	vm, ok := operation_setting.GetVirtualModelSetting().GetVirtualModel(name)
+	if !ok || len(vm.Targets) == 0 {
+		return nil, false
+	}
+	first := 0
+	totalWeight := 0
+	for _, target := range vm.Targets {
+		totalWeight += target.Weight
+	}
+	if totalWeight > 0 {
+		randomWeight := rand.Intn(totalWeight)
+		for i, target := range vm.Targets {
+			randomWeight -= target.Weight
+			if randomWeight < 0 {
+				first = i
+				break
+			}
+		}
+	}
+	targets := make([]string, 0, len(vm.Targets))
+	targets = append(targets, vm.Targets[first].Model)
+	for i, target := range vm.Targets {
+		if i != first {
+			targets = append(targets, target.Model)
+		}
+	}
+	return targets, true
+}
+
+// SetVirtualModelContext 记录客户端请求的虚拟模型及剩余的兜底目标
+func SetVirtualModelContext(c *gin.Context, virtualModel string, fallbacks []string) {
+	common.SetContextKey(c, constant.ContextKeyVirtualModel, virtualModel)
+	common.SetContextKey(c, constant.ContextKeyVirtualModelFallbacks, fallbacks)
+}
+
+// PopVirtualModelFallback 取出下一个兜底目标模型，没有剩余目标时返回 false
+func PopVirtualModelFallback(c *gin.Context) (string, bool) {
+	fallbacks, ok := common.GetContextKeyType[[]string](c, constant.ContextKeyVirtualModelFallbacks)
+	if !ok || len(fallbacks) == 0 {
+		return "", false
+	}
+	common.SetContextKey(c, constant.ContextKeyVirtualModelFallbacks, fallbacks[1:])
+	return fallbacks[0], true
+}
+
+// SelectVirtualModelTarget 跳过当前分组下没有可用渠道的目标，返回首个可用目标及其后的兜底目标；
+// 全部不可用时返回首个目标，由后续选渠道流程给出错误
+func SelectVirtualModelTarget(c *gin.Context, targets []string, group string) (string, []string) {
+	groups := []string{group}
+	if group == "auto" {
+		groups = GetUserAutoGroup(common.GetContextKeyString(c, constant.ContextKeyUserGroup))
+	}
+	for i, target := range targets {
+		for _, g := range groups {
+			if model.GetPriorityCount(g, target) > 0 {
+				return target, targets[i+1:]
+			}
+		}
+	}
+	return targets[0], targets[1:]
+}
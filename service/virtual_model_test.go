@@ -0,0 +1,73 @@
+package service
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/zhongruan0522/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setupVirtualModels(t *testing.T, models ...operation_setting.VirtualModel) {
+	t.Helper()
+	setting := operation_setting.GetVirtualModelSetting()
+	old := *setting
+	setting.Enabled = true
+	setting.Models = models
+	t.Cleanup(func() {
+		*setting = old
+	})
+}
+
+func TestResolveVirtualModelTargets(t *testing.T) {
+	setupVirtualModels(t,
+		operation_setting.VirtualModel{Name: "team-default", Targets: []operation_setting.VirtualModelTarget{
+			{Model: "claude-x"}, {Model: "gpt-x"}, {Model: "glm-x"},
+		}},
+		operation_setting.VirtualModel{Name: "ab", Targets: []operation_setting.VirtualModelTarget{
+			{Model: "a", Weight: 50}, {Model: "b", Weight: 50}, {Model: "c"},
+		}},
+	)
+
+	targets, ok := ResolveVirtualModelTargets("team-default")
+	if !ok || !reflect.DeepEqual(targets, []string{"claude-x", "gpt-x", "glm-x"}) {
+		t.Fatalf("team-default targets = %v, ok=%v", targets, ok)
+	}
+	if _, ok := ResolveVirtualModelTargets("gpt-x"); ok {
+		t.Fatal("plain model should not resolve as virtual model")
+	}
+
+	seen := map[string]bool{}
+	for i := 0; i < 200; i++ {
+		targets, _ := ResolveVirtualModelTargets("ab")
+		if len(targets) != 3 || targets[2] != "c" {
+			t.Fatalf("zero-weight target must stay as fallback, got %v", targets)
+		}
+		seen[targets[0]] = true
+	}
+	if !seen["a"] || !seen["b"] || seen["c"] {
+		t.Fatalf("unexpected primary targets: %v", seen)
+	}
+
+	operation_setting.GetVirtualModelSetting().Enabled = false
+	if _, ok := ResolveVirtualModelTargets("team-default"); ok {
+		t.Fatal("disabled setting should not resolve virtual models")
+	}
+}
+
+func TestPopVirtualModelFallback(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	SetVirtualModelContext(c, "team-default", []string{"gpt-x", "glm-x"})
+
+	for _, want := range []string{"gpt-x", "glm-x"} {
+		got, ok := PopVirtualModelFallback(c)
+		if !ok || got != want {
+			t.Fatalf("PopVirtualModelFallback = %q, %v; want %q", got, ok, want)
+		}
+	}
+	if _, ok := PopVirtualModelFallback(c); ok {
+		t.Fatal("expected no fallback left")
+	}
+}
@@ -0,0 +1,107 @@
+package model_setting
+
+import (
+	"strings"
+
+	"github.com/zhongruan0522/new-api/setting/config"
+)
+
+// VoiceSettings 网关音色目录：把 OpenAI 音色名映射为各渠道的音色，客户端切换后端时无需修改 voice 参数
+type VoiceSettings struct {
+	// 键为渠道名（与适配器 GetChannelName 一致），值为 OpenAI 音色到渠道音色的映射，"default" 为兜底音色
+	Catalog map[string]map[string]string `json:"catalog"`
+}
+
+// OpenAIVoices OpenAI TTS 内置音色
+var OpenAIVoices = []string{"alloy", "ash", "ballad", "coral", "echo", "fable", "nova", "onyx", "sage", "shimmer", "verse"}
+
+// 默认配置
+var defaultVoiceSettings = VoiceSettings{
+	Catalog: map[string]map[string]string{
+		"google gemini": {
+			"default": "Kore",
+			"alloy":   "Kore",
+			"ash":     "Orus",
+			"ballad":  "Enceladus",
+			"coral":   "Aoede",
+			"echo":    "Puck",
+			"fable":   "Fenrir",
+			"nova":    "Leda",
+			"onyx":    "Charon",
+			"sage":    "Iapetus",
+			"shimmer": "Zephyr",
+			"verse":   "Umbriel",
+		},
+		"zhipu_4v": {
+			"default": "tongtong",
+			"alloy":   "tongtong",
+			"coral":   "chuichui",
+			"shimmer": "xiaochen",
+			"echo":    "jam",
+			"fable":   "kazi",
+			"onyx":    "douji",
+			"ash":     "luodo",
+		},
+		"siliconflow": {
+			"default": "alex",
+			"alloy":   "alex",
+			"ash":     "benjamin",
+			"onyx":    "charles",
+			"echo":    "david",
+			"nova":    "anna",
+			"shimmer": "bella",
+			"coral":   "claire",
+			"fable":   "diana",
+		},
+		"minimax": {
+			"default": "male-qn-qingse",
+			"alloy":   "female-shaonv",
+			"nova":    "female-yujie",
+			"shimmer": "female-chengshu",
+			"fable":   "female-tianmei",
+			"echo":    "male-qn-jingying",
+			"onyx":    "male-qn-badao",
+			"ash":     "presenter_male",
+			"coral":   "presenter_female",
+		},
+	},
+}
+
+// 全局实例
+var voiceSettings = defaultVoiceSettings
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("voice", &voiceSettings)
+}
+
+// GetVoiceSettings 获取音色目录配置
+func GetVoiceSettings() *VoiceSettings {
+	return &voiceSettings
+}
+
+// GetProviderVoice 将请求中的音色转换为渠道音色。
+// 目录中有映射时使用映射值；未映射的 OpenAI 音色或空音色使用渠道的默认音色；其余视为渠道原生音色原样透传
+func GetProviderVoice(channelName string, voice string) string {
+	voice = strings.TrimSpace(voice)
+	voices := voiceSettings.Catalog[channelName]
+	if len(voices) == 0 {
+		return voice
+	}
+	if mapped, ok := voices[strings.ToLower(voice)]; ok && mapped != "" {
+		return mapped
+	}
+	if voice == "" || isOpenAIVoice(voice) {
+		return voices["default"]
+	}
+	return voice
+}
+
+func isOpenAIVoice(voice string) bool {
+	for _, v := range OpenAIVoices {
+		if strings.EqualFold(v, voice) {
+			return true
+		}
+	}
+	return false
+}
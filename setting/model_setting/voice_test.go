@@ -0,0 +1,26 @@
+package model_setting
+
+import "testing"
+
+func TestGetProviderVoice(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel string
+		voice   string
+		want    string
+	}{
+		{name: "mapped openai voice", channel: "google gemini", voice: "alloy", want: "Kore"},
+		{name: "mapped voice is case insensitive", channel: "siliconflow", voice: "Nova", want: "anna"},
+		{name: "unmapped openai voice uses default", channel: "zhipu_4v", voice: "ballad", want: "tongtong"},
+		{name: "empty voice uses default", channel: "minimax", voice: " ", want: "male-qn-qingse"},
+		{name: "native voice passes through", channel: "google gemini", voice: "Charon", want: "Charon"},
+		{name: "channel without catalog passes through", channel: "unknown", voice: "alloy", want: "alloy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetProviderVoice(tt.channel, tt.voice); got != tt.want {
+				t.Fatalf("GetProviderVoice(%q, %q) = %q, want %q", tt.channel, tt.voice, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package operation_setting
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zhongruan0522/new-api/setting/config"
+)
+
+// ChannelScriptSetting 渠道转换脚本的全局开关与资源限制
+type ChannelScriptSetting struct {
+	Enabled           bool `json:"enabled"`             // 关闭后所有渠道脚本都不执行
+	MaxExecutionSteps int  `json:"max_execution_steps"` // 单次调用最多执行的 Starlark 步数，限制 CPU 占用
+	TimeoutMs         int  `json:"timeout_ms"`          // 单次调用的最长执行时间（毫秒）
+}
+
+// 默认配置
+var channelScriptSetting = ChannelScriptSetting{
+	Enabled:           true,
+	MaxExecutionSteps: 1000000,
+	TimeoutMs:         100,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("channel_script_setting", &channelScriptSetting)
+}
+
+// GetChannelScriptSetting 获取渠道脚本配置
+func GetChannelScriptSetting() *ChannelScriptSetting {
+	return &channelScriptSetting
+}
+
+// GetMaxExecutionSteps 返回步数上限，配置非法时按默认值处理
+func (s *ChannelScriptSetting) GetMaxExecutionSteps() uint64 {
+	if s.MaxExecutionSteps <= 0 {
+		return 1000000
+	}
+	return uint64(s.MaxExecutionSteps)
+}
+
+// GetTimeout 返回单次执行超时，配置非法时按默认值处理
+func (s *ChannelScriptSetting) GetTimeout() time.Duration {
+	if s.TimeoutMs <= 0 {
+		return 100 * time.Millisecond
+	}
+	return time.Duration(s.TimeoutMs) * time.Millisecond
+}
+
+// ValidateChannelScriptPositiveInt 校验步数上限/超时为正整数
+func ValidateChannelScriptPositiveInt(value string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("invalid number: %s", value)
+	}
+	if n <= 0 {
+		return fmt.Errorf("value must be greater than 0")
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package operation_setting
+
+import "github.com/zhongruan0522/new-api/setting/config"
+
+// McpSetting 内置 MCP 服务配置，各工具未显式指定模型时使用这里的默认模型
+type McpSetting struct {
+	Enabled            bool   `json:"enabled"`             // 是否开放 /mcp 服务
+	VisionModel        string `json:"vision_model"`        // describe_image 使用的视觉模型
+	TranscriptionModel string `json:"transcription_model"` // transcribe_audio 使用的语音识别模型
+	ImageModel         string `json:"image_model"`         // generate_image 使用的绘图模型
+	EmbeddingModel     string `json:"embedding_model"`     // embed_text 使用的向量模型
+}
+
+// 默认配置
+var mcpSetting = McpSetting{
+	Enabled:            false,
+	VisionModel:        "gpt-4o-mini",
+	TranscriptionModel: "whisper-1",
+	ImageModel:         "dall-e-3",
+	EmbeddingModel:     "text-embedding-3-small",
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("mcp_setting", &mcpSetting)
+}
+
+// GetMcpSetting 获取 MCP 服务配置
+func GetMcpSetting() *McpSetting {
+	return &mcpSetting
+}
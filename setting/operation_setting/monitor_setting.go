@@ -10,12 +10,20 @@ import (
 type MonitorSetting struct {
 	AutoTestChannelEnabled bool    `json:"auto_test_channel_enabled"`
 	AutoTestChannelMinutes float64 `json:"auto_test_channel_minutes"`
+
+	AutoUpdateBalanceEnabled    bool    `json:"auto_update_balance_enabled"`    // 定时查询上游余额
+	AutoUpdateBalanceMinutes    float64 `json:"auto_update_balance_minutes"`    // 余额查询间隔
+	BalanceHistoryRetentionDays int     `json:"balance_history_retention_days"` // 余额历史保留天数，0 表示不清理
 }
 
 // 默认配置
 var monitorSetting = MonitorSetting{
 	AutoTestChannelEnabled: false,
 	AutoTestChannelMinutes: 10,
+
+	AutoUpdateBalanceEnabled:    false,
+	AutoUpdateBalanceMinutes:    30,
+	BalanceHistoryRetentionDays: 90,
 }
 
 func init() {
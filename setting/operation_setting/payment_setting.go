@@ -1,16 +1,30 @@
 package operation_setting
 
-import "github.com/zhongruan0522/new-api/setting/config"
+import (
+	"fmt"
+	"sort"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/setting/config"
+)
 
 type PaymentSetting struct {
-	AmountOptions  []int           `json:"amount_options"`
-	AmountDiscount map[int]float64 `json:"amount_discount"` // 充值金额对应的折扣，例如 100 元 0.9 表示 100 元充值享受 9 折优惠
+	AmountOptions  []int            `json:"amount_options"`
+	AmountDiscount map[int]float64  `json:"amount_discount"` // 充值金额对应的折扣，例如 100 元 0.9 表示 100 元充值享受 9 折优惠
+	BonusTiers     []TopUpBonusTier `json:"bonus_tiers"`     // 阶梯赠送，充值数量达到门槛时按比例额外赠送额度
+}
+
+// TopUpBonusTier 充值阶梯赠送规则
+type TopUpBonusTier struct {
+	MinAmount  int64   `json:"min_amount"`  // 充值数量门槛（含）
+	BonusRatio float64 `json:"bonus_ratio"` // 赠送比例，例如 0.1 表示额外赠送 10% 额度
 }
 
 // 默认配置
 var paymentSetting = PaymentSetting{
 	AmountOptions:  []int{10, 20, 50, 100, 200, 500},
 	AmountDiscount: map[int]float64{},
+	BonusTiers:     []TopUpBonusTier{},
 }
 
 func init() {
@@ -21,3 +35,37 @@ func init() {
 func GetPaymentSetting() *PaymentSetting {
 	return &paymentSetting
 }
+
+// GetTopUpBonusRatio 返回充值数量命中的最高阶梯赠送比例，未命中返回 0
+func GetTopUpBonusRatio(amount int64) float64 {
+	ratio := 0.0
+	var matched int64 = -1
+	for _, tier := range paymentSetting.BonusTiers {
+		if amount >= tier.MinAmount && tier.MinAmount > matched && tier.BonusRatio > 0 {
+			matched = tier.MinAmount
+			ratio = tier.BonusRatio
+		}
+	}
+	return ratio
+}
+
+// ValidateTopUpBonusTiers 校验阶梯赠送配置
+func ValidateTopUpBonusTiers(jsonStr string) error {
+	var tiers []TopUpBonusTier
+	if err := common.Unmarshal([]byte(jsonStr), &tiers); err != nil {
+		return fmt.Errorf("invalid JSON: %v", err)
+	}
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].MinAmount < tiers[j].MinAmount })
+	for i, tier := range tiers {
+		if tier.MinAmount <= 0 {
+			return fmt.Errorf("tier %d: min_amount must be positive", i+1)
+		}
+		if tier.BonusRatio <= 0 || tier.BonusRatio > 10 {
+			return fmt.Errorf("tier %d: bonus_ratio must be between 0 and 10", i+1)
+		}
+		if i > 0 && tiers[i-1].MinAmount == tier.MinAmount {
+			return fmt.Errorf("duplicate min_amount %d", tier.MinAmount)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package operation_setting
+
+import (
+	"fmt"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/setting/config"
+)
+
+// maxReferralLevels 限制多级返佣的最大层级，防止配置过深导致链路查询放大
+const maxReferralLevels = 5
+
+// ReferralSetting 充值返佣配置
+type ReferralSetting struct {
+	Enabled     bool      `json:"enabled"`      // 是否启用充值返佣
+	Rates       []float64 `json:"rates"`        // 各级返佣比例，下标 0 为直接邀请人，例如 [0.1, 0.02]
+	HoldingDays int       `json:"holding_days"` // 返佣冻结天数，冻结期满后才可划转，0 表示立即可用
+}
+
+// 默认配置
+var referralSetting = ReferralSetting{
+	Enabled:     false,
+	Rates:       []float64{0.05},
+	HoldingDays: 7,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("referral_setting", &referralSetting)
+}
+
+// GetReferralSetting 获取充值返佣配置
+func GetReferralSetting() *ReferralSetting {
+	if referralSetting.HoldingDays < 0 {
+		referralSetting.HoldingDays = 0
+	}
+	return &referralSetting
+}
+
+// GetReferralRates 返回生效的各级返佣比例；未启用时返回 nil
+func GetReferralRates() []float64 {
+	if !referralSetting.Enabled {
+		return nil
+	}
+	rates := referralSetting.Rates
+	if len(rates) > maxReferralLevels {
+		rates = rates[:maxReferralLevels]
+	}
+	return rates
+}
+
+// ValidateReferralRates 校验返佣比例配置
+func ValidateReferralRates(jsonStr string) error {
+	var rates []float64
+	if err := common.Unmarshal([]byte(jsonStr), &rates); err != nil {
+		return fmt.Errorf("invalid JSON: %v", err)
+	}
+	if len(rates) > maxReferralLevels {
+		return fmt.Errorf("at most %d levels are supported", maxReferralLevels)
+	}
+	total := 0.0
+	for i, rate := range rates {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("level %d: rate must be between 0 and 1", i+1)
+		}
+		total += rate
+	}
+	if total > 1 {
+		return fmt.Errorf("sum of rates cannot exceed 1")
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package operation_setting
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/zhongruan0522/new-api/setting/config"
+)
+
+// ResponsesStoreSetting 网关侧存储 Responses 输出的配置，用于支持 previous_response_id 及检索/删除接口
+type ResponsesStoreSetting struct {
+	Enabled       bool `json:"enabled"`         // 是否在网关侧保存 store 不为 false 的响应
+	RetentionDays int  `json:"retention_days"`  // 保留天数，过期的响应不可再引用并会被定期清理
+	MaxChainDepth int  `json:"max_chain_depth"` // 展开 previous_response_id 时最多回溯的响应数
+}
+
+// 默认配置
+var responsesStoreSetting = ResponsesStoreSetting{
+	Enabled:       false,
+	RetentionDays: 30,
+	MaxChainDepth: 100,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("responses_store_setting", &responsesStoreSetting)
+}
+
+// GetResponsesStoreSetting 获取 Responses 存储配置
+func GetResponsesStoreSetting() *ResponsesStoreSetting {
+	return &responsesStoreSetting
+}
+
+// RetentionSeconds 返回保留时长（秒），配置非法时按 30 天处理
+func (s *ResponsesStoreSetting) RetentionSeconds() int64 {
+	days := s.RetentionDays
+	if days <= 0 {
+		days = 30
+	}
+	return int64(days) * 24 * 60 * 60
+}
+
+// GetMaxChainDepth 返回展开历史时的最大回溯深度
+func (s *ResponsesStoreSetting) GetMaxChainDepth() int {
+	if s.MaxChainDepth <= 0 {
+		return 100
+	}
+	return s.MaxChainDepth
+}
+
+// ValidateResponsesStorePositiveInt 校验保留天数/回溯深度为正整数
+func ValidateResponsesStorePositiveInt(value string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("invalid number: %s", value)
+	}
+	if n <= 0 {
+		return fmt.Errorf("value must be greater than 0")
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package operation_setting
+
+import (
+	"fmt"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/setting/config"
+)
+
+// TicketSetting 工单配置，SLA 时限按优先级名称（low/normal/high/urgent）配置，单位分钟
+type TicketSetting struct {
+	SlaEnabled           bool           `json:"sla_enabled"`            // 是否启用 SLA 超时提醒
+	FirstResponseMinutes map[string]int `json:"first_response_minutes"` // 首次响应时限，0 或未配置表示不限
+	ResolutionMinutes    map[string]int `json:"resolution_minutes"`     // 解决时限，0 或未配置表示不限
+
+	NotifyEnabled        bool   `json:"notify_enabled"`         // 工单创建、回复和状态变更时发送通知
+	InboundEmailEnabled  bool   `json:"inbound_email_enabled"`  // 是否允许通过回复通知邮件来回复工单
+	InboundReplyAddress  string `json:"inbound_reply_address"`  // 收信地址，例如 support@example.com，回信地址会附加签名后缀
+	InboundWebhookSecret string `json:"inbound_webhook_secret"` // 收信 Webhook 的访问密钥
+}
+
+// 默认配置
+var ticketSetting = TicketSetting{
+	SlaEnabled: false,
+	FirstResponseMinutes: map[string]int{
+		"low":    24 * 60,
+		"normal": 8 * 60,
+		"high":   2 * 60,
+		"urgent": 30,
+	},
+	ResolutionMinutes: map[string]int{
+		"low":    7 * 24 * 60,
+		"normal": 3 * 24 * 60,
+		"high":   24 * 60,
+		"urgent": 4 * 60,
+	},
+	NotifyEnabled: true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("ticket_setting", &ticketSetting)
+}
+
+// GetTicketSetting 获取工单配置
+func GetTicketSetting() *TicketSetting {
+	return &ticketSetting
+}
+
+// GetTicketSlaMinutes 返回指定优先级的首次响应与解决时限；未启用 SLA 时均返回 0
+func GetTicketSlaMinutes(priority string) (firstResponse int, resolution int) {
+	if !ticketSetting.SlaEnabled {
+		return 0, 0
+	}
+	return ticketSetting.FirstResponseMinutes[priority], ticketSetting.ResolutionMinutes[priority]
+}
+
+// ValidateTicketSlaMinutes 校验 SLA 时限配置
+func ValidateTicketSlaMinutes(jsonStr string) error {
+	var minutes map[string]int
+	if err := common.Unmarshal([]byte(jsonStr), &minutes); err != nil {
+		return fmt.Errorf("invalid JSON: %v", err)
+	}
+	for priority, value := range minutes {
+		switch priority {
+		case "low", "normal", "high", "urgent":
+		default:
+			return fmt.Errorf("unknown priority: %s", priority)
+		}
+		if value < 0 {
+			return fmt.Errorf("%s: minutes cannot be negative", priority)
+		}
+	}
+	return nil
+}
@@ -14,13 +14,20 @@ const (
 	ToolBillingModePerCall = "per_call"
 )
 
+const (
+	ToolTypeWebSearch       = "web_search"
+	ToolTypeImageGeneration = "image_generation"
+	// ToolTypeGatewayTool covers admin-registered tools executed server-side by the relay.
+	ToolTypeGatewayTool = "gateway_tool"
+)
+
 // ToolBillingRule is a single pricing rule for one tool.
 type ToolBillingRule struct {
 	// Unique identifier, e.g. "web_search_openai", "image_generation_high_1024x1024"
 	ID string `json:"id"`
 	// Human-readable name shown in the UI
 	Name string `json:"name"`
-	// Which tool this rule applies to: "web_search", "image_generation"
+	// Which tool this rule applies to: "web_search", "image_generation", "gateway_tool"
 	ToolType string `json:"tool_type"`
 	// Billing mode: "per_call"
 	BillingMode string `json:"billing_mode"`
@@ -35,6 +42,8 @@ type ToolBillingRule struct {
 	Size string `json:"size,omitempty"`
 	// Optional provider filter: "openai", "claude", "gemini". Empty means all providers.
 	Provider string `json:"provider,omitempty"`
+	// Optional gateway tool name filter (for gateway_tool, comma-separated). Empty means all gateway tools.
+	ToolName string `json:"tool_name,omitempty"`
 	// Whether this rule is enabled
 	Enabled bool `json:"enabled"`
 }
@@ -286,6 +295,25 @@ func GetToolBillingPrice(toolType, modelName, provider, quality, size string) (f
 	return 0, false
 }
 
+// GetGatewayToolBillingPrice looks up the per-call price (USD) for a gateway tool.
+// Rules are matched by tool name (exact, or prefix with a trailing '*') and model.
+func GetGatewayToolBillingPrice(toolName, modelName string) (float64, bool) {
+	for i := range toolBillingSetting.Rules {
+		rule := &toolBillingSetting.Rules[i]
+		if !rule.Enabled || rule.ToolType != ToolTypeGatewayTool {
+			continue
+		}
+		if rule.ToolName != "" && !matchModelFilter(toolName, rule.ToolName) {
+			continue
+		}
+		if rule.ModelFilter != "" && !matchModelFilter(modelName, rule.ModelFilter) {
+			continue
+		}
+		return rule.Price, true
+	}
+	return 0, false
+}
+
 // GetToolBillingRules returns all configured rules.
 func GetToolBillingRules() []ToolBillingRule {
 	return toolBillingSetting.Rules
@@ -310,7 +338,7 @@ func ValidateToolBillingRules(jsonStr string) error {
 			return fmt.Errorf("rule %d (%s): tool_type is required", i, rule.ID)
 		}
 		rule.ToolType = strings.ToLower(rule.ToolType)
-		if rule.ToolType != "web_search" && rule.ToolType != "image_generation" {
+		if rule.ToolType != ToolTypeWebSearch && rule.ToolType != ToolTypeImageGeneration && rule.ToolType != ToolTypeGatewayTool {
 			return fmt.Errorf("rule %d (%s): unsupported tool_type %q", i, rule.ID, rule.ToolType)
 		}
 		if rule.BillingMode != ToolBillingModePerCall {
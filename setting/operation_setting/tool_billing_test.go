@@ -217,6 +217,41 @@ func TestValidateToolBillingRules(t *testing.T) {
 	}
 }
 
+func TestGetGatewayToolBillingPrice(t *testing.T) {
+	original := toolBillingSetting.Rules
+	defer func() { toolBillingSetting.Rules = original }()
+
+	toolBillingSetting.Rules = append(append([]ToolBillingRule{}, original...),
+		ToolBillingRule{
+			ID:          "gateway_weather",
+			ToolType:    ToolTypeGatewayTool,
+			BillingMode: ToolBillingModePerCall,
+			Price:       0.002,
+			ToolName:    "weather*",
+			Enabled:     true,
+		},
+		ToolBillingRule{
+			ID:          "gateway_default",
+			ToolType:    ToolTypeGatewayTool,
+			BillingMode: ToolBillingModePerCall,
+			Price:       0.001,
+			Enabled:     true,
+		},
+	)
+
+	if price, ok := GetGatewayToolBillingPrice("weather_lookup", "gpt-4o"); !ok || price != 0.002 {
+		t.Errorf("GetGatewayToolBillingPrice(weather_lookup) = %v, %v; want 0.002, true", price, ok)
+	}
+	if price, ok := GetGatewayToolBillingPrice("search", "gpt-4o"); !ok || price != 0.001 {
+		t.Errorf("GetGatewayToolBillingPrice(search) = %v, %v; want 0.001, true", price, ok)
+	}
+
+	toolBillingSetting.Rules = original
+	if _, ok := GetGatewayToolBillingPrice("search", "gpt-4o"); ok {
+		t.Error("GetGatewayToolBillingPrice without gateway rules should return false")
+	}
+}
+
 func TestValidateToolBillingRules_InvalidJSON(t *testing.T) {
 	err := ValidateToolBillingRules("not json")
 	if err == nil {
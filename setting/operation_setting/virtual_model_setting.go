@@ -0,0 +1,103 @@
+package operation_setting
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zhongruan0522/new-api/common"
+	"github.com/zhongruan0522/new-api/setting/config"
+)
+
+// VirtualModelTarget 虚拟模型的一个目标模型
+type VirtualModelTarget struct {
+	Model string `json:"model"`
+	// A/B 分流权重；所有目标权重均为 0 时按配置顺序，否则按权重随机选出首选目标，其余目标按配置顺序兜底
+	Weight int `json:"weight,omitempty"`
+}
+
+// VirtualModel 全局虚拟模型：请求该名称时按目标顺序依次尝试，当前目标的渠道全部失败后回退到下一个目标
+type VirtualModel struct {
+	Name        string               `json:"name"`
+	Description string               `json:"description,omitempty"`
+	Targets     []VirtualModelTarget `json:"targets"`
+}
+
+// TargetNames 返回按配置顺序的全部目标模型名
+func (m VirtualModel) TargetNames() []string {
+	names := make([]string, 0, len(m.Targets))
+	for _, target := range m.Targets {
+		names = append(names, target.Model)
+	}
+	return names
+}
+
+type VirtualModelSetting struct {
+	Enabled bool           `json:"enabled"`
+	Models  []VirtualModel `json:"models"`
+}
+
+var virtualModelSetting = VirtualModelSetting{
+	Enabled: true,
+	Models:  []VirtualModel{},
+}
+
+func init() {
+	config.GlobalConfig.Register("virtual_model_setting", &virtualModelSetting)
+}
+
+func GetVirtualModelSetting() *VirtualModelSetting {
+	return &virtualModelSetting
+}
+
+// GetVirtualModel 按名称查找虚拟模型，未启用或不存在时返回 false
+func (s *VirtualModelSetting) GetVirtualModel(name string) (VirtualModel, bool) {
+	if !s.Enabled || name == "" {
+		return VirtualModel{}, false
+	}
+	for _, m := range s.Models {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return VirtualModel{}, false
+}
+
+// ValidateVirtualModels 校验 virtual_model_setting.models 配置
+func ValidateVirtualModels(jsonStr string) error {
+	var models []VirtualModel
+	if err := common.Unmarshal([]byte(jsonStr), &models); err != nil {
+		return fmt.Errorf("invalid JSON: %v", err)
+	}
+	names := make(map[string]bool, len(models))
+	for _, m := range models {
+		if strings.TrimSpace(m.Name) == "" {
+			return fmt.Errorf("虚拟模型名称不能为空")
+		}
+		if names[m.Name] {
+			return fmt.Errorf("虚拟模型 %s 重复", m.Name)
+		}
+		names[m.Name] = true
+	}
+	for _, m := range models {
+		if len(m.Targets) == 0 {
+			return fmt.Errorf("虚拟模型 %s 至少需要一个目标模型", m.Name)
+		}
+		seen := make(map[string]bool, len(m.Targets))
+		for _, target := range m.Targets {
+			if strings.TrimSpace(target.Model) == "" {
+				return fmt.Errorf("虚拟模型 %s 的目标模型不能为空", m.Name)
+			}
+			if names[target.Model] {
+				return fmt.Errorf("虚拟模型 %s 的目标 %s 不能是虚拟模型", m.Name, target.Model)
+			}
+			if seen[target.Model] {
+				return fmt.Errorf("虚拟模型 %s 的目标 %s 重复", m.Name, target.Model)
+			}
+			if target.Weight < 0 {
+				return fmt.Errorf("虚拟模型 %s 的目标 %s 权重不能为负数", m.Name, target.Model)
+			}
+			seen[target.Model] = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package operation_setting
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateVirtualModels(t *testing.T) {
+	valid := `[{"name":"team-default","targets":[{"model":"claude-x","weight":80},{"model":"gpt-x","weight":20},{"model":"glm-x"}]}]`
+	if err := ValidateVirtualModels(valid); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{"empty name", `[{"name":"","targets":[{"model":"a"}]}]`, "名称不能为空"},
+		{"duplicate name", `[{"name":"v","targets":[{"model":"a"}]},{"name":"v","targets":[{"model":"b"}]}]`, "重复"},
+		{"no targets", `[{"name":"v","targets":[]}]`, "至少需要一个目标模型"},
+		{"nested virtual", `[{"name":"v1","targets":[{"model":"a"}]},{"name":"v2","targets":[{"model":"v1"}]}]`, "不能是虚拟模型"},
+		{"negative weight", `[{"name":"v","targets":[{"model":"a","weight":-1}]}]`, "不能为负数"},
+		{"invalid json", `{`, "invalid JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateVirtualModels(tt.input)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ValidateVirtualModels(%s) = %v, want error containing %q", tt.input, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -13,6 +13,7 @@ const (
 	RelayFormatOpenAIAudio                           = "openai_audio"
 	RelayFormatOpenAIImage                           = "openai_image"
 	RelayFormatOpenAIRealtime                        = "openai_realtime"
+	RelayFormatOpenAIVideo                           = "openai_video"
 	RelayFormatRerank                                = "rerank"
 	RelayFormatEmbedding                             = "embedding"
 )
@@ -25,7 +26,7 @@ func RelayFormatToPreferredAPIType(format RelayFormat) int {
 		RelayFormatOpenAIResponsesCompaction,
 		RelayFormatOpenAIAudio, RelayFormatOpenAIImage,
 		RelayFormatOpenAIRealtime, RelayFormatRerank,
-		RelayFormatEmbedding:
+		RelayFormatEmbedding, RelayFormatOpenAIVideo:
 		return constant.APITypeOpenAI
 	case RelayFormatClaude:
 		return constant.APITypeAnthropic
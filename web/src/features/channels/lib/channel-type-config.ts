@@ -84,6 +84,17 @@ export const CHANNEL_TYPE_CONFIGS: Record<number, ChannelTypeConfig> = {
       models: 'Use model names from Ollama',
     },
   },
+  44: {
+    id: 44,
+    name: CHANNEL_TYPES[44],
+    icon: 'vllm',
+    defaultBaseUrl: 'http://localhost:8000',
+    hints: {
+      baseUrl: 'Server root without /v1, e.g. http://localhost:8000',
+      key: 'Optional API key',
+      models: 'Fetch model names from /v1/models',
+    },
+  },
   6: {
     id: 6,
     name: CHANNEL_TYPES[6],
@@ -113,6 +124,17 @@ export const CHANNEL_TYPE_CONFIGS: Record<number, ChannelTypeConfig> = {
       models: 'claude-3-opus,claude-3-sonnet,claude-3-haiku',
     },
   },
+  17: {
+    id: 17,
+    name: CHANNEL_TYPES[17],
+    icon: 'alibabacloud',
+    defaultBaseUrl: 'https://dashscope.aliyuncs.com',
+    hints: {
+      baseUrl: 'Default: https://dashscope.aliyuncs.com',
+      key: 'DashScope API Key',
+      models: 'qwen-plus,qwen-vl-max,text-embedding-v4,wanx2.1-t2i-turbo',
+    },
+  },
   24: {
     id: 24,
     name: CHANNEL_TYPES[24],
@@ -182,6 +204,17 @@ export const CHANNEL_TYPE_CONFIGS: Record<number, ChannelTypeConfig> = {
       models: 'AWS Bedrock model IDs',
     },
   },
+  34: {
+    id: 34,
+    name: CHANNEL_TYPES[34],
+    icon: 'cohere',
+    defaultBaseUrl: 'https://api.cohere.com',
+    hints: {
+      baseUrl: 'Default: https://api.cohere.com',
+      key: 'Cohere API Key',
+      models: 'command-a-03-2025,embed-v4.0,rerank-v3.5',
+    },
+  },
   35: {
     id: 35,
     name: CHANNEL_TYPES[35],
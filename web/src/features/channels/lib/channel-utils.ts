@@ -52,15 +52,18 @@ export function getChannelTypeIcon(type: number): string {
     6: 'Xiaomi', // Xiaomi
     8: 'OpenAI', // Custom
     14: 'Claude', // Anthropic
+    17: 'AlibabaCloud', // DashScope
     20: 'OpenRouter', // OpenRouter
     24: 'Gemini', // Gemini
     25: 'Moonshot', // Moonshot
     26: 'Zhipu', // Zhipu V4
     33: 'Aws', // AWS
+    34: 'Cohere', // Cohere
     35: 'Minimax', // MiniMax
     40: 'SiliconCloud', // SiliconFlow
     41: 'Gemini', // Vertex AI
     43: 'DeepSeek', // DeepSeek
+    44: 'Vllm', // Self-Hosted
   }
 
   return TYPE_TO_ICON[type] || 'OpenAI'
@@ -43,6 +43,14 @@ import {
   FormMessage,
 } from '@/components/ui/form'
 import { Input } from '@/components/ui/input'
+import {
+  Select,
+  SelectContent,
+  SelectGroup,
+  SelectItem,
+  SelectTrigger,
+  SelectValue,
+} from '@/components/ui/select'
 import {
   Sheet,
   SheetClose,
@@ -66,7 +74,12 @@ import {
 } from '@/components/drawer-layout'
 import { MultiSelect } from '@/components/multi-select'
 import { createApiKey, updateApiKey, getApiKey } from '../api'
-import { ERROR_MESSAGES, SUCCESS_MESSAGES } from '../constants'
+import {
+  ERROR_MESSAGES,
+  REASONING_OUTPUT_DEFAULT,
+  REASONING_OUTPUT_OPTIONS,
+  SUCCESS_MESSAGES,
+} from '../constants'
 import {
   getApiKeyFormSchema,
   type ApiKeyFormValues,
@@ -761,6 +774,53 @@ export function ApiKeysMutateDrawer({
                         </FormItem>
                       )}
                     />
+
+                    <FormField
+                      control={form.control}
+                      name='reasoning_output'
+                      render={({ field }) => (
+                        <FormItem>
+                          <FormLabel>{t('Reasoning Output')}</FormLabel>
+                          <Select
+                            items={REASONING_OUTPUT_OPTIONS.map((option) => ({
+                              value: option.value,
+                              label: t(option.label),
+                            }))}
+                            onValueChange={(value) =>
+                              value !== null &&
+                              field.onChange(
+                                value === REASONING_OUTPUT_DEFAULT ? '' : value
+                              )
+                            }
+                            value={field.value || REASONING_OUTPUT_DEFAULT}
+                          >
+                            <FormControl>
+                              <SelectTrigger>
+                                <SelectValue />
+                              </SelectTrigger>
+                            </FormControl>
+                            <SelectContent alignItemWithTrigger={false}>
+                              <SelectGroup>
+                                {REASONING_OUTPUT_OPTIONS.map((option) => (
+                                  <SelectItem
+                                    key={option.value}
+                                    value={option.value}
+                                  >
+                                    {t(option.label)}
+                                  </SelectItem>
+                                ))}
+                              </SelectGroup>
+                            </SelectContent>
+                          </Select>
+                          <FormDescription>
+                            {t(
+                              'How reasoning is returned to Chat Completions clients. The X-Reasoning-Output request header overrides this setting.'
+                            )}
+                          </FormDescription>
+                          <FormMessage />
+                        </FormItem>
+                      )}
+                    />
                   </div>
                 </CollapsibleContent>
               </SideDrawerSection>
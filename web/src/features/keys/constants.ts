@@ -66,6 +66,21 @@ export const API_KEY_STATUS_OPTIONS = Object.values(API_KEY_STATUSES).map(
   })
 )
 
+// ============================================================================
+// Reasoning Output Modes
+// label values are i18n keys; '' follows the channel setting
+// ============================================================================
+
+export const REASONING_OUTPUT_DEFAULT = 'default' as const
+
+export const REASONING_OUTPUT_OPTIONS = [
+  { value: REASONING_OUTPUT_DEFAULT, label: 'Follow channel setting' },
+  { value: 'native', label: 'Native (as returned upstream)' },
+  { value: 'field', label: 'Separate field (reasoning_content)' },
+  { value: 'inline', label: 'Inline <think> tags' },
+  { value: 'hidden', label: 'Hidden' },
+] as const
+
 // ============================================================================
 // Default Values
 // ============================================================================
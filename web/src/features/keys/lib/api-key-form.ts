@@ -43,6 +43,7 @@ export function getApiKeyFormSchema(t: TFunction) {
       allow_ips: z.string().optional(),
       group: z.string().optional(),
       cross_group_retry: z.boolean().optional(),
+      reasoning_output: z.string().optional(),
       tokenCount: z.number().min(1).optional(),
     })
     .superRefine((data, ctx) => {
@@ -123,6 +124,7 @@ export const API_KEY_FORM_DEFAULT_VALUES: ApiKeyFormValues = {
   allow_ips: '',
   group: DEFAULT_GROUP,
   cross_group_retry: true,
+  reasoning_output: '',
   tokenCount: 1,
 }
 
@@ -161,6 +163,7 @@ export function transformFormDataToPayload(
     allow_ips: data.allow_ips || '',
     group: data.group || '',
     cross_group_retry: data.group === 'auto' ? !!data.cross_group_retry : false,
+    reasoning_output: data.reasoning_output || '',
     quota_type: quotaType,
     window_hours: quotaType >= 2 ? data.window_hours || 1 : 0,
     window_quota:
@@ -204,6 +207,7 @@ export function transformApiKeyToFormDefaults(
     allow_ips: apiKey.allow_ips || '',
     group: apiKey.group || DEFAULT_GROUP,
     cross_group_retry: !!apiKey.cross_group_retry,
+    reasoning_output: apiKey.reasoning_output || '',
     tokenCount: 1,
   }
 }
@@ -34,6 +34,7 @@ export const apiKeySchema = z.object({
   created_time: z.number(),
   accessed_time: z.number(),
   group: z.string().nullish().default(''),
+  reasoning_output: z.string().nullish().default(''),
   cross_group_retry: z
     .preprocess((v) => {
       if (v === 1) return true
@@ -107,6 +108,7 @@ export interface ApiKeyFormData {
   allow_ips: string
   group: string
   cross_group_retry: boolean
+  reasoning_output: string
   quota_type: number
   window_hours: number
   window_quota: number